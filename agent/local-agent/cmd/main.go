@@ -16,6 +16,7 @@ import (
 	"github.com/vokzal-tech/local-agent/internal/handlers"
 	"github.com/vokzal-tech/local-agent/internal/kkt"
 	"github.com/vokzal-tech/local-agent/internal/printer"
+	"github.com/vokzal-tech/local-agent/internal/qrverify"
 	"github.com/vokzal-tech/local-agent/internal/scanner"
 	"github.com/vokzal-tech/local-agent/internal/tts"
 )
@@ -66,6 +67,25 @@ func main() {
 		logger,
 	)
 
+	qrVerifier := qrverify.NewVerifier(
+		cfg.QR.KeysURL,
+		cfg.QR.CachePath,
+		cfg.QR.Enabled,
+		logger,
+	)
+	if cfg.QR.Enabled {
+		go func() {
+			ticker := time.NewTicker(cfg.QR.RefreshInterval)
+			defer ticker.Stop()
+			for {
+				if refreshErr := qrVerifier.Refresh(); refreshErr != nil {
+					logger.Warn("Failed to refresh QR keys, using cached set", zap.Error(refreshErr))
+				}
+				<-ticker.C
+			}
+		}()
+	}
+
 	ttsClient := tts.NewTTSClient(
 		cfg.TTS.Engine,
 		cfg.TTS.Voice,
@@ -75,7 +95,7 @@ func main() {
 	)
 
 	// Инициализировать handlers
-	handler := handlers.NewAgentHandler(kktClient, printerClient, scannerClient, ttsClient, logger)
+	handler := handlers.NewAgentHandler(kktClient, printerClient, scannerClient, qrVerifier, ttsClient, logger)
 
 	// Настроить Gin
 	gin.SetMode(cfg.Server.Mode)
@@ -91,10 +111,12 @@ func main() {
 	kktGroup.GET("/status", handler.GetKKTStatus)
 	printerGroup := router.Group("/printer")
 	printerGroup.POST("/ticket", handler.PrintTicket)
+	printerGroup.POST("/baggage-tag", handler.PrintBaggageTag)
 	printerGroup.GET("/status", handler.GetPrinterStatus)
 	scannerGroup := router.Group("/scanner")
 	scannerGroup.POST("/scan", handler.ScanBarcode)
 	scannerGroup.GET("/status", handler.GetScannerStatus)
+	scannerGroup.POST("/verify", handler.VerifyTicketQR)
 	ttsGroup := router.Group("/tts")
 	ttsGroup.POST("/announce", handler.Announce)
 	ttsGroup.GET("/status", handler.GetTTSStatus)
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,6 +13,7 @@ type Config struct {
 	KKT     KKTConfig     `mapstructure:"kkt"`
 	Printer PrinterConfig `mapstructure:"printer"`
 	Scanner ScannerConfig `mapstructure:"scanner"`
+	QR      QRConfig      `mapstructure:"qr"`
 	Server  ServerConfig  `mapstructure:"server"`
 	Logger  LoggerConfig  `mapstructure:"logger"`
 	TTS     TTSConfig     `mapstructure:"tts"`
@@ -47,6 +49,15 @@ type ScannerConfig struct {
 	Enabled    bool   `mapstructure:"enabled"`
 }
 
+// QRConfig — офлайн-проверка подписанных QR-кодов билетов.
+// Набор открытых ключей загружается из ticket-service и кэшируется в CachePath.
+type QRConfig struct {
+	KeysURL         string        `mapstructure:"keys_url"`
+	CachePath       string        `mapstructure:"cache_path"`
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"`
+	Enabled         bool          `mapstructure:"enabled"`
+}
+
 // TTSConfig — настройки голосовых оповещений.
 type TTSConfig struct {
 	Engine  string `mapstructure:"engine"`
@@ -81,6 +92,10 @@ func Load() (*Config, error) {
 	viper.SetDefault("printer.type", "escpos")
 	viper.SetDefault("scanner.enabled", true)
 	viper.SetDefault("scanner.mode", "hid")
+	viper.SetDefault("qr.enabled", true)
+	viper.SetDefault("qr.keys_url", "http://localhost:8083/v1/tickets/qr/keys")
+	viper.SetDefault("qr.cache_path", "/var/lib/vokzal/agent/qr-keys.json")
+	viper.SetDefault("qr.refresh_interval", "15m")
 	viper.SetDefault("tts.enabled", true)
 	viper.SetDefault("tts.engine", "rhvoice")
 	viper.SetDefault("tts.voice", "alena")
@@ -8,6 +8,7 @@ import (
 
 	"github.com/vokzal-tech/local-agent/internal/kkt"
 	"github.com/vokzal-tech/local-agent/internal/printer"
+	"github.com/vokzal-tech/local-agent/internal/qrverify"
 	"github.com/vokzal-tech/local-agent/internal/scanner"
 	"github.com/vokzal-tech/local-agent/internal/tts"
 )
@@ -17,6 +18,7 @@ type AgentHandler struct {
 	kkt     *kkt.ATOLClient
 	printer *printer.PrinterClient
 	scanner *scanner.ScannerClient
+	qr      *qrverify.Verifier
 	tts     *tts.TTSClient
 	logger  *zap.Logger
 }
@@ -26,6 +28,7 @@ func NewAgentHandler(
 	kktClient *kkt.ATOLClient,
 	printerClient *printer.PrinterClient,
 	scannerClient *scanner.ScannerClient,
+	qrVerifier *qrverify.Verifier,
 	ttsClient *tts.TTSClient,
 	logger *zap.Logger,
 ) *AgentHandler {
@@ -33,6 +36,7 @@ func NewAgentHandler(
 		kkt:     kktClient,
 		printer: printerClient,
 		scanner: scannerClient,
+		qr:      qrVerifier,
 		tts:     ttsClient,
 		logger:  logger,
 	}
@@ -111,6 +115,24 @@ func (h *AgentHandler) PrintTicket(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// PrintBaggageTag печатает багажную бирку.
+func (h *AgentHandler) PrintBaggageTag(c *gin.Context) {
+	var req printer.BaggageTagData
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error("Invalid baggage tag data", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+
+	if err := h.printer.PrintBaggageTag(&req); err != nil {
+		h.logger.Error("Failed to print baggage tag", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 // GetPrinterStatus возвращает статус принтера.
 func (h *AgentHandler) GetPrinterStatus(c *gin.Context) {
 	status, err := h.printer.GetStatus()
@@ -140,9 +162,30 @@ func (h *AgentHandler) ScanBarcode(c *gin.Context) {
 // GetScannerStatus возвращает статус сканера.
 func (h *AgentHandler) GetScannerStatus(c *gin.Context) {
 	status := h.scanner.GetStatus()
+	status["qr_keys"] = h.qr.GetStatus()
 	c.JSON(http.StatusOK, status)
 }
 
+// VerifyTicketQR проверяет подпись и срок действия QR-кода билета без обращения к серверу.
+func (h *AgentHandler) VerifyTicketQR(c *gin.Context) {
+	var req struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code is required"})
+		return
+	}
+
+	claims, err := h.qr.Verify(req.Code)
+	if err != nil {
+		h.logger.Info("Ticket QR rejected", zap.Error(err))
+		c.JSON(http.StatusOK, qrverify.Result{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, qrverify.Result{Claims: claims, Valid: true})
+}
+
 // ============= TTS Endpoints =============
 
 // Announce добавляет голосовое оповещение.
@@ -31,6 +31,20 @@ type TicketData struct {
 	Price        float64
 }
 
+// BaggageTagData — данные багажной бирки для печати.
+type BaggageTagData struct {
+	BaggageID    string
+	TicketID     string
+	Route        string
+	Date         string
+	Time         string
+	Seat         string
+	PassengerFIO string
+	WeightClass  string
+	BarCode      string
+	Pieces       int
+}
+
 // NewPrinterClient создаёт клиент принтера.
 func NewPrinterClient(devicePath, printerType string, enabled bool, logger *zap.Logger) *PrinterClient {
 	return &PrinterClient{
@@ -51,9 +65,43 @@ func (p *PrinterClient) PrintTicket(data *TicketData) error {
 	p.logger.Info("Printing ticket", zap.String("ticket_id", data.TicketID))
 
 	// ESC/POS команды для термопринтера
-	commands := p.generateESCPOSCommands(data)
+	if err := p.write(p.generateESCPOSCommands(data)); err != nil {
+		return err
+	}
+
+	p.logger.Info("Ticket printed successfully")
+	return nil
+}
 
-	// Отправить команды на принтер
+// PrintBaggageTag печатает багажную бирку (по одной на каждое место багажа).
+func (p *PrinterClient) PrintBaggageTag(data *BaggageTagData) error {
+	if !p.enabled {
+		p.logger.Info("Printer disabled, simulating baggage tag print")
+		return nil
+	}
+
+	p.logger.Info("Printing baggage tag",
+		zap.String("baggage_id", data.BaggageID),
+		zap.Int("pieces", data.Pieces))
+
+	pieces := data.Pieces
+	if pieces < 1 {
+		pieces = 1
+	}
+	var commands []byte
+	for i := 1; i <= pieces; i++ {
+		commands = append(commands, p.generateBaggageTagCommands(data, i, pieces)...)
+	}
+	if err := p.write(commands); err != nil {
+		return err
+	}
+
+	p.logger.Info("Baggage tag printed successfully")
+	return nil
+}
+
+// write отправляет команды на устройство принтера.
+func (p *PrinterClient) write(commands []byte) error {
 	file, err := os.OpenFile(p.devicePath, os.O_WRONLY, 0o600)
 	if err != nil {
 		return fmt.Errorf("failed to open printer: %w", err)
@@ -67,8 +115,6 @@ func (p *PrinterClient) PrintTicket(data *TicketData) error {
 	if _, err := file.Write(commands); err != nil {
 		return fmt.Errorf("failed to write to printer: %w", err)
 	}
-
-	p.logger.Info("Ticket printed successfully")
 	return nil
 }
 
@@ -124,6 +170,48 @@ func (p *PrinterClient) generateESCPOSCommands(data *TicketData) []byte {
 	return buf
 }
 
+func (p *PrinterClient) generateBaggageTagCommands(data *BaggageTagData, piece, total int) []byte {
+	var buf []byte
+
+	// ESC @ — Reset; выравнивание по центру; жирный шрифт.
+	buf = append(buf, 0x1B, 0x40, 0x1B, 0x61, 0x01, 0x1B, 0x45, 0x01)
+	buf = append(buf, []byte("Вокзал.ТЕХ\n")...)
+	buf = append(buf, []byte("БАГАЖ\n")...)
+	buf = append(buf, 0x1B, 0x45, 0x00)
+	buf = append(buf, []byte(fmt.Sprintf("Место %d из %d\n\n", piece, total))...)
+
+	// Выравнивание слева
+	buf = append(buf, 0x1B, 0x61, 0x00)
+
+	buf = append(buf, []byte(fmt.Sprintf("Квитанция: %s\n", data.BaggageID))...)
+	buf = append(buf, []byte(fmt.Sprintf("Билет: %s\n", data.TicketID))...)
+	buf = append(buf, []byte(fmt.Sprintf("Маршрут: %s\n", data.Route))...)
+	buf = append(buf, []byte(fmt.Sprintf("Дата: %s, %s\n", data.Date, data.Time))...)
+
+	if data.Seat != "" {
+		buf = append(buf, []byte(fmt.Sprintf("Место пассажира: %s\n", data.Seat))...)
+	}
+
+	if data.PassengerFIO != "" {
+		buf = append(buf, []byte(fmt.Sprintf("Пассажир: %s\n", data.PassengerFIO))...)
+	}
+
+	buf = append(buf, []byte(fmt.Sprintf("Категория: %s\n\n", data.WeightClass))...)
+
+	// Barcode
+	if data.BarCode != "" {
+		buf = append(buf, 0x1B, 0x61, 0x01) // Center
+		buf = append(buf, []byte(fmt.Sprintf("ШК: %s\n", data.BarCode))...)
+	}
+
+	buf = append(buf, []byte("\n--------------------------------\n\n")...)
+
+	// Отрезать бирку
+	buf = append(buf, 0x1D, 0x56, 0x00)
+
+	return buf
+}
+
 // GetStatus получает статус принтера.
 func (p *PrinterClient) GetStatus() (map[string]interface{}, error) {
 	if !p.enabled {
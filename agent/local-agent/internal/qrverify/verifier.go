@@ -0,0 +1,162 @@
+// Package qrverify проверяет подписанные QR-коды билетов без связи с сервером.
+package qrverify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/ticketqr"
+)
+
+// ErrNoKeys возвращается, если набор ключей ещё ни разу не был загружен.
+var ErrNoKeys = errors.New("QR signing keys are not loaded")
+
+// Verifier хранит набор открытых ключей ticket-service и проверяет по нему QR-коды.
+// Набор кэшируется в файл, чтобы проверка работала после перезапуска агента без сети.
+type Verifier struct {
+	fetchedAt time.Time
+	keys      *ticketqr.KeySet
+	client    *http.Client
+	logger    *zap.Logger
+	keysURL   string
+	cachePath string
+	mu        sync.RWMutex
+	enabled   bool
+}
+
+// Result — результат проверки кода.
+type Result struct {
+	Claims *ticketqr.Claims `json:"claims,omitempty"`
+	Error  string           `json:"error,omitempty"`
+	Valid  bool             `json:"valid"`
+}
+
+// NewVerifier создаёт проверяющий и загружает набор ключей из файлового кэша.
+func NewVerifier(keysURL, cachePath string, enabled bool, logger *zap.Logger) *Verifier {
+	v := &Verifier{
+		keysURL:   keysURL,
+		cachePath: cachePath,
+		enabled:   enabled,
+		logger:    logger,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	if enabled {
+		v.loadCache()
+	}
+	return v
+}
+
+// Refresh загружает актуальный набор ключей с сервера и сохраняет его в кэш.
+// При ошибке сети продолжает действовать ранее загруженный набор.
+func (v *Verifier) Refresh() error {
+	if !v.enabled {
+		return nil
+	}
+
+	resp, err := v.client.Get(v.keysURL)
+	if err != nil {
+		return fmt.Errorf("failed to fetch QR keys: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			v.logger.Warn("failed to close response body", zap.Error(closeErr))
+		}
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch QR keys: status %d", resp.StatusCode)
+	}
+
+	var keys ticketqr.KeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return fmt.Errorf("failed to decode QR keys: %w", err)
+	}
+
+	v.mu.Lock()
+	v.keys = &keys
+	v.fetchedAt = time.Now()
+	v.mu.Unlock()
+
+	if err := v.saveCache(&keys); err != nil {
+		v.logger.Warn("Failed to cache QR keys", zap.Error(err))
+	}
+	v.logger.Debug("QR keys refreshed", zap.Int("keys", len(keys.Keys)))
+	return nil
+}
+
+// Verify проверяет подпись и срок действия кода.
+func (v *Verifier) Verify(code string) (*ticketqr.Claims, error) {
+	v.mu.RLock()
+	keys := v.keys
+	v.mu.RUnlock()
+
+	if keys == nil {
+		return nil, ErrNoKeys
+	}
+	return ticketqr.Verify(code, keys, time.Now())
+}
+
+// GetStatus возвращает состояние набора ключей.
+func (v *Verifier) GetStatus() map[string]interface{} {
+	if !v.enabled {
+		return map[string]interface{}{
+			"status": "disabled",
+		}
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.keys == nil {
+		return map[string]interface{}{
+			"status": "no_keys",
+		}
+	}
+	return map[string]interface{}{
+		"status":     "ready",
+		"keys":       len(v.keys.Keys),
+		"fetched_at": v.fetchedAt,
+	}
+}
+
+func (v *Verifier) loadCache() {
+	data, err := os.ReadFile(v.cachePath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			v.logger.Warn("Failed to read QR keys cache", zap.Error(err))
+		}
+		return
+	}
+
+	var keys ticketqr.KeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		v.logger.Warn("Invalid QR keys cache", zap.Error(err))
+		return
+	}
+	if info, statErr := os.Stat(v.cachePath); statErr == nil {
+		v.fetchedAt = info.ModTime()
+	}
+	v.keys = &keys
+	v.logger.Info("QR keys loaded from cache", zap.Int("keys", len(keys.Keys)))
+}
+
+func (v *Verifier) saveCache(keys *ticketqr.KeySet) error {
+	data, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.cachePath), 0o750); err != nil {
+		return err
+	}
+	tmp := v.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.cachePath)
+}
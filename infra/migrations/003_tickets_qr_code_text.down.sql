@@ -0,0 +1,15 @@
+-- Migration: 003_tickets_qr_code_text (rollback)
+-- Description: Возврат qr_code к VARCHAR(255) после проверки, что нет кодов длиннее 255 символов
+
+DO $$
+DECLARE
+  cnt INTEGER;
+BEGIN
+  SELECT COUNT(*) INTO cnt FROM tickets WHERE char_length(qr_code) > 255;
+  IF cnt > 0 THEN
+    RAISE EXCEPTION 'Cannot rollback tickets.qr_code to VARCHAR(255): % row(s) exceed 255 characters (signed QR codes)', cnt;
+  END IF;
+END $$;
+
+ALTER TABLE tickets
+  ALTER COLUMN qr_code TYPE VARCHAR(255);
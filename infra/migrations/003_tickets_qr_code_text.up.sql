@@ -0,0 +1,5 @@
+-- Migration: 003_tickets_qr_code_text
+-- Description: Подписанный QR-код билета ("VT1.<kid>.<payload>.<signature>") длиннее 255 символов
+
+ALTER TABLE tickets
+  ALTER COLUMN qr_code TYPE TEXT;
@@ -12,6 +12,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/vokzal-tech/go-common/eventbus"
 
 	"github.com/vokzal-tech/audit-service/internal/config"
 	"github.com/vokzal-tech/audit-service/internal/handlers"
@@ -78,8 +81,26 @@ func main() {
 	auditRepo := repository.NewAuditRepository(db)
 	auditService := service.NewAuditService(auditRepo, logger)
 
-	// Подписаться на события
-	auditService.SubscribeToEvents(natsConn)
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Durable-консьюмер: записи аудита не теряются, пока сервис остановлен
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "audit",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	auditService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
 
 	auditHandler := handlers.NewAuditHandler(auditService, logger)
 
@@ -104,6 +125,8 @@ func main() {
 	audit.GET("/user", auditHandler.GetLogsByUser)
 	audit.GET("/date-range", auditHandler.GetLogsByDateRange)
 	audit.GET("/:id", auditHandler.GetLog)
+	// Необработанные события audit.log (роль admin)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "audit"), logger).Register(audit.Group("/dead-letters"))
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
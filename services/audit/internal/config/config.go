@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,6 +17,7 @@ type Config struct {
 	NATS     NATSConfig     `mapstructure:"nats"`
 	Server   ServerConfig   `mapstructure:"server"`
 	Logger   LoggerConfig   `mapstructure:"logger"`
+	Consumer ConsumerConfig `mapstructure:"consumer"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -46,6 +48,13 @@ type LoggerConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
 // Load читает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -70,6 +79,10 @@ func Load() (*Config, error) {
 	viper.SetDefault("nats.password", "nats_secret_2026")
 	viper.SetDefault("logger.level", "debug")
 
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
+
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
 		if !errors.As(err, &notFound) {
@@ -3,10 +3,11 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 
-	"github.com/nats-io/nats.go"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
 
 	"github.com/vokzal-tech/audit-service/internal/models"
 	"github.com/vokzal-tech/audit-service/internal/repository"
@@ -22,7 +23,7 @@ type AuditService interface {
 	GetLogsByUser(ctx context.Context, userID string, limit int) ([]*models.AuditLog, error)
 	GetLogsByDateRange(ctx context.Context, from, to string) ([]*models.AuditLog, error)
 	ListLogs(ctx context.Context, limit int) ([]*models.AuditLog, error)
-	SubscribeToEvents(nc *nats.Conn)
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type auditService struct {
@@ -106,43 +107,32 @@ func (s *auditService) ListLogs(ctx context.Context, limit int) ([]*models.Audit
 	return s.repo.List(ctx, limit)
 }
 
-// SubscribeToEvents подписывается на NATS-события для автоматического логирования.
-func (s *auditService) SubscribeToEvents(nc *nats.Conn) {
-	_, err := nc.Subscribe("audit.log", func(msg *nats.Msg) {
-		var data map[string]interface{}
-		if unmarshalErr := json.Unmarshal(msg.Data, &data); unmarshalErr != nil {
-			s.logger.Error("Failed to unmarshal audit.log event", zap.Error(unmarshalErr))
-			return
-		}
-
-		entityType, ok1 := data["entity_type"].(string)
-		entityID, ok2 := data["entity_id"].(string)
-		action, ok3 := data["action"].(string)
-		if !ok1 || !ok2 || !ok3 || entityType == "" || entityID == "" || action == "" {
-			s.logger.Warn("audit.log event missing required fields")
-			return
-		}
+// errInvalidAuditEvent возвращается для события audit.log без обязательных полей.
+var errInvalidAuditEvent = errors.New("audit.log event missing required fields")
 
-		ctx := context.Background()
-		req := &CreateLogRequest{
-			EntityType: entityType,
-			EntityID:   entityID,
-			Action:     action,
-			OldValue:   data["old_value"],
-			NewValue:   data["new_value"],
-		}
+// RegisterEventHandlers регистрирует обработчик событий audit.log в durable-консьюмере.
+func (s *auditService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypeAuditLog, events.Handler(s.handleAuditEvent))
+}
 
-		if userID, ok := data["user_id"].(string); ok {
-			req.UserID = &userID
-		}
+// handleAuditEvent создаёт запись аудита из события. Битое событие уходит в dead letters,
+// ошибка записи в БД — повтор.
+func (s *auditService) handleAuditEvent(ctx context.Context, event *events.AuditLog) error {
+	if event.EntityType == "" || event.EntityID == "" || event.Action == "" {
+		return eventbus.Permanent(errInvalidAuditEvent)
+	}
 
-		if _, createErr := s.CreateLog(ctx, req); createErr != nil {
-			s.logger.Error("Failed to create audit log from NATS", zap.Error(createErr))
-		}
-	})
-	if err != nil {
-		s.logger.Error("Failed to subscribe to audit.log", zap.Error(err))
-		return
+	req := &CreateLogRequest{
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		Action:     event.Action,
+		OldValue:   event.OldValue,
+		NewValue:   event.NewValue,
 	}
-	s.logger.Info("Subscribed to NATS events: audit.log")
+	if event.UserID != "" {
+		req.UserID = &event.UserID
+	}
+
+	_, err := s.CreateLog(ctx, req)
+	return err
 }
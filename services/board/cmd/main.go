@@ -3,7 +3,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
@@ -14,8 +13,12 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"github.com/redis/go-redis/v9"
 
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
 	"github.com/vokzal-tech/board-service/internal/cache"
 	"github.com/vokzal-tech/board-service/internal/config"
 	"github.com/vokzal-tech/board-service/internal/handlers"
@@ -33,70 +36,48 @@ func initLogger(cfg *config.Config) (*zap.Logger, error) {
 	return zap.NewDevelopment()
 }
 
-func subscribeNATS(natsConn *nats.Conn, redisCache *cache.RedisCache, hub *websocket.Hub, logger *zap.Logger) {
-	ctx := context.Background()
-	_, err := natsConn.Subscribe("trip.created", func(msg *nats.Msg) {
-		var data map[string]interface{}
-		if unmarshalErr := json.Unmarshal(msg.Data, &data); unmarshalErr != nil {
-			logger.Error("Failed to unmarshal trip.created", zap.Error(unmarshalErr))
-			return
-		}
-		if date, ok := data["date"].(string); ok {
-			if invErr := redisCache.InvalidateTrips(ctx, date); invErr != nil {
-				logger.Warn("failed to invalidate trips cache", zap.Error(invErr), zap.String("date", date))
-			}
-		}
-		var tripID string
-		if id, ok := data["id"].(string); ok {
-			tripID = id
+// registerEventHandlers регистрирует обработчики событий рейсов: инвалидация кэша табло
+// и рассылка обновления клиентам WebSocket. Ошибка Redis — повтор события (кэш не должен остаться
+// устаревшим), битое событие уходит в dead letters.
+func registerEventHandlers(consumer *eventbus.Consumer, redisCache *cache.RedisCache, hub *websocket.Hub) {
+	consumer.Handle(events.TypeTripCreated, events.Handler(func(ctx context.Context, trip *events.TripCreated) error {
+		if err := redisCache.InvalidateTrips(ctx, trip.Date); err != nil {
+			return fmt.Errorf("failed to invalidate trips cache for %s: %w", trip.Date, err)
 		}
 		hub.Broadcast(&websocket.Message{
 			Type:   "trip_created",
-			TripID: tripID,
-			Data:   data,
+			TripID: trip.ID,
+			Data:   trip.Trip,
 		})
-	})
-	if err != nil {
-		logger.Error("Failed to subscribe to trip.created", zap.Error(err))
-	}
-	_, err = natsConn.Subscribe("trip.status_changed", func(msg *nats.Msg) {
-		var data map[string]interface{}
-		if unmarshalErr := json.Unmarshal(msg.Data, &data); unmarshalErr != nil {
-			logger.Error("Failed to unmarshal trip.status_changed", zap.Error(unmarshalErr))
-			return
+		return nil
+	}))
+	// Добавленный или снятый автобус меняет строки табло и перроны посадки
+	consumer.Handle(events.TypeTripUpdated, events.Handler(func(ctx context.Context, trip *events.TripUpdated) error {
+		if err := redisCache.InvalidateTrips(ctx, trip.Date); err != nil {
+			return fmt.Errorf("failed to invalidate trips cache for %s: %w", trip.Date, err)
 		}
-
+		hub.Broadcast(&websocket.Message{
+			Type:   "trip_updated",
+			TripID: trip.ID,
+			Data:   trip.Trip,
+		})
+		return nil
+	}))
+	consumer.Handle(events.TypeTripStatusChanged, events.Handler(func(ctx context.Context, trip *events.TripStatusChanged) error {
 		// Инвалидировать кэш
-		if date, ok := data["date"].(string); ok {
-			if invErr := redisCache.InvalidateTrips(ctx, date); invErr != nil {
-				logger.Warn("failed to invalidate trips cache", zap.Error(invErr), zap.String("date", date))
-			}
+		if err := redisCache.InvalidateTrips(ctx, trip.Date); err != nil {
+			return fmt.Errorf("failed to invalidate trips cache for %s: %w", trip.Date, err)
 		}
 
 		// Отправить обновление через WebSocket
-		var tripID, status string
-		if id, ok := data["id"].(string); ok {
-			tripID = id
-		}
-		if s, ok := data["status"].(string); ok {
-			status = s
-		}
-		message := &websocket.Message{
-			Type:   "trip_update",
-			TripID: tripID,
-			Status: status,
-		}
-
-		if delay, ok := data["delay_minutes"].(float64); ok {
-			message.DelayMinutes = int(delay)
-		}
-
-		hub.Broadcast(message)
-	})
-	if err != nil {
-		logger.Error("Failed to subscribe to trip.status_changed", zap.Error(err))
-	}
-	logger.Info("Subscribed to NATS events: trip.created, trip.status_changed")
+		hub.Broadcast(&websocket.Message{
+			Type:         "trip_update",
+			TripID:       trip.ID,
+			Status:       trip.Status,
+			DelayMinutes: trip.DelayMinutes,
+		})
+		return nil
+	}))
 }
 
 func main() {
@@ -149,9 +130,29 @@ func main() {
 	defer natsConn.Close()
 	logger.Info("Connected to NATS", zap.String("url", cfg.NATS.URL))
 
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(ctx, js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
 	hub := websocket.NewHub(logger)
 	go hub.Run()
-	subscribeNATS(natsConn, redisCache, hub, logger)
+
+	// Durable-консьюмер: после перезапуска табло получает изменения рейсов, пропущенные за время простоя
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "board",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	registerEventHandlers(consumer, redisCache, hub)
+	if consumeErr := consumer.Start(ctx); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
 
 	var allowedOrigins []string
 	for _, o := range strings.Split(cfg.WebSocket.AllowedOrigins, ",") {
@@ -178,6 +179,8 @@ func main() {
 	board.GET("/public", boardHandler.GetPublicBoard)
 	board.GET("/platform/:platform", boardHandler.GetPlatformBoard)
 	board.GET("/stats", boardHandler.GetWebSocketStats)
+	// Необработанные события рейсов (роль admin)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "board"), logger).Register(board.Group("/dead-letters"))
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
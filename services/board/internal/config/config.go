@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -16,6 +17,7 @@ type Config struct {
 	Database  DatabaseConfig  `mapstructure:"database"`
 	WebSocket WebSocketConfig `mapstructure:"websocket"`
 	Redis     RedisConfig     `mapstructure:"redis"`
+	Consumer  ConsumerConfig  `mapstructure:"consumer"`
 }
 
 // WebSocketConfig — настройки WebSocket (в т.ч. проверка Origin).
@@ -61,6 +63,13 @@ type LoggerConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
 // Load читает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -91,6 +100,10 @@ func Load() (*Config, error) {
 	viper.SetDefault("websocket.allowed_origins", "http://localhost:3000,http://localhost:8086")
 	viper.SetDefault("websocket.allow_all_origins_in_dev", true)
 
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
+
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
 		if !errors.As(err, &notFound) {
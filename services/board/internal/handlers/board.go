@@ -68,7 +68,18 @@ func (h *BoardHandler) HandleWebSocket(c *gin.Context) {
 	ws.ServeWs(h.hub, conn)
 }
 
-// GetPublicBoard возвращает данные для общего табло.
+// tripVehiclesJoin разворачивает рейс в строки по автобусам: основной (номер 1, перрон рейса)
+// и добавленные из trip_vehicles (свой перрон или перрон рейса).
+const tripVehiclesJoin = `
+		JOIN LATERAL (
+			SELECT NULL::uuid AS vehicle_id, 1 AS vehicle_number, t.platform, t.bus_id
+			UNION ALL
+			SELECT tv.id, tv.number, COALESCE(tv.platform, t.platform), tv.bus_id
+			FROM trip_vehicles tv WHERE tv.trip_id = t.id
+		) v ON true
+		LEFT JOIN buses b ON b.id = v.bus_id`
+
+// GetPublicBoard возвращает данные для общего табло: по строке на каждый автобус рейса.
 func (h *BoardHandler) GetPublicBoard(c *gin.Context) {
 	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
 
@@ -76,13 +87,14 @@ func (h *BoardHandler) GetPublicBoard(c *gin.Context) {
 	var trips []map[string]interface{}
 	query := `
 		SELECT 
-			t.id, t.date, t.status, t.delay_minutes, t.platform,
+			t.id, t.date, t.status, t.delay_minutes, v.platform,
+			v.vehicle_number, b.plate_number,
 			s.departure_time, r.name as route_name
 		FROM trips t
 		JOIN schedules s ON s.id = t.schedule_id
-		JOIN routes r ON r.id = s.route_id
+		JOIN routes r ON r.id = s.route_id` + tripVehiclesJoin + `
 		WHERE t.date = ?
-		ORDER BY s.departure_time ASC
+		ORDER BY s.departure_time ASC, v.vehicle_number ASC
 	`
 
 	rows, err := h.db.Raw(query, date).Rows()
@@ -109,7 +121,8 @@ func (h *BoardHandler) GetPublicBoard(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": trips})
 }
 
-// GetPlatformBoard возвращает данные для перронного табло.
+// GetPlatformBoard возвращает данные для перронного табло: автобусы, посадка в которые идёт
+// с перрона, со статистикой посадки по пассажирам своего автобуса.
 func (h *BoardHandler) GetPlatformBoard(c *gin.Context) {
 	platform := c.Param("platform")
 	date := c.DefaultQuery("date", time.Now().Format("2006-01-02"))
@@ -118,17 +131,19 @@ func (h *BoardHandler) GetPlatformBoard(c *gin.Context) {
 	query := `
 		SELECT 
 			t.id, t.date, t.status, t.delay_minutes,
+			v.vehicle_number, b.plate_number,
 			s.departure_time, r.name as route_name,
 			COUNT(tk.id) as total_tickets,
 			COUNT(bm.id) as boarded_count
 		FROM trips t
 		JOIN schedules s ON s.id = t.schedule_id
-		JOIN routes r ON r.id = s.route_id
+		JOIN routes r ON r.id = s.route_id` + tripVehiclesJoin + `
 		LEFT JOIN tickets tk ON tk.trip_id = t.id AND tk.status = 'active'
+			AND tk.vehicle_id IS NOT DISTINCT FROM v.vehicle_id
 		LEFT JOIN boarding_marks bm ON bm.ticket_id = tk.id
-		WHERE t.date = ? AND t.platform = ?
-		GROUP BY t.id, s.departure_time, r.name
-		ORDER BY s.departure_time ASC
+		WHERE t.date = ? AND v.platform = ?
+		GROUP BY t.id, v.vehicle_id, v.vehicle_number, b.plate_number, s.departure_time, r.name
+		ORDER BY s.departure_time ASC, v.vehicle_number ASC
 	`
 
 	rows, err := h.db.Raw(query, date, platform).Rows()
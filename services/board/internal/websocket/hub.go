@@ -29,12 +29,12 @@ type Client struct {
 
 // Message — структура сообщения для клиентов (поля: 8-байтные, затем 16-байтные, затем time для fieldalignment).
 type Message struct {
-	Timestamp    time.Time              `json:"timestamp"`
-	Data         map[string]interface{} `json:"data,omitempty"`
-	Type         string                 `json:"type"`
-	TripID       string                 `json:"trip_id,omitempty"`
-	Status       string                 `json:"status,omitempty"`
-	DelayMinutes int                    `json:"delay_minutes,omitempty"`
+	Timestamp    time.Time   `json:"timestamp"`
+	Data         interface{} `json:"data,omitempty"`
+	Type         string      `json:"type"`
+	TripID       string      `json:"trip_id,omitempty"`
+	Status       string      `json:"status,omitempty"`
+	DelayMinutes int         `json:"delay_minutes,omitempty"`
 }
 
 // NewHub создаёт новый Hub.
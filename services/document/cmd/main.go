@@ -11,10 +11,17 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/document-service/internal/config"
 	"github.com/vokzal-tech/document-service/internal/handlers"
 	"github.com/vokzal-tech/document-service/internal/models"
@@ -61,24 +68,85 @@ func main() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if migErr := db.AutoMigrate(&models.DocumentTemplate{}, &models.GeneratedDocument{}); migErr != nil {
+	if migErr := db.AutoMigrate(&models.DocumentTemplate{}, &models.GeneratedDocument{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
 
+	natsConn, err := nats.Connect(cfg.NATS.URL,
+		nats.UserInfo(cfg.NATS.User, cfg.NATS.Password),
+		nats.Name("document-service"))
+	if err != nil {
+		logger.Fatal("Failed to connect to NATS", zap.Error(err))
+	}
+	defer natsConn.Close()
+
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "document",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	docRepo := repository.NewDocumentRepository(db)
 	pdfGenerator := pdf.NewGenerator(logger)
 
-	docService, err := service.NewDocumentService(docRepo, pdfGenerator, &cfg.MinIO, logger)
+	docService, err := service.NewDocumentService(docRepo, pdfGenerator, &cfg.MinIO, &cfg.PII, dbtx.NewTransactor(db), outbox.New(db, "document"), logger)
 	if err != nil {
 		logger.Fatal("Failed to create document service", zap.Error(err))
 	}
 
+	// Durable-консьюмер: команды обезличивания ticket-service (pii.anonymize), опубликованные
+	// при остановленном сервисе, будут обработаны после запуска
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "document",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	docService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
+
+	// Удаление документов без привязки к билету по сроку хранения
+	go func() {
+		ticker := time.NewTicker(cfg.PII.RetentionInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				n, retErr := docService.RunRetention(context.Background())
+				if retErr != nil {
+					logger.Error("Failed to remove expired documents", zap.Error(retErr))
+				}
+				if retErr != nil || n < cfg.PII.RetentionBatch {
+					break
+				}
+			}
+		}
+	}()
+
 	docHandler := handlers.NewDocumentHandler(docService, logger)
 
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -94,6 +162,7 @@ func main() {
 	doc.POST("/pd2", docHandler.GeneratePD2)
 	doc.GET("/:id", docHandler.GetDocument)
 	doc.GET("/list", docHandler.ListDocuments)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "document"), logger).Register(doc.Group("/dead-letters"))
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
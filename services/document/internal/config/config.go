@@ -4,17 +4,21 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config — корневая конфигурация сервиса.
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
 	NATS     NATSConfig     `mapstructure:"nats"`
+	Server   ServerConfig   `mapstructure:"server"`
 	Logger   LoggerConfig   `mapstructure:"logger"`
+	Database DatabaseConfig `mapstructure:"database"`
 	MinIO    MinIOConfig    `mapstructure:"minio"`
+	Consumer ConsumerConfig `mapstructure:"consumer"`
+	Outbox   OutboxConfig   `mapstructure:"outbox"`
+	PII      PIIConfig      `mapstructure:"pii"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -54,6 +58,31 @@ type MinIOConfig struct {
 	UseSSL    bool   `mapstructure:"use_ssl"`
 }
 
+// PIIConfig — удаление документов с ПД пассажиров.
+// Билеты удаляются по команде ticket-service (срок считается от даты рейса), документы без привязки
+// к билету (ПД-2) — через RetentionPeriod после создания (проверка раз в RetentionInterval).
+type PIIConfig struct {
+	RetentionPeriod   time.Duration `mapstructure:"retention_period"`
+	RetentionInterval time.Duration `mapstructure:"retention_interval"`
+	RetentionBatch    int           `mapstructure:"retention_batch"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
+// OutboxConfig — доставка событий из outbox в NATS (см. outbox.RelayConfig в go-common).
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
 // Load читает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -82,6 +111,17 @@ func Load() (*Config, error) {
 	viper.SetDefault("minio.secret_key", "minioadmin")
 	viper.SetDefault("minio.bucket", "vokzal-documents")
 	viper.SetDefault("minio.use_ssl", false)
+	viper.SetDefault("pii.retention_period", "8760h")
+	viper.SetDefault("pii.retention_interval", "1h")
+	viper.SetDefault("pii.retention_batch", 500)
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
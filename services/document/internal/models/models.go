@@ -34,15 +34,18 @@ func (d *DocumentTemplate) BeforeCreate(_ *gorm.DB) error {
 }
 
 // GeneratedDocument — сгенерированный документ.
+// Документы с ПД пассажира по истечении срока хранения удаляются из MinIO; запись остаётся
+// со статусом "anonymized" и пустым FileURL.
 type GeneratedDocument struct {
-	CreatedAt    time.Time `json:"created_at"`
-	TemplateID   *string   `gorm:"type:uuid;index" json:"template_id,omitempty"`
-	EntityID     *string   `gorm:"type:uuid;index" json:"entity_id,omitempty"`
-	ID           string    `gorm:"type:uuid;primary_key" json:"id"`
-	DocumentType string    `gorm:"type:varchar(50);not null" json:"document_type"`
-	FileURL      string    `gorm:"type:varchar(500)" json:"file_url"`
-	FileName     string    `gorm:"type:varchar(200)" json:"file_name"`
-	Status       string    `gorm:"type:varchar(20);default:'generated'" json:"status"`
+	CreatedAt    time.Time  `json:"created_at"`
+	TemplateID   *string    `gorm:"type:uuid;index" json:"template_id,omitempty"`
+	EntityID     *string    `gorm:"type:uuid;index" json:"entity_id,omitempty"`
+	AnonymizedAt *time.Time `gorm:"index" json:"anonymized_at,omitempty"`
+	ID           string     `gorm:"type:uuid;primary_key" json:"id"`
+	DocumentType string     `gorm:"type:varchar(50);not null" json:"document_type"`
+	FileURL      string     `gorm:"type:varchar(500)" json:"file_url"`
+	FileName     string     `gorm:"type:varchar(200)" json:"file_name"`
+	Status       string     `gorm:"type:varchar(20);default:'generated'" json:"status"`
 }
 
 // TableName возвращает имя таблицы сгенерированных документов.
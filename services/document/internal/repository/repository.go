@@ -4,9 +4,12 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+
 	"github.com/vokzal-tech/document-service/internal/models"
 )
 
@@ -29,6 +32,9 @@ type DocumentRepository interface {
 	FindDocumentByID(ctx context.Context, id string) (*models.GeneratedDocument, error)
 	FindDocumentsByEntity(ctx context.Context, entityID string) ([]*models.GeneratedDocument, error)
 	ListDocuments(ctx context.Context, limit int) ([]*models.GeneratedDocument, error)
+	FindDocumentsByEntities(ctx context.Context, entityIDs []string) ([]*models.GeneratedDocument, error)
+	FindDocumentsForRetention(ctx context.Context, createdBefore time.Time, limit int) ([]*models.GeneratedDocument, error)
+	MarkAnonymized(ctx context.Context, ids []string) error
 }
 
 type documentRepository struct {
@@ -41,13 +47,13 @@ func NewDocumentRepository(db *gorm.DB) DocumentRepository {
 }
 
 func (r *documentRepository) CreateTemplate(ctx context.Context, template *models.DocumentTemplate) error {
-	return r.db.WithContext(ctx).Create(template).Error
+	return dbtx.From(ctx, r.db).Create(template).Error
 }
 
 // findFirstBy выполняет First по условию и маппит gorm.ErrRecordNotFound в notFoundErr (package-level generic).
 func findFirstBy[T any](db *gorm.DB, ctx context.Context, query string, arg any, notFoundErr error) (*T, error) {
 	var t T
-	if err := db.WithContext(ctx).First(&t, query, arg).Error; err != nil {
+	if err := dbtx.From(ctx, db).First(&t, query, arg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, notFoundErr
 		}
@@ -66,18 +72,18 @@ func (r *documentRepository) FindTemplateByName(ctx context.Context, name string
 
 func (r *documentRepository) ListTemplates(ctx context.Context) ([]*models.DocumentTemplate, error) {
 	var templates []*models.DocumentTemplate
-	if err := r.db.WithContext(ctx).Where("is_active = ?", true).Find(&templates).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("is_active = ?", true).Find(&templates).Error; err != nil {
 		return nil, err
 	}
 	return templates, nil
 }
 
 func (r *documentRepository) UpdateTemplate(ctx context.Context, template *models.DocumentTemplate) error {
-	return r.db.WithContext(ctx).Save(template).Error
+	return dbtx.From(ctx, r.db).Save(template).Error
 }
 
 func (r *documentRepository) CreateDocument(ctx context.Context, doc *models.GeneratedDocument) error {
-	return r.db.WithContext(ctx).Create(doc).Error
+	return dbtx.From(ctx, r.db).Create(doc).Error
 }
 
 func (r *documentRepository) FindDocumentByID(ctx context.Context, id string) (*models.GeneratedDocument, error) {
@@ -86,7 +92,7 @@ func (r *documentRepository) FindDocumentByID(ctx context.Context, id string) (*
 
 func (r *documentRepository) FindDocumentsByEntity(ctx context.Context, entityID string) ([]*models.GeneratedDocument, error) {
 	var docs []*models.GeneratedDocument
-	if err := r.db.WithContext(ctx).Where("entity_id = ?", entityID).Find(&docs).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("entity_id = ?", entityID).Find(&docs).Error; err != nil {
 		return nil, err
 	}
 	return docs, nil
@@ -94,7 +100,7 @@ func (r *documentRepository) FindDocumentsByEntity(ctx context.Context, entityID
 
 func (r *documentRepository) ListDocuments(ctx context.Context, limit int) ([]*models.GeneratedDocument, error) {
 	var docs []*models.GeneratedDocument
-	query := r.db.WithContext(ctx).Order("created_at DESC")
+	query := dbtx.From(ctx, r.db).Order("created_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -103,3 +109,45 @@ func (r *documentRepository) ListDocuments(ctx context.Context, limit int) ([]*m
 	}
 	return docs, nil
 }
+
+// FindDocumentsByEntities возвращает необезличенные документы по связанным записям (билетам).
+func (r *documentRepository) FindDocumentsByEntities(ctx context.Context, entityIDs []string) ([]*models.GeneratedDocument, error) {
+	var docs []*models.GeneratedDocument
+	if len(entityIDs) == 0 {
+		return docs, nil
+	}
+	err := dbtx.From(ctx, r.db).
+		Where("entity_id IN ? AND anonymized_at IS NULL", entityIDs).
+		Find(&docs).Error
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// FindDocumentsForRetention возвращает необезличенные документы без привязки к билету, созданные раньше createdBefore.
+func (r *documentRepository) FindDocumentsForRetention(ctx context.Context, createdBefore time.Time, limit int) ([]*models.GeneratedDocument, error) {
+	var docs []*models.GeneratedDocument
+	err := dbtx.From(ctx, r.db).
+		Where("entity_id IS NULL AND anonymized_at IS NULL AND created_at < ?", createdBefore).
+		Limit(limit).
+		Find(&docs).Error
+	if err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// MarkAnonymized отмечает документы удалёнными из хранилища.
+func (r *documentRepository) MarkAnonymized(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dbtx.From(ctx, r.db).Model(&models.GeneratedDocument{}).
+		Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{
+			"status":        "anonymized",
+			"file_url":      "",
+			"anonymized_at": time.Now(),
+		}).Error
+}
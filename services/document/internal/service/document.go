@@ -11,6 +11,10 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/document-service/internal/config"
 	"github.com/vokzal-tech/document-service/internal/models"
 	"github.com/vokzal-tech/document-service/internal/pdf"
@@ -23,13 +27,20 @@ type DocumentService interface {
 	GeneratePD2(ctx context.Context, data *pdf.PD2Data) (*models.GeneratedDocument, error)
 	GetDocument(ctx context.Context, id string) (*models.GeneratedDocument, error)
 	ListDocuments(ctx context.Context, limit int) ([]*models.GeneratedDocument, error)
+
+	// Обезличивание
+	RunRetention(ctx context.Context) (int, error)
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type documentService struct {
 	repo      repository.DocumentRepository
 	generator *pdf.Generator
 	minio     *minio.Client
+	tx        *dbtx.Transactor
+	events    *outbox.Outbox
 	cfg       *config.MinIOConfig
+	piiCfg    *config.PIIConfig
 	logger    *zap.Logger
 }
 
@@ -38,6 +49,9 @@ func NewDocumentService(
 	repo repository.DocumentRepository,
 	generator *pdf.Generator,
 	cfg *config.MinIOConfig,
+	piiCfg *config.PIIConfig,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
 	logger *zap.Logger,
 ) (DocumentService, error) {
 	minioClient, err := minio.New(cfg.Endpoint, &minio.Options{
@@ -52,7 +66,10 @@ func NewDocumentService(
 		repo:      repo,
 		generator: generator,
 		minio:     minioClient,
+		tx:        tx,
+		events:    events,
 		cfg:       cfg,
+		piiCfg:    piiCfg,
 		logger:    logger,
 	}, nil
 }
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/document-service/internal/models"
+)
+
+// RunRetention удаляет очередную пачку документов без привязки к билету (ПД-2),
+// созданных раньше pii.retention_period. Возвращает число удалённых документов.
+func (s *documentService) RunRetention(ctx context.Context) (int, error) {
+	docs, err := s.repo.FindDocumentsForRetention(ctx, time.Now().Add(-s.piiCfg.RetentionPeriod), s.piiCfg.RetentionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired documents: %w", err)
+	}
+	removed, err := s.anonymize(ctx, docs, "retention", "")
+	if err != nil {
+		return 0, err
+	}
+	if removed > 0 {
+		s.logger.Info("Documents removed by retention", zap.Int("documents", removed))
+	}
+	return removed, nil
+}
+
+// HandleAnonymizeCommand удаляет документы по билетам, ПД которых обезличены в ticket-service
+// (событие pii.anonymize: истёк срок хранения или запрос субъекта на удаление). По запросу
+// на удаление отчёт отправляется и без документов: ticket-service закрывает запрос,
+// когда отчитались все сервисы.
+func (s *documentService) HandleAnonymizeCommand(ctx context.Context, command *events.PIIAnonymize) error {
+	ticketIDs := make([]string, 0, len(command.TicketIDs))
+	for _, v := range command.TicketIDs {
+		if v != "" {
+			ticketIDs = append(ticketIDs, v)
+		}
+	}
+	reason, requestID := command.Reason, command.ErasureRequestID
+	if len(ticketIDs) == 0 && requestID == "" {
+		return nil
+	}
+
+	docs, err := s.repo.FindDocumentsByEntities(ctx, ticketIDs)
+	if err != nil {
+		return fmt.Errorf("failed to find ticket documents: %w", err)
+	}
+	_, err = s.anonymize(ctx, docs, reason, requestID)
+	return err
+}
+
+// RegisterEventHandlers регистрирует в durable-консьюмере обработчик команд обезличивания ПД.
+func (s *documentService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypePIIAnonymize, events.Handler(s.HandleAnonymizeCommand))
+}
+
+// anonymize удаляет файлы документов из MinIO, отмечает записи и отчитывается в журнал
+// обезличивания (событие pii.anonymized). Документ, файл которого не удалось удалить,
+// остаётся необезличенным и будет обработан повторно. По запросу на удаление (requestID)
+// отчёт отправляется только целиком: при сбое удаления команда возвращается с ошибкой
+// и доставляется повторно (удаление уже удалённого файла не ошибка).
+func (s *documentService) anonymize(ctx context.Context, docs []*models.GeneratedDocument, reason, requestID string) (int, error) {
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		err := s.minio.RemoveObject(ctx, s.cfg.Bucket, doc.FileName, minio.RemoveObjectOptions{})
+		if err != nil {
+			s.logger.Error("Failed to remove document file", zap.String("document_id", doc.ID), zap.Error(err))
+			continue
+		}
+		ids = append(ids, doc.ID)
+	}
+	if requestID != "" && len(ids) < len(docs) {
+		return 0, fmt.Errorf("failed to remove %d of %d document files", len(docs)-len(ids), len(docs))
+	}
+	if len(ids) == 0 && requestID == "" {
+		return 0, nil
+	}
+	report := events.PIIAnonymized{
+		Service:    "document",
+		EntityType: "document",
+		Fields:     "file",
+		Reason:     reason,
+		EntityIDs:  ids,
+	}
+	aggregateType, aggregateID := "pii_retention", reason
+	if requestID != "" {
+		report.ErasureRequestID = requestID
+		aggregateType, aggregateID = "erasure_request", requestID
+	}
+	// Файлы уже удалены, поэтому отметка документов и отчёт для журнала сохраняются вместе
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.repo.MarkAnonymized(ctx, ids); dbErr != nil {
+			return fmt.Errorf("failed to mark documents anonymized: %w", dbErr)
+		}
+		return s.events.Publish(ctx, aggregateType, aggregateID, report)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
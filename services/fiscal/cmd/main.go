@@ -12,10 +12,16 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/fiscal-service/internal/atol"
 	"github.com/vokzal-tech/fiscal-service/internal/config"
 	"github.com/vokzal-tech/fiscal-service/internal/handlers"
@@ -64,7 +70,7 @@ func main() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if migErr := db.AutoMigrate(&models.FiscalReceipt{}, &models.ZReport{}); migErr != nil {
+	if migErr := db.AutoMigrate(&models.FiscalReceipt{}, &models.ZReport{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
 
@@ -79,6 +85,27 @@ func main() {
 
 	logger.Info("Connected to NATS", zap.String("url", cfg.NATS.URL))
 
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "fiscal",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	// Создать ATOL клиент
 	atolClient := atol.NewATOLClient(cfg.LocalAgent.URL, logger)
 
@@ -86,10 +113,20 @@ func main() {
 	fiscalRepo := repository.NewFiscalRepository(db)
 
 	// Создать сервис
-	fiscalService := service.NewFiscalService(fiscalRepo, atolClient, cfg, logger)
-
-	// Подписаться на NATS события
-	fiscalService.SubscribeToEvents(natsConn)
+	fiscalService := service.NewFiscalService(fiscalRepo, atolClient, dbtx.NewTransactor(db), outbox.New(db, "fiscal"), cfg, logger)
+
+	// Durable-консьюмер: события, опубликованные при остановленном сервисе, будут фискализированы после запуска
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "fiscal",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	fiscalService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
 
 	// Создать handlers
 	fiscalHandler := handlers.NewFiscalHandler(fiscalService, logger)
@@ -99,6 +136,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -118,6 +156,8 @@ func main() {
 	reports.GET("", fiscalHandler.ListZReports)
 	reports.GET("/date", fiscalHandler.GetZReport)
 	v1.GET("/kkt/status", fiscalHandler.GetKKTStatus)
+	// Необработанные события (роль admin)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "fiscal"), logger).Register(v1.Group("/fiscal/dead-letters"))
 
 	// Создать HTTP сервер
 	srv := &http.Server{
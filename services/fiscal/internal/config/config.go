@@ -4,18 +4,21 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config — корневая конфигурация сервиса.
 type Config struct {
+	Database   DatabaseConfig   `mapstructure:"database"`
 	NATS       NATSConfig       `mapstructure:"nats"`
 	ATOL       ATOLConfig       `mapstructure:"atol"`
 	Server     ServerConfig     `mapstructure:"server"`
 	Logger     LoggerConfig     `mapstructure:"logger"`
 	LocalAgent LocalAgentConfig `mapstructure:"local_agent"`
-	Database   DatabaseConfig   `mapstructure:"database"`
+	Consumer   ConsumerConfig   `mapstructure:"consumer"`
+	Outbox     OutboxConfig     `mapstructure:"outbox"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -58,6 +61,22 @@ type LocalAgentConfig struct {
 	URL string `mapstructure:"url"`
 }
 
+// OutboxConfig — доставка событий из outbox в NATS (см. outbox.RelayConfig в go-common).
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
 // Load читает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -85,6 +104,15 @@ func Load() (*Config, error) {
 	viper.SetDefault("atol.company_name", "ООО «Вокзал.ТЕХ»")
 	viper.SetDefault("atol.tax_system", "osn")
 	viper.SetDefault("local_agent.url", "http://localhost:8081")
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
+
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
@@ -8,19 +8,24 @@ import (
 	"gorm.io/gorm"
 )
 
-// FiscalReceipt — модель фискального чека.
+// FiscalReceipt — модель фискального чека. Discount — скидка по ваучеру, уже учтённая в Amount.
+// Чек продажи водителем в пути пробит на мобильной ККТ: PrintedAt — время печати на устройстве,
+// FiscalDocNumber — номер фискального документа.
 type FiscalReceipt struct {
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-	ErrorMsg   *string   `gorm:"type:text" json:"error_msg,omitempty"`
-	ID         string    `gorm:"type:uuid;primary_key" json:"id"`
-	TicketID   string    `gorm:"type:uuid;not null;index" json:"ticket_id"`
-	Type       string    `gorm:"type:varchar(20);not null" json:"type"`
-	OFDURL     string    `gorm:"type:varchar(500)" json:"ofd_url"`
-	KKTSerial  string    `gorm:"type:varchar(50)" json:"kkt_serial"`
-	FiscalSign string    `gorm:"type:varchar(100)" json:"fiscal_sign"`
-	Status     string    `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
-	Amount     float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	ErrorMsg        *string    `gorm:"type:text" json:"error_msg,omitempty"`
+	Discount        *float64   `gorm:"type:decimal(10,2)" json:"discount,omitempty"`
+	PrintedAt       *time.Time `json:"printed_at,omitempty"`
+	FiscalDocNumber *int       `json:"fiscal_doc_number,omitempty"`
+	ID              string     `gorm:"type:uuid;primary_key" json:"id"`
+	TicketID        string     `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	Type            string     `gorm:"type:varchar(20);not null" json:"type"`
+	OFDURL          string     `gorm:"type:varchar(500)" json:"ofd_url"`
+	KKTSerial       string     `gorm:"type:varchar(50)" json:"kkt_serial"`
+	FiscalSign      string     `gorm:"type:varchar(100)" json:"fiscal_sign"`
+	Status          string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Amount          float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
 }
 
 // ZReport — модель Z-отчёта.
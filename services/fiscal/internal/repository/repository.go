@@ -7,6 +7,8 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+
 	"github.com/vokzal-tech/fiscal-service/internal/models"
 )
 
@@ -23,6 +25,7 @@ type FiscalRepository interface {
 	CreateReceipt(ctx context.Context, receipt *models.FiscalReceipt) error
 	FindReceiptByID(ctx context.Context, id string) (*models.FiscalReceipt, error)
 	FindReceiptByTicketID(ctx context.Context, ticketID string) ([]*models.FiscalReceipt, error)
+	FindReceiptByTicketAndType(ctx context.Context, ticketID, receiptType string) (*models.FiscalReceipt, error)
 	UpdateReceipt(ctx context.Context, receipt *models.FiscalReceipt) error
 
 	// Z-Reports
@@ -43,12 +46,12 @@ func NewFiscalRepository(db *gorm.DB) FiscalRepository {
 
 // CreateReceipt создаёт запись чека.
 func (r *fiscalRepository) CreateReceipt(ctx context.Context, receipt *models.FiscalReceipt) error {
-	return r.db.WithContext(ctx).Create(receipt).Error
+	return dbtx.From(ctx, r.db).Create(receipt).Error
 }
 
 func findFirstBy[T any](db *gorm.DB, ctx context.Context, query string, arg any, notFoundErr error) (*T, error) {
 	var t T
-	if err := db.WithContext(ctx).First(&t, query, arg).Error; err != nil {
+	if err := dbtx.From(ctx, db).First(&t, query, arg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, notFoundErr
 		}
@@ -63,19 +66,35 @@ func (r *fiscalRepository) FindReceiptByID(ctx context.Context, id string) (*mod
 
 func (r *fiscalRepository) FindReceiptByTicketID(ctx context.Context, ticketID string) ([]*models.FiscalReceipt, error) {
 	var receipts []*models.FiscalReceipt
-	if err := r.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Find(&receipts).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("ticket_id = ?", ticketID).Find(&receipts).Error; err != nil {
 		return nil, err
 	}
 	return receipts, nil
 }
 
+// FindReceiptByTicketAndType возвращает последний чек билета (или багажа) заданного типа.
+func (r *fiscalRepository) FindReceiptByTicketAndType(ctx context.Context, ticketID, receiptType string) (*models.FiscalReceipt, error) {
+	var receipt models.FiscalReceipt
+	err := dbtx.From(ctx, r.db).
+		Where("ticket_id = ? AND type = ?", ticketID, receiptType).
+		Order("created_at DESC").
+		First(&receipt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReceiptNotFound
+		}
+		return nil, err
+	}
+	return &receipt, nil
+}
+
 func (r *fiscalRepository) UpdateReceipt(ctx context.Context, receipt *models.FiscalReceipt) error {
-	return r.db.WithContext(ctx).Save(receipt).Error
+	return dbtx.From(ctx, r.db).Save(receipt).Error
 }
 
 // CreateZReport создаёт запись Z-отчёта.
 func (r *fiscalRepository) CreateZReport(ctx context.Context, report *models.ZReport) error {
-	return r.db.WithContext(ctx).Create(report).Error
+	return dbtx.From(ctx, r.db).Create(report).Error
 }
 
 func (r *fiscalRepository) FindZReportByDate(ctx context.Context, date string) (*models.ZReport, error) {
@@ -84,7 +103,7 @@ func (r *fiscalRepository) FindZReportByDate(ctx context.Context, date string) (
 
 func (r *fiscalRepository) FindAllZReports(ctx context.Context, limit int) ([]*models.ZReport, error) {
 	var reports []*models.ZReport
-	query := r.db.WithContext(ctx).Order("date DESC")
+	query := dbtx.From(ctx, r.db).Order("date DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -95,5 +114,5 @@ func (r *fiscalRepository) FindAllZReports(ctx context.Context, limit int) ([]*m
 }
 
 func (r *fiscalRepository) UpdateZReport(ctx context.Context, report *models.ZReport) error {
-	return r.db.WithContext(ctx).Save(report).Error
+	return dbtx.From(ctx, r.db).Save(report).Error
 }
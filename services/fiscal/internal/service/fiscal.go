@@ -3,25 +3,44 @@ package service
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 
-	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/fiscal-service/internal/atol"
 	"github.com/vokzal-tech/fiscal-service/internal/config"
 	"github.com/vokzal-tech/fiscal-service/internal/models"
 	"github.com/vokzal-tech/fiscal-service/internal/repository"
 )
 
-const receiptStatusFailed = "failed"
+const (
+	receiptStatusConfirmed = "confirmed"
+	receiptStatusFailed    = "failed"
+
+	receiptTypeBaggageSale    = "baggage_sale"
+	receiptTypeBaggageRefund  = "baggage_refund"
+	receiptTypeExchangeSale   = "exchange_sale"
+	receiptTypeExchangeRefund = "exchange_refund"
+
+	// fareComponentFare — составляющая цены билета «тариф перевозчика» (events.FareComponent.Kind).
+	fareComponentFare = "fare"
+)
 
 // FiscalService — интерфейс сервиса фискализации.
 type FiscalService interface {
 	// Receipts
-	ProcessTicketSold(ctx context.Context, ticketData map[string]interface{}) error
-	ProcessTicketRefund(ctx context.Context, ticketData map[string]interface{}) error
+	ProcessTicketSold(ctx context.Context, ticket *events.TicketSold) error
+	ProcessTicketRefund(ctx context.Context, ticket *events.TicketReturned) error
+	ProcessBaggageSold(ctx context.Context, baggage *events.BaggageSold) error
+	ProcessBaggageRefund(ctx context.Context, baggage *events.BaggageReturned) error
+	ProcessTicketExchange(ctx context.Context, exchange *events.TicketExchanged) error
 	GetReceipt(ctx context.Context, id string) (*models.FiscalReceipt, error)
 	GetReceiptsByTicket(ctx context.Context, ticketID string) ([]*models.FiscalReceipt, error)
 
@@ -33,13 +52,15 @@ type FiscalService interface {
 	// KKT Status
 	GetKKTStatus(ctx context.Context) (map[string]interface{}, error)
 
-	// NATS Events
-	SubscribeToEvents(nc *nats.Conn)
+	// Events
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type fiscalService struct {
 	repo       repository.FiscalRepository
 	atolClient *atol.ATOLClient
+	tx         *dbtx.Transactor
+	events     *outbox.Outbox
 	cfg        *config.Config
 	logger     *zap.Logger
 }
@@ -48,136 +69,327 @@ type fiscalService struct {
 func NewFiscalService(
 	repo repository.FiscalRepository,
 	atolClient *atol.ATOLClient,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) FiscalService {
 	return &fiscalService{
 		repo:       repo,
 		atolClient: atolClient,
+		tx:         tx,
+		events:     events,
 		cfg:        cfg,
 		logger:     logger,
 	}
 }
 
-// ProcessTicketSold обрабатывает продажу билета (фискализация чека).
-//
-//nolint:dupl // структура совпадает с ProcessTicketRefund (продажа/возврат)
-func (s *fiscalService) ProcessTicketSold(ctx context.Context, ticketData map[string]interface{}) error {
-	var ticketID string
-	if id, ok := ticketData["id"].(string); ok {
-		ticketID = id
+// ProcessTicketSold обрабатывает продажу билета (фискализация чека). Составляющие цены (тариф перевозчика,
+// сбор вокзала, страховой сбор) печатаются отдельными позициями со своими ставками НДС; билет без
+// составляющих — одной позицией. Скидка по ваучеру в чек отдельной позицией не выводится: цена тарифа —
+// цена со скидкой, размер скидки указан в названии позиции и сохраняется в чеке. Билет, полностью
+// оплаченный ваучером, денежных расчётов не содержит и не фискализируется. Чек продажи водителем
+// уже пробит на мобильной ККТ и только регистрируется.
+func (s *fiscalService) ProcessTicketSold(ctx context.Context, ticket *events.TicketSold) error {
+	if ticket.DeviceReceipt != nil {
+		return s.registerDeviceReceipt(ctx, ticket)
 	}
-	var price float64
-	if p, ok := ticketData["price"].(float64); ok {
-		price = p
+	if ticket.Price <= 0 {
+		s.logger.Info("Ticket paid by voucher in full, no receipt required",
+			zap.String("ticket_id", ticket.ID),
+			zap.Stringp("voucher_id", ticket.VoucherID))
+		return nil
 	}
-
-	// Создать запись чека
 	receipt := &models.FiscalReceipt{
-		TicketID: ticketID,
+		TicketID: ticket.ID,
 		Type:     "sale",
-		Amount:   price,
+		Amount:   ticket.Price,
+		Discount: ticket.DiscountAmount,
 		Status:   "pending",
 	}
+	components := ticket.Components
+	if len(components) == 0 {
+		components = []events.FareComponent{{Kind: fareComponentFare, Name: "Билет на автобус", VAT: "none", Amount: ticket.Price}}
+	}
+	items := make([]atol.ReceiptItem, 0, len(components))
+	for _, component := range components {
+		if component.Amount <= 0 {
+			continue
+		}
+		name := component.Name
+		if component.Kind == fareComponentFare && ticket.DiscountAmount != nil && *ticket.DiscountAmount > 0 {
+			name = fmt.Sprintf("%s (скидка %.2f руб.)", name, *ticket.DiscountAmount)
+		}
+		items = append(items, atol.ReceiptItem{Name: name, Quantity: 1, Price: component.Amount, VAT: component.VAT})
+	}
+	return s.fiscalize(ctx, receipt, "sell", items)
+}
 
-	if err := s.repo.CreateReceipt(ctx, receipt); err != nil {
-		return fmt.Errorf("failed to create receipt: %w", err)
+// ProcessTicketRefund обрабатывает возврат билета (фискализация чека возврата). Для билета с составляющими
+// цены каждая возвращаемая составляющая — отдельная позиция с НДС, как в чеке продажи; невозвратные
+// сборы в чек возврата не попадают.
+func (s *fiscalService) ProcessTicketRefund(ctx context.Context, ticket *events.TicketReturned) error {
+	if len(ticket.Components) == 0 {
+		return s.processRefund(ctx, ticket.ID, ticket.RefundAmount, "refund", "Возврат билета на автобус")
 	}
 
-	// Отправить на ККТ
-	req := &atol.ReceiptRequest{
-		Operation: "sell",
-		Items: []atol.ReceiptItem{
-			{
-				Name:     "Билет на автобус",
-				Quantity: 1,
-				Price:    price,
-				VAT:      "none",
-			},
-		},
-		Payment: atol.Payment{
-			Type:   "card",
-			Amount: price,
-		},
-		Company: atol.Company{
-			INN:       s.cfg.ATOL.CompanyINN,
-			Name:      s.cfg.ATOL.CompanyName,
-			TaxSystem: s.cfg.ATOL.TaxSystem,
+	var amount float64
+	var items []atol.ReceiptItem
+	for _, component := range ticket.Components {
+		if component.RefundAmount == nil || *component.RefundAmount <= 0 {
+			continue
+		}
+		items = append(items, atol.ReceiptItem{
+			Name:     "Возврат: " + component.Name,
+			Quantity: 1,
+			Price:    *component.RefundAmount,
+			VAT:      component.VAT,
+		})
+		amount += *component.RefundAmount
+	}
+	if len(items) == 0 {
+		s.logger.Info("Nothing refunded for ticket, no receipt required", zap.String("ticket_id", ticket.ID))
+		return nil
+	}
+	receipt := &models.FiscalReceipt{
+		TicketID: ticket.ID,
+		Type:     "refund",
+		Amount:   roundMoney(amount),
+		Status:   "pending",
+	}
+	return s.fiscalize(ctx, receipt, "refund", items)
+}
+
+// ProcessBaggageSold фискализирует провоз багажа отдельным чеком (позиция — места багажа по тарифу).
+func (s *fiscalService) ProcessBaggageSold(ctx context.Context, baggage *events.BaggageSold) error {
+	receipt := &models.FiscalReceipt{
+		TicketID: baggage.ID,
+		Type:     receiptTypeBaggageSale,
+		Amount:   baggage.Price,
+		Status:   "pending",
+	}
+	items := []atol.ReceiptItem{
+		{
+			Name:     fmt.Sprintf("Провоз багажа (%s)", baggage.WeightClass),
+			Quantity: float64(baggage.Pieces),
+			Price:    baggage.Tariff,
+			VAT:      "none",
 		},
 	}
+	return s.fiscalize(ctx, receipt, "sell", items)
+}
 
-	result, err := s.atolClient.PrintReceipt(req)
-	if err != nil {
-		receipt.Status = receiptStatusFailed
-		errMsg := err.Error()
-		receipt.ErrorMsg = &errMsg
-		if updErr := s.repo.UpdateReceipt(ctx, receipt); updErr != nil {
-			s.logger.Warn("Failed to update receipt after print error", zap.Error(updErr))
+// ProcessBaggageRefund фискализирует возврат провоза багажа отдельным чеком.
+func (s *fiscalService) ProcessBaggageRefund(ctx context.Context, baggage *events.BaggageReturned) error {
+	return s.processRefund(ctx, baggage.ID, baggage.RefundAmount, receiptTypeBaggageRefund, "Возврат провоза багажа")
+}
+
+// ProcessTicketExchange фискализирует обмен билета: доплата разницы и сбор за обмен — чеком прихода,
+// возврат разницы — отдельным чеком возврата прихода. Разница считается по каждой составляющей цены
+// со своей ставкой НДС: подорожавшие составляющие — в чек прихода, подешевевшие — в чек возврата.
+func (s *fiscalService) ProcessTicketExchange(ctx context.Context, exchange *events.TicketExchanged) error {
+	ticketID, fee := exchange.ID, exchange.ExchangeFee
+
+	saleItems, refundItems := exchangeDifferenceItems(exchange)
+	var saleAmount, refundAmount float64
+	for _, item := range saleItems {
+		saleAmount += item.Price
+	}
+	for _, item := range refundItems {
+		refundAmount += item.Price
+	}
+	if fee > 0 {
+		saleItems = append(saleItems, atol.ReceiptItem{Name: "Сбор за обмен билета", Quantity: 1, Price: fee, VAT: "none"})
+		saleAmount += fee
+	}
+	if len(saleItems) > 0 {
+		receipt := &models.FiscalReceipt{
+			TicketID: ticketID,
+			Type:     receiptTypeExchangeSale,
+			Amount:   roundMoney(saleAmount),
+			Status:   "pending",
+		}
+		if err := s.fiscalize(ctx, receipt, "sell", saleItems); err != nil {
+			return err
 		}
-		return fmt.Errorf("failed to print receipt: %w", err)
 	}
 
-	if result.Success {
-		receipt.Status = "confirmed"
-		receipt.OFDURL = result.OFDURL
-		receipt.FiscalSign = result.FiscalSign
-		receipt.KKTSerial = result.KKTSerial
-	} else {
-		receipt.Status = receiptStatusFailed
-		receipt.ErrorMsg = &result.ErrorMsg
+	if len(refundItems) > 0 {
+		receipt := &models.FiscalReceipt{
+			TicketID: ticketID,
+			Type:     receiptTypeExchangeRefund,
+			Amount:   roundMoney(refundAmount),
+			Status:   "pending",
+		}
+		return s.fiscalize(ctx, receipt, "refund", refundItems)
 	}
+	return nil
+}
 
-	if updateErr := s.repo.UpdateReceipt(ctx, receipt); updateErr != nil {
-		s.logger.Error("Failed to update receipt", zap.Error(updateErr))
+// exchangeDifferenceItems раскладывает разницу цен при обмене по составляющим: составляющие сравниваются
+// по виду и ставке НДС (при смене ставки прежняя возвращается целиком, новая продаётся целиком). Билеты
+// без составляющих — одна позиция без НДС, как до их появления.
+func exchangeDifferenceItems(exchange *events.TicketExchanged) (sale, refund []atol.ReceiptItem) {
+	if len(exchange.OldComponents) == 0 && len(exchange.NewComponents) == 0 {
+		switch difference := exchange.FareDifference; {
+		case difference > 0:
+			sale = append(sale, atol.ReceiptItem{Name: "Доплата при обмене билета", Quantity: 1, Price: difference, VAT: "none"})
+		case difference < 0:
+			refund = append(refund, atol.ReceiptItem{Name: "Возврат разницы при обмене билета", Quantity: 1, Price: -difference, VAT: "none"})
+		}
+		return sale, refund
 	}
 
-	s.logger.Info("Receipt processed",
+	// Суммы по виду и ставке НДС; наименование позиции — из нового билета для доплаты, из исходного для возврата
+	type line struct {
+		component            events.FareComponent
+		oldAmount, newAmount float64
+	}
+	var lines []*line
+	byKey := map[string]*line{}
+	add := func(c events.FareComponent, isNew bool) {
+		key := c.Kind + "|" + c.VAT
+		l := byKey[key]
+		if l == nil {
+			l = &line{component: c}
+			byKey[key] = l
+			lines = append(lines, l)
+		}
+		if isNew {
+			l.component.Name = c.Name
+			l.newAmount += c.Amount
+		} else {
+			l.oldAmount += c.Amount
+		}
+	}
+	for _, c := range exchangeComponents(exchange.OldComponents, exchange.OldPrice) {
+		add(c, false)
+	}
+	for _, c := range exchangeComponents(exchange.NewComponents, exchange.NewPrice) {
+		add(c, true)
+	}
+
+	for _, l := range lines {
+		switch diff := roundMoney(l.newAmount - l.oldAmount); {
+		case diff > 0:
+			sale = append(sale, atol.ReceiptItem{Name: "Доплата при обмене: " + l.component.Name, Quantity: 1, Price: diff, VAT: l.component.VAT})
+		case diff < 0:
+			refund = append(refund, atol.ReceiptItem{Name: "Возврат при обмене: " + l.component.Name, Quantity: 1, Price: -diff, VAT: l.component.VAT})
+		}
+	}
+	return sale, refund
+}
+
+// roundMoney округляет сумму до копеек.
+func roundMoney(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// exchangeComponents возвращает составляющие цены билета при обмене; у билета без составляющих вся цена —
+// тариф перевозчика без НДС.
+func exchangeComponents(components []events.FareComponent, price float64) []events.FareComponent {
+	if len(components) > 0 {
+		return components
+	}
+	return []events.FareComponent{{Kind: fareComponentFare, Name: "Билет на автобус", VAT: "none", Amount: price}}
+}
+
+// registerDeviceReceipt сохраняет чек продажи, пробитый на мобильной ККТ водителя, с его фискальными
+// реквизитами. Повторное событие не создаёт второй чек.
+func (s *fiscalService) registerDeviceReceipt(ctx context.Context, ticket *events.TicketSold) error {
+	existing, err := s.repo.FindReceiptByTicketAndType(ctx, ticket.ID, "sale")
+	switch {
+	case err == nil:
+		s.logger.Info("Device receipt already registered, duplicate event skipped",
+			zap.String("receipt_id", existing.ID),
+			zap.String("ticket_id", existing.TicketID))
+		return nil
+	case !errors.Is(err, repository.ErrReceiptNotFound):
+		return fmt.Errorf("failed to find sale receipt: %w", err)
+	}
+
+	device := ticket.DeviceReceipt
+	printedAt, docNumber := device.PrintedAt, device.FiscalDocNumber
+	receipt := &models.FiscalReceipt{
+		TicketID:        ticket.ID,
+		Type:            "sale",
+		Amount:          ticket.Price,
+		Discount:        ticket.DiscountAmount,
+		KKTSerial:       device.KKTSerial,
+		FiscalSign:      device.FiscalSign,
+		PrintedAt:       &printedAt,
+		FiscalDocNumber: &docNumber,
+		Status:          receiptStatusConfirmed,
+	}
+	if err = s.repo.CreateReceipt(ctx, receipt); err != nil {
+		return fmt.Errorf("failed to register device receipt: %w", err)
+	}
+
+	s.logger.Info("Device receipt registered",
 		zap.String("receipt_id", receipt.ID),
-		zap.String("ticket_id", ticketID),
-		zap.String("status", receipt.Status))
+		zap.String("ticket_id", receipt.TicketID),
+		zap.String("kkt_serial", receipt.KKTSerial))
 
 	return nil
 }
 
-// ProcessTicketRefund обрабатывает возврат билета (фискализация чека возврата).
-//
-//nolint:dupl // структура совпадает с ProcessTicketSold (продажа/возврат), рефакторинг усложнит чтение
-func (s *fiscalService) ProcessTicketRefund(ctx context.Context, ticketData map[string]interface{}) error {
-	var ticketID string
-	if id, ok := ticketData["id"].(string); ok {
-		ticketID = id
-	}
+// processRefund фискализирует чек возврата одной позицией на сумму refund_amount из события.
+func (s *fiscalService) processRefund(ctx context.Context, id string, amount *float64, receiptType, itemName string) error {
 	var refundAmount float64
-	if a, ok := ticketData["refund_amount"].(float64); ok {
-		refundAmount = a
+	if amount != nil {
+		refundAmount = *amount
 	}
 
-	// Создать запись чека возврата
 	receipt := &models.FiscalReceipt{
-		TicketID: ticketID,
-		Type:     "refund",
+		TicketID: id,
+		Type:     receiptType,
 		Amount:   refundAmount,
 		Status:   "pending",
 	}
+	items := []atol.ReceiptItem{
+		{
+			Name:     itemName,
+			Quantity: 1,
+			Price:    refundAmount,
+			VAT:      "none",
+		},
+	}
+	return s.fiscalize(ctx, receipt, "refund", items)
+}
 
-	if err := s.repo.CreateReceipt(ctx, receipt); err != nil {
-		return fmt.Errorf("failed to create refund receipt: %w", err)
+// fiscalize сохраняет чек, печатает его на ККТ и фиксирует результат (фискальный признак или ошибку).
+func (s *fiscalService) fiscalize(ctx context.Context, receipt *models.FiscalReceipt, operation string, items []atol.ReceiptItem) error {
+	// Событие может прийти повторно (повтор после ошибки, переотправка из dead letters):
+	// пробитый чек второй раз не печатается, чек с ошибкой печати пробивается заново
+	existing, err := s.repo.FindReceiptByTicketAndType(ctx, receipt.TicketID, receipt.Type)
+	switch {
+	case err == nil && existing.Status == receiptStatusConfirmed:
+		s.logger.Info("Receipt already fiscalized, duplicate event skipped",
+			zap.String("receipt_id", existing.ID),
+			zap.String("ticket_id", existing.TicketID),
+			zap.String("type", existing.Type))
+		return nil
+	case err == nil:
+		receipt.ID = existing.ID
+		receipt.CreatedAt = existing.CreatedAt
+		if err = s.repo.UpdateReceipt(ctx, receipt); err != nil {
+			return fmt.Errorf("failed to reset %s receipt: %w", receipt.Type, err)
+		}
+	case errors.Is(err, repository.ErrReceiptNotFound):
+		if err = s.repo.CreateReceipt(ctx, receipt); err != nil {
+			return fmt.Errorf("failed to create %s receipt: %w", receipt.Type, err)
+		}
+	default:
+		return fmt.Errorf("failed to find %s receipt: %w", receipt.Type, err)
 	}
 
 	// Отправить на ККТ
 	req := &atol.ReceiptRequest{
-		Operation: "refund",
-		Items: []atol.ReceiptItem{
-			{
-				Name:     "Возврат билета на автобус",
-				Quantity: 1,
-				Price:    refundAmount,
-				VAT:      "none",
-			},
-		},
+		Operation: operation,
+		Items:     items,
 		Payment: atol.Payment{
 			Type:   "card",
-			Amount: refundAmount,
+			Amount: receipt.Amount,
 		},
 		Company: atol.Company{
 			INN:       s.cfg.ATOL.CompanyINN,
@@ -192,13 +404,13 @@ func (s *fiscalService) ProcessTicketRefund(ctx context.Context, ticketData map[
 		errMsg := err.Error()
 		receipt.ErrorMsg = &errMsg
 		if updErr := s.repo.UpdateReceipt(ctx, receipt); updErr != nil {
-			s.logger.Warn("Failed to update refund receipt after print error", zap.Error(updErr))
+			s.logger.Warn("Failed to update receipt after print error", zap.Error(updErr), zap.String("type", receipt.Type))
 		}
-		return fmt.Errorf("failed to print refund receipt: %w", err)
+		return fmt.Errorf("failed to print %s receipt: %w", receipt.Type, err)
 	}
 
 	if result.Success {
-		receipt.Status = "confirmed"
+		receipt.Status = receiptStatusConfirmed
 		receipt.OFDURL = result.OFDURL
 		receipt.FiscalSign = result.FiscalSign
 		receipt.KKTSerial = result.KKTSerial
@@ -208,12 +420,13 @@ func (s *fiscalService) ProcessTicketRefund(ctx context.Context, ticketData map[
 	}
 
 	if updateErr := s.repo.UpdateReceipt(ctx, receipt); updateErr != nil {
-		s.logger.Error("Failed to update refund receipt", zap.Error(updateErr))
+		s.logger.Error("Failed to update receipt", zap.Error(updateErr), zap.String("type", receipt.Type))
 	}
 
-	s.logger.Info("Refund receipt processed",
+	s.logger.Info("Receipt processed",
 		zap.String("receipt_id", receipt.ID),
-		zap.String("ticket_id", ticketID),
+		zap.String("ticket_id", receipt.TicketID),
+		zap.String("type", receipt.Type),
 		zap.String("status", receipt.Status))
 
 	return nil
@@ -260,8 +473,15 @@ func (s *fiscalService) CreateDailyZReport(ctx context.Context, date string) (*m
 		Status:       "completed",
 	}
 
-	if err := s.repo.CreateZReport(ctx, report); err != nil {
-		return nil, fmt.Errorf("failed to save Z-report: %w", err)
+	// Кассовые смены ticket-service, закрытые на этой ККТ, связываются с отчётом по событию
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.repo.CreateZReport(ctx, report); dbErr != nil {
+			return fmt.Errorf("failed to save Z-report: %w", dbErr)
+		}
+		return s.publishZReportEvent(ctx, report)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	s.logger.Info("Z-report created",
@@ -284,27 +504,29 @@ func (s *fiscalService) GetKKTStatus(_ context.Context) (map[string]interface{},
 	return s.atolClient.GetKKTStatus()
 }
 
-// subscribeNATSOne подписывается на один топик и обрабатывает JSON → process.
-func (s *fiscalService) subscribeNATSOne(nc *nats.Conn, subject string, process func(context.Context, map[string]interface{}) error, logLabel string) {
-	_, err := nc.Subscribe(subject, func(msg *nats.Msg) {
-		var ticketData map[string]interface{}
-		if unmarshalErr := json.Unmarshal(msg.Data, &ticketData); unmarshalErr != nil {
-			s.logger.Error("Failed to unmarshal "+logLabel, zap.Error(unmarshalErr))
-			return
-		}
-		ctx := context.Background()
-		if err := process(ctx, ticketData); err != nil {
-			s.logger.Error("Failed to process "+logLabel, zap.Error(err))
-		}
+// publishZReportEvent записывает в outbox событие fiscal.z_report о закрытии смены ККТ.
+// Отчёты одной ККТ доставляются в порядке закрытия смен.
+func (s *fiscalService) publishZReportEvent(ctx context.Context, report *models.ZReport) error {
+	return s.events.Publish(ctx, "kkt", report.KKTSerial, events.ZReport{
+		CreatedAt:    report.CreatedAt,
+		ID:           report.ID,
+		Date:         report.Date,
+		KKTSerial:    report.KKTSerial,
+		Status:       report.Status,
+		FiscalSign:   report.FiscalSign,
+		TotalSales:   report.TotalSales,
+		TotalRefunds: report.TotalRefunds,
+		ShiftNumber:  report.ShiftNumber,
+		SalesCount:   report.SalesCount,
+		RefundsCount: report.RefundsCount,
 	})
-	if err != nil {
-		s.logger.Error("Failed to subscribe to "+subject, zap.Error(err))
-	}
 }
 
-// SubscribeToEvents подписывается на NATS-события для фискализации.
-func (s *fiscalService) SubscribeToEvents(nc *nats.Conn) {
-	s.subscribeNATSOne(nc, "ticket.sold", s.ProcessTicketSold, "ticket.sold event")
-	s.subscribeNATSOne(nc, "ticket.returned", s.ProcessTicketRefund, "ticket.returned event")
-	s.logger.Info("Subscribed to NATS events: ticket.sold, ticket.returned")
+// RegisterEventHandlers регистрирует обработчики событий для фискализации в durable-консьюмере.
+func (s *fiscalService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypeTicketSold, events.Handler(s.ProcessTicketSold))
+	consumer.Handle(events.TypeTicketReturned, events.Handler(s.ProcessTicketRefund))
+	consumer.Handle(events.TypeBaggageSold, events.Handler(s.ProcessBaggageSold))
+	consumer.Handle(events.TypeBaggageReturned, events.Handler(s.ProcessBaggageRefund))
+	consumer.Handle(events.TypeTicketExchanged, events.Handler(s.ProcessTicketExchange))
 }
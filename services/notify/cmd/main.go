@@ -12,10 +12,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+	"github.com/vokzal-tech/go-common/pii"
+
 	"github.com/vokzal-tech/notify-service/internal/config"
 	"github.com/vokzal-tech/notify-service/internal/email"
 	"github.com/vokzal-tech/notify-service/internal/handlers"
@@ -24,6 +31,7 @@ import (
 	"github.com/vokzal-tech/notify-service/internal/service"
 	"github.com/vokzal-tech/notify-service/internal/sms"
 	"github.com/vokzal-tech/notify-service/internal/telegram"
+	"github.com/vokzal-tech/notify-service/internal/ticket"
 	"github.com/vokzal-tech/notify-service/internal/tts"
 )
 
@@ -65,7 +73,7 @@ func main() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if migErr := db.AutoMigrate(&models.Notification{}); migErr != nil {
+	if migErr := db.AutoMigrate(&models.Notification{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
 
@@ -77,6 +85,27 @@ func main() {
 	}
 	defer natsConn.Close()
 
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "notify",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	smsClient := sms.NewSMSRuClient(cfg.SMS.APIID, cfg.SMS.URL, logger)
 	emailClient := email.NewEmailClient(cfg.Email.SMTPHost, cfg.Email.SMTPPort, cfg.Email.Username, cfg.Email.Password, cfg.Email.From, logger)
 
@@ -90,14 +119,53 @@ func main() {
 
 	ttsClient := tts.NewTTSClient(cfg.LocalAgent.URL, logger)
 
+	indexer, err := pii.NewIndexer(cfg.PII.IndexKey)
+	if err != nil {
+		logger.Fatal("Failed to configure PII index key", zap.Error(err))
+	}
+
 	notifyRepo := repository.NewNotificationRepository(db)
-	notifyService := service.NewNotifyService(notifyRepo, smsClient, emailClient, telegramClient, ttsClient, logger)
+	ticketClient := ticket.NewClient(cfg.Ticket.URL, cfg.Ticket.JWTSecret, cfg.Ticket.Timeout, logger)
+	notifyService := service.NewNotifyService(notifyRepo, smsClient, emailClient, telegramClient, ttsClient, ticketClient, indexer, dbtx.NewTransactor(db), outbox.New(db, "notify"), &cfg.PII, logger)
+
+	// Обезличивание уведомлений по сроку хранения; запросы на удаление ПД (pii.anonymize) — через консьюмер
+	go func() {
+		ticker := time.NewTicker(cfg.PII.RetentionInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				n, retErr := notifyService.RunRetention(context.Background())
+				if retErr != nil {
+					logger.Error("Failed to anonymize expired notifications", zap.Error(retErr))
+				}
+				if retErr != nil || n < cfg.PII.RetentionBatch {
+					break
+				}
+			}
+		}
+	}()
+
+	// Durable-консьюмер: предложения из листа ожидания и команды обезличивания, опубликованные
+	// при остановленном сервисе, будут обработаны после запуска
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "notify",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	notifyService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
+
 	notifyHandler := handlers.NewNotifyHandler(notifyService, logger)
 
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{
@@ -115,6 +183,7 @@ func main() {
 	notify.POST("/tts", notifyHandler.SendTTS)
 	notify.GET("/:id", notifyHandler.GetNotification)
 	notify.GET("/list", notifyHandler.ListNotifications)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "notify"), logger).Register(notify.Group("/dead-letters"))
 
 	srv := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -11,13 +12,17 @@ import (
 // Config — корневая конфигурация сервиса.
 type Config struct {
 	NATS       NATSConfig       `mapstructure:"nats"`
+	Consumer   ConsumerConfig   `mapstructure:"consumer"`
 	Server     ServerConfig     `mapstructure:"server"`
 	SMS        SMSConfig        `mapstructure:"sms"`
 	Telegram   TelegramConfig   `mapstructure:"telegram"`
 	Logger     LoggerConfig     `mapstructure:"logger"`
 	LocalAgent LocalAgentConfig `mapstructure:"local_agent"`
+	Ticket     TicketConfig     `mapstructure:"ticket"`
 	Database   DatabaseConfig   `mapstructure:"database"`
 	Email      EmailConfig      `mapstructure:"email"`
+	PII        PIIConfig        `mapstructure:"pii"`
+	Outbox     OutboxConfig     `mapstructure:"outbox"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -69,11 +74,45 @@ type TelegramConfig struct {
 	WebhookURL string `mapstructure:"webhook_url"`
 }
 
+// PIIConfig — обезличивание получателей и текстов уведомлений.
+// IndexKey — ключ слепых индексов, общий с ticket-service: по нему исполняются запросы на удаление ПД.
+// Уведомления обезличиваются через RetentionPeriod после создания (проверка раз в RetentionInterval).
+type PIIConfig struct {
+	IndexKey          string        `mapstructure:"index_key"`
+	RetentionPeriod   time.Duration `mapstructure:"retention_period"`
+	RetentionInterval time.Duration `mapstructure:"retention_interval"`
+	RetentionBatch    int           `mapstructure:"retention_batch"`
+}
+
 // LocalAgentConfig — настройки локального агента (TTS и т.п.).
 type LocalAgentConfig struct {
 	URL string `mapstructure:"url"`
 }
 
+// TicketConfig — внутреннее API ticket-service: контакты пассажиров для предложений листа ожидания и ваучеров.
+// JWTSecret совпадает с internal.jwt_secret ticket-service.
+type TicketConfig struct {
+	URL       string        `mapstructure:"url"`
+	JWTSecret string        `mapstructure:"jwt_secret"`
+	Timeout   time.Duration `mapstructure:"timeout"`
+}
+
+// OutboxConfig — доставка событий из outbox в NATS (см. outbox.RelayConfig в go-common).
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
 // Load загружает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -101,6 +140,22 @@ func Load() (*Config, error) {
 	viper.SetDefault("email.smtp_port", 587)
 	viper.SetDefault("email.from", "noreply@vokzal.tech")
 	viper.SetDefault("local_agent.url", "http://localhost:8081")
+	// Ключ для локальной разработки, совпадает с ticket-service
+	viper.SetDefault("ticket.url", "http://localhost:8083")
+	viper.SetDefault("ticket.jwt_secret", "vokzal_internal_secret_change_in_production")
+	viper.SetDefault("ticket.timeout", "10s")
+	viper.SetDefault("pii.index_key", "wKidY27OpRox8hRcwCaJdx/b4uCxig46eVjLGkve7VI=")
+	viper.SetDefault("pii.retention_period", "8760h")
+	viper.SetDefault("pii.retention_interval", "1h")
+	viper.SetDefault("pii.retention_batch", 500)
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
@@ -9,18 +9,22 @@ import (
 )
 
 // Notification — модель уведомления (SMS, email, Telegram, TTS).
+// RecipientIndex — слепой индекс получателя для исполнения запросов на удаление ПД.
+// После обезличивания получатель и тексты очищаются (AnonymizedAt), тип, статус и даты сохраняются.
 type Notification struct {
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	Subject   *string    `gorm:"type:varchar(200)" json:"subject,omitempty"`
-	SentAt    *time.Time `json:"sent_at,omitempty"`
-	ErrorMsg  *string    `gorm:"type:text" json:"error_msg,omitempty"`
-	ID        string     `gorm:"type:uuid;primary_key" json:"id"`
-	Type      string     `gorm:"type:varchar(20);not null;index" json:"type"`
-	Recipient string     `gorm:"type:varchar(100);not null" json:"recipient"`
-	Message   string     `gorm:"type:text;not null" json:"message"`
-	Status    string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
-	Metadata  string     `gorm:"type:jsonb" json:"metadata,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Subject        *string    `gorm:"type:varchar(200)" json:"subject,omitempty"`
+	SentAt         *time.Time `json:"sent_at,omitempty"`
+	ErrorMsg       *string    `gorm:"type:text" json:"error_msg,omitempty"`
+	RecipientIndex *string    `gorm:"type:varchar(64);index" json:"-"`
+	AnonymizedAt   *time.Time `gorm:"index" json:"anonymized_at,omitempty"`
+	ID             string     `gorm:"type:uuid;primary_key" json:"id"`
+	Type           string     `gorm:"type:varchar(20);not null;index" json:"type"`
+	Recipient      string     `gorm:"type:varchar(100);not null" json:"recipient"`
+	Message        string     `gorm:"type:text;not null" json:"message"`
+	Status         string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	Metadata       string     `gorm:"type:jsonb" json:"metadata,omitempty"`
 }
 
 // TableName возвращает имя таблицы для GORM.
@@ -4,9 +4,12 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+
 	"github.com/vokzal-tech/notify-service/internal/models"
 )
 
@@ -20,8 +23,16 @@ type NotificationRepository interface {
 	FindByType(ctx context.Context, notifType string, limit int) ([]*models.Notification, error)
 	Update(ctx context.Context, notification *models.Notification) error
 	List(ctx context.Context, limit int) ([]*models.Notification, error)
+	FindForRetention(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Notification, error)
+	FindByRecipientIndexes(ctx context.Context, indexes []string) ([]*models.Notification, error)
+	FindWithoutRecipientIndex(ctx context.Context, limit int) ([]*models.Notification, error)
+	SetRecipientIndex(ctx context.Context, id, index string) error
+	Anonymize(ctx context.Context, ids []string) error
 }
 
+// personalTypes — типы уведомлений с персональным получателем (TTS — объявления на вокзале).
+var personalTypes = []string{"sms", "email", "telegram"}
+
 type notificationRepository struct {
 	db *gorm.DB
 }
@@ -32,12 +43,12 @@ func NewNotificationRepository(db *gorm.DB) NotificationRepository {
 }
 
 func (r *notificationRepository) Create(ctx context.Context, notification *models.Notification) error {
-	return r.db.WithContext(ctx).Create(notification).Error
+	return dbtx.From(ctx, r.db).Create(notification).Error
 }
 
 func (r *notificationRepository) FindByID(ctx context.Context, id string) (*models.Notification, error) {
 	var notification models.Notification
-	if err := r.db.WithContext(ctx).First(&notification, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&notification, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrNotificationNotFound
 		}
@@ -48,7 +59,7 @@ func (r *notificationRepository) FindByID(ctx context.Context, id string) (*mode
 
 func (r *notificationRepository) FindByType(ctx context.Context, notifType string, limit int) ([]*models.Notification, error) {
 	var notifications []*models.Notification
-	query := r.db.WithContext(ctx).Where("type = ?", notifType).Order("created_at DESC")
+	query := dbtx.From(ctx, r.db).Where("type = ?", notifType).Order("created_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -59,12 +70,12 @@ func (r *notificationRepository) FindByType(ctx context.Context, notifType strin
 }
 
 func (r *notificationRepository) Update(ctx context.Context, notification *models.Notification) error {
-	return r.db.WithContext(ctx).Save(notification).Error
+	return dbtx.From(ctx, r.db).Save(notification).Error
 }
 
 func (r *notificationRepository) List(ctx context.Context, limit int) ([]*models.Notification, error) {
 	var notifications []*models.Notification
-	query := r.db.WithContext(ctx).Order("created_at DESC")
+	query := dbtx.From(ctx, r.db).Order("created_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -73,3 +84,67 @@ func (r *notificationRepository) List(ctx context.Context, limit int) ([]*models
 	}
 	return notifications, nil
 }
+
+// FindForRetention возвращает необезличенные персональные уведомления, созданные раньше createdBefore.
+func (r *notificationRepository) FindForRetention(ctx context.Context, createdBefore time.Time, limit int) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	err := dbtx.From(ctx, r.db).
+		Where("type IN ? AND anonymized_at IS NULL AND created_at < ?", personalTypes, createdBefore).
+		Limit(limit).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *notificationRepository) FindByRecipientIndexes(ctx context.Context, indexes []string) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	if len(indexes) == 0 {
+		return notifications, nil
+	}
+	err := dbtx.From(ctx, r.db).
+		Where("recipient_index IN ? AND anonymized_at IS NULL", indexes).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// FindWithoutRecipientIndex возвращает уведомления, созданные до появления слепых индексов.
+func (r *notificationRepository) FindWithoutRecipientIndex(ctx context.Context, limit int) ([]*models.Notification, error) {
+	var notifications []*models.Notification
+	err := dbtx.From(ctx, r.db).
+		Where("type IN ? AND anonymized_at IS NULL AND recipient_index IS NULL", personalTypes).
+		Limit(limit).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func (r *notificationRepository) SetRecipientIndex(ctx context.Context, id, index string) error {
+	return dbtx.From(ctx, r.db).Model(&models.Notification{}).
+		Where("id = ?", id).
+		UpdateColumn("recipient_index", index).Error
+}
+
+// Anonymize очищает получателя, тексты и метаданные уведомлений; тип, статус и даты сохраняются.
+func (r *notificationRepository) Anonymize(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return dbtx.From(ctx, r.db).Model(&models.Notification{}).
+		Where("id IN ?", ids).
+		UpdateColumns(map[string]interface{}{
+			"recipient":       "",
+			"recipient_index": nil,
+			"subject":         nil,
+			"message":         "",
+			"error_msg":       nil,
+			"metadata":        nil,
+			"anonymized_at":   time.Now(),
+		}).Error
+}
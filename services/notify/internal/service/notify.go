@@ -9,11 +9,19 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+	"github.com/vokzal-tech/go-common/pii"
+
+	"github.com/vokzal-tech/notify-service/internal/config"
 	"github.com/vokzal-tech/notify-service/internal/email"
 	"github.com/vokzal-tech/notify-service/internal/models"
 	"github.com/vokzal-tech/notify-service/internal/repository"
 	"github.com/vokzal-tech/notify-service/internal/sms"
 	"github.com/vokzal-tech/notify-service/internal/telegram"
+	"github.com/vokzal-tech/notify-service/internal/ticket"
 	"github.com/vokzal-tech/notify-service/internal/tts"
 )
 
@@ -30,6 +38,12 @@ type NotifyService interface {
 	SendTTS(ctx context.Context, text, language, priority string) (*models.Notification, error)
 	GetNotification(ctx context.Context, id string) (*models.Notification, error)
 	ListNotifications(ctx context.Context, limit int) ([]*models.Notification, error)
+
+	// Обезличивание
+	RunRetention(ctx context.Context) (int, error)
+
+	// Предложения мест из листа ожидания, ваучеры и команды обезличивания
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type notifyService struct {
@@ -38,6 +52,11 @@ type notifyService struct {
 	emailClient    *email.EmailClient
 	telegramClient *telegram.TelegramClient
 	ttsClient      *tts.TTSClient
+	ticketClient   *ticket.Client
+	indexer        *pii.Indexer
+	tx             *dbtx.Transactor
+	events         *outbox.Outbox
+	cfg            *config.PIIConfig
 	logger         *zap.Logger
 }
 
@@ -48,6 +67,11 @@ func NewNotifyService(
 	emailClient *email.EmailClient,
 	telegramClient *telegram.TelegramClient,
 	ttsClient *tts.TTSClient,
+	ticketClient *ticket.Client,
+	indexer *pii.Indexer,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
+	cfg *config.PIIConfig,
 	logger *zap.Logger,
 ) NotifyService {
 	return &notifyService{
@@ -56,16 +80,22 @@ func NewNotifyService(
 		emailClient:    emailClient,
 		telegramClient: telegramClient,
 		ttsClient:      ttsClient,
+		ticketClient:   ticketClient,
+		indexer:        indexer,
+		tx:             tx,
+		events:         events,
+		cfg:            cfg,
 		logger:         logger,
 	}
 }
 
 func (s *notifyService) SendSMS(ctx context.Context, phone, message string) (*models.Notification, error) {
 	notification := &models.Notification{
-		Type:      "sms",
-		Recipient: phone,
-		Message:   message,
-		Status:    "pending",
+		Type:           "sms",
+		Recipient:      phone,
+		RecipientIndex: s.recipientIndex(phone),
+		Message:        message,
+		Status:         "pending",
 	}
 
 	if err := s.repo.Create(ctx, notification); err != nil {
@@ -96,11 +126,12 @@ func (s *notifyService) SendSMS(ctx context.Context, phone, message string) (*mo
 
 func (s *notifyService) SendEmail(ctx context.Context, to, subject, body string) (*models.Notification, error) {
 	notification := &models.Notification{
-		Type:      "email",
-		Recipient: to,
-		Subject:   &subject,
-		Message:   body,
-		Status:    "pending",
+		Type:           "email",
+		Recipient:      to,
+		RecipientIndex: s.recipientIndex(to),
+		Subject:        &subject,
+		Message:        body,
+		Status:         "pending",
 	}
 
 	if err := s.repo.Create(ctx, notification); err != nil {
@@ -130,11 +161,13 @@ func (s *notifyService) SendEmail(ctx context.Context, to, subject, body string)
 }
 
 func (s *notifyService) SendTelegram(ctx context.Context, chatID int64, message string) (*models.Notification, error) {
+	recipient := strconv.FormatInt(chatID, 10)
 	notification := &models.Notification{
-		Type:      "telegram",
-		Recipient: strconv.FormatInt(chatID, 10),
-		Message:   message,
-		Status:    "pending",
+		Type:           "telegram",
+		Recipient:      recipient,
+		RecipientIndex: s.recipientIndex(recipient),
+		Message:        message,
+		Status:         "pending",
 	}
 
 	if err := s.repo.Create(ctx, notification); err != nil {
@@ -204,3 +237,11 @@ func (s *notifyService) GetNotification(ctx context.Context, id string) (*models
 func (s *notifyService) ListNotifications(ctx context.Context, limit int) ([]*models.Notification, error) {
 	return s.repo.List(ctx, limit)
 }
+
+// RegisterEventHandlers регистрирует в durable-консьюмере обработчики команд на отправку уведомлений
+// и команды обезличивания ПД.
+func (s *notifyService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypePIIAnonymize, events.Handler(s.HandleAnonymizeCommand))
+	consumer.Handle(events.TypeWaitlistOffered, events.Handler(s.HandleWaitlistOffered))
+	consumer.Handle(events.TypeVoucherIssued, events.Handler(s.HandleVoucherIssued))
+}
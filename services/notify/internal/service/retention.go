@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/notify-service/internal/models"
+)
+
+// anonymizedFields — поля уведомления, очищаемые при обезличивании (для журнала ticket-service).
+const anonymizedFields = "recipient,subject,message,error_msg,metadata"
+
+// RunRetention обезличивает очередную пачку уведомлений старше pii.retention_period.
+// Перед этим проставляет слепые индексы уведомлениям, созданным до их появления.
+func (s *notifyService) RunRetention(ctx context.Context) (int, error) {
+	if err := s.backfillRecipientIndexes(ctx); err != nil {
+		return 0, err
+	}
+
+	expired, err := s.repo.FindForRetention(ctx, time.Now().Add(-s.cfg.RetentionPeriod), s.cfg.RetentionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired notifications: %w", err)
+	}
+	if err = s.anonymize(ctx, expired, "retention", ""); err != nil {
+		return 0, err
+	}
+	if len(expired) > 0 {
+		s.logger.Info("Notifications anonymized by retention", zap.Int("notifications", len(expired)))
+	}
+	return len(expired), nil
+}
+
+// HandleAnonymizeCommand обезличивает уведомления на контакты пассажира по команде ticket-service
+// (событие pii.anonymize с запросом субъекта на удаление ПД). По запросу на удаление отчёт
+// отправляется и без уведомлений: ticket-service закрывает запрос, когда отчитались все сервисы.
+func (s *notifyService) HandleAnonymizeCommand(ctx context.Context, command *events.PIIAnonymize) error {
+	indexes := make([]string, 0, len(command.ContactIndexes))
+	for _, v := range command.ContactIndexes {
+		if v != "" {
+			indexes = append(indexes, v)
+		}
+	}
+	reason, requestID := command.Reason, command.ErasureRequestID
+	if len(indexes) == 0 && requestID == "" {
+		return nil
+	}
+
+	var notifications []*models.Notification
+	if len(indexes) > 0 {
+		found, err := s.repo.FindByRecipientIndexes(ctx, indexes)
+		if err != nil {
+			return fmt.Errorf("failed to find notifications: %w", err)
+		}
+		notifications = found
+	}
+	if err := s.anonymize(ctx, notifications, reason, requestID); err != nil {
+		return err
+	}
+	s.logger.Info("Notifications anonymized on request",
+		zap.String("erasure_request_id", requestID),
+		zap.Int("notifications", len(notifications)))
+	return nil
+}
+
+// anonymize обезличивает уведомления и отчитывается в журнал (событие pii.anonymized).
+// По запросу на удаление (requestID) отчёт отправляется и с пустым списком.
+func (s *notifyService) anonymize(ctx context.Context, notifications []*models.Notification, reason, requestID string) error {
+	if len(notifications) == 0 && requestID == "" {
+		return nil
+	}
+	ids := make([]string, 0, len(notifications))
+	for _, n := range notifications {
+		ids = append(ids, n.ID)
+	}
+	report := events.PIIAnonymized{
+		Service:    "notify",
+		EntityType: "notification",
+		Fields:     anonymizedFields,
+		Reason:     reason,
+		EntityIDs:  ids,
+	}
+	aggregateType, aggregateID := "pii_retention", reason
+	if requestID != "" {
+		report.ErasureRequestID = requestID
+		aggregateType, aggregateID = "erasure_request", requestID
+	}
+	// Отчёт попадает в журнал ticket-service, только если обезличивание зафиксировано
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if err := s.repo.Anonymize(ctx, ids); err != nil {
+			return fmt.Errorf("failed to anonymize notifications: %w", err)
+		}
+		return s.events.Publish(ctx, aggregateType, aggregateID, report)
+	})
+}
+
+// backfillRecipientIndexes проставляет слепые индексы получателей старым уведомлениям.
+// Пустой индекс отмечает получателя, по которому искать нечего.
+func (s *notifyService) backfillRecipientIndexes(ctx context.Context) error {
+	notifications, err := s.repo.FindWithoutRecipientIndex(ctx, s.cfg.RetentionBatch)
+	if err != nil {
+		return fmt.Errorf("failed to find notifications without recipient index: %w", err)
+	}
+	for _, n := range notifications {
+		if err = s.repo.SetRecipientIndex(ctx, n.ID, s.indexer.BlindIndex(n.Recipient)); err != nil {
+			return fmt.Errorf("failed to set recipient index: %w", err)
+		}
+	}
+	return nil
+}
+
+// recipientIndex возвращает слепой индекс получателя или nil, если получатель пуст.
+func (s *notifyService) recipientIndex(recipient string) *string {
+	idx := s.indexer.BlindIndex(recipient)
+	if idx == "" {
+		return nil
+	}
+	return &idx
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/notify-service/internal/ticket"
+)
+
+// voucherIssuedSubject — тема письма с компенсационным ваучером.
+const voucherIssuedSubject = "Компенсация за отмену рейса"
+
+// HandleVoucherIssued отправляет пассажиру код компенсационного ваучера за отменённый рейс
+// (событие voucher.issued от ticket-service): по SMS, если известен телефон, иначе по email.
+// Код и контакт событие не содержит — они запрашиваются во внутреннем API ticket-service.
+// Ваучер, закрытый до отправки, и пассажир, ПД которого уже обезличены, пропускаются.
+func (s *notifyService) HandleVoucherIssued(ctx context.Context, event *events.VoucherIssued) error {
+	if event.VoucherID == "" {
+		return eventbus.Permanent(errors.New("voucher.issued without voucher id"))
+	}
+	voucher, err := s.ticketClient.Voucher(ctx, event.VoucherID)
+	if errors.Is(err, ticket.ErrUnavailable) {
+		s.logger.Info("Compensation voucher closed before delivery", zap.String("voucher_id", event.VoucherID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get voucher %s: %w", event.VoucherID, err)
+	}
+
+	message := voucherIssuedMessage(voucher)
+	switch {
+	case voucher.Phone != nil:
+		_, err = s.SendSMS(ctx, *voucher.Phone, message)
+	case voucher.Email != nil:
+		_, err = s.SendEmail(ctx, *voucher.Email, voucherIssuedSubject, message)
+	default:
+		s.logger.Info("Compensation voucher has no passenger contact", zap.String("voucher_id", event.VoucherID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send voucher %s: %w", event.VoucherID, err)
+	}
+
+	s.logger.Info("Compensation voucher sent", zap.String("voucher_id", event.VoucherID))
+	return nil
+}
+
+// voucherIssuedMessage — текст уведомления: сумма скидки, код и срок действия.
+func voucherIssuedMessage(voucher *ticket.Voucher) string {
+	message := fmt.Sprintf("Ваш рейс отменён перевозчиком. Дарим скидку %.2f руб. на следующую поездку, промокод %s",
+		voucher.Amount, voucher.Code)
+	if voucher.ValidUntil != nil {
+		message += ", действует до " + voucher.ValidUntil.Local().Format("02.01.2006")
+	}
+	return message
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/notify-service/internal/ticket"
+)
+
+// waitlistOfferSubject — тема письма с предложением места из листа ожидания.
+const waitlistOfferSubject = "Освободилось место на рейс"
+
+// HandleWaitlistOffered отправляет пассажиру из листа ожидания предложение места со ссылкой на выкуп
+// (событие waitlist.offered от ticket-service). Контакт и ссылку событие не содержит — они запрашиваются
+// во внутреннем API ticket-service. Истёкшее или закрытое до отправки предложение не отправляется.
+func (s *notifyService) HandleWaitlistOffered(ctx context.Context, event *events.WaitlistOffered) error {
+	if event.EntryID == "" {
+		return eventbus.Permanent(errors.New("waitlist offer without entry id"))
+	}
+	offer, err := s.ticketClient.WaitlistOffer(ctx, event.EntryID)
+	if errors.Is(err, ticket.ErrUnavailable) {
+		s.logger.Info("Waitlist offer closed before delivery", zap.String("entry_id", event.EntryID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get waitlist offer %s: %w", event.EntryID, err)
+	}
+	if !time.Now().Before(offer.ExpiresAt) {
+		s.logger.Info("Waitlist offer expired before delivery", zap.String("entry_id", event.EntryID))
+		return nil
+	}
+
+	message := waitlistOfferMessage(offer)
+	switch {
+	case offer.Channel == "sms" && offer.Phone != nil:
+		_, err = s.SendSMS(ctx, *offer.Phone, message)
+	case offer.Channel == "email" && offer.Email != nil:
+		_, err = s.SendEmail(ctx, *offer.Email, waitlistOfferSubject, message)
+	default:
+		return eventbus.Permanent(fmt.Errorf("waitlist offer %s: no contact for channel %q", event.EntryID, offer.Channel))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send waitlist offer %s: %w", event.EntryID, err)
+	}
+
+	s.logger.Info("Waitlist offer sent",
+		zap.String("entry_id", event.EntryID),
+		zap.String("channel", offer.Channel))
+	return nil
+}
+
+// waitlistOfferMessage — текст предложения: рейс, цена, срок и ссылка на выкуп.
+func waitlistOfferMessage(offer *ticket.WaitlistOffer) string {
+	trip := "рейс"
+	if offer.DepartureTime != nil {
+		trip += " " + offer.DepartureTime.Local().Format("02.01 15:04")
+	}
+	return fmt.Sprintf("Освободилось место на %s, стоимость %.2f руб. Выкупите билет до %s: %s",
+		trip, offer.Price, offer.ExpiresAt.Local().Format("15:04"), offer.OfferURL)
+}
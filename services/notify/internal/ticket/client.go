@@ -0,0 +1,129 @@
+// Package ticket предоставляет клиент внутреннего API ticket-service: данные с ПД пассажиров,
+// которых нет в событиях (контакт, ссылка на выкуп, код ваучера).
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+
+	commonjwt "github.com/vokzal-tech/go-common/jwt"
+)
+
+// serviceName — имя notify-service в JWT запросов к внутреннему API.
+const serviceName = "notify"
+
+// ErrUnavailable возвращается, когда данных для уведомления больше нет: запись не найдена,
+// предложение или ваучер истекли или закрыты.
+var ErrUnavailable = errors.New("notice is no longer available")
+
+// WaitlistOffer — предложение места из листа ожидания: контакт для Channel, ссылка на выкуп и условия.
+type WaitlistOffer struct {
+	ExpiresAt     time.Time  `json:"expires_at"`
+	DepartureTime *time.Time `json:"departure_time,omitempty"`
+	Phone         *string    `json:"phone,omitempty"`
+	Email         *string    `json:"email,omitempty"`
+	TripID        string     `json:"trip_id"`
+	Channel       string     `json:"channel"`
+	OfferURL      string     `json:"offer_url"`
+	Price         float64    `json:"price"`
+}
+
+// Voucher — компенсационный ваучер: код на скидку Amount до ValidUntil и контакт пассажира
+// (оба пусты, если ПД билета обезличены).
+type Voucher struct {
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Phone      *string    `json:"phone,omitempty"`
+	Email      *string    `json:"email,omitempty"`
+	Code       string     `json:"code"`
+	TripID     string     `json:"trip_id"`
+	Amount     float64    `json:"amount"`
+}
+
+// Client — клиент внутреннего API ticket-service. Запросы подписываются JWT сервиса (роль "service").
+type Client struct {
+	client  *http.Client
+	tokens  *commonjwt.Manager
+	logger  *zap.Logger
+	baseURL string
+}
+
+// NewClient создаёт клиент ticket-service; jwtSecret — общий секрет внутреннего API (internal.jwt_secret).
+func NewClient(baseURL, jwtSecret string, timeout time.Duration, logger *zap.Logger) *Client {
+	return &Client{
+		baseURL: baseURL,
+		tokens:  commonjwt.NewManager(commonjwt.Config{Secret: jwtSecret, Issuer: serviceName, Expiration: time.Minute}),
+		client: &http.Client{
+			Timeout: timeout,
+		},
+		logger: logger,
+	}
+}
+
+// WaitlistOffer возвращает действующее предложение места по ID записи листа ожидания.
+func (c *Client) WaitlistOffer(ctx context.Context, entryID string) (*WaitlistOffer, error) {
+	var offer WaitlistOffer
+	if err := c.get(ctx, "/v1/internal/waitlist/"+url.PathEscape(entryID)+"/notice", &offer); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// Voucher возвращает действующий компенсационный ваучер по ID.
+func (c *Client) Voucher(ctx context.Context, voucherID string) (*Voucher, error) {
+	var voucher Voucher
+	if err := c.get(ctx, "/v1/internal/vouchers/"+url.PathEscape(voucherID)+"/notice", &voucher); err != nil {
+		return nil, err
+	}
+	return &voucher, nil
+}
+
+// get выполняет GET внутреннего API и разбирает поле data ответа в out.
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	token, err := c.tokens.Generate(serviceName, serviceName, "service", "")
+	if err != nil {
+		return fmt.Errorf("failed to sign service token: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call ticket-service: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			c.logger.Warn("failed to close response body", zap.Error(closeErr))
+		}
+	}()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return ErrUnavailable
+	default:
+		return fmt.Errorf("ticket-service returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	envelope := struct {
+		Data interface{} `json:"data"`
+	}{Data: out}
+	if err = json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	commonjwt "github.com/vokzal-tech/go-common/jwt"
+)
+
+const testSecret = "internal-secret"
+
+func TestWaitlistOffer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/internal/waitlist/entry-1/notice" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		token, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		claims, err := commonjwt.NewManager(commonjwt.Config{Secret: testSecret}).Verify(token)
+		if err != nil {
+			t.Fatalf("service token: %v", err)
+		}
+		if claims.Role != "service" || claims.Username != "notify" {
+			t.Errorf("claims role = %q, username = %q, want service, notify", claims.Role, claims.Username)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"channel": "sms", "phone": "+79001234567", "offer_url": "https://vokzal.tech/w/abc",
+			"expires_at": "2026-10-18T12:00:00Z", "trip_id": "trip-1", "price": 1500}}`))
+	}))
+	defer srv.Close()
+
+	offer, err := NewClient(srv.URL, testSecret, time.Second, zap.NewNop()).WaitlistOffer(context.Background(), "entry-1")
+	if err != nil {
+		t.Fatalf("WaitlistOffer: %v", err)
+	}
+	if offer.Channel != "sms" || offer.Phone == nil || *offer.Phone != "+79001234567" || offer.Price != 1500 {
+		t.Errorf("offer = %+v", offer)
+	}
+}
+
+func TestVoucher(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/internal/vouchers/voucher-1/notice" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		_, _ = w.Write([]byte(`{"data": {"code": "ABCD-1234", "amount": 300, "email": "a@example.com", "trip_id": "trip-1"}}`))
+	}))
+	defer srv.Close()
+
+	voucher, err := NewClient(srv.URL, testSecret, time.Second, zap.NewNop()).Voucher(context.Background(), "voucher-1")
+	if err != nil {
+		t.Fatalf("Voucher: %v", err)
+	}
+	if voucher.Code != "ABCD-1234" || voucher.Amount != 300 || voucher.Phone != nil || voucher.Email == nil {
+		t.Errorf("voucher = %+v", voucher)
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	for _, status := range []int{http.StatusNotFound, http.StatusGone} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(status)
+		}))
+		_, err := NewClient(srv.URL, testSecret, time.Second, zap.NewNop()).WaitlistOffer(context.Background(), "entry-1")
+		srv.Close()
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("status %d: err = %v, want ErrUnavailable", status, err)
+		}
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	_, err := NewClient(srv.URL, testSecret, time.Second, zap.NewNop()).WaitlistOffer(context.Background(), "entry-1")
+	if err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("status 500: err = %v, want retryable error", err)
+	}
+}
@@ -12,10 +12,17 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/idempotency"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/payment-service/internal/config"
 	"github.com/vokzal-tech/payment-service/internal/handlers"
 	"github.com/vokzal-tech/payment-service/internal/models"
@@ -65,7 +72,7 @@ func main() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if migErr := db.AutoMigrate(&models.Payment{}); migErr != nil {
+	if migErr := db.AutoMigrate(&models.Payment{}, &models.Refund{}, &idempotency.Record{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
 
@@ -80,6 +87,27 @@ func main() {
 
 	logger.Info("Connected to NATS", zap.String("url", cfg.NATS.URL))
 
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "payment",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	// Создать клиенты для провайдеров
 	tinkoffClient := tinkoff.NewTinkoffClient(
 		cfg.Tinkoff.TerminalKey,
@@ -95,19 +123,53 @@ func main() {
 		logger,
 	)
 
-	// Создать репозиторий
+	// Создать репозитории
 	paymentRepo := repository.NewPaymentRepository(db)
+	refundRepo := repository.NewRefundRepository(db)
 
 	// Создать сервис
 	paymentService := service.NewPaymentService(
 		paymentRepo,
+		refundRepo,
 		tinkoffClient,
 		sbpClient,
-		natsConn,
+		dbtx.NewTransactor(db),
+		outbox.New(db, "payment"),
 		cfg,
 		logger,
 	)
 
+	// Durable-консьюмер: возврат билета, пока сервис остановлен, запустит возврат денег после запуска
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "payment",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	paymentService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
+
+	// Защита от повторной инициализации платежа (Idempotency-Key)
+	idempotencyStore := idempotency.NewGormStore(db)
+	idempotent := idempotency.Middleware(idempotencyStore, idempotency.Config{
+		Logger:      logger,
+		TTL:         cfg.Idempotency.TTL,
+		LockTimeout: cfg.Idempotency.LockTimeout,
+		Required:    cfg.Idempotency.Required,
+	})
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, idemErr := idempotencyStore.DeleteExpired(context.Background(), time.Now()); idemErr != nil {
+				logger.Error("Failed to delete expired idempotency keys", zap.Error(idemErr))
+			}
+		}
+	}()
+
 	// Создать handlers
 	paymentHandler := handlers.NewPaymentHandler(paymentService, logger)
 
@@ -116,6 +178,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -128,15 +191,21 @@ func main() {
 
 	v1 := router.Group("/v1")
 	payments := v1.Group("/payments")
-	payments.POST("/tinkoff/init", paymentHandler.InitTinkoff)
-	payments.POST("/sbp/init", paymentHandler.InitSBP)
-	payments.POST("/cash/init", paymentHandler.InitCash)
+	payments.POST("/tinkoff/init", idempotent, paymentHandler.InitTinkoff)
+	payments.POST("/sbp/init", idempotent, paymentHandler.InitSBP)
+	payments.POST("/cash/init", idempotent, paymentHandler.InitCash)
 	payments.GET("/:id", paymentHandler.GetPayment)
 	payments.GET("/:id/status", paymentHandler.CheckStatus)
 	payments.GET("", paymentHandler.GetPaymentsByTicket)
 	payments.GET("/list", paymentHandler.ListPayments)
+	refunds := v1.Group("/refunds")
+	refunds.GET("", paymentHandler.GetRefundsByPayment)
+	refunds.GET("/stuck", paymentHandler.ListStuckRefunds)
+	refunds.POST("/:id/retry", paymentHandler.RetryRefund)
 	webhooks := v1.Group("/webhooks")
 	webhooks.POST("/tinkoff", paymentHandler.TinkoffWebhook)
+	// Необработанные события (роль admin)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "payment"), logger).Register(v1.Group("/payment/dead-letters"))
 
 	// Создать HTTP сервер
 	srv := &http.Server{
@@ -155,6 +224,16 @@ func main() {
 		}
 	}()
 
+	// Повторять возвраты после временных ошибок провайдера
+	go func() {
+		ticker := time.NewTicker(cfg.Refund.PollInterval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			paymentService.ProcessDueRefunds(context.Background())
+		}
+	}()
+
 	// Graceful shutdown
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
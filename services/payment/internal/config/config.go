@@ -4,18 +4,23 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config — корневая конфигурация сервиса.
 type Config struct {
-	NATS     NATSConfig     `mapstructure:"nats"`
-	Tinkoff  TinkoffConfig  `mapstructure:"tinkoff"`
-	SBP      SBPConfig      `mapstructure:"sbp"`
-	Server   ServerConfig   `mapstructure:"server"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
-	Database DatabaseConfig `mapstructure:"database"`
+	NATS        NATSConfig        `mapstructure:"nats"`
+	Tinkoff     TinkoffConfig     `mapstructure:"tinkoff"`
+	SBP         SBPConfig         `mapstructure:"sbp"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Logger      LoggerConfig      `mapstructure:"logger"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Consumer    ConsumerConfig    `mapstructure:"consumer"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	Refund      RefundConfig      `mapstructure:"refund"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -61,6 +66,40 @@ type SBPConfig struct {
 	APIKey     string `mapstructure:"api_key"`
 }
 
+// RefundConfig — повторы возвратов у провайдера: экспоненциальная задержка от RetryBaseDelay,
+// не более MaxAttempts попыток; ожидающий дольше StuckAfter возврат считается зависшим.
+type RefundConfig struct {
+	RetryBaseDelay time.Duration `mapstructure:"retry_base_delay"`
+	PollInterval   time.Duration `mapstructure:"poll_interval"`
+	StuckAfter     time.Duration `mapstructure:"stuck_after"`
+	MaxAttempts    int           `mapstructure:"max_attempts"`
+}
+
+// IdempotencyConfig — ключи Idempotency-Key для инициализации платежей.
+// Ответ хранится TTL, выполняемый запрос занимает ключ не дольше LockTimeout;
+// Required — отклонять запросы без ключа.
+type IdempotencyConfig struct {
+	TTL         time.Duration `mapstructure:"ttl"`
+	LockTimeout time.Duration `mapstructure:"lock_timeout"`
+	Required    bool          `mapstructure:"required"`
+}
+
+// OutboxConfig — доставка событий из outbox в NATS (см. outbox.RelayConfig в go-common).
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
 // Load загружает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -86,6 +125,22 @@ func Load() (*Config, error) {
 	viper.SetDefault("logger.level", "debug")
 	viper.SetDefault("tinkoff.api_url", "https://securepay.tinkoff.ru/v2")
 	viper.SetDefault("sbp.api_url", "https://api.sbp.nspk.ru")
+	viper.SetDefault("refund.max_attempts", 6)
+	viper.SetDefault("refund.retry_base_delay", "1m")
+	viper.SetDefault("refund.poll_interval", "30s")
+	viper.SetDefault("refund.stuck_after", "24h")
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lock_timeout", "1m")
+	viper.SetDefault("idempotency.required", false)
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
+
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
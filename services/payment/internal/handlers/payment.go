@@ -2,12 +2,14 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/payment-service/internal/repository"
 	"github.com/vokzal-tech/payment-service/internal/service"
 )
 
@@ -139,3 +141,52 @@ func (h *PaymentHandler) ListPayments(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": payments})
 }
+
+// GetRefundsByPayment возвращает возвраты по платежу.
+func (h *PaymentHandler) GetRefundsByPayment(c *gin.Context) {
+	paymentID := c.Query("payment_id")
+	if paymentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "payment_id is required"})
+		return
+	}
+
+	refunds, err := h.svc.GetRefundsByPayment(c.Request.Context(), paymentID)
+	if err != nil {
+		h.logger.Error("Failed to get refunds", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
+
+// ListStuckRefunds возвращает зависшие и отклонённые возвраты для бухгалтерии.
+func (h *PaymentHandler) ListStuckRefunds(c *gin.Context) {
+	refunds, err := h.svc.ListStuckRefunds(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to list stuck refunds", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list stuck refunds"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refunds})
+}
+
+// RetryRefund повторяет возврат у провайдера.
+func (h *PaymentHandler) RetryRefund(c *gin.Context) {
+	refund, err := h.svc.RetryRefund(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.logger.Error("Failed to retry refund", zap.Error(err))
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, repository.ErrRefundNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrRefundAlreadyCompleted), errors.Is(err, service.ErrRefundInProgress):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": refund})
+}
@@ -29,11 +29,36 @@ type Payment struct {
 	Amount       float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
 }
 
+// Refund — возврат денег по платежу у провайдера (Tinkoff, СБП).
+// Reference — ключ идемпотентности (например, "ticket.returned:<ticket_id>"), защищает от повторной доставки события.
+// Статусы: pending (ожидает отправки или повтора), succeeded, failed (провайдер отклонил или исчерпаны попытки).
+type Refund struct {
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	CompletedAt   *time.Time `json:"completed_at,omitempty"`
+	TicketID      *string    `gorm:"type:uuid;index" json:"ticket_id,omitempty"`
+	ExternalID    *string    `gorm:"type:varchar(100)" json:"external_id,omitempty"`
+	LastError     *string    `gorm:"type:text" json:"last_error,omitempty"`
+	ID            string     `gorm:"type:uuid;primary_key" json:"id"`
+	PaymentID     string     `gorm:"type:uuid;not null;index" json:"payment_id"`
+	Reference     string     `gorm:"type:varchar(150);not null;uniqueIndex" json:"reference"`
+	Provider      string     `gorm:"type:varchar(20);not null" json:"provider"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	Amount        float64    `gorm:"type:decimal(10,2);not null" json:"amount"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+}
+
 // TableName возвращает имя таблицы для GORM.
 func (Payment) TableName() string {
 	return "payments"
 }
 
+// TableName возвращает имя таблицы для GORM (Refund).
+func (Refund) TableName() string {
+	return "payment_refunds"
+}
+
 // BeforeCreate генерирует UUID для новой записи.
 func (p *Payment) BeforeCreate(_ *gorm.DB) error {
 	if p.ID == "" {
@@ -41,3 +66,11 @@ func (p *Payment) BeforeCreate(_ *gorm.DB) error {
 	}
 	return nil
 }
+
+// BeforeCreate генерирует UUID для новой записи (Refund).
+func (r *Refund) BeforeCreate(_ *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -4,14 +4,22 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vokzal-tech/go-common/dbtx"
 
 	"github.com/vokzal-tech/payment-service/internal/models"
 )
 
-// ErrPaymentNotFound возвращается, когда платёж не найден.
-var ErrPaymentNotFound = errors.New("payment not found")
+var (
+	// ErrPaymentNotFound возвращается, когда платёж не найден.
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrRefundNotFound возвращается, когда возврат не найден.
+	ErrRefundNotFound = errors.New("refund not found")
+)
 
 // PaymentRepository — интерфейс репозитория платежей.
 type PaymentRepository interface {
@@ -23,6 +31,18 @@ type PaymentRepository interface {
 	List(ctx context.Context, limit int) ([]*models.Payment, error)
 }
 
+// RefundRepository — интерфейс репозитория возвратов по платежам.
+type RefundRepository interface {
+	// Create создаёт возврат; created=false, если возврат с таким Reference уже существует.
+	Create(ctx context.Context, refund *models.Refund) (created bool, err error)
+	FindByID(ctx context.Context, id string) (*models.Refund, error)
+	FindByPaymentID(ctx context.Context, paymentID string) ([]*models.Refund, error)
+	ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]*models.Refund, error)
+	ClaimFailed(ctx context.Context, id string, lease time.Time) (bool, error)
+	FindStuck(ctx context.Context, pendingSince time.Time) ([]*models.Refund, error)
+	Update(ctx context.Context, refund *models.Refund) error
+}
+
 type paymentRepository struct {
 	db *gorm.DB
 }
@@ -32,13 +52,22 @@ func NewPaymentRepository(db *gorm.DB) PaymentRepository {
 	return &paymentRepository{db: db}
 }
 
+type refundRepository struct {
+	db *gorm.DB
+}
+
+// NewRefundRepository создаёт репозиторий возвратов.
+func NewRefundRepository(db *gorm.DB) RefundRepository {
+	return &refundRepository{db: db}
+}
+
 func (r *paymentRepository) Create(ctx context.Context, payment *models.Payment) error {
-	return r.db.WithContext(ctx).Create(payment).Error
+	return dbtx.From(ctx, r.db).Create(payment).Error
 }
 
 func findFirstBy[T any](db *gorm.DB, ctx context.Context, query string, arg any, notFoundErr error) (*T, error) {
 	var t T
-	if err := db.WithContext(ctx).First(&t, query, arg).Error; err != nil {
+	if err := dbtx.From(ctx, db).First(&t, query, arg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, notFoundErr
 		}
@@ -57,19 +86,19 @@ func (r *paymentRepository) FindByExternalID(ctx context.Context, externalID str
 
 func (r *paymentRepository) FindByTicketID(ctx context.Context, ticketID string) ([]*models.Payment, error) {
 	var payments []*models.Payment
-	if err := r.db.WithContext(ctx).Where("ticket_id = ?", ticketID).Find(&payments).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("ticket_id = ?", ticketID).Find(&payments).Error; err != nil {
 		return nil, err
 	}
 	return payments, nil
 }
 
 func (r *paymentRepository) Update(ctx context.Context, payment *models.Payment) error {
-	return r.db.WithContext(ctx).Save(payment).Error
+	return dbtx.From(ctx, r.db).Save(payment).Error
 }
 
 func (r *paymentRepository) List(ctx context.Context, limit int) ([]*models.Payment, error) {
 	var payments []*models.Payment
-	query := r.db.WithContext(ctx).Order("created_at DESC")
+	query := dbtx.From(ctx, r.db).Order("created_at DESC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -78,3 +107,85 @@ func (r *paymentRepository) List(ctx context.Context, limit int) ([]*models.Paym
 	}
 	return payments, nil
 }
+
+func (r *refundRepository) Create(ctx context.Context, refund *models.Refund) (bool, error) {
+	result := dbtx.From(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "reference"}}, DoNothing: true}).
+		Create(refund)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *refundRepository) FindByID(ctx context.Context, id string) (*models.Refund, error) {
+	return findFirstBy[models.Refund](r.db, ctx, "id = ?", id, ErrRefundNotFound)
+}
+
+func (r *refundRepository) FindByPaymentID(ctx context.Context, paymentID string) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	if err := dbtx.From(ctx, r.db).Where("payment_id = ?", paymentID).Order("created_at ASC").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// ClaimDue занимает до lease ожидающие возвраты, у которых наступило время очередной попытки.
+// Строки выбираются FOR UPDATE SKIP LOCKED, поэтому экземпляры сервиса не обрабатывают один возврат.
+func (r *refundRepository) ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	err := dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		query := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND (next_attempt_at IS NULL OR next_attempt_at <= ?)", "pending", now).
+			Order("created_at ASC")
+		if limit > 0 {
+			query = query.Limit(limit)
+		}
+		if err := query.Find(&refunds).Error; err != nil {
+			return err
+		}
+		if len(refunds) == 0 {
+			return nil
+		}
+		ids := make([]string, len(refunds))
+		for i, refund := range refunds {
+			ids[i] = refund.ID
+			refund.NextAttemptAt = &lease
+		}
+		return tx.Model(&models.Refund{}).Where("id IN ?", ids).Update("next_attempt_at", lease).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+// ClaimFailed возвращает отклонённый возврат в ожидание с обнулёнными попытками и занимает его до lease.
+// false — возврат не в статусе failed (выполнен или уже обрабатывается).
+func (r *refundRepository) ClaimFailed(ctx context.Context, id string, lease time.Time) (bool, error) {
+	result := dbtx.From(ctx, r.db).Model(&models.Refund{}).
+		Where("id = ? AND status = ?", id, "failed").
+		Updates(map[string]interface{}{"status": "pending", "attempts": 0, "next_attempt_at": lease})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// FindStuck возвращает возвраты, требующие внимания бухгалтерии: отклонённые провайдером
+// или исчерпавшие попытки, а также ожидающие дольше pendingSince.
+func (r *refundRepository) FindStuck(ctx context.Context, pendingSince time.Time) ([]*models.Refund, error) {
+	var refunds []*models.Refund
+	err := dbtx.From(ctx, r.db).
+		Where("status = ? OR (status = ? AND created_at < ?)", "failed", "pending", pendingSince).
+		Order("created_at ASC").
+		Find(&refunds).Error
+	if err != nil {
+		return nil, err
+	}
+	return refunds, nil
+}
+
+func (r *refundRepository) Update(ctx context.Context, refund *models.Refund) error {
+	return dbtx.From(ctx, r.db).Save(refund).Error
+}
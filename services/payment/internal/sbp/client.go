@@ -4,6 +4,7 @@ package sbp
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,6 +13,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrRejected возвращается, когда СБП явно отклонила операцию (success=false).
+// Такие ошибки окончательны; прочие (сеть, 5xx) — временные, операцию можно повторить.
+var ErrRejected = errors.New("SBP rejected operation")
+
 // SBPClient — клиент для работы с СБП.
 //
 //nolint:revive // Имя сохраняем для ясности (sbp.Client).
@@ -57,6 +62,22 @@ type StatusResponse struct {
 	Success   bool       `json:"success"`
 }
 
+// RefundRequest — запрос на возврат по платежу СБП (полный или частичный).
+type RefundRequest struct {
+	MerchantID string  `json:"merchantId"`
+	PaymentID  string  `json:"paymentId"`
+	Amount     float64 `json:"amount"`
+}
+
+// RefundResponse — ответ на запрос возврата.
+type RefundResponse struct {
+	RefundID string  `json:"refundId"`
+	Status   string  `json:"status"`
+	ErrorMsg string  `json:"errorMsg,omitempty"`
+	Amount   float64 `json:"amount"`
+	Success  bool    `json:"success"`
+}
+
 // NewSBPClient создаёт клиент СБП.
 func NewSBPClient(merchantID, apiURL, apiKey string, logger *zap.Logger) *SBPClient {
 	return &SBPClient{
@@ -164,3 +185,59 @@ func (c *SBPClient) GetStatus(paymentID string) (*StatusResponse, error) {
 
 	return &result, nil
 }
+
+// Refund возвращает указанную сумму по платежу СБП. idempotencyKey — ID операции у вызывающей стороны:
+// повтор с тем же ключом возвращает результат первого запроса, а не выполняет возврат снова.
+func (c *SBPClient) Refund(paymentID string, amount float64, idempotencyKey string) (*RefundResponse, error) {
+	req := &RefundRequest{
+		MerchantID: c.merchantID,
+		PaymentID:  paymentID,
+		Amount:     amount,
+	}
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/payment/refund", c.apiURL)
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	httpReq.Header.Set("Idempotency-Key", idempotencyKey)
+
+	c.logger.Debug("SBP Refund request", zap.String("url", url), zap.String("payment_id", paymentID), zap.Float64("amount", amount))
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			c.logger.Warn("failed to close response body", zap.Error(closeErr))
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("SBP unavailable: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result RefundResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("%w: %s", ErrRejected, result.ErrorMsg)
+	}
+
+	return &result, nil
+}
@@ -3,13 +3,16 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
-	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/payment-service/internal/config"
 	"github.com/vokzal-tech/payment-service/internal/models"
 	"github.com/vokzal-tech/payment-service/internal/repository"
@@ -39,13 +42,24 @@ type PaymentService interface {
 
 	// List
 	ListPayments(ctx context.Context, limit int) ([]*models.Payment, error)
+
+	// Refunds
+	HandleTicketReturned(ctx context.Context, ticket *events.TicketReturned) error
+	HandleTicketExchanged(ctx context.Context, exchange *events.TicketExchanged) error
+	ProcessDueRefunds(ctx context.Context)
+	RetryRefund(ctx context.Context, id string) (*models.Refund, error)
+	GetRefundsByPayment(ctx context.Context, paymentID string) ([]*models.Refund, error)
+	ListStuckRefunds(ctx context.Context) ([]*models.Refund, error)
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type paymentService struct {
 	repo          repository.PaymentRepository
+	refundRepo    repository.RefundRepository
 	tinkoffClient *tinkoff.TinkoffClient
 	sbpClient     *sbp.SBPClient
-	natsConn      *nats.Conn
+	tx            *dbtx.Transactor
+	events        *outbox.Outbox
 	cfg           *config.Config
 	logger        *zap.Logger
 }
@@ -60,17 +74,21 @@ type InitPaymentRequest struct {
 // NewPaymentService создаёт сервис платежей.
 func NewPaymentService(
 	repo repository.PaymentRepository,
+	refundRepo repository.RefundRepository,
 	tinkoffClient *tinkoff.TinkoffClient,
 	sbpClient *sbp.SBPClient,
-	natsConn *nats.Conn,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) PaymentService {
 	return &paymentService{
 		repo:          repo,
+		refundRepo:    refundRepo,
 		tinkoffClient: tinkoffClient,
 		sbpClient:     sbpClient,
-		natsConn:      natsConn,
+		tx:            tx,
+		events:        events,
 		cfg:           cfg,
 		logger:        logger,
 	}
@@ -188,13 +206,17 @@ func (s *paymentService) InitCashPayment(ctx context.Context, req *InitPaymentRe
 	now := time.Now()
 	payment.ConfirmedAt = &now
 
-	if err := s.repo.Create(ctx, payment); err != nil {
-		return nil, fmt.Errorf("failed to create payment: %w", err)
+	// Наличная оплата подтверждена сразу — платёж и событие фиксируются вместе
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if err := s.repo.Create(ctx, payment); err != nil {
+			return fmt.Errorf("failed to create payment: %w", err)
+		}
+		return s.publishPaymentEvent(ctx, payment)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Отправить событие подтверждения
-	s.publishPaymentEvent(payment)
-
 	s.logger.Info("Cash payment created", zap.String("payment_id", payment.ID))
 
 	return payment, nil
@@ -215,7 +237,8 @@ func (s *paymentService) CheckPaymentStatus(ctx context.Context, id string) (*mo
 		return nil, err
 	}
 
-	if payment.Status == statusConfirmed || payment.Status == statusFailed {
+	switch payment.Status {
+	case statusConfirmed, statusFailed, statusRefunded, statusPartiallyRefunded:
 		return payment, nil
 	}
 
@@ -236,7 +259,6 @@ func (s *paymentService) CheckPaymentStatus(ctx context.Context, id string) (*mo
 			payment.Status = statusConfirmed
 			now := time.Now()
 			payment.ConfirmedAt = &now
-			s.publishPaymentEvent(payment)
 		case "REJECTED":
 			payment.Status = statusFailed
 			errMsg := "Payment rejected"
@@ -253,7 +275,6 @@ func (s *paymentService) CheckPaymentStatus(ctx context.Context, id string) (*mo
 		case "paid":
 			payment.Status = statusConfirmed
 			payment.ConfirmedAt = result.PaidAt
-			s.publishPaymentEvent(payment)
 		case "expired", "cancelled": //nolint:misspell // SBP/API returns British spelling
 			payment.Status = statusFailed
 			errMsg := fmt.Sprintf("Payment %s", result.Status)
@@ -261,7 +282,7 @@ func (s *paymentService) CheckPaymentStatus(ctx context.Context, id string) (*mo
 		}
 	}
 
-	if err := s.repo.Update(ctx, payment); err != nil {
+	if err := s.savePaymentStatus(ctx, payment, payment.Status == statusConfirmed); err != nil {
 		s.logger.Error("Failed to update payment status", zap.Error(err))
 	}
 
@@ -284,20 +305,21 @@ func (s *paymentService) HandleTinkoffWebhook(ctx context.Context, data map[stri
 	if err != nil {
 		return fmt.Errorf("payment not found: %w", err)
 	}
+	// Провайдер может повторить webhook — подтверждение публикуется один раз
+	wasConfirmed := payment.Status == statusConfirmed
 
 	switch status {
 	case "CONFIRMED", "AUTHORIZED":
 		payment.Status = statusConfirmed
 		now := time.Now()
 		payment.ConfirmedAt = &now
-		s.publishPaymentEvent(payment)
 	case "REJECTED":
 		payment.Status = statusFailed
 		errMsg := "Payment rejected"
 		payment.ErrorMsg = &errMsg
 	}
 
-	if err := s.repo.Update(ctx, payment); err != nil {
+	if err := s.savePaymentStatus(ctx, payment, payment.Status == statusConfirmed && !wasConfirmed); err != nil {
 		return fmt.Errorf("failed to update payment: %w", err)
 	}
 
@@ -312,24 +334,38 @@ func (s *paymentService) ListPayments(ctx context.Context, limit int) ([]*models
 	return s.repo.List(ctx, limit)
 }
 
-const paymentConfirmedSubject = "payment.confirmed"
-
-// publishPaymentEvent публикует событие подтверждения платежа в NATS.
-func (s *paymentService) publishPaymentEvent(payment *models.Payment) {
-	if s.natsConn == nil || !s.natsConn.IsConnected() {
-		s.logger.Warn("NATS connection unavailable, skipping payment event",
-			zap.String("payment_id", payment.ID),
-			zap.String("subject", paymentConfirmedSubject))
-		return
-	}
+// savePaymentStatus сохраняет статус платежа; если платёж только что подтверждён (confirmed),
+// вместе с ним фиксируется событие payment.confirmed.
+func (s *paymentService) savePaymentStatus(ctx context.Context, payment *models.Payment, confirmed bool) error {
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if err := s.repo.Update(ctx, payment); err != nil {
+			return err
+		}
+		if !confirmed {
+			return nil
+		}
+		return s.publishPaymentEvent(ctx, payment)
+	})
+}
 
-	data, err := json.Marshal(payment)
-	if err != nil {
-		s.logger.Error("Failed to marshal payment event", zap.Error(err))
-		return
-	}
+// publishPaymentEvent записывает событие подтверждения платежа в outbox.
+func (s *paymentService) publishPaymentEvent(ctx context.Context, payment *models.Payment) error {
+	return s.publishEvent(ctx, payment.ID, events.PaymentConfirmed{
+		CreatedAt:   payment.CreatedAt,
+		ConfirmedAt: payment.ConfirmedAt,
+		TicketID:    payment.TicketID,
+		ExternalID:  payment.ExternalID,
+		ID:          payment.ID,
+		Provider:    payment.Provider,
+		Method:      payment.Method,
+		Status:      payment.Status,
+		Currency:    payment.Currency,
+		Amount:      payment.Amount,
+	})
+}
 
-	if err := s.natsConn.Publish(paymentConfirmedSubject, data); err != nil {
-		s.logger.Error("Failed to publish payment event", zap.Error(err), zap.String("subject", paymentConfirmedSubject))
-	}
+// publishEvent записывает событие (платёж, возврат) в outbox. События одного платежа
+// доставляются в порядке записи: подтверждение раньше возврата.
+func (s *paymentService) publishEvent(ctx context.Context, paymentID string, event events.Event) error {
+	return s.events.Publish(ctx, "payment", paymentID, event)
 }
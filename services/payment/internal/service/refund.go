@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/payment-service/internal/models"
+	"github.com/vokzal-tech/payment-service/internal/sbp"
+	"github.com/vokzal-tech/payment-service/internal/tinkoff"
+)
+
+const (
+	statusRefunded          = "refunded"
+	statusPartiallyRefunded = "partially_refunded"
+
+	refundStatusPending   = "pending"
+	refundStatusSucceeded = "succeeded"
+	refundStatusFailed    = "failed"
+
+	// dueRefundsBatch — сколько возвратов обрабатывается за один проход фонового воркера.
+	dueRefundsBatch = 50
+	// maxRetryDelay — верхняя граница экспоненциальной задержки между попытками.
+	maxRetryDelay = 6 * time.Hour
+)
+
+var (
+	// ErrRefundNotSupported возвращается для провайдеров без возврата через API (наличные).
+	ErrRefundNotSupported = errors.New("refund is not supported for payment provider")
+	// ErrRefundAlreadyCompleted возвращается при повторе уже выполненного возврата.
+	ErrRefundAlreadyCompleted = errors.New("refund already completed")
+	// ErrRefundInProgress возвращается при ручном повторе возврата, который ещё обрабатывается.
+	ErrRefundInProgress = errors.New("refund is still being processed")
+)
+
+// HandleTicketReturned создаёт возврат у провайдера по событию ticket.returned
+// для билетов, оплаченных картой или через СБП. Повторная доставка события не создаёт второй возврат.
+func (s *paymentService) HandleTicketReturned(ctx context.Context, ticket *events.TicketReturned) error {
+	ticketID := ticket.ID
+	if ticketID == "" {
+		return eventbus.Permanent(errors.New("invalid ticket.returned data: no id"))
+	}
+	if !providerMethod(ticket.PaymentMethod) {
+		return nil
+	}
+	if ticket.RefundAmount == nil || *ticket.RefundAmount <= 0 {
+		return nil
+	}
+	return s.refundTicketPayment(ctx, ticketID, "ticket.returned:"+ticketID, *ticket.RefundAmount)
+}
+
+// HandleTicketExchanged возвращает пассажиру у провайдера разницу тарифов за вычетом сбора
+// (событие ticket.exchanged), если новый билет дешевле исходного, оплаченного картой или через СБП.
+// Возврат идёт по платежу исходного билета; повторная доставка события не создаёт второй возврат.
+func (s *paymentService) HandleTicketExchanged(ctx context.Context, exchange *events.TicketExchanged) error {
+	if exchange.ID == "" || exchange.ExchangedFromID == "" {
+		return eventbus.Permanent(errors.New("invalid ticket.exchanged data: id and exchanged_from_id are required"))
+	}
+	if !providerMethod(exchange.PaymentMethod) {
+		return nil
+	}
+	// Разница тарифов отрицательна, когда новый билет дешевле; сбор за обмен удерживается из неё
+	amount := -math.Round((exchange.FareDifference+exchange.ExchangeFee)*100) / 100
+	if amount <= 0 {
+		return nil
+	}
+	return s.refundTicketPayment(ctx, exchange.ExchangedFromID, "ticket.exchanged:"+exchange.ID, amount)
+}
+
+// refundTicketPayment создаёт возврат amount по платежу провайдера за билет ticketID и выполняет первую
+// попытку. reference — ключ идемпотентности: возврат с тем же ключом повторно не создаётся.
+func (s *paymentService) refundTicketPayment(ctx context.Context, ticketID, reference string, amount float64) error {
+	payments, err := s.repo.FindByTicketID(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to find payments for ticket: %w", err)
+	}
+	payment := refundablePayment(payments)
+	if payment == nil {
+		s.logger.Warn("No provider payment found for ticket refund",
+			zap.String("ticket_id", ticketID),
+			zap.String("reference", reference),
+			zap.Float64("refund_amount", amount))
+		return nil
+	}
+
+	// Нельзя вернуть больше, чем осталось на платеже после предыдущих возвратов
+	if remaining := payment.Amount - refundedAmount(payment); amount > remaining {
+		amount = remaining
+	}
+	if amount <= 0 {
+		return nil
+	}
+
+	// До первой попытки возврат «занят» обработчиком события, чтобы его не подхватил воркер повторов
+	lease := s.refundLease()
+	refund := &models.Refund{
+		PaymentID:     payment.ID,
+		TicketID:      &ticketID,
+		Reference:     reference,
+		Provider:      payment.Provider,
+		Status:        refundStatusPending,
+		Amount:        amount,
+		NextAttemptAt: &lease,
+	}
+	created, err := s.refundRepo.Create(ctx, refund)
+	if err != nil {
+		return fmt.Errorf("failed to create refund: %w", err)
+	}
+	if !created {
+		s.logger.Debug("Refund already exists", zap.String("reference", reference))
+		return nil
+	}
+
+	s.attemptRefund(ctx, refund)
+	return nil
+}
+
+// ProcessDueRefunds повторяет ожидающие возвраты, у которых наступило время очередной попытки.
+// Возвраты занимаются на время попытки, поэтому воркеры нескольких экземпляров не дублируют их.
+func (s *paymentService) ProcessDueRefunds(ctx context.Context) {
+	refunds, err := s.refundRepo.ClaimDue(ctx, time.Now(), s.refundLease(), dueRefundsBatch)
+	if err != nil {
+		s.logger.Error("Failed to find due refunds", zap.Error(err))
+		return
+	}
+	for _, refund := range refunds {
+		s.attemptRefund(ctx, refund)
+	}
+}
+
+// RetryRefund заново запускает отклонённый возврат (ручное действие бухгалтерии). Ожидающий возврат
+// не повторяется: его попытки выполняет воркер, и вторая параллельная попытка могла бы вернуть деньги дважды.
+func (s *paymentService) RetryRefund(ctx context.Context, id string) (*models.Refund, error) {
+	claimed, err := s.refundRepo.ClaimFailed(ctx, id, s.refundLease())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim refund: %w", err)
+	}
+	refund, err := s.refundRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		if refund.Status == refundStatusSucceeded {
+			return nil, ErrRefundAlreadyCompleted
+		}
+		return nil, ErrRefundInProgress
+	}
+
+	s.attemptRefund(ctx, refund)
+
+	return refund, nil
+}
+
+func (s *paymentService) GetRefundsByPayment(ctx context.Context, paymentID string) ([]*models.Refund, error) {
+	return s.refundRepo.FindByPaymentID(ctx, paymentID)
+}
+
+// ListStuckRefunds возвращает возвраты, требующие ручной обработки.
+func (s *paymentService) ListStuckRefunds(ctx context.Context) ([]*models.Refund, error) {
+	return s.refundRepo.FindStuck(ctx, time.Now().Add(-s.cfg.Refund.StuckAfter))
+}
+
+// attemptRefund выполняет одну попытку возврата у провайдера. Временные ошибки откладывают
+// возврат с экспоненциальной задержкой, отказ провайдера или исчерпание попыток переводят его в failed.
+func (s *paymentService) attemptRefund(ctx context.Context, refund *models.Refund) {
+	payment, err := s.repo.FindByID(ctx, refund.PaymentID)
+	if err != nil {
+		s.logger.Error("Failed to find payment for refund", zap.Error(err), zap.String("refund_id", refund.ID))
+		return
+	}
+
+	refund.Attempts++
+	externalID, err := s.callProviderRefund(payment, refund)
+	now := time.Now()
+
+	switch {
+	case err == nil:
+		refund.Status = refundStatusSucceeded
+		refund.ExternalID = &externalID
+		refund.CompletedAt = &now
+		refund.NextAttemptAt = nil
+		refund.LastError = nil
+	case isFinalRefundError(err) || refund.Attempts >= s.cfg.Refund.MaxAttempts:
+		errMsg := err.Error()
+		refund.Status = refundStatusFailed
+		refund.LastError = &errMsg
+		refund.NextAttemptAt = nil
+	default:
+		errMsg := err.Error()
+		next := now.Add(s.retryDelay(refund.Attempts))
+		refund.LastError = &errMsg
+		refund.NextAttemptAt = &next
+	}
+
+	// Результат попытки, статус платежа и событие о возврате фиксируются вместе
+	updErr := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.refundRepo.Update(ctx, refund); dbErr != nil {
+			return dbErr
+		}
+		switch refund.Status {
+		case refundStatusSucceeded:
+			if dbErr := s.applyRefundToPayment(ctx, payment, refund.Amount, now); dbErr != nil {
+				return dbErr
+			}
+			return s.publishEvent(ctx, payment.ID, events.PaymentRefunded{Refund: refundEvent(refund)})
+		case refundStatusFailed:
+			return s.publishEvent(ctx, payment.ID, events.PaymentRefundFailed{Refund: refundEvent(refund)})
+		}
+		return nil
+	})
+	if updErr != nil {
+		s.logger.Error("Failed to update refund", zap.Error(updErr), zap.String("refund_id", refund.ID))
+		return
+	}
+
+	switch refund.Status {
+	case refundStatusSucceeded:
+		s.logger.Info("Refund completed",
+			zap.String("refund_id", refund.ID),
+			zap.String("payment_id", payment.ID),
+			zap.Float64("amount", refund.Amount))
+	case refundStatusFailed:
+		s.logger.Error("Refund failed, manual action required",
+			zap.String("refund_id", refund.ID),
+			zap.String("payment_id", payment.ID),
+			zap.Int("attempts", refund.Attempts),
+			zap.Error(err))
+	default:
+		s.logger.Warn("Refund attempt failed, will retry",
+			zap.String("refund_id", refund.ID),
+			zap.Int("attempts", refund.Attempts),
+			zap.Timep("next_attempt_at", refund.NextAttemptAt),
+			zap.Error(err))
+	}
+}
+
+// callProviderRefund вызывает возврат у провайдера платежа и возвращает ID операции у провайдера.
+// ID возврата передаётся провайдеру как ключ идемпотентности: если попытка завершилась таймаутом,
+// а возврат у провайдера прошёл, повтор вернёт его результат, а не вернёт деньги ещё раз.
+func (s *paymentService) callProviderRefund(payment *models.Payment, refund *models.Refund) (string, error) {
+	if payment.ExternalID == nil {
+		return "", fmt.Errorf("%w: payment %s has no external id", ErrRefundNotSupported, payment.ID)
+	}
+
+	switch payment.Provider {
+	case "tinkoff":
+		result, err := s.tinkoffClient.Cancel(*payment.ExternalID, refund.Amount, refund.ID)
+		if err != nil {
+			return "", err
+		}
+		return result.PaymentID, nil
+	case "sbp":
+		result, err := s.sbpClient.Refund(*payment.ExternalID, refund.Amount, refund.ID)
+		if err != nil {
+			return "", err
+		}
+		return result.RefundID, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrRefundNotSupported, payment.Provider)
+	}
+}
+
+// applyRefundToPayment учитывает выполненный возврат в платеже (частичный или полный).
+func (s *paymentService) applyRefundToPayment(ctx context.Context, payment *models.Payment, amount float64, at time.Time) error {
+	total := refundedAmount(payment) + amount
+	payment.RefundAmount = &total
+	payment.RefundedAt = &at
+	if total >= payment.Amount {
+		payment.Status = statusRefunded
+	} else {
+		payment.Status = statusPartiallyRefunded
+	}
+
+	if err := s.repo.Update(ctx, payment); err != nil {
+		return fmt.Errorf("failed to update payment after refund: %w", err)
+	}
+	return nil
+}
+
+// refundLease возвращает срок, до которого возврат занят текущей попыткой.
+func (s *paymentService) refundLease() time.Time {
+	return time.Now().Add(s.cfg.Refund.RetryBaseDelay)
+}
+
+// retryDelay возвращает задержку перед следующей попыткой: base * 2^(attempts-1), не более maxRetryDelay.
+func (s *paymentService) retryDelay(attempts int) time.Duration {
+	delay := s.cfg.Refund.RetryBaseDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+func isFinalRefundError(err error) bool {
+	return errors.Is(err, tinkoff.ErrRejected) || errors.Is(err, sbp.ErrRejected) || errors.Is(err, ErrRefundNotSupported)
+}
+
+// refundablePayment выбирает подтверждённый платёж провайдера, по которому ещё можно вернуть деньги.
+func refundablePayment(payments []*models.Payment) *models.Payment {
+	for _, p := range payments {
+		if p.Provider != "tinkoff" && p.Provider != "sbp" {
+			continue
+		}
+		if p.Status == statusConfirmed || p.Status == statusPartiallyRefunded {
+			return p
+		}
+	}
+	return nil
+}
+
+// providerMethod сообщает, оплачивается ли способ оплаты билета через провайдера (карта, СБП).
+// Пустой способ оплаты — событие от версии ticket-service без этого поля.
+func providerMethod(method string) bool {
+	return method == "" || method == "card" || method == "sbp"
+}
+
+// refundEvent возвращает возврат для событий.
+func refundEvent(refund *models.Refund) events.Refund {
+	return events.Refund{
+		CreatedAt:   refund.CreatedAt,
+		CompletedAt: refund.CompletedAt,
+		TicketID:    refund.TicketID,
+		ExternalID:  refund.ExternalID,
+		LastError:   refund.LastError,
+		ID:          refund.ID,
+		PaymentID:   refund.PaymentID,
+		Reference:   refund.Reference,
+		Provider:    refund.Provider,
+		Status:      refund.Status,
+		Amount:      refund.Amount,
+		Attempts:    refund.Attempts,
+	}
+}
+
+func refundedAmount(payment *models.Payment) float64 {
+	if payment.RefundAmount == nil {
+		return 0
+	}
+	return *payment.RefundAmount
+}
+
+// RegisterEventHandlers регистрирует обработчики событий, запускающих возвраты, в durable-консьюмере.
+func (s *paymentService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypeTicketReturned, events.Handler(s.HandleTicketReturned))
+	consumer.Handle(events.TypeTicketExchanged, events.Handler(s.HandleTicketExchanged))
+}
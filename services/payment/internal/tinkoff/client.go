@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"sort"
 	"strings"
@@ -15,6 +17,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrRejected is returned when Tinkoff explicitly rejects an operation (Success=false).
+// Such errors are final; other errors (network, 5xx) are transient and may be retried.
+var ErrRejected = errors.New("tinkoff rejected operation")
+
 // TinkoffClient is a client for interacting with the Tinkoff Acquiring API.
 //
 //nolint:revive // Name preserved for clarity (tinkoff.Client).
@@ -65,6 +71,30 @@ type GetStateResponse struct {
 	Success   bool   `json:"Success"`
 }
 
+// CancelRequest is a payment cancel/refund request. Amount is omitted for a full refund.
+// ExternalRequestID makes the operation idempotent: a repeated request with the same ID
+// returns the result of the first one instead of refunding again.
+type CancelRequest struct {
+	TerminalKey       string `json:"TerminalKey"`
+	PaymentID         string `json:"PaymentId"`
+	ExternalRequestID string `json:"ExternalRequestId"`
+	Token             string `json:"Token"`
+	Amount            int64  `json:"Amount,omitempty"`
+}
+
+// CancelResponse is a payment cancel/refund response.
+// Status is REVERSED, REFUNDED or PARTIAL_REFUNDED; amounts are in kopecks.
+type CancelResponse struct {
+	ErrorCode      string `json:"ErrorCode"`
+	Message        string `json:"Message"`
+	Status         string `json:"Status"`
+	PaymentID      string `json:"PaymentId"`
+	OrderID        string `json:"OrderId"`
+	OriginalAmount int64  `json:"OriginalAmount"`
+	NewAmount      int64  `json:"NewAmount"`
+	Success        bool   `json:"Success"`
+}
+
 // NewTinkoffClient creates a Tinkoff Acquiring client.
 // apiSecret is the terminal API secret (not a user password) used to sign API requests.
 func NewTinkoffClient(terminalKey, apiSecret, apiURL string, logger *zap.Logger) *TinkoffClient {
@@ -185,6 +215,68 @@ func (c *TinkoffClient) GetState(paymentID string) (*GetStateResponse, error) {
 	return &result, nil
 }
 
+// Cancel cancels a payment or refunds the given amount (partial refund if less than the paid amount).
+// requestID is the caller's operation ID; retries of the same operation must reuse it.
+func (c *TinkoffClient) Cancel(paymentID string, amount float64, requestID string) (*CancelResponse, error) {
+	req := &CancelRequest{
+		TerminalKey:       c.terminalKey,
+		PaymentID:         paymentID,
+		ExternalRequestID: requestID,
+		Amount:            int64(math.Round(amount * 100)),
+	}
+
+	req.Token = c.generateToken(map[string]interface{}{
+		"TerminalKey":       req.TerminalKey,
+		"PaymentId":         req.PaymentID,
+		"ExternalRequestId": req.ExternalRequestID,
+		"Amount":            req.Amount,
+	})
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/Cancel", c.apiURL)
+	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	c.logger.Debug("Tinkoff Cancel request", zap.String("url", url), zap.String("payment_id", paymentID), zap.String("request_id", requestID))
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			c.logger.Warn("failed to close response body", zap.Error(closeErr))
+		}
+	}()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return nil, fmt.Errorf("tinkoff unavailable: HTTP %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result CancelResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if !result.Success {
+		return nil, fmt.Errorf("%w: %s - %s", ErrRejected, result.ErrorCode, result.Message)
+	}
+
+	return &result, nil
+}
+
 // generateToken generates a token for signing requests according to the Tinkoff Acquiring API specification.
 //
 // SECURITY NOTE: SHA-256 is used here for generating API request signatures (HMAC-like mechanism),
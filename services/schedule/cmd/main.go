@@ -12,10 +12,16 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
 	"github.com/vokzal-tech/schedule-service/internal/config"
 	"github.com/vokzal-tech/schedule-service/internal/handlers"
 	"github.com/vokzal-tech/schedule-service/internal/middleware"
@@ -50,12 +56,12 @@ func main() {
 	logger.Info("Starting Schedule Service", zap.String("version", "1.0.0"))
 
 	// Подключиться к БД
-	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 
-	if migErr := db.AutoMigrate(&models.Station{}, &models.Route{}, &models.Schedule{}, &models.Trip{}, &models.Bus{}, &models.Driver{}); migErr != nil {
+	if migErr := db.AutoMigrate(&models.Station{}, &models.Carrier{}, &models.Route{}, &models.Schedule{}, &models.Trip{}, &models.TripVehicle{}, &models.DelayProposal{}, &models.Bus{}, &models.Driver{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
 
@@ -70,16 +76,54 @@ func main() {
 
 	logger.Info("Connected to NATS", zap.String("url", cfg.NATS.URL))
 
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "schedule",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	// Создать репозитории
 	stationRepo := repository.NewStationRepository(db)
 	routeRepo := repository.NewRouteRepository(db)
+	carrierRepo := repository.NewCarrierRepository(db)
 	scheduleRepo := repository.NewScheduleRepository(db)
 	tripRepo := repository.NewTripRepository(db)
+	vehicleRepo := repository.NewTripVehicleRepository(db)
+	proposalRepo := repository.NewDelayProposalRepository(db)
 	busRepo := repository.NewBusRepository(db)
 	driverRepo := repository.NewDriverRepository(db)
 
 	// Создать сервис
-	scheduleService := service.NewScheduleService(stationRepo, routeRepo, scheduleRepo, tripRepo, busRepo, driverRepo, natsConn, logger)
+	scheduleService := service.NewScheduleService(stationRepo, routeRepo, scheduleRepo, tripRepo, busRepo, driverRepo, carrierRepo, vehicleRepo, proposalRepo, dbtx.NewTransactor(db), outbox.New(db, "schedule"), cfg, logger)
+
+	// Durable-консьюмер: завершение посадки, опубликованное при остановленном сервисе,
+	// будет обработано после запуска — рейс не останется в статусе boarding
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "schedule",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	scheduleService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
 
 	// Создать handlers
 	scheduleHandler := handlers.NewScheduleHandler(scheduleService, logger)
@@ -89,6 +133,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	// Public: health check (no auth).
 	router.GET("/health", func(c *gin.Context) {
@@ -109,6 +154,12 @@ func main() {
 	stations.GET("/:id", scheduleHandler.GetStation)
 	stations.PATCH("/:id", scheduleHandler.UpdateStation)
 	stations.DELETE("/:id", scheduleHandler.DeleteStation)
+	carriers := v1.Group("/carriers")
+	carriers.POST("", scheduleHandler.CreateCarrier)
+	carriers.GET("", scheduleHandler.ListCarriers)
+	carriers.GET("/:id", scheduleHandler.GetCarrier)
+	carriers.PATCH("/:id", scheduleHandler.UpdateCarrier)
+	carriers.DELETE("/:id", scheduleHandler.DeactivateCarrier)
 	routes := v1.Group("/routes")
 	routes.POST("", scheduleHandler.CreateRoute)
 	routes.GET("", scheduleHandler.ListRoutes)
@@ -127,6 +178,10 @@ func main() {
 	trips.GET("/:id", scheduleHandler.GetTrip)
 	trips.PATCH("/:id/status", scheduleHandler.UpdateTripStatus)
 	trips.PATCH("/:id", scheduleHandler.UpdateTrip)
+	trips.POST("/:id/vehicles", scheduleHandler.AddTripVehicle)
+	trips.GET("/:id/vehicles", scheduleHandler.ListTripVehicles)
+	trips.PATCH("/:id/vehicles/:vehicle_id", scheduleHandler.UpdateTripVehicle)
+	trips.DELETE("/:id/vehicles/:vehicle_id", scheduleHandler.RemoveTripVehicle)
 	trips.POST("/generate", scheduleHandler.GenerateTrips)
 	buses := v1.Group("/buses")
 	buses.POST("", scheduleHandler.CreateBus)
@@ -140,6 +195,14 @@ func main() {
 	drivers.GET("/:id", scheduleHandler.GetDriver)
 	drivers.PATCH("/:id", scheduleHandler.UpdateDriver)
 	drivers.DELETE("/:id", scheduleHandler.DeleteDriver)
+	vehicleBlocks := v1.Group("/vehicle-blocks")
+	vehicleBlocks.GET("", scheduleHandler.ListVehicleBlocks)
+	vehicleBlocks.GET("/:bus_id", scheduleHandler.GetVehicleBlock)
+	delayProposals := v1.Group("/delay-proposals")
+	delayProposals.GET("", scheduleHandler.ListDelayProposals)
+	delayProposals.POST("/:id/accept", scheduleHandler.AcceptDelayProposal)
+	delayProposals.POST("/:id/dismiss", scheduleHandler.DismissDelayProposal)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "schedule"), logger).Register(v1.Group("/schedule/dead-letters"))
 
 	// Создать HTTP сервер
 	srv := &http.Server{
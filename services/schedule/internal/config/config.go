@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -17,6 +18,9 @@ type Config struct {
 	Logger   LoggerConfig   `mapstructure:"logger"`
 	Database DatabaseConfig `mapstructure:"database"`
 	JWT      JWTConfig      `mapstructure:"jwt"`
+	Outbox   OutboxConfig   `mapstructure:"outbox"`
+	Consumer ConsumerConfig `mapstructure:"consumer"`
+	Rotation RotationConfig `mapstructure:"rotation"`
 }
 
 // JWTConfig — настройки JWT для проверки токенов (тот же секрет, что в Auth Service).
@@ -52,6 +56,28 @@ type LoggerConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+// OutboxConfig — доставка событий из outbox в NATS (см. outbox.RelayConfig в go-common).
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
+// RotationConfig — оборот автобуса между рейсами блока: MinTurnaround — минимальное время от прибытия
+// до следующего отправления того же автобуса (высадка, уборка, отдых водителя).
+type RotationConfig struct {
+	MinTurnaround time.Duration `mapstructure:"min_turnaround"`
+}
+
 // Load загружает конфигурацию из файла и переменных окружения.
 func Load() (*Config, error) {
 	viper.SetConfigName("config")
@@ -76,6 +102,15 @@ func Load() (*Config, error) {
 	viper.SetDefault("nats.password", "nats_secret_2026")
 	viper.SetDefault("logger.level", "debug")
 	viper.SetDefault("jwt.secret", "vokzal_jwt_secret_change_in_production")
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
+	viper.SetDefault("rotation.min_turnaround", "15m")
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
@@ -117,6 +117,10 @@ func (h *ScheduleHandler) CreateRoute(c *gin.Context) {
 
 	route, err := h.svc.CreateRoute(c.Request.Context(), &req)
 	if err != nil {
+		if errors.Is(err, service.ErrCarrierNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "carrier_id: carrier not found"})
+			return
+		}
 		h.logger.Error("Failed to create route", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create route"})
 		return
@@ -161,6 +165,10 @@ func (h *ScheduleHandler) UpdateRoute(c *gin.Context) {
 
 	route, err := h.svc.UpdateRoute(c.Request.Context(), id, &req)
 	if err != nil {
+		if errors.Is(err, service.ErrCarrierNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "carrier_id: carrier not found"})
+			return
+		}
 		h.logger.Error("Failed to update route", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update route"})
 		return
@@ -181,6 +189,102 @@ func (h *ScheduleHandler) DeleteRoute(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Route deleted"})
 }
 
+// CreateCarrier создаёт перевозчика.
+func (h *ScheduleHandler) CreateCarrier(c *gin.Context) {
+	var req service.CreateCarrierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+	carrier, err := h.svc.CreateCarrier(c.Request.Context(), &req)
+	if err != nil {
+		if status := carrierErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to create carrier", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create carrier"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": carrier})
+}
+
+// GetCarrier возвращает перевозчика по ID.
+func (h *ScheduleHandler) GetCarrier(c *gin.Context) {
+	carrier, err := h.svc.GetCarrier(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrCarrierNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Carrier not found"})
+			return
+		}
+		h.logger.Error("Failed to get carrier", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get carrier"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": carrier})
+}
+
+// ListCarriers возвращает список перевозчиков.
+func (h *ScheduleHandler) ListCarriers(c *gin.Context) {
+	activeOnly := c.DefaultQuery("active", "false") == "true"
+	carriers, err := h.svc.ListCarriers(c.Request.Context(), activeOnly)
+	if err != nil {
+		h.logger.Error("Failed to list carriers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list carriers"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": carriers})
+}
+
+// UpdateCarrier обновляет перевозчика.
+func (h *ScheduleHandler) UpdateCarrier(c *gin.Context) {
+	var req service.UpdateCarrierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = c.GetString("user_id")
+	carrier, err := h.svc.UpdateCarrier(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		if status := carrierErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to update carrier", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update carrier"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": carrier})
+}
+
+// DeactivateCarrier отключает перевозчика (запись сохраняется для расчётов).
+func (h *ScheduleHandler) DeactivateCarrier(c *gin.Context) {
+	if err := h.svc.DeactivateCarrier(c.Request.Context(), c.Param("id"), c.GetString("user_id")); err != nil {
+		if status := carrierErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to deactivate carrier", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deactivate carrier"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Carrier deactivated"})
+}
+
+// carrierErrorStatus возвращает HTTP-статус для ошибок перевозчика или 0, если ошибка внутренняя.
+func carrierErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrCarrierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidCarrier):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrCarrierINNTaken), errors.Is(err, service.ErrCarrierHasRoutes):
+		return http.StatusConflict
+	}
+	return 0
+}
+
 // CreateSchedule создаёт расписание.
 func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
 	var req service.CreateScheduleRequest
@@ -380,6 +484,10 @@ func (h *ScheduleHandler) UpdateTrip(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
 			return
 		}
+		if errors.Is(err, service.ErrBusAlreadyOnTrip) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		h.logger.Error("Failed to update trip", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update trip"})
 		return
@@ -387,6 +495,90 @@ func (h *ScheduleHandler) UpdateTrip(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": trip})
 }
 
+// AddTripVehicle добавляет к рейсу автобус (подсадка при нехватке мест).
+func (h *ScheduleHandler) AddTripVehicle(c *gin.Context) {
+	var req service.AddTripVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	vehicle, err := h.svc.AddTripVehicle(c.Request.Context(), c.Param("id"), &req)
+	if err != nil {
+		if status := tripVehicleErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to add trip vehicle", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add trip vehicle"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": vehicle})
+}
+
+// ListTripVehicles возвращает дополнительные автобусы рейса.
+func (h *ScheduleHandler) ListTripVehicles(c *gin.Context) {
+	vehicles, err := h.svc.ListTripVehicles(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, service.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		h.logger.Error("Failed to list trip vehicles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trip vehicles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": vehicles})
+}
+
+// UpdateTripVehicle меняет автобус, водителя или перрон дополнительного автобуса рейса.
+func (h *ScheduleHandler) UpdateTripVehicle(c *gin.Context) {
+	var req service.UpdateTripVehicleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	vehicle, err := h.svc.UpdateTripVehicle(c.Request.Context(), c.Param("id"), c.Param("vehicle_id"), &req)
+	if err != nil {
+		if status := tripVehicleErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to update trip vehicle", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update trip vehicle"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": vehicle})
+}
+
+// RemoveTripVehicle снимает дополнительный автобус с рейса.
+func (h *ScheduleHandler) RemoveTripVehicle(c *gin.Context) {
+	if err := h.svc.RemoveTripVehicle(c.Request.Context(), c.Param("id"), c.Param("vehicle_id")); err != nil {
+		if status := tripVehicleErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to remove trip vehicle", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove trip vehicle"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// tripVehicleErrorStatus возвращает HTTP-статус для ошибок автобусов рейса или 0, если ошибка внутренняя.
+func tripVehicleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrTripNotFound), errors.Is(err, service.ErrTripVehicleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrBusNotFound), errors.Is(err, service.ErrDriverNotFound):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrBusAlreadyOnTrip), errors.Is(err, service.ErrVehicleHasTickets):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrTripFinished):
+		return http.StatusUnprocessableEntity
+	}
+	return 0
+}
+
 // CreateBus создаёт автобус.
 func (h *ScheduleHandler) CreateBus(c *gin.Context) {
 	var req service.CreateBusRequest
@@ -591,6 +783,112 @@ func (h *ScheduleHandler) DeleteDriver(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// ListVehicleBlocks возвращает блоки рейсов автобусов за дату (по умолчанию — сегодня).
+func (h *ScheduleHandler) ListVehicleBlocks(c *gin.Context) {
+	date, ok := dateQuery(c)
+	if !ok {
+		return
+	}
+	blocks, err := h.svc.ListVehicleBlocks(c.Request.Context(), date)
+	if err != nil {
+		h.logger.Error("Failed to list vehicle blocks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list vehicle blocks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": blocks})
+}
+
+// GetVehicleBlock возвращает блок рейсов автобуса за дату с прогнозом задержек по цепочке.
+func (h *ScheduleHandler) GetVehicleBlock(c *gin.Context) {
+	date, ok := dateQuery(c)
+	if !ok {
+		return
+	}
+	block, err := h.svc.GetVehicleBlock(c.Request.Context(), c.Param("bus_id"), date)
+	if err != nil {
+		if errors.Is(err, service.ErrBusNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bus not found"})
+			return
+		}
+		h.logger.Error("Failed to get vehicle block", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get vehicle block"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": block})
+}
+
+// ListDelayProposals возвращает предложения по задержкам за дату (status — необязательный фильтр).
+func (h *ScheduleHandler) ListDelayProposals(c *gin.Context) {
+	date, ok := dateQuery(c)
+	if !ok {
+		return
+	}
+	proposals, err := h.svc.ListDelayProposals(c.Request.Context(), date, c.Query("status"))
+	if err != nil {
+		h.logger.Error("Failed to list delay proposals", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list delay proposals"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": proposals})
+}
+
+// AcceptDelayProposal применяет предложение: перенос отправления или замену автобуса.
+func (h *ScheduleHandler) AcceptDelayProposal(c *gin.Context) {
+	proposal, err := h.svc.AcceptDelayProposal(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		if status := delayProposalErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to accept delay proposal", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept delay proposal"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": proposal})
+}
+
+// DismissDelayProposal отклоняет предложение.
+func (h *ScheduleHandler) DismissDelayProposal(c *gin.Context) {
+	proposal, err := h.svc.DismissDelayProposal(c.Request.Context(), c.Param("id"), c.GetString("user_id"))
+	if err != nil {
+		if status := delayProposalErrorStatus(err); status != 0 {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to dismiss delay proposal", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss delay proposal"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": proposal})
+}
+
+// delayProposalErrorStatus возвращает HTTP-статус для ошибок предложений по задержкам или 0, если ошибка внутренняя.
+func delayProposalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrDelayProposalNotFound), errors.Is(err, service.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrProposalResolved), errors.Is(err, service.ErrProposalOutdated),
+		errors.Is(err, service.ErrReplacementBusBusy), errors.Is(err, service.ErrBusAlreadyOnTrip):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrTripFinished):
+		return http.StatusUnprocessableEntity
+	}
+	return 0
+}
+
+// dateQuery возвращает дату из параметра date (по умолчанию — сегодня); при неверном формате отвечает 400.
+func dateQuery(c *gin.Context) (string, bool) {
+	dateStr := c.Query("date")
+	if dateStr == "" {
+		return time.Now().Format("2006-01-02"), true
+	}
+	if _, err := parseDate(dateStr); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid date format (use YYYY-MM-DD)"})
+		return "", false
+	}
+	return dateStr, true
+}
+
 // GetDashboardStats возвращает статистику рейсов за дату для дашборда.
 func (h *ScheduleHandler) GetDashboardStats(c *gin.Context) {
 	dateStr := c.Query("date")
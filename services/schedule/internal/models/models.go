@@ -62,10 +62,29 @@ func (s *Station) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
-// Route — модель маршрута.
+// Carrier — перевозчик, от имени которого вокзал продаёт билеты по агентскому договору.
+// CommissionRate — агентское вознаграждение вокзала (доля выручки, 0.1 — 10%), ServiceFee — сбор вокзала
+// с каждого проданного билета; по ним ticket-service считает расчёты с перевозчиком.
+type Carrier struct {
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	ContractNumber *string   `gorm:"type:varchar(50)" json:"contract_number,omitempty"`
+	Phone          *string   `gorm:"type:varchar(20)" json:"phone,omitempty"`
+	Email          *string   `gorm:"type:varchar(100)" json:"email,omitempty"`
+	ID             string    `gorm:"type:uuid;primary_key" json:"id"`
+	Name           string    `gorm:"type:varchar(200);not null" json:"name"`
+	LegalName      string    `gorm:"type:varchar(255)" json:"legal_name,omitempty"`
+	INN            string    `gorm:"type:varchar(12);uniqueIndex;not null" json:"inn"`
+	CommissionRate float64   `gorm:"type:decimal(5,4);not null;default:0" json:"commission_rate"`
+	ServiceFee     float64   `gorm:"type:decimal(10,2);not null;default:0" json:"service_fee"`
+	IsActive       bool      `gorm:"default:true" json:"is_active"`
+}
+
+// Route — модель маршрута. CarrierID — перевозчик, обслуживающий маршрут (для правил возврата и расчётов).
 type Route struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	CarrierID   *string   `gorm:"type:uuid;index" json:"carrier_id,omitempty"`
 	ID          string    `gorm:"type:uuid;primary_key" json:"id"`
 	Name        string    `gorm:"type:varchar(100);not null" json:"name"`
 	Stops       JSONB     `gorm:"type:jsonb;not null" json:"stops"`
@@ -87,21 +106,57 @@ type Schedule struct {
 	IsActive      bool      `gorm:"default:true" json:"is_active"`
 }
 
-// Trip — модель рейса.
+// Trip — модель рейса. BusID, DriverID и Platform — основной автобус рейса, Vehicles — автобусы,
+// добавленные к рейсу сверх основного (по номеру).
 type Trip struct {
-	UpdatedAt       time.Time  `json:"updated_at"`
-	CreatedAt       time.Time  `json:"created_at"`
-	ArrivalActual   *time.Time `json:"arrival_actual,omitempty"`
-	DriverID        *string    `gorm:"type:uuid" json:"driver_id,omitempty"`
-	Platform        *string    `gorm:"type:varchar(10)" json:"platform,omitempty"`
-	DepartureActual *time.Time `json:"departure_actual,omitempty"`
-	BusID           *string    `gorm:"type:uuid" json:"bus_id,omitempty"`
-	ID              string     `gorm:"type:uuid;primary_key" json:"id"`
-	Status          string     `gorm:"type:varchar(20);not null;default:'scheduled'" json:"status"`
-	Date            string     `gorm:"type:date;not null;index" json:"date"`
-	ScheduleID      string     `gorm:"type:uuid;not null;index" json:"schedule_id"`
-	Schedule        Schedule   `gorm:"foreignKey:ScheduleID" json:"schedule,omitempty"`
-	DelayMinutes    int        `gorm:"default:0" json:"delay_minutes"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	CreatedAt       time.Time     `json:"created_at"`
+	ArrivalActual   *time.Time    `json:"arrival_actual,omitempty"`
+	DriverID        *string       `gorm:"type:uuid" json:"driver_id,omitempty"`
+	Platform        *string       `gorm:"type:varchar(10)" json:"platform,omitempty"`
+	DepartureActual *time.Time    `json:"departure_actual,omitempty"`
+	BusID           *string       `gorm:"type:uuid" json:"bus_id,omitempty"`
+	ID              string        `gorm:"type:uuid;primary_key" json:"id"`
+	Status          string        `gorm:"type:varchar(20);not null;default:'scheduled'" json:"status"`
+	Date            string        `gorm:"type:date;not null;index" json:"date"`
+	ScheduleID      string        `gorm:"type:uuid;not null;index" json:"schedule_id"`
+	Vehicles        []TripVehicle `gorm:"foreignKey:TripID" json:"vehicles,omitempty"`
+	Schedule        Schedule      `gorm:"foreignKey:ScheduleID" json:"schedule,omitempty"`
+	DelayMinutes    int           `gorm:"default:0" json:"delay_minutes"`
+}
+
+// TripVehicle — дополнительный автобус рейса, который диспетчер ставит, когда мест в основном не хватает.
+// Основной автобус рейса имеет номер 1, дополнительные нумеруются с 2; у каждого своя схема мест,
+// водитель и перрон (Platform nil — посадка с перрона рейса).
+type TripVehicle struct {
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	DriverID  *string   `gorm:"type:uuid" json:"driver_id,omitempty"`
+	Platform  *string   `gorm:"type:varchar(10)" json:"platform,omitempty"`
+	ID        string    `gorm:"type:uuid;primary_key" json:"id"`
+	TripID    string    `gorm:"type:uuid;not null;uniqueIndex:idx_trip_vehicles_number;uniqueIndex:idx_trip_vehicles_bus" json:"trip_id"`
+	BusID     string    `gorm:"type:uuid;not null;uniqueIndex:idx_trip_vehicles_bus" json:"bus_id"`
+	Number    int       `gorm:"not null;uniqueIndex:idx_trip_vehicles_number" json:"number"`
+}
+
+// DelayProposal — предложение диспетчеру по рейсу, который опоздание предыдущего рейса того же автобуса
+// (SourceTripID) задерживает по цепочке блока. Kind delay — перенести отправление: DelayMinutes —
+// предлагаемая задержка; replace_bus — поставить на рейс свободный автобус ReplacementBusID вместо BusID,
+// DelayMinutes — задержка, которой замена позволяет избежать. Статусы: pending, accepted, dismissed.
+type DelayProposal struct {
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	ResolvedAt       *time.Time `json:"resolved_at,omitempty"`
+	ReplacementBusID *string    `gorm:"type:uuid" json:"replacement_bus_id,omitempty"`
+	ResolvedBy       *string    `gorm:"type:varchar(100)" json:"resolved_by,omitempty"`
+	ID               string     `gorm:"type:uuid;primary_key" json:"id"`
+	TripID           string     `gorm:"type:uuid;not null;index" json:"trip_id"`
+	SourceTripID     string     `gorm:"type:uuid;not null" json:"source_trip_id"`
+	BusID            string     `gorm:"type:uuid;not null;index:idx_delay_proposals_block" json:"bus_id"`
+	Date             string     `gorm:"type:date;not null;index:idx_delay_proposals_block" json:"date"`
+	Kind             string     `gorm:"type:varchar(20);not null" json:"kind"`
+	Status           string     `gorm:"type:varchar(20);not null;default:'pending'" json:"status"`
+	DelayMinutes     int        `gorm:"not null" json:"delay_minutes"`
 }
 
 // Stop — информация об остановке.
@@ -146,6 +201,11 @@ func (Route) TableName() string {
 	return "routes"
 }
 
+// TableName возвращает имя таблицы для GORM (Carrier).
+func (Carrier) TableName() string {
+	return "carriers"
+}
+
 // TableName возвращает имя таблицы для GORM (Bus).
 func (Bus) TableName() string {
 	return "buses"
@@ -156,6 +216,14 @@ func (Driver) TableName() string {
 	return "drivers"
 }
 
+// BeforeCreate генерирует UUID для Carrier.
+func (c *Carrier) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // BeforeCreate генерирует UUID для Bus.
 func (b *Bus) BeforeCreate(_ *gorm.DB) error {
 	if b.ID == "" {
@@ -182,6 +250,32 @@ func (Trip) TableName() string {
 	return "trips"
 }
 
+// TableName возвращает имя таблицы для GORM (TripVehicle).
+func (TripVehicle) TableName() string {
+	return "trip_vehicles"
+}
+
+// BeforeCreate генерирует UUID для новой записи (TripVehicle).
+func (v *TripVehicle) BeforeCreate(_ *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName возвращает имя таблицы для GORM (DelayProposal).
+func (DelayProposal) TableName() string {
+	return "delay_proposals"
+}
+
+// BeforeCreate генерирует UUID для новой записи (DelayProposal).
+func (p *DelayProposal) BeforeCreate(_ *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // BeforeCreate генерирует UUID для новой записи (Route).
 func (r *Route) BeforeCreate(tx *gorm.DB) error {
 	if r.ID == "" {
@@ -7,6 +7,8 @@ import (
 
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+
 	"github.com/vokzal-tech/schedule-service/internal/models"
 )
 
@@ -23,8 +25,25 @@ var (
 	ErrBusNotFound = errors.New("bus not found")
 	// ErrDriverNotFound возвращается, когда водитель не найден.
 	ErrDriverNotFound = errors.New("driver not found")
+	// ErrCarrierNotFound возвращается, когда перевозчик не найден.
+	ErrCarrierNotFound = errors.New("carrier not found")
+	// ErrTripVehicleNotFound возвращается, когда дополнительный автобус рейса не найден.
+	ErrTripVehicleNotFound = errors.New("trip vehicle not found")
+	// ErrDelayProposalNotFound возвращается, когда предложение по задержке не найдено.
+	ErrDelayProposalNotFound = errors.New("delay proposal not found")
 )
 
+// CarrierRepository — интерфейс репозитория перевозчиков.
+//
+//nolint:dupl // CRUD interface shape is intentionally the same across repositories
+type CarrierRepository interface {
+	Create(ctx context.Context, carrier *models.Carrier) error
+	FindByID(ctx context.Context, id string) (*models.Carrier, error)
+	FindAll(ctx context.Context, isActive *bool) ([]*models.Carrier, error)
+	Update(ctx context.Context, carrier *models.Carrier) error
+	CountRoutes(ctx context.Context, id string) (int64, error)
+}
+
 // BusRepository — интерфейс репозитория автобусов.
 type BusRepository interface {
 	Create(ctx context.Context, bus *models.Bus) error
@@ -84,6 +103,25 @@ type TripRepository interface {
 	Delete(ctx context.Context, id string) error
 }
 
+// TripVehicleRepository — интерфейс репозитория дополнительных автобусов рейсов.
+type TripVehicleRepository interface {
+	Create(ctx context.Context, vehicle *models.TripVehicle) error
+	FindByID(ctx context.Context, tripID, id string) (*models.TripVehicle, error)
+	FindByTripID(ctx context.Context, tripID string) ([]*models.TripVehicle, error)
+	Update(ctx context.Context, vehicle *models.TripVehicle) error
+	Delete(ctx context.Context, id string) error
+	CountActiveTickets(ctx context.Context, id string) (int64, error)
+}
+
+// DelayProposalRepository — интерфейс репозитория предложений по задержкам в блоках рейсов автобусов.
+type DelayProposalRepository interface {
+	Create(ctx context.Context, proposal *models.DelayProposal) error
+	FindByID(ctx context.Context, id string) (*models.DelayProposal, error)
+	FindByDate(ctx context.Context, date, status string) ([]*models.DelayProposal, error)
+	Resolve(ctx context.Context, proposal *models.DelayProposal) (bool, error)
+	DeletePending(ctx context.Context, busID, date string) error
+}
+
 type stationRepository struct {
 	db *gorm.DB
 }
@@ -100,6 +138,14 @@ type tripRepository struct {
 	db *gorm.DB
 }
 
+type tripVehicleRepository struct {
+	db *gorm.DB
+}
+
+type delayProposalRepository struct {
+	db *gorm.DB
+}
+
 type busRepository struct {
 	db *gorm.DB
 }
@@ -108,6 +154,10 @@ type driverRepository struct {
 	db *gorm.DB
 }
 
+type carrierRepository struct {
+	db *gorm.DB
+}
+
 // NewBusRepository создаёт репозиторий автобусов.
 func NewBusRepository(db *gorm.DB) BusRepository {
 	return &busRepository{db: db}
@@ -118,6 +168,11 @@ func NewDriverRepository(db *gorm.DB) DriverRepository {
 	return &driverRepository{db: db}
 }
 
+// NewCarrierRepository создаёт репозиторий перевозчиков.
+func NewCarrierRepository(db *gorm.DB) CarrierRepository {
+	return &carrierRepository{db: db}
+}
+
 // NewStationRepository создаёт репозиторий станций.
 func NewStationRepository(db *gorm.DB) StationRepository {
 	return &stationRepository{db: db}
@@ -138,15 +193,25 @@ func NewTripRepository(db *gorm.DB) TripRepository {
 	return &tripRepository{db: db}
 }
 
+// NewTripVehicleRepository создаёт репозиторий дополнительных автобусов рейсов.
+func NewTripVehicleRepository(db *gorm.DB) TripVehicleRepository {
+	return &tripVehicleRepository{db: db}
+}
+
+// NewDelayProposalRepository создаёт репозиторий предложений по задержкам.
+func NewDelayProposalRepository(db *gorm.DB) DelayProposalRepository {
+	return &delayProposalRepository{db: db}
+}
+
 // Station repository implementation.
 func (r *stationRepository) Create(ctx context.Context, station *models.Station) error {
-	return r.db.WithContext(ctx).Create(station).Error
+	return dbtx.From(ctx, r.db).Create(station).Error
 }
 
 //nolint:dupl // FindByID pattern is the same across repositories; only model and error differ
 func (r *stationRepository) FindByID(ctx context.Context, id string) (*models.Station, error) {
 	var station models.Station
-	if err := r.db.WithContext(ctx).First(&station, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&station, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrStationNotFound
 		}
@@ -157,7 +222,7 @@ func (r *stationRepository) FindByID(ctx context.Context, id string) (*models.St
 
 func (r *stationRepository) FindAll(ctx context.Context, city string, _ *bool) ([]*models.Station, error) {
 	var stations []*models.Station
-	query := r.db.WithContext(ctx)
+	query := dbtx.From(ctx, r.db)
 	if city != "" {
 		query = query.Where("name ILIKE ? OR address ILIKE ?", "%"+city+"%", "%"+city+"%")
 	}
@@ -168,11 +233,11 @@ func (r *stationRepository) FindAll(ctx context.Context, city string, _ *bool) (
 }
 
 func (r *stationRepository) Update(ctx context.Context, station *models.Station) error {
-	return r.db.WithContext(ctx).Save(station).Error
+	return dbtx.From(ctx, r.db).Save(station).Error
 }
 
 func (r *stationRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Station{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Station{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -184,13 +249,13 @@ func (r *stationRepository) Delete(ctx context.Context, id string) error {
 
 // Create создаёт маршрут.
 func (r *routeRepository) Create(ctx context.Context, route *models.Route) error {
-	return r.db.WithContext(ctx).Create(route).Error
+	return dbtx.From(ctx, r.db).Create(route).Error
 }
 
 //nolint:dupl // FindByID pattern is the same across repositories; only model and error differ
 func (r *routeRepository) FindByID(ctx context.Context, id string) (*models.Route, error) {
 	var route models.Route
-	if err := r.db.WithContext(ctx).First(&route, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&route, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrRouteNotFound
 		}
@@ -201,7 +266,7 @@ func (r *routeRepository) FindByID(ctx context.Context, id string) (*models.Rout
 
 func (r *routeRepository) FindAll(ctx context.Context, isActive *bool) ([]*models.Route, error) {
 	var routes []*models.Route
-	query := r.db.WithContext(ctx)
+	query := dbtx.From(ctx, r.db)
 	if isActive != nil {
 		query = query.Where("is_active = ?", *isActive)
 	}
@@ -212,11 +277,11 @@ func (r *routeRepository) FindAll(ctx context.Context, isActive *bool) ([]*model
 }
 
 func (r *routeRepository) Update(ctx context.Context, route *models.Route) error {
-	return r.db.WithContext(ctx).Save(route).Error
+	return dbtx.From(ctx, r.db).Save(route).Error
 }
 
 func (r *routeRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Route{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Route{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -228,13 +293,13 @@ func (r *routeRepository) Delete(ctx context.Context, id string) error {
 
 // Create создаёт расписание.
 func (r *scheduleRepository) Create(ctx context.Context, schedule *models.Schedule) error {
-	return r.db.WithContext(ctx).Create(schedule).Error
+	return dbtx.From(ctx, r.db).Create(schedule).Error
 }
 
 //nolint:dupl // FindByID with Preload is the same for Schedule and Trip; only model and error differ
 func (r *scheduleRepository) FindByID(ctx context.Context, id string) (*models.Schedule, error) {
 	var schedule models.Schedule
-	if err := r.db.WithContext(ctx).Preload("Route").First(&schedule, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Preload("Route").First(&schedule, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrScheduleNotFound
 		}
@@ -245,18 +310,18 @@ func (r *scheduleRepository) FindByID(ctx context.Context, id string) (*models.S
 
 func (r *scheduleRepository) FindByRouteID(ctx context.Context, routeID string) ([]*models.Schedule, error) {
 	var schedules []*models.Schedule
-	if err := r.db.WithContext(ctx).Preload("Route").Where("route_id = ? AND is_active = ?", routeID, true).Find(&schedules).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Preload("Route").Where("route_id = ? AND is_active = ?", routeID, true).Find(&schedules).Error; err != nil {
 		return nil, err
 	}
 	return schedules, nil
 }
 
 func (r *scheduleRepository) Update(ctx context.Context, schedule *models.Schedule) error {
-	return r.db.WithContext(ctx).Save(schedule).Error
+	return dbtx.From(ctx, r.db).Save(schedule).Error
 }
 
 func (r *scheduleRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Schedule{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Schedule{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -268,13 +333,13 @@ func (r *scheduleRepository) Delete(ctx context.Context, id string) error {
 
 // Create создаёт рейс.
 func (r *tripRepository) Create(ctx context.Context, trip *models.Trip) error {
-	return r.db.WithContext(ctx).Create(trip).Error
+	return dbtx.From(ctx, r.db).Create(trip).Error
 }
 
 //nolint:dupl // FindByID with Preload is the same for Schedule and Trip; only model and error differ
 func (r *tripRepository) FindByID(ctx context.Context, id string) (*models.Trip, error) {
 	var trip models.Trip
-	if err := r.db.WithContext(ctx).Preload("Schedule.Route").First(&trip, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Preload("Schedule.Route").Preload("Vehicles", orderByNumber).First(&trip, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrTripNotFound
 		}
@@ -285,7 +350,8 @@ func (r *tripRepository) FindByID(ctx context.Context, id string) (*models.Trip,
 
 func (r *tripRepository) FindByDate(ctx context.Context, date string) ([]*models.Trip, error) {
 	var trips []*models.Trip
-	if err := r.db.WithContext(ctx).Preload("Schedule.Route").Where("date = ?", date).Order("date ASC").Find(&trips).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Preload("Schedule.Route").Preload("Vehicles", orderByNumber).
+		Where("date = ?", date).Order("date ASC").Find(&trips).Error; err != nil {
 		return nil, err
 	}
 	return trips, nil
@@ -293,7 +359,7 @@ func (r *tripRepository) FindByDate(ctx context.Context, date string) ([]*models
 
 func (r *tripRepository) FindByScheduleAndDate(ctx context.Context, scheduleID, date string) (*models.Trip, error) {
 	var trip models.Trip
-	if err := r.db.WithContext(ctx).Where("schedule_id = ? AND date = ?", scheduleID, date).First(&trip).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("schedule_id = ? AND date = ?", scheduleID, date).First(&trip).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -303,11 +369,11 @@ func (r *tripRepository) FindByScheduleAndDate(ctx context.Context, scheduleID,
 }
 
 func (r *tripRepository) Update(ctx context.Context, trip *models.Trip) error {
-	return r.db.WithContext(ctx).Save(trip).Error
+	return dbtx.From(ctx, r.db).Save(trip).Error
 }
 
 func (r *tripRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Trip{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Trip{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -317,15 +383,112 @@ func (r *tripRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+// orderByNumber упорядочивает дополнительные автобусы рейса по номеру.
+func orderByNumber(db *gorm.DB) *gorm.DB {
+	return db.Order("number ASC")
+}
+
+// Trip vehicle repository implementation.
+func (r *tripVehicleRepository) Create(ctx context.Context, vehicle *models.TripVehicle) error {
+	return dbtx.From(ctx, r.db).Create(vehicle).Error
+}
+
+func (r *tripVehicleRepository) FindByID(ctx context.Context, tripID, id string) (*models.TripVehicle, error) {
+	var vehicle models.TripVehicle
+	if err := dbtx.From(ctx, r.db).First(&vehicle, "id = ? AND trip_id = ?", id, tripID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTripVehicleNotFound
+		}
+		return nil, err
+	}
+	return &vehicle, nil
+}
+
+func (r *tripVehicleRepository) FindByTripID(ctx context.Context, tripID string) ([]*models.TripVehicle, error) {
+	var vehicles []*models.TripVehicle
+	if err := dbtx.From(ctx, r.db).Where("trip_id = ?", tripID).Order("number ASC").Find(&vehicles).Error; err != nil {
+		return nil, err
+	}
+	return vehicles, nil
+}
+
+func (r *tripVehicleRepository) Update(ctx context.Context, vehicle *models.TripVehicle) error {
+	return dbtx.From(ctx, r.db).Save(vehicle).Error
+}
+
+func (r *tripVehicleRepository) Delete(ctx context.Context, id string) error {
+	result := dbtx.From(ctx, r.db).Delete(&models.TripVehicle{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTripVehicleNotFound
+	}
+	return nil
+}
+
+// CountActiveTickets возвращает число действующих билетов, проданных на автобус
+// (таблица tickets ticket-service в общей БД).
+func (r *tripVehicleRepository) CountActiveTickets(ctx context.Context, id string) (int64, error) {
+	var count int64
+	err := dbtx.From(ctx, r.db).Raw(
+		"SELECT COUNT(*) FROM tickets WHERE vehicle_id = ? AND status = 'active'", id,
+	).Scan(&count).Error
+	return count, err
+}
+
+// Delay proposal repository implementation.
+func (r *delayProposalRepository) Create(ctx context.Context, proposal *models.DelayProposal) error {
+	return dbtx.From(ctx, r.db).Create(proposal).Error
+}
+
+func (r *delayProposalRepository) FindByID(ctx context.Context, id string) (*models.DelayProposal, error) {
+	var proposal models.DelayProposal
+	if err := dbtx.From(ctx, r.db).First(&proposal, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDelayProposalNotFound
+		}
+		return nil, err
+	}
+	return &proposal, nil
+}
+
+// FindByDate возвращает предложения за дату рейсов; пустой status — во всех статусах.
+func (r *delayProposalRepository) FindByDate(ctx context.Context, date, status string) ([]*models.DelayProposal, error) {
+	var proposals []*models.DelayProposal
+	query := dbtx.From(ctx, r.db).Where("date = ?", date)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at ASC").Find(&proposals).Error; err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// Resolve сохраняет решение по предложению, только если в БД оно всё ещё нерассмотренное.
+// Возвращает false, если предложение уже принял или отклонил другой запрос либо удалил пересчёт.
+func (r *delayProposalRepository) Resolve(ctx context.Context, proposal *models.DelayProposal) (bool, error) {
+	result := dbtx.From(ctx, r.db).Model(proposal).Where("status = 'pending'").Select("*").Updates(proposal)
+	return result.RowsAffected == 1, result.Error
+}
+
+// DeletePending удаляет нерассмотренные предложения по блоку автобуса за дату (перед пересчётом).
+func (r *delayProposalRepository) DeletePending(ctx context.Context, busID, date string) error {
+	return dbtx.From(ctx, r.db).
+		Where("bus_id = ? AND date = ? AND status = 'pending'", busID, date).
+		Delete(&models.DelayProposal{}).Error
+}
+
 // Bus repository implementation.
 func (r *busRepository) Create(ctx context.Context, bus *models.Bus) error {
-	return r.db.WithContext(ctx).Create(bus).Error
+	return dbtx.From(ctx, r.db).Create(bus).Error
 }
 
 //nolint:dupl // FindByID pattern is the same across repositories; only model and error differ
 func (r *busRepository) FindByID(ctx context.Context, id string) (*models.Bus, error) {
 	var bus models.Bus
-	if err := r.db.WithContext(ctx).First(&bus, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&bus, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrBusNotFound
 		}
@@ -336,7 +499,7 @@ func (r *busRepository) FindByID(ctx context.Context, id string) (*models.Bus, e
 
 func (r *busRepository) FindAll(ctx context.Context, stationID, status *string) ([]*models.Bus, error) {
 	var buses []*models.Bus
-	query := r.db.WithContext(ctx)
+	query := dbtx.From(ctx, r.db)
 	if stationID != nil && *stationID != "" {
 		query = query.Where("station_id = ?", *stationID)
 	}
@@ -350,11 +513,11 @@ func (r *busRepository) FindAll(ctx context.Context, stationID, status *string)
 }
 
 func (r *busRepository) Update(ctx context.Context, bus *models.Bus) error {
-	return r.db.WithContext(ctx).Save(bus).Error
+	return dbtx.From(ctx, r.db).Save(bus).Error
 }
 
 func (r *busRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Bus{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Bus{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -366,13 +529,13 @@ func (r *busRepository) Delete(ctx context.Context, id string) error {
 
 // Driver repository implementation.
 func (r *driverRepository) Create(ctx context.Context, driver *models.Driver) error {
-	return r.db.WithContext(ctx).Create(driver).Error
+	return dbtx.From(ctx, r.db).Create(driver).Error
 }
 
 //nolint:dupl // FindByID pattern is the same across repositories; only model and error differ
 func (r *driverRepository) FindByID(ctx context.Context, id string) (*models.Driver, error) {
 	var driver models.Driver
-	if err := r.db.WithContext(ctx).First(&driver, "id = ?", id).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&driver, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, ErrDriverNotFound
 		}
@@ -383,7 +546,7 @@ func (r *driverRepository) FindByID(ctx context.Context, id string) (*models.Dri
 
 func (r *driverRepository) FindAll(ctx context.Context, stationID *string) ([]*models.Driver, error) {
 	var drivers []*models.Driver
-	query := r.db.WithContext(ctx)
+	query := dbtx.From(ctx, r.db)
 	if stationID != nil && *stationID != "" {
 		query = query.Where("station_id = ?", *stationID)
 	}
@@ -394,11 +557,11 @@ func (r *driverRepository) FindAll(ctx context.Context, stationID *string) ([]*m
 }
 
 func (r *driverRepository) Update(ctx context.Context, driver *models.Driver) error {
-	return r.db.WithContext(ctx).Save(driver).Error
+	return dbtx.From(ctx, r.db).Save(driver).Error
 }
 
 func (r *driverRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Driver{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Driver{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -407,3 +570,43 @@ func (r *driverRepository) Delete(ctx context.Context, id string) error {
 	}
 	return nil
 }
+
+// Carrier repository implementation.
+func (r *carrierRepository) Create(ctx context.Context, carrier *models.Carrier) error {
+	return dbtx.From(ctx, r.db).Create(carrier).Error
+}
+
+//nolint:dupl // FindByID pattern is the same across repositories; only model and error differ
+func (r *carrierRepository) FindByID(ctx context.Context, id string) (*models.Carrier, error) {
+	var carrier models.Carrier
+	if err := dbtx.From(ctx, r.db).First(&carrier, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCarrierNotFound
+		}
+		return nil, err
+	}
+	return &carrier, nil
+}
+
+func (r *carrierRepository) FindAll(ctx context.Context, isActive *bool) ([]*models.Carrier, error) {
+	var carriers []*models.Carrier
+	query := dbtx.From(ctx, r.db)
+	if isActive != nil {
+		query = query.Where("is_active = ?", *isActive)
+	}
+	if err := query.Order("name ASC").Find(&carriers).Error; err != nil {
+		return nil, err
+	}
+	return carriers, nil
+}
+
+func (r *carrierRepository) Update(ctx context.Context, carrier *models.Carrier) error {
+	return dbtx.From(ctx, r.db).Save(carrier).Error
+}
+
+// CountRoutes возвращает число активных маршрутов перевозчика.
+func (r *carrierRepository) CountRoutes(ctx context.Context, id string) (int64, error) {
+	var count int64
+	err := dbtx.From(ctx, r.db).Model(&models.Route{}).Where("carrier_id = ? AND is_active = ?", id, true).Count(&count).Error
+	return count, err
+}
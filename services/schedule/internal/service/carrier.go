@@ -0,0 +1,250 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/schedule-service/internal/models"
+	"github.com/vokzal-tech/schedule-service/internal/repository"
+)
+
+var (
+	// ErrCarrierNotFound возвращается, когда перевозчик не найден или carrier_id маршрута не ссылается
+	// на действующего перевозчика.
+	ErrCarrierNotFound = errors.New("carrier not found")
+	// ErrInvalidCarrier возвращается при неверной ставке вознаграждения, сборе или ИНН перевозчика.
+	ErrInvalidCarrier = errors.New("invalid carrier parameters")
+	// ErrCarrierINNTaken возвращается, когда перевозчик с таким ИНН уже есть.
+	ErrCarrierINNTaken = errors.New("carrier with this INN already exists")
+	// ErrCarrierHasRoutes возвращается при отключении перевозчика, за которым есть активные маршруты.
+	ErrCarrierHasRoutes = errors.New("carrier has active routes")
+)
+
+// CreateCarrierRequest — запрос на создание перевозчика. CommissionRate — доля выручки (0.1 — 10%).
+type CreateCarrierRequest struct {
+	ContractNumber *string `json:"contract_number"`
+	Phone          *string `json:"phone"`
+	Email          *string `json:"email" binding:"omitempty,email"`
+	Name           string  `json:"name" binding:"required,max=200"`
+	LegalName      string  `json:"legal_name" binding:"max=255"`
+	INN            string  `json:"inn" binding:"required"`
+	UserID         string  `json:"-"`
+	CommissionRate float64 `json:"commission_rate"`
+	ServiceFee     float64 `json:"service_fee"`
+}
+
+// UpdateCarrierRequest — запрос на обновление перевозчика.
+type UpdateCarrierRequest struct {
+	ContractNumber *string  `json:"contract_number"`
+	Phone          *string  `json:"phone"`
+	Email          *string  `json:"email" binding:"omitempty,email"`
+	Name           *string  `json:"name" binding:"omitempty,max=200"`
+	LegalName      *string  `json:"legal_name" binding:"omitempty,max=255"`
+	INN            *string  `json:"inn"`
+	CommissionRate *float64 `json:"commission_rate"`
+	ServiceFee     *float64 `json:"service_fee"`
+	IsActive       *bool    `json:"is_active"`
+	UserID         string   `json:"-"`
+}
+
+// CreateCarrier создаёт перевозчика.
+func (s *scheduleService) CreateCarrier(ctx context.Context, req *CreateCarrierRequest) (*models.Carrier, error) {
+	carrier := &models.Carrier{
+		ContractNumber: req.ContractNumber,
+		Phone:          req.Phone,
+		Email:          req.Email,
+		Name:           strings.TrimSpace(req.Name),
+		LegalName:      strings.TrimSpace(req.LegalName),
+		INN:            strings.TrimSpace(req.INN),
+		CommissionRate: req.CommissionRate,
+		ServiceFee:     req.ServiceFee,
+		IsActive:       true,
+	}
+	if err := validateCarrier(carrier); err != nil {
+		return nil, err
+	}
+
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.carrierRepo.Create(ctx, carrier); dbErr != nil {
+			if errors.Is(dbErr, gorm.ErrDuplicatedKey) {
+				return ErrCarrierINNTaken
+			}
+			return dbErr
+		}
+		return s.publishCarrierAudit(ctx, carrier.ID, "create", req.UserID, nil, carrier)
+	})
+	if err != nil {
+		if errors.Is(err, ErrCarrierINNTaken) {
+			return nil, err
+		}
+		s.logger.Error("CreateCarrier failed", zap.String("inn", carrier.INN), zap.Error(err))
+		return nil, fmt.Errorf("create carrier: %w", err)
+	}
+	s.logger.Info("Carrier created", zap.String("carrier_id", carrier.ID), zap.String("name", carrier.Name))
+	return carrier, nil
+}
+
+func (s *scheduleService) GetCarrier(ctx context.Context, id string) (*models.Carrier, error) {
+	carrier, err := s.carrierRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrCarrierNotFound) {
+			return nil, ErrCarrierNotFound
+		}
+		return nil, fmt.Errorf("find carrier: %w", err)
+	}
+	return carrier, nil
+}
+
+func (s *scheduleService) ListCarriers(ctx context.Context, activeOnly bool) ([]*models.Carrier, error) {
+	var isActive *bool
+	if activeOnly {
+		val := true
+		isActive = &val
+	}
+	return s.carrierRepo.FindAll(ctx, isActive)
+}
+
+// UpdateCarrier обновляет перевозчика. Новые ставки действуют для расчётов, сформированных после изменения;
+// закрытые расчёты хранят ставки на момент формирования.
+func (s *scheduleService) UpdateCarrier(ctx context.Context, id string, req *UpdateCarrierRequest) (*models.Carrier, error) {
+	carrier, err := s.GetCarrier(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	old := *carrier
+
+	if req.ContractNumber != nil {
+		carrier.ContractNumber = req.ContractNumber
+	}
+	if req.Phone != nil {
+		carrier.Phone = req.Phone
+	}
+	if req.Email != nil {
+		carrier.Email = req.Email
+	}
+	if req.Name != nil {
+		carrier.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.LegalName != nil {
+		carrier.LegalName = strings.TrimSpace(*req.LegalName)
+	}
+	if req.INN != nil {
+		carrier.INN = strings.TrimSpace(*req.INN)
+	}
+	if req.CommissionRate != nil {
+		carrier.CommissionRate = *req.CommissionRate
+	}
+	if req.ServiceFee != nil {
+		carrier.ServiceFee = *req.ServiceFee
+	}
+	if req.IsActive != nil {
+		carrier.IsActive = *req.IsActive
+	}
+	if err = validateCarrier(carrier); err != nil {
+		return nil, err
+	}
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if old.IsActive && !carrier.IsActive {
+			if routesErr := s.ensureNoActiveRoutes(ctx, id); routesErr != nil {
+				return routesErr
+			}
+		}
+		if dbErr := s.carrierRepo.Update(ctx, carrier); dbErr != nil {
+			if errors.Is(dbErr, gorm.ErrDuplicatedKey) {
+				return ErrCarrierINNTaken
+			}
+			return dbErr
+		}
+		return s.publishCarrierAudit(ctx, id, "update", req.UserID, &old, carrier)
+	})
+	if err != nil {
+		if errors.Is(err, ErrCarrierINNTaken) || errors.Is(err, ErrCarrierHasRoutes) {
+			return nil, err
+		}
+		s.logger.Error("UpdateCarrier failed", zap.String("carrier_id", id), zap.Error(err))
+		return nil, fmt.Errorf("update carrier: %w", err)
+	}
+	s.logger.Info("Carrier updated", zap.String("carrier_id", id),
+		zap.Float64("commission_rate", carrier.CommissionRate), zap.Float64("service_fee", carrier.ServiceFee))
+	return carrier, nil
+}
+
+// DeactivateCarrier отключает перевозчика. Перевозчик не удаляется: на него ссылаются расчёты
+// и проданные билеты; отключить можно только перевозчика без активных маршрутов.
+func (s *scheduleService) DeactivateCarrier(ctx context.Context, id, userID string) error {
+	active := false
+	_, err := s.UpdateCarrier(ctx, id, &UpdateCarrierRequest{IsActive: &active, UserID: userID})
+	return err
+}
+
+// ensureNoActiveRoutes возвращает ErrCarrierHasRoutes, если за перевозчиком есть активные маршруты.
+func (s *scheduleService) ensureNoActiveRoutes(ctx context.Context, carrierID string) error {
+	routes, err := s.carrierRepo.CountRoutes(ctx, carrierID)
+	if err != nil {
+		return err
+	}
+	if routes > 0 {
+		return ErrCarrierHasRoutes
+	}
+	return nil
+}
+
+// resolveRouteCarrier проверяет carrier_id маршрута: пустая строка снимает привязку (nil),
+// иначе перевозчик должен существовать и быть активным.
+func (s *scheduleService) resolveRouteCarrier(ctx context.Context, carrierID *string) (*string, error) {
+	if carrierID == nil || strings.TrimSpace(*carrierID) == "" {
+		return nil, nil
+	}
+	carrier, err := s.carrierRepo.FindByID(ctx, *carrierID)
+	if err != nil {
+		if errors.Is(err, repository.ErrCarrierNotFound) {
+			return nil, ErrCarrierNotFound
+		}
+		return nil, fmt.Errorf("find carrier: %w", err)
+	}
+	if !carrier.IsActive {
+		return nil, ErrCarrierNotFound
+	}
+	return &carrier.ID, nil
+}
+
+// publishCarrierAudit записывает изменение перевозчика в журнал аудита: ставки влияют на расчёты.
+func (s *scheduleService) publishCarrierAudit(ctx context.Context, id, action, userID string, oldValue, newValue interface{}) error {
+	if userID == "" {
+		userID = "system"
+	}
+	return s.events.Publish(ctx, "carrier", id, events.AuditLog{
+		Timestamp:  time.Now(),
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		EntityType: "carrier",
+		EntityID:   id,
+		Action:     action,
+		UserID:     userID,
+	})
+}
+
+// validateCarrier проверяет ставку вознаграждения (от 0 до 1), сбор (не меньше 0) и ИНН (10 или 12 цифр).
+func validateCarrier(carrier *models.Carrier) error {
+	if carrier.Name == "" || carrier.CommissionRate < 0 || carrier.CommissionRate >= 1 || carrier.ServiceFee < 0 {
+		return ErrInvalidCarrier
+	}
+	if len(carrier.INN) != 10 && len(carrier.INN) != 12 {
+		return ErrInvalidCarrier
+	}
+	for _, r := range carrier.INN {
+		if r < '0' || r > '9' {
+			return ErrInvalidCarrier
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+)
+
+// HandleBoardingClosed переводит рейс в статус departed по событию завершения посадки (ticket-service).
+// Рейсы, уже отправленные, прибывшие или отменённые, не изменяются.
+func (s *scheduleService) HandleBoardingClosed(ctx context.Context, event *events.BoardingClosed) error {
+	tripID := event.TripID
+	if tripID == "" {
+		return eventbus.Permanent(fmt.Errorf("invalid boarding.closed data: no trip_id"))
+	}
+
+	trip, err := s.tripRepo.FindByID(ctx, tripID)
+	if err != nil {
+		return fmt.Errorf("find trip: %w", err)
+	}
+	switch trip.Status {
+	case "departed", "arrived", "cancelled": //nolint:misspell // trip status; British spelling intentional
+		s.logger.Debug("Trip already finished boarding stage", zap.String("trip_id", tripID), zap.String("status", trip.Status))
+		return nil
+	}
+
+	_, err = s.UpdateTripStatus(ctx, tripID, "departed", trip.DelayMinutes)
+	return err
+}
+
+// RegisterEventHandlers регистрирует в durable-консьюмере обработчик завершения посадки:
+// событие, опубликованное при остановленном сервисе, переведёт рейс в departed после запуска.
+func (s *scheduleService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypeBoardingClosed, events.Handler(s.HandleBoardingClosed))
+}
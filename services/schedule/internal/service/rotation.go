@@ -0,0 +1,564 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/schedule-service/internal/models"
+	"github.com/vokzal-tech/schedule-service/internal/repository"
+)
+
+// Виды и статусы предложений по задержкам (models.DelayProposal).
+const (
+	ProposalKindDelay      = "delay"
+	ProposalKindReplaceBus = "replace_bus"
+
+	ProposalPending   = "pending"
+	ProposalAccepted  = "accepted"
+	ProposalDismissed = "dismissed"
+)
+
+var (
+	// ErrDelayProposalNotFound возвращается, когда предложение по задержке не найдено.
+	ErrDelayProposalNotFound = errors.New("delay proposal not found")
+	// ErrProposalResolved возвращается при повторном принятии или отклонении предложения.
+	ErrProposalResolved = errors.New("delay proposal has already been resolved")
+	// ErrProposalOutdated возвращается, когда автобус блока уже снят с рейса и замена не применима.
+	ErrProposalOutdated = errors.New("delay proposal no longer matches the trip")
+	// ErrReplacementBusBusy возвращается, когда автобус на замену успели поставить на пересекающийся рейс.
+	ErrReplacementBusBusy = errors.New("replacement bus is no longer free")
+)
+
+// BlockTrip — рейс в блоке автобуса с прогнозом по цепочке. DelayMinutes — задержка, объявленная
+// диспетчером; PredictedDelayMinutes — ожидаемая с учётом опоздания автобуса с предыдущего рейса;
+// KnockOnMinutes — их разница (больше нуля — рейс задержится по цепочке). VehicleNumber — номер
+// автобуса на рейсе (1 — основной).
+type BlockTrip struct {
+	PlannedDeparture      time.Time `json:"planned_departure"`
+	ExpectedDeparture     time.Time `json:"expected_departure"`
+	ExpectedArrival       time.Time `json:"expected_arrival"`
+	trip                  *models.Trip
+	TripID                string `json:"trip_id"`
+	RouteName             string `json:"route_name"`
+	Status                string `json:"status"`
+	VehicleNumber         int    `json:"vehicle_number"`
+	DelayMinutes          int    `json:"delay_minutes"`
+	PredictedDelayMinutes int    `json:"predicted_delay_minutes"`
+	KnockOnMinutes        int    `json:"knock_on_minutes"`
+}
+
+// VehicleBlock — блок рейсов автобуса за дату: рейсы, на которых он работает основным или
+// дополнительным автобусом, в порядке планового отправления. KnockOnTrips — сколько рейсов
+// блока задержится по цепочке сверх объявленной задержки.
+type VehicleBlock struct {
+	BusID        string      `json:"bus_id"`
+	PlateNumber  string      `json:"plate_number,omitempty"`
+	Date         string      `json:"date"`
+	Trips        []BlockTrip `json:"trips"`
+	Capacity     int         `json:"capacity"`
+	KnockOnTrips int         `json:"knock_on_trips"`
+}
+
+// ListVehicleBlocks возвращает блоки рейсов автобусов за дату в порядке первого отправления.
+func (s *scheduleService) ListVehicleBlocks(ctx context.Context, date string) ([]*VehicleBlock, error) {
+	blocks, _, err := s.vehicleBlocks(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+// GetVehicleBlock возвращает блок рейсов автобуса за дату (без рейсов, если автобус в этот день не работает).
+func (s *scheduleService) GetVehicleBlock(ctx context.Context, busID, date string) (*VehicleBlock, error) {
+	bus, err := s.busRepo.FindByID(ctx, busID)
+	if err != nil {
+		if errors.Is(err, repository.ErrBusNotFound) {
+			return nil, ErrBusNotFound
+		}
+		return nil, fmt.Errorf("find bus: %w", err)
+	}
+	blocks, _, err := s.vehicleBlocks(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	if block := findBlock(blocks, busID); block != nil {
+		return block, nil
+	}
+	return &VehicleBlock{
+		BusID:       bus.ID,
+		PlateNumber: bus.PlateNumber,
+		Date:        date,
+		Trips:       []BlockTrip{},
+		Capacity:    bus.Capacity,
+	}, nil
+}
+
+// ListDelayProposals возвращает предложения по задержкам за дату; пустой status — во всех статусах.
+func (s *scheduleService) ListDelayProposals(ctx context.Context, date, status string) ([]*models.DelayProposal, error) {
+	proposals, err := s.proposalRepo.FindByDate(ctx, date, status)
+	if err != nil {
+		return nil, fmt.Errorf("list delay proposals: %w", err)
+	}
+	return proposals, nil
+}
+
+// AcceptDelayProposal применяет предложение: переносит отправление рейса на предложенную задержку
+// или ставит на рейс автобус на замену. Остальные предложения по блоку пересчитываются.
+func (s *scheduleService) AcceptDelayProposal(ctx context.Context, id, userID string) (*models.DelayProposal, error) {
+	var proposal *models.DelayProposal
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		p, dbErr := s.pendingProposal(ctx, id)
+		if dbErr != nil {
+			return dbErr
+		}
+		trip, dbErr := s.findOpenTrip(ctx, p.TripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		// Принятое предложение сохраняется до пересчёта, который удаляет нерассмотренные. Параллельное
+		// принятие или отклонение того же предложения проигрывает на условном обновлении и откатывается
+		resolveProposal(p, ProposalAccepted, userID)
+		if dbErr = s.resolvePending(ctx, p); dbErr != nil {
+			return dbErr
+		}
+		proposal = p
+
+		if p.Kind == ProposalKindReplaceBus {
+			return s.replaceBlockBus(ctx, trip, p)
+		}
+		status := trip.Status
+		if status == "scheduled" {
+			status = "delayed"
+		}
+		_, dbErr = s.UpdateTripStatus(ctx, trip.ID, status, p.DelayMinutes)
+		return dbErr
+	})
+	if err != nil {
+		if isProposalError(err) {
+			return nil, err
+		}
+		s.logger.Error("AcceptDelayProposal failed", zap.String("proposal_id", id), zap.Error(err))
+		return nil, fmt.Errorf("accept delay proposal: %w", err)
+	}
+	s.logger.Info("Delay proposal accepted",
+		zap.String("proposal_id", proposal.ID),
+		zap.String("trip_id", proposal.TripID),
+		zap.String("kind", proposal.Kind))
+	return proposal, nil
+}
+
+// DismissDelayProposal отклоняет предложение. Такое же предложение при пересчёте больше не создаётся.
+func (s *scheduleService) DismissDelayProposal(ctx context.Context, id, userID string) (*models.DelayProposal, error) {
+	p, err := s.pendingProposal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resolveProposal(p, ProposalDismissed, userID)
+	if err = s.resolvePending(ctx, p); err != nil {
+		if errors.Is(err, ErrProposalResolved) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("dismiss delay proposal: %w", err)
+	}
+	return p, nil
+}
+
+// resolvePending сохраняет решение по предложению, если его ещё не принял или не отклонил другой запрос.
+func (s *scheduleService) resolvePending(ctx context.Context, p *models.DelayProposal) error {
+	resolved, err := s.proposalRepo.Resolve(ctx, p)
+	if err != nil {
+		return err
+	}
+	if !resolved {
+		return ErrProposalResolved
+	}
+	return nil
+}
+
+// pendingProposal возвращает нерассмотренное предложение.
+func (s *scheduleService) pendingProposal(ctx context.Context, id string) (*models.DelayProposal, error) {
+	p, err := s.proposalRepo.FindByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, repository.ErrDelayProposalNotFound) {
+			return nil, ErrDelayProposalNotFound
+		}
+		return nil, fmt.Errorf("find delay proposal: %w", err)
+	}
+	if p.Status != ProposalPending {
+		return nil, ErrProposalResolved
+	}
+	return p, nil
+}
+
+// replaceBlockBus ставит на рейс автобус на замену вместо автобуса блока (основного или дополнительного),
+// если тот всё ещё свободен на время рейса.
+func (s *scheduleService) replaceBlockBus(ctx context.Context, trip *models.Trip, p *models.DelayProposal) error {
+	newBusID := *p.ReplacementBusID
+	vehicles := make([]*models.TripVehicle, 0, len(trip.Vehicles))
+	for i := range trip.Vehicles {
+		vehicles = append(vehicles, &trip.Vehicles[i])
+	}
+	if busOnTrip(trip, vehicles, newBusID, "") {
+		return ErrBusAlreadyOnTrip
+	}
+
+	blocks, _, err := s.vehicleBlocks(ctx, p.Date)
+	if err != nil {
+		return err
+	}
+	bt := findBlockTrip(findBlock(blocks, p.BusID), trip.ID)
+	if bt == nil {
+		return ErrProposalOutdated
+	}
+	if !s.busFree(blocks, newBusID, bt) {
+		return ErrReplacementBusBusy
+	}
+
+	if trip.BusID != nil && *trip.BusID == p.BusID {
+		trip.BusID = &newBusID
+		if err = s.tripRepo.Update(ctx, trip); err != nil {
+			return fmt.Errorf("update trip: %w", err)
+		}
+		if err = s.publishTripEvent(ctx, trip.ID, events.TripUpdated{Trip: tripEvent(trip)}); err != nil {
+			return err
+		}
+	} else {
+		var vehicle *models.TripVehicle
+		for _, v := range vehicles {
+			if v.BusID == p.BusID {
+				vehicle = v
+			}
+		}
+		if vehicle == nil {
+			return ErrProposalOutdated
+		}
+		vehicle.BusID = newBusID
+		if err = s.vehicleRepo.Update(ctx, vehicle); err != nil {
+			return fmt.Errorf("update trip vehicle: %w", err)
+		}
+		if err = s.publishTripVehicles(ctx, trip, vehicles); err != nil {
+			return err
+		}
+	}
+	return s.refreshDelayProposals(ctx, p.Date, p.BusID, newBusID)
+}
+
+// refreshDelayProposals пересчитывает нерассмотренные предложения по блокам автобусов за дату: для рейса,
+// который задержится по цепочке, предлагается перенос отправления и, если есть свободный автобус, замена.
+// Отклонённое диспетчером предложение с теми же значениями заново не создаётся.
+func (s *scheduleService) refreshDelayProposals(ctx context.Context, date string, busIDs ...string) error {
+	blocks, buses, err := s.vehicleBlocks(ctx, date)
+	if err != nil {
+		return err
+	}
+	dismissed, err := s.proposalRepo.FindByDate(ctx, date, ProposalDismissed)
+	if err != nil {
+		return fmt.Errorf("list dismissed proposals: %w", err)
+	}
+
+	seen := make(map[string]bool, len(busIDs))
+	for _, busID := range busIDs {
+		if busID == "" || seen[busID] {
+			continue
+		}
+		seen[busID] = true
+		if err = s.proposalRepo.DeletePending(ctx, busID, date); err != nil {
+			return fmt.Errorf("delete pending proposals: %w", err)
+		}
+		block := findBlock(blocks, busID)
+		if block == nil {
+			continue
+		}
+		for i := 1; i < len(block.Trips); i++ {
+			bt := &block.Trips[i]
+			if bt.KnockOnMinutes <= 0 || !proposable(bt.Status) {
+				continue
+			}
+			proposals := []*models.DelayProposal{{
+				TripID:       bt.TripID,
+				SourceTripID: block.Trips[i-1].TripID,
+				BusID:        busID,
+				Date:         date,
+				Kind:         ProposalKindDelay,
+				Status:       ProposalPending,
+				DelayMinutes: bt.PredictedDelayMinutes,
+			}}
+			if bus := s.replacementBus(blocks, buses, block, bt); bus != nil {
+				replacement := *proposals[0]
+				replacement.Kind = ProposalKindReplaceBus
+				replacement.ReplacementBusID = &bus.ID
+				proposals = append(proposals, &replacement)
+			}
+			for _, p := range proposals {
+				if wasDismissed(dismissed, p) {
+					continue
+				}
+				if err = s.proposalRepo.Create(ctx, p); err != nil {
+					return fmt.Errorf("create delay proposal: %w", err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// vehicleBlocks строит блоки всех автобусов, работающих на рейсах даты, и возвращает их вместе
+// со справочником автобусов. Отменённые рейсы в блоки не входят.
+func (s *scheduleService) vehicleBlocks(ctx context.Context, date string) ([]*VehicleBlock, []*models.Bus, error) {
+	trips, err := s.tripRepo.FindByDate(ctx, date)
+	if err != nil {
+		return nil, nil, fmt.Errorf("find trips by date: %w", err)
+	}
+	buses, err := s.busRepo.FindAll(ctx, nil, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("list buses: %w", err)
+	}
+	busByID := make(map[string]*models.Bus, len(buses))
+	for _, b := range buses {
+		busByID[b.ID] = b
+	}
+
+	var blocks []*VehicleBlock
+	byBus := make(map[string]*VehicleBlock)
+	add := func(busID string, trip *models.Trip, planned time.Time, number int) {
+		block := byBus[busID]
+		if block == nil {
+			block = &VehicleBlock{BusID: busID, Date: date}
+			if bus := busByID[busID]; bus != nil {
+				block.PlateNumber = bus.PlateNumber
+				block.Capacity = bus.Capacity
+			}
+			byBus[busID] = block
+			blocks = append(blocks, block)
+		}
+		block.Trips = append(block.Trips, BlockTrip{
+			PlannedDeparture: planned,
+			trip:             trip,
+			TripID:           trip.ID,
+			RouteName:        trip.Schedule.Route.Name,
+			Status:           trip.Status,
+			VehicleNumber:    number,
+			DelayMinutes:     trip.DelayMinutes,
+		})
+	}
+	for _, t := range trips {
+		if t.Status == "cancelled" { //nolint:misspell // trip status; British spelling intentional
+			continue
+		}
+		planned, parseErr := plannedDeparture(t)
+		if parseErr != nil {
+			s.logger.Warn("Trip skipped in vehicle blocks", zap.String("trip_id", t.ID), zap.Error(parseErr))
+			continue
+		}
+		if t.BusID != nil && *t.BusID != "" {
+			add(*t.BusID, t, planned, 1)
+		}
+		for _, v := range t.Vehicles {
+			add(v.BusID, t, planned, v.Number)
+		}
+	}
+
+	for _, block := range blocks {
+		sort.SliceStable(block.Trips, func(i, j int) bool {
+			return block.Trips[i].PlannedDeparture.Before(block.Trips[j].PlannedDeparture)
+		})
+		s.predictBlock(block)
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].Trips[0].PlannedDeparture.Before(blocks[j].Trips[0].PlannedDeparture)
+	})
+	return blocks, buses, nil
+}
+
+// predictBlock прогнозирует отправления рейсов блока по цепочке: автобус уходит в рейс не раньше
+// планового времени с объявленной задержкой и не раньше, чем прибудет с предыдущего рейса и пройдёт
+// минимальный оборот. Отправившиеся и прибывшие рейсы считаются по фактическому времени.
+func (s *scheduleService) predictBlock(block *VehicleBlock) {
+	var ready time.Time
+	block.KnockOnTrips = 0
+	for i := range block.Trips {
+		bt := &block.Trips[i]
+		bt.ExpectedDeparture = bt.PlannedDeparture.Add(time.Duration(bt.DelayMinutes) * time.Minute)
+		bt.PredictedDelayMinutes = bt.DelayMinutes
+		switch {
+		case bt.trip.DepartureActual != nil:
+			bt.ExpectedDeparture = *bt.trip.DepartureActual
+		case ready.After(bt.ExpectedDeparture):
+			bt.ExpectedDeparture = ready
+			bt.PredictedDelayMinutes = int(math.Ceil(ready.Sub(bt.PlannedDeparture).Minutes()))
+		}
+		bt.KnockOnMinutes = bt.PredictedDelayMinutes - bt.DelayMinutes
+		if bt.KnockOnMinutes > 0 {
+			block.KnockOnTrips++
+		}
+
+		bt.ExpectedArrival = bt.ExpectedDeparture.Add(time.Duration(bt.trip.Schedule.Route.DurationMin) * time.Minute)
+		if bt.trip.ArrivalActual != nil {
+			bt.ExpectedArrival = *bt.trip.ArrivalActual
+		}
+		ready = bt.ExpectedArrival.Add(s.cfg.Rotation.MinTurnaround)
+	}
+}
+
+// replacementBus подбирает автобус на замену для рейса блока: действующий, не меньшей вместимости,
+// не работающий на этом рейсе и свободный на время рейса. Автобусы станции отправления рейса — в приоритете.
+func (s *scheduleService) replacementBus(blocks []*VehicleBlock, buses []*models.Bus, block *VehicleBlock, bt *BlockTrip) *models.Bus {
+	station := departureStation(&bt.trip.Schedule.Route)
+	var fallback *models.Bus
+	for _, bus := range buses {
+		if bus.Status != "active" || bus.Capacity < block.Capacity || findBlockTrip(findBlock(blocks, bus.ID), bt.TripID) != nil {
+			continue
+		}
+		if !s.busFree(blocks, bus.ID, bt) {
+			continue
+		}
+		if station != "" && bus.StationID == station {
+			return bus
+		}
+		if fallback == nil {
+			fallback = bus
+		}
+	}
+	return fallback
+}
+
+// busFree сообщает, успевает ли автобус выполнить рейс без задержки: его рейсы за день вместе
+// с оборотом до и после не пересекаются с рейсом.
+func (s *scheduleService) busFree(blocks []*VehicleBlock, busID string, bt *BlockTrip) bool {
+	block := findBlock(blocks, busID)
+	if block == nil {
+		return true
+	}
+	start := bt.PlannedDeparture.Add(time.Duration(bt.DelayMinutes) * time.Minute)
+	end := start.Add(time.Duration(bt.trip.Schedule.Route.DurationMin) * time.Minute)
+	turnaround := s.cfg.Rotation.MinTurnaround
+	for _, other := range block.Trips {
+		if other.ExpectedDeparture.Add(-turnaround).Before(end) && other.ExpectedArrival.Add(turnaround).After(start) {
+			return false
+		}
+	}
+	return true
+}
+
+// plannedDeparture возвращает плановое отправление рейса: дата рейса и время по расписанию (местное время).
+func plannedDeparture(trip *models.Trip) (time.Time, error) {
+	day, err := time.ParseInLocation(time.DateOnly, tripDate(trip), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse trip date: %w", err)
+	}
+	clock, err := time.Parse(time.TimeOnly, trip.Schedule.DepartureTime)
+	if err != nil {
+		if clock, err = time.Parse("15:04", trip.Schedule.DepartureTime); err != nil {
+			return time.Time{}, fmt.Errorf("parse departure time: %w", err)
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, time.Local), nil
+}
+
+// tripDate возвращает дату рейса в формате YYYY-MM-DD (драйвер БД может вернуть дату с временем).
+func tripDate(trip *models.Trip) string {
+	if len(trip.Date) > len(time.DateOnly) {
+		return trip.Date[:len(time.DateOnly)]
+	}
+	return trip.Date
+}
+
+// tripBusIDs возвращает автобусы рейса: основной и дополнительные.
+func tripBusIDs(trip *models.Trip) []string {
+	ids := make([]string, 0, len(trip.Vehicles)+1)
+	if trip.BusID != nil {
+		ids = append(ids, *trip.BusID)
+	}
+	for _, v := range trip.Vehicles {
+		ids = append(ids, v.BusID)
+	}
+	return ids
+}
+
+// departureStation возвращает станцию отправления маршрута (первая остановка) или пустую строку.
+func departureStation(route *models.Route) string {
+	stops, err := route.ParseStops()
+	if err != nil || len(stops) == 0 {
+		return ""
+	}
+	first := stops[0]
+	for _, stop := range stops[1:] {
+		if stop.Order < first.Order {
+			first = stop
+		}
+	}
+	return first.StationID
+}
+
+func findBlock(blocks []*VehicleBlock, busID string) *VehicleBlock {
+	for _, b := range blocks {
+		if b.BusID == busID {
+			return b
+		}
+	}
+	return nil
+}
+
+func findBlockTrip(block *VehicleBlock, tripID string) *BlockTrip {
+	if block == nil {
+		return nil
+	}
+	for i := range block.Trips {
+		if block.Trips[i].TripID == tripID {
+			return &block.Trips[i]
+		}
+	}
+	return nil
+}
+
+// proposable сообщает, можно ли ещё изменить отправление рейса.
+func proposable(status string) bool {
+	return status == "scheduled" || status == "delayed" || status == "boarding"
+}
+
+// wasDismissed сообщает, отклонял ли диспетчер такое же предложение.
+func wasDismissed(dismissed []*models.DelayProposal, p *models.DelayProposal) bool {
+	for _, d := range dismissed {
+		if d.TripID == p.TripID && d.BusID == p.BusID && d.Kind == p.Kind && d.DelayMinutes == p.DelayMinutes &&
+			stringValue(d.ReplacementBusID) == stringValue(p.ReplacementBusID) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringValue(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func resolveProposal(p *models.DelayProposal, status, userID string) {
+	now := time.Now()
+	p.Status = status
+	p.ResolvedAt = &now
+	if userID != "" {
+		p.ResolvedBy = &userID
+	}
+}
+
+func isProposalError(err error) bool {
+	for _, target := range []error{
+		ErrDelayProposalNotFound, ErrProposalResolved, ErrProposalOutdated, ErrReplacementBusBusy,
+		ErrTripNotFound, ErrTripFinished, ErrBusAlreadyOnTrip,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
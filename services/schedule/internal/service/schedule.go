@@ -8,9 +8,14 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+
+	"github.com/vokzal-tech/schedule-service/internal/config"
 	"github.com/vokzal-tech/schedule-service/internal/models"
 	"github.com/vokzal-tech/schedule-service/internal/repository"
 )
@@ -46,6 +51,13 @@ type ScheduleService interface {
 	UpdateRoute(ctx context.Context, id string, req *UpdateRouteRequest) (*models.Route, error)
 	DeleteRoute(ctx context.Context, id string) error
 
+	// Carriers
+	CreateCarrier(ctx context.Context, req *CreateCarrierRequest) (*models.Carrier, error)
+	GetCarrier(ctx context.Context, id string) (*models.Carrier, error)
+	ListCarriers(ctx context.Context, activeOnly bool) ([]*models.Carrier, error)
+	UpdateCarrier(ctx context.Context, id string, req *UpdateCarrierRequest) (*models.Carrier, error)
+	DeactivateCarrier(ctx context.Context, id, userID string) error
+
 	// Schedules
 	CreateSchedule(ctx context.Context, req *CreateScheduleRequest) (*models.Schedule, error)
 	GetSchedule(ctx context.Context, id string) (*models.Schedule, error)
@@ -62,6 +74,19 @@ type ScheduleService interface {
 	GenerateTripsForSchedule(ctx context.Context, scheduleID string, fromDate, toDate time.Time) error
 	GetDashboardStats(ctx context.Context, date string) (*DashboardStats, error)
 
+	// Trip vehicles
+	AddTripVehicle(ctx context.Context, tripID string, req *AddTripVehicleRequest) (*models.TripVehicle, error)
+	ListTripVehicles(ctx context.Context, tripID string) ([]*models.TripVehicle, error)
+	UpdateTripVehicle(ctx context.Context, tripID, vehicleID string, req *UpdateTripVehicleRequest) (*models.TripVehicle, error)
+	RemoveTripVehicle(ctx context.Context, tripID, vehicleID string) error
+
+	// Vehicle blocks
+	ListVehicleBlocks(ctx context.Context, date string) ([]*VehicleBlock, error)
+	GetVehicleBlock(ctx context.Context, busID, date string) (*VehicleBlock, error)
+	ListDelayProposals(ctx context.Context, date, status string) ([]*models.DelayProposal, error)
+	AcceptDelayProposal(ctx context.Context, id, userID string) (*models.DelayProposal, error)
+	DismissDelayProposal(ctx context.Context, id, userID string) (*models.DelayProposal, error)
+
 	// Buses
 	CreateBus(ctx context.Context, req *CreateBusRequest) (*models.Bus, error)
 	GetBus(ctx context.Context, id string) (*models.Bus, error)
@@ -75,6 +100,9 @@ type ScheduleService interface {
 	ListDrivers(ctx context.Context, stationID *string) ([]*models.Driver, error)
 	UpdateDriver(ctx context.Context, id string, req *UpdateDriverRequest) (*models.Driver, error)
 	DeleteDriver(ctx context.Context, id string) error
+
+	// Events
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type scheduleService struct {
@@ -84,7 +112,12 @@ type scheduleService struct {
 	tripRepo     repository.TripRepository
 	busRepo      repository.BusRepository
 	driverRepo   repository.DriverRepository
-	natsConn     *nats.Conn
+	carrierRepo  repository.CarrierRepository
+	vehicleRepo  repository.TripVehicleRepository
+	proposalRepo repository.DelayProposalRepository
+	tx           *dbtx.Transactor
+	events       *outbox.Outbox
+	cfg          *config.Config
 	logger       *zap.Logger
 }
 
@@ -104,17 +137,19 @@ type UpdateStationRequest struct {
 	Timezone *string `json:"timezone"`
 }
 
-// CreateRouteRequest — запрос на создание маршрута.
+// CreateRouteRequest — запрос на создание маршрута. CarrierID — действующий перевозчик (необязателен).
 type CreateRouteRequest struct {
+	CarrierID   *string                  `json:"carrier_id"`
 	Name        string                   `json:"name" binding:"required"`
 	Stops       []map[string]interface{} `json:"stops" binding:"required"`
 	DistanceKm  float64                  `json:"distance_km"`
 	DurationMin int                      `json:"duration_min"`
 }
 
-// UpdateRouteRequest — запрос на обновление маршрута.
+// UpdateRouteRequest — запрос на обновление маршрута. Пустой carrier_id снимает привязку к перевозчику.
 type UpdateRouteRequest struct {
 	Name        *string                  `json:"name"`
+	CarrierID   *string                  `json:"carrier_id"`
 	DistanceKm  *float64                 `json:"distance_km"`
 	DurationMin *int                     `json:"duration_min"`
 	IsActive    *bool                    `json:"is_active"`
@@ -190,6 +225,9 @@ type UpdateDriverRequest struct {
 
 // DashboardStats — статистика для дашборда (рейсы за дату).
 type DashboardStats struct {
+	// VehicleBlocks — цепочки рейсов автобусов (от двух рейсов) с прогнозом задержек по цепочке.
+	VehicleBlocks []*VehicleBlock `json:"vehicle_blocks"`
+
 	TripsTotal     int `json:"trips_total"`
 	TripsScheduled int `json:"trips_scheduled"`
 	TripsBoarding  int `json:"trips_boarding"`
@@ -197,8 +235,11 @@ type DashboardStats struct {
 	TripsCancelled int `json:"trips_cancelled"` //nolint:misspell // British spelling; golangci-lint misspell (locale US) flags it
 	TripsDelayed   int `json:"trips_delayed"`
 	TripsArrived   int `json:"trips_arrived"`
-	// TotalCapacity — сумма вместимостей автобусов по рейсам за дату (рейсы без автобуса считаются как 40 мест).
+	// TotalCapacity — сумма вместимостей автобусов по рейсам за дату с учётом дополнительных автобусов
+	// (рейсы без основного автобуса считаются как 40 мест).
 	TotalCapacity int `json:"total_capacity"`
+	// PendingDelayProposals — нерассмотренные предложения по задержкам и заменам автобусов.
+	PendingDelayProposals int `json:"pending_delay_proposals"`
 }
 
 // NewScheduleService создаёт сервис расписания.
@@ -209,7 +250,12 @@ func NewScheduleService(
 	tripRepo repository.TripRepository,
 	busRepo repository.BusRepository,
 	driverRepo repository.DriverRepository,
-	natsConn *nats.Conn,
+	carrierRepo repository.CarrierRepository,
+	vehicleRepo repository.TripVehicleRepository,
+	proposalRepo repository.DelayProposalRepository,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
+	cfg *config.Config,
 	logger *zap.Logger,
 ) ScheduleService {
 	return &scheduleService{
@@ -219,7 +265,12 @@ func NewScheduleService(
 		tripRepo:     tripRepo,
 		busRepo:      busRepo,
 		driverRepo:   driverRepo,
-		natsConn:     natsConn,
+		carrierRepo:  carrierRepo,
+		vehicleRepo:  vehicleRepo,
+		proposalRepo: proposalRepo,
+		tx:           tx,
+		events:       events,
+		cfg:          cfg,
 		logger:       logger,
 	}
 }
@@ -285,7 +336,13 @@ func (s *scheduleService) CreateRoute(ctx context.Context, req *CreateRouteReque
 		return nil, fmt.Errorf("failed to marshal stops: %w", err)
 	}
 
+	carrierID, err := s.resolveRouteCarrier(ctx, req.CarrierID)
+	if err != nil {
+		return nil, err
+	}
+
 	route := &models.Route{
+		CarrierID:   carrierID,
 		Name:        req.Name,
 		Stops:       models.JSONB(stopsJSON),
 		DistanceKm:  req.DistanceKm,
@@ -323,6 +380,11 @@ func (s *scheduleService) UpdateRoute(ctx context.Context, id string, req *Updat
 	if req.Name != nil {
 		route.Name = *req.Name
 	}
+	if req.CarrierID != nil {
+		if route.CarrierID, err = s.resolveRouteCarrier(ctx, req.CarrierID); err != nil {
+			return nil, err
+		}
+	}
 	if req.Stops != nil {
 		stopsJSON, err := json.Marshal(req.Stops)
 		if err != nil {
@@ -439,13 +501,16 @@ func (s *scheduleService) CreateTrip(ctx context.Context, req *CreateTripRequest
 		DriverID:   req.DriverID,
 	}
 
-	if err := s.tripRepo.Create(ctx, trip); err != nil {
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.tripRepo.Create(ctx, trip); dbErr != nil {
+			return dbErr
+		}
+		return s.publishTripEvent(ctx, trip.ID, events.TripCreated{Trip: tripEvent(trip)})
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Отправить событие в NATS
-	s.publishTripEvent("trip.created", trip)
-
 	s.logger.Info("Trip created", zap.String("trip_id", trip.ID), zap.String("date", trip.Date))
 	return trip, nil
 }
@@ -477,13 +542,20 @@ func (s *scheduleService) UpdateTripStatus(ctx context.Context, id, status strin
 		trip.ArrivalActual = &now
 	}
 
-	if err := s.tripRepo.Update(ctx, trip); err != nil {
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.tripRepo.Update(ctx, trip); dbErr != nil {
+			return dbErr
+		}
+		if pubErr := s.publishTripEvent(ctx, trip.ID, events.TripStatusChanged{Trip: tripEvent(trip)}); pubErr != nil {
+			return pubErr
+		}
+		// Опоздание рейса сдвигает следующие рейсы его автобусов
+		return s.refreshDelayProposals(ctx, tripDate(trip), tripBusIDs(trip)...)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Отправить событие
-	s.publishTripEvent("trip.status_changed", trip)
-
 	s.logger.Info("Trip status updated",
 		zap.String("trip_id", trip.ID),
 		zap.String("status", status),
@@ -500,19 +572,37 @@ func (s *scheduleService) UpdateTrip(ctx context.Context, id string, req *Update
 		}
 		return nil, fmt.Errorf("find trip: %w", err)
 	}
+	busIDs := tripBusIDs(trip)
 	if req.Platform != nil {
 		trip.Platform = req.Platform
 	}
 	if req.BusID != nil {
+		for _, v := range trip.Vehicles {
+			if v.BusID == *req.BusID {
+				return nil, ErrBusAlreadyOnTrip
+			}
+		}
 		trip.BusID = req.BusID
 	}
 	if req.DriverID != nil {
 		trip.DriverID = req.DriverID
 	}
-	if err := s.tripRepo.Update(ctx, trip); err != nil {
-		return nil, fmt.Errorf("update trip: %w", err)
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.tripRepo.Update(ctx, trip); dbErr != nil {
+			return fmt.Errorf("update trip: %w", dbErr)
+		}
+		if pubErr := s.publishTripEvent(ctx, trip.ID, events.TripUpdated{Trip: tripEvent(trip)}); pubErr != nil {
+			return pubErr
+		}
+		if req.BusID == nil {
+			return nil
+		}
+		// Замена автобуса меняет блоки прежнего и нового автобуса
+		return s.refreshDelayProposals(ctx, tripDate(trip), append(busIDs, *req.BusID)...)
+	})
+	if err != nil {
+		return nil, err
 	}
-	s.publishTripEvent("trip.updated", trip)
 	return trip, nil
 }
 
@@ -726,14 +816,35 @@ func (s *scheduleService) GetDashboardStats(ctx context.Context, date string) (*
 		}
 		seats := defaultCapacityPerTrip
 		if t.BusID != nil && *t.BusID != "" {
-			bus, err := s.busRepo.FindByID(ctx, *t.BusID)
-			if err == nil {
+			bus, busErr := s.busRepo.FindByID(ctx, *t.BusID)
+			if busErr == nil {
 				seats = bus.Capacity
 			}
 		}
+		for _, v := range t.Vehicles {
+			if bus, busErr := s.busRepo.FindByID(ctx, v.BusID); busErr == nil {
+				seats += bus.Capacity
+			}
+		}
 		totalCapacity += seats
 	}
 	stats.TotalCapacity = totalCapacity
+
+	blocks, _, err := s.vehicleBlocks(ctx, date)
+	if err != nil {
+		return nil, fmt.Errorf("GetDashboardStats: %w", err)
+	}
+	stats.VehicleBlocks = make([]*VehicleBlock, 0, len(blocks))
+	for _, b := range blocks {
+		if len(b.Trips) > 1 {
+			stats.VehicleBlocks = append(stats.VehicleBlocks, b)
+		}
+	}
+	proposals, err := s.proposalRepo.FindByDate(ctx, date, ProposalPending)
+	if err != nil {
+		return nil, fmt.Errorf("GetDashboardStats: find delay proposals: %w", err)
+	}
+	stats.PendingDelayProposals = len(proposals)
 	return stats, nil
 }
 
@@ -775,25 +886,60 @@ func (s *scheduleService) GenerateTripsForSchedule(ctx context.Context, schedule
 				Status:     "scheduled",
 				Platform:   schedule.Platform,
 			}
-			if err := s.tripRepo.Create(ctx, trip); err != nil {
-				s.logger.Error("Failed to create trip", zap.Error(err), zap.String("date", dateStr))
+			createErr := s.tx.Run(ctx, func(ctx context.Context) error {
+				if dbErr := s.tripRepo.Create(ctx, trip); dbErr != nil {
+					return dbErr
+				}
+				return s.publishTripEvent(ctx, trip.ID, events.TripCreated{Trip: tripEvent(trip)})
+			})
+			if createErr != nil {
+				s.logger.Error("Failed to create trip", zap.Error(createErr), zap.String("date", dateStr))
 				continue
 			}
-			s.publishTripEvent("trip.created", trip)
 		}
 	}
 
 	return nil
 }
 
-func (s *scheduleService) publishTripEvent(subject string, trip *models.Trip) {
-	data, err := json.Marshal(trip)
-	if err != nil {
-		s.logger.Error("Failed to marshal trip event", zap.Error(err))
-		return
-	}
-
-	if err := s.natsConn.Publish(subject, data); err != nil {
-		s.logger.Error("Failed to publish trip event", zap.Error(err), zap.String("subject", subject))
-	}
+// publishTripEvent записывает событие по рейсу в outbox; события рейса доставляются в порядке записи.
+func (s *scheduleService) publishTripEvent(ctx context.Context, tripID string, event events.Event) error {
+	return s.events.Publish(ctx, "trip", tripID, event)
+}
+
+// tripEvent возвращает рейс для событий (без вложенного расписания).
+func tripEvent(trip *models.Trip) events.Trip {
+	return events.Trip{
+		CreatedAt:       trip.CreatedAt,
+		UpdatedAt:       trip.UpdatedAt,
+		DepartureActual: trip.DepartureActual,
+		ArrivalActual:   trip.ArrivalActual,
+		Platform:        trip.Platform,
+		BusID:           trip.BusID,
+		DriverID:        trip.DriverID,
+		ID:              trip.ID,
+		ScheduleID:      trip.ScheduleID,
+		Date:            trip.Date,
+		Status:          trip.Status,
+		Vehicles:        tripVehicleEvents(trip.Vehicles),
+		DelayMinutes:    trip.DelayMinutes,
+	}
+}
+
+// tripVehicleEvents возвращает дополнительные автобусы рейса для событий.
+func tripVehicleEvents(vehicles []models.TripVehicle) []events.TripVehicle {
+	if len(vehicles) == 0 {
+		return nil
+	}
+	out := make([]events.TripVehicle, 0, len(vehicles))
+	for _, v := range vehicles {
+		out = append(out, events.TripVehicle{
+			DriverID: v.DriverID,
+			Platform: v.Platform,
+			ID:       v.ID,
+			BusID:    v.BusID,
+			Number:   v.Number,
+		})
+	}
+	return out
 }
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/schedule-service/internal/models"
+	"github.com/vokzal-tech/schedule-service/internal/repository"
+)
+
+var (
+	// ErrTripVehicleNotFound возвращается, когда дополнительный автобус не найден на рейсе.
+	ErrTripVehicleNotFound = errors.New("trip vehicle not found")
+	// ErrBusAlreadyOnTrip возвращается, когда автобус уже работает на рейсе (основным или дополнительным).
+	ErrBusAlreadyOnTrip = errors.New("bus is already assigned to the trip")
+	// ErrTripFinished возвращается при изменении автобусов отправившегося, прибывшего или отменённого рейса.
+	ErrTripFinished = errors.New("trip has already departed or been cancelled")
+	// ErrVehicleHasTickets возвращается при снятии с рейса автобуса, на который проданы билеты.
+	ErrVehicleHasTickets = errors.New("vehicle has active tickets")
+)
+
+// AddTripVehicleRequest — запрос на добавление автобуса к рейсу. Platform не задан — посадка с перрона рейса.
+type AddTripVehicleRequest struct {
+	DriverID *string `json:"driver_id"`
+	Platform *string `json:"platform"`
+	BusID    string  `json:"bus_id" binding:"required"`
+}
+
+// UpdateTripVehicleRequest — запрос на замену автобуса, водителя или перрона дополнительного автобуса.
+type UpdateTripVehicleRequest struct {
+	BusID    *string `json:"bus_id"`
+	DriverID *string `json:"driver_id"`
+	Platform *string `json:"platform"`
+}
+
+// AddTripVehicle добавляет к рейсу автобус со своей схемой мест: продажа продолжается на нём,
+// когда основной автобус заполнен. Автобус получает следующий номер после уже добавленных.
+func (s *scheduleService) AddTripVehicle(ctx context.Context, tripID string, req *AddTripVehicleRequest) (*models.TripVehicle, error) {
+	trip, err := s.findOpenTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkVehicleCrew(ctx, &req.BusID, req.DriverID); err != nil {
+		return nil, err
+	}
+
+	vehicle := &models.TripVehicle{
+		TripID:   tripID,
+		BusID:    req.BusID,
+		DriverID: req.DriverID,
+		Platform: req.Platform,
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		vehicles, dbErr := s.vehicleRepo.FindByTripID(ctx, tripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if busOnTrip(trip, vehicles, req.BusID, "") {
+			return ErrBusAlreadyOnTrip
+		}
+		vehicle.Number = 2
+		if len(vehicles) > 0 {
+			vehicle.Number = vehicles[len(vehicles)-1].Number + 1
+		}
+		if dbErr = s.vehicleRepo.Create(ctx, vehicle); dbErr != nil {
+			// Уникальные индексы (рейс, автобус) и (рейс, номер) ловят одновременное добавление
+			if errors.Is(dbErr, gorm.ErrDuplicatedKey) {
+				return ErrBusAlreadyOnTrip
+			}
+			return dbErr
+		}
+		return s.publishTripVehicles(ctx, trip, append(vehicles, vehicle))
+	})
+	if err != nil {
+		if errors.Is(err, ErrBusAlreadyOnTrip) {
+			return nil, err
+		}
+		s.logger.Error("AddTripVehicle failed", zap.String("trip_id", tripID), zap.Error(err))
+		return nil, fmt.Errorf("add trip vehicle: %w", err)
+	}
+	s.logger.Info("Trip vehicle added",
+		zap.String("trip_id", tripID),
+		zap.String("bus_id", vehicle.BusID),
+		zap.Int("number", vehicle.Number))
+	return vehicle, nil
+}
+
+// ListTripVehicles возвращает дополнительные автобусы рейса по номерам.
+func (s *scheduleService) ListTripVehicles(ctx context.Context, tripID string) ([]*models.TripVehicle, error) {
+	if _, err := s.findTrip(ctx, tripID); err != nil {
+		return nil, err
+	}
+	return s.vehicleRepo.FindByTripID(ctx, tripID)
+}
+
+// UpdateTripVehicle меняет автобус, водителя или перрон дополнительного автобуса; проданные
+// на него билеты остаются за ним.
+func (s *scheduleService) UpdateTripVehicle(ctx context.Context, tripID, vehicleID string, req *UpdateTripVehicleRequest) (*models.TripVehicle, error) {
+	trip, err := s.findOpenTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.checkVehicleCrew(ctx, req.BusID, req.DriverID); err != nil {
+		return nil, err
+	}
+
+	var vehicle *models.TripVehicle
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		vehicles, dbErr := s.vehicleRepo.FindByTripID(ctx, tripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		for _, v := range vehicles {
+			if v.ID == vehicleID {
+				vehicle = v
+			}
+		}
+		if vehicle == nil {
+			return ErrTripVehicleNotFound
+		}
+		if req.BusID != nil {
+			if busOnTrip(trip, vehicles, *req.BusID, vehicleID) {
+				return ErrBusAlreadyOnTrip
+			}
+			vehicle.BusID = *req.BusID
+		}
+		if req.DriverID != nil {
+			vehicle.DriverID = req.DriverID
+		}
+		if req.Platform != nil {
+			vehicle.Platform = req.Platform
+		}
+		if dbErr = s.vehicleRepo.Update(ctx, vehicle); dbErr != nil {
+			if errors.Is(dbErr, gorm.ErrDuplicatedKey) {
+				return ErrBusAlreadyOnTrip
+			}
+			return dbErr
+		}
+		return s.publishTripVehicles(ctx, trip, vehicles)
+	})
+	if err != nil {
+		if errors.Is(err, ErrTripVehicleNotFound) || errors.Is(err, ErrBusAlreadyOnTrip) {
+			return nil, err
+		}
+		s.logger.Error("UpdateTripVehicle failed", zap.String("trip_id", tripID), zap.String("vehicle_id", vehicleID), zap.Error(err))
+		return nil, fmt.Errorf("update trip vehicle: %w", err)
+	}
+	return vehicle, nil
+}
+
+// RemoveTripVehicle снимает дополнительный автобус с рейса. Автобус с проданными билетами снять нельзя:
+// пассажиров сначала пересаживают коррекцией посадки или обменом.
+func (s *scheduleService) RemoveTripVehicle(ctx context.Context, tripID, vehicleID string) error {
+	trip, err := s.findOpenTrip(ctx, tripID)
+	if err != nil {
+		return err
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if _, dbErr := s.vehicleRepo.FindByID(ctx, tripID, vehicleID); dbErr != nil {
+			if errors.Is(dbErr, repository.ErrTripVehicleNotFound) {
+				return ErrTripVehicleNotFound
+			}
+			return dbErr
+		}
+		tickets, dbErr := s.vehicleRepo.CountActiveTickets(ctx, vehicleID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if tickets > 0 {
+			return ErrVehicleHasTickets
+		}
+		if dbErr = s.vehicleRepo.Delete(ctx, vehicleID); dbErr != nil {
+			return dbErr
+		}
+		vehicles, dbErr := s.vehicleRepo.FindByTripID(ctx, tripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		return s.publishTripVehicles(ctx, trip, vehicles)
+	})
+	if err != nil {
+		if errors.Is(err, ErrTripVehicleNotFound) || errors.Is(err, ErrVehicleHasTickets) {
+			return err
+		}
+		s.logger.Error("RemoveTripVehicle failed", zap.String("trip_id", tripID), zap.String("vehicle_id", vehicleID), zap.Error(err))
+		return fmt.Errorf("remove trip vehicle: %w", err)
+	}
+	s.logger.Info("Trip vehicle removed", zap.String("trip_id", tripID), zap.String("vehicle_id", vehicleID))
+	return nil
+}
+
+// findTrip возвращает рейс или ErrTripNotFound.
+func (s *scheduleService) findTrip(ctx context.Context, tripID string) (*models.Trip, error) {
+	trip, err := s.tripRepo.FindByID(ctx, tripID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			return nil, ErrTripNotFound
+		}
+		return nil, fmt.Errorf("find trip: %w", err)
+	}
+	return trip, nil
+}
+
+// findOpenTrip возвращает рейс, автобусы которого ещё можно менять: он не отправился и не отменён.
+func (s *scheduleService) findOpenTrip(ctx context.Context, tripID string) (*models.Trip, error) {
+	trip, err := s.findTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	switch trip.Status {
+	case "departed", "arrived", "cancelled": //nolint:misspell // trip status; British spelling intentional
+		return nil, ErrTripFinished
+	}
+	return trip, nil
+}
+
+// checkVehicleCrew проверяет, что автобус и водитель (если заданы) существуют.
+func (s *scheduleService) checkVehicleCrew(ctx context.Context, busID, driverID *string) error {
+	if busID != nil {
+		if _, err := s.busRepo.FindByID(ctx, *busID); err != nil {
+			if errors.Is(err, repository.ErrBusNotFound) {
+				return ErrBusNotFound
+			}
+			return fmt.Errorf("find bus: %w", err)
+		}
+	}
+	if driverID != nil && *driverID != "" {
+		if _, err := s.driverRepo.FindByID(ctx, *driverID); err != nil {
+			if errors.Is(err, repository.ErrDriverNotFound) {
+				return ErrDriverNotFound
+			}
+			return fmt.Errorf("find driver: %w", err)
+		}
+	}
+	return nil
+}
+
+// publishTripVehicles публикует trip.updated с актуальным составом автобусов рейса: ticket-service
+// предлагает появившиеся места листу ожидания, табло обновляет посадку.
+func (s *scheduleService) publishTripVehicles(ctx context.Context, trip *models.Trip, vehicles []*models.TripVehicle) error {
+	trip.Vehicles = trip.Vehicles[:0]
+	for _, v := range vehicles {
+		trip.Vehicles = append(trip.Vehicles, *v)
+	}
+	return s.publishTripEvent(ctx, trip.ID, events.TripUpdated{Trip: tripEvent(trip)})
+}
+
+// busOnTrip сообщает, работает ли автобус на рейсе основным или дополнительным (кроме exceptVehicleID).
+func busOnTrip(trip *models.Trip, vehicles []*models.TripVehicle, busID, exceptVehicleID string) bool {
+	if trip.BusID != nil && *trip.BusID == busID {
+		return true
+	}
+	for _, v := range vehicles {
+		if v.BusID == busID && v.ID != exceptVehicleID {
+			return true
+		}
+	}
+	return false
+}
@@ -12,12 +12,21 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
 	"go.uber.org/zap"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/idempotency"
+	"github.com/vokzal-tech/go-common/outbox"
+	"github.com/vokzal-tech/go-common/pii"
+
 	"github.com/vokzal-tech/ticket-service/internal/config"
 	"github.com/vokzal-tech/ticket-service/internal/handlers"
+	"github.com/vokzal-tech/ticket-service/internal/middleware"
 	"github.com/vokzal-tech/ticket-service/internal/models"
 	"github.com/vokzal-tech/ticket-service/internal/repository"
 	"github.com/vokzal-tech/ticket-service/internal/service"
@@ -49,7 +58,7 @@ func main() {
 	logger.Info("Starting Ticket Service", zap.String("version", "1.0.0"))
 
 	// Подключиться к БД с оптимизированным connection pool
-	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(cfg.Database.DSN()), &gorm.Config{TranslateError: true})
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
@@ -63,9 +72,20 @@ func main() {
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
 
-	if migErr := db.AutoMigrate(&models.Ticket{}, &models.BoardingEvent{}, &models.BoardingMark{}); migErr != nil {
+	// Шифрование ПД пассажиров: ключи нужны до первого обращения к таблице билетов
+	piiKeyring, err := pii.NewKeyring(cfg.PII.Keys, cfg.PII.ActiveKey, cfg.PII.IndexKey)
+	if err != nil {
+		logger.Fatal("Failed to configure PII encryption", zap.Error(err))
+	}
+	models.SetPIIKeyring(piiKeyring)
+
+	if migErr := db.AutoMigrate(&models.Ticket{}, &models.BaggageTicket{}, &models.RefundPolicy{}, &models.FareComponentRule{}, &models.TripSalesSettings{}, &models.DriverSale{}, &models.QRSigningKey{}, &models.BoardingEvent{}, &models.BoardingMark{}, &models.BoardingCorrection{}, &models.ErasureRequest{}, &models.AnonymizationLog{}, &models.TicketNameToken{}, &models.CashierShift{}, &models.ShiftOperation{}, &models.ReportJob{}, &models.WaitlistEntry{}, &models.Voucher{}, &models.CarrierSettlement{}, &idempotency.Record{}, &outbox.Message{}); migErr != nil {
 		logger.Warn("Auto-migration failed", zap.Error(migErr))
 	}
+	// seed ключей подписи QR перенесён в зашифрованную колонку seed; private_key остаётся только у старых ключей
+	if migErr := db.Exec("ALTER TABLE qr_signing_keys ALTER COLUMN private_key DROP NOT NULL").Error; migErr != nil {
+		logger.Warn("Failed to relax qr_signing_keys.private_key", zap.Error(migErr))
+	}
 
 	// Подключиться к NATS
 	natsConn, err := nats.Connect(cfg.NATS.URL,
@@ -78,12 +98,168 @@ func main() {
 
 	logger.Info("Connected to NATS", zap.String("url", cfg.NATS.URL))
 
+	// Потоки JetStream создаются любым сервисом, который публикует или получает события
+	js, err := jetstream.New(natsConn)
+	if err != nil {
+		logger.Fatal("Failed to create JetStream context", zap.Error(err))
+	}
+	if streamErr := eventbus.EnsureStreams(context.Background(), js); streamErr != nil {
+		logger.Fatal("Failed to ensure JetStream streams", zap.Error(streamErr))
+	}
+
+	// Доставка событий из outbox в JetStream (останавливается до закрытия соединения с NATS)
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	defer stopRelay()
+	go outbox.NewRelay(db, eventbus.NewPublisher(js, cfg.Outbox.FlushTimeout), outbox.RelayConfig{
+		Service:      "ticket",
+		Interval:     cfg.Outbox.Interval,
+		MaxBackoff:   cfg.Outbox.MaxBackoff,
+		Retention:    cfg.Outbox.Retention,
+		FlushTimeout: cfg.Outbox.FlushTimeout,
+		BatchSize:    cfg.Outbox.BatchSize,
+	}, logger).Run(relayCtx)
+
 	// Создать репозитории
 	ticketRepo := repository.NewTicketRepository(db)
 	boardingRepo := repository.NewBoardingRepository(db)
+	baggageRepo := repository.NewBaggageRepository(db)
+	refundPolicyRepo := repository.NewRefundPolicyRepository(db)
+	qrKeyRepo := repository.NewQRKeyRepository(db)
+	shiftRepo := repository.NewShiftRepository(db)
+	retentionRepo := repository.NewRetentionRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	waitlistRepo := repository.NewWaitlistRepository(db)
+	voucherRepo := repository.NewVoucherRepository(db)
+	settlementRepo := repository.NewSettlementRepository(db)
+	fareComponentRepo := repository.NewFareComponentRepository(db)
+	salesRepo := repository.NewSalesRepository(db)
+	driverSaleRepo := repository.NewDriverSaleRepository(db)
 
 	// Создать сервис
-	ticketService := service.NewTicketService(ticketRepo, boardingRepo, natsConn, cfg, logger)
+	ticketService := service.NewTicketService(ticketRepo, boardingRepo, baggageRepo, refundPolicyRepo, qrKeyRepo, retentionRepo, shiftRepo, reportRepo, waitlistRepo, voucherRepo, settlementRepo, fareComponentRepo, salesRepo, driverSaleRepo, piiKeyring, dbtx.NewTransactor(db), outbox.New(db, "ticket"), cfg, logger)
+
+	// Ротация ключей подписи QR-кодов (первый ключ создаётся при старте). seed ключей,
+	// выпущенных до шифрования, шифруются ключом ПД до первой подписи.
+	if _, encErr := ticketService.ReencryptQRKeys(context.Background()); encErr != nil {
+		logger.Error("Failed to encrypt QR signing key seeds", zap.Error(encErr))
+	}
+	if rotErr := ticketService.RotateQRKeyIfDue(context.Background()); rotErr != nil {
+		logger.Error("Failed to ensure QR signing key", zap.Error(rotErr))
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if rotErr := ticketService.RotateQRKeyIfDue(context.Background()); rotErr != nil {
+				logger.Error("Failed to rotate QR signing key", zap.Error(rotErr))
+			}
+		}
+	}()
+
+	// Перешифрование ПД после смены активного ключа (и записей, сохранённых до включения шифрования)
+	go func() {
+		ticker := time.NewTicker(cfg.PII.ReencryptInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, encErr := ticketService.ReencryptQRKeys(context.Background()); encErr != nil {
+				logger.Error("Failed to re-encrypt QR signing key seeds", zap.Error(encErr))
+			}
+			for {
+				n, encErr := ticketService.ReencryptPII(context.Background())
+				if encErr != nil {
+					logger.Error("Failed to re-encrypt passenger PII", zap.Error(encErr))
+				}
+				if encErr != nil || n < cfg.PII.ReencryptBatch {
+					break
+				}
+			}
+		}
+	}()
+
+	// Обезличивание ПД по истечении срока хранения
+	go func() {
+		ticker := time.NewTicker(cfg.PII.RetentionInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for {
+				n, retErr := ticketService.RunRetention(context.Background())
+				if retErr != nil {
+					logger.Error("Failed to anonymize expired passenger data", zap.Error(retErr))
+				}
+				if retErr != nil || n < cfg.PII.RetentionBatch {
+					break
+				}
+			}
+		}
+	}()
+
+	// Фоновое построение отчётов за большие периоды и удаление просроченных результатов
+	go func() {
+		ticker := time.NewTicker(cfg.Reports.JobInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, repErr := ticketService.RunReportJobs(context.Background()); repErr != nil {
+				logger.Error("Failed to run report jobs", zap.Error(repErr))
+			}
+		}
+	}()
+
+	// Истёкшие предложения листа ожидания: место переходит следующему в очереди
+	go func() {
+		ticker := time.NewTicker(cfg.Waitlist.CheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, wlErr := ticketService.ExpireWaitlistOffers(context.Background()); wlErr != nil {
+				logger.Error("Failed to expire waitlist offers", zap.Error(wlErr))
+			}
+		}
+	}()
+
+	// Черновики расчётов с перевозчиками за прошедший месяц
+	if cfg.Settlements.AutoMonthly {
+		go func() {
+			ticker := time.NewTicker(cfg.Settlements.CheckInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if _, stErr := ticketService.CreateMonthlySettlements(context.Background(), time.Now()); stErr != nil {
+					logger.Error("Failed to create monthly carrier settlements", zap.Error(stErr))
+				}
+			}
+		}()
+	}
+
+	// Защита от повторов продажи, возврата и начала посадки (Idempotency-Key)
+	idempotencyStore := idempotency.NewGormStore(db)
+	idempotent := idempotency.Middleware(idempotencyStore, idempotency.Config{
+		Logger:      logger,
+		TTL:         cfg.Idempotency.TTL,
+		LockTimeout: cfg.Idempotency.LockTimeout,
+		Required:    cfg.Idempotency.Required,
+	})
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, idemErr := idempotencyStore.DeleteExpired(context.Background(), time.Now()); idemErr != nil {
+				logger.Error("Failed to delete expired idempotency keys", zap.Error(idemErr))
+			}
+		}
+	}()
+
+	// Durable-консьюмер: Z-отчёты, изменения рейсов и отчёты сервисов об обезличивании, опубликованные
+	// при остановленном сервисе, будут обработаны после запуска — смены не останутся без Z-отчёта,
+	// пассажиры отменённого рейса без компенсаций, запросы на удаление ПД незакрытыми
+	consumer := eventbus.NewConsumer(natsConn, js, eventbus.ConsumerConfig{
+		Durable:    "ticket",
+		Backoff:    cfg.Consumer.Backoff,
+		AckWait:    cfg.Consumer.AckWait,
+		MaxDeliver: cfg.Consumer.MaxDeliver,
+	}, logger)
+	ticketService.RegisterEventHandlers(consumer)
+	if consumeErr := consumer.Start(context.Background()); consumeErr != nil {
+		logger.Fatal("Failed to start event consumer", zap.Error(consumeErr))
+	}
+	defer consumer.Stop()
 
 	// Создать handlers
 	ticketHandler := handlers.NewTicketHandler(ticketService, logger)
@@ -93,6 +269,7 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 	router := gin.Default()
+	router.Use(events.CorrelationMiddleware())
 
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
@@ -106,16 +283,101 @@ func main() {
 	v1 := router.Group("/v1")
 	ticketStats := v1.Group("/ticket")
 	ticketStats.GET("/stats/dashboard", ticketHandler.GetDashboardStats)
+	eventbus.NewAdminHandler(eventbus.NewDeadLetters(js, "ticket"), logger).Register(ticketStats.Group("/dead-letters"))
+	reports := v1.Group("/reports")
+	reports.GET("/sales", ticketHandler.GetSalesReport)
+	reports.POST("/sales/jobs", ticketHandler.CreateSalesReportJob)
+	reports.GET("/jobs", ticketHandler.ListReportJobs)
+	reports.GET("/jobs/:id", ticketHandler.GetReportJob)
+	reports.GET("/jobs/:id/download", ticketHandler.DownloadReportJob)
 	tickets := v1.Group("/tickets")
-	tickets.POST("/sell", ticketHandler.SellTicket)
+	tickets.POST("/sell", idempotent, ticketHandler.SellTicket)
 	tickets.GET("", ticketHandler.ListTicketsByTrip)
 	tickets.GET("/:id", ticketHandler.GetTicket)
+	tickets.GET("/lookup", ticketHandler.LookupTickets)
+	tickets.GET("/search", ticketHandler.SearchTickets)
 	tickets.GET("/qr", ticketHandler.GetTicketByQR)
-	tickets.POST("/:id/refund", ticketHandler.RefundTicket)
+	tickets.GET("/qr/keys", ticketHandler.GetQRKeySet)
+	tickets.POST("/qr/keys/rotate", ticketHandler.RotateQRKey)
+	tickets.POST("/:id/refund", idempotent, ticketHandler.RefundTicket)
+	tickets.POST("/:id/exchange", idempotent, ticketHandler.ExchangeTicket)
+	baggage := v1.Group("/baggage")
+	baggage.POST("/sell", idempotent, ticketHandler.SellBaggage)
+	baggage.GET("", ticketHandler.ListBaggageByTicket)
+	baggage.GET("/:id", ticketHandler.GetBaggage)
+	baggage.POST("/:id/refund", idempotent, ticketHandler.RefundBaggage)
+	refundPolicies := v1.Group("/refund-policies")
+	refundPolicies.POST("", ticketHandler.CreateRefundPolicy)
+	refundPolicies.GET("", ticketHandler.ListRefundPolicies)
+	refundPolicies.GET("/:id", ticketHandler.GetRefundPolicy)
+	refundPolicies.DELETE("/:id", ticketHandler.DeactivateRefundPolicy)
+	fareComponents := v1.Group("/fare-components")
+	fareComponents.POST("", ticketHandler.CreateFareComponentRule)
+	fareComponents.GET("", ticketHandler.ListFareComponentRules)
+	fareComponents.GET("/:id", ticketHandler.GetFareComponentRule)
+	fareComponents.DELETE("/:id", ticketHandler.DeactivateFareComponentRule)
+	tripSales := v1.Group("/trips/:trip_id/sales")
+	tripSales.GET("", ticketHandler.GetSalesSettings)
+	tripSales.PUT("", ticketHandler.UpdateSalesSettings)
+	tripSales.DELETE("", ticketHandler.DeleteSalesSettings)
+	v1.GET("/trips/:trip_id/vehicles", ticketHandler.ListTripVehicles)
+	shifts := v1.Group("/shifts")
+	shifts.POST("/open", ticketHandler.OpenShift)
+	shifts.GET("", ticketHandler.ListShifts)
+	shifts.GET("/current", ticketHandler.GetCurrentShift)
+	shifts.GET("/:id", ticketHandler.GetShift)
+	shifts.GET("/:id/operations", ticketHandler.ListShiftOperations)
+	shifts.POST("/:id/cash-in", ticketHandler.CashIn)
+	shifts.POST("/:id/cash-out", ticketHandler.CashOut)
+	shifts.GET("/:id/x-report", ticketHandler.GetXReport)
+	shifts.POST("/:id/close", ticketHandler.CloseShift)
+	waitlist := v1.Group("/waitlist")
+	waitlist.POST("", ticketHandler.JoinWaitlist)
+	waitlist.GET("", ticketHandler.ListWaitlist)
+	waitlist.GET("/stats", ticketHandler.GetWaitlistStats)
+	waitlist.GET("/:id", ticketHandler.GetWaitlistEntry)
+	waitlist.DELETE("/:id", ticketHandler.CancelWaitlistEntry)
+	waitlist.GET("/offers/:token", ticketHandler.GetWaitlistOffer)
+	waitlist.POST("/offers/:token/accept", idempotent, ticketHandler.AcceptWaitlistOffer)
+	waitlist.POST("/offers/:token/decline", ticketHandler.DeclineWaitlistOffer)
+	// Внутреннее API: данные с ПД для уведомлений выдаются только сервисам из internal.services
+	internal := v1.Group("/internal", middleware.ServiceAuth(cfg.Internal.JWTSecret, cfg.Internal.Services, logger))
+	internal.GET("/waitlist/:id/notice", ticketHandler.GetWaitlistOfferNotice)
+	internal.GET("/vouchers/:id/notice", ticketHandler.GetVoucherNotice)
+	vouchers := v1.Group("/vouchers")
+	vouchers.POST("", ticketHandler.CreateVoucher)
+	vouchers.GET("", ticketHandler.ListVouchers)
+	vouchers.POST("/check", ticketHandler.CheckVoucher)
+	vouchers.POST("/compensation", ticketHandler.IssueCompensationVouchers)
+	vouchers.GET("/:id", ticketHandler.GetVoucher)
+	vouchers.DELETE("/:id", ticketHandler.DeactivateVoucher)
+	settlements := v1.Group("/settlements")
+	settlements.POST("", ticketHandler.CreateSettlement)
+	settlements.GET("", ticketHandler.ListSettlements)
+	settlements.GET("/:id", ticketHandler.GetSettlement)
+	settlements.POST("/:id/recalculate", ticketHandler.RecalculateSettlement)
+	settlements.POST("/:id/close", ticketHandler.CloseSettlement)
+	settlements.GET("/:id/verify", ticketHandler.VerifySettlement)
+	settlements.GET("/:id/export", ticketHandler.ExportSettlement)
+	settlements.DELETE("/:id", ticketHandler.DeleteSettlement)
+	piiGroup := v1.Group("/pii")
+	piiGroup.POST("/erasure-requests", ticketHandler.CreateErasureRequest)
+	piiGroup.GET("/erasure-requests/:id", ticketHandler.GetErasureRequest)
+	piiGroup.GET("/anonymization-log", ticketHandler.ListAnonymizationLog)
 	boarding := v1.Group("/boarding")
-	boarding.POST("/start", ticketHandler.StartBoarding)
+	boarding.POST("/start", idempotent, ticketHandler.StartBoarding)
+	boarding.POST("/end", ticketHandler.EndBoarding)
 	boarding.POST("/mark", ticketHandler.MarkBoarding)
 	boarding.GET("/status", ticketHandler.GetBoardingStatus)
+	boarding.GET("/manifest", ticketHandler.GetBoardingManifest)
+	boarding.POST("/sync", ticketHandler.SyncBoarding)
+	boarding.POST("/marks/cancel", ticketHandler.CancelBoardingMark)
+	boarding.POST("/marks/correct", ticketHandler.CorrectBoardingMark)
+	boarding.GET("/corrections", ticketHandler.ListBoardingCorrections)
+	driverSales := v1.Group("/driver-sales")
+	driverSales.GET("/manifest", ticketHandler.GetDriverManifest)
+	driverSales.POST("/sync", ticketHandler.SyncDriverSales)
+	driverSales.GET("/reconciliation", ticketHandler.GetDriverSalesReconciliation)
 
 	// Создать HTTP сервер
 	srv := &http.Server{
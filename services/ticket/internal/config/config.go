@@ -4,17 +4,28 @@ package config
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 // Config — корневая конфигурация сервиса.
 type Config struct {
-	NATS     NATSConfig     `mapstructure:"nats"`
-	Server   ServerConfig   `mapstructure:"server"`
-	Logger   LoggerConfig   `mapstructure:"logger"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Business BusinessConfig `mapstructure:"business"`
+	NATS        NATSConfig        `mapstructure:"nats"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Logger      LoggerConfig      `mapstructure:"logger"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Waitlist    WaitlistConfig    `mapstructure:"waitlist"`
+	Internal    InternalConfig    `mapstructure:"internal"`
+	Settlements SettlementsConfig `mapstructure:"settlements"`
+	Consumer    ConsumerConfig    `mapstructure:"consumer"`
+	PII         PIIConfig         `mapstructure:"pii"`
+	Business    BusinessConfig    `mapstructure:"business"`
+	Reports     ReportsConfig     `mapstructure:"reports"`
+	Outbox      OutboxConfig      `mapstructure:"outbox"`
+	QR          QRConfig          `mapstructure:"qr"`
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+	Vouchers    VouchersConfig    `mapstructure:"vouchers"`
 }
 
 // ServerConfig — настройки HTTP-сервера.
@@ -45,12 +56,161 @@ type LoggerConfig struct {
 	Level string `mapstructure:"level"`
 }
 
+// PIIConfig — шифрование персональных данных пассажиров.
+// Keys — kid → ключ AES-256 (base64); новые записи шифруются ActiveKey, прежние ключи нужны для чтения,
+// пока фоновая задача (раз в ReencryptInterval, по ReencryptBatch билетов) не перешифрует записи.
+// IndexKey — ключ HMAC слепых индексов; его смена требует пересчёта индексов.
+// ПД обезличиваются через RetentionPeriod после даты рейса (проверка раз в RetentionInterval).
+type PIIConfig struct {
+	Keys              map[string]string `mapstructure:"keys"`
+	ActiveKey         string            `mapstructure:"active_key"`
+	IndexKey          string            `mapstructure:"index_key"`
+	ReencryptInterval time.Duration     `mapstructure:"reencrypt_interval"`
+	ReencryptBatch    int               `mapstructure:"reencrypt_batch"`
+	RetentionPeriod   time.Duration     `mapstructure:"retention_period"`
+	RetentionInterval time.Duration     `mapstructure:"retention_interval"`
+	RetentionBatch    int               `mapstructure:"retention_batch"`
+}
+
+// QRConfig — подпись QR-кодов билетов.
+// Код действует до отправления плюс ValidAfterDeparture (DefaultValidity — если время отправления неизвестно);
+// ключ ротируется раз в RotationInterval, выведенный ключ публикуется ещё RetiredKeyTTL.
+type QRConfig struct {
+	ValidAfterDeparture time.Duration `mapstructure:"valid_after_departure"`
+	DefaultValidity     time.Duration `mapstructure:"default_validity"`
+	RotationInterval    time.Duration `mapstructure:"rotation_interval"`
+	RetiredKeyTTL       time.Duration `mapstructure:"retired_key_ttl"`
+}
+
+// ReportsConfig — отчёты о продажах. Период до SyncMaxPeriod строится сразу, больший (до MaxPeriod) —
+// фоновым заданием: задания проверяются раз в JobInterval, задание в работе дольше JobTimeout
+// перезапускается, готовый файл хранится ResultTTL.
+type ReportsConfig struct {
+	SyncMaxPeriod time.Duration `mapstructure:"sync_max_period"`
+	MaxPeriod     time.Duration `mapstructure:"max_period"`
+	JobInterval   time.Duration `mapstructure:"job_interval"`
+	JobTimeout    time.Duration `mapstructure:"job_timeout"`
+	ResultTTL     time.Duration `mapstructure:"result_ttl"`
+}
+
+// WaitlistConfig — лист ожидания на распроданные рейсы. Освободившееся место предлагается следующему
+// в очереди на OfferTTL; истёкшие предложения проверяются раз в CheckInterval. OfferURL — ссылка на
+// выкуп билета, {token} в ней заменяется кодом предложения.
+type WaitlistConfig struct {
+	OfferURL      string        `mapstructure:"offer_url"`
+	OfferTTL      time.Duration `mapstructure:"offer_ttl"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+}
+
+// InternalConfig — внутреннее API для других сервисов (/v1/internal): контакты пассажиров для уведомлений.
+// Запрос подписывается JWT с секретом JWTSecret, ролью "service" и именем сервиса из Services.
+type InternalConfig struct {
+	JWTSecret string   `mapstructure:"jwt_secret"`
+	Services  []string `mapstructure:"services"`
+}
+
+// VouchersConfig — компенсационные ваучеры пассажирам рейсов, отменённых перевозчиком: скидка
+// CompensationRate от цены билета, ваучер действует CompensationValidity. Нулевая доля — ваучеры не выпускаются.
+type VouchersConfig struct {
+	CompensationRate     float64       `mapstructure:"compensation_rate"`
+	CompensationValidity time.Duration `mapstructure:"compensation_validity"`
+}
+
+// SettlementsConfig — расчёты с перевозчиками. При AutoMonthly черновики расчётов за прошедший
+// календарный месяц формируются сами (проверка раз в CheckInterval). FontPath — TTF-шрифт с кириллицей
+// для выгрузки в PDF.
+type SettlementsConfig struct {
+	FontPath      string        `mapstructure:"font_path"`
+	CheckInterval time.Duration `mapstructure:"check_interval"`
+	AutoMonthly   bool          `mapstructure:"auto_monthly"`
+}
+
+// IdempotencyConfig — ключи Idempotency-Key для продажи, возврата и начала посадки.
+// Ответ хранится TTL, выполняемый запрос занимает ключ не дольше LockTimeout;
+// Required — отклонять запросы без ключа.
+type IdempotencyConfig struct {
+	TTL         time.Duration `mapstructure:"ttl"`
+	LockTimeout time.Duration `mapstructure:"lock_timeout"`
+	Required    bool          `mapstructure:"required"`
+}
+
+// ConsumerConfig — durable-консьюмер JetStream (см. eventbus.ConsumerConfig в go-common).
+type ConsumerConfig struct {
+	Backoff    []time.Duration `mapstructure:"backoff"`
+	AckWait    time.Duration   `mapstructure:"ack_wait"`
+	MaxDeliver int             `mapstructure:"max_deliver"`
+}
+
+// OutboxConfig — доставка событий из outbox в NATS. Outbox проверяется раз в Interval, за проход
+// отправляется до BatchSize событий; повтор откладывается вдвое дольше, но не более MaxBackoff.
+// Доставленные события хранятся Retention.
+type OutboxConfig struct {
+	Interval     time.Duration `mapstructure:"interval"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	Retention    time.Duration `mapstructure:"retention"`
+	FlushTimeout time.Duration `mapstructure:"flush_timeout"`
+	BatchSize    int           `mapstructure:"batch_size"`
+}
+
 // BusinessConfig — бизнес-настройки (штрафы за возврат и т.п.).
 type BusinessConfig struct {
+	DriverSales   DriverSalesConfig   `mapstructure:"driver_sales"`
+	Fare          FareConfig          `mapstructure:"fare"`
+	Baggage       BaggageConfig       `mapstructure:"baggage"`
 	RefundPenalty RefundPenaltyConfig `mapstructure:"refund_penalty"`
+	Exchange      ExchangeConfig      `mapstructure:"exchange"`
+	Sales         SalesConfig         `mapstructure:"sales"`
+}
+
+// DriverSalesConfig — продажа билетов водителем в пути с мобильного устройства.
+type DriverSalesConfig struct {
+	KKT MobileKKTConfig `mapstructure:"kkt"`
+}
+
+// MobileKKTConfig — профиль мобильной ККТ водителя: реквизиты организации, система налогообложения
+// и место расчётов, с которыми устройство печатает чеки без связи с сервером.
+type MobileKKTConfig struct {
+	CompanyINN   string `mapstructure:"company_inn" json:"company_inn"`
+	CompanyName  string `mapstructure:"company_name" json:"company_name"`
+	TaxSystem    string `mapstructure:"tax_system" json:"tax_system"`
+	PaymentPlace string `mapstructure:"payment_place" json:"payment_place"`
+}
+
+// SalesConfig — окна продаж по умолчанию для кассы (Counter — продажа в кассовой смене) и онлайн-продаж;
+// для рейса их можно переопределить настройками продажи рейса.
+type SalesConfig struct {
+	Online  SalesWindowConfig `mapstructure:"online"`
+	Counter SalesWindowConfig `mapstructure:"counter"`
+}
+
+// SalesWindowConfig — продажа открывается за OpenDaysBefore дней (0 — без ограничения) и закрывается
+// за CloseMinutesBefore минут до отправления с остановки посадки.
+type SalesWindowConfig struct {
+	OpenDaysBefore     int `mapstructure:"open_days_before"`
+	CloseMinutesBefore int `mapstructure:"close_minutes_before"`
+}
+
+// FareConfig — тариф перевозчика в чеке: VAT — ставка НДС позиции в кодах ККТ ("none", "vat0", "vat22"…).
+// Сборы вокзала и страховые сборы настраиваются правилами в БД (fare_component_rules).
+type FareConfig struct {
+	VAT string `mapstructure:"vat"`
+}
+
+// ExchangeConfig — сбор за обмен билета: фиксированная часть плюс доля от стоимости исходного билета.
+type ExchangeConfig struct {
+	FeeFixed float64 `mapstructure:"fee_fixed"`
+	FeeRate  float64 `mapstructure:"fee_rate"`
+}
+
+// BaggageConfig — тарифы на провоз багажа.
+type BaggageConfig struct {
+	// Tariffs — стоимость одного места багажа по весовой категории (ключ — код категории).
+	Tariffs   map[string]float64 `mapstructure:"tariffs"`
+	MaxPieces int                `mapstructure:"max_pieces"`
 }
 
 // RefundPenaltyConfig — коэффициенты штрафа за возврат по времени до отправления.
+// Используются как политика по умолчанию, если в БД нет действующей политики возврата для рейса.
 type RefundPenaltyConfig struct {
 	Over24Hours  float64 `mapstructure:"over_24_hours"`
 	Between12_24 float64 `mapstructure:"between_12_24"`
@@ -83,6 +243,62 @@ func Load() (*Config, error) {
 	viper.SetDefault("business.refund_penalty.over_24_hours", 0.10)
 	viper.SetDefault("business.refund_penalty.between_12_24", 0.20)
 	viper.SetDefault("business.refund_penalty.under_12_hours", 0.30)
+	viper.SetDefault("business.exchange.fee_fixed", 100.0)
+	viper.SetDefault("business.exchange.fee_rate", 0.0)
+	viper.SetDefault("business.fare.vat", "none")
+	viper.SetDefault("business.sales.online.open_days_before", 45)
+	viper.SetDefault("business.sales.online.close_minutes_before", 30)
+	viper.SetDefault("business.sales.counter.open_days_before", 45)
+	viper.SetDefault("business.sales.counter.close_minutes_before", 0)
+	viper.SetDefault("business.driver_sales.kkt.company_inn", "1234567890")
+	viper.SetDefault("business.driver_sales.kkt.company_name", "ООО «Вокзал.ТЕХ»")
+	viper.SetDefault("business.driver_sales.kkt.tax_system", "osn")
+	viper.SetDefault("business.driver_sales.kkt.payment_place", "Автобус, продажа в пути")
+	// Ключи для локальной разработки; в окружениях задаются через конфигурацию/секреты
+	viper.SetDefault("pii.keys", map[string]string{"dev1": "w1PD949mNPkAqfb4TmIzLK2FA/T7Q5HrQZHKHFI2CR4="})
+	viper.SetDefault("pii.active_key", "dev1")
+	viper.SetDefault("pii.index_key", "wKidY27OpRox8hRcwCaJdx/b4uCxig46eVjLGkve7VI=")
+	viper.SetDefault("pii.reencrypt_interval", "10m")
+	viper.SetDefault("pii.reencrypt_batch", 500)
+	viper.SetDefault("pii.retention_period", "8760h")
+	viper.SetDefault("pii.retention_interval", "1h")
+	viper.SetDefault("pii.retention_batch", 500)
+	viper.SetDefault("qr.valid_after_departure", "12h")
+	viper.SetDefault("qr.default_validity", "720h")
+	viper.SetDefault("qr.rotation_interval", "720h")
+	viper.SetDefault("qr.retired_key_ttl", "2160h")
+	viper.SetDefault("reports.sync_max_period", "744h")
+	viper.SetDefault("reports.max_period", "26352h")
+	viper.SetDefault("reports.job_interval", "10s")
+	viper.SetDefault("reports.job_timeout", "1h")
+	viper.SetDefault("reports.result_ttl", "168h")
+	viper.SetDefault("waitlist.offer_url", "https://vokzal.tech/waitlist/offers/{token}")
+	viper.SetDefault("waitlist.offer_ttl", "30m")
+	viper.SetDefault("waitlist.check_interval", "1m")
+	viper.SetDefault("vouchers.compensation_rate", 0.2)
+	viper.SetDefault("vouchers.compensation_validity", "4320h")
+	viper.SetDefault("settlements.font_path", "/usr/share/fonts/dejavu/DejaVuSans.ttf")
+	viper.SetDefault("settlements.check_interval", "1h")
+	viper.SetDefault("settlements.auto_monthly", true)
+	viper.SetDefault("idempotency.ttl", "24h")
+	viper.SetDefault("idempotency.lock_timeout", "1m")
+	viper.SetDefault("idempotency.required", false)
+	viper.SetDefault("internal.jwt_secret", "vokzal_internal_secret_change_in_production")
+	viper.SetDefault("internal.services", []string{"notify"})
+	viper.SetDefault("consumer.backoff", []string{"5s", "30s", "2m", "10m", "30m"})
+	viper.SetDefault("consumer.ack_wait", "1m")
+	viper.SetDefault("consumer.max_deliver", 10)
+	viper.SetDefault("outbox.interval", "1s")
+	viper.SetDefault("outbox.max_backoff", "5m")
+	viper.SetDefault("outbox.retention", "72h")
+	viper.SetDefault("outbox.flush_timeout", "5s")
+	viper.SetDefault("outbox.batch_size", 100)
+	viper.SetDefault("business.baggage.max_pieces", 5)
+	viper.SetDefault("business.baggage.tariffs", map[string]float64{
+		"up_to_10kg": 100,
+		"up_to_20kg": 200,
+		"up_to_30kg": 300,
+	})
 
 	if err := viper.ReadInConfig(); err != nil {
 		var notFound viper.ConfigFileNotFoundError
@@ -4,12 +4,19 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/ticketqr"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
 	"github.com/vokzal-tech/ticket-service/internal/service"
 )
 
@@ -27,6 +34,28 @@ func NewTicketHandler(svc service.TicketService, logger *zap.Logger) *TicketHand
 	}
 }
 
+// requestUserID возвращает user_id из контекста (middleware) или заголовка X-User-ID
+// (API Gateway после аутентификации); по умолчанию — "system".
+func requestUserID(c *gin.Context) string {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		userID = c.GetHeader("X-User-ID")
+	}
+	if userID == "" {
+		userID = "system"
+	}
+	return userID
+}
+
+// requestRole возвращает роль пользователя из контекста (middleware) или заголовка X-User-Role.
+func requestRole(c *gin.Context) string {
+	role := c.GetString("role")
+	if role == "" {
+		role = c.GetHeader("X-User-Role")
+	}
+	return role
+}
+
 // SellTicket продаёт билет.
 func (h *TicketHandler) SellTicket(c *gin.Context) {
 	var req service.SellTicketRequest
@@ -34,15 +63,29 @@ func (h *TicketHandler) SellTicket(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
 
 	ticket, err := h.svc.SellTicket(c.Request.Context(), &req)
 	if err != nil {
 		h.logger.Error("Failed to sell ticket", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrShiftRequired), errors.Is(err, repository.ErrSeatAlreadyTaken),
+			errors.Is(err, service.ErrSeatHeld):
+			status = http.StatusConflict
+		case errors.Is(err, repository.ErrVoucherNotFound), errors.Is(err, service.ErrVoucherNotApplicable):
+			status = http.StatusUnprocessableEntity
+		}
+		if code, saleStatus := saleRejection(err); code != "" {
+			c.JSON(saleStatus, gin.H{"error": err.Error(), "code": code})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"data": ticket})
+	c.JSON(http.StatusCreated, gin.H{"data": service.MaskTicket(ticket, req.Role)})
 }
 
 // GetTicket возвращает билет по ID.
@@ -54,7 +97,7 @@ func (h *TicketHandler) GetTicket(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": ticket})
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskTicket(ticket, requestRole(c))})
 }
 
 // GetTicketByQR возвращает билет по QR-коду.
@@ -67,11 +110,40 @@ func (h *TicketHandler) GetTicketByQR(c *gin.Context) {
 
 	ticket, err := h.svc.GetTicketByQR(c.Request.Context(), qrCode)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		switch {
+		case errors.Is(err, ticketqr.ErrExpired), errors.Is(err, ticketqr.ErrNotYetValid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ticket not found"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskTicket(ticket, requestRole(c))})
+}
+
+// GetQRKeySet возвращает открытые ключи (JWK) для офлайн-проверки QR-кодов.
+func (h *TicketHandler) GetQRKeySet(c *gin.Context) {
+	keys, err := h.svc.GetQRKeySet(c.Request.Context())
+	if err != nil {
+		h.logger.Error("Failed to get QR keys", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get QR keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RotateQRKey выпускает новый ключ подписи QR-кодов.
+func (h *TicketHandler) RotateQRKey(c *gin.Context) {
+	key, err := h.svc.RotateQRKey(c.Request.Context(), requestUserID(c))
+	if err != nil {
+		h.logger.Error("Failed to rotate QR key", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate QR key"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": ticket})
+	c.JSON(http.StatusOK, gin.H{"message": "QR signing key rotated", "data": key})
 }
 
 // ListTicketsByTrip возвращает список билетов на рейс.
@@ -89,26 +161,85 @@ func (h *TicketHandler) ListTicketsByTrip(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": tickets})
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskTickets(tickets, requestRole(c))})
 }
 
-// RefundTicket возвращает билет.
-func (h *TicketHandler) RefundTicket(c *gin.Context) {
-	ticketID := c.Param("id")
+// LookupTickets ищет билеты по номеру документа, телефону или email (точное совпадение).
+func (h *TicketHandler) LookupTickets(c *gin.Context) {
+	var req service.TicketLookupRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// user_id из контекста (middleware) или заголовка X-User-ID (API Gateway после аутентификации)
-	userID := c.GetString("user_id")
-	if userID == "" {
-		userID = c.GetHeader("X-User-ID")
+	tickets, err := h.svc.LookupTickets(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLookup) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to look up tickets", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up tickets"})
+		return
 	}
-	if userID == "" {
-		userID = "system"
+
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskTickets(tickets, requestRole(c))})
+}
+
+// SearchTickets ищет билеты по ФИО (нечётко), документу, телефону, email, коду брони,
+// датам рейса и станции с постраничной выдачей. ПД в ответе маскируются по роли.
+func (h *TicketHandler) SearchTickets(c *gin.Context) {
+	var req service.TicketSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Role = requestRole(c)
+
+	result, err := h.svc.SearchTickets(c.Request.Context(), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSearchForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, service.ErrInvalidSearch), errors.Is(err, service.ErrPassengerCriterionRequired):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.Error("Failed to search tickets", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search tickets"})
+		}
+		return
+	}
+
+	result.Tickets = service.MaskTickets(result.Tickets, req.Role)
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RefundTicket возвращает билет. Тело запроса необязательно (причина возврата и документ).
+func (h *TicketHandler) RefundTicket(c *gin.Context) {
+	var req service.RefundTicketRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
+	req.TicketID = c.Param("id")
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
 
-	result, err := h.svc.RefundTicket(c.Request.Context(), ticketID, userID)
+	result, err := h.svc.RefundTicket(c.Request.Context(), &req)
 	if err != nil {
 		h.logger.Error("Failed to refund ticket", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, repository.ErrTicketNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrRefundNotAllowed), errors.Is(err, service.ErrTicketAlreadyUsed),
+			errors.Is(err, repository.ErrBoardingAlreadyStarted), errors.Is(err, service.ErrShiftRequired),
+			errors.Is(err, repository.ErrTicketNotActive):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -118,6 +249,344 @@ func (h *TicketHandler) RefundTicket(c *gin.Context) {
 	})
 }
 
+// ExchangeTicket обменивает билет на другой рейс или место.
+func (h *TicketHandler) ExchangeTicket(c *gin.Context) {
+	var req service.ExchangeTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.TicketID = c.Param("id")
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	result, err := h.svc.ExchangeTicket(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to exchange ticket", zap.Error(err))
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, repository.ErrTicketNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, repository.ErrSeatAlreadyTaken), errors.Is(err, service.ErrShiftRequired),
+			errors.Is(err, repository.ErrTicketNotActive):
+			status = http.StatusConflict
+		}
+		if code, saleStatus := saleRejection(err); code != "" {
+			c.JSON(saleStatus, gin.H{"error": err.Error(), "code": code})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	result.Ticket = service.MaskTicket(result.Ticket, req.Role)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Ticket exchanged successfully",
+		"data":    result,
+	})
+}
+
+// SellBaggage оформляет багажную квитанцию к билету.
+func (h *TicketHandler) SellBaggage(c *gin.Context) {
+	var req service.SellBaggageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	baggage, err := h.svc.SellBaggage(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to sell baggage", zap.Error(err))
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrUnknownWeightClass), errors.Is(err, service.ErrTooManyPieces):
+			status = http.StatusBadRequest
+		case errors.Is(err, repository.ErrTicketNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrShiftRequired), errors.Is(err, repository.ErrBoardingClosed):
+			status = http.StatusConflict
+		}
+		if code, saleStatus := saleRejection(err); code != "" {
+			c.JSON(saleStatus, gin.H{"error": err.Error(), "code": code})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": baggage})
+}
+
+// GetBaggage возвращает багажную квитанцию по ID.
+func (h *TicketHandler) GetBaggage(c *gin.Context) {
+	id := c.Param("id")
+	baggage, err := h.svc.GetBaggage(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Baggage ticket not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": baggage})
+}
+
+// ListBaggageByTicket возвращает багажные квитанции по билету пассажира.
+func (h *TicketHandler) ListBaggageByTicket(c *gin.Context) {
+	ticketID := c.Query("ticket_id")
+	if ticketID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ticket_id is required"})
+		return
+	}
+
+	baggage, err := h.svc.ListBaggageByTicket(c.Request.Context(), ticketID)
+	if err != nil {
+		h.logger.Error("Failed to list baggage", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list baggage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": baggage})
+}
+
+// RefundBaggage возвращает багажную квитанцию.
+func (h *TicketHandler) RefundBaggage(c *gin.Context) {
+	result, err := h.svc.RefundBaggage(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		h.logger.Error("Failed to refund baggage", zap.Error(err))
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrShiftRequired) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Baggage refunded successfully",
+		"data":    result,
+	})
+}
+
+// CreateRefundPolicy создаёт новую версию политики возврата.
+func (h *TicketHandler) CreateRefundPolicy(c *gin.Context) {
+	var req service.CreateRefundPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	policy, err := h.svc.CreateRefundPolicy(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to create refund policy", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidRefundPolicy) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": policy})
+}
+
+// ListRefundPolicies возвращает версии политик возврата (active_only=true — только действующие).
+func (h *TicketHandler) ListRefundPolicies(c *gin.Context) {
+	policies, err := h.svc.ListRefundPolicies(c.Request.Context(), c.Query("active_only") == "true")
+	if err != nil {
+		h.logger.Error("Failed to list refund policies", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list refund policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": policies})
+}
+
+// GetRefundPolicy возвращает версию политики возврата по ID.
+func (h *TicketHandler) GetRefundPolicy(c *gin.Context) {
+	policy, err := h.svc.GetRefundPolicy(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Refund policy not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": policy})
+}
+
+// DeactivateRefundPolicy выводит версию политики возврата из действия.
+func (h *TicketHandler) DeactivateRefundPolicy(c *gin.Context) {
+	if err := h.svc.DeactivateRefundPolicy(c.Request.Context(), c.Param("id"), requestUserID(c)); err != nil {
+		h.logger.Error("Failed to deactivate refund policy", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrRefundPolicyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Refund policy deactivated"})
+}
+
+// CreateFareComponentRule создаёт правило начисления сбора вокзала или страхового сбора к тарифу.
+func (h *TicketHandler) CreateFareComponentRule(c *gin.Context) {
+	var req service.CreateFareComponentRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	rule, err := h.svc.CreateFareComponentRule(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to create fare component rule", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidFareComponentRule) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": rule})
+}
+
+// ListFareComponentRules возвращает правила начисления сборов (active_only=true — только действующие).
+func (h *TicketHandler) ListFareComponentRules(c *gin.Context) {
+	rules, err := h.svc.ListFareComponentRules(c.Request.Context(), c.Query("active_only") == "true")
+	if err != nil {
+		h.logger.Error("Failed to list fare component rules", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list fare component rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// GetFareComponentRule возвращает правило начисления сбора по ID.
+func (h *TicketHandler) GetFareComponentRule(c *gin.Context) {
+	rule, err := h.svc.GetFareComponentRule(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fare component rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// DeactivateFareComponentRule выводит правило начисления сбора из действия.
+func (h *TicketHandler) DeactivateFareComponentRule(c *gin.Context) {
+	if err := h.svc.DeactivateFareComponentRule(c.Request.Context(), c.Param("id"), requestUserID(c)); err != nil {
+		h.logger.Error("Failed to deactivate fare component rule", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrFareComponentRuleNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Fare component rule deactivated"})
+}
+
+// saleRejection возвращает код отказа в продаже (поле code ответа) и HTTP-статус для отказов по окну
+// продаж, состоянию рейса и квоте остановки; для остальных ошибок код пустой.
+func saleRejection(err error) (string, int) {
+	switch {
+	case errors.Is(err, service.ErrSalesNotOpen):
+		return "sales_not_open", http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrSalesClosed):
+		return "sales_closed", http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrTripNotOnSale):
+		return "trip_not_on_sale", http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrStationNotOnRoute):
+		return "station_not_on_route", http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrStopQuotaExceeded):
+		return "stop_quota_exceeded", http.StatusConflict
+	case errors.Is(err, service.ErrVehicleNotOnTrip):
+		return "vehicle_not_on_trip", http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrTripNotFound):
+		return "trip_not_found", http.StatusNotFound
+	default:
+		return "", 0
+	}
+}
+
+// GetSalesSettings возвращает окна продаж и квоты остановок рейса.
+func (h *TicketHandler) GetSalesSettings(c *gin.Context) {
+	status, err := h.svc.GetSalesSettings(c.Request.Context(), c.Param("trip_id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		h.logger.Error("Failed to get sales settings", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sales settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// UpdateSalesSettings задаёт окна продаж и квоты остановок рейса.
+func (h *TicketHandler) UpdateSalesSettings(c *gin.Context) {
+	var req service.UpdateSalesSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.TripID = c.Param("trip_id")
+	req.UserID = requestUserID(c)
+
+	settings, err := h.svc.UpdateSalesSettings(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to update sales settings", zap.Error(err))
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, repository.ErrTripNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, service.ErrInvalidSalesSettings), errors.Is(err, service.ErrStationNotOnRoute):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settings})
+}
+
+// DeleteSalesSettings сбрасывает настройки продажи рейса к значениям из конфигурации.
+func (h *TicketHandler) DeleteSalesSettings(c *gin.Context) {
+	if err := h.svc.DeleteSalesSettings(c.Request.Context(), c.Param("trip_id"), requestUserID(c)); err != nil {
+		h.logger.Error("Failed to delete sales settings", zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, repository.ErrSalesSettingsNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trip sales settings reset"})
+}
+
+// ListTripVehicles возвращает автобусы рейса со схемой занятых мест каждого.
+func (h *TicketHandler) ListTripVehicles(c *gin.Context) {
+	vehicles, err := h.svc.ListTripVehicles(c.Request.Context(), c.Param("trip_id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		h.logger.Error("Failed to list trip vehicles", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list trip vehicles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": vehicles})
+}
+
 // StartBoarding начинает посадку.
 func (h *TicketHandler) StartBoarding(c *gin.Context) {
 	var req struct {
@@ -129,14 +598,7 @@ func (h *TicketHandler) StartBoarding(c *gin.Context) {
 		return
 	}
 
-	// user_id из контекста (middleware) или заголовка X-User-ID (API Gateway после аутентификации)
-	userID := c.GetString("user_id")
-	if userID == "" {
-		userID = c.GetHeader("X-User-ID")
-	}
-	if userID == "" {
-		userID = "system"
-	}
+	userID := requestUserID(c)
 
 	if err := h.svc.StartBoarding(c.Request.Context(), req.TripID, userID); err != nil {
 		h.logger.Error("Failed to start boarding", zap.Error(err))
@@ -147,6 +609,34 @@ func (h *TicketHandler) StartBoarding(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Boarding started successfully"})
 }
 
+// EndBoarding завершает посадку и возвращает итог.
+func (h *TicketHandler) EndBoarding(c *gin.Context) {
+	var req struct {
+		TripID string `json:"trip_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := h.svc.EndBoarding(c.Request.Context(), req.TripID, requestUserID(c))
+	if err != nil {
+		h.logger.Error("Failed to end boarding", zap.Error(err))
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, repository.ErrBoardingNotStarted):
+			status = http.StatusBadRequest
+		case errors.Is(err, repository.ErrBoardingClosed):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Boarding closed successfully", "data": summary})
+}
+
 // MarkBoarding отмечает посадку пассажира.
 func (h *TicketHandler) MarkBoarding(c *gin.Context) {
 	var req service.MarkBoardingRequest
@@ -157,7 +647,11 @@ func (h *TicketHandler) MarkBoarding(c *gin.Context) {
 
 	if err := h.svc.MarkBoarding(c.Request.Context(), &req); err != nil {
 		h.logger.Error("Failed to mark boarding", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		if errors.Is(err, service.ErrWrongVehicle) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -174,6 +668,10 @@ func (h *TicketHandler) GetBoardingStatus(c *gin.Context) {
 
 	status, err := h.svc.GetBoardingStatus(c.Request.Context(), tripID)
 	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
 		h.logger.Error("Failed to get boarding status", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get boarding status"})
 		return
@@ -182,23 +680,950 @@ func (h *TicketHandler) GetBoardingStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": status})
 }
 
-// GetDashboardStats возвращает статистику билетов за дату для дашборда.
-func (h *TicketHandler) GetDashboardStats(c *gin.Context) {
-	date := c.Query("date")
-	if date == "" {
-		date = time.Now().Format("2006-01-02")
+// GetBoardingManifest возвращает манифест рейса для офлайн-посадки.
+func (h *TicketHandler) GetBoardingManifest(c *gin.Context) {
+	tripID := c.Query("trip_id")
+	if tripID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "trip_id is required"})
+		return
 	}
-	ticketsSold, ticketsReturned, revenue, err := h.svc.GetDashboardStats(c.Request.Context(), date)
+
+	manifest, err := h.svc.GetBoardingManifest(c.Request.Context(), tripID)
 	if err != nil {
-		h.logger.Error("Failed to get dashboard stats", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard stats"})
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		h.logger.Error("Failed to get boarding manifest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get boarding manifest"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
-		"data": gin.H{
-			"tickets_sold":     ticketsSold,
-			"tickets_returned": ticketsReturned,
-			"revenue":          revenue,
-		},
-	})
+
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskManifest(manifest, requestRole(c))})
+}
+
+// SyncBoarding принимает пакет отметок посадки, собранных без связи.
+func (h *TicketHandler) SyncBoarding(c *gin.Context) {
+	var req service.SyncBoardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.svc.SyncBoarding(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if errors.Is(err, service.ErrVehicleNotOnTrip) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to sync boarding marks", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync boarding marks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// CancelBoardingMark отменяет отметку посадки (только старший смены).
+func (h *TicketHandler) CancelBoardingMark(c *gin.Context) {
+	var req service.CancelBoardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	correction, err := h.svc.CancelBoardingMark(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to cancel boarding mark", zap.Error(err))
+		c.JSON(boardingCorrectionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Boarding mark cancelled", "data": correction})
+}
+
+// CorrectBoardingMark пересаживает отмеченного пассажира (только старший смены).
+func (h *TicketHandler) CorrectBoardingMark(c *gin.Context) {
+	var req service.CorrectBoardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	correction, err := h.svc.CorrectBoardingMark(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to correct boarding mark", zap.Error(err))
+		c.JSON(boardingCorrectionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Boarding mark corrected", "data": correction})
+}
+
+// ListBoardingCorrections возвращает журнал отмен и исправлений отметок по билету.
+func (h *TicketHandler) ListBoardingCorrections(c *gin.Context) {
+	ticketID := c.Query("ticket_id")
+	if ticketID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ticket_id is required"})
+		return
+	}
+
+	corrections, err := h.svc.ListBoardingCorrections(c.Request.Context(), ticketID)
+	if err != nil {
+		h.logger.Error("Failed to list boarding corrections", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list boarding corrections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": corrections})
+}
+
+func boardingCorrectionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrSupervisorRequired):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrTicketNotFound), errors.Is(err, repository.ErrBoardingMarkNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrVehicleNotOnTrip):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, repository.ErrSeatAlreadyTaken), errors.Is(err, repository.ErrBoardingClosed):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// GetDriverManifest возвращает манифест автобуса рейса для продажи водителем без связи.
+func (h *TicketHandler) GetDriverManifest(c *gin.Context) {
+	var query struct {
+		TripID        string `form:"trip_id" binding:"required"`
+		VehicleNumber int    `form:"vehicle_number" binding:"omitempty,min=1"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	manifest, err := h.svc.GetDriverManifest(c.Request.Context(), query.TripID, query.VehicleNumber)
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if errors.Is(err, service.ErrVehicleNotOnTrip) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to get driver manifest", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get driver manifest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": manifest})
+}
+
+// SyncDriverSales принимает пакет продаж, оформленных водителем в пути.
+func (h *TicketHandler) SyncDriverSales(c *gin.Context) {
+	var req service.SyncDriverSalesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	result, err := h.svc.SyncDriverSales(c.Request.Context(), &req)
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		if errors.Is(err, service.ErrVehicleNotOnTrip) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.Error("Failed to sync driver sales", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sync driver sales"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// GetDriverSalesReconciliation возвращает сверку продаж водителей с манифестом рейса.
+func (h *TicketHandler) GetDriverSalesReconciliation(c *gin.Context) {
+	tripID := c.Query("trip_id")
+	if tripID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "trip_id is required"})
+		return
+	}
+
+	reconciliation, err := h.svc.GetDriverSalesReconciliation(c.Request.Context(), tripID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTripNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Trip not found"})
+			return
+		}
+		h.logger.Error("Failed to reconcile driver sales", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile driver sales"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reconciliation})
+}
+
+// GetDashboardStats возвращает статистику билетов за дату для дашборда.
+func (h *TicketHandler) GetDashboardStats(c *gin.Context) {
+	date := c.Query("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	ticketsSold, ticketsReturned, revenue, err := h.svc.GetDashboardStats(c.Request.Context(), date)
+	if err != nil {
+		h.logger.Error("Failed to get dashboard stats", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard stats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"tickets_sold":     ticketsSold,
+			"tickets_returned": ticketsReturned,
+			"revenue":          revenue,
+		},
+	})
+}
+
+// CreateErasureRequest удаляет ПД пассажира по запросу субъекта (роль supervisor/admin).
+func (h *TicketHandler) CreateErasureRequest(c *gin.Context) {
+	var req service.ErasureRequestInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	details, err := h.svc.CreateErasureRequest(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to erase passenger data", zap.Error(err))
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, service.ErrSupervisorRequired):
+			status = http.StatusForbidden
+		case errors.Is(err, service.ErrInvalidLookup):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": details})
+}
+
+// GetErasureRequest возвращает запрос на удаление ПД с журналом обезличивания.
+func (h *TicketHandler) GetErasureRequest(c *gin.Context) {
+	details, err := h.svc.GetErasureRequest(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, repository.ErrErasureRequestNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Erasure request not found"})
+			return
+		}
+		h.logger.Error("Failed to get erasure request", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get erasure request"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": details})
+}
+
+// ListAnonymizationLog возвращает журнал обезличивания по записи (entity_id — билет, уведомление, документ).
+func (h *TicketHandler) ListAnonymizationLog(c *gin.Context) {
+	entityID := c.Query("entity_id")
+	if entityID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "entity_id is required"})
+		return
+	}
+
+	logs, err := h.svc.ListAnonymizationLog(c.Request.Context(), entityID)
+	if err != nil {
+		h.logger.Error("Failed to list anonymization log", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list anonymization log"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": logs})
+}
+
+// OpenShift открывает смену текущего кассира на рабочем месте.
+func (h *TicketHandler) OpenShift(c *gin.Context) {
+	var req service.OpenShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	shift, err := h.svc.OpenShift(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to open shift", zap.Error(err))
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": shift})
+}
+
+// GetCurrentShift возвращает открытую смену текущего пользователя.
+func (h *TicketHandler) GetCurrentShift(c *gin.Context) {
+	shift, err := h.svc.GetCurrentShift(c.Request.Context(), requestUserID(c))
+	if err != nil {
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shift})
+}
+
+// GetShift возвращает смену по ID.
+func (h *TicketHandler) GetShift(c *gin.Context) {
+	shift, err := h.svc.GetShift(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shift})
+}
+
+// ListShifts возвращает смены (фильтры workstation_id, cashier_id, date — дата открытия).
+func (h *TicketHandler) ListShifts(c *gin.Context) {
+	shifts, err := h.svc.ListShifts(c.Request.Context(), c.Query("workstation_id"), c.Query("cashier_id"), c.Query("date"))
+	if err != nil {
+		h.logger.Error("Failed to list shifts", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list shifts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": shifts})
+}
+
+// ListShiftOperations возвращает движение денег в смене.
+func (h *TicketHandler) ListShiftOperations(c *gin.Context) {
+	ops, err := h.svc.ListShiftOperations(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": ops})
+}
+
+// CashIn вносит наличные в денежный ящик смены.
+func (h *TicketHandler) CashIn(c *gin.Context) {
+	h.cashOperation(c, h.svc.CashIn)
+}
+
+// CashOut изымает наличные из денежного ящика смены.
+func (h *TicketHandler) CashOut(c *gin.Context) {
+	h.cashOperation(c, h.svc.CashOut)
+}
+
+// GetXReport возвращает X-отчёт смены.
+func (h *TicketHandler) GetXReport(c *gin.Context) {
+	report, err := h.svc.GetXReport(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// CloseShift закрывает смену со сверкой наличных.
+func (h *TicketHandler) CloseShift(c *gin.Context) {
+	var req service.CloseShiftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ShiftID = c.Param("id")
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	report, err := h.svc.CloseShift(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to close shift", zap.Error(err))
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Shift closed", "data": report})
+}
+
+// cashOperation — общий обработчик внесения и изъятия наличных.
+func (h *TicketHandler) cashOperation(
+	c *gin.Context,
+	do func(context.Context, *service.CashOperationRequest) (*models.ShiftOperation, error),
+) {
+	var req service.CashOperationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ShiftID = c.Param("id")
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	op, err := do(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to record cash operation", zap.Error(err))
+		c.JSON(shiftErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": op})
+}
+
+// shiftErrorStatus возвращает HTTP-статус для ошибок операций со сменой.
+func shiftErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrShiftNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrShiftForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, repository.ErrShiftAlreadyOpen), errors.Is(err, repository.ErrShiftClosed):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInsufficientCash):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrCashierRequired):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// GetSalesReport строит отчёт о продажах: JSON или файл CSV/XLSX (format).
+func (h *TicketHandler) GetSalesReport(c *gin.Context) {
+	var req service.SalesReportRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	if req.Format == service.ReportFormatCSV || req.Format == service.ReportFormatXLSX {
+		file, err := h.svc.ExportSalesReport(c.Request.Context(), &req)
+		if err != nil {
+			h.logger.Error("Failed to export sales report", zap.Error(err))
+			c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		sendReportFile(c, file)
+		return
+	}
+
+	report, err := h.svc.GetSalesReport(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to build sales report", zap.Error(err))
+		c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": report})
+}
+
+// CreateSalesReportJob ставит в очередь построение отчёта о продажах за большой период.
+func (h *TicketHandler) CreateSalesReportJob(c *gin.Context) {
+	var req service.SalesReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	job, err := h.svc.CreateSalesReportJob(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to create report job", zap.Error(err))
+		c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Report job created", "data": job})
+}
+
+// ListReportJobs возвращает задания текущего пользователя.
+func (h *TicketHandler) ListReportJobs(c *gin.Context) {
+	jobs, err := h.svc.ListReportJobs(c.Request.Context(), requestUserID(c), requestRole(c))
+	if err != nil {
+		c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// GetReportJob возвращает статус задания.
+func (h *TicketHandler) GetReportJob(c *gin.Context) {
+	job, err := h.svc.GetReportJob(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// DownloadReportJob отдаёт файл готового отчёта.
+func (h *TicketHandler) DownloadReportJob(c *gin.Context) {
+	file, err := h.svc.DownloadReportJob(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		c.JSON(reportErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	sendReportFile(c, file)
+}
+
+// sendReportFile отдаёт файл отчёта как вложение.
+func sendReportFile(c *gin.Context, file *service.ReportFile) {
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", file.Name))
+	c.Data(http.StatusOK, file.ContentType, file.Data)
+}
+
+func reportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrReportJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrReportForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidReport):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrReportPeriodTooLong):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrReportNotReady):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// JoinWaitlist ставит пассажира в лист ожидания рейса.
+func (h *TicketHandler) JoinWaitlist(c *gin.Context) {
+	var req service.JoinWaitlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	entry, err := h.svc.JoinWaitlist(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to join waitlist", zap.Error(err))
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": service.MaskWaitlistEntry(entry, requestRole(c))})
+}
+
+// ListWaitlist возвращает лист ожидания рейса (trip_id обязателен, status — фильтр).
+func (h *TicketHandler) ListWaitlist(c *gin.Context) {
+	tripID := c.Query("trip_id")
+	if tripID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "trip_id is required"})
+		return
+	}
+
+	entries, err := h.svc.ListWaitlist(c.Request.Context(), tripID, c.Query("status"))
+	if err != nil {
+		h.logger.Error("Failed to list waitlist", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list waitlist"})
+		return
+	}
+
+	role := requestRole(c)
+	for i, entry := range entries {
+		entries[i] = service.MaskWaitlistEntry(entry, role)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
+
+// GetWaitlistEntry возвращает запись листа ожидания.
+func (h *TicketHandler) GetWaitlistEntry(c *gin.Context) {
+	entry, err := h.svc.GetWaitlistEntry(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskWaitlistEntry(entry, requestRole(c))})
+}
+
+// CancelWaitlistEntry снимает пассажира с листа ожидания.
+func (h *TicketHandler) CancelWaitlistEntry(c *gin.Context) {
+	if err := h.svc.CancelWaitlistEntry(c.Request.Context(), c.Param("id"), requestUserID(c)); err != nil {
+		h.logger.Error("Failed to cancel waitlist entry", zap.Error(err))
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist entry cancelled"})
+}
+
+// GetWaitlistOffer возвращает предложение по коду из ссылки на выкуп. ПД пассажира маскируются.
+func (h *TicketHandler) GetWaitlistOffer(c *gin.Context) {
+	entry, err := h.svc.GetWaitlistOffer(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": service.MaskWaitlistEntry(entry, "")})
+}
+
+// GetWaitlistOfferNotice возвращает сервису-отправителю (внутреннее API) контакт пассажира и ссылку
+// на выкуп по действующему предложению. Каждая выдача ПД пишется в лог.
+func (h *TicketHandler) GetWaitlistOfferNotice(c *gin.Context) {
+	notice, err := h.svc.GetWaitlistOfferNotice(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Waitlist offer contact disclosed",
+		zap.String("entry_id", c.Param("id")),
+		zap.String("service", c.GetString("username")))
+	c.JSON(http.StatusOK, gin.H{"data": notice})
+}
+
+// AcceptWaitlistOffer продаёт билет по предложению из листа ожидания.
+func (h *TicketHandler) AcceptWaitlistOffer(c *gin.Context) {
+	var req service.AcceptWaitlistOfferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Token = c.Param("token")
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	ticket, err := h.svc.AcceptWaitlistOffer(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to accept waitlist offer", zap.Error(err))
+		if code, saleStatus := saleRejection(err); code != "" {
+			c.JSON(saleStatus, gin.H{"error": err.Error(), "code": code})
+			return
+		}
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": service.MaskTicket(ticket, req.Role)})
+}
+
+// DeclineWaitlistOffer отказывается от предложения; место переходит следующему в очереди.
+func (h *TicketHandler) DeclineWaitlistOffer(c *gin.Context) {
+	if err := h.svc.DeclineWaitlistOffer(c.Request.Context(), c.Param("token")); err != nil {
+		h.logger.Error("Failed to decline waitlist offer", zap.Error(err))
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Waitlist offer declined"})
+}
+
+// GetWaitlistStats возвращает статистику листа ожидания и конверсию предложений.
+func (h *TicketHandler) GetWaitlistStats(c *gin.Context) {
+	var req service.WaitlistStatsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.svc.GetWaitlistStats(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(waitlistErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+func waitlistErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrWaitlistEntryNotFound), errors.Is(err, repository.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWaitlistContact), errors.Is(err, service.ErrInvalidSegment),
+		errors.Is(err, service.ErrInvalidReport):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrOfferExpired):
+		return http.StatusGone
+	case errors.Is(err, service.ErrTripClosed), errors.Is(err, service.ErrWaitlistEntryClosed),
+		errors.Is(err, repository.ErrSeatAlreadyTaken), errors.Is(err, service.ErrShiftRequired):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateVoucher создаёт промокод маркетинговой кампании.
+func (h *TicketHandler) CreateVoucher(c *gin.Context) {
+	var req service.CreateVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+
+	voucher, err := h.svc.CreateVoucher(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Error("Failed to create voucher", zap.Error(err))
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": voucher})
+}
+
+// ListVouchers возвращает ваучеры (kind, source_ticket_id, active_only=true — фильтры).
+func (h *TicketHandler) ListVouchers(c *gin.Context) {
+	vouchers, err := h.svc.ListVouchers(c.Request.Context(), &repository.VoucherFilter{
+		Kind:           c.Query("kind"),
+		SourceTicketID: c.Query("source_ticket_id"),
+		ActiveOnly:     c.Query("active_only") == "true",
+	})
+	if err != nil {
+		h.logger.Error("Failed to list vouchers", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list vouchers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": vouchers})
+}
+
+// GetVoucher возвращает ваучер и проданные по нему билеты.
+func (h *TicketHandler) GetVoucher(c *gin.Context) {
+	voucher, err := h.svc.GetVoucher(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": voucher})
+}
+
+// GetVoucherNotice возвращает сервису-отправителю (внутреннее API) код компенсационного ваучера
+// и контакт пассажира. Каждая выдача ПД пишется в лог.
+func (h *TicketHandler) GetVoucherNotice(c *gin.Context) {
+	notice, err := h.svc.GetVoucherNotice(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("Voucher contact disclosed",
+		zap.String("voucher_id", c.Param("id")),
+		zap.String("service", c.GetString("username")))
+	c.JSON(http.StatusOK, gin.H{"data": notice})
+}
+
+// DeactivateVoucher выключает ваучер.
+func (h *TicketHandler) DeactivateVoucher(c *gin.Context) {
+	if err := h.svc.DeactivateVoucher(c.Request.Context(), c.Param("id"), requestUserID(c)); err != nil {
+		h.logger.Error("Failed to deactivate voucher", zap.Error(err))
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Voucher deactivated"})
+}
+
+// CheckVoucher рассчитывает цену билета с промокодом при оформлении заказа.
+func (h *TicketHandler) CheckVoucher(c *gin.Context) {
+	var req service.CheckVoucherRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	quote, err := h.svc.CheckVoucher(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": quote})
+}
+
+// IssueCompensationVouchers выпускает компенсационные ваучеры по отменённому рейсу, которые
+// не были выпущены автоматически.
+func (h *TicketHandler) IssueCompensationVouchers(c *gin.Context) {
+	var req struct {
+		TripID string `json:"trip_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	issued, err := h.svc.IssueCompensationVouchers(c.Request.Context(), req.TripID, requestUserID(c))
+	if err != nil {
+		h.logger.Error("Failed to issue compensation vouchers", zap.Error(err), zap.String("trip_id", req.TripID))
+		c.JSON(voucherErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"trip_id": req.TripID, "issued": issued}})
+}
+
+func voucherErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrVoucherNotFound), errors.Is(err, repository.ErrTripNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidVoucher):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrVoucherCodeTaken), errors.Is(err, service.ErrTripNotCancelled):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrVoucherNotApplicable):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrVoucherClosed):
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// CreateSettlement формирует черновик расчёта с перевозчиком за период.
+func (h *TicketHandler) CreateSettlement(c *gin.Context) {
+	var req service.CreateSettlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = requestUserID(c)
+	req.Role = requestRole(c)
+
+	settlement, err := h.svc.CreateSettlement(c.Request.Context(), &req)
+	if err != nil {
+		h.logSettlementError("Failed to create settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": settlement})
+}
+
+// ListSettlements возвращает расчёты с перевозчиками (фильтры carrier_id и status).
+func (h *TicketHandler) ListSettlements(c *gin.Context) {
+	settlements, err := h.svc.ListSettlements(c.Request.Context(), &repository.SettlementFilter{
+		CarrierID: c.Query("carrier_id"),
+		Status:    c.Query("status"),
+	}, requestRole(c))
+	if err != nil {
+		h.logSettlementError("Failed to list settlements", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settlements})
+}
+
+// GetSettlement возвращает расчёт с перевозчиком со строками по маршрутам.
+func (h *TicketHandler) GetSettlement(c *gin.Context) {
+	settlement, err := h.svc.GetSettlement(c.Request.Context(), c.Param("id"), requestRole(c))
+	if err != nil {
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settlement})
+}
+
+// RecalculateSettlement пересчитывает черновик расчёта.
+func (h *TicketHandler) RecalculateSettlement(c *gin.Context) {
+	settlement, err := h.svc.RecalculateSettlement(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		h.logSettlementError("Failed to recalculate settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settlement})
+}
+
+// CloseSettlement закрывает расчёт; после закрытия он не меняется.
+func (h *TicketHandler) CloseSettlement(c *gin.Context) {
+	settlement, err := h.svc.CloseSettlement(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c))
+	if err != nil {
+		h.logSettlementError("Failed to close settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": settlement})
+}
+
+// VerifySettlement сверяет закрытый расчёт с хешем закрытия и текущими продажами.
+func (h *TicketHandler) VerifySettlement(c *gin.Context) {
+	verification, err := h.svc.VerifySettlement(c.Request.Context(), c.Param("id"), requestRole(c))
+	if err != nil {
+		h.logSettlementError("Failed to verify settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": verification})
+}
+
+// ExportSettlement выгружает расчёт файлом (format=pdf или xlsx, по умолчанию pdf).
+func (h *TicketHandler) ExportSettlement(c *gin.Context) {
+	file, err := h.svc.ExportSettlement(c.Request.Context(), c.Param("id"), c.DefaultQuery("format", service.ReportFormatPDF), requestRole(c))
+	if err != nil {
+		h.logSettlementError("Failed to export settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	sendReportFile(c, file)
+}
+
+// DeleteSettlement удаляет черновик расчёта.
+func (h *TicketHandler) DeleteSettlement(c *gin.Context) {
+	if err := h.svc.DeleteSettlement(c.Request.Context(), c.Param("id"), requestUserID(c), requestRole(c)); err != nil {
+		h.logSettlementError("Failed to delete settlement", err)
+		c.JSON(settlementErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Settlement deleted"})
+}
+
+// logSettlementError логирует только внутренние ошибки расчётов; ошибки запроса уходят клиенту.
+func (h *TicketHandler) logSettlementError(msg string, err error) {
+	if settlementErrorStatus(err) == http.StatusInternalServerError {
+		h.logger.Error(msg, zap.Error(err))
+	}
+}
+
+func settlementErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrSettlementNotFound), errors.Is(err, repository.ErrCarrierNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrSettlementForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrInvalidSettlement):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrSettlementOverlap), errors.Is(err, service.ErrSettlementClosed),
+		errors.Is(err, service.ErrSettlementNotClosed), errors.Is(err, service.ErrSettlementPeriodOpen):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
 }
@@ -0,0 +1,51 @@
+// Package middleware — HTTP middleware Ticket Service.
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+
+	commonjwt "github.com/vokzal-tech/go-common/jwt"
+)
+
+// RoleService — роль в JWT запросов других сервисов к внутреннему API.
+const RoleService = "service"
+
+// ServiceAuth возвращает gin.HandlerFunc, пропускающий во внутреннее API только сервисы из services:
+// JWT в заголовке Authorization подписан секретом jwtSecret, роль — "service", имя (username) — сервис.
+// Без токена или с невалидным токеном отвечает 401, другому сервису или пользователю — 403.
+func ServiceAuth(jwtSecret string, services []string, logger *zap.Logger) gin.HandlerFunc {
+	tokens := commonjwt.NewManager(commonjwt.Config{Secret: jwtSecret})
+	allowed := make(map[string]bool, len(services))
+	for _, s := range services {
+		allowed[s] = true
+	}
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			return
+		}
+		claims, err := tokens.Verify(token)
+		if err != nil {
+			logger.Warn("Invalid or expired service JWT", zap.Error(err))
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			return
+		}
+		if claims.Role != RoleService || !allowed[claims.Username] {
+			logger.Warn("Internal API access denied",
+				zap.String("role", claims.Role),
+				zap.String("username", claims.Username))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Internal API is available to services only"})
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Next()
+	}
+}
@@ -2,50 +2,494 @@
 package models
 
 import (
+	"database/sql/driver"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// JSONB — тип для PostgreSQL JSONB.
+type JSONB []byte
+
+// Value реализует driver.Valuer для JSONB.
+func (j JSONB) Value() (driver.Value, error) {
+	if len(j) == 0 {
+		return nil, nil
+	}
+	return string(j), nil
+}
+
+// Scan реализует sql.Scanner для JSONB.
+func (j *JSONB) Scan(value interface{}) error {
+	if value == nil {
+		*j = nil
+		return nil
+	}
+	s, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to scan JSONB value")
+	}
+	*j = s
+	return nil
+}
+
 // Ticket — модель билета.
+// При возврате сохраняются причина, применённая политика (ID и версия) и удержанный сервисный сбор.
+// При обмене исходный билет получает статус "exchanged" и ссылку ExchangedToID на новый,
+// а новый — обратную ссылку ExchangedFromID и удержанный сбор ExchangeFee.
+// ПД пассажира (ФИО, документ, телефон, email) хранятся зашифрованными (serializer:pii),
+// поиск по ним — через слепые индексы *Index; PIIKeyID — ключ, которым зашифрована запись.
+// По истечении срока хранения или по запросу субъекта ПД удаляются, остальные поля сохраняются (AnonymizedAt).
+// Билет, проданный в кассе, ссылается на кассовую смену (ShiftID) и кассира (SoldBy).
+// Price — цена к оплате: при продаже по ваучеру (VoucherID) из тарифа вычтена скидка DiscountAmount.
+// Components — составляющие цены (тариф перевозчика, сбор вокзала, страховой сбор), в сумме равные Price;
+// у билетов, проданных до учёта составляющих, пусто. FromStationID — остановка посадки пассажира,
+// ToStationID — остановка высадки (nil — конечная); участок задаётся при продаже водителем в пути.
+// VehicleID — дополнительный автобус рейса, за которым закреплено место (nil — основной автобус).
 type Ticket struct {
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	ShiftID             *string         `gorm:"type:uuid;index" json:"shift_id,omitempty"`
+	SoldBy              *string         `gorm:"type:varchar(64);index" json:"sold_by,omitempty"`
+	RefundedAt          *time.Time      `json:"refunded_at,omitempty"`
+	RefundAmount        *float64        `gorm:"type:decimal(10,2)" json:"refund_amount,omitempty"`
+	PassengerDoc        *string         `gorm:"type:text;serializer:pii" json:"passenger_doc,omitempty"`
+	Phone               *string         `gorm:"type:text;serializer:pii" json:"phone,omitempty"`
+	Email               *string         `gorm:"type:text;serializer:pii" json:"email,omitempty"`
+	SeatID              *string         `gorm:"type:uuid;index" json:"seat_id,omitempty"`
+	RefundPenalty       *float64        `gorm:"type:decimal(10,2)" json:"refund_penalty,omitempty"`
+	PassengerName       *string         `gorm:"type:text;serializer:pii" json:"passenger_name,omitempty"`
+	PassengerDocIndex   *string         `gorm:"type:varchar(64);index" json:"-"`
+	PhoneIndex          *string         `gorm:"type:varchar(64);index" json:"-"`
+	EmailIndex          *string         `gorm:"type:varchar(64);index" json:"-"`
+	PIIKeyID            *string         `gorm:"type:varchar(32);index" json:"-"`
+	AnonymizedAt        *time.Time      `gorm:"index" json:"anonymized_at,omitempty"`
+	ExchangedFromID     *string         `gorm:"type:uuid;index" json:"exchanged_from_id,omitempty"`
+	ExchangedToID       *string         `gorm:"type:uuid;index" json:"exchanged_to_id,omitempty"`
+	ExchangeFee         *float64        `gorm:"type:decimal(10,2)" json:"exchange_fee,omitempty"`
+	RefundReason        *string         `gorm:"type:varchar(30)" json:"refund_reason,omitempty"`
+	RefundPolicyID      *string         `gorm:"type:uuid;index" json:"refund_policy_id,omitempty"`
+	RefundPolicyVersion *int            `json:"refund_policy_version,omitempty"`
+	RefundServiceFee    *float64        `gorm:"type:decimal(10,2)" json:"refund_service_fee,omitempty"`
+	NoShowAt            *time.Time      `gorm:"index" json:"no_show_at,omitempty"`
+	VoucherID           *string         `gorm:"type:uuid;index" json:"voucher_id,omitempty"`
+	DiscountAmount      *float64        `gorm:"type:decimal(10,2)" json:"discount_amount,omitempty"`
+	FromStationID       *string         `gorm:"type:uuid;index" json:"from_station_id,omitempty"`
+	ToStationID         *string         `gorm:"type:uuid" json:"to_station_id,omitempty"`
+	VehicleID           *string         `gorm:"type:uuid;index" json:"vehicle_id,omitempty"`
+	PaymentMethod       string          `gorm:"type:varchar(20)" json:"payment_method"`
+	PassengerCategory   string          `gorm:"type:varchar(20);not null;default:'adult';index" json:"passenger_category"`
+	BarCode             string          `gorm:"type:varchar(255);unique" json:"bar_code"`
+	ID                  string          `gorm:"type:uuid;primary_key" json:"id"`
+	QRCode              string          `gorm:"type:text;unique" json:"qr_code"`
+	Status              string          `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+	TripID              string          `gorm:"type:uuid;not null;index" json:"trip_id"`
+	Components          []FareComponent `gorm:"type:jsonb;serializer:json" json:"components,omitempty"`
+	Price               float64         `gorm:"type:decimal(10,2);not null" json:"price"`
+}
+
+// BaggageTicket — модель багажной квитанции, привязанной к билету пассажира.
+type BaggageTicket struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
+	ShiftID       *string    `gorm:"type:uuid;index" json:"shift_id,omitempty"`
 	RefundedAt    *time.Time `json:"refunded_at,omitempty"`
 	RefundAmount  *float64   `gorm:"type:decimal(10,2)" json:"refund_amount,omitempty"`
-	PassengerDoc  *string    `gorm:"type:varchar(50)" json:"passenger_doc,omitempty"`
-	Phone         *string    `gorm:"type:varchar(20)" json:"phone,omitempty"`
-	Email         *string    `gorm:"type:varchar(100)" json:"email,omitempty"`
-	SeatID        *string    `gorm:"type:uuid;index" json:"seat_id,omitempty"`
 	RefundPenalty *float64   `gorm:"type:decimal(10,2)" json:"refund_penalty,omitempty"`
-	PassengerName *string    `gorm:"type:varchar(100)" json:"passenger_name,omitempty"`
+	ID            string     `gorm:"type:uuid;primary_key" json:"id"`
+	TicketID      string     `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	TripID        string     `gorm:"type:uuid;not null;index" json:"trip_id"`
+	WeightClass   string     `gorm:"type:varchar(20);not null" json:"weight_class"`
 	PaymentMethod string     `gorm:"type:varchar(20)" json:"payment_method"`
 	BarCode       string     `gorm:"type:varchar(255);unique" json:"bar_code"`
-	ID            string     `gorm:"type:uuid;primary_key" json:"id"`
-	QRCode        string     `gorm:"type:varchar(255);unique" json:"qr_code"`
 	Status        string     `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
-	TripID        string     `gorm:"type:uuid;not null;index" json:"trip_id"`
+	Pieces        int        `gorm:"not null" json:"pieces"`
+	Tariff        float64    `gorm:"type:decimal(10,2);not null" json:"tariff"`
 	Price         float64    `gorm:"type:decimal(10,2);not null" json:"price"`
 }
 
-// BoardingEvent — модель события начала посадки.
+// RefundPolicy — версия правил возврата для перевозчика и/или маршрута.
+// Версии неизменяемы: изменение правил создаёт новую версию с тем же Code, предыдущая деактивируется.
+// Политика без CarrierID и RouteID действует по умолчанию. Tiers — JSON-массив RefundTier;
+// NoShowPenaltyRate == nil означает, что после отправления возврат не производится.
+// ServiceFee — сервисный сбор в составе цены билета, при добровольном возврате не возвращается;
+// к билетам с составляющими цены (Ticket.Components) не применяется — сборы возвращаются по своим правилам.
+type RefundPolicy struct {
+	CreatedAt         time.Time `json:"created_at"`
+	CarrierID         *string   `gorm:"type:uuid;index" json:"carrier_id,omitempty"`
+	RouteID           *string   `gorm:"type:uuid;index" json:"route_id,omitempty"`
+	NoShowPenaltyRate *float64  `gorm:"type:decimal(5,4)" json:"no_show_penalty_rate,omitempty"`
+	ID                string    `gorm:"type:uuid;primary_key" json:"id"`
+	Code              string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_refund_policy_code_version" json:"code"`
+	Name              string    `gorm:"type:varchar(100);not null" json:"name"`
+	CreatedBy         string    `gorm:"type:varchar(100)" json:"created_by"`
+	Tiers             JSONB     `gorm:"type:jsonb;not null" json:"tiers"`
+	Version           int       `gorm:"not null;uniqueIndex:idx_refund_policy_code_version" json:"version"`
+	ServiceFee        float64   `gorm:"type:decimal(10,2);not null;default:0" json:"service_fee"`
+	IsActive          bool      `gorm:"not null;default:true;index" json:"is_active"`
+	MedicalExempt     bool      `gorm:"not null;default:false" json:"medical_exempt"`
+}
+
+// RefundTier — ступень штрафа: действует, если до отправления осталось не меньше MinHoursBefore часов.
+type RefundTier struct {
+	MinHoursBefore float64 `json:"min_hours_before"`
+	PenaltyRate    float64 `json:"penalty_rate"`
+}
+
+// FareComponent — составляющая цены билета, печатается в чеке отдельной позицией со своей ставкой НДС.
+// RefundMode и VAT копируются из правила на момент продажи: изменение правила проданные билеты не меняет.
+// RefundAmount — возвращённая пассажиру часть составляющей (заполняется при возврате).
+type FareComponent struct {
+	RefundAmount *float64 `json:"refund_amount,omitempty"`
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	VAT          string   `json:"vat"`
+	RefundMode   string   `json:"refund_mode"`
+	Amount       float64  `json:"amount"`
+}
+
+// FareComponentRule — правило начисления сбора к тарифу перевозчика: сбора вокзала (Kind "station_fee")
+// или страхового сбора ("insurance"). Сумма — Amount плюс доля Rate от тарифа. Правило действует для станции
+// отправления (StationID) и/или маршрута (RouteID); правило без них — по умолчанию. RefundMode — как сбор
+// возвращается: "full" — полностью, "fare" — вместе с тарифом за вычетом штрафа, "none" — не возвращается
+// (кроме отмены рейса перевозчиком).
+type FareComponentRule struct {
+	CreatedAt  time.Time `json:"created_at"`
+	StationID  *string   `gorm:"type:uuid;index" json:"station_id,omitempty"`
+	RouteID    *string   `gorm:"type:uuid;index" json:"route_id,omitempty"`
+	ID         string    `gorm:"type:uuid;primary_key" json:"id"`
+	Kind       string    `gorm:"type:varchar(20);not null;index" json:"kind"`
+	Name       string    `gorm:"type:varchar(128);not null" json:"name"`
+	VAT        string    `gorm:"type:varchar(10);not null;default:'none'" json:"vat"`
+	RefundMode string    `gorm:"type:varchar(10);not null" json:"refund_mode"`
+	CreatedBy  string    `gorm:"type:varchar(100)" json:"created_by"`
+	Amount     float64   `gorm:"type:decimal(10,2);not null;default:0" json:"amount"`
+	Rate       float64   `gorm:"type:decimal(5,4);not null;default:0" json:"rate"`
+	IsActive   bool      `gorm:"not null;default:true;index" json:"is_active"`
+}
+
+// QRSigningKey — ключ Ed25519 для подписи QR-кодов билетов. Seed — seed ключа в base64, хранится
+// зашифрованным ключом ПД (как ПД пассажира) и наружу не отдаётся. LegacyPrivateKey — seed открытым
+// текстом у ключей, выпущенных до шифрования; при старте переносится в Seed и очищается.
+// Действующий ключ один (status "active"); после ротации прежний получает статус "retired"
+// и публикуется для проверки до VerifyUntil — пока не истекут подписанные им коды.
+type QRSigningKey struct {
+	CreatedAt        time.Time  `json:"created_at"`
+	RetiredAt        *time.Time `json:"retired_at,omitempty"`
+	VerifyUntil      *time.Time `json:"verify_until,omitempty"`
+	Seed             *string    `gorm:"type:text;serializer:pii" json:"-"`
+	ID               string     `gorm:"type:varchar(32);primary_key" json:"kid"`
+	Status           string     `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+	PublicKey        []byte     `gorm:"type:bytea;not null" json:"-"`
+	LegacyPrivateKey []byte     `gorm:"column:private_key;type:bytea" json:"-"`
+}
+
+// BoardingEvent — модель события посадки по рейсу. EndedAt заполняется при завершении посадки,
+// после чего манифест заморожен.
 type BoardingEvent struct {
-	StartedAt time.Time `gorm:"not null" json:"started_at"`
-	CreatedAt time.Time `json:"created_at"`
-	ID        string    `gorm:"type:uuid;primary_key" json:"id"`
-	TripID    string    `gorm:"type:uuid;not null;unique;index" json:"trip_id"`
-	StartedBy string    `gorm:"type:uuid;not null" json:"started_by"`
+	StartedAt time.Time  `gorm:"not null" json:"started_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	EndedBy   *string    `gorm:"type:uuid" json:"ended_by,omitempty"`
+	ID        string     `gorm:"type:uuid;primary_key" json:"id"`
+	TripID    string     `gorm:"type:uuid;not null;unique;index" json:"trip_id"`
+	StartedBy string     `gorm:"type:uuid;not null" json:"started_by"`
 }
 
 // BoardingMark — модель отметки посадки.
+// Для отметок, собранных офлайн, MarkedAt — время на устройстве контролёра, SyncedAt — время выгрузки.
+// Отменённая отметка (CancelledAt) не учитывается в посадке и остаётся для аудита.
 type BoardingMark struct {
-	MarkedAt   time.Time `gorm:"not null" json:"marked_at"`
-	CreatedAt  time.Time `json:"created_at"`
-	ID         string    `gorm:"type:uuid;primary_key" json:"id"`
-	TicketID   string    `gorm:"type:uuid;not null;index" json:"ticket_id"`
-	MarkedBy   string    `gorm:"type:uuid;not null" json:"marked_by"`
-	ScanMethod string    `gorm:"type:varchar(20)" json:"scan_method"`
+	MarkedAt     time.Time  `gorm:"not null" json:"marked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	SyncedAt     *time.Time `json:"synced_at,omitempty"`
+	DeviceID     *string    `gorm:"type:varchar(64);index" json:"device_id,omitempty"`
+	CancelledAt  *time.Time `gorm:"index" json:"cancelled_at,omitempty"`     //nolint:misspell // column name
+	CancelledBy  *string    `gorm:"type:uuid" json:"cancelled_by,omitempty"` //nolint:misspell // column name
+	CancelReason *string    `gorm:"type:varchar(255)" json:"cancel_reason,omitempty"`
+	ID           string     `gorm:"type:uuid;primary_key" json:"id"`
+	TicketID     string     `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	MarkedBy     string     `gorm:"type:uuid;not null" json:"marked_by"`
+	ScanMethod   string     `gorm:"type:varchar(20)" json:"scan_method"`
+}
+
+// BoardingCorrection — запись журнала отмен и исправлений отметок посадки.
+// Action: "cancel" — отметка снята; "correct" — пассажир пересажен на другое место или в другой автобус.
+// OldVehicleID/NewVehicleID — дополнительный автобус рейса до и после пересадки (nil — основной).
+type BoardingCorrection struct {
+	CreatedAt    time.Time `json:"created_at"`
+	OldTripID    *string   `gorm:"type:uuid" json:"old_trip_id,omitempty"`
+	NewTripID    *string   `gorm:"type:uuid" json:"new_trip_id,omitempty"`
+	OldSeatID    *string   `gorm:"type:uuid" json:"old_seat_id,omitempty"`
+	NewSeatID    *string   `gorm:"type:uuid" json:"new_seat_id,omitempty"`
+	OldVehicleID *string   `gorm:"type:uuid" json:"old_vehicle_id,omitempty"`
+	NewVehicleID *string   `gorm:"type:uuid" json:"new_vehicle_id,omitempty"`
+	ID           string    `gorm:"type:uuid;primary_key" json:"id"`
+	MarkID       string    `gorm:"type:uuid;not null;index" json:"mark_id"`
+	TicketID     string    `gorm:"type:uuid;not null;index" json:"ticket_id"`
+	Action       string    `gorm:"type:varchar(20);not null" json:"action"`
+	Reason       string    `gorm:"type:varchar(255);not null" json:"reason"`
+	PerformedBy  string    `gorm:"type:uuid;not null" json:"performed_by"`
+	Role         string    `gorm:"type:varchar(20);not null" json:"role"`
+}
+
+// ErasureRequest — запрос субъекта ПД на удаление его данных (152-ФЗ).
+// Пассажир находится по одному критерию; хранится только слепой индекс критерия, не само значение.
+// Запрос выполнен (CompletedAt), когда о своей части отчитались notify-service и document-service.
+type ErasureRequest struct {
+	CreatedAt          time.Time  `json:"created_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty"`
+	NotifyReportedAt   *time.Time `json:"notify_reported_at,omitempty"`
+	DocumentReportedAt *time.Time `json:"document_reported_at,omitempty"`
+	ID                 string     `gorm:"type:uuid;primary_key" json:"id"`
+	Criterion          string     `gorm:"type:varchar(20);not null" json:"criterion"`
+	CriterionIndex     string     `gorm:"type:varchar(64);not null;index" json:"-"`
+	Basis              string     `gorm:"type:varchar(255);not null" json:"basis"`
+	RequestedBy        string     `gorm:"type:uuid;not null" json:"requested_by"`
+	TicketCount        int        `json:"ticket_count"`
+}
+
+// AnonymizationLog — запись журнала обезличивания: какие поля какой записи и какого сервиса обезличены.
+// Reason: "retention" — истёк срок хранения; "erase_request" — запрос субъекта (ErasureRequestID).
+type AnonymizationLog struct {
+	CreatedAt        time.Time `json:"created_at"`
+	ErasureRequestID *string   `gorm:"type:uuid;index" json:"erasure_request_id,omitempty"`
+	ID               string    `gorm:"type:uuid;primary_key" json:"id"`
+	Service          string    `gorm:"type:varchar(30);not null;index" json:"service"`
+	EntityType       string    `gorm:"type:varchar(30);not null" json:"entity_type"`
+	EntityID         string    `gorm:"type:varchar(64);not null;index" json:"entity_id"`
+	Reason           string    `gorm:"type:varchar(20);not null" json:"reason"`
+	Fields           string    `gorm:"type:varchar(255)" json:"fields"`
+}
+
+// CashierShift — кассовая смена кассира на рабочем месте. Открывается с разменным фондом (OpeningFloat),
+// при закрытии ожидаемая сумма наличных (ExpectedCash) сверяется с пересчитанной (CountedCash).
+// После Z-отчёта ККТ рабочего места смена связывается с ним (ZReportID, KKTShiftNumber).
+// Открытой может быть одна смена кассира и одна смена рабочего места.
+type CashierShift struct {
+	OpenedAt       time.Time  `json:"opened_at"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	ExpectedCash   *float64   `gorm:"type:decimal(10,2)" json:"expected_cash,omitempty"`
+	CountedCash    *float64   `gorm:"type:decimal(10,2)" json:"counted_cash,omitempty"`
+	CashDifference *float64   `gorm:"type:decimal(10,2)" json:"cash_difference,omitempty"`
+	ClosedBy       *string    `gorm:"type:varchar(64)" json:"closed_by,omitempty"`
+	ZReportID      *string    `gorm:"type:uuid;index" json:"z_report_id,omitempty"`
+	KKTShiftNumber *int       `json:"kkt_shift_number,omitempty"`
+	ID             string     `gorm:"type:uuid;primary_key" json:"id"`
+	CashierID      string     `gorm:"type:varchar(64);not null;uniqueIndex:idx_cashier_shifts_open_cashier,where:status = 'open'" json:"cashier_id"`
+	WorkstationID  string     `gorm:"type:varchar(50);not null;uniqueIndex:idx_cashier_shifts_open_workstation,where:status = 'open'" json:"workstation_id"`
+	KKTSerial      string     `gorm:"type:varchar(50);index" json:"kkt_serial"`
+	Status         string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	OpeningFloat   float64    `gorm:"type:decimal(10,2);not null" json:"opening_float"`
+}
+
+// ShiftOperation — движение денег в кассовой смене: продажа и возврат (билет, багаж, доплата
+// или возврат разницы при обмене) любым способом оплаты, внесение и изъятие наличных.
+// Amount всегда положителен, направление задаёт Type.
+type ShiftOperation struct {
+	CreatedAt     time.Time `json:"created_at"`
+	EntityID      *string   `gorm:"type:uuid;index" json:"entity_id,omitempty"`
+	Reason        *string   `gorm:"type:varchar(255)" json:"reason,omitempty"`
+	ID            string    `gorm:"type:uuid;primary_key" json:"id"`
+	ShiftID       string    `gorm:"type:uuid;not null;index" json:"shift_id"`
+	Type          string    `gorm:"type:varchar(20);not null" json:"type"`
+	EntityType    string    `gorm:"type:varchar(20)" json:"entity_type,omitempty"`
+	PaymentMethod string    `gorm:"type:varchar(20);not null" json:"payment_method"`
+	UserID        string    `gorm:"type:varchar(64);not null" json:"user_id"`
+	Amount        float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+}
+
+// TicketNameToken — слепой токен (триграмма) ФИО пассажира для нечёткого поиска по зашифрованным именам.
+// Пустой токен отмечает ФИО без букв, по которому искать нечего.
+type TicketNameToken struct {
+	TicketID string `gorm:"type:uuid;primaryKey" json:"ticket_id"`
+	Token    string `gorm:"type:varchar(16);primaryKey;index" json:"token"`
+}
+
+// ReportJob — фоновое построение отчёта за большой период. Параметры отчёта хранятся в Params,
+// готовый файл (CSV или XLSX) — в Result до ExpiresAt.
+// Status: "pending" → "running" → "done" или "failed" (Error).
+type ReportJob struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	Error       *string    `gorm:"type:text" json:"error,omitempty"`
+	ID          string     `gorm:"type:uuid;primary_key" json:"id"`
+	Type        string     `gorm:"type:varchar(30);not null" json:"type"`
+	Format      string     `gorm:"type:varchar(10);not null" json:"format"`
+	Status      string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	RequestedBy string     `gorm:"type:varchar(64);not null;index" json:"requested_by"`
+	FileName    string     `gorm:"type:varchar(255)" json:"file_name,omitempty"`
+	Params      JSONB      `gorm:"type:jsonb;not null" json:"params"`
+	Result      []byte     `gorm:"type:bytea" json:"-"`
+	RowCount    int        `json:"row_count"`
+}
+
+// WaitlistEntry — запись листа ожидания на распроданный рейс; FromStationID и ToStationID задают
+// участок, если пассажиру нужен не весь маршрут. Очередь рейса — в порядке CreatedAt.
+// Status: "waiting" → "offered" (место удерживается до OfferExpiresAt, ссылка на выкуп — OfferToken)
+// → "converted" (выкуплен билет TicketID) или "expired"; "cancelled" — отказ пассажира или отмена кассиром.
+// ФИО и контакт для предложения (Phone или Email по Channel) хранятся зашифрованными и удаляются
+// при закрытии записи (ClosedAt): после выкупа они есть в билете.
+type WaitlistEntry struct {
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	OfferedAt         *time.Time `json:"offered_at,omitempty"`
+	OfferExpiresAt    *time.Time `gorm:"index" json:"offer_expires_at,omitempty"`
+	ClosedAt          *time.Time `json:"closed_at,omitempty"`
+	AnonymizedAt      *time.Time `json:"anonymized_at,omitempty"`
+	FromStationID     *string    `gorm:"type:uuid" json:"from_station_id,omitempty"`
+	ToStationID       *string    `gorm:"type:uuid" json:"to_station_id,omitempty"`
+	SeatID            *string    `gorm:"type:uuid" json:"seat_id,omitempty"`
+	TicketID          *string    `gorm:"type:uuid;index" json:"ticket_id,omitempty"`
+	OfferToken        *string    `gorm:"type:varchar(64);uniqueIndex" json:"-"`
+	PassengerName     *string    `gorm:"type:text;serializer:pii" json:"passenger_name,omitempty"`
+	Phone             *string    `gorm:"type:text;serializer:pii" json:"phone,omitempty"`
+	Email             *string    `gorm:"type:text;serializer:pii" json:"email,omitempty"`
+	PhoneIndex        *string    `gorm:"type:varchar(64);index" json:"-"`
+	EmailIndex        *string    `gorm:"type:varchar(64);index" json:"-"`
+	PIIKeyID          *string    `gorm:"type:varchar(32)" json:"-"`
+	CreatedBy         *string    `gorm:"type:varchar(64)" json:"created_by,omitempty"`
+	ID                string     `gorm:"type:uuid;primary_key" json:"id"`
+	TripID            string     `gorm:"type:uuid;not null;index" json:"trip_id"`
+	Channel           string     `gorm:"type:varchar(10);not null" json:"channel"`
+	PassengerCategory string     `gorm:"type:varchar(20);not null;default:'adult'" json:"passenger_category"`
+	Status            string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	Price             float64    `gorm:"type:decimal(10,2);not null" json:"price"`
+}
+
+// Voucher — промокод маркетинговой кампании (Kind "promo") или компенсационный ваучер за рейс,
+// отменённый перевозчиком (Kind "compensation", выпускается на билет SourceTicketID).
+// DiscountType "fixed" — скидка Value рублей, "percent" — Value процентов тарифа, но не больше MaxDiscount.
+// Код применяется с ValidFrom до ValidUntil и не больше UsageLimit раз (nil — без ограничения);
+// RouteIDs и TravelDateFrom/TravelDateTo (YYYY-MM-DD) ограничивают маршруты и даты отправления рейсов.
+type Voucher struct {
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	ValidFrom      *time.Time `json:"valid_from,omitempty"`
+	ValidUntil     *time.Time `gorm:"index" json:"valid_until,omitempty"`
+	MaxDiscount    *float64   `gorm:"type:decimal(10,2)" json:"max_discount,omitempty"`
+	UsageLimit     *int       `json:"usage_limit,omitempty"`
+	TravelDateFrom *string    `gorm:"type:varchar(10)" json:"travel_date_from,omitempty"`
+	TravelDateTo   *string    `gorm:"type:varchar(10)" json:"travel_date_to,omitempty"`
+	SourceTicketID *string    `gorm:"type:uuid;uniqueIndex" json:"source_ticket_id,omitempty"`
+	Description    *string    `gorm:"type:varchar(255)" json:"description,omitempty"`
+	ID             string     `gorm:"type:uuid;primary_key" json:"id"`
+	Code           string     `gorm:"type:varchar(32);not null;uniqueIndex" json:"code"`
+	Kind           string     `gorm:"type:varchar(20);not null;index" json:"kind"`
+	DiscountType   string     `gorm:"type:varchar(10);not null" json:"discount_type"`
+	CreatedBy      string     `gorm:"type:varchar(64)" json:"created_by,omitempty"`
+	RouteIDs       []string   `gorm:"type:jsonb;serializer:json" json:"route_ids,omitempty"`
+	Value          float64    `gorm:"type:decimal(10,2);not null" json:"value"`
+	UsedCount      int        `gorm:"not null;default:0" json:"used_count"`
+	IsActive       bool       `gorm:"not null;default:true;index" json:"is_active"`
+}
+
+// CarrierSettlement — расчёт с перевозчиком (отчёт агента) за период PeriodFrom–PeriodTo (YYYY-MM-DD
+// включительно): выручка по маршрутам перевозчика за вычетом возвратов, агентского вознаграждения
+// и сборов вокзала. Ставки перевозчика (CommissionRate, ServiceFee) фиксируются при расчёте.
+// Status: "draft" — можно пересчитать или удалить; "closed" — закрыт (ClosedAt, ClosedBy) и больше
+// не меняется, ContentHash — SHA-256 содержимого на момент закрытия.
+type CarrierSettlement struct {
+	CreatedAt         time.Time        `json:"created_at"`
+	UpdatedAt         time.Time        `json:"updated_at"`
+	CalculatedAt      time.Time        `json:"calculated_at"`
+	ClosedAt          *time.Time       `json:"closed_at,omitempty"`
+	ClosedBy          *string          `gorm:"type:varchar(64)" json:"closed_by,omitempty"`
+	ContentHash       *string          `gorm:"type:varchar(64)" json:"content_hash,omitempty"`
+	ContractNumber    *string          `gorm:"type:varchar(50)" json:"contract_number,omitempty"`
+	ID                string           `gorm:"type:uuid;primary_key" json:"id"`
+	CarrierID         string           `gorm:"type:uuid;not null;index" json:"carrier_id"`
+	CarrierName       string           `gorm:"type:varchar(255);not null" json:"carrier_name"`
+	CarrierINN        string           `gorm:"type:varchar(12)" json:"carrier_inn"`
+	PeriodFrom        string           `gorm:"type:varchar(10);not null" json:"period_from"`
+	PeriodTo          string           `gorm:"type:varchar(10);not null" json:"period_to"`
+	Status            string           `gorm:"type:varchar(20);not null;index" json:"status"`
+	CreatedBy         string           `gorm:"type:varchar(64);not null" json:"created_by"`
+	Lines             []SettlementLine `gorm:"type:jsonb;serializer:json" json:"lines"`
+	SettlementAmounts `gorm:"embedded"`
+	CommissionRate    float64 `gorm:"type:decimal(5,4);not null" json:"commission_rate"`
+	ServiceFee        float64 `gorm:"type:decimal(10,2);not null" json:"service_fee"`
+}
+
+// SettlementAmounts — суммы расчёта с перевозчиком. Продажи и возвраты считаются, как в отчёте о продажах:
+// продажа — датой продажи, возврат — датой возврата. Collected — собрано в пользу перевозчика
+// (чистая выручка), Commission — агентское вознаграждение, ServiceFees — сборы вокзала за проданные билеты,
+// Payable — к перечислению перевозчику (отрицательная сумма — долг перевозчика вокзалу).
+type SettlementAmounts struct {
+	TicketsAmount   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"tickets_amount"`
+	BaggageAmount   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"baggage_amount"`
+	ExchangeFees    float64 `gorm:"type:decimal(12,2);not null;default:0" json:"exchange_fees"`
+	ExchangedAmount float64 `gorm:"type:decimal(12,2);not null;default:0" json:"exchanged_amount"`
+	RefundsAmount   float64 `gorm:"type:decimal(12,2);not null;default:0" json:"refunds_amount"`
+	Penalties       float64 `gorm:"type:decimal(12,2);not null;default:0" json:"penalties"`
+	Collected       float64 `gorm:"type:decimal(12,2);not null;default:0" json:"collected"`
+	Commission      float64 `gorm:"type:decimal(12,2);not null;default:0" json:"commission"`
+	ServiceFees     float64 `gorm:"type:decimal(12,2);not null;default:0" json:"service_fees"`
+	Payable         float64 `gorm:"type:decimal(12,2);not null;default:0" json:"payable"`
+	TicketsSold     int64   `gorm:"not null;default:0" json:"tickets_sold"`
+	BaggageSold     int64   `gorm:"not null;default:0" json:"baggage_sold"`
+	Refunds         int64   `gorm:"not null;default:0" json:"refunds"`
+}
+
+// SettlementLine — суммы расчёта по одному маршруту перевозчика.
+type SettlementLine struct {
+	RouteID   *string `json:"route_id,omitempty"`
+	RouteName *string `json:"route_name,omitempty"`
+	SettlementAmounts
+}
+
+// TripSalesSettings — настройки продажи рейса: окна продаж кассы и онлайн-продаж (незаданные значения
+// берутся из конфигурации business.sales) и квоты мест для посадки на остановках маршрута.
+type TripSalesSettings struct {
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+	Online     SalesWindow `gorm:"embedded;embeddedPrefix:online_" json:"online"`
+	Counter    SalesWindow `gorm:"embedded;embeddedPrefix:counter_" json:"counter"`
+	TripID     string      `gorm:"type:uuid;primary_key" json:"trip_id"`
+	UpdatedBy  string      `gorm:"type:varchar(100)" json:"updated_by"`
+	StopQuotas []StopQuota `gorm:"type:jsonb;serializer:json" json:"stop_quotas"`
+}
+
+// DriverSale — продажа билета водителем в пути с мобильного устройства (DeviceID). ClientSaleID —
+// номер продажи на устройстве: повторная выгрузка той же продажи не создаёт второй билет. SoldAt — время
+// продажи по часам устройства; чек пробит на мобильной ККТ (KKTSerial, FiscalSign, FiscalDocNumber).
+// Conflict — расхождение с манифестом рейса, с которым продажа принята: деньги уже получены, чек пробит.
+type DriverSale struct {
+	SoldAt           time.Time `gorm:"not null" json:"sold_at"`
+	ReceiptPrintedAt time.Time `gorm:"not null" json:"receipt_printed_at"`
+	CreatedAt        time.Time `json:"created_at"`
+	SeatID           *string   `gorm:"type:uuid" json:"seat_id,omitempty"`
+	Conflict         *string   `gorm:"type:varchar(30);index" json:"conflict,omitempty"`
+	ID               string    `gorm:"type:uuid;primary_key" json:"id"`
+	TicketID         string    `gorm:"type:uuid;not null;uniqueIndex" json:"ticket_id"`
+	TripID           string    `gorm:"type:uuid;not null;index" json:"trip_id"`
+	DeviceID         string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_driver_sales_client" json:"device_id"`
+	ClientSaleID     string    `gorm:"type:varchar(64);not null;uniqueIndex:idx_driver_sales_client" json:"client_sale_id"`
+	DriverID         string    `gorm:"type:varchar(64);not null;index" json:"driver_id"`
+	FromStationID    string    `gorm:"type:uuid;not null" json:"from_station_id"`
+	ToStationID      string    `gorm:"type:uuid;not null" json:"to_station_id"`
+	PaymentMethod    string    `gorm:"type:varchar(20);not null" json:"payment_method"`
+	KKTSerial        string    `gorm:"type:varchar(50);not null" json:"kkt_serial"`
+	FiscalSign       string    `gorm:"type:varchar(100);not null" json:"fiscal_sign"`
+	FiscalDocNumber  int       `gorm:"not null" json:"fiscal_doc_number"`
+	Amount           float64   `gorm:"type:decimal(10,2);not null" json:"amount"`
+}
+
+// SalesWindow — окно продаж канала: открывается за OpenDaysBefore дней (0 — без ограничения)
+// и закрывается за CloseMinutesBefore минут до отправления с остановки посадки.
+type SalesWindow struct {
+	OpenDaysBefore     *int `json:"open_days_before,omitempty"`
+	CloseMinutesBefore *int `json:"close_minutes_before,omitempty"`
+}
+
+// StopQuota — сколько мест рейса можно продать с посадкой на остановке StationID.
+type StopQuota struct {
+	StationID string `json:"station_id"`
+	Seats     int    `json:"seats"`
 }
 
 // TableName возвращает имя таблицы для GORM (Ticket).
@@ -53,6 +497,26 @@ func (Ticket) TableName() string {
 	return "tickets"
 }
 
+// TableName возвращает имя таблицы для GORM (BaggageTicket).
+func (BaggageTicket) TableName() string {
+	return "baggage_tickets"
+}
+
+// TableName возвращает имя таблицы для GORM (RefundPolicy).
+func (RefundPolicy) TableName() string {
+	return "refund_policies"
+}
+
+// TableName возвращает имя таблицы для GORM (FareComponentRule).
+func (FareComponentRule) TableName() string {
+	return "fare_component_rules"
+}
+
+// TableName возвращает имя таблицы для GORM (QRSigningKey).
+func (QRSigningKey) TableName() string {
+	return "qr_signing_keys"
+}
+
 // TableName возвращает имя таблицы для GORM (BoardingEvent).
 func (BoardingEvent) TableName() string {
 	return "boarding_events"
@@ -63,6 +527,51 @@ func (BoardingMark) TableName() string {
 	return "boarding_marks"
 }
 
+// TableName возвращает имя таблицы для GORM (ErasureRequest).
+func (ErasureRequest) TableName() string {
+	return "erasure_requests"
+}
+
+// TableName возвращает имя таблицы для GORM (AnonymizationLog).
+func (AnonymizationLog) TableName() string {
+	return "anonymization_log"
+}
+
+// TableName возвращает имя таблицы для GORM (TicketNameToken).
+func (TicketNameToken) TableName() string {
+	return "ticket_name_tokens"
+}
+
+// TableName возвращает имя таблицы для GORM (CashierShift).
+func (CashierShift) TableName() string {
+	return "cashier_shifts"
+}
+
+// TableName возвращает имя таблицы для GORM (ShiftOperation).
+func (ShiftOperation) TableName() string {
+	return "shift_operations"
+}
+
+// TableName возвращает имя таблицы для GORM (ReportJob).
+func (ReportJob) TableName() string {
+	return "report_jobs"
+}
+
+// TableName возвращает имя таблицы для GORM (WaitlistEntry).
+func (WaitlistEntry) TableName() string {
+	return "waitlist_entries"
+}
+
+// TableName возвращает имя таблицы для GORM (Voucher).
+func (Voucher) TableName() string {
+	return "vouchers"
+}
+
+// TableName возвращает имя таблицы для GORM (BoardingCorrection).
+func (BoardingCorrection) TableName() string {
+	return "boarding_corrections"
+}
+
 // BeforeCreate генерирует UUID и коды для новой записи (Ticket).
 func (t *Ticket) BeforeCreate(_ *gorm.DB) error {
 	if t.ID == "" {
@@ -77,6 +586,46 @@ func (t *Ticket) BeforeCreate(_ *gorm.DB) error {
 	return nil
 }
 
+// BeforeSave обновляет слепые индексы ПД и ключ шифрования записи.
+func (t *Ticket) BeforeSave(_ *gorm.DB) error {
+	if piiKeyring == nil {
+		return errPIIKeyringNotSet
+	}
+	t.PassengerDocIndex = blindIndex(t.PassengerDoc)
+	t.PhoneIndex = blindIndex(t.Phone)
+	t.EmailIndex = blindIndex(t.Email)
+	kid := piiKeyring.ActiveKeyID()
+	t.PIIKeyID = &kid
+	return nil
+}
+
+// BeforeCreate генерирует UUID и штрихкод бирки для новой записи (BaggageTicket).
+func (b *BaggageTicket) BeforeCreate(_ *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	if b.BarCode == "" {
+		b.BarCode = "BG" + uuid.New().String()[:12]
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (RefundPolicy).
+func (p *RefundPolicy) BeforeCreate(_ *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (FareComponentRule).
+func (r *FareComponentRule) BeforeCreate(_ *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // BeforeCreate генерирует UUID для новой записи (BoardingEvent).
 func (b *BoardingEvent) BeforeCreate(_ *gorm.DB) error {
 	if b.ID == "" {
@@ -92,3 +641,110 @@ func (b *BoardingMark) BeforeCreate(_ *gorm.DB) error {
 	}
 	return nil
 }
+
+// BeforeCreate генерирует UUID для новой записи (BoardingCorrection).
+func (b *BoardingCorrection) BeforeCreate(_ *gorm.DB) error {
+	if b.ID == "" {
+		b.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (ErasureRequest).
+func (e *ErasureRequest) BeforeCreate(_ *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (CashierShift).
+func (c *CashierShift) BeforeCreate(_ *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (ShiftOperation).
+func (o *ShiftOperation) BeforeCreate(_ *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (ReportJob).
+func (j *ReportJob) BeforeCreate(_ *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (WaitlistEntry).
+func (w *WaitlistEntry) BeforeCreate(_ *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName возвращает имя таблицы для GORM (TripSalesSettings).
+func (TripSalesSettings) TableName() string {
+	return "trip_sales_settings"
+}
+
+// TableName возвращает имя таблицы для GORM (DriverSale).
+func (DriverSale) TableName() string {
+	return "driver_sales"
+}
+
+// TableName возвращает имя таблицы для GORM (CarrierSettlement).
+func (CarrierSettlement) TableName() string {
+	return "carrier_settlements"
+}
+
+// BeforeCreate генерирует UUID для новой записи (CarrierSettlement).
+func (cs *CarrierSettlement) BeforeCreate(_ *gorm.DB) error {
+	if cs.ID == "" {
+		cs.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (DriverSale).
+func (d *DriverSale) BeforeCreate(_ *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (Voucher).
+func (v *Voucher) BeforeCreate(_ *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeSave обновляет слепые индексы контактов и ключ шифрования записи листа ожидания.
+func (w *WaitlistEntry) BeforeSave(_ *gorm.DB) error {
+	if piiKeyring == nil {
+		return errPIIKeyringNotSet
+	}
+	w.PhoneIndex = blindIndex(w.Phone)
+	w.EmailIndex = blindIndex(w.Email)
+	kid := piiKeyring.ActiveKeyID()
+	w.PIIKeyID = &kid
+	return nil
+}
+
+// BeforeCreate генерирует UUID для новой записи (AnonymizationLog).
+func (a *AnonymizationLog) BeforeCreate(_ *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
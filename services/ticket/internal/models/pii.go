@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	"github.com/vokzal-tech/go-common/pii"
+)
+
+// piiKeyring — набор ключей шифрования ПД, общий для сериализатора и хуков моделей.
+var piiKeyring *pii.Keyring
+
+// errPIIKeyringNotSet возвращается, если шифрование ПД не настроено до работы с БД.
+var errPIIKeyringNotSet = errors.New("PII keyring is not configured")
+
+// SetPIIKeyring настраивает шифрование полей с тегом serializer:pii. Вызывается при старте до миграций.
+func SetPIIKeyring(k *pii.Keyring) {
+	piiKeyring = k
+	schema.RegisterSerializer("pii", piiSerializer{})
+}
+
+// piiSerializer шифрует *string-поля при записи и расшифровывает при чтении.
+type piiSerializer struct{}
+
+// Scan расшифровывает значение из БД.
+func (piiSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var value *string
+	if dbValue != nil {
+		if piiKeyring == nil {
+			return errPIIKeyringNotSet
+		}
+		var raw string
+		switch v := dbValue.(type) {
+		case string:
+			raw = v
+		case []byte:
+			raw = string(v)
+		default:
+			return fmt.Errorf("unsupported PII column type %T", dbValue)
+		}
+		plaintext, err := piiKeyring.Decrypt(raw)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+		}
+		value = &plaintext
+	}
+	field.ReflectValueOf(ctx, dst).Set(reflect.ValueOf(value))
+	return nil
+}
+
+// Value шифрует значение активным ключом.
+func (piiSerializer) Value(_ context.Context, _ *schema.Field, _ reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(*string)
+	if !ok || value == nil {
+		return nil, nil
+	}
+	if piiKeyring == nil {
+		return nil, errPIIKeyringNotSet
+	}
+	return piiKeyring.Encrypt(*value)
+}
+
+// blindIndex возвращает слепой индекс значения или nil для пустого значения.
+func blindIndex(value *string) *string {
+	if value == nil || piiKeyring == nil {
+		return nil
+	}
+	idx := piiKeyring.BlindIndex(*value)
+	if idx == "" {
+		return nil
+	}
+	return &idx
+}
+
+// ReplaceNameTokens заменяет токены ФИО билета. Для пустого ФИО токены удаляются.
+func ReplaceNameTokens(tx *gorm.DB, ticketID string, name *string) error {
+	if err := tx.Where("ticket_id = ?", ticketID).Delete(&TicketNameToken{}).Error; err != nil {
+		return err
+	}
+	if name == nil || *name == "" {
+		return nil
+	}
+	if piiKeyring == nil {
+		return errPIIKeyringNotSet
+	}
+	tokens := piiKeyring.NameTokens(*name)
+	if len(tokens) == 0 {
+		tokens = []string{""}
+	}
+	rows := make([]TicketNameToken, len(tokens))
+	for i, token := range tokens {
+		rows[i] = TicketNameToken{TicketID: ticketID, Token: token}
+	}
+	return tx.Create(&rows).Error
+}
@@ -3,10 +3,16 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/vokzal-tech/go-common/dbtx"
 
 	"github.com/vokzal-tech/ticket-service/internal/models"
 )
@@ -16,32 +22,428 @@ var (
 	ErrTicketNotFound = errors.New("ticket not found")
 	// ErrSeatAlreadyTaken возвращается, когда место уже занято.
 	ErrSeatAlreadyTaken = errors.New("seat already taken")
+	// ErrTicketNotActive возвращается, когда билет успел вернуть или обменять параллельный запрос.
+	ErrTicketNotActive = errors.New("ticket is no longer active")
 	// ErrBoardingAlreadyStarted возвращается, когда посадка уже начата.
 	ErrBoardingAlreadyStarted = errors.New("boarding already started")
 	// ErrBoardingNotStarted возвращается, когда посадка ещё не начата.
 	ErrBoardingNotStarted = errors.New("boarding not started")
+	// ErrBoardingClosed возвращается, когда посадка уже завершена.
+	ErrBoardingClosed = errors.New("boarding already closed")
+	// ErrBoardingMarkNotFound возвращается, когда у билета нет действующей отметки посадки.
+	ErrBoardingMarkNotFound = errors.New("boarding mark not found")
+	// ErrBaggageNotFound возвращается, когда багажная квитанция не найдена.
+	ErrBaggageNotFound = errors.New("baggage ticket not found")
+	// ErrTripNotFound возвращается, когда рейс не найден.
+	ErrTripNotFound = errors.New("trip not found")
+	// ErrRefundPolicyNotFound возвращается, когда политика возврата не найдена.
+	ErrRefundPolicyNotFound = errors.New("refund policy not found")
+	// ErrFareComponentRuleNotFound возвращается, когда правило начисления сбора не найдено.
+	ErrFareComponentRuleNotFound = errors.New("fare component rule not found")
+	// ErrErasureRequestNotFound возвращается, когда запрос на удаление ПД не найден.
+	ErrErasureRequestNotFound = errors.New("erasure request not found")
+	// ErrShiftNotFound возвращается, когда кассовая смена не найдена.
+	ErrShiftNotFound = errors.New("cashier shift not found")
+	// ErrShiftAlreadyOpen возвращается, когда у кассира или рабочего места уже есть открытая смена.
+	ErrShiftAlreadyOpen = errors.New("cashier or workstation already has an open shift")
+	// ErrShiftClosed возвращается при операции с закрытой сменой.
+	ErrShiftClosed = errors.New("cashier shift is closed")
+	// ErrReportJobNotFound возвращается, когда задание на построение отчёта не найдено.
+	ErrReportJobNotFound = errors.New("report job not found")
+	// ErrWaitlistEntryNotFound возвращается, когда запись листа ожидания или предложение не найдены.
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+	// ErrVoucherNotFound возвращается, когда ваучер или промокод не найден.
+	ErrVoucherNotFound = errors.New("voucher not found")
+	// ErrVoucherCodeTaken возвращается, когда ваучер с таким кодом уже есть.
+	ErrVoucherCodeTaken = errors.New("voucher code already exists")
+	// ErrCarrierNotFound возвращается, когда перевозчик не найден.
+	ErrCarrierNotFound = errors.New("carrier not found")
+	// ErrSettlementNotFound возвращается, когда расчёт с перевозчиком не найден.
+	ErrSettlementNotFound = errors.New("carrier settlement not found")
+	// ErrSalesSettingsNotFound возвращается, когда у рейса нет своих настроек продажи.
+	ErrSalesSettingsNotFound = errors.New("trip sales settings not found")
+	// ErrDriverSaleNotFound возвращается, когда продажа водителя не найдена.
+	ErrDriverSaleNotFound = errors.New("driver sale not found")
+)
+
+// ShiftOperationTotal — итог операций смены одного типа и способа оплаты.
+type ShiftOperationTotal struct {
+	Type          string  `gorm:"column:type"`
+	PaymentMethod string  `gorm:"column:payment_method"`
+	Count         int     `gorm:"column:count"`
+	Amount        float64 `gorm:"column:amount"`
+}
+
+// TripRefundInfo — сведения о рейсе, от которых зависят правила возврата.
+type TripRefundInfo struct {
+	DepartureTime *time.Time `gorm:"column:departure_time"`
+	CarrierID     *string    `gorm:"column:carrier_id"`
+	RouteID       string     `gorm:"column:route_id"`
+	Status        string     `gorm:"column:status"`
+}
+
+// TripVehicle — автобус рейса: основной (VehicleID nil, номер 1, автобус, водитель и перрон рейса)
+// или добавленный диспетчером (trip_vehicles schedule-service, перрон по умолчанию — перрон рейса).
+// Capacity nil — автобус не назначен; Sold — действующие билеты, закреплённые за автобусом.
+type TripVehicle struct {
+	VehicleID   *string `gorm:"column:vehicle_id" json:"vehicle_id,omitempty"`
+	BusID       *string `gorm:"column:bus_id" json:"bus_id,omitempty"`
+	PlateNumber *string `gorm:"column:plate_number" json:"plate_number,omitempty"`
+	DriverID    *string `gorm:"column:driver_id" json:"driver_id,omitempty"`
+	Platform    *string `gorm:"column:platform" json:"platform,omitempty"`
+	Capacity    *int    `gorm:"column:capacity" json:"capacity,omitempty"`
+	Number      int     `gorm:"column:number" json:"number"`
+	Sold        int     `gorm:"column:sold" json:"sold"`
+}
+
+// TicketSearchFilter — условия поиска билетов кассиром. Пустые условия не применяются.
+// ПД задаются слепыми индексами и токенами ФИО, открытых значений в запросе к БД нет.
+type TicketSearchFilter struct {
+	DateFrom   *time.Time
+	DateTo     *time.Time
+	DocIndex   string
+	PhoneIndex string
+	EmailIndex string
+	// Code — код бронирования: штрихкод или код QR билета.
+	Code      string
+	StationID string
+	Status    string
+	// NameTokens — слепые токены ФИО из запроса.
+	NameTokens []string
+	// MinNameMatches — сколько токенов ФИО должно совпасть, чтобы билет попал в выдачу.
+	MinNameMatches int
+	Offset         int
+	Limit          int
+}
+
+// SalesReportQuery — параметры отчёта о продажах: полуинтервал [From, To), разрезы группировки
+// (ключи SalesDimensions) и необязательные фильтры по разрезам.
+// FareOnly — суммы билетов только по тарифу перевозчика (составляющая "fare"), без сборов вокзала
+// и страховки; используется в расчётах с перевозчиками. Билеты без составляющих учитываются всей ценой.
+type SalesReportQuery struct {
+	From     time.Time
+	To       time.Time
+	Filters  map[string]string
+	GroupBy  []string
+	FareOnly bool
+}
+
+// SalesReportRow — строка отчёта о продажах. Поля разрезов, не входящих в группировку, пусты.
+// Продажи и возвраты учитываются датой операции: продажа — датой продажи, возврат — датой возврата.
+type SalesReportRow struct {
+	Day               *time.Time `gorm:"column:day" json:"day,omitempty"`
+	RouteID           *string    `gorm:"column:route_id" json:"route_id,omitempty"`
+	RouteName         *string    `gorm:"column:route_name" json:"route_name,omitempty"`
+	CarrierID         *string    `gorm:"column:carrier_id" json:"carrier_id,omitempty"`
+	StationID         *string    `gorm:"column:station_id" json:"station_id,omitempty"`
+	StationName       *string    `gorm:"column:station_name" json:"station_name,omitempty"`
+	CashierID         *string    `gorm:"column:cashier_id" json:"cashier_id,omitempty"`
+	PaymentMethod     *string    `gorm:"column:payment_method" json:"payment_method,omitempty"`
+	PassengerCategory *string    `gorm:"column:passenger_category" json:"passenger_category,omitempty"`
+	TicketsSold       int64      `gorm:"column:tickets_sold" json:"tickets_sold"`
+	TicketsAmount     float64    `gorm:"column:tickets_amount" json:"tickets_amount"`
+	BaggageSold       int64      `gorm:"column:baggage_sold" json:"baggage_sold"`
+	BaggageAmount     float64    `gorm:"column:baggage_amount" json:"baggage_amount"`
+	// ExchangeFees — сборы за обмен; ExchangedAmount — стоимость исходных билетов, зачтённая при обмене.
+	ExchangeFees    float64 `gorm:"column:exchange_fees" json:"exchange_fees"`
+	ExchangedAmount float64 `gorm:"column:exchanged_amount" json:"exchanged_amount"`
+	Refunds         int64   `gorm:"column:refunds" json:"refunds"`
+	RefundsAmount   float64 `gorm:"column:refunds_amount" json:"refunds_amount"`
+	// Penalties — удержанные при возврате штрафы и сервисные сборы (входят в выручку).
+	Penalties float64 `gorm:"column:penalties" json:"penalties"`
+	// GrossRevenue — продажи билетов и багажа со сборами за обмен за вычетом зачтённой стоимости
+	// обменянных билетов; NetRevenue — GrossRevenue за вычетом выплат по возвратам.
+	GrossRevenue float64 `gorm:"column:gross_revenue" json:"gross_revenue"`
+	NetRevenue   float64 `gorm:"column:net_revenue" json:"net_revenue"`
+	// FeeTickets — проданные билеты без сбора вокзала в цене (с ним сбор уже оплатил пассажир);
+	// заполняется при FareOnly.
+	FeeTickets int64 `gorm:"column:fee_tickets" json:"-"`
+}
+
+// SalesDimensions — разрезы отчёта о продажах в порядке колонок строки отчёта.
+var SalesDimensions = []string{"day", "route", "carrier", "station", "cashier", "payment_method", "passenger_category"}
+
+// salesDimension — колонки разреза в строке отчёта и выражение для фильтра по нему.
+type salesDimension struct {
+	filter  string
+	columns []string
+}
+
+// salesDimensionColumns описывает разрезы отчёта о продажах. Станция — станция отправления маршрута
+// (первая остановка), кассир — продавший билет (для багажа — кассир смены продажи).
+var salesDimensionColumns = map[string]salesDimension{
+	"day":                {columns: []string{"e.day"}},
+	"route":              {filter: "rt.id::text", columns: []string{"rt.id::text AS route_id", "rt.name AS route_name"}},
+	"carrier":            {filter: "rt.carrier_id::text", columns: []string{"rt.carrier_id::text AS carrier_id"}},
+	"station":            {filter: "origin.station_id", columns: []string{"origin.station_id", "stn.name AS station_name"}},
+	"cashier":            {filter: "e.cashier_id", columns: []string{"e.cashier_id"}},
+	"payment_method":     {filter: "e.payment_method", columns: []string{"e.payment_method"}},
+	"passenger_category": {filter: "e.passenger_category", columns: []string{"e.passenger_category"}},
+}
+
+// PIIField — поле ПД билета, по которому возможен поиск через слепой индекс.
+type PIIField string
+
+// Поля ПД со слепыми индексами.
+const (
+	PIIFieldDocument PIIField = "passenger_doc_index"
+	PIIFieldPhone    PIIField = "phone_index"
+	PIIFieldEmail    PIIField = "email_index"
 )
 
+// piiColumns — колонки с ПД и их индексами, перезаписываемые при перешифровании.
+var piiColumns = []string{
+	"passenger_name", "passenger_doc", "phone", "email",
+	"passenger_doc_index", "phone_index", "email_index", "pii_key_id",
+}
+
 // TicketRepository — интерфейс репозитория билетов.
 type TicketRepository interface {
 	Create(ctx context.Context, ticket *models.Ticket) error
 	FindByID(ctx context.Context, id string) (*models.Ticket, error)
 	FindByQRCode(ctx context.Context, qrCode string) (*models.Ticket, error)
 	FindByTripID(ctx context.Context, tripID string) ([]*models.Ticket, error)
-	CheckSeatAvailability(ctx context.Context, tripID, seatID string) (bool, error)
+	FindByPIIIndex(ctx context.Context, field PIIField, index string, limit int) ([]*models.Ticket, error)
+	FindForReencryption(ctx context.Context, activeKeyID string, limit int) ([]*models.Ticket, error)
+	SavePII(ctx context.Context, ticket *models.Ticket) error
+	ReplaceNameTokens(ctx context.Context, ticket *models.Ticket) error
+	CheckSeatAvailability(ctx context.Context, tripID string, vehicleID *string, seatID string) (bool, error)
+	GetTripVehicles(ctx context.Context, tripID string) ([]*TripVehicle, error)
 	Update(ctx context.Context, ticket *models.Ticket) error
+	UpdateIfActive(ctx context.Context, ticket *models.Ticket) error
+	Exchange(ctx context.Context, original, replacement *models.Ticket) error
 	Delete(ctx context.Context, id string) error
-	GetTripDepartureTime(ctx context.Context, tripID string) (*time.Time, error)
+	GetTripRefundInfo(ctx context.Context, tripID string) (*TripRefundInfo, error)
 	GetDashboardStats(ctx context.Context, date string) (ticketsSold, ticketsReturned int, revenue float64, err error)
+	Search(ctx context.Context, filter *TicketSearchFilter) ([]*models.Ticket, int64, error)
+}
+
+// BaggageRepository — интерфейс репозитория багажных квитанций.
+type BaggageRepository interface {
+	Create(ctx context.Context, baggage *models.BaggageTicket) error
+	FindByID(ctx context.Context, id string) (*models.BaggageTicket, error)
+	FindByTicketID(ctx context.Context, ticketID string) ([]*models.BaggageTicket, error)
+	Update(ctx context.Context, baggage *models.BaggageTicket) error
+}
+
+// RefundPolicyRepository — интерфейс репозитория версий политик возврата.
+type RefundPolicyRepository interface {
+	Create(ctx context.Context, policy *models.RefundPolicy) error
+	FindByID(ctx context.Context, id string) (*models.RefundPolicy, error)
+	FindAll(ctx context.Context, activeOnly bool) ([]*models.RefundPolicy, error)
+	FindApplicable(ctx context.Context, routeID string, carrierID *string) (*models.RefundPolicy, error)
+	Deactivate(ctx context.Context, id string) error
+}
+
+// FareComponentRepository — интерфейс репозитория правил начисления сборов к тарифу.
+type FareComponentRepository interface {
+	Create(ctx context.Context, rule *models.FareComponentRule) error
+	FindByID(ctx context.Context, id string) (*models.FareComponentRule, error)
+	FindAll(ctx context.Context, activeOnly bool) ([]*models.FareComponentRule, error)
+	FindForTrip(ctx context.Context, tripID string) ([]*models.FareComponentRule, error)
+	Deactivate(ctx context.Context, id string) error
+}
+
+// QRKeyRepository — интерфейс репозитория ключей подписи QR-кодов.
+type QRKeyRepository interface {
+	FindActive(ctx context.Context) (*models.QRSigningKey, error)
+	FindPublished(ctx context.Context, now time.Time) ([]*models.QRSigningKey, error)
+	Rotate(ctx context.Context, key *models.QRSigningKey, verifyUntil time.Time) error
+	FindForReencryption(ctx context.Context, activeKeyID string) ([]*models.QRSigningKey, error)
+	SaveSeed(ctx context.Context, key *models.QRSigningKey) error
 }
 
 // BoardingRepository — интерфейс репозитория событий и отметок посадки.
 type BoardingRepository interface {
 	CreateEvent(ctx context.Context, event *models.BoardingEvent) error
+	CloseEvent(ctx context.Context, event *models.BoardingEvent) (int64, error)
 	FindEventByTripID(ctx context.Context, tripID string) (*models.BoardingEvent, error)
 	CreateMark(ctx context.Context, mark *models.BoardingMark) error
 	FindMarksByTripID(ctx context.Context, tripID string) ([]*models.BoardingMark, error)
 	CheckIfMarked(ctx context.Context, ticketID string) (bool, error)
+	FindActiveMarkByTicketID(ctx context.Context, ticketID string) (*models.BoardingMark, error)
+	CancelMark(ctx context.Context, mark *models.BoardingMark, correction *models.BoardingCorrection, noShowAt *time.Time) error
+	CorrectMark(ctx context.Context, ticket *models.Ticket, correction *models.BoardingCorrection) error
+	FindCorrectionsByTicketID(ctx context.Context, ticketID string) ([]*models.BoardingCorrection, error)
+}
+
+// RetentionRepository — интерфейс репозитория обезличивания ПД и запросов субъектов на удаление.
+type RetentionRepository interface {
+	FindExpiredTickets(ctx context.Context, tripBefore time.Time, limit int) ([]*models.Ticket, error)
+	Anonymize(ctx context.Context, ticketIDs []string, logs []*models.AnonymizationLog) error
+	FindExpiredWaitlistEntries(ctx context.Context, tripBefore time.Time, limit int) ([]*models.WaitlistEntry, error)
+	FindWaitlistEntriesByContact(ctx context.Context, field PIIField, index string) ([]*models.WaitlistEntry, error)
+	AnonymizeWaitlistEntries(ctx context.Context, entryIDs []string, logs []*models.AnonymizationLog) error
+	CreateLogs(ctx context.Context, logs []*models.AnonymizationLog) error
+	FindLogs(ctx context.Context, erasureRequestID, entityID string, limit int) ([]*models.AnonymizationLog, error)
+	CreateErasureRequest(ctx context.Context, req *models.ErasureRequest) error
+	UpdateErasureRequest(ctx context.Context, req *models.ErasureRequest) error
+	MarkErasureReported(ctx context.Context, id, service string, at time.Time) error
+	FindErasureRequestByID(ctx context.Context, id string) (*models.ErasureRequest, error)
+}
+
+// ShiftRepository — интерфейс репозитория кассовых смен и движения денег в них.
+type ShiftRepository interface {
+	Create(ctx context.Context, shift *models.CashierShift) error
+	FindByID(ctx context.Context, id string) (*models.CashierShift, error)
+	FindOpenByCashier(ctx context.Context, cashierID string) (*models.CashierShift, error)
+	FindOpenByWorkstation(ctx context.Context, workstationID string) (*models.CashierShift, error)
+	FindAll(ctx context.Context, workstationID, cashierID, date string, limit int) ([]*models.CashierShift, error)
+	Close(ctx context.Context, shift *models.CashierShift) error
+	AddOperation(ctx context.Context, op *models.ShiftOperation) error
+	FindOperations(ctx context.Context, shiftID string) ([]*models.ShiftOperation, error)
+	SummarizeOperations(ctx context.Context, shiftID string) ([]ShiftOperationTotal, error)
+	LinkZReport(ctx context.Context, kktSerial, zReportID string, kktShiftNumber int, reportedAt time.Time) (int64, error)
+}
+
+// ReportRepository — интерфейс репозитория отчётов о продажах и заданий на их построение.
+type ReportRepository interface {
+	SalesReport(ctx context.Context, query *SalesReportQuery) ([]*SalesReportRow, error)
+	CreateJob(ctx context.Context, job *models.ReportJob) error
+	FindJobByID(ctx context.Context, id string) (*models.ReportJob, error)
+	FindJobsByUser(ctx context.Context, userID string, limit int) ([]*models.ReportJob, error)
+	ClaimJob(ctx context.Context, staleBefore time.Time) (*models.ReportJob, error)
+	SaveJob(ctx context.Context, job *models.ReportJob) error
+	DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error)
+}
+
+// TripSeats — вместимость рейса (по назначенным автобусам, nil — основной автобус не назначен), проданные
+// билеты и места, удерживаемые предложениями листа ожидания.
+type TripSeats struct {
+	Capacity *int   `gorm:"column:capacity"`
+	TripID   string `gorm:"column:trip_id"`
+	Status   string `gorm:"column:status"`
+	Sold     int    `gorm:"column:sold"`
+	Held     int    `gorm:"column:held"`
+}
+
+// WaitlistSegment — участок маршрута, на всех перегонах которого есть свободное место: FromStationIDs —
+// остановки, с которых можно сесть, ToStationIDs — на которых можно сойти. FromStart и ToEnd — участок
+// начинается с начальной станции и заканчивается конечной: подходят и записи без остановок.
+type WaitlistSegment struct {
+	FromStationIDs []string
+	ToStationIDs   []string
+	FromStart      bool
+	ToEnd          bool
+}
+
+// WaitlistStatsFilter — условия статистики листа ожидания: рейс и период постановки в очередь [From, To).
+type WaitlistStatsFilter struct {
+	From   *time.Time
+	To     *time.Time
+	TripID string
+}
+
+// WaitlistStatusCount — число записей листа ожидания в статусе; Offered — сколько из них получили предложение.
+type WaitlistStatusCount struct {
+	Status  string `gorm:"column:status" json:"status"`
+	Count   int    `gorm:"column:count" json:"count"`
+	Offered int    `gorm:"column:offered" json:"offered"`
+}
+
+// WaitlistRepository — интерфейс репозитория листа ожидания.
+type WaitlistRepository interface {
+	Create(ctx context.Context, entry *models.WaitlistEntry) error
+	FindByID(ctx context.Context, id string) (*models.WaitlistEntry, error)
+	FindByOfferToken(ctx context.Context, token string) (*models.WaitlistEntry, error)
+	FindByTrip(ctx context.Context, tripID, status string) ([]*models.WaitlistEntry, error)
+	FindOpenByTrip(ctx context.Context, tripID string) ([]*models.WaitlistEntry, error)
+	FindOpenByContact(ctx context.Context, field PIIField, index string) ([]*models.WaitlistEntry, error)
+	FindExpiredOffers(ctx context.Context, now time.Time, limit int) ([]*models.WaitlistEntry, error)
+	LockTrip(ctx context.Context, tripID string) error
+	NextWaiting(ctx context.Context, tripID string, segments []WaitlistSegment) (*models.WaitlistEntry, error)
+	UpdateIfStatus(ctx context.Context, entry *models.WaitlistEntry, status string) (bool, error)
+	IsSeatHeld(ctx context.Context, tripID, seatID, exceptEntryID string) (bool, error)
+	GetTripSeats(ctx context.Context, tripID string) (*TripSeats, error)
+	Stats(ctx context.Context, filter *WaitlistStatsFilter) ([]*WaitlistStatusCount, error)
+}
+
+// VoucherFilter — условия списка ваучеров. Пустые условия не применяются.
+type VoucherFilter struct {
+	Kind           string
+	SourceTicketID string
+	ActiveOnly     bool
+}
+
+// VoucherUsage — билеты, проданные по ваучеру, и сумма предоставленных скидок.
+type VoucherUsage struct {
+	Tickets  int     `gorm:"column:tickets" json:"tickets"`
+	Discount float64 `gorm:"column:discount" json:"discount"`
+}
+
+// VoucherRepository — интерфейс репозитория ваучеров и промокодов.
+type VoucherRepository interface {
+	Create(ctx context.Context, voucher *models.Voucher) error
+	CreateForTicket(ctx context.Context, voucher *models.Voucher) (bool, error)
+	FindByID(ctx context.Context, id string) (*models.Voucher, error)
+	FindByCode(ctx context.Context, code string) (*models.Voucher, error)
+	FindAll(ctx context.Context, filter *VoucherFilter) ([]*models.Voucher, error)
+	Redeem(ctx context.Context, id string) (bool, error)
+	Deactivate(ctx context.Context, id string) error
+	Usage(ctx context.Context, id string) (*VoucherUsage, error)
+}
+
+// CarrierInfo — перевозчик (таблица carriers schedule-service) со ставками для расчётов.
+type CarrierInfo struct {
+	ContractNumber *string `gorm:"column:contract_number"`
+	ID             string  `gorm:"column:id"`
+	Name           string  `gorm:"column:name"`
+	LegalName      string  `gorm:"column:legal_name"`
+	INN            string  `gorm:"column:inn"`
+	CommissionRate float64 `gorm:"column:commission_rate"`
+	ServiceFee     float64 `gorm:"column:service_fee"`
+	IsActive       bool    `gorm:"column:is_active"`
+}
+
+// SettlementFilter — условия списка расчётов с перевозчиками. Пустые условия не применяются.
+type SettlementFilter struct {
+	CarrierID string
+	Status    string
+}
+
+// SettlementRepository — интерфейс репозитория расчётов с перевозчиками.
+type SettlementRepository interface {
+	FindCarrier(ctx context.Context, id string) (*CarrierInfo, error)
+	FindActiveCarriers(ctx context.Context) ([]*CarrierInfo, error)
+	LockCarrier(ctx context.Context, carrierID string) error
+	HasOverlap(ctx context.Context, carrierID, periodFrom, periodTo, exceptID string) (bool, error)
+	Create(ctx context.Context, settlement *models.CarrierSettlement) error
+	FindByID(ctx context.Context, id string) (*models.CarrierSettlement, error)
+	FindAll(ctx context.Context, filter *SettlementFilter) ([]*models.CarrierSettlement, error)
+	UpdateDraft(ctx context.Context, settlement *models.CarrierSettlement) (bool, error)
+	DeleteDraft(ctx context.Context, id string) (bool, error)
+}
+
+// TripSalesInfo — сведения о рейсе, от которых зависит продажа: плановое отправление с начальной станции,
+// задержка, статус и остановки маршрута по порядку.
+type TripSalesInfo struct {
+	DepartureTime *time.Time `gorm:"column:departure_time"`
+	Status        string     `gorm:"column:status"`
+	Stops         []TripStop `gorm:"-"`
+	DelayMinutes  int        `gorm:"column:delay_minutes"`
+}
+
+// TripStop — остановка маршрута рейса; OffsetMin — минуты в пути от начальной станции.
+type TripStop struct {
+	StationID string `gorm:"column:station_id" json:"station_id"`
+	OffsetMin int    `gorm:"column:offset_min" json:"offset_min"`
+}
+
+// SalesRepository — интерфейс репозитория настроек продажи рейсов.
+type SalesRepository interface {
+	GetTripSalesInfo(ctx context.Context, tripID string) (*TripSalesInfo, error)
+	FindSettings(ctx context.Context, tripID string) (*models.TripSalesSettings, error)
+	SaveSettings(ctx context.Context, settings *models.TripSalesSettings) error
+	DeleteSettings(ctx context.Context, tripID string) error
+	LockTrip(ctx context.Context, tripID string) error
+	CountSoldByStop(ctx context.Context, tripID string) (map[string]int, error)
+}
+
+// DriverSaleRepository — интерфейс репозитория продаж водителей в пути.
+type DriverSaleRepository interface {
+	Create(ctx context.Context, sale *models.DriverSale) error
+	FindByClientSaleID(ctx context.Context, deviceID, clientSaleID string) (*models.DriverSale, error)
+	FindByTripID(ctx context.Context, tripID string) ([]*models.DriverSale, error)
 }
 
 type ticketRepository struct {
@@ -52,6 +454,54 @@ type boardingRepository struct {
 	db *gorm.DB
 }
 
+type baggageRepository struct {
+	db *gorm.DB
+}
+
+type refundPolicyRepository struct {
+	db *gorm.DB
+}
+
+type fareComponentRepository struct {
+	db *gorm.DB
+}
+
+type qrKeyRepository struct {
+	db *gorm.DB
+}
+
+type retentionRepository struct {
+	db *gorm.DB
+}
+
+type shiftRepository struct {
+	db *gorm.DB
+}
+
+type reportRepository struct {
+	db *gorm.DB
+}
+
+type waitlistRepository struct {
+	db *gorm.DB
+}
+
+type voucherRepository struct {
+	db *gorm.DB
+}
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+type salesRepository struct {
+	db *gorm.DB
+}
+
+type driverSaleRepository struct {
+	db *gorm.DB
+}
+
 // NewTicketRepository создаёт репозиторий билетов.
 func NewTicketRepository(db *gorm.DB) TicketRepository {
 	return &ticketRepository{db: db}
@@ -62,14 +512,34 @@ func NewBoardingRepository(db *gorm.DB) BoardingRepository {
 	return &boardingRepository{db: db}
 }
 
+// NewBaggageRepository создаёт репозиторий багажных квитанций.
+func NewBaggageRepository(db *gorm.DB) BaggageRepository {
+	return &baggageRepository{db: db}
+}
+
+// NewRefundPolicyRepository создаёт репозиторий политик возврата.
+func NewRefundPolicyRepository(db *gorm.DB) RefundPolicyRepository {
+	return &refundPolicyRepository{db: db}
+}
+
+// NewFareComponentRepository создаёт репозиторий правил начисления сборов.
+func NewFareComponentRepository(db *gorm.DB) FareComponentRepository {
+	return &fareComponentRepository{db: db}
+}
+
+// NewQRKeyRepository создаёт репозиторий ключей подписи QR-кодов.
+func NewQRKeyRepository(db *gorm.DB) QRKeyRepository {
+	return &qrKeyRepository{db: db}
+}
+
 // Create создаёт билет.
 func (r *ticketRepository) Create(ctx context.Context, ticket *models.Ticket) error {
-	return r.db.WithContext(ctx).Create(ticket).Error
+	return dbtx.From(ctx, r.db).Create(ticket).Error
 }
 
 func findFirstBy[T any](db *gorm.DB, ctx context.Context, query string, arg any, notFoundErr error) (*T, error) {
 	var t T
-	if err := db.WithContext(ctx).First(&t, query, arg).Error; err != nil {
+	if err := dbtx.From(ctx, db).First(&t, query, arg).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, notFoundErr
 		}
@@ -88,16 +558,71 @@ func (r *ticketRepository) FindByQRCode(ctx context.Context, qrCode string) (*mo
 
 func (r *ticketRepository) FindByTripID(ctx context.Context, tripID string) ([]*models.Ticket, error) {
 	var tickets []*models.Ticket
-	if err := r.db.WithContext(ctx).Where("trip_id = ?", tripID).Find(&tickets).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).Where("trip_id = ?", tripID).Find(&tickets).Error; err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// FindByPIIIndex ищет билеты по слепому индексу поля ПД, новые первыми.
+func (r *ticketRepository) FindByPIIIndex(ctx context.Context, field PIIField, index string, limit int) ([]*models.Ticket, error) {
+	switch field {
+	case PIIFieldDocument, PIIFieldPhone, PIIFieldEmail:
+	default:
+		return nil, fmt.Errorf("unsupported PII field %q", field)
+	}
+	var tickets []*models.Ticket
+	err := dbtx.From(ctx, r.db).
+		Where(string(field)+" = ?", index).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&tickets).Error
+	if err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// FindForReencryption возвращает билеты, ПД которых не зашифрованы или зашифрованы не активным ключом,
+// а также билеты с ФИО без токенов нечёткого поиска (проданные до их появления).
+func (r *ticketRepository) FindForReencryption(ctx context.Context, activeKeyID string, limit int) ([]*models.Ticket, error) {
+	var tickets []*models.Ticket
+	err := dbtx.From(ctx, r.db).
+		Where("pii_key_id IS NULL OR pii_key_id <> ?", activeKeyID).
+		Or("passenger_name IS NOT NULL AND NOT EXISTS (SELECT 1 FROM ticket_name_tokens WHERE ticket_name_tokens.ticket_id = tickets.id)").
+		Limit(limit).
+		Find(&tickets).Error
+	if err != nil {
 		return nil, err
 	}
 	return tickets, nil
 }
 
-func (r *ticketRepository) CheckSeatAvailability(ctx context.Context, tripID, seatID string) (bool, error) {
+// SavePII перезаписывает только ПД билета активным ключом. Хуки модели не вызываются,
+// чтобы не менять updated_at, поэтому индексы и токены ФИО пересчитываются явно.
+func (r *ticketRepository) SavePII(ctx context.Context, ticket *models.Ticket) error {
+	if err := ticket.BeforeSave(nil); err != nil {
+		return err
+	}
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(ticket).Select(piiColumns).UpdateColumns(ticket).Error; err != nil {
+			return err
+		}
+		return models.ReplaceNameTokens(tx, ticket.ID, ticket.PassengerName)
+	})
+}
+
+// ReplaceNameTokens пересоздаёт токены ФИО билета для поиска по имени. Вызывается в транзакции
+// выписки билета: остальные изменения билета ФИО не меняют.
+func (r *ticketRepository) ReplaceNameTokens(ctx context.Context, ticket *models.Ticket) error {
+	return models.ReplaceNameTokens(dbtx.From(ctx, r.db), ticket.ID, ticket.PassengerName)
+}
+
+// CheckSeatAvailability проверяет, что место свободно в автобусе рейса (vehicleID nil — основной автобус).
+func (r *ticketRepository) CheckSeatAvailability(ctx context.Context, tripID string, vehicleID *string, seatID string) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&models.Ticket{}).
-		Where("trip_id = ? AND seat_id = ? AND status = ?", tripID, seatID, "active").
+	err := dbtx.From(ctx, r.db).Model(&models.Ticket{}).
+		Where("trip_id = ? AND vehicle_id IS NOT DISTINCT FROM ? AND seat_id = ? AND status = ?", tripID, vehicleID, seatID, "active").
 		Count(&count).Error
 	if err != nil {
 		return false, err
@@ -105,12 +630,74 @@ func (r *ticketRepository) CheckSeatAvailability(ctx context.Context, tripID, se
 	return count == 0, nil
 }
 
+// GetTripVehicles возвращает автобусы рейса по номерам: основной (trips) и добавленные (trip_vehicles)
+// с вместимостью (buses) и числом проданных на каждый билетов.
+func (r *ticketRepository) GetTripVehicles(ctx context.Context, tripID string) ([]*TripVehicle, error) {
+	var vehicles []*TripVehicle
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT v.vehicle_id, v.number, v.bus_id, v.driver_id, v.platform, b.plate_number, b.capacity,
+			(SELECT COUNT(*) FROM tickets tk
+				WHERE tk.trip_id = ? AND tk.status = 'active' AND tk.vehicle_id IS NOT DISTINCT FROM v.vehicle_id) AS sold
+		FROM (
+			SELECT NULL::uuid AS vehicle_id, 1 AS number, t.bus_id, t.driver_id, t.platform
+			FROM trips t WHERE t.id = ?
+			UNION ALL
+			SELECT tv.id, tv.number, tv.bus_id, tv.driver_id, COALESCE(tv.platform, t.platform)
+			FROM trip_vehicles tv JOIN trips t ON t.id = tv.trip_id WHERE tv.trip_id = ?
+		) v
+		LEFT JOIN buses b ON b.id = v.bus_id
+		ORDER BY v.number
+	`, tripID, tripID, tripID).Scan(&vehicles).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(vehicles) == 0 {
+		return nil, ErrTripNotFound
+	}
+	return vehicles, nil
+}
+
 func (r *ticketRepository) Update(ctx context.Context, ticket *models.Ticket) error {
-	return r.db.WithContext(ctx).Save(ticket).Error
+	return dbtx.From(ctx, r.db).Save(ticket).Error
+}
+
+// UpdateIfActive сохраняет билет, только если в БД он всё ещё действующий; иначе — ErrTicketNotActive
+// (билет вернули или обменяли параллельно).
+func (r *ticketRepository) UpdateIfActive(ctx context.Context, ticket *models.Ticket) error {
+	res := dbtx.From(ctx, r.db).Model(ticket).Where("status = ?", "active").Select("*").Updates(ticket)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected != 1 {
+		return ErrTicketNotActive
+	}
+	return nil
+}
+
+// Exchange в одной транзакции выписывает билет взамен исходного, помечает исходный как обменянный
+// и переносит на новый билет действующие багажные квитанции. Исходный билет обновляется, только если
+// в БД он всё ещё действующий; иначе — ErrTicketNotActive (билет вернули или обменяли параллельно).
+func (r *ticketRepository) Exchange(ctx context.Context, original, replacement *models.Ticket) error {
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(replacement).Error; err != nil {
+			return err
+		}
+		original.ExchangedToID = &replacement.ID
+		res := tx.Model(original).Where("status = ?", "active").Select("*").Updates(original)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected != 1 {
+			return ErrTicketNotActive
+		}
+		return tx.Model(&models.BaggageTicket{}).
+			Where("ticket_id = ? AND status = ?", original.ID, "active").
+			Updates(map[string]interface{}{"ticket_id": replacement.ID, "trip_id": replacement.TripID}).Error
+	})
 }
 
 func (r *ticketRepository) Delete(ctx context.Context, id string) error {
-	result := r.db.WithContext(ctx).Delete(&models.Ticket{}, "id = ?", id)
+	result := dbtx.From(ctx, r.db).Delete(&models.Ticket{}, "id = ?", id)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -120,20 +707,27 @@ func (r *ticketRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
-// GetTripDepartureTime возвращает время отправления рейса (date + schedule.departure_time из БД).
-func (r *ticketRepository) GetTripDepartureTime(ctx context.Context, tripID string) (*time.Time, error) {
-	var dep time.Time
-	err := r.db.WithContext(ctx).Raw(
-		"SELECT (t.date + s.departure_time) AS dep FROM trips t JOIN schedules s ON s.id = t.schedule_id WHERE t.id = ?",
-		tripID,
-	).Scan(&dep).Error
+// GetTripRefundInfo возвращает время отправления (date + schedule.departure_time), маршрут,
+// перевозчика и статус рейса из БД trips+schedules+routes.
+func (r *ticketRepository) GetTripRefundInfo(ctx context.Context, tripID string) (*TripRefundInfo, error) {
+	var info TripRefundInfo
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT (t.date + s.departure_time) AS departure_time, s.route_id, rt.carrier_id, t.status
+		FROM trips t
+		JOIN schedules s ON s.id = t.schedule_id
+		JOIN routes rt ON rt.id = s.route_id
+		WHERE t.id = ?
+	`, tripID).Scan(&info).Error
 	if err != nil {
 		return nil, err
 	}
-	if dep.IsZero() {
-		return nil, nil
+	if info.RouteID == "" {
+		return nil, ErrTripNotFound
+	}
+	if info.DepartureTime != nil && info.DepartureTime.IsZero() {
+		info.DepartureTime = nil
 	}
-	return &dep, nil
+	return &info, nil
 }
 
 // GetDashboardStats возвращает агрегаты по билетам за дату (created_at::date = date).
@@ -144,7 +738,7 @@ func (r *ticketRepository) GetDashboardStats(ctx context.Context, date string) (
 		ReturnedCount int64   `gorm:"column:returned_count"`
 		Revenue       float64 `gorm:"column:revenue"`
 	}
-	err = r.db.WithContext(ctx).Raw(`
+	err = dbtx.From(ctx, r.db).Raw(`
 		SELECT
 			COUNT(*) FILTER (WHERE status IN ('active', 'used')) AS sold_count,
 			COUNT(*) FILTER (WHERE status = 'returned') AS returned_count,
@@ -158,14 +752,287 @@ func (r *ticketRepository) GetDashboardStats(ctx context.Context, date string) (
 	return int(row.SoldCount), int(row.ReturnedCount), row.Revenue, nil
 }
 
+// Search ищет билеты по фильтру и возвращает страницу результатов и общее число найденных.
+// При поиске по ФИО билеты упорядочены по числу совпавших токенов, иначе — новые первыми.
+// Дата и станция берутся из рейса: trips.date и остановки маршрута (routes.stops).
+func (r *ticketRepository) Search(ctx context.Context, filter *TicketSearchFilter) ([]*models.Ticket, int64, error) {
+	q := dbtx.From(ctx, r.db).Model(&models.Ticket{})
+	if filter.DateFrom != nil || filter.DateTo != nil || filter.StationID != "" {
+		q = q.Joins("JOIN trips ON trips.id = tickets.trip_id")
+	}
+	if filter.DateFrom != nil {
+		q = q.Where("trips.date >= ?", filter.DateFrom.Format("2006-01-02"))
+	}
+	if filter.DateTo != nil {
+		q = q.Where("trips.date <= ?", filter.DateTo.Format("2006-01-02"))
+	}
+	if filter.StationID != "" {
+		stop, err := json.Marshal([]map[string]string{{"station_id": filter.StationID}})
+		if err != nil {
+			return nil, 0, err
+		}
+		q = q.Joins("JOIN schedules ON schedules.id = trips.schedule_id").
+			Joins("JOIN routes ON routes.id = schedules.route_id").
+			Where("routes.stops @> ?::jsonb", string(stop))
+	}
+	for column, value := range map[string]string{
+		"tickets.passenger_doc_index": filter.DocIndex,
+		"tickets.phone_index":         filter.PhoneIndex,
+		"tickets.email_index":         filter.EmailIndex,
+		"tickets.status":              filter.Status,
+	} {
+		if value != "" {
+			q = q.Where(column+" = ?", value)
+		}
+	}
+	if filter.Code != "" {
+		q = q.Where("tickets.bar_code = ? OR tickets.qr_code = ?", filter.Code, filter.Code)
+	}
+	order := "tickets.created_at DESC"
+	if len(filter.NameTokens) > 0 {
+		matches := dbtx.From(ctx, r.db).Model(&models.TicketNameToken{}).
+			Select("ticket_id, COUNT(*) AS score").
+			Where("token IN ?", filter.NameTokens).
+			Group("ticket_id").
+			Having("COUNT(*) >= ?", filter.MinNameMatches)
+		q = q.Joins("JOIN (?) AS name_matches ON name_matches.ticket_id = tickets.id", matches)
+		order = "name_matches.score DESC, " + order
+	}
+
+	var total int64
+	if err := q.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var tickets []*models.Ticket
+	err := q.Select("tickets.*").
+		Order(order).
+		Offset(filter.Offset).
+		Limit(filter.Limit).
+		Find(&tickets).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return tickets, total, nil
+}
+
+// Create создаёт багажную квитанцию.
+func (r *baggageRepository) Create(ctx context.Context, baggage *models.BaggageTicket) error {
+	return dbtx.From(ctx, r.db).Create(baggage).Error
+}
+
+func (r *baggageRepository) FindByID(ctx context.Context, id string) (*models.BaggageTicket, error) {
+	return findFirstBy[models.BaggageTicket](r.db, ctx, "id = ?", id, ErrBaggageNotFound)
+}
+
+func (r *baggageRepository) FindByTicketID(ctx context.Context, ticketID string) ([]*models.BaggageTicket, error) {
+	var baggage []*models.BaggageTicket
+	if err := dbtx.From(ctx, r.db).Where("ticket_id = ?", ticketID).Order("created_at ASC").Find(&baggage).Error; err != nil {
+		return nil, err
+	}
+	return baggage, nil
+}
+
+func (r *baggageRepository) Update(ctx context.Context, baggage *models.BaggageTicket) error {
+	return dbtx.From(ctx, r.db).Save(baggage).Error
+}
+
+// Create сохраняет новую версию политики: номер версии — следующий после максимального для Code,
+// действующие версии с тем же Code деактивируются в той же транзакции.
+func (r *refundPolicyRepository) Create(ctx context.Context, policy *models.RefundPolicy) error {
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		var maxVersion int
+		if err := tx.Model(&models.RefundPolicy{}).
+			Where("code = ?", policy.Code).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.RefundPolicy{}).
+			Where("code = ? AND is_active = ?", policy.Code, true).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		policy.Version = maxVersion + 1
+		policy.IsActive = true
+		return tx.Create(policy).Error
+	})
+}
+
+func (r *refundPolicyRepository) FindByID(ctx context.Context, id string) (*models.RefundPolicy, error) {
+	return findFirstBy[models.RefundPolicy](r.db, ctx, "id = ?", id, ErrRefundPolicyNotFound)
+}
+
+func (r *refundPolicyRepository) FindAll(ctx context.Context, activeOnly bool) ([]*models.RefundPolicy, error) {
+	var policies []*models.RefundPolicy
+	query := dbtx.From(ctx, r.db).Order("code ASC, version DESC")
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	if err := query.Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	return policies, nil
+}
+
+// FindApplicable возвращает наиболее специфичную действующую политику для рейса:
+// маршрут важнее перевозчика, перевозчик — политики по умолчанию. Если подходящей нет — nil.
+func (r *refundPolicyRepository) FindApplicable(ctx context.Context, routeID string, carrierID *string) (*models.RefundPolicy, error) {
+	var policy models.RefundPolicy
+	err := dbtx.From(ctx, r.db).
+		Where("is_active = ?", true).
+		Where("route_id IS NULL OR route_id = ?", routeID).
+		Where("carrier_id IS NULL OR carrier_id = ?", carrierID).
+		Order("(route_id IS NOT NULL) DESC, (carrier_id IS NOT NULL) DESC, created_at DESC").
+		First(&policy).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *refundPolicyRepository) Deactivate(ctx context.Context, id string) error {
+	result := dbtx.From(ctx, r.db).Model(&models.RefundPolicy{}).
+		Where("id = ?", id).
+		Update("is_active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRefundPolicyNotFound
+	}
+	return nil
+}
+
+func (r *fareComponentRepository) Create(ctx context.Context, rule *models.FareComponentRule) error {
+	return dbtx.From(ctx, r.db).Create(rule).Error
+}
+
+func (r *fareComponentRepository) FindByID(ctx context.Context, id string) (*models.FareComponentRule, error) {
+	return findFirstBy[models.FareComponentRule](r.db, ctx, "id = ?", id, ErrFareComponentRuleNotFound)
+}
+
+func (r *fareComponentRepository) FindAll(ctx context.Context, activeOnly bool) ([]*models.FareComponentRule, error) {
+	var rules []*models.FareComponentRule
+	query := dbtx.From(ctx, r.db).Order("kind ASC, created_at DESC")
+	if activeOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	if err := query.Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// FindForTrip возвращает по одному действующему правилу каждого вида для рейса — наиболее специфичное:
+// маршрут важнее станции отправления (первой остановки маршрута), станция — правила по умолчанию.
+func (r *fareComponentRepository) FindForTrip(ctx context.Context, tripID string) ([]*models.FareComponentRule, error) {
+	var rules []*models.FareComponentRule
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT DISTINCT ON (f.kind) f.*
+		FROM fare_component_rules f
+		JOIN trips t ON t.id = ?
+		JOIN schedules s ON s.id = t.schedule_id
+		JOIN routes rt ON rt.id = s.route_id
+		LEFT JOIN LATERAL (
+			SELECT st->>'station_id' AS station_id
+			FROM jsonb_array_elements(rt.stops) st
+			ORDER BY (st->>'order')::int
+			LIMIT 1
+		) origin ON true
+		WHERE f.is_active
+			AND (f.route_id IS NULL OR f.route_id = s.route_id)
+			AND (f.station_id IS NULL OR f.station_id::text = origin.station_id)
+		ORDER BY f.kind, (f.route_id IS NOT NULL) DESC, (f.station_id IS NOT NULL) DESC, f.created_at DESC
+	`, tripID).Scan(&rules).Error
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (r *fareComponentRepository) Deactivate(ctx context.Context, id string) error {
+	result := dbtx.From(ctx, r.db).Model(&models.FareComponentRule{}).
+		Where("id = ?", id).
+		Update("is_active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrFareComponentRuleNotFound
+	}
+	return nil
+}
+
+// FindActive возвращает действующий ключ подписи или nil, если ключей ещё нет.
+func (r *qrKeyRepository) FindActive(ctx context.Context) (*models.QRSigningKey, error) {
+	var key models.QRSigningKey
+	err := dbtx.From(ctx, r.db).Where("status = ?", "active").Order("created_at DESC").First(&key).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindPublished возвращает ключи для публикации: действующий и выведенные, ещё принимаемые для проверки.
+func (r *qrKeyRepository) FindPublished(ctx context.Context, now time.Time) ([]*models.QRSigningKey, error) {
+	var keys []*models.QRSigningKey
+	err := dbtx.From(ctx, r.db).
+		Where("status = ? OR (status = ? AND verify_until > ?)", "active", "retired", now).
+		Order("created_at DESC").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Rotate в одной транзакции выводит действующий ключ (проверка до verifyUntil) и сохраняет новый.
+func (r *qrKeyRepository) Rotate(ctx context.Context, key *models.QRSigningKey, verifyUntil time.Time) error {
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.QRSigningKey{}).
+			Where("status = ?", "active").
+			Updates(map[string]interface{}{"status": "retired", "retired_at": time.Now(), "verify_until": verifyUntil}).Error; err != nil {
+			return err
+		}
+		key.Status = "active"
+		return tx.Create(key).Error
+	})
+}
+
+// FindForReencryption возвращает ключи с seed открытым текстом или зашифрованным не активным ключом ПД.
+func (r *qrKeyRepository) FindForReencryption(ctx context.Context, activeKeyID string) ([]*models.QRSigningKey, error) {
+	var keys []*models.QRSigningKey
+	err := dbtx.From(ctx, r.db).
+		Where("private_key IS NOT NULL OR seed IS NULL OR seed NOT LIKE ?", "enc:v1:"+activeKeyID+":%").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SaveSeed перезаписывает seed ключа активным ключом ПД и удаляет seed открытым текстом.
+func (r *qrKeyRepository) SaveSeed(ctx context.Context, key *models.QRSigningKey) error {
+	key.LegacyPrivateKey = nil
+	return dbtx.From(ctx, r.db).Model(key).
+		Select("seed", "private_key").
+		UpdateColumns(key).Error
+}
+
 // CreateEvent создаёт событие начала посадки.
 func (r *boardingRepository) CreateEvent(ctx context.Context, event *models.BoardingEvent) error {
-	return r.db.WithContext(ctx).Create(event).Error
+	return dbtx.From(ctx, r.db).Create(event).Error
 }
 
 func (r *boardingRepository) FindEventByTripID(ctx context.Context, tripID string) (*models.BoardingEvent, error) {
 	var event models.BoardingEvent
-	if err := r.db.WithContext(ctx).First(&event, "trip_id = ?", tripID).Error; err != nil {
+	if err := dbtx.From(ctx, r.db).First(&event, "trip_id = ?", tripID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -174,15 +1041,52 @@ func (r *boardingRepository) FindEventByTripID(ctx context.Context, tripID strin
 	return &event, nil
 }
 
+// CloseEvent в одной транзакции завершает посадку и отмечает неявку по действующим билетам
+// рейса без отметки посадки. Возвращает число билетов с неявкой.
+func (r *boardingRepository) CloseEvent(ctx context.Context, event *models.BoardingEvent) (int64, error) {
+	var noShows int64
+	err := dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BoardingEvent{}).
+			Where("id = ? AND ended_at IS NULL", event.ID).
+			Updates(map[string]interface{}{"ended_at": event.EndedAt, "ended_by": event.EndedBy})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrBoardingClosed
+		}
+
+		res = tx.Model(&models.Ticket{}).
+			Where("trip_id = ? AND status = ? AND no_show_at IS NULL", event.TripID, "active").
+			Where("NOT EXISTS (SELECT 1 FROM boarding_marks m WHERE m.ticket_id = tickets.id AND m.cancelled_at IS NULL)").
+			Update("no_show_at", event.EndedAt)
+		if res.Error != nil {
+			return res.Error
+		}
+		noShows = res.RowsAffected
+		return nil
+	})
+	return noShows, err
+}
+
+// CreateMark создаёт отметку посадки; отметка снимает с билета неявку
+// (офлайн-отметка, выгруженная после завершения посадки).
 func (r *boardingRepository) CreateMark(ctx context.Context, mark *models.BoardingMark) error {
-	return r.db.WithContext(ctx).Create(mark).Error
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(mark).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Ticket{}).
+			Where("id = ? AND no_show_at IS NOT NULL", mark.TicketID).
+			Update("no_show_at", nil).Error
+	})
 }
 
 func (r *boardingRepository) FindMarksByTripID(ctx context.Context, tripID string) ([]*models.BoardingMark, error) {
 	var marks []*models.BoardingMark
-	err := r.db.WithContext(ctx).
+	err := dbtx.From(ctx, r.db).
 		Joins("JOIN tickets ON tickets.id = boarding_marks.ticket_id").
-		Where("tickets.trip_id = ?", tripID).
+		Where("tickets.trip_id = ? AND boarding_marks.cancelled_at IS NULL", tripID).
 		Find(&marks).Error
 	if err != nil {
 		return nil, err
@@ -192,11 +1096,1019 @@ func (r *boardingRepository) FindMarksByTripID(ctx context.Context, tripID strin
 
 func (r *boardingRepository) CheckIfMarked(ctx context.Context, ticketID string) (bool, error) {
 	var count int64
-	err := r.db.WithContext(ctx).Model(&models.BoardingMark{}).
-		Where("ticket_id = ?", ticketID).
+	err := dbtx.From(ctx, r.db).Model(&models.BoardingMark{}).
+		Where("ticket_id = ? AND cancelled_at IS NULL", ticketID).
 		Count(&count).Error
 	if err != nil {
 		return false, err
 	}
 	return count > 0, nil
 }
+
+func (r *boardingRepository) FindActiveMarkByTicketID(ctx context.Context, ticketID string) (*models.BoardingMark, error) {
+	return findFirstBy[models.BoardingMark](r.db, ctx, "ticket_id = ? AND cancelled_at IS NULL", ticketID, ErrBoardingMarkNotFound)
+}
+
+// CancelMark в одной транзакции снимает отметку посадки и записывает её в журнал исправлений.
+// Если посадка уже завершена (noShowAt != nil), билету возвращается неявка.
+func (r *boardingRepository) CancelMark(ctx context.Context, mark *models.BoardingMark, correction *models.BoardingCorrection, noShowAt *time.Time) error {
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.BoardingMark{}).
+			Where("id = ? AND cancelled_at IS NULL", mark.ID).
+			Updates(map[string]interface{}{
+				"cancelled_at":  mark.CancelledAt,
+				"cancelled_by":  mark.CancelledBy,
+				"cancel_reason": mark.CancelReason,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrBoardingMarkNotFound
+		}
+		if err := tx.Create(correction).Error; err != nil {
+			return err
+		}
+		if noShowAt == nil {
+			return nil
+		}
+		return tx.Model(&models.Ticket{}).Where("id = ?", mark.TicketID).Update("no_show_at", noShowAt).Error
+	})
+}
+
+// CorrectMark в одной транзакции сохраняет пересадку пассажира (место, рейс) и запись журнала.
+func (r *boardingRepository) CorrectMark(ctx context.Context, ticket *models.Ticket, correction *models.BoardingCorrection) error {
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(ticket).Error; err != nil {
+			return err
+		}
+		return tx.Create(correction).Error
+	})
+}
+
+func (r *boardingRepository) FindCorrectionsByTicketID(ctx context.Context, ticketID string) ([]*models.BoardingCorrection, error) {
+	var corrections []*models.BoardingCorrection
+	err := dbtx.From(ctx, r.db).
+		Where("ticket_id = ?", ticketID).
+		Order("created_at").
+		Find(&corrections).Error
+	if err != nil {
+		return nil, err
+	}
+	return corrections, nil
+}
+
+// NewRetentionRepository создаёт репозиторий обезличивания ПД.
+func NewRetentionRepository(db *gorm.DB) RetentionRepository {
+	return &retentionRepository{db: db}
+}
+
+// FindExpiredTickets возвращает необезличенные билеты на рейсы с датой раньше tripBefore.
+func (r *retentionRepository) FindExpiredTickets(ctx context.Context, tripBefore time.Time, limit int) ([]*models.Ticket, error) {
+	var tickets []*models.Ticket
+	err := dbtx.From(ctx, r.db).
+		Joins("JOIN trips ON trips.id = tickets.trip_id").
+		Where("trips.date < ? AND tickets.anonymized_at IS NULL", tripBefore.Format("2006-01-02")).
+		Limit(limit).
+		Find(&tickets).Error
+	if err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+// Anonymize удаляет ПД билетов (вместе со слепыми индексами) и пишет журнал в одной транзакции.
+// Стоимость, статус, возврат и остальные поля билета не меняются.
+func (r *retentionRepository) Anonymize(ctx context.Context, ticketIDs []string, logs []*models.AnonymizationLog) error {
+	if len(ticketIDs) == 0 {
+		return nil
+	}
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Ticket{}).
+			Where("id IN ?", ticketIDs).
+			UpdateColumns(map[string]interface{}{
+				"passenger_name":      nil,
+				"passenger_doc":       nil,
+				"phone":               nil,
+				"email":               nil,
+				"passenger_doc_index": nil,
+				"phone_index":         nil,
+				"email_index":         nil,
+				"anonymized_at":       time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err = tx.Where("ticket_id IN ?", ticketIDs).Delete(&models.TicketNameToken{}).Error; err != nil {
+			return err
+		}
+		// В пачке могут быть только билеты без ПД: журналировать нечего, но отметка ставится.
+		if len(logs) == 0 {
+			return nil
+		}
+		return tx.Create(&logs).Error
+	})
+}
+
+// FindExpiredWaitlistEntries возвращает необезличенные записи листа ожидания на рейсы с датой раньше tripBefore.
+func (r *retentionRepository) FindExpiredWaitlistEntries(ctx context.Context, tripBefore time.Time, limit int) ([]*models.WaitlistEntry, error) {
+	var entries []*models.WaitlistEntry
+	err := dbtx.From(ctx, r.db).
+		Joins("JOIN trips ON trips.id = waitlist_entries.trip_id").
+		Where("trips.date < ? AND waitlist_entries.anonymized_at IS NULL", tripBefore.Format("2006-01-02")).
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindWaitlistEntriesByContact возвращает все необезличенные записи листа ожидания (в том числе
+// закрытые) по слепому индексу телефона или email.
+func (r *retentionRepository) FindWaitlistEntriesByContact(ctx context.Context, field PIIField, index string) ([]*models.WaitlistEntry, error) {
+	if field != PIIFieldPhone && field != PIIFieldEmail {
+		return nil, fmt.Errorf("unsupported waitlist PII field %q", field)
+	}
+	var entries []*models.WaitlistEntry
+	err := dbtx.From(ctx, r.db).
+		Where(string(field)+" = ? AND anonymized_at IS NULL", index).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// AnonymizeWaitlistEntries удаляет ПД записей листа ожидания (вместе со слепыми индексами)
+// и пишет журнал в одной транзакции. Статус, рейс и цена записи не меняются.
+func (r *retentionRepository) AnonymizeWaitlistEntries(ctx context.Context, entryIDs []string, logs []*models.AnonymizationLog) error {
+	if len(entryIDs) == 0 {
+		return nil
+	}
+	return dbtx.From(ctx, r.db).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.WaitlistEntry{}).
+			Where("id IN ?", entryIDs).
+			UpdateColumns(map[string]interface{}{
+				"passenger_name": nil,
+				"phone":          nil,
+				"email":          nil,
+				"phone_index":    nil,
+				"email_index":    nil,
+				"pii_key_id":     nil,
+				"anonymized_at":  time.Now(),
+			}).Error
+		if err != nil || len(logs) == 0 {
+			return err
+		}
+		return tx.Create(&logs).Error
+	})
+}
+
+func (r *retentionRepository) CreateLogs(ctx context.Context, logs []*models.AnonymizationLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return dbtx.From(ctx, r.db).Create(&logs).Error
+}
+
+// FindLogs возвращает журнал обезличивания, новые записи первыми; пустой фильтр не применяется.
+func (r *retentionRepository) FindLogs(ctx context.Context, erasureRequestID, entityID string, limit int) ([]*models.AnonymizationLog, error) {
+	var logs []*models.AnonymizationLog
+	query := dbtx.From(ctx, r.db).Order("created_at DESC").Limit(limit)
+	if erasureRequestID != "" {
+		query = query.Where("erasure_request_id = ?", erasureRequestID)
+	}
+	if entityID != "" {
+		query = query.Where("entity_id = ?", entityID)
+	}
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (r *retentionRepository) CreateErasureRequest(ctx context.Context, req *models.ErasureRequest) error {
+	return dbtx.From(ctx, r.db).Create(req).Error
+}
+
+func (r *retentionRepository) UpdateErasureRequest(ctx context.Context, req *models.ErasureRequest) error {
+	return dbtx.From(ctx, r.db).Save(req).Error
+}
+
+// MarkErasureReported отмечает отчёт сервиса (notify, document) по запросу на удаление ПД
+// и закрывает запрос, когда отчитались оба. Повторный отчёт отметку не меняет.
+func (r *retentionRepository) MarkErasureReported(ctx context.Context, id, service string, at time.Time) error {
+	if service != "notify" && service != "document" {
+		return fmt.Errorf("unexpected erasure report service %q", service)
+	}
+	column := service + "_reported_at"
+	db := dbtx.From(ctx, r.db)
+	err := db.Model(&models.ErasureRequest{}).
+		Where("id = ? AND "+column+" IS NULL", id).
+		UpdateColumn(column, at).Error
+	if err != nil {
+		return err
+	}
+	return db.Model(&models.ErasureRequest{}).
+		Where("id = ? AND completed_at IS NULL AND notify_reported_at IS NOT NULL AND document_reported_at IS NOT NULL", id).
+		UpdateColumn("completed_at", at).Error
+}
+
+func (r *retentionRepository) FindErasureRequestByID(ctx context.Context, id string) (*models.ErasureRequest, error) {
+	return findFirstBy[models.ErasureRequest](r.db, ctx, "id = ?", id, ErrErasureRequestNotFound)
+}
+
+// NewShiftRepository создаёт репозиторий кассовых смен.
+func NewShiftRepository(db *gorm.DB) ShiftRepository {
+	return &shiftRepository{db: db}
+}
+
+// Create открывает смену. Вторая открытая смена кассира или рабочего места отсекается
+// частичными уникальными индексами (status = 'open').
+func (r *shiftRepository) Create(ctx context.Context, shift *models.CashierShift) error {
+	err := dbtx.From(ctx, r.db).Create(shift).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrShiftAlreadyOpen
+	}
+	return err
+}
+
+func (r *shiftRepository) FindByID(ctx context.Context, id string) (*models.CashierShift, error) {
+	return findFirstBy[models.CashierShift](r.db, ctx, "id = ?", id, ErrShiftNotFound)
+}
+
+// FindOpenByCashier возвращает открытую смену кассира или nil, если её нет.
+func (r *shiftRepository) FindOpenByCashier(ctx context.Context, cashierID string) (*models.CashierShift, error) {
+	return r.findOpen(ctx, "cashier_id = ?", cashierID)
+}
+
+// FindOpenByWorkstation возвращает открытую смену рабочего места или nil, если её нет.
+func (r *shiftRepository) FindOpenByWorkstation(ctx context.Context, workstationID string) (*models.CashierShift, error) {
+	return r.findOpen(ctx, "workstation_id = ?", workstationID)
+}
+
+func (r *shiftRepository) findOpen(ctx context.Context, query, arg string) (*models.CashierShift, error) {
+	shift, err := findFirstBy[models.CashierShift](r.db, ctx, "status = 'open' AND "+query, arg, ErrShiftNotFound)
+	if errors.Is(err, ErrShiftNotFound) {
+		return nil, nil
+	}
+	return shift, err
+}
+
+// FindAll возвращает смены, новые первыми. Пустые условия не применяются; date — дата открытия (YYYY-MM-DD).
+func (r *shiftRepository) FindAll(ctx context.Context, workstationID, cashierID, date string, limit int) ([]*models.CashierShift, error) {
+	var shifts []*models.CashierShift
+	query := dbtx.From(ctx, r.db).Order("opened_at DESC").Limit(limit)
+	if workstationID != "" {
+		query = query.Where("workstation_id = ?", workstationID)
+	}
+	if cashierID != "" {
+		query = query.Where("cashier_id = ?", cashierID)
+	}
+	if date != "" {
+		query = query.Where("DATE(opened_at) = ?", date)
+	}
+	if err := query.Find(&shifts).Error; err != nil {
+		return nil, err
+	}
+	return shifts, nil
+}
+
+// Close сохраняет итоги закрытия смены, если она ещё открыта (защита от двойного закрытия).
+func (r *shiftRepository) Close(ctx context.Context, shift *models.CashierShift) error {
+	res := dbtx.From(ctx, r.db).Model(&models.CashierShift{}).
+		Where("id = ? AND status = ?", shift.ID, "open").
+		Updates(map[string]interface{}{
+			"status":          shift.Status,
+			"closed_at":       shift.ClosedAt,
+			"closed_by":       shift.ClosedBy,
+			"expected_cash":   shift.ExpectedCash,
+			"counted_cash":    shift.CountedCash,
+			"cash_difference": shift.CashDifference,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrShiftClosed
+	}
+	return nil
+}
+
+func (r *shiftRepository) AddOperation(ctx context.Context, op *models.ShiftOperation) error {
+	return dbtx.From(ctx, r.db).Create(op).Error
+}
+
+// FindOperations возвращает операции смены в порядке проведения.
+func (r *shiftRepository) FindOperations(ctx context.Context, shiftID string) ([]*models.ShiftOperation, error) {
+	var ops []*models.ShiftOperation
+	if err := dbtx.From(ctx, r.db).Where("shift_id = ?", shiftID).Order("created_at").Find(&ops).Error; err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+// SummarizeOperations возвращает число и сумму операций смены по типу и способу оплаты.
+func (r *shiftRepository) SummarizeOperations(ctx context.Context, shiftID string) ([]ShiftOperationTotal, error) {
+	var totals []ShiftOperationTotal
+	err := dbtx.From(ctx, r.db).Model(&models.ShiftOperation{}).
+		Select("type, payment_method, COUNT(*) AS count, COALESCE(SUM(amount), 0) AS amount").
+		Where("shift_id = ?", shiftID).
+		Group("type, payment_method").
+		Order("type, payment_method").
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// LinkZReport связывает с Z-отчётом ККТ закрытые до него смены рабочего места с этой ККТ,
+// ещё не связанные с другим отчётом. Возвращает число связанных смен.
+func (r *shiftRepository) LinkZReport(ctx context.Context, kktSerial, zReportID string, kktShiftNumber int, reportedAt time.Time) (int64, error) {
+	res := dbtx.From(ctx, r.db).Model(&models.CashierShift{}).
+		Where("kkt_serial = ? AND status = ? AND z_report_id IS NULL AND closed_at <= ?", kktSerial, "closed", reportedAt).
+		Updates(map[string]interface{}{"z_report_id": zReportID, "kkt_shift_number": kktShiftNumber})
+	return res.RowsAffected, res.Error
+}
+
+// NewReportRepository создаёт репозиторий отчётов.
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+// salesEventsSQL — продажи и возвраты билетов и багажа как отдельные события с датой операции.
+// Обмен — продажа нового билета со сбором и зачётом стоимости исходного. Суммы билетов подставляются
+// salesEventsFull или salesEventsFare.
+const salesEventsSQL = `
+	SELECT DATE(t.created_at) AS day, t.trip_id, t.sold_by AS cashier_id, t.payment_method, t.passenger_category,
+		1 AS tickets_sold, {fee_tickets} AS fee_tickets, {ticket_amount} AS tickets_amount,
+		0 AS baggage_sold, 0 AS baggage_amount,
+		COALESCE(t.exchange_fee, 0) AS exchange_fees, COALESCE({exchanged_amount}, 0) AS exchanged_amount,
+		0 AS refunds, 0 AS refunds_amount, 0 AS penalties
+	FROM tickets t
+	LEFT JOIN tickets o ON o.id = t.exchanged_from_id
+	WHERE t.created_at >= @from AND t.created_at < @to
+	UNION ALL
+	SELECT DATE(t.refunded_at), t.trip_id, t.sold_by, t.payment_method, t.passenger_category,
+		0, 0, 0, 0, 0, 0, 0,
+		1, COALESCE({refund_amount}, 0), {penalties}
+	FROM tickets t
+	WHERE t.refunded_at >= @from AND t.refunded_at < @to
+	UNION ALL
+	SELECT DATE(b.created_at), b.trip_id, cs.cashier_id, b.payment_method, t.passenger_category,
+		0, 0, 0, 1, b.price, 0, 0, 0, 0, 0
+	FROM baggage_tickets b
+	JOIN tickets t ON t.id = b.ticket_id
+	LEFT JOIN cashier_shifts cs ON cs.id = b.shift_id
+	WHERE b.created_at >= @from AND b.created_at < @to
+	UNION ALL
+	SELECT DATE(b.refunded_at), b.trip_id, cs.cashier_id, b.payment_method, t.passenger_category,
+		0, 0, 0, 0, 0, 0, 0,
+		1, COALESCE(b.refund_amount, 0), COALESCE(b.refund_penalty, 0)
+	FROM baggage_tickets b
+	JOIN tickets t ON t.id = b.ticket_id
+	LEFT JOIN cashier_shifts cs ON cs.id = b.shift_id
+	WHERE b.refunded_at >= @from AND b.refunded_at < @to`
+
+// fareComponentSQL — поле составляющей "fare" билета alias (NULL у билетов без составляющих).
+func fareComponentSQL(alias, field string) string {
+	return fmt.Sprintf(`(SELECT SUM((c->>'%s')::numeric) FROM jsonb_array_elements(
+		CASE WHEN jsonb_typeof(%s.components) = 'array' THEN %s.components ELSE '[]'::jsonb END) c
+		WHERE c->>'kind' = 'fare')`, field, alias, alias)
+}
+
+var (
+	// salesEventsFull — суммы билетов полностью: цена со всеми сборами, возврат и удержания.
+	salesEventsFull = strings.NewReplacer(
+		"{ticket_amount}", "t.price",
+		"{exchanged_amount}", "o.price",
+		"{refund_amount}", "t.refund_amount",
+		"{penalties}", "COALESCE(t.refund_penalty, 0) + COALESCE(t.refund_service_fee, 0)",
+		"{fee_tickets}", "0",
+	).Replace(salesEventsSQL)
+	// salesEventsFare — суммы по тарифу перевозчика: у билетов с составляющими цены — составляющая
+	// "fare" (удержание — невозвращённая её часть), у прежних билетов — как в salesEventsFull.
+	salesEventsFare = strings.NewReplacer(
+		"{ticket_amount}", "COALESCE("+fareComponentSQL("t", "amount")+", t.price)",
+		"{exchanged_amount}", "COALESCE("+fareComponentSQL("o", "amount")+", o.price)",
+		"{refund_amount}", "COALESCE("+fareComponentSQL("t", "refund_amount")+", t.refund_amount)",
+		"{penalties}", "COALESCE("+fareComponentSQL("t", "amount")+" - "+fareComponentSQL("t", "refund_amount")+
+			", COALESCE(t.refund_penalty, 0) + COALESCE(t.refund_service_fee, 0))",
+		"{fee_tickets}", `CASE WHEN jsonb_typeof(t.components) = 'array' AND t.components @> '[{"kind": "station_fee"}]'
+			THEN 0 ELSE 1 END`,
+	).Replace(salesEventsSQL)
+)
+
+// SalesReport агрегирует продажи, возвраты и выручку за период по выбранным разрезам.
+// Маршрут, перевозчик и станция отправления берутся из рейса (trips → schedules → routes).
+func (r *reportRepository) SalesReport(ctx context.Context, query *SalesReportQuery) ([]*SalesReportRow, error) {
+	args := map[string]interface{}{"from": query.From, "to": query.To}
+
+	grouped := map[string]bool{}
+	for _, d := range query.GroupBy {
+		grouped[d] = true
+	}
+	var columns, groupBy []string
+	for _, d := range SalesDimensions {
+		if !grouped[d] {
+			continue
+		}
+		for _, col := range salesDimensionColumns[d].columns {
+			columns = append(columns, col)
+			if i := strings.Index(col, " AS "); i >= 0 {
+				col = col[:i]
+			}
+			groupBy = append(groupBy, col)
+		}
+	}
+
+	var conditions []string
+	for _, d := range SalesDimensions {
+		value, ok := query.Filters[d]
+		if !ok || salesDimensionColumns[d].filter == "" {
+			continue
+		}
+		conditions = append(conditions, fmt.Sprintf("%s = @%s", salesDimensionColumns[d].filter, d))
+		args[d] = value
+	}
+
+	events := salesEventsFull
+	if query.FareOnly {
+		events = salesEventsFare
+	}
+	stmt := "WITH e AS (" + events + `)
+		SELECT ` + strings.Join(append(columns,
+		"SUM(e.tickets_sold) AS tickets_sold",
+		"SUM(e.fee_tickets) AS fee_tickets",
+		"SUM(e.tickets_amount) AS tickets_amount",
+		"SUM(e.baggage_sold) AS baggage_sold",
+		"SUM(e.baggage_amount) AS baggage_amount",
+		"SUM(e.exchange_fees) AS exchange_fees",
+		"SUM(e.exchanged_amount) AS exchanged_amount",
+		"SUM(e.refunds) AS refunds",
+		"SUM(e.refunds_amount) AS refunds_amount",
+		"SUM(e.penalties) AS penalties",
+	), ", ") + `
+		FROM e
+		LEFT JOIN trips tr ON tr.id = e.trip_id
+		LEFT JOIN schedules s ON s.id = tr.schedule_id
+		LEFT JOIN routes rt ON rt.id = s.route_id
+		LEFT JOIN LATERAL (
+			SELECT st->>'station_id' AS station_id
+			FROM jsonb_array_elements(rt.stops) st
+			ORDER BY (st->>'order')::int
+			LIMIT 1
+		) origin ON true
+		LEFT JOIN stations stn ON stn.id::text = origin.station_id`
+	if len(conditions) > 0 {
+		stmt += "\n\t\tWHERE " + strings.Join(conditions, " AND ")
+	}
+	if len(groupBy) > 0 {
+		stmt += "\n\t\tGROUP BY " + strings.Join(groupBy, ", ") + "\n\t\tORDER BY " + strings.Join(groupBy, ", ")
+	}
+
+	var rows []*SalesReportRow
+	if err := dbtx.From(ctx, r.db).Raw(stmt, args).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row.GrossRevenue = row.TicketsAmount + row.BaggageAmount + row.ExchangeFees - row.ExchangedAmount
+		row.NetRevenue = row.GrossRevenue - row.RefundsAmount
+	}
+	return rows, nil
+}
+
+func (r *reportRepository) CreateJob(ctx context.Context, job *models.ReportJob) error {
+	return dbtx.From(ctx, r.db).Create(job).Error
+}
+
+// FindJobByID возвращает задание вместе с файлом результата.
+func (r *reportRepository) FindJobByID(ctx context.Context, id string) (*models.ReportJob, error) {
+	return findFirstBy[models.ReportJob](r.db, ctx, "id = ?", id, ErrReportJobNotFound)
+}
+
+// FindJobsByUser возвращает задания пользователя, новые первыми, без файлов результата.
+func (r *reportRepository) FindJobsByUser(ctx context.Context, userID string, limit int) ([]*models.ReportJob, error) {
+	var jobs []*models.ReportJob
+	err := dbtx.From(ctx, r.db).Omit("result").
+		Where("requested_by = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// ClaimJob забирает в работу старейшее ожидающее задание или задание, зависшее в работе
+// с момента раньше staleBefore (обработчик упал). Несколько экземпляров сервиса не получат
+// одно задание (FOR UPDATE SKIP LOCKED). Возвращает nil, nil, если заданий нет.
+func (r *reportRepository) ClaimJob(ctx context.Context, staleBefore time.Time) (*models.ReportJob, error) {
+	var jobs []*models.ReportJob
+	err := dbtx.From(ctx, r.db).Raw(`
+		UPDATE report_jobs SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM report_jobs
+			WHERE status = 'pending' OR (status = 'running' AND started_at < ?)
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *
+	`, staleBefore).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+func (r *reportRepository) SaveJob(ctx context.Context, job *models.ReportJob) error {
+	return dbtx.From(ctx, r.db).Save(job).Error
+}
+
+// DeleteExpiredJobs удаляет задания, срок хранения результата которых истёк.
+func (r *reportRepository) DeleteExpiredJobs(ctx context.Context, now time.Time) (int64, error) {
+	res := dbtx.From(ctx, r.db).Where("expires_at < ?", now).Delete(&models.ReportJob{})
+	return res.RowsAffected, res.Error
+}
+
+// NewWaitlistRepository создаёт репозиторий листа ожидания.
+func NewWaitlistRepository(db *gorm.DB) WaitlistRepository {
+	return &waitlistRepository{db: db}
+}
+
+func (r *waitlistRepository) Create(ctx context.Context, entry *models.WaitlistEntry) error {
+	return dbtx.From(ctx, r.db).Create(entry).Error
+}
+
+func (r *waitlistRepository) FindByID(ctx context.Context, id string) (*models.WaitlistEntry, error) {
+	return findFirstBy[models.WaitlistEntry](r.db, ctx, "id = ?", id, ErrWaitlistEntryNotFound)
+}
+
+func (r *waitlistRepository) FindByOfferToken(ctx context.Context, token string) (*models.WaitlistEntry, error) {
+	return findFirstBy[models.WaitlistEntry](r.db, ctx, "offer_token = ?", token, ErrWaitlistEntryNotFound)
+}
+
+// FindByTrip возвращает очередь рейса в порядке постановки; пустой status — записи во всех статусах.
+func (r *waitlistRepository) FindByTrip(ctx context.Context, tripID, status string) ([]*models.WaitlistEntry, error) {
+	query := dbtx.From(ctx, r.db).Where("trip_id = ?", tripID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var entries []*models.WaitlistEntry
+	if err := query.Order("created_at").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindOpenByTrip возвращает ожидающие записи рейса и записи с действующим предложением.
+func (r *waitlistRepository) FindOpenByTrip(ctx context.Context, tripID string) ([]*models.WaitlistEntry, error) {
+	var entries []*models.WaitlistEntry
+	err := dbtx.From(ctx, r.db).
+		Where("trip_id = ? AND status IN ?", tripID, []string{"waiting", "offered"}).
+		Order("created_at").
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindOpenByContact возвращает незакрытые записи по слепому индексу телефона или email.
+func (r *waitlistRepository) FindOpenByContact(ctx context.Context, field PIIField, index string) ([]*models.WaitlistEntry, error) {
+	if field != PIIFieldPhone && field != PIIFieldEmail {
+		return nil, fmt.Errorf("unsupported waitlist PII field %q", field)
+	}
+	var entries []*models.WaitlistEntry
+	err := dbtx.From(ctx, r.db).
+		Where(string(field)+" = ? AND status IN ?", index, []string{"waiting", "offered"}).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// FindExpiredOffers возвращает предложения, срок которых истёк к now, старейшие первыми.
+func (r *waitlistRepository) FindExpiredOffers(ctx context.Context, now time.Time, limit int) ([]*models.WaitlistEntry, error) {
+	var entries []*models.WaitlistEntry
+	err := dbtx.From(ctx, r.db).
+		Where("status = ? AND offer_expires_at <= ?", "offered", now).
+		Order("offer_expires_at").
+		Limit(limit).
+		Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// LockTrip блокирует лист ожидания рейса до конца транзакции (advisory lock): подсчёт свободных мест
+// и выдача предложений по одному рейсу не идут параллельно.
+func (r *waitlistRepository) LockTrip(ctx context.Context, tripID string) error {
+	return dbtx.From(ctx, r.db).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "waitlist:"+tripID).Error
+}
+
+// NextWaiting блокирует до конца транзакции первую ожидающую запись очереди рейса, участок которой
+// целиком лежит в одном из segments (пустой список — участок не проверяется); записи, заблокированные
+// другим экземпляром сервиса, пропускаются. Возвращает nil, nil, если подходящих записей нет.
+func (r *waitlistRepository) NextWaiting(ctx context.Context, tripID string, segments []WaitlistSegment) (*models.WaitlistEntry, error) {
+	query := dbtx.From(ctx, r.db).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("trip_id = ? AND status = ?", tripID, "waiting")
+	if len(segments) > 0 {
+		conds := make([]string, 0, len(segments))
+		args := make([]interface{}, 0, 2*len(segments))
+		for _, seg := range segments {
+			from, to := "from_station_id IN ?", "to_station_id IN ?"
+			if seg.FromStart {
+				from = "(from_station_id IS NULL OR " + from + ")"
+			}
+			if seg.ToEnd {
+				to = "(to_station_id IS NULL OR " + to + ")"
+			}
+			conds = append(conds, "("+from+" AND "+to+")")
+			args = append(args, seg.FromStationIDs, seg.ToStationIDs)
+		}
+		query = query.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	var entries []*models.WaitlistEntry
+	err := query.Order("created_at").Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// UpdateIfStatus сохраняет запись, только если в БД она всё ещё в статусе status. Возвращает false,
+// если запись уже перевёл другой запрос (выкуп, отказ или истечение предложения).
+func (r *waitlistRepository) UpdateIfStatus(ctx context.Context, entry *models.WaitlistEntry, status string) (bool, error) {
+	res := dbtx.From(ctx, r.db).Model(entry).Where("status = ?", status).Select("*").Updates(entry)
+	return res.RowsAffected > 0, res.Error
+}
+
+// IsSeatHeld проверяет, удерживается ли место рейса действующим предложением листа ожидания,
+// кроме предложения записи exceptEntryID.
+func (r *waitlistRepository) IsSeatHeld(ctx context.Context, tripID, seatID, exceptEntryID string) (bool, error) {
+	query := dbtx.From(ctx, r.db).Model(&models.WaitlistEntry{}).
+		Where("trip_id = ? AND seat_id = ? AND status = ?", tripID, seatID, "offered")
+	if exceptEntryID != "" {
+		query = query.Where("id <> ?", exceptEntryID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetTripSeats возвращает статус рейса, вместимость (основной автобус из trips+buses вместе
+// с добавленными из trip_vehicles) и занятые места.
+func (r *waitlistRepository) GetTripSeats(ctx context.Context, tripID string) (*TripSeats, error) {
+	var seats TripSeats
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT t.id AS trip_id, t.status,
+			b.capacity + COALESCE((SELECT SUM(vb.capacity) FROM trip_vehicles tv
+				JOIN buses vb ON vb.id = tv.bus_id WHERE tv.trip_id = t.id), 0) AS capacity,
+			(SELECT COUNT(*) FROM tickets WHERE trip_id = t.id AND status = 'active') AS sold,
+			(SELECT COUNT(*) FROM waitlist_entries WHERE trip_id = t.id AND status = 'offered') AS held
+		FROM trips t
+		LEFT JOIN buses b ON b.id = t.bus_id
+		WHERE t.id = ?
+	`, tripID).Scan(&seats).Error
+	if err != nil {
+		return nil, err
+	}
+	if seats.TripID == "" {
+		return nil, ErrTripNotFound
+	}
+	return &seats, nil
+}
+
+// Stats считает записи листа ожидания по статусам.
+func (r *waitlistRepository) Stats(ctx context.Context, filter *WaitlistStatsFilter) ([]*WaitlistStatusCount, error) {
+	query := dbtx.From(ctx, r.db).Model(&models.WaitlistEntry{}).
+		Select("status, COUNT(*) AS count, COUNT(offered_at) AS offered")
+	if filter.TripID != "" {
+		query = query.Where("trip_id = ?", filter.TripID)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+	var rows []*WaitlistStatusCount
+	if err := query.Group("status").Order("status").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// NewVoucherRepository создаёт репозиторий ваучеров.
+func NewVoucherRepository(db *gorm.DB) VoucherRepository {
+	return &voucherRepository{db: db}
+}
+
+func (r *voucherRepository) Create(ctx context.Context, voucher *models.Voucher) error {
+	err := dbtx.From(ctx, r.db).Create(voucher).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrVoucherCodeTaken
+	}
+	return err
+}
+
+// CreateForTicket выпускает ваучер на билет SourceTicketID. Возвращает false, если ваучер
+// на этот билет уже выпущен (повторное событие об отмене рейса).
+func (r *voucherRepository) CreateForTicket(ctx context.Context, voucher *models.Voucher) (bool, error) {
+	res := dbtx.From(ctx, r.db).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "source_ticket_id"}}, DoNothing: true}).
+		Create(voucher)
+	if errors.Is(res.Error, gorm.ErrDuplicatedKey) {
+		return false, ErrVoucherCodeTaken
+	}
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *voucherRepository) FindByID(ctx context.Context, id string) (*models.Voucher, error) {
+	return findFirstBy[models.Voucher](r.db, ctx, "id = ?", id, ErrVoucherNotFound)
+}
+
+func (r *voucherRepository) FindByCode(ctx context.Context, code string) (*models.Voucher, error) {
+	return findFirstBy[models.Voucher](r.db, ctx, "code = ?", code, ErrVoucherNotFound)
+}
+
+func (r *voucherRepository) FindAll(ctx context.Context, filter *VoucherFilter) ([]*models.Voucher, error) {
+	query := dbtx.From(ctx, r.db).Order("created_at DESC")
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	if filter.SourceTicketID != "" {
+		query = query.Where("source_ticket_id = ?", filter.SourceTicketID)
+	}
+	if filter.ActiveOnly {
+		query = query.Where("is_active = ?", true)
+	}
+	var vouchers []*models.Voucher
+	if err := query.Find(&vouchers).Error; err != nil {
+		return nil, err
+	}
+	return vouchers, nil
+}
+
+// Redeem засчитывает применение ваучера. Возвращает false, если лимит применений уже исчерпан:
+// проверка и увеличение счётчика — одно условное обновление, параллельные продажи не превысят лимит.
+func (r *voucherRepository) Redeem(ctx context.Context, id string) (bool, error) {
+	res := dbtx.From(ctx, r.db).Model(&models.Voucher{}).
+		Where("id = ? AND (usage_limit IS NULL OR used_count < usage_limit)", id).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *voucherRepository) Deactivate(ctx context.Context, id string) error {
+	result := dbtx.From(ctx, r.db).Model(&models.Voucher{}).
+		Where("id = ?", id).
+		Update("is_active", false)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrVoucherNotFound
+	}
+	return nil
+}
+
+// Usage возвращает число билетов, проданных по ваучеру (включая возвращённые), и сумму скидок.
+func (r *voucherRepository) Usage(ctx context.Context, id string) (*VoucherUsage, error) {
+	var usage VoucherUsage
+	err := dbtx.From(ctx, r.db).Model(&models.Ticket{}).
+		Select("COUNT(*) AS tickets, COALESCE(SUM(discount_amount), 0) AS discount").
+		Where("voucher_id = ?", id).
+		Scan(&usage).Error
+	if err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// NewSettlementRepository создаёт репозиторий расчётов с перевозчиками.
+func NewSettlementRepository(db *gorm.DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+// FindCarrier возвращает перевозчика из справочника schedule-service.
+func (r *settlementRepository) FindCarrier(ctx context.Context, id string) (*CarrierInfo, error) {
+	var carriers []*CarrierInfo
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT id, name, legal_name, inn, contract_number, commission_rate, service_fee, is_active
+		FROM carriers WHERE id = ?`, id).Scan(&carriers).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(carriers) == 0 {
+		return nil, ErrCarrierNotFound
+	}
+	return carriers[0], nil
+}
+
+func (r *settlementRepository) FindActiveCarriers(ctx context.Context) ([]*CarrierInfo, error) {
+	var carriers []*CarrierInfo
+	err := dbtx.From(ctx, r.db).Raw(`
+		SELECT id, name, legal_name, inn, contract_number, commission_rate, service_fee, is_active
+		FROM carriers WHERE is_active ORDER BY name`).Scan(&carriers).Error
+	if err != nil {
+		return nil, err
+	}
+	return carriers, nil
+}
+
+// LockCarrier блокирует расчёты перевозчика до конца транзакции, чтобы два расчёта за пересекающиеся
+// периоды не были созданы одновременно.
+func (r *settlementRepository) LockCarrier(ctx context.Context, carrierID string) error {
+	return dbtx.From(ctx, r.db).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "settlement:"+carrierID).Error
+}
+
+// HasOverlap проверяет, есть ли у перевозчика другой расчёт (кроме exceptID), период которого
+// пересекается с [periodFrom, periodTo]. Даты в формате YYYY-MM-DD сравниваются как строки.
+func (r *settlementRepository) HasOverlap(ctx context.Context, carrierID, periodFrom, periodTo, exceptID string) (bool, error) {
+	query := dbtx.From(ctx, r.db).Model(&models.CarrierSettlement{}).
+		Where("carrier_id = ? AND period_from <= ? AND period_to >= ?", carrierID, periodTo, periodFrom)
+	if exceptID != "" {
+		query = query.Where("id <> ?", exceptID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *settlementRepository) Create(ctx context.Context, settlement *models.CarrierSettlement) error {
+	return dbtx.From(ctx, r.db).Create(settlement).Error
+}
+
+func (r *settlementRepository) FindByID(ctx context.Context, id string) (*models.CarrierSettlement, error) {
+	return findFirstBy[models.CarrierSettlement](r.db, ctx, "id = ?", id, ErrSettlementNotFound)
+}
+
+// FindAll возвращает расчёты, последние периоды первыми.
+func (r *settlementRepository) FindAll(ctx context.Context, filter *SettlementFilter) ([]*models.CarrierSettlement, error) {
+	query := dbtx.From(ctx, r.db).Omit("lines").Order("period_from DESC, carrier_name")
+	if filter.CarrierID != "" {
+		query = query.Where("carrier_id = ?", filter.CarrierID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	var settlements []*models.CarrierSettlement
+	if err := query.Find(&settlements).Error; err != nil {
+		return nil, err
+	}
+	return settlements, nil
+}
+
+// UpdateDraft сохраняет расчёт, пока он в статусе draft (в том числе закрывает его). Возвращает false,
+// если расчёт уже закрыт: закрытый расчёт не меняется.
+func (r *settlementRepository) UpdateDraft(ctx context.Context, settlement *models.CarrierSettlement) (bool, error) {
+	res := dbtx.From(ctx, r.db).Model(settlement).
+		Where("status = ?", "draft").
+		Select("*").Omit("id", "created_at", "created_by").
+		Updates(settlement)
+	return res.RowsAffected > 0, res.Error
+}
+
+// DeleteDraft удаляет расчёт в статусе draft. Возвращает false, если расчёт закрыт или не найден.
+func (r *settlementRepository) DeleteDraft(ctx context.Context, id string) (bool, error) {
+	res := dbtx.From(ctx, r.db).Where("id = ? AND status = ?", id, "draft").Delete(&models.CarrierSettlement{})
+	return res.RowsAffected > 0, res.Error
+}
+
+// NewSalesRepository создаёт репозиторий настроек продажи рейсов.
+func NewSalesRepository(db *gorm.DB) SalesRepository {
+	return &salesRepository{db: db}
+}
+
+// GetTripSalesInfo возвращает плановое отправление (date + schedule.departure_time), задержку, статус рейса
+// и остановки маршрута (routes.stops по порядку).
+func (r *salesRepository) GetTripSalesInfo(ctx context.Context, tripID string) (*TripSalesInfo, error) {
+	db := dbtx.From(ctx, r.db)
+	var info TripSalesInfo
+	err := db.Raw(`
+		SELECT (t.date + s.departure_time) AS departure_time, t.status, t.delay_minutes
+		FROM trips t
+		JOIN schedules s ON s.id = t.schedule_id
+		WHERE t.id = ?
+	`, tripID).Scan(&info).Error
+	if err != nil {
+		return nil, err
+	}
+	if info.Status == "" {
+		return nil, ErrTripNotFound
+	}
+	if info.DepartureTime != nil && info.DepartureTime.IsZero() {
+		info.DepartureTime = nil
+	}
+
+	err = db.Raw(`
+		SELECT st->>'station_id' AS station_id, COALESCE((st->>'arrival_offset_min')::int, 0) AS offset_min
+		FROM trips t
+		JOIN schedules s ON s.id = t.schedule_id
+		JOIN routes rt ON rt.id = s.route_id
+		CROSS JOIN LATERAL jsonb_array_elements(rt.stops) st
+		WHERE t.id = ?
+		ORDER BY (st->>'order')::int
+	`, tripID).Scan(&info.Stops).Error
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// FindSettings возвращает настройки продажи рейса или nil, если у рейса их нет.
+func (r *salesRepository) FindSettings(ctx context.Context, tripID string) (*models.TripSalesSettings, error) {
+	settings, err := findFirstBy[models.TripSalesSettings](r.db, ctx, "trip_id = ?", tripID, ErrSalesSettingsNotFound)
+	if errors.Is(err, ErrSalesSettingsNotFound) {
+		return nil, nil
+	}
+	return settings, err
+}
+
+// SaveSettings создаёт или заменяет настройки продажи рейса.
+func (r *salesRepository) SaveSettings(ctx context.Context, settings *models.TripSalesSettings) error {
+	return dbtx.From(ctx, r.db).Save(settings).Error
+}
+
+func (r *salesRepository) DeleteSettings(ctx context.Context, tripID string) error {
+	res := dbtx.From(ctx, r.db).Where("trip_id = ?", tripID).Delete(&models.TripSalesSettings{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSalesSettingsNotFound
+	}
+	return nil
+}
+
+// LockTrip блокирует продажи рейса до конца транзакции, чтобы квоту остановки не превысили
+// одновременные продажи.
+func (r *salesRepository) LockTrip(ctx context.Context, tripID string) error {
+	return dbtx.From(ctx, r.db).Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "sales:"+tripID).Error
+}
+
+// CountSoldByStop возвращает число действующих билетов рейса по остановкам посадки.
+func (r *salesRepository) CountSoldByStop(ctx context.Context, tripID string) (map[string]int, error) {
+	var rows []struct {
+		StationID string `gorm:"column:from_station_id"`
+		Count     int    `gorm:"column:count"`
+	}
+	err := dbtx.From(ctx, r.db).Model(&models.Ticket{}).
+		Select("from_station_id, COUNT(*) AS count").
+		Where("trip_id = ? AND status = ? AND from_station_id IS NOT NULL", tripID, "active").
+		Group("from_station_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	sold := make(map[string]int, len(rows))
+	for _, row := range rows {
+		sold[row.StationID] = row.Count
+	}
+	return sold, nil
+}
+
+// NewDriverSaleRepository создаёт репозиторий продаж водителей в пути.
+func NewDriverSaleRepository(db *gorm.DB) DriverSaleRepository {
+	return &driverSaleRepository{db: db}
+}
+
+func (r *driverSaleRepository) Create(ctx context.Context, sale *models.DriverSale) error {
+	return dbtx.From(ctx, r.db).Create(sale).Error
+}
+
+// FindByClientSaleID находит продажу по номеру на устройстве — для повторной выгрузки.
+func (r *driverSaleRepository) FindByClientSaleID(ctx context.Context, deviceID, clientSaleID string) (*models.DriverSale, error) {
+	var sale models.DriverSale
+	err := dbtx.From(ctx, r.db).
+		Where("device_id = ? AND client_sale_id = ?", deviceID, clientSaleID).
+		First(&sale).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDriverSaleNotFound
+		}
+		return nil, err
+	}
+	return &sale, nil
+}
+
+// FindByTripID возвращает продажи водителей на рейсе в порядке продажи.
+func (r *driverSaleRepository) FindByTripID(ctx context.Context, tripID string) ([]*models.DriverSale, error) {
+	var sales []*models.DriverSale
+	if err := dbtx.From(ctx, r.db).Where("trip_id = ?", tripID).Order("sold_at").Find(&sales).Error; err != nil {
+		return nil, err
+	}
+	return sales, nil
+}
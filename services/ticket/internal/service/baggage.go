@@ -0,0 +1,257 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+var (
+	// ErrUnknownWeightClass возвращается, когда для весовой категории багажа нет тарифа.
+	ErrUnknownWeightClass = errors.New("unknown baggage weight class")
+	// ErrTooManyPieces возвращается, когда количество мест багажа превышает лимит.
+	ErrTooManyPieces = errors.New("too many baggage pieces")
+)
+
+// SellBaggageRequest — запрос на оформление багажной квитанции.
+type SellBaggageRequest struct {
+	TicketID      string `json:"ticket_id" binding:"required"`
+	WeightClass   string `json:"weight_class" binding:"required"`
+	PaymentMethod string `json:"payment_method" binding:"required"`
+	UserID        string `json:"-"`
+	Role          string `json:"-"`
+	Pieces        int    `json:"pieces" binding:"required,gt=0"`
+}
+
+// SellBaggage оформляет провоз багажа по билету пассажира.
+func (s *ticketService) SellBaggage(ctx context.Context, req *SellBaggageRequest) (*models.BaggageTicket, error) {
+	tariff, ok := s.cfg.Business.Baggage.Tariffs[req.WeightClass]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownWeightClass, req.WeightClass)
+	}
+	if maxPieces := s.cfg.Business.Baggage.MaxPieces; maxPieces > 0 && req.Pieces > maxPieces {
+		return nil, fmt.Errorf("%w: %d > %d", ErrTooManyPieces, req.Pieces, maxPieces)
+	}
+
+	// Багаж оформляется только к действующему билету
+	ticket, err := s.ticketRepo.FindByID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status != "active" {
+		return nil, fmt.Errorf("ticket is not active, current status: %s", ticket.Status)
+	}
+
+	shift, err := s.cashierShift(ctx, req.UserID, req.Role)
+	if err != nil {
+		return nil, err
+	}
+	// Багаж продаётся на тех же условиях, что и билет: рейс в продаже, окно продаж канала открыто
+	// для остановки посадки пассажира; после завершения посадки багаж уже не погрузить
+	if _, err = s.checkSale(ctx, ticket.TripID, ticket.FromStationID, channelOf(shift), time.Now()); err != nil {
+		return nil, err
+	}
+	boardingEvent, err := s.boardingRepo.FindEventByTripID(ctx, ticket.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding status: %w", err)
+	}
+	if boardingEvent != nil && boardingEvent.EndedAt != nil {
+		return nil, repository.ErrBoardingClosed
+	}
+
+	baggage := &models.BaggageTicket{
+		TicketID:      ticket.ID,
+		TripID:        ticket.TripID,
+		WeightClass:   req.WeightClass,
+		Pieces:        req.Pieces,
+		Tariff:        tariff,
+		Price:         tariff * float64(req.Pieces),
+		Status:        "active",
+		PaymentMethod: req.PaymentMethod,
+		ShiftID:       shiftIDOf(shift),
+	}
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.baggageRepo.Create(ctx, baggage); dbErr != nil {
+			return fmt.Errorf("failed to create baggage ticket: %w", dbErr)
+		}
+		if dbErr := s.addCashEntry(ctx, cashEntry(shift, ShiftOpSale, req.PaymentMethod, req.UserID, baggage.Price), "baggage", baggage.ID); dbErr != nil {
+			return dbErr
+		}
+		// Отдельный чек на провоз багажа
+		return s.publishEvent(ctx, "baggage", baggage.ID, events.BaggageSold{Baggage: baggageEvent(baggage)})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Baggage sold",
+		zap.String("baggage_id", baggage.ID),
+		zap.String("ticket_id", baggage.TicketID),
+		zap.Int("pieces", baggage.Pieces),
+		zap.Float64("price", baggage.Price))
+
+	return baggage, nil
+}
+
+func (s *ticketService) GetBaggage(ctx context.Context, id string) (*models.BaggageTicket, error) {
+	return s.baggageRepo.FindByID(ctx, id)
+}
+
+func (s *ticketService) ListBaggageByTicket(ctx context.Context, ticketID string) ([]*models.BaggageTicket, error) {
+	return s.baggageRepo.FindByTicketID(ctx, ticketID)
+}
+
+// RefundBaggage возвращает багажную квитанцию (штраф — как для билета).
+func (s *ticketService) RefundBaggage(ctx context.Context, baggageID, userID, role string) (*RefundResult, error) {
+	baggage, err := s.baggageRepo.FindByID(ctx, baggageID)
+	if err != nil {
+		return nil, err
+	}
+	if baggage.Status != "active" {
+		return nil, fmt.Errorf("baggage ticket is not active, current status: %s", baggage.Status)
+	}
+
+	ticket, err := s.ticketRepo.FindByID(ctx, baggage.TicketID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find baggage ticket holder: %w", err)
+	}
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, baggage.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trip info for refund calculation: %w", err)
+	}
+
+	now := time.Now()
+	reason, err := refundReason(trip, &RefundTicketRequest{}, now)
+	if err != nil {
+		return nil, err
+	}
+	// Багаж возвращается на тех же условиях, что и билет, к которому он привязан: неявка пассажира,
+	// зафиксированная при завершении посадки, — возврат по правилу неявки
+	if ticket.NoShowAt != nil && reason == RefundReasonVoluntary {
+		reason = RefundReasonNoShow
+	}
+	departed := ticket.NoShowAt != nil || (trip.DepartureTime != nil && !now.Before(*trip.DepartureTime))
+	if err = s.checkRefundable(ctx, baggage.TicketID, baggage.TripID, reason, departed); err != nil {
+		return nil, err
+	}
+
+	rules, err := s.resolveRefundRules(ctx, trip)
+	if err != nil {
+		return nil, err
+	}
+	rate, err := rules.penaltyRate(reason, hoursBeforeDeparture(trip, now))
+	if err != nil {
+		return nil, err
+	}
+
+	shift, err := s.cashierShift(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.refundBaggage(ctx, baggage, shift, userID, reason, rate)
+}
+
+// refundLinkedBaggage возвращает все действующие квитанции, привязанные к возвращённому билету,
+// с той же долей штрафа, что и билет, в смене возврата билета. Вызывается в транзакции возврата
+// билета: если квитанцию вернуть не удалось, не возвращается и билет.
+func (s *ticketService) refundLinkedBaggage(
+	ctx context.Context,
+	ticketID string,
+	shift *models.CashierShift,
+	userID, reason string,
+	penaltyRate float64,
+) error {
+	items, err := s.baggageRepo.FindByTicketID(ctx, ticketID)
+	if err != nil {
+		return fmt.Errorf("failed to find linked baggage: %w", err)
+	}
+	for _, baggage := range items {
+		if baggage.Status != "active" {
+			continue
+		}
+		if _, err = s.refundBaggage(ctx, baggage, shift, userID, reason, penaltyRate); err != nil {
+			return fmt.Errorf("failed to refund linked baggage %s: %w", baggage.ID, err)
+		}
+	}
+	return nil
+}
+
+// refundBaggage оформляет возврат квитанции; сервисный сбор к багажу не применяется.
+// Возвращённая сумма проводится в смене shift (nil — вне смены).
+func (s *ticketService) refundBaggage(
+	ctx context.Context,
+	baggage *models.BaggageTicket,
+	shift *models.CashierShift,
+	userID, reason string,
+	penaltyRate float64,
+) (*RefundResult, error) {
+	penalty := roundMoney(baggage.Price * penaltyRate)
+
+	now := time.Now()
+	refundAmount := roundMoney(baggage.Price - penalty)
+	baggage.Status = "returned"
+	baggage.RefundedAt = &now
+	baggage.RefundAmount = &refundAmount
+	baggage.RefundPenalty = &penalty
+
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.baggageRepo.Update(ctx, baggage); dbErr != nil {
+			return fmt.Errorf("failed to update baggage ticket: %w", dbErr)
+		}
+		if dbErr := s.addCashEntry(ctx, cashEntry(shift, ShiftOpRefund, baggage.PaymentMethod, userID, refundAmount), "baggage", baggage.ID); dbErr != nil {
+			return dbErr
+		}
+		if pubErr := s.publishEvent(ctx, "baggage", baggage.ID, events.BaggageReturned{Baggage: baggageEvent(baggage)}); pubErr != nil {
+			return pubErr
+		}
+		return s.publishAuditEvent(ctx, "baggage", baggage.ID, "refund", userID, baggage.Price, refundAmount)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Baggage refunded",
+		zap.String("baggage_id", baggage.ID),
+		zap.String("ticket_id", baggage.TicketID),
+		zap.Float64("penalty", penalty),
+		zap.Float64("refund", refundAmount))
+
+	return &RefundResult{
+		Reason:         reason,
+		OriginalAmount: baggage.Price,
+		Fare:           baggage.Price,
+		Penalty:        penalty,
+		PenaltyRate:    penaltyRate,
+		RefundAmount:   refundAmount,
+	}, nil
+}
+
+// baggageEvent возвращает багажную квитанцию для событий.
+func baggageEvent(b *models.BaggageTicket) events.Baggage {
+	return events.Baggage{
+		CreatedAt:     b.CreatedAt,
+		ShiftID:       b.ShiftID,
+		RefundedAt:    b.RefundedAt,
+		RefundAmount:  b.RefundAmount,
+		RefundPenalty: b.RefundPenalty,
+		ID:            b.ID,
+		TicketID:      b.TicketID,
+		TripID:        b.TripID,
+		WeightClass:   b.WeightClass,
+		PaymentMethod: b.PaymentMethod,
+		Status:        b.Status,
+		Tariff:        b.Tariff,
+		Price:         b.Price,
+		Pieces:        b.Pieces,
+	}
+}
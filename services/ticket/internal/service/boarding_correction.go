@@ -0,0 +1,244 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+var (
+	// ErrSupervisorRequired возвращается, если отмену или исправление отметки выполняет не старший смены.
+	ErrSupervisorRequired = errors.New("supervisor role required")
+	// ErrInvalidCorrection возвращается при некорректных параметрах исправления отметки.
+	ErrInvalidCorrection = errors.New("invalid boarding correction")
+)
+
+// supervisorRoles — роли, которым разрешено отменять и исправлять отметки посадки.
+var supervisorRoles = map[string]bool{
+	"supervisor": true,
+	"admin":      true,
+}
+
+// CancelBoardingRequest — запрос на отмену отметки посадки (например, отсканирован не тот пассажир).
+type CancelBoardingRequest struct {
+	TicketID string `json:"ticket_id" binding:"required"`
+	Reason   string `json:"reason" binding:"required,max=255"`
+	UserID   string `json:"-"`
+	Role     string `json:"-"`
+}
+
+// CorrectBoardingRequest — запрос на пересадку отмеченного пассажира на другое место, в другой автобус
+// того же рейса (NewVehicleNumber) и/или на другой рейс того же маршрута с начатой посадкой.
+// Отметка посадки сохраняется.
+type CorrectBoardingRequest struct {
+	NewSeatID        *string `json:"new_seat_id"`
+	NewTripID        *string `json:"new_trip_id"`
+	TicketID         string  `json:"ticket_id" binding:"required"`
+	Reason           string  `json:"reason" binding:"required,max=255"`
+	UserID           string  `json:"-"`
+	Role             string  `json:"-"`
+	NewVehicleNumber int     `json:"new_vehicle_number" binding:"omitempty,min=1"`
+}
+
+// CancelBoardingMark снимает отметку посадки. Отметка не удаляется, а помечается отменённой
+// и перестаёт учитываться в статусе посадки; пассажира можно отметить заново.
+func (s *ticketService) CancelBoardingMark(ctx context.Context, req *CancelBoardingRequest) (*models.BoardingCorrection, error) {
+	if !supervisorRoles[req.Role] {
+		return nil, ErrSupervisorRequired
+	}
+
+	ticket, err := s.ticketRepo.FindByID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	mark, err := s.boardingRepo.FindActiveMarkByTicketID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	event, err := s.boardingRepo.FindEventByTripID(ctx, ticket.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding: %w", err)
+	}
+
+	now := time.Now()
+	mark.CancelledAt = &now
+	mark.CancelledBy = &req.UserID
+	mark.CancelReason = &req.Reason
+	correction := &models.BoardingCorrection{
+		MarkID:      mark.ID,
+		TicketID:    ticket.ID,
+		Action:      "cancel",
+		Reason:      req.Reason,
+		PerformedBy: req.UserID,
+		Role:        req.Role,
+	}
+
+	// После завершения посадки пассажир без отметки считается неявившимся
+	var noShowAt *time.Time
+	if event != nil && event.EndedAt != nil {
+		noShowAt = event.EndedAt
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.boardingRepo.CancelMark(ctx, mark, correction, noShowAt); dbErr != nil {
+			return fmt.Errorf("failed to cancel boarding mark: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "boarding_mark", mark.ID, "cancel", req.UserID,
+			map[string]interface{}{"ticket_id": ticket.ID, "marked_at": mark.MarkedAt, "marked_by": mark.MarkedBy},
+			map[string]interface{}{"reason": req.Reason, "role": req.Role})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Boarding mark cancelled",
+		zap.String("ticket_id", ticket.ID),
+		zap.String("mark_id", mark.ID),
+		zap.String("user_id", req.UserID))
+
+	return correction, nil
+}
+
+// CorrectBoardingMark пересаживает отмеченного пассажира на другое место или в другой автобус.
+// При смене рейса билет переносится вместе с отметкой и получает новый QR-код; при пересадке
+// в другой автобус того же рейса QR-код не меняется.
+func (s *ticketService) CorrectBoardingMark(ctx context.Context, req *CorrectBoardingRequest) (*models.BoardingCorrection, error) {
+	if !supervisorRoles[req.Role] {
+		return nil, ErrSupervisorRequired
+	}
+	if req.NewSeatID == nil && req.NewTripID == nil && req.NewVehicleNumber == 0 {
+		return nil, fmt.Errorf("%w: new_seat_id, new_trip_id or new_vehicle_number is required", ErrInvalidCorrection)
+	}
+
+	ticket, err := s.ticketRepo.FindByID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.Status != "active" {
+		return nil, fmt.Errorf("ticket is not active, current status: %s", ticket.Status)
+	}
+	mark, err := s.boardingRepo.FindActiveMarkByTicketID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+
+	correction := &models.BoardingCorrection{
+		OldTripID:    &ticket.TripID,
+		OldSeatID:    ticket.SeatID,
+		OldVehicleID: ticket.VehicleID,
+		MarkID:       mark.ID,
+		TicketID:     ticket.ID,
+		Action:       "correct",
+		Reason:       req.Reason,
+		PerformedBy:  req.UserID,
+		Role:         req.Role,
+	}
+	oldTripID := ticket.TripID
+
+	tripID := ticket.TripID
+	if req.NewTripID != nil && *req.NewTripID != ticket.TripID {
+		if err = s.checkTransferTrip(ctx, ticket.TripID, *req.NewTripID); err != nil {
+			return nil, err
+		}
+		tripID = *req.NewTripID
+		// Место в другом автобусе указывается явно, иначе пассажир едет без места
+		ticket.SeatID = nil
+		ticket.VehicleID = nil
+	}
+	if req.NewVehicleNumber > 0 {
+		vehicle, vErr := s.tripVehicle(ctx, tripID, req.NewVehicleNumber)
+		if vErr != nil {
+			return nil, vErr
+		}
+		if !sameVehicle(vehicle.VehicleID, ticket.VehicleID) {
+			ticket.SeatID = nil
+			ticket.VehicleID = vehicle.VehicleID
+		}
+	}
+	if req.NewSeatID != nil {
+		available, availErr := s.ticketRepo.CheckSeatAvailability(ctx, tripID, ticket.VehicleID, *req.NewSeatID)
+		if availErr != nil {
+			return nil, fmt.Errorf("failed to check seat availability: %w", availErr)
+		}
+		if !available {
+			return nil, repository.ErrSeatAlreadyTaken
+		}
+		ticket.SeatID = req.NewSeatID
+	}
+
+	// Рейс и место входят в подписанный QR-код: после пересадки старый код недействителен
+	if tripID != oldTripID || !sameSeat(correction.OldSeatID, ticket.SeatID) {
+		ticket.TripID = tripID
+		if err = s.issueQRCode(ctx, ticket); err != nil {
+			return nil, err
+		}
+	}
+	correction.NewTripID = &tripID
+	correction.NewSeatID = ticket.SeatID
+	correction.NewVehicleID = ticket.VehicleID
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.boardingRepo.CorrectMark(ctx, ticket, correction); dbErr != nil {
+			return fmt.Errorf("failed to correct boarding mark: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "boarding_mark", mark.ID, "correct", req.UserID,
+			map[string]interface{}{"ticket_id": ticket.ID, "trip_id": oldTripID, "seat_id": correction.OldSeatID, "vehicle_id": correction.OldVehicleID},
+			map[string]interface{}{"trip_id": tripID, "seat_id": ticket.SeatID, "vehicle_id": ticket.VehicleID, "reason": req.Reason, "role": req.Role})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Boarding mark corrected",
+		zap.String("ticket_id", ticket.ID),
+		zap.String("old_trip_id", oldTripID),
+		zap.String("new_trip_id", tripID))
+
+	return correction, nil
+}
+
+// ListBoardingCorrections возвращает журнал отмен и исправлений отметок по билету.
+func (s *ticketService) ListBoardingCorrections(ctx context.Context, ticketID string) ([]*models.BoardingCorrection, error) {
+	return s.boardingRepo.FindCorrectionsByTicketID(ctx, ticketID)
+}
+
+// sameSeat сообщает, что места совпадают (nil — без места).
+func sameSeat(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// checkTransferTrip проверяет, что пассажира можно пересадить в автобус другого рейса:
+// рейс того же маршрута, посадка на нём идёт.
+func (s *ticketService) checkTransferTrip(ctx context.Context, fromTripID, toTripID string) error {
+	from, err := s.ticketRepo.GetTripRefundInfo(ctx, fromTripID)
+	if err != nil {
+		return fmt.Errorf("failed to get trip info: %w", err)
+	}
+	to, err := s.ticketRepo.GetTripRefundInfo(ctx, toTripID)
+	if err != nil {
+		return fmt.Errorf("failed to get target trip info: %w", err)
+	}
+	if from.RouteID != to.RouteID {
+		return fmt.Errorf("%w: target trip is on another route", ErrInvalidCorrection)
+	}
+
+	event, err := s.boardingRepo.FindEventByTripID(ctx, toTripID)
+	if err != nil {
+		return fmt.Errorf("failed to check boarding: %w", err)
+	}
+	if event == nil {
+		return repository.ErrBoardingNotStarted
+	}
+	if event.EndedAt != nil {
+		return repository.ErrBoardingClosed
+	}
+	return nil
+}
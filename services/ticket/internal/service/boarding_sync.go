@@ -0,0 +1,329 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/ticketqr"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Причины конфликтов при выгрузке офлайн-отметок посадки.
+const (
+	ConflictTicketNotFound     = "ticket_not_found"
+	ConflictWrongTrip          = "wrong_trip"
+	ConflictTicketNotActive    = "ticket_not_active"
+	ConflictAlreadyBoarded     = "already_boarded"
+	ConflictInvalidQR          = "invalid_qr"
+	ConflictBoardingNotStarted = "boarding_not_started"
+	ConflictBoardingClosed     = "boarding_closed"
+	ConflictWrongVehicle       = "wrong_vehicle"
+)
+
+// BoardingManifest — манифест рейса для офлайн-посадки; Vehicles — автобусы рейса с перронами.
+type BoardingManifest struct {
+	GeneratedAt       time.Time                 `json:"generated_at"`
+	BoardingStartedAt *time.Time                `json:"boarding_started_at,omitempty"`
+	QRKeys            *ticketqr.KeySet          `json:"qr_keys"`
+	TripID            string                    `json:"trip_id"`
+	Vehicles          []*repository.TripVehicle `json:"vehicles"`
+	Tickets           []BoardingManifestItem    `json:"tickets"`
+}
+
+// BoardingManifestItem — билет в манифесте; VehicleNumber — автобус рейса, за которым закреплено место.
+type BoardingManifestItem struct {
+	BoardedAt     *time.Time `json:"boarded_at,omitempty"`
+	SeatID        *string    `json:"seat_id,omitempty"`
+	PassengerName *string    `json:"passenger_name,omitempty"`
+	TicketID      string     `json:"ticket_id"`
+	QRCode        string     `json:"qr_code"`
+	BarCode       string     `json:"bar_code"`
+	VehicleNumber int        `json:"vehicle_number"`
+	Boarded       bool       `json:"boarded"`
+}
+
+// SyncBoardingRequest — пакет отметок посадки, собранных устройством без связи. VehicleNumber — автобус,
+// у которого работало устройство: отметки билетов другого автобуса рейса возвращаются как wrong_vehicle.
+type SyncBoardingRequest struct {
+	TripID        string            `json:"trip_id" binding:"required"`
+	DeviceID      string            `json:"device_id" binding:"required,max=64"`
+	UserID        string            `json:"user_id" binding:"required"`
+	Marks         []OfflineBoarding `json:"marks" binding:"required,dive"`
+	VehicleNumber int               `json:"vehicle_number" binding:"omitempty,min=1"`
+}
+
+// OfflineBoarding — одна отметка посадки с временем на устройстве.
+type OfflineBoarding struct {
+	MarkedAt   time.Time `json:"marked_at" binding:"required"`
+	TicketID   string    `json:"ticket_id"`
+	QRCode     string    `json:"qr_code"`
+	ScanMethod string    `json:"scan_method"`
+}
+
+// SyncBoardingResult — итог выгрузки: принятые отметки и конфликты, требующие внимания контролёра.
+type SyncBoardingResult struct {
+	TripID        string             `json:"trip_id"`
+	DeviceID      string             `json:"device_id"`
+	Conflicts     []BoardingConflict `json:"conflicts"`
+	Accepted      int                `json:"accepted"`
+	AlreadySynced int                `json:"already_synced"`
+}
+
+// BoardingConflict — отметка, не принятая при выгрузке.
+// Для already_boarded указано, каким устройством и когда пассажир уже отмечен.
+type BoardingConflict struct {
+	ClientMarkedAt   time.Time  `json:"client_marked_at"`
+	ExistingMarkedAt *time.Time `json:"existing_marked_at,omitempty"`
+	ExistingDeviceID *string    `json:"existing_device_id,omitempty"`
+	TicketStatus     *string    `json:"ticket_status,omitempty"`
+	TicketID         string     `json:"ticket_id,omitempty"`
+	QRCode           string     `json:"qr_code,omitempty"`
+	Reason           string     `json:"reason"`
+}
+
+// GetBoardingManifest возвращает действующие билеты рейса с подписанными QR-кодами
+// и ключами для их проверки на устройстве без связи.
+func (s *ticketService) GetBoardingManifest(ctx context.Context, tripID string) (*BoardingManifest, error) {
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+	marks, err := s.boardingRepo.FindMarksByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list boarding marks: %w", err)
+	}
+	event, err := s.boardingRepo.FindEventByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding: %w", err)
+	}
+	keys, err := s.GetQRKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	vehicles, err := s.ticketRepo.GetTripVehicles(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	boardedAt := make(map[string]time.Time, len(marks))
+	for _, m := range marks {
+		boardedAt[m.TicketID] = m.MarkedAt
+	}
+
+	manifest := &BoardingManifest{
+		GeneratedAt: time.Now(),
+		QRKeys:      keys,
+		TripID:      tripID,
+		Vehicles:    vehicles,
+		Tickets:     make([]BoardingManifestItem, 0, len(tickets)),
+	}
+	index := vehicleIndex(vehicles)
+	if event != nil {
+		manifest.BoardingStartedAt = &event.StartedAt
+	}
+	for _, t := range tickets {
+		if t.Status != "active" {
+			continue
+		}
+		item := BoardingManifestItem{
+			SeatID:        t.SeatID,
+			PassengerName: t.PassengerName,
+			TicketID:      t.ID,
+			QRCode:        t.QRCode,
+			BarCode:       t.BarCode,
+		}
+		if i, ok := index[vehicleKey(t.VehicleID)]; ok {
+			item.VehicleNumber = vehicles[i].Number
+		}
+		if at, ok := boardedAt[t.ID]; ok {
+			item.BoardedAt = &at
+			item.Boarded = true
+		}
+		manifest.Tickets = append(manifest.Tickets, item)
+	}
+	return manifest, nil
+}
+
+// SyncBoarding принимает отметки, собранные офлайн. Отметка принимается, если билет действует
+// и пассажир ещё не отмечен; иначе она возвращается как конфликт. Повторная выгрузка
+// тем же устройством не считается конфликтом.
+func (s *ticketService) SyncBoarding(ctx context.Context, req *SyncBoardingRequest) (*SyncBoardingResult, error) {
+	event, err := s.boardingRepo.FindEventByTripID(ctx, req.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding: %w", err)
+	}
+	tickets, err := s.ticketRepo.FindByTripID(ctx, req.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+	marks, err := s.boardingRepo.FindMarksByTripID(ctx, req.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list boarding marks: %w", err)
+	}
+	var vehicle *repository.TripVehicle
+	if req.VehicleNumber > 0 {
+		if vehicle, err = s.tripVehicle(ctx, req.TripID, req.VehicleNumber); err != nil {
+			return nil, err
+		}
+	}
+
+	byID := make(map[string]*models.Ticket, len(tickets))
+	byQR := make(map[string]*models.Ticket, len(tickets))
+	for _, t := range tickets {
+		byID[t.ID] = t
+		byQR[t.QRCode] = t
+	}
+	marked := make(map[string]*models.BoardingMark, len(marks))
+	for _, m := range marks {
+		marked[m.TicketID] = m
+	}
+
+	result := &SyncBoardingResult{
+		TripID:    req.TripID,
+		DeviceID:  req.DeviceID,
+		Conflicts: []BoardingConflict{},
+	}
+	now := time.Now()
+
+	for i := range req.Marks {
+		item := &req.Marks[i]
+		conflict := BoardingConflict{
+			ClientMarkedAt: item.MarkedAt,
+			TicketID:       item.TicketID,
+			QRCode:         item.QRCode,
+		}
+
+		if event == nil {
+			conflict.Reason = ConflictBoardingNotStarted
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+		// После завершения посадки принимаются только отметки, сделанные до него
+		if event.EndedAt != nil && item.MarkedAt.After(*event.EndedAt) {
+			conflict.Reason = ConflictBoardingClosed
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		ticket, reason := s.resolveOfflineTicket(ctx, item, req.TripID, byID, byQR)
+		if ticket != nil {
+			conflict.TicketID = ticket.ID
+		}
+		if reason == "" && ticket.Status != "active" {
+			// Билет возвращён или обменян после загрузки манифеста
+			reason = ConflictTicketNotActive
+			conflict.TicketStatus = &ticket.Status
+		}
+		if reason == "" && vehicle != nil && !sameVehicle(vehicle.VehicleID, ticket.VehicleID) {
+			reason = ConflictWrongVehicle
+		}
+		if reason == "" {
+			if existing, ok := marked[ticket.ID]; ok {
+				if sameDevice(existing.DeviceID, req.DeviceID) {
+					result.AlreadySynced++
+					continue
+				}
+				reason = ConflictAlreadyBoarded
+				conflict.ExistingMarkedAt = &existing.MarkedAt
+				conflict.ExistingDeviceID = existing.DeviceID
+			}
+		}
+		if reason != "" {
+			conflict.Reason = reason
+			result.Conflicts = append(result.Conflicts, conflict)
+			continue
+		}
+
+		// Время устройства может уходить вперёд — отметка не может быть позже выгрузки
+		markedAt := item.MarkedAt
+		if markedAt.After(now) {
+			markedAt = now
+		}
+		deviceID := req.DeviceID
+		mark := &models.BoardingMark{
+			MarkedAt:   markedAt,
+			SyncedAt:   &now,
+			DeviceID:   &deviceID,
+			TicketID:   ticket.ID,
+			MarkedBy:   req.UserID,
+			ScanMethod: item.ScanMethod,
+		}
+		if err = s.boardingRepo.CreateMark(ctx, mark); err != nil {
+			return nil, fmt.Errorf("failed to create boarding mark: %w", err)
+		}
+		marked[ticket.ID] = mark
+		result.Accepted++
+	}
+
+	// Отметки уже приняты: повторная выгрузка вернёт их как already_synced, поэтому сбой записи
+	// события не отменяет результат синхронизации
+	err = s.publishEvent(ctx, "trip", req.TripID, events.BoardingSynced{
+		TripID:        req.TripID,
+		DeviceID:      req.DeviceID,
+		UserID:        req.UserID,
+		Accepted:      result.Accepted,
+		AlreadySynced: result.AlreadySynced,
+		Conflicts:     len(result.Conflicts),
+	})
+	if err != nil {
+		s.logger.Error("Failed to publish boarding sync event", zap.Error(err), zap.String("trip_id", req.TripID))
+	}
+
+	s.logger.Info("Offline boarding synced",
+		zap.String("trip_id", req.TripID),
+		zap.String("device_id", req.DeviceID),
+		zap.Int("accepted", result.Accepted),
+		zap.Int("already_synced", result.AlreadySynced),
+		zap.Int("conflicts", len(result.Conflicts)))
+
+	return result, nil
+}
+
+// resolveOfflineTicket находит билет офлайн-отметки по ID или QR-коду.
+// Возвращает причину конфликта, если билет не найден, относится к другому рейсу или код подделан.
+func (s *ticketService) resolveOfflineTicket(
+	ctx context.Context,
+	item *OfflineBoarding,
+	tripID string,
+	byID, byQR map[string]*models.Ticket,
+) (*models.Ticket, string) {
+	switch {
+	case item.QRCode != "":
+		if t, ok := byQR[item.QRCode]; ok {
+			return t, ""
+		}
+		ticket, err := s.GetTicketByQR(ctx, item.QRCode)
+		switch {
+		case err == nil && ticket.TripID != tripID:
+			return ticket, ConflictWrongTrip
+		case err == nil:
+			return ticket, ""
+		case errors.Is(err, repository.ErrTicketNotFound):
+			return nil, ConflictTicketNotFound
+		default:
+			return nil, ConflictInvalidQR
+		}
+	case item.TicketID != "":
+		if t, ok := byID[item.TicketID]; ok {
+			return t, ""
+		}
+		ticket, err := s.ticketRepo.FindByID(ctx, item.TicketID)
+		if err != nil {
+			return nil, ConflictTicketNotFound
+		}
+		return ticket, ConflictWrongTrip
+	default:
+		return nil, ConflictTicketNotFound
+	}
+}
+
+func sameDevice(deviceID *string, other string) bool {
+	return deviceID != nil && *deviceID == other
+}
@@ -0,0 +1,574 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/config"
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Итог выгрузки продажи водителя.
+const (
+	DriverSaleAccepted      = "accepted"
+	DriverSaleAlreadySynced = "already_synced"
+	DriverSaleRejected      = "rejected"
+)
+
+// Расхождения продажи водителя с манифестом рейса. Продажа с seat_taken, over_capacity или fare_mismatch
+// принимается (деньги получены, чек пробит) и попадает в сверку; с invalid_segment или
+// invalid_components — отклоняется: билет по ней оформить нельзя.
+const (
+	DriverConflictSeatTaken         = "seat_taken"
+	DriverConflictOverCapacity      = "over_capacity"
+	DriverConflictFareMismatch      = "fare_mismatch"
+	DriverConflictInvalidSegment    = "invalid_segment"
+	DriverConflictInvalidComponents = "invalid_components"
+)
+
+// fareMismatchTolerance — допустимое расхождение суммы чека с ценой по правилам (копейка на округление).
+const fareMismatchTolerance = 0.01
+
+// DriverManifest — манифест автобуса рейса для продажи водителем без связи: остановки, занятость мест
+// автобуса по участкам, правила сборов к тарифу и профиль мобильной ККТ.
+type DriverManifest struct {
+	GeneratedAt   time.Time                   `json:"generated_at"`
+	DepartureTime *time.Time                  `json:"departure_time,omitempty"`
+	Capacity      *int                        `json:"capacity,omitempty"`
+	KKT           config.MobileKKTConfig      `json:"kkt"`
+	TripID        string                      `json:"trip_id"`
+	Status        string                      `json:"status"`
+	FareVAT       string                      `json:"fare_vat"`
+	Stops         []repository.TripStop       `json:"stops"`
+	Seats         []SeatOccupancy             `json:"seats"`
+	Legs          []LegLoad                   `json:"legs"`
+	FareRules     []*models.FareComponentRule `json:"fare_rules"`
+	VehicleNumber int                         `json:"vehicle_number"`
+}
+
+// SeatOccupancy — место, занятое билетом на участке [FromStationID, ToStationID).
+type SeatOccupancy struct {
+	SeatID        string `json:"seat_id"`
+	TicketID      string `json:"ticket_id"`
+	FromStationID string `json:"from_station_id"`
+	ToStationID   string `json:"to_station_id"`
+}
+
+// LegLoad — загрузка перегона между соседними остановками: пассажиры всех каналов, из них купившие
+// билет у водителя, и свободные места (nil — автобус не назначен, отрицательное — перегруз).
+type LegLoad struct {
+	Free          *int   `json:"free,omitempty"`
+	FromStationID string `json:"from_station_id"`
+	ToStationID   string `json:"to_station_id"`
+	Passengers    int    `json:"passengers"`
+	DriverSales   int    `json:"driver_sales"`
+}
+
+// SyncDriverSalesRequest — пакет продаж, оформленных водителем на устройстве DeviceID в автобусе
+// рейса VehicleNumber (не задан — основной автобус).
+type SyncDriverSalesRequest struct {
+	TripID        string           `json:"trip_id" binding:"required"`
+	DeviceID      string           `json:"device_id" binding:"required,max=64"`
+	UserID        string           `json:"-"`
+	Sales         []DriverSaleItem `json:"sales" binding:"required,dive"`
+	VehicleNumber int              `json:"vehicle_number" binding:"omitempty,min=1"`
+}
+
+// DriverSaleItem — продажа на устройстве: участок, место (необязательно), составляющие цены,
+// как они напечатаны в чеке, и фискальные реквизиты чека мобильной ККТ.
+type DriverSaleItem struct {
+	SoldAt            time.Time              `json:"sold_at" binding:"required"`
+	SeatID            *string                `json:"seat_id"`
+	PassengerName     *string                `json:"passenger_name"`
+	PassengerDoc      *string                `json:"passenger_doc"`
+	Receipt           DriverReceipt          `json:"receipt" binding:"required"`
+	ClientSaleID      string                 `json:"client_sale_id" binding:"required,max=64"`
+	FromStationID     string                 `json:"from_station_id" binding:"required"`
+	ToStationID       string                 `json:"to_station_id" binding:"required"`
+	PaymentMethod     string                 `json:"payment_method" binding:"required,oneof=cash card"`
+	PassengerCategory string                 `json:"passenger_category" binding:"omitempty,oneof=adult child student senior benefit"`
+	Components        []models.FareComponent `json:"components" binding:"required,min=1"`
+}
+
+// DriverReceipt — реквизиты чека, пробитого на мобильной ККТ.
+type DriverReceipt struct {
+	PrintedAt       time.Time `json:"printed_at" binding:"required"`
+	KKTSerial       string    `json:"kkt_serial" binding:"required,max=50"`
+	FiscalSign      string    `json:"fiscal_sign" binding:"required,max=100"`
+	FiscalDocNumber int       `json:"fiscal_doc_number" binding:"required,gt=0"`
+}
+
+// SyncDriverSalesResult — итог выгрузки по каждой продаже пакета.
+type SyncDriverSalesResult struct {
+	TripID        string              `json:"trip_id"`
+	DeviceID      string              `json:"device_id"`
+	Sales         []DriverSaleOutcome `json:"sales"`
+	Accepted      int                 `json:"accepted"`
+	AlreadySynced int                 `json:"already_synced"`
+	Rejected      int                 `json:"rejected"`
+}
+
+// DriverSaleOutcome — итог выгрузки продажи: Status — accepted, already_synced или rejected;
+// Conflict — расхождение с манифестом (для rejected — причина отказа).
+type DriverSaleOutcome struct {
+	TicketID     *string `json:"ticket_id,omitempty"`
+	SeatID       *string `json:"seat_id,omitempty"`
+	Conflict     *string `json:"conflict,omitempty"`
+	ClientSaleID string  `json:"client_sale_id"`
+	Status       string  `json:"status"`
+}
+
+// DriverSalesReconciliation — сверка продаж водителей с манифестом рейса: загрузка перегонов,
+// итоги по устройствам и продажи с расхождениями.
+type DriverSalesReconciliation struct {
+	TripID    string               `json:"trip_id"`
+	Legs      []LegLoad            `json:"legs"`
+	Devices   []DriverDeviceTotals `json:"devices"`
+	Conflicts []*models.DriverSale `json:"conflicts"`
+}
+
+// DriverDeviceTotals — продажи водителя с одного устройства: число билетов и выручка по способам оплаты.
+type DriverDeviceTotals struct {
+	DeviceID string  `json:"device_id"`
+	DriverID string  `json:"driver_id"`
+	Sales    int     `json:"sales"`
+	Cash     float64 `json:"cash"`
+	Card     float64 `json:"card"`
+	Amount   float64 `json:"amount"`
+}
+
+// GetDriverManifest возвращает манифест автобуса рейса (vehicleNumber 0 — основной) для устройства
+// водителя. ПД пассажиров в манифест не попадают: для продажи достаточно занятости мест по участкам.
+func (s *ticketService) GetDriverManifest(ctx context.Context, tripID string, vehicleNumber int) (*DriverManifest, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	vehicle, err := s.tripVehicle(ctx, tripID, vehicleNumber)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+	tickets = vehicleTickets(tickets, vehicle.VehicleID)
+	rules, err := s.fareComponentRepo.FindForTrip(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find fare component rules: %w", err)
+	}
+
+	manifest := &DriverManifest{
+		GeneratedAt:   time.Now(),
+		DepartureTime: stopDeparture(info, 0),
+		Capacity:      vehicle.Capacity,
+		KKT:           s.cfg.Business.DriverSales.KKT,
+		TripID:        tripID,
+		Status:        info.Status,
+		FareVAT:       s.cfg.Business.Fare.VAT,
+		Stops:         info.Stops,
+		Seats:         []SeatOccupancy{},
+		Legs:          legLoads(info.Stops, tickets, nil, vehicle.Capacity),
+		FareRules:     rules,
+		VehicleNumber: vehicle.Number,
+	}
+	if len(info.Stops) == 0 {
+		return manifest, nil
+	}
+	for _, t := range tickets {
+		if t.Status != "active" || t.SeatID == nil {
+			continue
+		}
+		from, to := ticketSegment(info.Stops, t)
+		manifest.Seats = append(manifest.Seats, SeatOccupancy{
+			SeatID:        *t.SeatID,
+			TicketID:      t.ID,
+			FromStationID: info.Stops[from].StationID,
+			ToStationID:   info.Stops[to].StationID,
+		})
+	}
+	return manifest, nil
+}
+
+// SyncDriverSales оформляет билеты по продажам, сделанным водителем в пути. Продажа уже оплачена
+// и пробита на мобильной ККТ, поэтому окна продаж и квоты остановок к ней не применяются,
+// а расхождения с манифестом (место занято, перегруз, цена не по правилам) не отменяют её,
+// а фиксируются для сверки. Повторная выгрузка продажи возвращает уже оформленный билет.
+func (s *ticketService) SyncDriverSales(ctx context.Context, req *SyncDriverSalesRequest) (*SyncDriverSalesResult, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, req.TripID)
+	if err != nil {
+		return nil, err
+	}
+	vehicle, err := s.tripVehicle(ctx, req.TripID, req.VehicleNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SyncDriverSalesResult{
+		TripID:   req.TripID,
+		DeviceID: req.DeviceID,
+		Sales:    make([]DriverSaleOutcome, 0, len(req.Sales)),
+	}
+	for i := range req.Sales {
+		outcome, syncErr := s.syncDriverSale(ctx, req, &req.Sales[i], info.Stops, vehicle)
+		if syncErr != nil {
+			// Принятые продажи остаются: повторная выгрузка вернёт их как already_synced
+			return nil, syncErr
+		}
+		switch outcome.Status {
+		case DriverSaleAccepted:
+			result.Accepted++
+		case DriverSaleAlreadySynced:
+			result.AlreadySynced++
+		default:
+			result.Rejected++
+		}
+		result.Sales = append(result.Sales, *outcome)
+	}
+
+	s.logger.Info("Driver sales synced",
+		zap.String("trip_id", req.TripID),
+		zap.String("device_id", req.DeviceID),
+		zap.Int("accepted", result.Accepted),
+		zap.Int("already_synced", result.AlreadySynced),
+		zap.Int("rejected", result.Rejected))
+
+	return result, nil
+}
+
+// syncDriverSale оформляет билет по одной продаже водителя в автобусе vehicle.
+func (s *ticketService) syncDriverSale(
+	ctx context.Context,
+	req *SyncDriverSalesRequest,
+	item *DriverSaleItem,
+	stops []repository.TripStop,
+	vehicle *repository.TripVehicle,
+) (*DriverSaleOutcome, error) {
+	outcome := &DriverSaleOutcome{ClientSaleID: item.ClientSaleID, SeatID: item.SeatID}
+
+	existing, err := s.driverSaleRepo.FindByClientSaleID(ctx, req.DeviceID, item.ClientSaleID)
+	switch {
+	case err == nil:
+		outcome.Status = DriverSaleAlreadySynced
+		outcome.TicketID = &existing.TicketID
+		outcome.Conflict = existing.Conflict
+		return outcome, nil
+	case !errors.Is(err, repository.ErrDriverSaleNotFound):
+		return nil, fmt.Errorf("failed to find driver sale: %w", err)
+	}
+
+	from, to := routeStopIndex(stops, item.FromStationID), routeStopIndex(stops, item.ToStationID)
+	if from < 0 || to <= from {
+		return rejectDriverSale(outcome, DriverConflictInvalidSegment), nil
+	}
+	components, fare, total, ok := driverSaleComponents(item.Components)
+	if !ok {
+		return rejectDriverSale(outcome, DriverConflictInvalidComponents), nil
+	}
+
+	ticket := &models.Ticket{
+		TripID:            req.TripID,
+		SeatID:            item.SeatID,
+		PassengerName:     item.PassengerName,
+		PassengerDoc:      item.PassengerDoc,
+		Price:             total,
+		Status:            "active",
+		PaymentMethod:     item.PaymentMethod,
+		FromStationID:     &stops[from].StationID,
+		ToStationID:       &stops[to].StationID,
+		VehicleID:         vehicle.VehicleID,
+		PassengerCategory: PassengerCategoryAdult,
+		Components:        components,
+	}
+	if item.PassengerCategory != "" {
+		ticket.PassengerCategory = item.PassengerCategory
+	}
+	if req.UserID != "" {
+		ticket.SoldBy = &req.UserID
+	}
+	if err = s.issueQRCode(ctx, ticket); err != nil {
+		return nil, err
+	}
+
+	// Время устройства может уходить вперёд — продажа не может быть позже выгрузки
+	soldAt := item.SoldAt
+	if now := time.Now(); soldAt.After(now) {
+		soldAt = now
+	}
+	sale := &models.DriverSale{
+		SoldAt:           soldAt,
+		ReceiptPrintedAt: item.Receipt.PrintedAt,
+		SeatID:           item.SeatID,
+		TicketID:         ticket.ID,
+		TripID:           req.TripID,
+		DeviceID:         req.DeviceID,
+		ClientSaleID:     item.ClientSaleID,
+		DriverID:         req.UserID,
+		FromStationID:    stops[from].StationID,
+		ToStationID:      stops[to].StationID,
+		PaymentMethod:    item.PaymentMethod,
+		KKTSerial:        item.Receipt.KKTSerial,
+		FiscalSign:       item.Receipt.FiscalSign,
+		FiscalDocNumber:  item.Receipt.FiscalDocNumber,
+		Amount:           total,
+	}
+
+	// Продажи рейса сверяются с занятостью мест по очереди; билет, продажа и событие фиксируются вместе
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.salesRepo.LockTrip(ctx, req.TripID); dbErr != nil {
+			return fmt.Errorf("failed to lock trip sales: %w", dbErr)
+		}
+		tickets, dbErr := s.ticketRepo.FindByTripID(ctx, req.TripID)
+		if dbErr != nil {
+			return fmt.Errorf("failed to list tickets: %w", dbErr)
+		}
+		// Место и вместимость сверяются с билетами того автобуса, в котором едет водитель
+		tickets = vehicleTickets(tickets, vehicle.VehicleID)
+		conflict, fErr := s.driverSaleConflict(ctx, ticket, fare, stops, tickets, vehicle.Capacity, from, to)
+		if fErr != nil {
+			return fErr
+		}
+		if conflict == DriverConflictSeatTaken {
+			// Пассажир уже в автобусе: билет оформляется без места, место разбирается при сверке
+			ticket.SeatID = nil
+		}
+		if conflict != "" {
+			sale.Conflict = &conflict
+		}
+		if dbErr = s.ticketRepo.Create(ctx, ticket); dbErr != nil {
+			return fmt.Errorf("failed to create ticket: %w", dbErr)
+		}
+		if dbErr = s.ticketRepo.ReplaceNameTokens(ctx, ticket); dbErr != nil {
+			return fmt.Errorf("failed to index passenger name: %w", dbErr)
+		}
+		if dbErr = s.driverSaleRepo.Create(ctx, sale); dbErr != nil {
+			return fmt.Errorf("failed to create driver sale: %w", dbErr)
+		}
+		event := ticketEvent(ticket)
+		event.DeviceReceipt = &events.DeviceReceipt{
+			PrintedAt:       item.Receipt.PrintedAt,
+			KKTSerial:       item.Receipt.KKTSerial,
+			FiscalSign:      item.Receipt.FiscalSign,
+			FiscalDocNumber: item.Receipt.FiscalDocNumber,
+		}
+		return s.publishEvent(ctx, "ticket", ticket.ID, events.TicketSold{Ticket: event})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Driver sale accepted",
+		zap.String("ticket_id", ticket.ID),
+		zap.String("trip_id", ticket.TripID),
+		zap.String("device_id", req.DeviceID),
+		zap.Stringp("conflict", sale.Conflict),
+		zap.Float64("price", ticket.Price))
+
+	outcome.Status = DriverSaleAccepted
+	outcome.TicketID = &ticket.ID
+	outcome.SeatID = ticket.SeatID
+	outcome.Conflict = sale.Conflict
+	return outcome, nil
+}
+
+// driverSaleConflict сверяет продажу водителя с билетами автобуса: место не занято на участке,
+// на перегонах участка есть свободные места и сумма чека совпадает с ценой по правилам сборов.
+// Возвращает первое найденное расхождение или пустую строку.
+func (s *ticketService) driverSaleConflict(
+	ctx context.Context,
+	ticket *models.Ticket,
+	fare float64,
+	stops []repository.TripStop,
+	tickets []*models.Ticket,
+	capacity *int,
+	from, to int,
+) (string, error) {
+	if ticket.SeatID != nil {
+		for _, t := range tickets {
+			if t.Status != "active" || t.SeatID == nil || *t.SeatID != *ticket.SeatID {
+				continue
+			}
+			if tFrom, tTo := ticketSegment(stops, t); tFrom < to && from < tTo {
+				return DriverConflictSeatTaken, nil
+			}
+		}
+	}
+	if capacity != nil {
+		for _, leg := range legLoads(stops, tickets, nil, capacity)[from:to] {
+			if *leg.Free <= 0 {
+				return DriverConflictOverCapacity, nil
+			}
+		}
+	}
+	expected := &models.Ticket{TripID: ticket.TripID, Price: fare}
+	if err := s.applyFareComponents(ctx, expected); err != nil {
+		return "", err
+	}
+	if math.Abs(expected.Price-ticket.Price) >= fareMismatchTolerance {
+		return DriverConflictFareMismatch, nil
+	}
+	return "", nil
+}
+
+// GetDriverSalesReconciliation сверяет продажи водителей на рейсе с манифестом: загрузка перегонов
+// с учётом всех каналов продаж, выручка по устройствам и продажи, принятые с расхождениями.
+func (s *ticketService) GetDriverSalesReconciliation(ctx context.Context, tripID string) (*DriverSalesReconciliation, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	seats, err := s.waitlistRepo.GetTripSeats(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+	sales, err := s.driverSaleRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list driver sales: %w", err)
+	}
+
+	driverTickets := make(map[string]bool, len(sales))
+	result := &DriverSalesReconciliation{
+		TripID:    tripID,
+		Devices:   []DriverDeviceTotals{},
+		Conflicts: []*models.DriverSale{},
+	}
+	byDevice := make(map[string]int)
+	for _, sale := range sales {
+		driverTickets[sale.TicketID] = true
+		if sale.Conflict != nil {
+			result.Conflicts = append(result.Conflicts, sale)
+		}
+		key := sale.DeviceID + "|" + sale.DriverID
+		i, ok := byDevice[key]
+		if !ok {
+			i = len(result.Devices)
+			byDevice[key] = i
+			result.Devices = append(result.Devices, DriverDeviceTotals{DeviceID: sale.DeviceID, DriverID: sale.DriverID})
+		}
+		totals := &result.Devices[i]
+		totals.Sales++
+		totals.Amount = roundMoney(totals.Amount + sale.Amount)
+		if sale.PaymentMethod == "cash" {
+			totals.Cash = roundMoney(totals.Cash + sale.Amount)
+		} else {
+			totals.Card = roundMoney(totals.Card + sale.Amount)
+		}
+	}
+	result.Legs = legLoads(info.Stops, tickets, driverTickets, seats.Capacity)
+	return result, nil
+}
+
+// legLoads считает загрузку перегонов маршрута действующими билетами; driverTickets — билеты,
+// проданные водителями (для подсчёта их доли).
+func legLoads(stops []repository.TripStop, tickets []*models.Ticket, driverTickets map[string]bool, capacity *int) []LegLoad {
+	if len(stops) < 2 {
+		return []LegLoad{}
+	}
+	legs := make([]LegLoad, len(stops)-1)
+	for i := range legs {
+		legs[i].FromStationID = stops[i].StationID
+		legs[i].ToStationID = stops[i+1].StationID
+	}
+	for _, t := range tickets {
+		if t.Status != "active" {
+			continue
+		}
+		from, to := ticketSegment(stops, t)
+		for i := from; i < to; i++ {
+			legs[i].Passengers++
+			if driverTickets[t.ID] {
+				legs[i].DriverSales++
+			}
+		}
+	}
+	if capacity != nil {
+		for i := range legs {
+			free := *capacity - legs[i].Passengers
+			legs[i].Free = &free
+		}
+	}
+	return legs
+}
+
+// ticketSegment возвращает номера остановок посадки и высадки билета. Без остановок (или с остановкой
+// не из маршрута) билет занимает место от начальной станции до конечной.
+func ticketSegment(stops []repository.TripStop, t *models.Ticket) (from, to int) {
+	return stopSegment(stops, t.FromStationID, t.ToStationID)
+}
+
+// stopSegment возвращает номера остановок участка fromStationID → toStationID (nil — начальная
+// и конечная станции маршрута).
+func stopSegment(stops []repository.TripStop, fromStationID, toStationID *string) (from, to int) {
+	from, to = 0, len(stops)-1
+	if fromStationID != nil {
+		if i := routeStopIndex(stops, *fromStationID); i >= 0 && i < to {
+			from = i
+		}
+	}
+	if toStationID != nil {
+		if i := routeStopIndex(stops, *toStationID); i > from {
+			to = i
+		}
+	}
+	return from, to
+}
+
+// routeStopIndex возвращает номер станции в маршруте или -1.
+func routeStopIndex(stops []repository.TripStop, stationID string) int {
+	for i := range stops {
+		if stops[i].StationID == stationID {
+			return i
+		}
+	}
+	return -1
+}
+
+// driverSaleComponents проверяет составляющие цены из чека водителя: ровно один тариф перевозчика,
+// известные виды сборов, ставки НДС и порядок возврата, положительные суммы. Возвращает составляющие
+// для билета, тариф и итог чека.
+func driverSaleComponents(items []models.FareComponent) (components []models.FareComponent, fare, total float64, ok bool) {
+	components = make([]models.FareComponent, 0, len(items))
+	fares := 0
+	for _, c := range items {
+		if c.Kind == FareComponentFare {
+			fares++
+			fare = c.Amount
+		} else if defaultFareComponentNames[c.Kind] == "" {
+			return nil, 0, 0, false
+		}
+		switch c.RefundMode {
+		case FareRefundFull, FareRefundWithFare, FareRefundNone:
+		default:
+			return nil, 0, 0, false
+		}
+		if c.Amount <= 0 || !fareVATRates[c.VAT] || c.Name == "" {
+			return nil, 0, 0, false
+		}
+		c.RefundAmount = nil
+		c.Amount = roundMoney(c.Amount)
+		components = append(components, c)
+		total += c.Amount
+	}
+	if fares != 1 {
+		return nil, 0, 0, false
+	}
+	return components, fare, roundMoney(total), true
+}
+
+func rejectDriverSale(outcome *DriverSaleOutcome, reason string) *DriverSaleOutcome {
+	outcome.Status = DriverSaleRejected
+	outcome.Conflict = &reason
+	return outcome
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// ExchangeTicketRequest — запрос на обмен билета на другой рейс или место.
+type ExchangeTicketRequest struct {
+	NewSeatID     *string `json:"new_seat_id"`
+	TicketID      string  `json:"-"`
+	UserID        string  `json:"-"`
+	Role          string  `json:"-"`
+	NewTripID     string  `json:"new_trip_id" binding:"required"`
+	PaymentMethod string  `json:"payment_method"`
+	// NewVehicleNumber — автобус нового рейса (1 — основной); не задан — при обмене в том же рейсе
+	// пассажир остаётся в своём автобусе, на другой рейс — в первом автобусе со свободными местами.
+	NewVehicleNumber int `json:"new_vehicle_number" binding:"omitempty,min=1"`
+	// NewPrice — тариф перевозчика на новом рейсе, сборы к нему начисляются как при продаже.
+	NewPrice float64 `json:"new_price" binding:"required,gt=0"`
+}
+
+// ExchangeResult — результат обмена билета.
+// AmountDue — сумма к доплате пассажиром, RefundAmount — сумма к возврату (взаимоисключающие).
+type ExchangeResult struct {
+	Ticket         *models.Ticket `json:"ticket"`
+	OldTicketID    string         `json:"old_ticket_id"`
+	FareDifference float64        `json:"fare_difference"`
+	ExchangeFee    float64        `json:"exchange_fee"`
+	AmountDue      float64        `json:"amount_due"`
+	RefundAmount   float64        `json:"refund_amount"`
+}
+
+// ExchangeTicket обменивает билет на другой рейс или место: выписывает новый билет,
+// удерживает сбор за обмен и рассчитывает доплату или возврат разницы тарифов.
+func (s *ticketService) ExchangeTicket(ctx context.Context, req *ExchangeTicketRequest) (*ExchangeResult, error) {
+	original, err := s.ticketRepo.FindByID(ctx, req.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if original.Status != "active" {
+		return nil, fmt.Errorf("ticket is not active, current status: %s", original.Status)
+	}
+
+	// После начала посадки билет уже не обменять (как и не вернуть)
+	boardingEvent, err := s.boardingRepo.FindEventByTripID(ctx, original.TripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding status: %w", err)
+	}
+	if boardingEvent != nil {
+		return nil, repository.ErrBoardingAlreadyStarted
+	}
+
+	vehicleID := original.VehicleID
+	if req.NewTripID != original.TripID || req.NewVehicleNumber > 0 {
+		vehicle, vErr := s.resolveVehicle(ctx, req.NewTripID, req.NewVehicleNumber)
+		if vErr != nil {
+			return nil, vErr
+		}
+		vehicleID = vehicle.VehicleID
+	}
+	if req.NewSeatID != nil {
+		if seatErr := s.checkSeat(ctx, req.NewTripID, vehicleID, *req.NewSeatID, ""); seatErr != nil {
+			return nil, seatErr
+		}
+	}
+
+	paymentMethod := req.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = original.PaymentMethod
+	}
+	shift, shiftErr := s.cashierShift(ctx, req.UserID, req.Role)
+	if shiftErr != nil {
+		return nil, shiftErr
+	}
+	// Пассажир сохраняет свой участок, если его остановки есть в маршруте нового рейса, иначе едет
+	// от начальной станции до конечной
+	fromStationID, toStationID, err := s.exchangeSegment(ctx, original, req.NewTripID)
+	if err != nil {
+		return nil, err
+	}
+	// На новый рейс действуют окна продаж и квота остановки посадки; при смене места в том же рейсе
+	// остановка посадки и её загрузка не меняются, поэтому квота не проверяется
+	sale, err := s.checkSale(ctx, req.NewTripID, fromStationID, channelOf(shift), time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if req.NewTripID == original.TripID {
+		sale.quota = nil
+	}
+
+	replacement := &models.Ticket{
+		TripID:            req.NewTripID,
+		SeatID:            req.NewSeatID,
+		PassengerName:     original.PassengerName,
+		PassengerDoc:      original.PassengerDoc,
+		Phone:             original.Phone,
+		Email:             original.Email,
+		Price:             req.NewPrice,
+		Status:            "active",
+		PaymentMethod:     paymentMethod,
+		ExchangedFromID:   &original.ID,
+		ShiftID:           shiftIDOf(shift),
+		FromStationID:     sale.stationID,
+		ToStationID:       toStationID,
+		VehicleID:         vehicleID,
+		PassengerCategory: original.PassengerCategory,
+	}
+	if req.UserID != "" {
+		replacement.SoldBy = &req.UserID
+	}
+	// NewPrice — тариф нового рейса: сборы начисляются по его правилам, разница считается по итоговым ценам
+	if err = s.applyFareComponents(ctx, replacement); err != nil {
+		return nil, err
+	}
+
+	fee := roundMoney(s.cfg.Business.Exchange.FeeFixed + original.Price*s.cfg.Business.Exchange.FeeRate)
+	difference := roundMoney(replacement.Price - original.Price)
+	// Доплата и сбор принимаются, а разница тарифов выдаётся тем же способом оплаты: наличные — из кассы,
+	// картой и через СБП — возвратом payment-service по событию ticket.exchanged
+	balance := roundMoney(difference + fee)
+	replacement.ExchangeFee = &fee
+	cash := cashEntry(shift, ShiftOpSale, paymentMethod, req.UserID, balance)
+	if balance <= 0 {
+		cash = cashEntry(shift, ShiftOpRefund, paymentMethod, req.UserID, -balance)
+	}
+	if err = s.issueQRCode(ctx, replacement); err != nil {
+		return nil, err
+	}
+	original.Status = "exchanged"
+
+	result := &ExchangeResult{
+		Ticket:         replacement,
+		OldTicketID:    original.ID,
+		FareDifference: difference,
+		ExchangeFee:    fee,
+	}
+	if balance > 0 {
+		result.AmountDue = balance
+	} else {
+		result.RefundAmount = -balance
+	}
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if qErr := s.reserveStopQuota(ctx, replacement.TripID, sale); qErr != nil {
+			return qErr
+		}
+		// Квота остановки на исходном рейсе считается по действующим билетам: перевод исходного билета
+		// в exchanged освобождает его место в квоте в этой же транзакции
+		if dbErr := s.ticketRepo.Exchange(ctx, original, replacement); dbErr != nil {
+			return fmt.Errorf("failed to exchange ticket: %w", dbErr)
+		}
+		if dbErr := s.ticketRepo.ReplaceNameTokens(ctx, replacement); dbErr != nil {
+			return fmt.Errorf("failed to index passenger name: %w", dbErr)
+		}
+		if dbErr := s.addCashEntry(ctx, cash, "ticket", replacement.ID); dbErr != nil {
+			return dbErr
+		}
+
+		// Фискализация только разницы: доплата и сбор — чек прихода, возврат разницы — чек возврата прихода
+		pubErr := s.publishEvent(ctx, "ticket", original.ID, events.TicketExchanged{
+			ID:              replacement.ID,
+			ExchangedFromID: original.ID,
+			TripID:          replacement.TripID,
+			PaymentMethod:   paymentMethod,
+			OldComponents:   fareComponentEvents(original.Components),
+			NewComponents:   fareComponentEvents(replacement.Components),
+			OldPrice:        original.Price,
+			NewPrice:        replacement.Price,
+			FareDifference:  difference,
+			ExchangeFee:     fee,
+		})
+		if pubErr != nil {
+			return pubErr
+		}
+
+		return s.publishAuditEvent(ctx, "ticket", original.ID, "exchange", req.UserID,
+			map[string]interface{}{"ticket_id": original.ID, "trip_id": original.TripID, "seat_id": original.SeatID, "price": original.Price},
+			map[string]interface{}{"ticket_id": replacement.ID, "trip_id": replacement.TripID, "seat_id": replacement.SeatID, "price": replacement.Price, "exchange_fee": fee})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Ticket exchanged",
+		zap.String("old_ticket_id", original.ID),
+		zap.String("new_ticket_id", replacement.ID),
+		zap.String("new_trip_id", replacement.TripID),
+		zap.Float64("fare_difference", difference),
+		zap.Float64("exchange_fee", fee))
+
+	s.releaseSeat(ctx, original.TripID, original.SeatID)
+
+	return result, nil
+}
+
+// exchangeSegment возвращает остановки посадки и высадки исходного билета для билета на рейс tripID:
+// в том же рейсе — без изменений, на другом — те из них, что есть в его маршруте (nil — начальная
+// или конечная станция).
+func (s *ticketService) exchangeSegment(ctx context.Context, original *models.Ticket, tripID string) (from, to *string, err error) {
+	if tripID == original.TripID || (original.FromStationID == nil && original.ToStationID == nil) {
+		return original.FromStationID, original.ToStationID, nil
+	}
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+	if err != nil {
+		return nil, nil, err
+	}
+	fromIndex := 0
+	if original.FromStationID != nil {
+		if i := boardingStopIndex(info.Stops, *original.FromStationID); i >= 0 {
+			from, fromIndex = original.FromStationID, i
+		}
+	}
+	if original.ToStationID != nil && routeStopIndex(info.Stops, *original.ToStationID) > fromIndex {
+		to = original.ToStationID
+	}
+	return from, to, nil
+}
+
+// roundMoney округляет сумму до копеек.
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
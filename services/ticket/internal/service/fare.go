@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+)
+
+// Виды составляющих цены билета.
+const (
+	FareComponentFare       = "fare"
+	FareComponentStationFee = "station_fee"
+	FareComponentInsurance  = "insurance"
+)
+
+// Порядок возврата составляющей цены.
+const (
+	// FareRefundFull — составляющая возвращается полностью, штраф на неё не начисляется.
+	FareRefundFull = "full"
+	// FareRefundWithFare — составляющая возвращается вместе с тарифом за вычетом штрафа по той же ставке.
+	FareRefundWithFare = "fare"
+	// FareRefundNone — составляющая не возвращается, кроме отмены рейса перевозчиком.
+	FareRefundNone = "none"
+)
+
+// fareComponentName — наименование позиции тарифа перевозчика в чеке.
+const fareComponentName = "Билет на автобус"
+
+// ErrInvalidFareComponentRule возвращается при некорректных параметрах правила начисления сбора.
+var ErrInvalidFareComponentRule = errors.New("invalid fare component rule")
+
+// fareVATRates — ставки НДС в кодах ККТ, допустимые для составляющих цены.
+var fareVATRates = map[string]bool{
+	"none": true, "vat0": true, "vat5": true, "vat7": true, "vat10": true, "vat20": true, "vat22": true,
+}
+
+// defaultFareComponentNames — наименования позиций чека по видам сборов, если в правиле не задано своё.
+var defaultFareComponentNames = map[string]string{
+	FareComponentStationFee: "Сбор за услуги автовокзала",
+	FareComponentInsurance:  "Страховой сбор",
+}
+
+// CreateFareComponentRuleRequest — запрос на создание правила начисления сбора к тарифу.
+// Сумма сбора — Amount плюс доля Rate от тарифа перевозчика (0.01 — 1%).
+type CreateFareComponentRuleRequest struct {
+	StationID  *string `json:"station_id"`
+	RouteID    *string `json:"route_id"`
+	Kind       string  `json:"kind" binding:"required,oneof=station_fee insurance"`
+	Name       string  `json:"name" binding:"omitempty,max=128"`
+	VAT        string  `json:"vat"`
+	RefundMode string  `json:"refund_mode" binding:"required,oneof=full fare none"`
+	UserID     string  `json:"-"`
+	Amount     float64 `json:"amount" binding:"gte=0"`
+	Rate       float64 `json:"rate" binding:"gte=0"`
+}
+
+// CreateFareComponentRule создаёт правило начисления сбора. Правила не изменяются: новое правило
+// для той же станции и маршрута действует вместо прежнего, прежнее выводится из действия отдельно.
+func (s *ticketService) CreateFareComponentRule(ctx context.Context, req *CreateFareComponentRuleRequest) (*models.FareComponentRule, error) {
+	rule := &models.FareComponentRule{
+		StationID:  req.StationID,
+		RouteID:    req.RouteID,
+		Kind:       req.Kind,
+		Name:       req.Name,
+		VAT:        req.VAT,
+		RefundMode: req.RefundMode,
+		CreatedBy:  req.UserID,
+		Amount:     req.Amount,
+		Rate:       req.Rate,
+		IsActive:   true,
+	}
+	if rule.Name == "" {
+		rule.Name = defaultFareComponentNames[rule.Kind]
+	}
+	if rule.VAT == "" {
+		rule.VAT = "none"
+	}
+	if err := validateFareComponentRule(rule); err != nil {
+		return nil, err
+	}
+
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.fareComponentRepo.Create(ctx, rule); dbErr != nil {
+			return fmt.Errorf("failed to create fare component rule: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "fare_component_rule", rule.ID, "create", req.UserID, nil, rule)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Fare component rule created",
+		zap.String("rule_id", rule.ID),
+		zap.String("kind", rule.Kind),
+		zap.Stringp("station_id", rule.StationID),
+		zap.Stringp("route_id", rule.RouteID))
+
+	return rule, nil
+}
+
+func (s *ticketService) GetFareComponentRule(ctx context.Context, id string) (*models.FareComponentRule, error) {
+	return s.fareComponentRepo.FindByID(ctx, id)
+}
+
+func (s *ticketService) ListFareComponentRules(ctx context.Context, activeOnly bool) ([]*models.FareComponentRule, error) {
+	return s.fareComponentRepo.FindAll(ctx, activeOnly)
+}
+
+// DeactivateFareComponentRule выводит правило из действия; проданные билеты хранят свои составляющие.
+func (s *ticketService) DeactivateFareComponentRule(ctx context.Context, id, userID string) error {
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.fareComponentRepo.Deactivate(ctx, id); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "fare_component_rule", id, "deactivate", userID, true, false)
+	})
+}
+
+// applyFareComponents раскладывает цену билета на составляющие: тариф перевозчика (ticket.Price, уже
+// со скидкой по ваучеру) и сборы по правилам для рейса. Доля сбора считается от тарифа без скидки:
+// ваучер уменьшает только тариф. Price билета становится суммой составляющих.
+func (s *ticketService) applyFareComponents(ctx context.Context, ticket *models.Ticket) error {
+	rules, err := s.fareComponentRepo.FindForTrip(ctx, ticket.TripID)
+	if err != nil {
+		return fmt.Errorf("failed to find fare component rules: %w", err)
+	}
+
+	tariff := ticket.Price
+	if ticket.DiscountAmount != nil {
+		tariff += *ticket.DiscountAmount
+	}
+	components := []models.FareComponent{{
+		Kind:       FareComponentFare,
+		Name:       fareComponentName,
+		VAT:        s.cfg.Business.Fare.VAT,
+		RefundMode: FareRefundWithFare,
+		Amount:     ticket.Price,
+	}}
+	total := ticket.Price
+	for _, rule := range rules {
+		amount := roundMoney(rule.Amount + tariff*rule.Rate)
+		if amount <= 0 {
+			continue
+		}
+		components = append(components, models.FareComponent{
+			Kind:       rule.Kind,
+			Name:       rule.Name,
+			VAT:        rule.VAT,
+			RefundMode: rule.RefundMode,
+			Amount:     amount,
+		})
+		total += amount
+	}
+	ticket.Components = components
+	ticket.Price = roundMoney(total)
+	return nil
+}
+
+func validateFareComponentRule(rule *models.FareComponentRule) error {
+	if defaultFareComponentNames[rule.Kind] == "" {
+		return fmt.Errorf("%w: unsupported kind %q", ErrInvalidFareComponentRule, rule.Kind)
+	}
+	switch rule.RefundMode {
+	case FareRefundFull, FareRefundWithFare, FareRefundNone:
+	default:
+		return fmt.Errorf("%w: unsupported refund_mode %q", ErrInvalidFareComponentRule, rule.RefundMode)
+	}
+	if !fareVATRates[rule.VAT] {
+		return fmt.Errorf("%w: unsupported vat %q", ErrInvalidFareComponentRule, rule.VAT)
+	}
+	if rule.Amount < 0 || rule.Rate < 0 || rule.Rate >= 1 || rule.Amount+rule.Rate <= 0 {
+		return fmt.Errorf("%w: amount must be >= 0, rate within [0, 1) and at least one of them positive", ErrInvalidFareComponentRule)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/pii"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// ErrInvalidLookup возвращается, если в поиске по ПД не указан ровно один критерий.
+var ErrInvalidLookup = errors.New("exactly one of passenger_doc, phone or email is required")
+
+// lookupLimit — максимальное число билетов в ответе поиска по ПД.
+const lookupLimit = 50
+
+// piiVisibility — объём ПД пассажира, который видит роль.
+type piiVisibility int
+
+const (
+	// piiMasked — всё маскируется: фамилия с инициалами, последние символы документа и телефона.
+	piiMasked piiVisibility = iota
+	// piiPassenger — ФИО полностью (сверка с документом), документ и контакты маскируются.
+	piiPassenger
+	// piiFull — ПД без маскирования.
+	piiFull
+)
+
+// piiRoleVisibility — видимость ПД по ролям; неизвестные роли получают piiMasked.
+var piiRoleVisibility = map[string]piiVisibility{
+	"admin":      piiFull,
+	"supervisor": piiFull,
+	"cashier":    piiPassenger,
+	"controller": piiPassenger,
+}
+
+// TicketLookupRequest — поиск билетов по точному значению документа, телефона или email.
+type TicketLookupRequest struct {
+	PassengerDoc string `form:"passenger_doc"`
+	Phone        string `form:"phone"`
+	Email        string `form:"email"`
+}
+
+// LookupTickets ищет билеты по слепому индексу поля ПД без расшифровки таблицы.
+func (s *ticketService) LookupTickets(ctx context.Context, req *TicketLookupRequest) ([]*models.Ticket, error) {
+	field, index, err := s.piiCriterion(req.PassengerDoc, req.Phone, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	return s.ticketRepo.FindByPIIIndex(ctx, field, index, lookupLimit)
+}
+
+// piiCriterion выбирает единственный заданный критерий поиска по ПД и возвращает его слепой индекс.
+func (s *ticketService) piiCriterion(doc, phone, email string) (repository.PIIField, string, error) {
+	var (
+		field repository.PIIField
+		value string
+		set   int
+	)
+	for _, c := range []struct {
+		field repository.PIIField
+		value string
+	}{
+		{repository.PIIFieldDocument, doc},
+		{repository.PIIFieldPhone, phone},
+		{repository.PIIFieldEmail, email},
+	} {
+		if c.value != "" {
+			field, value = c.field, c.value
+			set++
+		}
+	}
+	if set != 1 {
+		return "", "", ErrInvalidLookup
+	}
+
+	index := s.piiKeyring.BlindIndex(value)
+	if index == "" {
+		return "", "", ErrInvalidLookup
+	}
+	return field, index, nil
+}
+
+// ReencryptPII перешифровывает активным ключом очередную пачку билетов, записанных
+// открытым текстом или прежним ключом. Возвращает число обработанных билетов.
+func (s *ticketService) ReencryptPII(ctx context.Context) (int, error) {
+	tickets, err := s.ticketRepo.FindForReencryption(ctx, s.piiKeyring.ActiveKeyID(), s.cfg.PII.ReencryptBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find tickets for re-encryption: %w", err)
+	}
+	for i, t := range tickets {
+		if err = s.ticketRepo.SavePII(ctx, t); err != nil {
+			return i, fmt.Errorf("failed to re-encrypt ticket %s: %w", t.ID, err)
+		}
+	}
+	if len(tickets) > 0 {
+		s.logger.Info("Passenger PII re-encrypted",
+			zap.Int("tickets", len(tickets)),
+			zap.String("key_id", s.piiKeyring.ActiveKeyID()))
+	}
+	return len(tickets), nil
+}
+
+// MaskTicket возвращает копию билета с ПД, замаскированными по роли пользователя.
+func MaskTicket(ticket *models.Ticket, role string) *models.Ticket {
+	if ticket == nil {
+		return nil
+	}
+	visibility := piiRoleVisibility[role]
+	if visibility == piiFull {
+		return ticket
+	}
+	masked := *ticket
+	if visibility == piiMasked {
+		masked.PassengerName = maskPII(ticket.PassengerName, pii.MaskName)
+	}
+	masked.PassengerDoc = maskPII(ticket.PassengerDoc, pii.MaskDocument)
+	masked.Phone = maskPII(ticket.Phone, pii.MaskPhone)
+	masked.Email = maskPII(ticket.Email, pii.MaskEmail)
+	return &masked
+}
+
+// MaskTickets маскирует ПД в списке билетов.
+func MaskTickets(tickets []*models.Ticket, role string) []*models.Ticket {
+	masked := make([]*models.Ticket, len(tickets))
+	for i, t := range tickets {
+		masked[i] = MaskTicket(t, role)
+	}
+	return masked
+}
+
+// MaskWaitlistEntry возвращает копию записи листа ожидания с ПД, замаскированными по роли пользователя.
+func MaskWaitlistEntry(entry *models.WaitlistEntry, role string) *models.WaitlistEntry {
+	if entry == nil {
+		return nil
+	}
+	visibility := piiRoleVisibility[role]
+	if visibility == piiFull {
+		return entry
+	}
+	masked := *entry
+	if visibility == piiMasked {
+		masked.PassengerName = maskPII(entry.PassengerName, pii.MaskName)
+	}
+	masked.Phone = maskPII(entry.Phone, pii.MaskPhone)
+	masked.Email = maskPII(entry.Email, pii.MaskEmail)
+	return &masked
+}
+
+// MaskManifest маскирует ФИО пассажиров в манифесте посадки.
+func MaskManifest(manifest *BoardingManifest, role string) *BoardingManifest {
+	if manifest == nil || piiRoleVisibility[role] != piiMasked {
+		return manifest
+	}
+	masked := *manifest
+	masked.Tickets = make([]BoardingManifestItem, len(manifest.Tickets))
+	for i, item := range manifest.Tickets {
+		item.PassengerName = maskPII(item.PassengerName, pii.MaskName)
+		masked.Tickets[i] = item
+	}
+	return &masked
+}
+
+func maskPII(value *string, mask func(string) string) *string {
+	if value == nil {
+		return nil
+	}
+	masked := mask(*value)
+	return &masked
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/ticketqr"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+)
+
+// GetQRKeySet возвращает опубликованный набор открытых ключей для офлайн-проверки QR-кодов.
+func (s *ticketService) GetQRKeySet(ctx context.Context) (*ticketqr.KeySet, error) {
+	keys, err := s.qrKeyRepo.FindPublished(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load QR keys: %w", err)
+	}
+
+	set := &ticketqr.KeySet{Keys: make([]ticketqr.Key, 0, len(keys))}
+	for _, k := range keys {
+		set.Keys = append(set.Keys, ticketqr.NewKey(k.ID, ed25519.PublicKey(k.PublicKey), k.VerifyUntil))
+	}
+	return set, nil
+}
+
+// RotateQRKey выпускает новый ключ подписи; прежний остаётся в наборе для проверки до истечения qr.retired_key_ttl.
+func (s *ticketService) RotateQRKey(ctx context.Context, userID string) (*models.QRSigningKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate QR signing key: %w", err)
+	}
+	kid := make([]byte, 8)
+	if _, err = rand.Read(kid); err != nil {
+		return nil, fmt.Errorf("failed to generate key id: %w", err)
+	}
+
+	seed := base64.StdEncoding.EncodeToString(priv.Seed())
+	key := &models.QRSigningKey{
+		ID:        hex.EncodeToString(kid),
+		PublicKey: pub,
+		Seed:      &seed,
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.qrKeyRepo.Rotate(ctx, key, time.Now().Add(s.cfg.QR.RetiredKeyTTL)); dbErr != nil {
+			return fmt.Errorf("failed to rotate QR signing key: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "qr_signing_key", key.ID, "rotate", userID, nil, map[string]interface{}{"kid": key.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Info("QR signing key rotated", zap.String("kid", key.ID))
+
+	return key, nil
+}
+
+// RotateQRKeyIfDue создаёт первый ключ или ротирует действующий, если он старше qr.rotation_interval.
+func (s *ticketService) RotateQRKeyIfDue(ctx context.Context) error {
+	active, err := s.qrKeyRepo.FindActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find active QR key: %w", err)
+	}
+	if active != nil && time.Since(active.CreatedAt) < s.cfg.QR.RotationInterval {
+		return nil
+	}
+	_, err = s.RotateQRKey(ctx, "system")
+	return err
+}
+
+// ReencryptQRKeys шифрует ключом ПД seed ключей, выпущенных до шифрования, и перешифровывает
+// активным ключом ПД seed, зашифрованные прежним. Возвращает число обработанных ключей.
+func (s *ticketService) ReencryptQRKeys(ctx context.Context) (int, error) {
+	keys, err := s.qrKeyRepo.FindForReencryption(ctx, s.piiKeyring.ActiveKeyID())
+	if err != nil {
+		return 0, fmt.Errorf("failed to find QR keys for re-encryption: %w", err)
+	}
+	for i, k := range keys {
+		if k.Seed == nil {
+			if len(k.LegacyPrivateKey) != ed25519.SeedSize {
+				return i, fmt.Errorf("QR signing key %s has no seed", k.ID)
+			}
+			seed := base64.StdEncoding.EncodeToString(k.LegacyPrivateKey)
+			k.Seed = &seed
+		}
+		if err = s.qrKeyRepo.SaveSeed(ctx, k); err != nil {
+			return i, fmt.Errorf("failed to re-encrypt QR key %s: %w", k.ID, err)
+		}
+	}
+	if len(keys) > 0 {
+		s.logger.Info("QR signing key seeds re-encrypted",
+			zap.Int("keys", len(keys)),
+			zap.String("key_id", s.piiKeyring.ActiveKeyID()))
+	}
+	return len(keys), nil
+}
+
+// errNoQRSeed возвращается, если seed ключа подписи не расшифрован или повреждён.
+var errNoQRSeed = errors.New("QR signing key seed is missing or invalid")
+
+// signingKey возвращает закрытый ключ подписи из seed ключа.
+func signingKey(key *models.QRSigningKey) (ed25519.PrivateKey, error) {
+	if key.Seed == nil {
+		return nil, fmt.Errorf("%w: %s", errNoQRSeed, key.ID)
+	}
+	seed, err := base64.StdEncoding.DecodeString(*key.Seed)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("%w: %s", errNoQRSeed, key.ID)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// issueQRCode присваивает билету ID и подписанный QR-код с рейсом, местом и сроком действия.
+func (s *ticketService) issueQRCode(ctx context.Context, ticket *models.Ticket) error {
+	key, err := s.qrKeyRepo.FindActive(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find active QR key: %w", err)
+	}
+	if key == nil {
+		if key, err = s.RotateQRKey(ctx, "system"); err != nil {
+			return err
+		}
+	}
+
+	if ticket.ID == "" {
+		ticket.ID = uuid.New().String()
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.QR.DefaultValidity)
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, ticket.TripID)
+	switch {
+	case err != nil:
+		s.logger.Warn("Trip departure unknown, using default QR validity", zap.Error(err), zap.String("trip_id", ticket.TripID))
+	case trip.DepartureTime != nil:
+		expiresAt = trip.DepartureTime.Add(s.cfg.QR.ValidAfterDeparture)
+	}
+
+	priv, err := signingKey(key)
+	if err != nil {
+		return err
+	}
+	code, err := ticketqr.Sign(&ticketqr.Claims{
+		TicketID:  ticket.ID,
+		TripID:    ticket.TripID,
+		SeatID:    ticket.SeatID,
+		NotBefore: now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, key.ID, priv)
+	if err != nil {
+		return fmt.Errorf("failed to sign QR code: %w", err)
+	}
+	ticket.QRCode = code
+	return nil
+}
+
+// findTicketBySignedQR проверяет подпись кода по опубликованным ключам и возвращает билет,
+// если код совпадает с текущим кодом билета (после обмена старый код недействителен).
+func (s *ticketService) findTicketBySignedQR(ctx context.Context, code string) (*models.Ticket, error) {
+	keys, err := s.GetQRKeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := ticketqr.Verify(code, keys, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ticket, err := s.ticketRepo.FindByID(ctx, claims.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.QRCode != code {
+		return nil, ticketqr.ErrBadSignature
+	}
+	return ticket, nil
+}
@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Причины возврата. Отмена рейса перевозчиком и неявка определяются по рейсу,
+// клиент может заявить только добровольный возврат или возврат по медицинским показаниям.
+const (
+	RefundReasonVoluntary        = "voluntary"
+	RefundReasonMedical          = "medical"
+	RefundReasonCarrierCancelled = "carrier_cancelled"
+	RefundReasonNoShow           = "no_show"
+)
+
+// tripStatusCancelled — статус рейса, отменённого перевозчиком (schedule-service).
+const tripStatusCancelled = "cancelled" //nolint:misspell // trip status; British spelling intentional
+
+var (
+	// ErrRefundNotAllowed возвращается, когда политика не предусматривает возврат в данный момент.
+	ErrRefundNotAllowed = errors.New("refund is not allowed by refund policy")
+	// ErrDepartureTimeUnknown возвращается, когда время отправления рейса не определено.
+	ErrDepartureTimeUnknown = errors.New("trip departure time is unknown")
+	// ErrMedicalDocumentRequired возвращается при возврате по медицинским показаниям без документа.
+	ErrMedicalDocumentRequired = errors.New("document_ref is required for medical refund")
+	// ErrTicketAlreadyUsed возвращается при попытке вернуть билет пассажира, прошедшего посадку.
+	ErrTicketAlreadyUsed = errors.New("passenger has boarded, ticket is already used")
+	// ErrInvalidRefundPolicy возвращается при некорректных параметрах политики возврата.
+	ErrInvalidRefundPolicy = errors.New("invalid refund policy")
+)
+
+// CreateRefundPolicyRequest — запрос на создание новой версии политики возврата.
+// Повторный запрос с тем же Code создаёт следующую версию и деактивирует предыдущую.
+type CreateRefundPolicyRequest struct {
+	CarrierID         *string             `json:"carrier_id"`
+	RouteID           *string             `json:"route_id"`
+	NoShowPenaltyRate *float64            `json:"no_show_penalty_rate"`
+	Code              string              `json:"code" binding:"required"`
+	Name              string              `json:"name" binding:"required"`
+	UserID            string              `json:"-"`
+	Tiers             []models.RefundTier `json:"tiers" binding:"required,min=1"`
+	ServiceFee        float64             `json:"service_fee" binding:"gte=0"`
+	MedicalExempt     bool                `json:"medical_exempt"`
+}
+
+// refundRules — разобранная политика возврата, по которой считается возврат.
+// Ступени отсортированы по убыванию MinHoursBefore.
+type refundRules struct {
+	policyID          *string
+	policyVersion     *int
+	noShowPenaltyRate *float64
+	tiers             []models.RefundTier
+	serviceFee        float64
+	medicalExempt     bool
+}
+
+// CreateRefundPolicy создаёт новую версию политики возврата.
+func (s *ticketService) CreateRefundPolicy(ctx context.Context, req *CreateRefundPolicyRequest) (*models.RefundPolicy, error) {
+	if err := validateRefundTiers(req.Tiers, req.NoShowPenaltyRate); err != nil {
+		return nil, err
+	}
+
+	tiersJSON, err := json.Marshal(req.Tiers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tiers: %w", err)
+	}
+
+	policy := &models.RefundPolicy{
+		CarrierID:         req.CarrierID,
+		RouteID:           req.RouteID,
+		NoShowPenaltyRate: req.NoShowPenaltyRate,
+		Code:              req.Code,
+		Name:              req.Name,
+		CreatedBy:         req.UserID,
+		Tiers:             models.JSONB(tiersJSON),
+		ServiceFee:        req.ServiceFee,
+		MedicalExempt:     req.MedicalExempt,
+	}
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.refundPolicyRepo.Create(ctx, policy); dbErr != nil {
+			return fmt.Errorf("failed to create refund policy: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "refund_policy", policy.ID, "create", req.UserID, nil, policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Refund policy created",
+		zap.String("policy_id", policy.ID),
+		zap.String("code", policy.Code),
+		zap.Int("version", policy.Version))
+
+	return policy, nil
+}
+
+func (s *ticketService) GetRefundPolicy(ctx context.Context, id string) (*models.RefundPolicy, error) {
+	return s.refundPolicyRepo.FindByID(ctx, id)
+}
+
+func (s *ticketService) ListRefundPolicies(ctx context.Context, activeOnly bool) ([]*models.RefundPolicy, error) {
+	return s.refundPolicyRepo.FindAll(ctx, activeOnly)
+}
+
+// DeactivateRefundPolicy выводит версию политики из действия (версии не удаляются — на них ссылаются билеты).
+func (s *ticketService) DeactivateRefundPolicy(ctx context.Context, id, userID string) error {
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.refundPolicyRepo.Deactivate(ctx, id); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "refund_policy", id, "deactivate", userID, true, false)
+	})
+}
+
+func validateRefundTiers(tiers []models.RefundTier, noShowPenaltyRate *float64) error {
+	seen := make(map[float64]bool, len(tiers))
+	for _, tier := range tiers {
+		if tier.MinHoursBefore < 0 || tier.PenaltyRate < 0 || tier.PenaltyRate > 1 {
+			return fmt.Errorf("%w: tier min_hours_before must be >= 0 and penalty_rate within [0, 1]", ErrInvalidRefundPolicy)
+		}
+		if seen[tier.MinHoursBefore] {
+			return fmt.Errorf("%w: duplicate tier for %.2f hours", ErrInvalidRefundPolicy, tier.MinHoursBefore)
+		}
+		seen[tier.MinHoursBefore] = true
+	}
+	if noShowPenaltyRate != nil && (*noShowPenaltyRate < 0 || *noShowPenaltyRate > 1) {
+		return fmt.Errorf("%w: no_show_penalty_rate must be within [0, 1]", ErrInvalidRefundPolicy)
+	}
+	return nil
+}
+
+// resolveRefundRules подбирает политику возврата для рейса; без политики в БД
+// действуют коэффициенты business.refund_penalty из конфигурации.
+func (s *ticketService) resolveRefundRules(ctx context.Context, trip *repository.TripRefundInfo) (*refundRules, error) {
+	policy, err := s.refundPolicyRepo.FindApplicable(ctx, trip.RouteID, trip.CarrierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find refund policy: %w", err)
+	}
+	if policy == nil {
+		penalty := s.cfg.Business.RefundPenalty
+		return newRefundRules([]models.RefundTier{
+			{MinHoursBefore: 24, PenaltyRate: penalty.Over24Hours},
+			{MinHoursBefore: 12, PenaltyRate: penalty.Between12_24},
+			{MinHoursBefore: 0, PenaltyRate: penalty.Under12Hours},
+		}), nil
+	}
+
+	var tiers []models.RefundTier
+	if err := json.Unmarshal(policy.Tiers, &tiers); err != nil {
+		return nil, fmt.Errorf("failed to parse refund policy %s tiers: %w", policy.ID, err)
+	}
+	rules := newRefundRules(tiers)
+	rules.policyID = &policy.ID
+	rules.policyVersion = &policy.Version
+	rules.noShowPenaltyRate = policy.NoShowPenaltyRate
+	rules.serviceFee = policy.ServiceFee
+	rules.medicalExempt = policy.MedicalExempt
+	return rules, nil
+}
+
+func newRefundRules(tiers []models.RefundTier) *refundRules {
+	sorted := make([]models.RefundTier, len(tiers))
+	copy(sorted, tiers)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinHoursBefore > sorted[j].MinHoursBefore })
+	return &refundRules{tiers: sorted}
+}
+
+// penaltyRate возвращает долю штрафа по причине возврата и числу часов до отправления.
+func (r *refundRules) penaltyRate(reason string, hoursBefore float64) (float64, error) {
+	switch reason {
+	case RefundReasonCarrierCancelled:
+		return 0, nil
+	case RefundReasonMedical:
+		if r.medicalExempt {
+			return 0, nil
+		}
+	case RefundReasonNoShow:
+		if r.noShowPenaltyRate == nil {
+			return 0, ErrRefundNotAllowed
+		}
+		return *r.noShowPenaltyRate, nil
+	}
+	for _, tier := range r.tiers {
+		if hoursBefore >= tier.MinHoursBefore {
+			return tier.PenaltyRate, nil
+		}
+	}
+	return 0, ErrRefundNotAllowed
+}
+
+// calculate считает возврат по билету: штраф удерживается только с тарифа,
+// сервисный сбор не возвращается, кроме отмены рейса перевозчиком.
+func (r *refundRules) calculate(price float64, reason string, hoursBefore float64) (*RefundResult, error) {
+	rate, err := r.penaltyRate(reason, hoursBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceFee := min(r.serviceFee, price)
+	if reason == RefundReasonCarrierCancelled {
+		serviceFee = 0
+	}
+	fare := roundMoney(price - serviceFee)
+	penalty := roundMoney(fare * rate)
+
+	return &RefundResult{
+		PolicyID:       r.policyID,
+		PolicyVersion:  r.policyVersion,
+		Reason:         reason,
+		OriginalAmount: price,
+		Fare:           fare,
+		ServiceFee:     serviceFee,
+		Penalty:        penalty,
+		PenaltyRate:    rate,
+		RefundAmount:   roundMoney(fare - penalty),
+	}, nil
+}
+
+// calculateTicket считает возврат по билету: по составляющим цены, если они сохранены при продаже,
+// иначе — по цене билета с сервисным сбором политики.
+func (r *refundRules) calculateTicket(ticket *models.Ticket, reason string, hoursBefore float64) (*RefundResult, error) {
+	if len(ticket.Components) == 0 {
+		return r.calculate(ticket.Price, reason, hoursBefore)
+	}
+	return r.calculateComponents(ticket.Components, reason, hoursBefore)
+}
+
+// calculateComponents считает возврат по составляющим цены, каждую — по её порядку возврата: штраф
+// удерживается с тарифа и сборов, возвращаемых вместе с ним; невозвратные сборы удерживаются (в ServiceFee),
+// кроме отмены рейса перевозчиком. Сервисный сбор политики не применяется: сборы — составляющие билета.
+func (r *refundRules) calculateComponents(components []models.FareComponent, reason string, hoursBefore float64) (*RefundResult, error) {
+	rate, err := r.penaltyRate(reason, hoursBefore)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RefundResult{
+		PolicyID:      r.policyID,
+		PolicyVersion: r.policyVersion,
+		Components:    make([]models.FareComponent, len(components)),
+		Reason:        reason,
+		PenaltyRate:   rate,
+	}
+	for i, component := range components {
+		refund := component.Amount
+		switch component.RefundMode {
+		case FareRefundWithFare:
+			penalty := roundMoney(component.Amount * rate)
+			refund = roundMoney(component.Amount - penalty)
+			result.Fare += component.Amount
+			result.Penalty += penalty
+		case FareRefundNone:
+			if reason != RefundReasonCarrierCancelled {
+				refund = 0
+				result.ServiceFee += component.Amount
+			}
+		}
+		component.RefundAmount = &refund
+		result.Components[i] = component
+		result.OriginalAmount += component.Amount
+		result.RefundAmount += refund
+	}
+	result.OriginalAmount = roundMoney(result.OriginalAmount)
+	result.Fare = roundMoney(result.Fare)
+	result.ServiceFee = roundMoney(result.ServiceFee)
+	result.Penalty = roundMoney(result.Penalty)
+	result.RefundAmount = roundMoney(result.RefundAmount)
+	return result, nil
+}
+
+// refundReason определяет причину возврата по состоянию рейса и заявленной клиентом причине.
+func refundReason(trip *repository.TripRefundInfo, req *RefundTicketRequest, now time.Time) (string, error) {
+	if trip.Status == tripStatusCancelled {
+		return RefundReasonCarrierCancelled, nil
+	}
+	if trip.DepartureTime == nil {
+		return "", ErrDepartureTimeUnknown
+	}
+	if req.Reason == RefundReasonMedical {
+		if req.DocumentRef == nil || *req.DocumentRef == "" {
+			return "", ErrMedicalDocumentRequired
+		}
+		return RefundReasonMedical, nil
+	}
+	if req.Reason != "" && req.Reason != RefundReasonVoluntary {
+		return "", fmt.Errorf("unsupported refund reason: %s", req.Reason)
+	}
+	if !now.Before(*trip.DepartureTime) {
+		return RefundReasonNoShow, nil
+	}
+	return RefundReasonVoluntary, nil
+}
+
+// hoursBeforeDeparture возвращает число часов до отправления (0, если рейс уже отправился или время неизвестно).
+func hoursBeforeDeparture(trip *repository.TripRefundInfo, now time.Time) float64 {
+	if trip.DepartureTime == nil {
+		return 0
+	}
+	return max(trip.DepartureTime.Sub(now).Hours(), 0)
+}
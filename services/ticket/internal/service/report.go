@@ -0,0 +1,348 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Статусы заданий на построение отчёта.
+const (
+	ReportJobPending = "pending"
+	ReportJobRunning = "running"
+	ReportJobDone    = "done"
+	ReportJobFailed  = "failed"
+)
+
+// Форматы отчёта: JSON — только для построения сразу, CSV и XLSX — файлы.
+const (
+	ReportFormatJSON = "json"
+	ReportFormatCSV  = "csv"
+	ReportFormatXLSX = "xlsx"
+)
+
+const (
+	// reportTypeSales — тип задания: отчёт о продажах.
+	reportTypeSales = "sales"
+	// reportJobListLimit — максимальное число заданий в списке.
+	reportJobListLimit = 100
+)
+
+var (
+	// ErrReportForbidden возвращается, если роли недоступны отчёты или чужое задание.
+	ErrReportForbidden = errors.New("sales reports are not allowed for this role")
+	// ErrInvalidReport возвращается при неверном периоде, разрезе или формате отчёта.
+	ErrInvalidReport = errors.New("invalid report parameters")
+	// ErrReportPeriodTooLong возвращается, если период слишком велик для построения сразу.
+	ErrReportPeriodTooLong = errors.New("report period is too long, create a report job")
+	// ErrReportNotReady возвращается при скачивании незавершённого или неудачного задания.
+	ErrReportNotReady = errors.New("report is not ready")
+)
+
+// reportRoles — роли с доступом к отчётам о продажах.
+var reportRoles = map[string]bool{
+	"accountant": true,
+	"supervisor": true,
+	"admin":      true,
+}
+
+// SalesReportRequest — отчёт о продажах за период (даты YYYY-MM-DD включительно).
+// GroupBy — разрезы через запятую (day, route, carrier, station, cashier, payment_method,
+// passenger_category), по умолчанию day. Фильтры необязательны и объединяются через И.
+type SalesReportRequest struct {
+	DateFrom          string `form:"date_from" json:"date_from" binding:"required"`
+	DateTo            string `form:"date_to" json:"date_to" binding:"required"`
+	GroupBy           string `form:"group_by" json:"group_by,omitempty"`
+	RouteID           string `form:"route_id" json:"route_id,omitempty" binding:"omitempty,uuid"`
+	CarrierID         string `form:"carrier_id" json:"carrier_id,omitempty" binding:"omitempty,uuid"`
+	StationID         string `form:"station_id" json:"station_id,omitempty" binding:"omitempty,uuid"`
+	CashierID         string `form:"cashier_id" json:"cashier_id,omitempty"`
+	PaymentMethod     string `form:"payment_method" json:"payment_method,omitempty"`
+	PassengerCategory string `form:"passenger_category" json:"passenger_category,omitempty"`
+	Format            string `form:"format" json:"format,omitempty" binding:"omitempty,oneof=json csv xlsx"`
+	UserID            string `form:"-" json:"-"`
+	Role              string `form:"-" json:"-"`
+}
+
+// SalesReport — отчёт о продажах: строки по разрезам и итог за период.
+type SalesReport struct {
+	GeneratedAt time.Time                    `json:"generated_at"`
+	Total       *repository.SalesReportRow   `json:"total"`
+	DateFrom    string                       `json:"date_from"`
+	DateTo      string                       `json:"date_to"`
+	GroupBy     []string                     `json:"group_by"`
+	Rows        []*repository.SalesReportRow `json:"rows"`
+}
+
+// ReportFile — файл отчёта для скачивания.
+type ReportFile struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// GetSalesReport строит отчёт о продажах за период не длиннее reports.sync_max_period.
+func (s *ticketService) GetSalesReport(ctx context.Context, req *SalesReportRequest) (*SalesReport, error) {
+	if !reportRoles[req.Role] {
+		return nil, ErrReportForbidden
+	}
+	query, err := salesReportQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	if query.To.Sub(query.From) > s.cfg.Reports.SyncMaxPeriod {
+		return nil, ErrReportPeriodTooLong
+	}
+	return s.salesReport(ctx, req, query)
+}
+
+// ExportSalesReport строит отчёт о продажах сразу и возвращает его файлом в формате req.Format.
+func (s *ticketService) ExportSalesReport(ctx context.Context, req *SalesReportRequest) (*ReportFile, error) {
+	if req.Format != ReportFormatCSV && req.Format != ReportFormatXLSX {
+		return nil, ErrInvalidReport
+	}
+	report, err := s.GetSalesReport(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return renderSalesReport(report, req.Format)
+}
+
+// CreateSalesReportJob ставит в очередь построение отчёта о продажах за период до reports.max_period.
+// Результат — файл CSV или XLSX (по умолчанию), который скачивается по готовности задания.
+func (s *ticketService) CreateSalesReportJob(ctx context.Context, req *SalesReportRequest) (*models.ReportJob, error) {
+	if !reportRoles[req.Role] {
+		return nil, ErrReportForbidden
+	}
+	if req.Format == "" {
+		req.Format = ReportFormatXLSX
+	}
+	if req.Format == ReportFormatJSON {
+		return nil, ErrInvalidReport
+	}
+	query, err := salesReportQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	if query.To.Sub(query.From) > s.cfg.Reports.MaxPeriod {
+		return nil, ErrInvalidReport
+	}
+
+	params, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal report params: %w", err)
+	}
+	job := &models.ReportJob{
+		Type:        reportTypeSales,
+		Format:      req.Format,
+		Status:      ReportJobPending,
+		RequestedBy: req.UserID,
+		Params:      params,
+	}
+	if err = s.reportRepo.CreateJob(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create report job: %w", err)
+	}
+
+	s.logger.Info("Report job created",
+		zap.String("job_id", job.ID),
+		zap.String("type", job.Type),
+		zap.String("requested_by", job.RequestedBy))
+	return job, nil
+}
+
+// ListReportJobs возвращает последние задания пользователя.
+func (s *ticketService) ListReportJobs(ctx context.Context, userID, role string) ([]*models.ReportJob, error) {
+	if !reportRoles[role] {
+		return nil, ErrReportForbidden
+	}
+	return s.reportRepo.FindJobsByUser(ctx, userID, reportJobListLimit)
+}
+
+// GetReportJob возвращает задание. Чужие задания доступны только администратору.
+func (s *ticketService) GetReportJob(ctx context.Context, id, userID, role string) (*models.ReportJob, error) {
+	if !reportRoles[role] {
+		return nil, ErrReportForbidden
+	}
+	job, err := s.reportRepo.FindJobByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.RequestedBy != userID && role != "admin" {
+		return nil, ErrReportForbidden
+	}
+	return job, nil
+}
+
+// DownloadReportJob возвращает файл готового отчёта.
+func (s *ticketService) DownloadReportJob(ctx context.Context, id, userID, role string) (*ReportFile, error) {
+	job, err := s.GetReportJob(ctx, id, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != ReportJobDone {
+		return nil, ErrReportNotReady
+	}
+	return &ReportFile{Name: job.FileName, ContentType: reportContentType(job.Format), Data: job.Result}, nil
+}
+
+// RunReportJobs удаляет просроченные результаты и выполняет все ожидающие задания.
+// Возвращает число выполненных заданий.
+func (s *ticketService) RunReportJobs(ctx context.Context) (int, error) {
+	if _, err := s.reportRepo.DeleteExpiredJobs(ctx, time.Now()); err != nil {
+		return 0, fmt.Errorf("failed to delete expired report jobs: %w", err)
+	}
+	processed := 0
+	for {
+		job, err := s.reportRepo.ClaimJob(ctx, time.Now().Add(-s.cfg.Reports.JobTimeout))
+		if err != nil {
+			return processed, fmt.Errorf("failed to claim report job: %w", err)
+		}
+		if job == nil {
+			return processed, nil
+		}
+		s.runReportJob(ctx, job)
+		processed++
+	}
+}
+
+// runReportJob строит отчёт задания и сохраняет файл или ошибку. Результат хранится reports.result_ttl.
+func (s *ticketService) runReportJob(ctx context.Context, job *models.ReportJob) {
+	file, rows, err := s.buildJobReport(ctx, job)
+	now := time.Now()
+	expiresAt := now.Add(s.cfg.Reports.ResultTTL)
+	job.FinishedAt = &now
+	job.ExpiresAt = &expiresAt
+	if err != nil {
+		msg := err.Error()
+		job.Status = ReportJobFailed
+		job.Error = &msg
+		s.logger.Error("Report job failed", zap.String("job_id", job.ID), zap.Error(err))
+	} else {
+		job.Status = ReportJobDone
+		job.FileName = file.Name
+		job.Result = file.Data
+		job.RowCount = rows
+	}
+	if saveErr := s.reportRepo.SaveJob(ctx, job); saveErr != nil {
+		s.logger.Error("Failed to save report job", zap.String("job_id", job.ID), zap.Error(saveErr))
+		return
+	}
+	s.logger.Info("Report job finished", zap.String("job_id", job.ID), zap.String("status", job.Status))
+}
+
+// buildJobReport строит файл отчёта по параметрам задания и возвращает его с числом строк.
+func (s *ticketService) buildJobReport(ctx context.Context, job *models.ReportJob) (*ReportFile, int, error) {
+	if job.Type != reportTypeSales {
+		return nil, 0, fmt.Errorf("unknown report type %q", job.Type)
+	}
+	var req SalesReportRequest
+	if err := json.Unmarshal(job.Params, &req); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal report params: %w", err)
+	}
+	query, err := salesReportQuery(&req)
+	if err != nil {
+		return nil, 0, err
+	}
+	report, err := s.salesReport(ctx, &req, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	file, err := renderSalesReport(report, job.Format)
+	if err != nil {
+		return nil, 0, err
+	}
+	return file, len(report.Rows), nil
+}
+
+// salesReport выполняет запрос отчёта и считает итог по всем строкам.
+func (s *ticketService) salesReport(ctx context.Context, req *SalesReportRequest, query *repository.SalesReportQuery) (*SalesReport, error) {
+	rows, err := s.reportRepo.SalesReport(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build sales report: %w", err)
+	}
+
+	total := &repository.SalesReportRow{}
+	for _, row := range rows {
+		total.TicketsSold += row.TicketsSold
+		total.TicketsAmount += row.TicketsAmount
+		total.BaggageSold += row.BaggageSold
+		total.BaggageAmount += row.BaggageAmount
+		total.ExchangeFees += row.ExchangeFees
+		total.ExchangedAmount += row.ExchangedAmount
+		total.Refunds += row.Refunds
+		total.RefundsAmount += row.RefundsAmount
+		total.Penalties += row.Penalties
+		total.GrossRevenue += row.GrossRevenue
+		total.NetRevenue += row.NetRevenue
+	}
+	return &SalesReport{
+		GeneratedAt: time.Now(),
+		Total:       total,
+		DateFrom:    req.DateFrom,
+		DateTo:      req.DateTo,
+		GroupBy:     query.GroupBy,
+		Rows:        rows,
+	}, nil
+}
+
+// salesReportQuery проверяет параметры отчёта и переводит их в запрос к репозиторию.
+// Даты берутся в локальном часовом поясе сервиса; дата окончания входит в период.
+func salesReportQuery(req *SalesReportRequest) (*repository.SalesReportQuery, error) {
+	from, err := time.ParseInLocation("2006-01-02", req.DateFrom, time.Local)
+	if err != nil {
+		return nil, ErrInvalidReport
+	}
+	to, err := time.ParseInLocation("2006-01-02", req.DateTo, time.Local)
+	if err != nil || to.Before(from) {
+		return nil, ErrInvalidReport
+	}
+	query := &repository.SalesReportQuery{From: from, To: to.AddDate(0, 0, 1), Filters: map[string]string{}}
+
+	groupBy := req.GroupBy
+	if strings.TrimSpace(groupBy) == "" {
+		groupBy = "day"
+	}
+	seen := map[string]bool{}
+	for _, d := range strings.Split(groupBy, ",") {
+		d = strings.TrimSpace(d)
+		if !isSalesDimension(d) {
+			return nil, ErrInvalidReport
+		}
+		if !seen[d] {
+			seen[d] = true
+			query.GroupBy = append(query.GroupBy, d)
+		}
+	}
+
+	for dimension, value := range map[string]string{
+		"route":              req.RouteID,
+		"carrier":            req.CarrierID,
+		"station":            req.StationID,
+		"cashier":            req.CashierID,
+		"payment_method":     req.PaymentMethod,
+		"passenger_category": req.PassengerCategory,
+	} {
+		if value != "" {
+			query.Filters[dimension] = value
+		}
+	}
+	return query, nil
+}
+
+// isSalesDimension проверяет, что d — разрез отчёта о продажах.
+func isSalesDimension(d string) bool {
+	for _, dimension := range repository.SalesDimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
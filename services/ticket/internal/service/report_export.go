@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// salesSheetName — имя листа отчёта о продажах в XLSX.
+const salesSheetName = "Продажи"
+
+// salesDimensionHeaders — заголовки колонок разрезов отчёта о продажах.
+var salesDimensionHeaders = map[string]string{
+	"day":                "Дата",
+	"route":              "Маршрут",
+	"carrier":            "Перевозчик",
+	"station":            "Станция отправления",
+	"cashier":            "Кассир",
+	"payment_method":     "Способ оплаты",
+	"passenger_category": "Категория пассажира",
+}
+
+// salesMetricHeaders — заголовки колонок показателей в порядке salesMetrics.
+var salesMetricHeaders = []string{
+	"Продано билетов", "Сумма билетов", "Продано мест багажа", "Сумма багажа",
+	"Сборы за обмен", "Зачтено при обмене", "Возвратов", "Выплачено по возвратам",
+	"Удержано штрафов и сборов", "Выручка", "Чистая выручка",
+}
+
+// renderSalesReport выгружает отчёт о продажах в файл CSV или XLSX.
+func renderSalesReport(report *SalesReport, format string) (*ReportFile, error) {
+	header, rows := salesReportTable(report)
+	name := fmt.Sprintf("sales_%s_%s.%s", report.DateFrom, report.DateTo, format)
+
+	var data []byte
+	var err error
+	switch format {
+	case ReportFormatCSV:
+		data, err = writeCSV(header, rows)
+	case ReportFormatXLSX:
+		data, err = writeXLSX(salesSheetName, header, rows)
+	default:
+		return nil, ErrInvalidReport
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to render sales report: %w", err)
+	}
+	return &ReportFile{Name: name, ContentType: reportContentType(format), Data: data}, nil
+}
+
+// salesReportTable раскладывает отчёт в таблицу: колонки разрезов группировки, затем показатели.
+// Последняя строка — итог. Ячейки — строки, целые или суммы (float64).
+func salesReportTable(report *SalesReport) ([]string, [][]interface{}) {
+	grouped := map[string]bool{}
+	for _, d := range report.GroupBy {
+		grouped[d] = true
+	}
+	var dimensions []string
+	for _, d := range repository.SalesDimensions {
+		if grouped[d] {
+			dimensions = append(dimensions, d)
+		}
+	}
+
+	header := make([]string, 0, len(dimensions)+len(salesMetricHeaders))
+	for _, d := range dimensions {
+		header = append(header, salesDimensionHeaders[d])
+	}
+	header = append(header, salesMetricHeaders...)
+
+	rows := make([][]interface{}, 0, len(report.Rows)+1)
+	for _, row := range report.Rows {
+		cells := make([]interface{}, 0, len(header))
+		for _, d := range dimensions {
+			cells = append(cells, salesDimensionValue(row, d))
+		}
+		rows = append(rows, append(cells, salesMetrics(row)...))
+	}
+
+	// Разрез есть всегда (по умолчанию day), итог подписывается в первой колонке
+	total := make([]interface{}, len(dimensions), len(header))
+	for i := range dimensions {
+		total[i] = ""
+	}
+	total[0] = "Итого"
+	return header, append(rows, append(total, salesMetrics(report.Total)...))
+}
+
+// salesDimensionValue возвращает значение разреза строки: для маршрута и станции — название,
+// если оно известно.
+func salesDimensionValue(row *repository.SalesReportRow, dimension string) string {
+	var value *string
+	switch dimension {
+	case "day":
+		if row.Day != nil {
+			return row.Day.Format("2006-01-02")
+		}
+	case "route":
+		value = row.RouteName
+		if value == nil {
+			value = row.RouteID
+		}
+	case "carrier":
+		value = row.CarrierID
+	case "station":
+		value = row.StationName
+		if value == nil {
+			value = row.StationID
+		}
+	case "cashier":
+		value = row.CashierID
+	case "payment_method":
+		value = row.PaymentMethod
+	case "passenger_category":
+		value = row.PassengerCategory
+	}
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+// salesMetrics возвращает показатели строки в порядке salesMetricHeaders.
+func salesMetrics(row *repository.SalesReportRow) []interface{} {
+	return []interface{}{
+		row.TicketsSold, roundMoney(row.TicketsAmount), row.BaggageSold, roundMoney(row.BaggageAmount),
+		roundMoney(row.ExchangeFees), roundMoney(row.ExchangedAmount), row.Refunds, roundMoney(row.RefundsAmount),
+		roundMoney(row.Penalties), roundMoney(row.GrossRevenue), roundMoney(row.NetRevenue),
+	}
+}
+
+// writeCSV записывает таблицу в CSV (UTF-8 с BOM, чтобы Excel верно открывал кириллицу).
+func writeCSV(header []string, rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF")
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			switch v := cell.(type) {
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', 2, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeXLSX записывает таблицу на один лист XLSX с выделенной шапкой; суммы — числа с двумя знаками.
+func writeXLSX(sheet string, header []string, rows [][]interface{}) (data []byte, err error) {
+	f := excelize.NewFile()
+	defer func() {
+		if closeErr := f.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+
+	if err = f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		return nil, err
+	}
+	var headerStyle, moneyStyle int
+	headerStyle, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true}})
+	if err != nil {
+		return nil, err
+	}
+	moneyFormat := "0.00"
+	moneyStyle, err = f.NewStyle(&excelize.Style{CustomNumFmt: &moneyFormat})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = f.SetSheetRow(sheet, "A1", &header); err != nil {
+		return nil, err
+	}
+	var last string
+	last, err = excelize.CoordinatesToCellName(len(header), 1)
+	if err != nil {
+		return nil, err
+	}
+	if err = f.SetCellStyle(sheet, "A1", last, headerStyle); err != nil {
+		return nil, err
+	}
+
+	for i, row := range rows {
+		first, cellErr := excelize.CoordinatesToCellName(1, i+2)
+		if cellErr != nil {
+			return nil, cellErr
+		}
+		if err = f.SetSheetRow(sheet, first, &row); err != nil {
+			return nil, err
+		}
+		for j, cell := range row {
+			if _, ok := cell.(float64); !ok {
+				continue
+			}
+			name, nameErr := excelize.CoordinatesToCellName(j+1, i+2)
+			if nameErr != nil {
+				return nil, nameErr
+			}
+			if err = f.SetCellStyle(sheet, name, name, moneyStyle); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var buf *bytes.Buffer
+	if buf, err = f.WriteToBuffer(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// reportContentType возвращает MIME-тип файла отчёта.
+func reportContentType(format string) string {
+	if format == ReportFormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
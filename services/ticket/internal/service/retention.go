@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Основания обезличивания ПД.
+const (
+	AnonymizeReasonRetention = "retention"
+	AnonymizeReasonErasure   = "erase_request"
+)
+
+// logLimit — максимальное число записей журнала обезличивания в ответе.
+const logLimit = 500
+
+// erasureReporters — сервисы, отчёт которых (pii.anonymized) нужен для выполнения запроса на удаление.
+var erasureReporters = map[string]bool{"notify": true, "document": true}
+
+// ErasureRequestInput — запрос субъекта ПД на удаление данных: ровно один критерий поиска пассажира
+// и основание (номер обращения).
+type ErasureRequestInput struct {
+	PassengerDoc string `json:"passenger_doc"`
+	Phone        string `json:"phone"`
+	Email        string `json:"email"`
+	Basis        string `json:"basis" binding:"required,max=255"`
+	UserID       string `json:"-"`
+	Role         string `json:"-"`
+}
+
+// ErasureRequestDetails — запрос на удаление ПД и журнал обезличивания по нему во всех сервисах.
+type ErasureRequestDetails struct {
+	Request *models.ErasureRequest     `json:"request"`
+	Log     []*models.AnonymizationLog `json:"log"`
+}
+
+// RunRetention обезличивает очередную пачку билетов и записей листа ожидания на рейсы, с даты которых
+// прошло больше pii.retention_period. Возвращает наибольшее из чисел обезличенных билетов и записей:
+// пока оно равно размеру пачки, очистка продолжается.
+func (s *ticketService) RunRetention(ctx context.Context) (int, error) {
+	tripBefore := time.Now().Add(-s.cfg.PII.RetentionPeriod)
+	tickets, err := s.retentionRepo.FindExpiredTickets(ctx, tripBefore, s.cfg.PII.RetentionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired tickets: %w", err)
+	}
+	if len(tickets) > 0 {
+		if err = s.anonymizeTickets(ctx, tickets, AnonymizeReasonRetention, nil); err != nil {
+			return 0, err
+		}
+	}
+	entries, err := s.retentionRepo.FindExpiredWaitlistEntries(ctx, tripBefore, s.cfg.PII.RetentionBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired waitlist entries: %w", err)
+	}
+	if err = s.anonymizeWaitlistEntries(ctx, entries, AnonymizeReasonRetention, nil); err != nil {
+		return 0, err
+	}
+	if len(tickets) == 0 && len(entries) == 0 {
+		return 0, nil
+	}
+
+	s.logger.Info("Passenger PII anonymized by retention",
+		zap.Int("tickets", len(tickets)),
+		zap.Int("waitlist_entries", len(entries)))
+	return max(len(tickets), len(entries)), nil
+}
+
+// CreateErasureRequest обезличивает все билеты пассажира по запросу субъекта ПД
+// и рассылает команду обезличивания связанных уведомлений и документов. Запрос выполнен,
+// когда notify-service и document-service отчитаются о своей части (HandleAnonymized).
+func (s *ticketService) CreateErasureRequest(ctx context.Context, req *ErasureRequestInput) (*ErasureRequestDetails, error) {
+	if !supervisorRoles[req.Role] {
+		return nil, ErrSupervisorRequired
+	}
+	field, index, err := s.piiCriterion(req.PassengerDoc, req.Phone, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	erasure := &models.ErasureRequest{
+		Criterion:      strings.TrimSuffix(string(field), "_index"),
+		CriterionIndex: index,
+		Basis:          req.Basis,
+		RequestedBy:    req.UserID,
+	}
+	if err = s.retentionRepo.CreateErasureRequest(ctx, erasure); err != nil {
+		return nil, fmt.Errorf("failed to create erasure request: %w", err)
+	}
+
+	// Уведомления не связаны с билетами — notify-service находит их по слепым индексам контактов
+	contacts := map[string]bool{}
+	var ticketIDs []string
+	if field != repository.PIIFieldDocument {
+		contacts[index] = true
+	}
+	for {
+		// Обезличенные билеты теряют индексы и не попадают в следующую пачку
+		tickets, findErr := s.ticketRepo.FindByPIIIndex(ctx, field, index, s.cfg.PII.RetentionBatch)
+		if findErr != nil {
+			return nil, fmt.Errorf("failed to find passenger tickets: %w", findErr)
+		}
+		if len(tickets) == 0 {
+			break
+		}
+		for _, t := range tickets {
+			ticketIDs = append(ticketIDs, t.ID)
+			for _, idx := range []*string{t.PhoneIndex, t.EmailIndex} {
+				if idx != nil {
+					contacts[*idx] = true
+				}
+			}
+		}
+		if err = s.anonymizeTickets(ctx, tickets, AnonymizeReasonErasure, &erasure.ID); err != nil {
+			return nil, err
+		}
+		erasure.TicketCount += len(tickets)
+	}
+
+	// Лист ожидания: записи с действующим предложением передают место следующему в очереди,
+	// затем обезличиваются все записи пассажира, в том числе закрытые
+	if _, err = s.cancelWaitlistByContact(ctx, field, index); err != nil {
+		return nil, err
+	}
+	waitlisted := 0
+	if field != repository.PIIFieldDocument {
+		entries, findErr := s.retentionRepo.FindWaitlistEntriesByContact(ctx, field, index)
+		if findErr != nil {
+			return nil, fmt.Errorf("failed to find passenger waitlist entries: %w", findErr)
+		}
+		for _, e := range entries {
+			for _, idx := range []*string{e.PhoneIndex, e.EmailIndex} {
+				if idx != nil {
+					contacts[*idx] = true
+				}
+			}
+		}
+		if err = s.anonymizeWaitlistEntries(ctx, entries, AnonymizeReasonErasure, &erasure.ID); err != nil {
+			return nil, err
+		}
+		waitlisted = len(entries)
+	}
+
+	contactIndexes := make([]string, 0, len(contacts))
+	for idx := range contacts {
+		contactIndexes = append(contactIndexes, idx)
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		// Одна команда на весь запрос, и она уходит даже без билетов и контактов:
+		// каждый сервис отвечает на неё одним отчётом, по которым запрос закрывается
+		if pubErr := s.publishAnonymizeCommand(ctx, AnonymizeReasonErasure, &erasure.ID, ticketIDs, contactIndexes); pubErr != nil {
+			return pubErr
+		}
+		if dbErr := s.retentionRepo.UpdateErasureRequest(ctx, erasure); dbErr != nil {
+			return fmt.Errorf("failed to update erasure request: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "erasure_request", erasure.ID, "erase", req.UserID, nil,
+			map[string]interface{}{"criterion": erasure.Criterion, "basis": req.Basis, "tickets": erasure.TicketCount,
+				"waitlist_entries": waitlisted})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Passenger tickets erased on request, waiting for notify and document reports",
+		zap.String("erasure_request_id", erasure.ID),
+		zap.String("criterion", erasure.Criterion),
+		zap.Int("tickets", erasure.TicketCount),
+		zap.Int("waitlist_entries", waitlisted))
+
+	return s.GetErasureRequest(ctx, erasure.ID)
+}
+
+// GetErasureRequest возвращает запрос на удаление ПД с журналом обезличивания по нему.
+func (s *ticketService) GetErasureRequest(ctx context.Context, id string) (*ErasureRequestDetails, error) {
+	erasure, err := s.retentionRepo.FindErasureRequestByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := s.retentionRepo.FindLogs(ctx, id, "", logLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get anonymization log: %w", err)
+	}
+	return &ErasureRequestDetails{Request: erasure, Log: logs}, nil
+}
+
+// ListAnonymizationLog возвращает журнал обезличивания по записи (билет, уведомление, документ).
+func (s *ticketService) ListAnonymizationLog(ctx context.Context, entityID string) ([]*models.AnonymizationLog, error) {
+	return s.retentionRepo.FindLogs(ctx, "", entityID, logLimit)
+}
+
+// HandleAnonymized записывает в журнал отчёт другого сервиса об обезличенных записях (событие pii.anonymized).
+// Отчёт по запросу на удаление отмечается в запросе; когда отчитались все сервисы, запрос выполнен.
+func (s *ticketService) HandleAnonymized(ctx context.Context, report *events.PIIAnonymized) error {
+	if report.Service == "" || report.EntityType == "" || report.Reason == "" {
+		return eventbus.Permanent(errors.New("invalid pii.anonymized data: service, entity_type and reason are required"))
+	}
+	if report.ErasureRequestID != "" && !erasureReporters[report.Service] {
+		return eventbus.Permanent(fmt.Errorf("unexpected pii.anonymized report from %q", report.Service))
+	}
+	var requestID *string
+	if report.ErasureRequestID != "" {
+		requestID = &report.ErasureRequestID
+	}
+
+	logs := make([]*models.AnonymizationLog, 0, len(report.EntityIDs))
+	for _, entityID := range report.EntityIDs {
+		if entityID == "" {
+			continue
+		}
+		logs = append(logs, &models.AnonymizationLog{
+			ErasureRequestID: requestID,
+			Service:          report.Service,
+			EntityType:       report.EntityType,
+			EntityID:         entityID,
+			Reason:           report.Reason,
+			Fields:           report.Fields,
+		})
+	}
+	if requestID == nil {
+		return s.retentionRepo.CreateLogs(ctx, logs)
+	}
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.retentionRepo.CreateLogs(ctx, logs); dbErr != nil {
+			return dbErr
+		}
+		if dbErr := s.retentionRepo.MarkErasureReported(ctx, *requestID, report.Service, time.Now()); dbErr != nil {
+			return fmt.Errorf("failed to mark erasure report: %w", dbErr)
+		}
+		return nil
+	})
+}
+
+// anonymizeTickets удаляет ПД билетов, пишет журнал и при плановой очистке рассылает команду
+// обезличивания документов по этим билетам (document-service). По запросу на удаление (requestID)
+// билеты уходят в общую команду запроса.
+func (s *ticketService) anonymizeTickets(
+	ctx context.Context,
+	tickets []*models.Ticket,
+	reason string,
+	requestID *string,
+) error {
+	ids := make([]string, 0, len(tickets))
+	logs := make([]*models.AnonymizationLog, 0, len(tickets))
+	for _, t := range tickets {
+		ids = append(ids, t.ID)
+		fields := ticketPIIFields(t)
+		if fields == "" {
+			// Билет без ПД (продажа без указания пассажира) — обезличивать нечего, в журнал не попадает
+			continue
+		}
+		logs = append(logs, &models.AnonymizationLog{
+			ErasureRequestID: requestID,
+			Service:          "ticket",
+			EntityType:       "ticket",
+			EntityID:         t.ID,
+			Reason:           reason,
+			Fields:           fields,
+		})
+	}
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.retentionRepo.Anonymize(ctx, ids, logs); dbErr != nil {
+			return fmt.Errorf("failed to anonymize tickets: %w", dbErr)
+		}
+		if requestID != nil {
+			return nil
+		}
+		return s.publishAnonymizeCommand(ctx, reason, nil, ids, nil)
+	})
+}
+
+// anonymizeWaitlistEntries удаляет ПД записей листа ожидания и пишет журнал. Документов по записям нет,
+// уведомления обезличиваются по контактам, поэтому команда обезличивания не рассылается.
+func (s *ticketService) anonymizeWaitlistEntries(
+	ctx context.Context,
+	entries []*models.WaitlistEntry,
+	reason string,
+	requestID *string,
+) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(entries))
+	logs := make([]*models.AnonymizationLog, 0, len(entries))
+	for _, e := range entries {
+		ids = append(ids, e.ID)
+		fields := waitlistPIIFields(e)
+		if fields == "" {
+			// ПД закрытой записи уже удалены, остаются только слепые индексы — в журнал не попадает
+			continue
+		}
+		logs = append(logs, &models.AnonymizationLog{
+			ErasureRequestID: requestID,
+			Service:          "ticket",
+			EntityType:       "waitlist_entry",
+			EntityID:         e.ID,
+			Reason:           reason,
+			Fields:           fields,
+		})
+	}
+	if err := s.retentionRepo.AnonymizeWaitlistEntries(ctx, ids, logs); err != nil {
+		return fmt.Errorf("failed to anonymize waitlist entries: %w", err)
+	}
+	return nil
+}
+
+// publishAnonymizeCommand рассылает команду pii.anonymize: документы по билетам ticket_ids
+// и уведомления на контакты со слепыми индексами contact_indexes. ПД в команде нет.
+// Команды одной причины плановой очистки доставляются по порядку; команда запроса на удаление
+// уходит и пустой, чтобы сервисы отчитались о запросе.
+func (s *ticketService) publishAnonymizeCommand(
+	ctx context.Context,
+	reason string,
+	requestID *string,
+	ticketIDs, contactIndexes []string,
+) error {
+	if requestID == nil && len(ticketIDs) == 0 && len(contactIndexes) == 0 {
+		return nil
+	}
+	aggregateType, aggregateID := "pii_retention", reason
+	command := events.PIIAnonymize{
+		Reason:         reason,
+		TicketIDs:      ticketIDs,
+		ContactIndexes: contactIndexes,
+	}
+	if requestID != nil {
+		command.ErasureRequestID = *requestID
+		aggregateType, aggregateID = "erasure_request", *requestID
+	}
+	return s.publishEvent(ctx, aggregateType, aggregateID, command)
+}
+
+// ticketPIIFields перечисляет заполненные поля ПД билета через запятую.
+func ticketPIIFields(t *models.Ticket) string {
+	var fields []string
+	for _, f := range []struct {
+		value *string
+		name  string
+	}{
+		{t.PassengerName, "passenger_name"},
+		{t.PassengerDoc, "passenger_doc"},
+		{t.Phone, "phone"},
+		{t.Email, "email"},
+	} {
+		if f.value != nil && *f.value != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+// waitlistPIIFields перечисляет заполненные поля ПД записи листа ожидания через запятую.
+func waitlistPIIFields(e *models.WaitlistEntry) string {
+	var fields []string
+	for _, f := range []struct {
+		value *string
+		name  string
+	}{
+		{e.PassengerName, "passenger_name"},
+		{e.Phone, "phone"},
+		{e.Email, "email"},
+	} {
+		if f.value != nil && *f.value != "" {
+			fields = append(fields, f.name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Каналы продаж: касса — продажа в открытой кассовой смене, онлайн — все остальные продажи.
+const (
+	SalesChannelOnline  = "online"
+	SalesChannelCounter = "counter"
+)
+
+var (
+	// ErrSalesNotOpen возвращается, когда продажа на рейс ещё не открыта.
+	ErrSalesNotOpen = errors.New("sales for this trip are not open yet")
+	// ErrSalesClosed возвращается, когда продажа на рейс уже закрыта.
+	ErrSalesClosed = errors.New("sales for this trip are closed")
+	// ErrTripNotOnSale возвращается для рейса, который отправился, прибыл или отменён.
+	ErrTripNotOnSale = errors.New("trip is not on sale")
+	// ErrStationNotOnRoute возвращается, когда остановка посадки не входит в маршрут рейса
+	// или является конечной.
+	ErrStationNotOnRoute = errors.New("boarding station is not on trip route")
+	// ErrStopQuotaExceeded возвращается, когда квота мест для посадки на остановке исчерпана.
+	ErrStopQuotaExceeded = errors.New("seat quota for boarding station is exhausted")
+	// ErrInvalidSalesSettings возвращается при некорректных окнах продаж или квотах.
+	ErrInvalidSalesSettings = errors.New("invalid trip sales settings")
+)
+
+// tripStatusesClosed — статусы рейса, на который билеты не продаются.
+var tripStatusesClosed = map[string]bool{"departed": true, "arrived": true, tripStatusCancelled: true}
+
+// UpdateSalesSettingsRequest — настройки продажи рейса. Незаданные значения окон берутся из конфигурации,
+// StopQuotas заменяет квоты целиком (пустой список — квот нет).
+type UpdateSalesSettingsRequest struct {
+	Online     models.SalesWindow `json:"online"`
+	Counter    models.SalesWindow `json:"counter"`
+	TripID     string             `json:"-"`
+	UserID     string             `json:"-"`
+	StopQuotas []models.StopQuota `json:"stop_quotas" binding:"dive"`
+}
+
+// TripSalesStatus — действующие правила продажи рейса: окна по каналам для отправления с начальной
+// станции и квоты остановок с числом проданных мест.
+type TripSalesStatus struct {
+	DepartureTime *time.Time                   `json:"departure_time,omitempty"`
+	Settings      *models.TripSalesSettings    `json:"settings,omitempty"`
+	Windows       map[string]SalesWindowStatus `json:"windows"`
+	TripID        string                       `json:"trip_id"`
+	Quotas        []StopQuotaStatus            `json:"quotas"`
+}
+
+// SalesWindowStatus — окно продаж канала; OpensAt == nil — продажа открыта без ограничения по сроку.
+type SalesWindowStatus struct {
+	OpensAt  *time.Time `json:"opens_at,omitempty"`
+	ClosesAt *time.Time `json:"closes_at,omitempty"`
+	Open     bool       `json:"open"`
+}
+
+// StopQuotaStatus — квота остановки и сколько мест с посадкой на ней уже продано.
+type StopQuotaStatus struct {
+	StationID string `json:"station_id"`
+	Seats     int    `json:"seats"`
+	Sold      int    `json:"sold"`
+}
+
+// saleCheck — результат проверки продажи: остановка посадки и её квота (nil — без квоты).
+type saleCheck struct {
+	stationID *string
+	quota     *int
+}
+
+// GetSalesSettings возвращает настройки продажи рейса и действующие окна и квоты.
+func (s *ticketService) GetSalesSettings(ctx context.Context, tripID string) (*TripSalesStatus, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.salesRepo.FindSettings(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sales settings: %w", err)
+	}
+
+	now := time.Now()
+	status := &TripSalesStatus{
+		DepartureTime: info.DepartureTime,
+		Settings:      settings,
+		Windows:       make(map[string]SalesWindowStatus, 2),
+		TripID:        tripID,
+		Quotas:        []StopQuotaStatus{},
+	}
+	for _, channel := range []string{SalesChannelOnline, SalesChannelCounter} {
+		window := SalesWindowStatus{Open: !tripStatusesClosed[info.Status]}
+		if departure := stopDeparture(info, 0); departure != nil {
+			window.OpensAt, window.ClosesAt = s.salesWindow(settings, channel, *departure)
+			window.Open = window.Open && checkSalesWindow(window.OpensAt, window.ClosesAt, now) == nil
+		}
+		status.Windows[channel] = window
+	}
+	if settings != nil && len(settings.StopQuotas) > 0 {
+		sold, countErr := s.salesRepo.CountSoldByStop(ctx, tripID)
+		if countErr != nil {
+			return nil, fmt.Errorf("failed to count sold seats: %w", countErr)
+		}
+		for _, quota := range settings.StopQuotas {
+			status.Quotas = append(status.Quotas, StopQuotaStatus{StationID: quota.StationID, Seats: quota.Seats, Sold: sold[quota.StationID]})
+		}
+	}
+	return status, nil
+}
+
+// UpdateSalesSettings сохраняет окна продаж и квоты остановок рейса. Квоты проверяются при следующих
+// продажах: уже проданные билеты не аннулируются, даже если их больше новой квоты.
+func (s *ticketService) UpdateSalesSettings(ctx context.Context, req *UpdateSalesSettingsRequest) (*models.TripSalesSettings, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, req.TripID)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateSalesSettings(req, info); err != nil {
+		return nil, err
+	}
+
+	settings := &models.TripSalesSettings{
+		Online:     req.Online,
+		Counter:    req.Counter,
+		TripID:     req.TripID,
+		UpdatedBy:  req.UserID,
+		StopQuotas: req.StopQuotas,
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		old, dbErr := s.salesRepo.FindSettings(ctx, req.TripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if old != nil {
+			settings.CreatedAt = old.CreatedAt
+		}
+		if dbErr = s.salesRepo.SaveSettings(ctx, settings); dbErr != nil {
+			return fmt.Errorf("failed to save sales settings: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "trip_sales_settings", req.TripID, "update", req.UserID, old, settings)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Trip sales settings updated",
+		zap.String("trip_id", req.TripID),
+		zap.Int("stop_quotas", len(settings.StopQuotas)))
+
+	return settings, nil
+}
+
+// DeleteSalesSettings сбрасывает настройки продажи рейса: действуют окна из конфигурации, квот нет.
+func (s *ticketService) DeleteSalesSettings(ctx context.Context, tripID, userID string) error {
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		old, dbErr := s.salesRepo.FindSettings(ctx, tripID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if dbErr = s.salesRepo.DeleteSettings(ctx, tripID); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "trip_sales_settings", tripID, "delete", userID, old, nil)
+	})
+}
+
+// checkSale проверяет, что билет на рейс можно продать в канале channel с посадкой на остановке
+// stationID (nil — начальная станция): рейс не отправлен и не отменён, продажа открыта по окну канала
+// для отправления с этой остановки. Квота остановки проверяется при продаже — reserveStopQuota.
+func (s *ticketService) checkSale(ctx context.Context, tripID string, stationID *string, channel string, now time.Time) (*saleCheck, error) {
+	info, err := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if tripStatusesClosed[info.Status] {
+		return nil, fmt.Errorf("%w: trip is %s", ErrTripNotOnSale, info.Status)
+	}
+	settings, err := s.salesRepo.FindSettings(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find sales settings: %w", err)
+	}
+
+	check := &saleCheck{}
+	stopIndex := 0
+	if stationID != nil && *stationID != "" {
+		if stopIndex = boardingStopIndex(info.Stops, *stationID); stopIndex < 0 {
+			return nil, ErrStationNotOnRoute
+		}
+	}
+	if len(info.Stops) > 0 {
+		check.stationID = &info.Stops[stopIndex].StationID
+	}
+	if departure := stopDeparture(info, stopIndex); departure != nil {
+		opensAt, closesAt := s.salesWindow(settings, channel, *departure)
+		if err = checkSalesWindow(opensAt, closesAt, now); err != nil {
+			return nil, err
+		}
+	}
+	if settings != nil && check.stationID != nil {
+		for i := range settings.StopQuotas {
+			if settings.StopQuotas[i].StationID == *check.stationID {
+				check.quota = &settings.StopQuotas[i].Seats
+				break
+			}
+		}
+	}
+	return check, nil
+}
+
+// reserveStopQuota проверяет квоту остановки посадки в транзакции продажи. Продажи рейса с квотой
+// выполняются по очереди (блокировка рейса до конца транзакции), поэтому квоту не превысить.
+func (s *ticketService) reserveStopQuota(ctx context.Context, tripID string, check *saleCheck) error {
+	if check.quota == nil {
+		return nil
+	}
+	if err := s.salesRepo.LockTrip(ctx, tripID); err != nil {
+		return fmt.Errorf("failed to lock trip sales: %w", err)
+	}
+	sold, err := s.salesRepo.CountSoldByStop(ctx, tripID)
+	if err != nil {
+		return fmt.Errorf("failed to count sold seats: %w", err)
+	}
+	if sold[*check.stationID] >= *check.quota {
+		return fmt.Errorf("%w: %d of %d seats sold", ErrStopQuotaExceeded, sold[*check.stationID], *check.quota)
+	}
+	return nil
+}
+
+// salesWindow возвращает начало и конец продаж канала для отправления departure: значения рейса,
+// а если не заданы — из конфигурации. opensAt == nil — начало продаж не ограничено.
+func (s *ticketService) salesWindow(settings *models.TripSalesSettings, channel string, departure time.Time) (opensAt, closesAt *time.Time) {
+	defaults := s.cfg.Business.Sales.Online
+	var window models.SalesWindow
+	if channel == SalesChannelCounter {
+		defaults = s.cfg.Business.Sales.Counter
+	}
+	if settings != nil {
+		window = settings.Online
+		if channel == SalesChannelCounter {
+			window = settings.Counter
+		}
+	}
+
+	openDays, closeMinutes := defaults.OpenDaysBefore, defaults.CloseMinutesBefore
+	if window.OpenDaysBefore != nil {
+		openDays = *window.OpenDaysBefore
+	}
+	if window.CloseMinutesBefore != nil {
+		closeMinutes = *window.CloseMinutesBefore
+	}
+	if openDays > 0 {
+		opens := departure.AddDate(0, 0, -openDays)
+		opensAt = &opens
+	}
+	closes := departure.Add(-time.Duration(closeMinutes) * time.Minute)
+	return opensAt, &closes
+}
+
+// checkSalesWindow проверяет, что момент now попадает в окно продаж [opensAt, closesAt).
+func checkSalesWindow(opensAt, closesAt *time.Time, now time.Time) error {
+	if opensAt != nil && now.Before(*opensAt) {
+		return fmt.Errorf("%w: sales open at %s", ErrSalesNotOpen, opensAt.Format(time.RFC3339))
+	}
+	if closesAt != nil && !now.Before(*closesAt) {
+		return fmt.Errorf("%w: sales closed at %s", ErrSalesClosed, closesAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// stopDeparture возвращает ожидаемое отправление рейса с остановки stopIndex: плановое отправление
+// с учётом задержки плюс время в пути до остановки. nil — время отправления рейса неизвестно.
+func stopDeparture(info *repository.TripSalesInfo, stopIndex int) *time.Time {
+	if info.DepartureTime == nil {
+		return nil
+	}
+	offset := info.DelayMinutes
+	if stopIndex < len(info.Stops) {
+		offset += info.Stops[stopIndex].OffsetMin
+	}
+	departure := info.DepartureTime.Add(time.Duration(offset) * time.Minute)
+	return &departure
+}
+
+// boardingStopIndex возвращает номер остановки посадки в маршруте или -1, если станции нет в маршруте
+// или она конечная (с неё не уехать).
+func boardingStopIndex(stops []repository.TripStop, stationID string) int {
+	for i := 0; i < len(stops)-1; i++ {
+		if stops[i].StationID == stationID {
+			return i
+		}
+	}
+	return -1
+}
+
+// channelOf возвращает канал продажи: продажа в кассовой смене — касса, остальные — онлайн.
+func channelOf(shift *models.CashierShift) string {
+	if shift != nil {
+		return SalesChannelCounter
+	}
+	return SalesChannelOnline
+}
+
+func validateSalesSettings(req *UpdateSalesSettingsRequest, info *repository.TripSalesInfo) error {
+	for _, window := range []models.SalesWindow{req.Online, req.Counter} {
+		if (window.OpenDaysBefore != nil && *window.OpenDaysBefore < 0) ||
+			(window.CloseMinutesBefore != nil && *window.CloseMinutesBefore < 0) {
+			return fmt.Errorf("%w: open_days_before and close_minutes_before must be >= 0", ErrInvalidSalesSettings)
+		}
+	}
+	seen := make(map[string]bool, len(req.StopQuotas))
+	for _, quota := range req.StopQuotas {
+		if quota.Seats < 0 {
+			return fmt.Errorf("%w: seats must be >= 0", ErrInvalidSalesSettings)
+		}
+		if seen[quota.StationID] {
+			return fmt.Errorf("%w: duplicate quota for station %s", ErrInvalidSalesSettings, quota.StationID)
+		}
+		seen[quota.StationID] = true
+		if boardingStopIndex(info.Stops, quota.StationID) < 0 {
+			return fmt.Errorf("%w: station %s", ErrStationNotOnRoute, quota.StationID)
+		}
+	}
+	return nil
+}
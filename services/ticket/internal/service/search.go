@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+var (
+	// ErrSearchForbidden возвращается, если роли недоступен поиск билетов.
+	ErrSearchForbidden = errors.New("ticket search is not allowed for this role")
+	// ErrInvalidSearch возвращается для поиска без условий или с неверным периодом.
+	ErrInvalidSearch = errors.New("invalid ticket search criteria")
+	// ErrPassengerCriterionRequired возвращается, если кассир ищет без данных пассажира или кода брони.
+	ErrPassengerCriterionRequired = errors.New("name, passenger_doc, phone, email or booking_code is required")
+)
+
+const (
+	// searchPageSize — размер страницы поиска по умолчанию, searchMaxPageSize — наибольший.
+	searchPageSize    = 20
+	searchMaxPageSize = 100
+	// searchNameMatch — доля токенов запроса, которые должны совпасть с ФИО билета.
+	searchNameMatch = 0.6
+	// searchMaxPeriod — наибольший период поиска по датам рейсов.
+	searchMaxPeriod = 366 * 24 * time.Hour
+)
+
+// searchRoles — роли с доступом к поиску. Кассир обязан указать пассажира или код брони,
+// старший смены и администратор могут искать только по датам и станции.
+var searchRoles = map[string]bool{
+	"cashier":    true,
+	"supervisor": true,
+	"admin":      true,
+}
+
+// TicketSearchRequest — поиск билетов кассиром. Все условия необязательны и объединяются через И.
+// Name ищется нечётко (опечатки, кириллица/латиница), документ, телефон и email — по точному значению.
+type TicketSearchRequest struct {
+	Name         string `form:"name"`
+	PassengerDoc string `form:"passenger_doc"`
+	Phone        string `form:"phone"`
+	Email        string `form:"email"`
+	BookingCode  string `form:"booking_code"`
+	DateFrom     string `form:"date_from"`
+	DateTo       string `form:"date_to"`
+	StationID    string `form:"station_id" binding:"omitempty,uuid"`
+	Status       string `form:"status"`
+	Role         string `form:"-"`
+	Page         int    `form:"page" binding:"omitempty,min=1"`
+	PageSize     int    `form:"page_size" binding:"omitempty,min=1"`
+}
+
+// TicketSearchResult — страница результатов поиска.
+type TicketSearchResult struct {
+	Tickets  []*models.Ticket `json:"tickets"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"page_size"`
+}
+
+// SearchTickets ищет билеты по ФИО, документу, телефону, email, коду брони, датам рейса и станции.
+// ПД в БД не расшифровываются: точные условия сравниваются по слепым индексам, ФИО — по токенам.
+// Билеты в результате не маскированы — маскирование по роли выполняет вызывающий.
+func (s *ticketService) SearchTickets(ctx context.Context, req *TicketSearchRequest) (*TicketSearchResult, error) {
+	if !searchRoles[req.Role] {
+		return nil, ErrSearchForbidden
+	}
+
+	filter := &repository.TicketSearchFilter{
+		DocIndex:   s.piiKeyring.BlindIndex(req.PassengerDoc),
+		PhoneIndex: s.piiKeyring.BlindIndex(req.Phone),
+		EmailIndex: s.piiKeyring.BlindIndex(req.Email),
+		Code:       strings.TrimSpace(req.BookingCode),
+		StationID:  req.StationID,
+		Status:     req.Status,
+	}
+	// Значение без букв и цифр даёт пустой индекс и не должно превращаться в «без условия»
+	for _, c := range []struct{ value, index string }{
+		{req.PassengerDoc, filter.DocIndex},
+		{req.Phone, filter.PhoneIndex},
+		{req.Email, filter.EmailIndex},
+	} {
+		if strings.TrimSpace(c.value) != "" && c.index == "" {
+			return nil, ErrInvalidSearch
+		}
+	}
+	if strings.TrimSpace(req.Name) != "" {
+		filter.NameTokens = s.piiKeyring.NameTokens(req.Name)
+		if len(filter.NameTokens) == 0 {
+			return nil, ErrInvalidSearch
+		}
+		filter.MinNameMatches = int(float64(len(filter.NameTokens))*searchNameMatch + 0.5)
+	}
+
+	var err error
+	if filter.DateFrom, err = parseSearchDate(req.DateFrom); err != nil {
+		return nil, err
+	}
+	if filter.DateTo, err = parseSearchDate(req.DateTo); err != nil {
+		return nil, err
+	}
+	if filter.DateFrom != nil && filter.DateTo != nil &&
+		(filter.DateTo.Before(*filter.DateFrom) || filter.DateTo.Sub(*filter.DateFrom) > searchMaxPeriod) {
+		return nil, ErrInvalidSearch
+	}
+
+	passenger := len(filter.NameTokens) > 0 || filter.DocIndex != "" || filter.PhoneIndex != "" ||
+		filter.EmailIndex != "" || filter.Code != ""
+	if !passenger {
+		if !supervisorRoles[req.Role] {
+			return nil, ErrPassengerCriterionRequired
+		}
+		// Выгрузка всех билетов без условий не нужна никому
+		if filter.DateFrom == nil && filter.DateTo == nil && filter.StationID == "" {
+			return nil, ErrInvalidSearch
+		}
+	}
+
+	page, pageSize := req.Page, req.PageSize
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = searchPageSize
+	}
+	if pageSize > searchMaxPageSize {
+		pageSize = searchMaxPageSize
+	}
+	filter.Offset = (page - 1) * pageSize
+	filter.Limit = pageSize
+
+	tickets, total, err := s.ticketRepo.Search(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return &TicketSearchResult{Tickets: tickets, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+// parseSearchDate разбирает дату рейса в формате YYYY-MM-DD; пустая строка — без ограничения.
+func parseSearchDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, ErrInvalidSearch
+	}
+	return &date, nil
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Статусы расчёта с перевозчиком.
+const (
+	SettlementDraft  = "draft"
+	SettlementClosed = "closed"
+)
+
+// ReportFormatPDF — формат выгрузки расчёта с перевозчиком в PDF (XLSX — ReportFormatXLSX).
+const ReportFormatPDF = "pdf"
+
+var (
+	// ErrSettlementForbidden возвращается, если роли недоступны расчёты с перевозчиками.
+	ErrSettlementForbidden = errors.New("carrier settlements are not allowed for this role")
+	// ErrInvalidSettlement возвращается при неверном периоде или формате выгрузки расчёта.
+	ErrInvalidSettlement = errors.New("invalid settlement parameters")
+	// ErrSettlementOverlap возвращается, если у перевозчика уже есть расчёт за пересекающийся период.
+	ErrSettlementOverlap = errors.New("settlement period overlaps another settlement of the carrier")
+	// ErrSettlementClosed возвращается при изменении или удалении закрытого расчёта.
+	ErrSettlementClosed = errors.New("settlement is closed")
+	// ErrSettlementNotClosed возвращается при проверке расчёта, который ещё не закрыт.
+	ErrSettlementNotClosed = errors.New("settlement is not closed")
+	// ErrSettlementPeriodOpen возвращается при закрытии расчёта до окончания его периода.
+	ErrSettlementPeriodOpen = errors.New("settlement period has not ended yet")
+)
+
+// settlementRoles — роли с доступом к расчётам с перевозчиками.
+var settlementRoles = map[string]bool{
+	"accountant": true,
+	"admin":      true,
+}
+
+// CreateSettlementRequest — расчёт с перевозчиком за период (даты YYYY-MM-DD включительно).
+type CreateSettlementRequest struct {
+	CarrierID string `json:"carrier_id" binding:"required,uuid"`
+	DateFrom  string `json:"date_from" binding:"required"`
+	DateTo    string `json:"date_to" binding:"required"`
+	UserID    string `json:"-"`
+	Role      string `json:"-"`
+}
+
+// SettlementVerification — проверка закрытого расчёта: HashValid — содержимое совпадает с хешем,
+// записанным при закрытии; Current — суммы, пересчитанные по текущим данным, и PayableDifference —
+// их расхождение с закрытым расчётом (например, после исправления продаж задним числом).
+type SettlementVerification struct {
+	Current           *models.SettlementAmounts `json:"current"`
+	SettlementID      string                    `json:"settlement_id"`
+	ContentHash       string                    `json:"content_hash"`
+	PayableDifference float64                   `json:"payable_difference"`
+	HashValid         bool                      `json:"hash_valid"`
+}
+
+// settlementContent — содержимое закрытого расчёта, от которого считается ContentHash.
+type settlementContent struct {
+	ClosedAt       time.Time                `json:"closed_at"`
+	ID             string                   `json:"id"`
+	CarrierID      string                   `json:"carrier_id"`
+	CarrierINN     string                   `json:"carrier_inn"`
+	PeriodFrom     string                   `json:"period_from"`
+	PeriodTo       string                   `json:"period_to"`
+	ClosedBy       string                   `json:"closed_by"`
+	Lines          []models.SettlementLine  `json:"lines"`
+	CommissionRate float64                  `json:"commission_rate"`
+	ServiceFee     float64                  `json:"service_fee"`
+	Amounts        models.SettlementAmounts `json:"amounts"`
+}
+
+// CreateSettlement формирует черновик расчёта с перевозчиком по его текущим ставкам.
+func (s *ticketService) CreateSettlement(ctx context.Context, req *CreateSettlementRequest) (*models.CarrierSettlement, error) {
+	if !settlementRoles[req.Role] {
+		return nil, ErrSettlementForbidden
+	}
+	if _, _, err := settlementPeriod(req.DateFrom, req.DateTo, s.cfg.Reports.MaxPeriod); err != nil {
+		return nil, err
+	}
+	return s.createSettlement(ctx, req.CarrierID, req.DateFrom, req.DateTo, req.UserID)
+}
+
+// createSettlement формирует расчёт без проверки роли (вызывается и фоновой задачей).
+func (s *ticketService) createSettlement(ctx context.Context, carrierID, dateFrom, dateTo, userID string) (*models.CarrierSettlement, error) {
+	settlement := &models.CarrierSettlement{
+		CarrierID:  carrierID,
+		PeriodFrom: dateFrom,
+		PeriodTo:   dateTo,
+		Status:     SettlementDraft,
+		CreatedBy:  userID,
+	}
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if lockErr := s.settlementRepo.LockCarrier(ctx, carrierID); lockErr != nil {
+			return lockErr
+		}
+		overlap, dbErr := s.settlementRepo.HasOverlap(ctx, carrierID, dateFrom, dateTo, "")
+		if dbErr != nil {
+			return dbErr
+		}
+		if overlap {
+			return ErrSettlementOverlap
+		}
+		carrier, dbErr := s.settlementRepo.FindCarrier(ctx, carrierID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if calcErr := s.calculateSettlement(ctx, settlement, carrier); calcErr != nil {
+			return calcErr
+		}
+		if dbErr = s.settlementRepo.Create(ctx, settlement); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "settlement", settlement.ID, "create", userID, nil, settlementAudit(settlement))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Carrier settlement created",
+		zap.String("settlement_id", settlement.ID),
+		zap.String("carrier_id", carrierID),
+		zap.String("period_from", dateFrom),
+		zap.String("period_to", dateTo),
+		zap.Float64("payable", settlement.Payable))
+	return settlement, nil
+}
+
+// GetSettlement возвращает расчёт с перевозчиком со строками по маршрутам.
+func (s *ticketService) GetSettlement(ctx context.Context, id, role string) (*models.CarrierSettlement, error) {
+	if !settlementRoles[role] {
+		return nil, ErrSettlementForbidden
+	}
+	return s.settlementRepo.FindByID(ctx, id)
+}
+
+// ListSettlements возвращает расчёты с перевозчиками без строк по маршрутам.
+func (s *ticketService) ListSettlements(ctx context.Context, filter *repository.SettlementFilter, role string) ([]*models.CarrierSettlement, error) {
+	if !settlementRoles[role] {
+		return nil, ErrSettlementForbidden
+	}
+	return s.settlementRepo.FindAll(ctx, filter)
+}
+
+// RecalculateSettlement пересчитывает черновик по текущим продажам и ставкам перевозчика.
+func (s *ticketService) RecalculateSettlement(ctx context.Context, id, userID, role string) (*models.CarrierSettlement, error) {
+	if !settlementRoles[role] {
+		return nil, ErrSettlementForbidden
+	}
+	return s.updateSettlement(ctx, id, userID, "recalculate", nil)
+}
+
+// CloseSettlement пересчитывает расчёт и закрывает его: суммы фиксируются, содержимое подписывается
+// хешем SHA-256, закрытие записывается в журнал аудита. Закрыть можно только расчёт за прошедший период.
+func (s *ticketService) CloseSettlement(ctx context.Context, id, userID, role string) (*models.CarrierSettlement, error) {
+	if !settlementRoles[role] {
+		return nil, ErrSettlementForbidden
+	}
+	return s.updateSettlement(ctx, id, userID, "close", func(settlement *models.CarrierSettlement) error {
+		_, to, err := settlementPeriod(settlement.PeriodFrom, settlement.PeriodTo, 0)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC().Truncate(time.Second)
+		if now.Before(to) {
+			return ErrSettlementPeriodOpen
+		}
+		hash, err := settlementHash(settlement, now, userID)
+		if err != nil {
+			return err
+		}
+		settlement.Status = SettlementClosed
+		settlement.ClosedAt = &now
+		settlement.ClosedBy = &userID
+		settlement.ContentHash = &hash
+		return nil
+	})
+}
+
+// updateSettlement пересчитывает черновик под блокировкой перевозчика, применяет finish (закрытие)
+// и сохраняет расчёт, если он всё ещё черновик.
+func (s *ticketService) updateSettlement(ctx context.Context, id, userID, action string, finish func(*models.CarrierSettlement) error) (*models.CarrierSettlement, error) {
+	var settlement *models.CarrierSettlement
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		found, dbErr := s.settlementRepo.FindByID(ctx, id)
+		if dbErr != nil {
+			return dbErr
+		}
+		if found.Status != SettlementDraft {
+			return ErrSettlementClosed
+		}
+		if dbErr = s.settlementRepo.LockCarrier(ctx, found.CarrierID); dbErr != nil {
+			return dbErr
+		}
+		old := settlementAudit(found)
+		carrier, dbErr := s.settlementRepo.FindCarrier(ctx, found.CarrierID)
+		if dbErr != nil {
+			return dbErr
+		}
+		if calcErr := s.calculateSettlement(ctx, found, carrier); calcErr != nil {
+			return calcErr
+		}
+		if finish != nil {
+			if finishErr := finish(found); finishErr != nil {
+				return finishErr
+			}
+		}
+		updated, dbErr := s.settlementRepo.UpdateDraft(ctx, found)
+		if dbErr != nil {
+			return dbErr
+		}
+		if !updated {
+			return ErrSettlementClosed
+		}
+		settlement = found
+		return s.publishAuditEvent(ctx, "settlement", id, action, userID, old, settlementAudit(found))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Carrier settlement updated",
+		zap.String("settlement_id", id),
+		zap.String("action", action),
+		zap.String("status", settlement.Status),
+		zap.Float64("payable", settlement.Payable))
+	return settlement, nil
+}
+
+// DeleteSettlement удаляет черновик расчёта; закрытый расчёт не удаляется.
+func (s *ticketService) DeleteSettlement(ctx context.Context, id, userID, role string) error {
+	if !settlementRoles[role] {
+		return ErrSettlementForbidden
+	}
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		settlement, err := s.settlementRepo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		deleted, err := s.settlementRepo.DeleteDraft(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrSettlementClosed
+		}
+		return s.publishAuditEvent(ctx, "settlement", id, "delete", userID, settlementAudit(settlement), nil)
+	})
+}
+
+// VerifySettlement сверяет закрытый расчёт с хешем закрытия и с текущими данными продаж.
+func (s *ticketService) VerifySettlement(ctx context.Context, id, role string) (*SettlementVerification, error) {
+	if !settlementRoles[role] {
+		return nil, ErrSettlementForbidden
+	}
+	settlement, err := s.settlementRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != SettlementClosed || settlement.ContentHash == nil || settlement.ClosedAt == nil || settlement.ClosedBy == nil {
+		return nil, ErrSettlementNotClosed
+	}
+	hash, err := settlementHash(settlement, *settlement.ClosedAt, *settlement.ClosedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Пересчёт по ставкам на момент закрытия: расхождение показывает только изменения продаж
+	current := *settlement
+	carrier := &repository.CarrierInfo{
+		ContractNumber: settlement.ContractNumber,
+		ID:             settlement.CarrierID,
+		Name:           settlement.CarrierName,
+		INN:            settlement.CarrierINN,
+		CommissionRate: settlement.CommissionRate,
+		ServiceFee:     settlement.ServiceFee,
+	}
+	if err = s.calculateSettlement(ctx, &current, carrier); err != nil {
+		return nil, err
+	}
+	return &SettlementVerification{
+		Current:           &current.SettlementAmounts,
+		SettlementID:      id,
+		ContentHash:       *settlement.ContentHash,
+		PayableDifference: roundMoney(current.Payable - settlement.Payable),
+		HashValid:         hash == *settlement.ContentHash,
+	}, nil
+}
+
+// ExportSettlement выгружает расчёт с перевозчиком в PDF или XLSX. Черновик помечается в файле как проект.
+func (s *ticketService) ExportSettlement(ctx context.Context, id, format, role string) (*ReportFile, error) {
+	if format != ReportFormatPDF && format != ReportFormatXLSX {
+		return nil, ErrInvalidSettlement
+	}
+	settlement, err := s.GetSettlement(ctx, id, role)
+	if err != nil {
+		return nil, err
+	}
+	return renderSettlement(settlement, format, s.cfg.Settlements.FontPath)
+}
+
+// CreateMonthlySettlements формирует черновики расчётов за прошедший календарный месяц для активных
+// перевозчиков, у которых расчёта за этот период ещё нет. Возвращает число созданных расчётов.
+func (s *ticketService) CreateMonthlySettlements(ctx context.Context, now time.Time) (int, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	dateFrom := monthStart.AddDate(0, -1, 0).Format("2006-01-02")
+	dateTo := monthStart.AddDate(0, 0, -1).Format("2006-01-02")
+
+	carriers, err := s.settlementRepo.FindActiveCarriers(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list carriers: %w", err)
+	}
+	created := 0
+	for _, carrier := range carriers {
+		_, err = s.createSettlement(ctx, carrier.ID, dateFrom, dateTo, "system")
+		if errors.Is(err, ErrSettlementOverlap) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to create monthly settlement", zap.String("carrier_id", carrier.ID), zap.Error(err))
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// calculateSettlement заполняет расчёт: реквизиты и ставки перевозчика, строки по маршрутам и итог.
+// Продажи берутся запросом отчёта о продажах с отбором по перевозчику и разрезом по маршрутам —
+// только по тарифу: сборы вокзала и страховка в цене билета перевозчику не причитаются.
+func (s *ticketService) calculateSettlement(ctx context.Context, settlement *models.CarrierSettlement, carrier *repository.CarrierInfo) error {
+	from, to, err := settlementPeriod(settlement.PeriodFrom, settlement.PeriodTo, 0)
+	if err != nil {
+		return err
+	}
+	rows, err := s.reportRepo.SalesReport(ctx, &repository.SalesReportQuery{
+		From:     from,
+		To:       to,
+		Filters:  map[string]string{"carrier": carrier.ID},
+		GroupBy:  []string{"route"},
+		FareOnly: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build settlement: %w", err)
+	}
+
+	settlement.CarrierName = carrier.Name
+	if carrier.LegalName != "" {
+		settlement.CarrierName = carrier.LegalName
+	}
+	settlement.CarrierINN = carrier.INN
+	settlement.ContractNumber = carrier.ContractNumber
+	settlement.CommissionRate = carrier.CommissionRate
+	settlement.ServiceFee = carrier.ServiceFee
+	settlement.CalculatedAt = time.Now()
+	settlement.Lines = make([]models.SettlementLine, 0, len(rows))
+	settlement.SettlementAmounts = models.SettlementAmounts{}
+
+	total := &settlement.SettlementAmounts
+	for _, row := range rows {
+		line := models.SettlementLine{
+			RouteID:           row.RouteID,
+			RouteName:         row.RouteName,
+			SettlementAmounts: settlementAmounts(row, carrier),
+		}
+		settlement.Lines = append(settlement.Lines, line)
+
+		total.TicketsSold += line.TicketsSold
+		total.TicketsAmount = roundMoney(total.TicketsAmount + line.TicketsAmount)
+		total.BaggageSold += line.BaggageSold
+		total.BaggageAmount = roundMoney(total.BaggageAmount + line.BaggageAmount)
+		total.ExchangeFees = roundMoney(total.ExchangeFees + line.ExchangeFees)
+		total.ExchangedAmount = roundMoney(total.ExchangedAmount + line.ExchangedAmount)
+		total.Refunds += line.Refunds
+		total.RefundsAmount = roundMoney(total.RefundsAmount + line.RefundsAmount)
+		total.Penalties = roundMoney(total.Penalties + line.Penalties)
+		total.Collected = roundMoney(total.Collected + line.Collected)
+		total.Commission = roundMoney(total.Commission + line.Commission)
+		total.ServiceFees = roundMoney(total.ServiceFees + line.ServiceFees)
+		total.Payable = roundMoney(total.Payable + line.Payable)
+	}
+	return nil
+}
+
+// settlementAmounts считает суммы маршрута по тарифу перевозчика: собрано — чистая выручка (с удержанными
+// штрафами), вознаграждение — доля собранного, сбор вокзала — за каждый проданный билет (и при обмене),
+// кроме билетов, в цену которых сбор вокзала уже включён составляющей.
+func settlementAmounts(row *repository.SalesReportRow, carrier *repository.CarrierInfo) models.SettlementAmounts {
+	collected := roundMoney(row.NetRevenue)
+	commission := roundMoney(collected * carrier.CommissionRate)
+	serviceFees := roundMoney(float64(row.FeeTickets) * carrier.ServiceFee)
+	return models.SettlementAmounts{
+		TicketsAmount:   roundMoney(row.TicketsAmount),
+		BaggageAmount:   roundMoney(row.BaggageAmount),
+		ExchangeFees:    roundMoney(row.ExchangeFees),
+		ExchangedAmount: roundMoney(row.ExchangedAmount),
+		RefundsAmount:   roundMoney(row.RefundsAmount),
+		Penalties:       roundMoney(row.Penalties),
+		Collected:       collected,
+		Commission:      commission,
+		ServiceFees:     serviceFees,
+		Payable:         roundMoney(collected - commission - serviceFees),
+		TicketsSold:     row.TicketsSold,
+		BaggageSold:     row.BaggageSold,
+		Refunds:         row.Refunds,
+	}
+}
+
+// settlementPeriod переводит даты расчёта в полуинтервал [from, to) в локальном часовом поясе
+// сервиса. maxPeriod > 0 ограничивает длину периода.
+func settlementPeriod(dateFrom, dateTo string, maxPeriod time.Duration) (time.Time, time.Time, error) {
+	from, err := time.ParseInLocation("2006-01-02", dateFrom, time.Local)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidSettlement
+	}
+	to, err := time.ParseInLocation("2006-01-02", dateTo, time.Local)
+	if err != nil || to.Before(from) {
+		return time.Time{}, time.Time{}, ErrInvalidSettlement
+	}
+	to = to.AddDate(0, 0, 1)
+	if maxPeriod > 0 && to.Sub(from) > maxPeriod {
+		return time.Time{}, time.Time{}, ErrInvalidSettlement
+	}
+	return from, to, nil
+}
+
+// settlementHash возвращает SHA-256 (hex) содержимого расчёта с отметкой о закрытии.
+func settlementHash(settlement *models.CarrierSettlement, closedAt time.Time, closedBy string) (string, error) {
+	data, err := json.Marshal(settlementContent{
+		ClosedAt:       closedAt.UTC(),
+		Amounts:        settlement.SettlementAmounts,
+		ID:             settlement.ID,
+		CarrierID:      settlement.CarrierID,
+		CarrierINN:     settlement.CarrierINN,
+		PeriodFrom:     settlement.PeriodFrom,
+		PeriodTo:       settlement.PeriodTo,
+		ClosedBy:       closedBy,
+		Lines:          settlement.Lines,
+		CommissionRate: settlement.CommissionRate,
+		ServiceFee:     settlement.ServiceFee,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal settlement: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// settlementAudit возвращает снимок расчёта для журнала аудита (без строк по маршрутам).
+func settlementAudit(settlement *models.CarrierSettlement) map[string]interface{} {
+	return map[string]interface{}{
+		"carrier_id":      settlement.CarrierID,
+		"period_from":     settlement.PeriodFrom,
+		"period_to":       settlement.PeriodTo,
+		"status":          settlement.Status,
+		"commission_rate": settlement.CommissionRate,
+		"service_fee":     settlement.ServiceFee,
+		"amounts":         settlement.SettlementAmounts,
+		"content_hash":    settlement.ContentHash,
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jung-kurt/gofpdf"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+)
+
+// settlementSheetName — имя листа расчёта с перевозчиком в XLSX.
+const settlementSheetName = "Расчёт"
+
+// settlementHeaders — заголовки колонок строк расчёта в порядке settlementRow.
+var settlementHeaders = []string{
+	"Маршрут", "Продано билетов", "Сумма билетов", "Мест багажа", "Сумма багажа", "Сборы за обмен",
+	"Зачтено при обмене", "Возвратов", "Выплачено по возвратам", "Удержано", "Собрано",
+	"Вознаграждение агента", "Сборы вокзала", "К перечислению",
+}
+
+// renderSettlement выгружает расчёт с перевозчиком в PDF или XLSX.
+func renderSettlement(settlement *models.CarrierSettlement, format, fontPath string) (*ReportFile, error) {
+	name := fmt.Sprintf("settlement_%s_%s_%s.%s", settlement.CarrierINN, settlement.PeriodFrom, settlement.PeriodTo, format)
+
+	var data []byte
+	var err error
+	var contentType string
+	switch format {
+	case ReportFormatXLSX:
+		data, err = writeXLSX(settlementSheetName, settlementHeaders, settlementTable(settlement))
+		contentType = reportContentType(format)
+	case ReportFormatPDF:
+		data, err = writeSettlementPDF(settlement, fontPath)
+		contentType = "application/pdf"
+	default:
+		return nil, ErrInvalidSettlement
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to render settlement: %w", err)
+	}
+	return &ReportFile{Name: name, ContentType: contentType, Data: data}, nil
+}
+
+// settlementTable раскладывает расчёт в таблицу: строки по маршрутам, итог и реквизиты расчёта
+// (после пустой строки).
+func settlementTable(settlement *models.CarrierSettlement) [][]interface{} {
+	rows := make([][]interface{}, 0, len(settlement.Lines)+10)
+	for i := range settlement.Lines {
+		line := &settlement.Lines[i]
+		rows = append(rows, settlementRow(settlementRouteName(line), &line.SettlementAmounts))
+	}
+	rows = append(rows, settlementRow("Итого", &settlement.SettlementAmounts), []interface{}{})
+	for _, field := range settlementDetails(settlement) {
+		rows = append(rows, []interface{}{field[0], field[1]})
+	}
+	return rows
+}
+
+// settlementRow возвращает ячейки строки расчёта в порядке settlementHeaders.
+func settlementRow(name string, a *models.SettlementAmounts) []interface{} {
+	return []interface{}{
+		name, a.TicketsSold, a.TicketsAmount, a.BaggageSold, a.BaggageAmount, a.ExchangeFees,
+		a.ExchangedAmount, a.Refunds, a.RefundsAmount, a.Penalties, a.Collected,
+		a.Commission, a.ServiceFees, a.Payable,
+	}
+}
+
+// settlementRouteName возвращает название маршрута строки, его ID или пометку для продаж без маршрута.
+func settlementRouteName(line *models.SettlementLine) string {
+	switch {
+	case line.RouteName != nil:
+		return *line.RouteName
+	case line.RouteID != nil:
+		return *line.RouteID
+	default:
+		return "Без маршрута"
+	}
+}
+
+// settlementDetails возвращает реквизиты расчёта парами «подпись — значение».
+func settlementDetails(settlement *models.CarrierSettlement) [][2]string {
+	details := [][2]string{
+		{"Перевозчик", settlement.CarrierName},
+		{"ИНН", settlement.CarrierINN},
+	}
+	if settlement.ContractNumber != nil {
+		details = append(details, [2]string{"Договор", *settlement.ContractNumber})
+	}
+	details = append(details,
+		[2]string{"Период", settlement.PeriodFrom + " — " + settlement.PeriodTo},
+		[2]string{"Вознаграждение агента", fmt.Sprintf("%.2f%%", settlement.CommissionRate*100)},
+		[2]string{"Сбор вокзала за билет", fmt.Sprintf("%.2f руб.", settlement.ServiceFee)},
+		[2]string{"Рассчитан", settlement.CalculatedAt.Format("2006-01-02 15:04")},
+	)
+	if settlement.Status != SettlementClosed || settlement.ClosedAt == nil {
+		return append(details, [2]string{"Статус", "ПРОЕКТ — расчёт не закрыт"})
+	}
+	details = append(details,
+		[2]string{"Статус", "Закрыт"},
+		[2]string{"Закрыт", settlement.ClosedAt.Format("2006-01-02 15:04")},
+	)
+	if settlement.ClosedBy != nil {
+		details = append(details, [2]string{"Закрыл", *settlement.ClosedBy})
+	}
+	if settlement.ContentHash != nil {
+		details = append(details, [2]string{"SHA-256", *settlement.ContentHash})
+	}
+	return details
+}
+
+// settlementPDFColumns — колонки таблицы PDF: заголовок и ширина в мм (альбомный A4).
+var settlementPDFColumns = []struct {
+	title string
+	width float64
+}{
+	{"Маршрут", 55}, {"Билетов", 17}, {"Билеты и обмен", 25}, {"Багаж", 22}, {"Возвращено", 22},
+	{"Удержано", 20}, {"Собрано", 25}, {"Вознагр.", 22}, {"Сборы", 20}, {"К перечисл.", 27},
+}
+
+// writeSettlementPDF печатает расчёт: реквизиты, таблицу по маршрутам с итогом и место для подписей.
+func writeSettlementPDF(settlement *models.CarrierSettlement, fontPath string) ([]byte, error) {
+	pdf := gofpdf.New("L", "mm", "A4", filepath.Dir(fontPath))
+	pdf.AddUTF8Font("DejaVu", "", filepath.Base(fontPath))
+	pdf.AddPage()
+	pdf.SetFont("DejaVu", "", 14)
+	pdf.CellFormat(0, 10, "Отчёт агента и расчёт с перевозчиком", "", 1, "C", false, 0, "")
+
+	pdf.SetFont("DejaVu", "", 9)
+	for _, field := range settlementDetails(settlement) {
+		pdf.CellFormat(50, 5, field[0]+":", "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, field[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("DejaVu", "", 8)
+	for _, col := range settlementPDFColumns {
+		pdf.CellFormat(col.width, 7, col.title, "1", 0, "C", false, 0, "")
+	}
+	pdf.Ln(-1)
+	for i := range settlement.Lines {
+		line := &settlement.Lines[i]
+		writeSettlementPDFRow(pdf, settlementRouteName(line), &line.SettlementAmounts)
+	}
+	writeSettlementPDFRow(pdf, "Итого", &settlement.SettlementAmounts)
+
+	pdf.Ln(6)
+	pdf.SetFont("DejaVu", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("К перечислению перевозчику: %.2f руб.", settlement.Payable), "", 1, "L", false, 0, "")
+	pdf.Ln(10)
+	pdf.CellFormat(135, 6, "Агент: ____________________", "", 0, "L", false, 0, "")
+	pdf.CellFormat(0, 6, "Перевозчик: ____________________", "", 1, "L", false, 0, "")
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeSettlementPDFRow печатает строку таблицы расчёта в порядке settlementPDFColumns.
+func writeSettlementPDFRow(pdf *gofpdf.Fpdf, name string, a *models.SettlementAmounts) {
+	cells := []string{
+		name,
+		fmt.Sprint(a.TicketsSold),
+		fmt.Sprintf("%.2f", a.TicketsAmount-a.ExchangedAmount+a.ExchangeFees),
+		fmt.Sprintf("%.2f", a.BaggageAmount),
+		fmt.Sprintf("%.2f", a.RefundsAmount),
+		fmt.Sprintf("%.2f", a.Penalties),
+		fmt.Sprintf("%.2f", a.Collected),
+		fmt.Sprintf("%.2f", a.Commission),
+		fmt.Sprintf("%.2f", a.ServiceFees),
+		fmt.Sprintf("%.2f", a.Payable),
+	}
+	for i, col := range settlementPDFColumns {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(col.width, 6, cells[i], "1", 0, align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
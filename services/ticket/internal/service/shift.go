@@ -0,0 +1,441 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Статусы кассовой смены.
+const (
+	ShiftStatusOpen   = "open"
+	ShiftStatusClosed = "closed"
+)
+
+// Типы движения денег в смене.
+const (
+	ShiftOpSale    = "sale"
+	ShiftOpRefund  = "refund"
+	ShiftOpCashIn  = "cash_in"
+	ShiftOpCashOut = "cash_out"
+)
+
+// paymentMethodCash — способ оплаты, влияющий на наличные в денежном ящике.
+const paymentMethodCash = "cash"
+
+// shiftListLimit — максимальное число смен в списке.
+const shiftListLimit = 200
+
+var (
+	// ErrShiftRequired возвращается, если кассир продаёт или возвращает без открытой смены.
+	ErrShiftRequired = errors.New("cashier has no open shift")
+	// ErrShiftForbidden возвращается при операции со сменой другого кассира без роли старшего смены.
+	ErrShiftForbidden = errors.New("shift belongs to another cashier")
+	// ErrCashierRequired возвращается, если смену открывают без пользователя.
+	ErrCashierRequired = errors.New("cashier user is required to open a shift")
+	// ErrInsufficientCash возвращается, если изъятие превышает наличные в денежном ящике.
+	ErrInsufficientCash = errors.New("not enough cash in the drawer")
+)
+
+// OpenShiftRequest — открытие смены кассира на рабочем месте. KKTSerial — ККТ рабочего места,
+// по которой смена связывается с Z-отчётом.
+type OpenShiftRequest struct {
+	WorkstationID string  `json:"workstation_id" binding:"required,max=50"`
+	KKTSerial     string  `json:"kkt_serial" binding:"max=50"`
+	UserID        string  `json:"-"`
+	OpeningFloat  float64 `json:"opening_float" binding:"gte=0"`
+}
+
+// CashOperationRequest — внесение или изъятие наличных в смене.
+type CashOperationRequest struct {
+	Reason  *string `json:"reason" binding:"omitempty,max=255"`
+	ShiftID string  `json:"-"`
+	UserID  string  `json:"-"`
+	Role    string  `json:"-"`
+	Amount  float64 `json:"amount" binding:"required,gt=0"`
+}
+
+// CloseShiftRequest — закрытие смены с пересчитанной суммой наличных.
+type CloseShiftRequest struct {
+	CountedCash *float64 `json:"counted_cash" binding:"required,gte=0"`
+	ShiftID     string   `json:"-"`
+	UserID      string   `json:"-"`
+	Role        string   `json:"-"`
+}
+
+// PaymentMethodTotals — продажи и возвраты смены по одному способу оплаты.
+type PaymentMethodTotals struct {
+	PaymentMethod string  `json:"payment_method"`
+	SalesCount    int     `json:"sales_count"`
+	SalesAmount   float64 `json:"sales_amount"`
+	RefundsCount  int     `json:"refunds_count"`
+	RefundsAmount float64 `json:"refunds_amount"`
+	Net           float64 `json:"net"`
+}
+
+// ShiftReport — X-отчёт смены (текущие итоги без закрытия) или итоговый отчёт при закрытии.
+// ExpectedCash = разменный фонд + наличные продажи − наличные возвраты + внесения − изъятия.
+type ShiftReport struct {
+	GeneratedAt     time.Time             `json:"generated_at"`
+	Shift           *models.CashierShift  `json:"shift"`
+	ByPaymentMethod []PaymentMethodTotals `json:"by_payment_method"`
+	SalesAmount     float64               `json:"sales_amount"`
+	RefundsAmount   float64               `json:"refunds_amount"`
+	CashIn          float64               `json:"cash_in"`
+	CashOut         float64               `json:"cash_out"`
+	ExpectedCash    float64               `json:"expected_cash"`
+	CashInCount     int                   `json:"cash_in_count"`
+	CashOutCount    int                   `json:"cash_out_count"`
+}
+
+// OpenShift открывает смену кассира. У кассира и у рабочего места может быть только одна открытая смена.
+func (s *ticketService) OpenShift(ctx context.Context, req *OpenShiftRequest) (*models.CashierShift, error) {
+	if req.UserID == "" || req.UserID == "system" {
+		return nil, ErrCashierRequired
+	}
+	open, err := s.shiftRepo.FindOpenByCashier(ctx, req.UserID)
+	if err == nil && open == nil {
+		open, err = s.shiftRepo.FindOpenByWorkstation(ctx, req.WorkstationID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check open shifts: %w", err)
+	}
+	if open != nil {
+		return nil, repository.ErrShiftAlreadyOpen
+	}
+
+	shift := &models.CashierShift{
+		OpenedAt:      time.Now(),
+		CashierID:     req.UserID,
+		WorkstationID: req.WorkstationID,
+		KKTSerial:     req.KKTSerial,
+		Status:        ShiftStatusOpen,
+		OpeningFloat:  roundMoney(req.OpeningFloat),
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.shiftRepo.Create(ctx, shift); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "cashier_shift", shift.ID, "open", req.UserID, nil,
+			map[string]interface{}{"workstation_id": shift.WorkstationID, "kkt_serial": shift.KKTSerial, "opening_float": shift.OpeningFloat})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Cashier shift opened",
+		zap.String("shift_id", shift.ID),
+		zap.String("cashier_id", shift.CashierID),
+		zap.String("workstation_id", shift.WorkstationID),
+		zap.Float64("opening_float", shift.OpeningFloat))
+
+	return shift, nil
+}
+
+func (s *ticketService) GetShift(ctx context.Context, id string) (*models.CashierShift, error) {
+	return s.shiftRepo.FindByID(ctx, id)
+}
+
+// GetCurrentShift возвращает открытую смену пользователя.
+func (s *ticketService) GetCurrentShift(ctx context.Context, userID string) (*models.CashierShift, error) {
+	shift, err := s.shiftRepo.FindOpenByCashier(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if shift == nil {
+		return nil, repository.ErrShiftNotFound
+	}
+	return shift, nil
+}
+
+// ListShifts возвращает смены рабочего места и/или кассира, открытые в дату date (YYYY-MM-DD).
+func (s *ticketService) ListShifts(ctx context.Context, workstationID, cashierID, date string) ([]*models.CashierShift, error) {
+	return s.shiftRepo.FindAll(ctx, workstationID, cashierID, date, shiftListLimit)
+}
+
+// ListShiftOperations возвращает движение денег в смене.
+func (s *ticketService) ListShiftOperations(ctx context.Context, shiftID, userID, role string) ([]*models.ShiftOperation, error) {
+	if _, err := s.accessShift(ctx, shiftID, userID, role); err != nil {
+		return nil, err
+	}
+	return s.shiftRepo.FindOperations(ctx, shiftID)
+}
+
+// CashIn вносит наличные в денежный ящик (размен, подкрепление).
+func (s *ticketService) CashIn(ctx context.Context, req *CashOperationRequest) (*models.ShiftOperation, error) {
+	return s.cashOperation(ctx, req, ShiftOpCashIn)
+}
+
+// CashOut изымает наличные из денежного ящика (инкассация). Изъять больше, чем есть в ящике, нельзя.
+func (s *ticketService) CashOut(ctx context.Context, req *CashOperationRequest) (*models.ShiftOperation, error) {
+	return s.cashOperation(ctx, req, ShiftOpCashOut)
+}
+
+// GetXReport формирует X-отчёт смены: итоги по способам оплаты и ожидаемые наличные без закрытия смены.
+func (s *ticketService) GetXReport(ctx context.Context, shiftID, userID, role string) (*ShiftReport, error) {
+	shift, err := s.accessShift(ctx, shiftID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	return s.shiftReport(ctx, shift)
+}
+
+// CloseShift закрывает смену: сверяет ожидаемые наличные с пересчитанными и фиксирует расхождение.
+// Связь с Z-отчётом ККТ появляется позже, при его формировании (событие fiscal.z_report).
+func (s *ticketService) CloseShift(ctx context.Context, req *CloseShiftRequest) (*ShiftReport, error) {
+	shift, err := s.accessShift(ctx, req.ShiftID, req.UserID, req.Role)
+	if err != nil {
+		return nil, err
+	}
+	if shift.Status != ShiftStatusOpen {
+		return nil, repository.ErrShiftClosed
+	}
+	report, err := s.shiftReport(ctx, shift)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	counted := roundMoney(*req.CountedCash)
+	difference := roundMoney(counted - report.ExpectedCash)
+	shift.Status = ShiftStatusClosed
+	shift.ClosedAt = &now
+	shift.ClosedBy = &req.UserID
+	shift.ExpectedCash = &report.ExpectedCash
+	shift.CountedCash = &counted
+	shift.CashDifference = &difference
+	summary := events.ShiftClosed{
+		ShiftID:        shift.ID,
+		CashierID:      shift.CashierID,
+		WorkstationID:  shift.WorkstationID,
+		KKTSerial:      shift.KKTSerial,
+		ExpectedCash:   report.ExpectedCash,
+		CountedCash:    counted,
+		CashDifference: difference,
+		SalesAmount:    report.SalesAmount,
+		RefundsAmount:  report.RefundsAmount,
+	}
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.shiftRepo.Close(ctx, shift); dbErr != nil {
+			return dbErr
+		}
+		if pubErr := s.publishEvent(ctx, "cashier_shift", shift.ID, summary); pubErr != nil {
+			return pubErr
+		}
+		return s.publishAuditEvent(ctx, "cashier_shift", shift.ID, "close", req.UserID, nil, summary)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logFn := s.logger.Info
+	if difference != 0 {
+		logFn = s.logger.Warn
+	}
+	logFn("Cashier shift closed",
+		zap.String("shift_id", shift.ID),
+		zap.Float64("expected_cash", report.ExpectedCash),
+		zap.Float64("counted_cash", counted),
+		zap.Float64("difference", difference))
+
+	return report, nil
+}
+
+// HandleZReport связывает закрытые смены с Z-отчётом ККТ (событие fiscal.z_report от fiscal-service).
+func (s *ticketService) HandleZReport(ctx context.Context, report *events.ZReport) error {
+	if report.ID == "" || report.KKTSerial == "" {
+		return eventbus.Permanent(fmt.Errorf("invalid fiscal.z_report data: id and kkt_serial are required"))
+	}
+	reportedAt := report.CreatedAt
+	if reportedAt.IsZero() {
+		reportedAt = time.Now()
+	}
+
+	linked, err := s.shiftRepo.LinkZReport(ctx, report.KKTSerial, report.ID, report.ShiftNumber, reportedAt)
+	if err != nil {
+		return fmt.Errorf("failed to link shifts to Z-report: %w", err)
+	}
+	s.logger.Info("Cashier shifts linked to Z-report",
+		zap.String("z_report_id", report.ID),
+		zap.String("kkt_serial", report.KKTSerial),
+		zap.Int64("shifts", linked))
+	return nil
+}
+
+// cashierShift возвращает открытую смену пользователя для продажи или возврата в кассе.
+// Кассир без открытой смены работать не может; операции других ролей и системы (сайт,
+// массовый возврат при отмене рейса) проводятся вне смен, если смена не открыта.
+func (s *ticketService) cashierShift(ctx context.Context, userID, role string) (*models.CashierShift, error) {
+	var shift *models.CashierShift
+	if userID != "" && userID != "system" {
+		var err error
+		if shift, err = s.shiftRepo.FindOpenByCashier(ctx, userID); err != nil {
+			return nil, fmt.Errorf("failed to find cashier shift: %w", err)
+		}
+	}
+	if shift == nil && role == "cashier" {
+		return nil, ErrShiftRequired
+	}
+	return shift, nil
+}
+
+// cashEntry готовит движение денег в смене; вне смены и для нулевых сумм — nil.
+func cashEntry(shift *models.CashierShift, opType, paymentMethod, userID string, amount float64) *models.ShiftOperation {
+	if shift == nil || amount <= 0 {
+		return nil
+	}
+	return &models.ShiftOperation{
+		ShiftID:       shift.ID,
+		Type:          opType,
+		PaymentMethod: paymentMethod,
+		UserID:        userID,
+		Amount:        roundMoney(amount),
+	}
+}
+
+// addCashEntry проводит в кассовой смене движение денег по билету или багажной квитанции
+// (op nil — операция вне смены). Вызывается в транзакции операции.
+func (s *ticketService) addCashEntry(ctx context.Context, op *models.ShiftOperation, entityType, entityID string) error {
+	if op == nil {
+		return nil
+	}
+	op.EntityType = entityType
+	op.EntityID = &entityID
+	if err := s.shiftRepo.AddOperation(ctx, op); err != nil {
+		return fmt.Errorf("failed to save cash operation: %w", err)
+	}
+	return nil
+}
+
+// shiftIDOf возвращает ID смены или nil вне смены.
+func shiftIDOf(shift *models.CashierShift) *string {
+	if shift == nil {
+		return nil
+	}
+	return &shift.ID
+}
+
+// accessShift возвращает смену, если пользователь — её кассир или старший смены.
+func (s *ticketService) accessShift(ctx context.Context, shiftID, userID, role string) (*models.CashierShift, error) {
+	shift, err := s.shiftRepo.FindByID(ctx, shiftID)
+	if err != nil {
+		return nil, err
+	}
+	if shift.CashierID != userID && !supervisorRoles[role] {
+		return nil, ErrShiftForbidden
+	}
+	return shift, nil
+}
+
+// cashOperation проводит внесение или изъятие наличных в открытой смене.
+func (s *ticketService) cashOperation(ctx context.Context, req *CashOperationRequest, opType string) (*models.ShiftOperation, error) {
+	shift, err := s.accessShift(ctx, req.ShiftID, req.UserID, req.Role)
+	if err != nil {
+		return nil, err
+	}
+	if shift.Status != ShiftStatusOpen {
+		return nil, repository.ErrShiftClosed
+	}
+	if opType == ShiftOpCashOut {
+		report, reportErr := s.shiftReport(ctx, shift)
+		if reportErr != nil {
+			return nil, reportErr
+		}
+		if roundMoney(req.Amount) > report.ExpectedCash {
+			return nil, fmt.Errorf("%w: %.2f > %.2f", ErrInsufficientCash, req.Amount, report.ExpectedCash)
+		}
+	}
+
+	op := cashEntry(shift, opType, paymentMethodCash, req.UserID, req.Amount)
+	op.Reason = req.Reason
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.shiftRepo.AddOperation(ctx, op); dbErr != nil {
+			return fmt.Errorf("failed to save cash operation: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "cashier_shift", shift.ID, opType, req.UserID, nil,
+			map[string]interface{}{"amount": op.Amount, "reason": op.Reason})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Cash operation recorded",
+		zap.String("shift_id", shift.ID),
+		zap.String("type", opType),
+		zap.Float64("amount", op.Amount))
+
+	return op, nil
+}
+
+// shiftReport считает итоги смены по журналу движения денег.
+func (s *ticketService) shiftReport(ctx context.Context, shift *models.CashierShift) (*ShiftReport, error) {
+	totals, err := s.shiftRepo.SummarizeOperations(ctx, shift.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize shift operations: %w", err)
+	}
+
+	report := &ShiftReport{GeneratedAt: time.Now(), Shift: shift}
+	byMethod := map[string]*PaymentMethodTotals{}
+	method := func(name string) *PaymentMethodTotals {
+		if byMethod[name] == nil {
+			byMethod[name] = &PaymentMethodTotals{PaymentMethod: name}
+		}
+		return byMethod[name]
+	}
+	cash := shift.OpeningFloat
+	for _, t := range totals {
+		switch t.Type {
+		case ShiftOpSale:
+			m := method(t.PaymentMethod)
+			m.SalesCount += t.Count
+			m.SalesAmount += t.Amount
+			report.SalesAmount += t.Amount
+			if t.PaymentMethod == paymentMethodCash {
+				cash += t.Amount
+			}
+		case ShiftOpRefund:
+			m := method(t.PaymentMethod)
+			m.RefundsCount += t.Count
+			m.RefundsAmount += t.Amount
+			report.RefundsAmount += t.Amount
+			if t.PaymentMethod == paymentMethodCash {
+				cash -= t.Amount
+			}
+		case ShiftOpCashIn:
+			report.CashIn += t.Amount
+			report.CashInCount += t.Count
+			cash += t.Amount
+		case ShiftOpCashOut:
+			report.CashOut += t.Amount
+			report.CashOutCount += t.Count
+			cash -= t.Amount
+		}
+	}
+
+	report.ByPaymentMethod = make([]PaymentMethodTotals, 0, len(byMethod))
+	for _, m := range byMethod {
+		m.SalesAmount = roundMoney(m.SalesAmount)
+		m.RefundsAmount = roundMoney(m.RefundsAmount)
+		m.Net = roundMoney(m.SalesAmount - m.RefundsAmount)
+		report.ByPaymentMethod = append(report.ByPaymentMethod, *m)
+	}
+	sort.Slice(report.ByPaymentMethod, func(i, j int) bool {
+		return report.ByPaymentMethod[i].PaymentMethod < report.ByPaymentMethod[j].PaymentMethod
+	})
+	report.SalesAmount = roundMoney(report.SalesAmount)
+	report.RefundsAmount = roundMoney(report.RefundsAmount)
+	report.CashIn = roundMoney(report.CashIn)
+	report.CashOut = roundMoney(report.CashOut)
+	report.ExpectedCash = roundMoney(cash)
+	return report, nil
+}
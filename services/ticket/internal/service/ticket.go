@@ -3,13 +3,18 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"time"
 
-	"github.com/nats-io/nats.go"
 	"go.uber.org/zap"
 
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/eventbus"
+	"github.com/vokzal-tech/go-common/events"
+	"github.com/vokzal-tech/go-common/outbox"
+	"github.com/vokzal-tech/go-common/pii"
+	"github.com/vokzal-tech/go-common/ticketqr"
+
 	"github.com/vokzal-tech/ticket-service/internal/config"
 	"github.com/vokzal-tech/ticket-service/internal/models"
 	"github.com/vokzal-tech/ticket-service/internal/repository"
@@ -22,28 +27,154 @@ type TicketService interface {
 	GetTicket(ctx context.Context, id string) (*models.Ticket, error)
 	GetTicketByQR(ctx context.Context, qrCode string) (*models.Ticket, error)
 	ListTicketsByTrip(ctx context.Context, tripID string) ([]*models.Ticket, error)
+	LookupTickets(ctx context.Context, req *TicketLookupRequest) ([]*models.Ticket, error)
+	SearchTickets(ctx context.Context, req *TicketSearchRequest) (*TicketSearchResult, error)
+
+	// Кассовые смены
+	OpenShift(ctx context.Context, req *OpenShiftRequest) (*models.CashierShift, error)
+	GetShift(ctx context.Context, id string) (*models.CashierShift, error)
+	GetCurrentShift(ctx context.Context, userID string) (*models.CashierShift, error)
+	ListShifts(ctx context.Context, workstationID, cashierID, date string) ([]*models.CashierShift, error)
+	ListShiftOperations(ctx context.Context, shiftID, userID, role string) ([]*models.ShiftOperation, error)
+	CashIn(ctx context.Context, req *CashOperationRequest) (*models.ShiftOperation, error)
+	CashOut(ctx context.Context, req *CashOperationRequest) (*models.ShiftOperation, error)
+	GetXReport(ctx context.Context, shiftID, userID, role string) (*ShiftReport, error)
+	CloseShift(ctx context.Context, req *CloseShiftRequest) (*ShiftReport, error)
+	HandleZReport(ctx context.Context, report *events.ZReport) error
+
+	// Персональные данные
+	ReencryptPII(ctx context.Context) (int, error)
+	RunRetention(ctx context.Context) (int, error)
+	CreateErasureRequest(ctx context.Context, req *ErasureRequestInput) (*ErasureRequestDetails, error)
+	GetErasureRequest(ctx context.Context, id string) (*ErasureRequestDetails, error)
+	ListAnonymizationLog(ctx context.Context, entityID string) ([]*models.AnonymizationLog, error)
 
 	// Возврат
-	RefundTicket(ctx context.Context, ticketID string, userID string) (*RefundResult, error)
+	RefundTicket(ctx context.Context, req *RefundTicketRequest) (*RefundResult, error)
+
+	// Лист ожидания
+	JoinWaitlist(ctx context.Context, req *JoinWaitlistRequest) (*models.WaitlistEntry, error)
+	GetWaitlistEntry(ctx context.Context, id string) (*models.WaitlistEntry, error)
+	ListWaitlist(ctx context.Context, tripID, status string) ([]*models.WaitlistEntry, error)
+	CancelWaitlistEntry(ctx context.Context, id, userID string) error
+	GetWaitlistOffer(ctx context.Context, token string) (*models.WaitlistEntry, error)
+	GetWaitlistOfferNotice(ctx context.Context, id string) (*WaitlistOfferNotice, error)
+	GetVoucherNotice(ctx context.Context, id string) (*VoucherNotice, error)
+	AcceptWaitlistOffer(ctx context.Context, req *AcceptWaitlistOfferRequest) (*models.Ticket, error)
+	DeclineWaitlistOffer(ctx context.Context, token string) error
+	ExpireWaitlistOffers(ctx context.Context) (int, error)
+	GetWaitlistStats(ctx context.Context, req *WaitlistStatsRequest) (*WaitlistStats, error)
+
+	// Ваучеры и промокоды
+	CreateVoucher(ctx context.Context, req *CreateVoucherRequest) (*models.Voucher, error)
+	GetVoucher(ctx context.Context, id string) (*VoucherDetails, error)
+	ListVouchers(ctx context.Context, filter *repository.VoucherFilter) ([]*models.Voucher, error)
+	DeactivateVoucher(ctx context.Context, id, userID string) error
+	CheckVoucher(ctx context.Context, req *CheckVoucherRequest) (*VoucherQuote, error)
+	IssueCompensationVouchers(ctx context.Context, tripID, userID string) (int, error)
+
+	// Расчёты с перевозчиками
+	CreateSettlement(ctx context.Context, req *CreateSettlementRequest) (*models.CarrierSettlement, error)
+	GetSettlement(ctx context.Context, id, role string) (*models.CarrierSettlement, error)
+	ListSettlements(ctx context.Context, filter *repository.SettlementFilter, role string) ([]*models.CarrierSettlement, error)
+	RecalculateSettlement(ctx context.Context, id, userID, role string) (*models.CarrierSettlement, error)
+	CloseSettlement(ctx context.Context, id, userID, role string) (*models.CarrierSettlement, error)
+	DeleteSettlement(ctx context.Context, id, userID, role string) error
+	VerifySettlement(ctx context.Context, id, role string) (*SettlementVerification, error)
+	ExportSettlement(ctx context.Context, id, format, role string) (*ReportFile, error)
+	CreateMonthlySettlements(ctx context.Context, now time.Time) (int, error)
+
+	// Ключи подписи QR-кодов
+	GetQRKeySet(ctx context.Context) (*ticketqr.KeySet, error)
+	RotateQRKey(ctx context.Context, userID string) (*models.QRSigningKey, error)
+	RotateQRKeyIfDue(ctx context.Context) error
+	ReencryptQRKeys(ctx context.Context) (int, error)
+
+	// Политики возврата
+	CreateRefundPolicy(ctx context.Context, req *CreateRefundPolicyRequest) (*models.RefundPolicy, error)
+	GetRefundPolicy(ctx context.Context, id string) (*models.RefundPolicy, error)
+	ListRefundPolicies(ctx context.Context, activeOnly bool) ([]*models.RefundPolicy, error)
+	DeactivateRefundPolicy(ctx context.Context, id string, userID string) error
+
+	// Сборы в составе цены билета
+	CreateFareComponentRule(ctx context.Context, req *CreateFareComponentRuleRequest) (*models.FareComponentRule, error)
+	GetFareComponentRule(ctx context.Context, id string) (*models.FareComponentRule, error)
+	ListFareComponentRules(ctx context.Context, activeOnly bool) ([]*models.FareComponentRule, error)
+	DeactivateFareComponentRule(ctx context.Context, id, userID string) error
+
+	// Окна продаж и квоты остановок
+	GetSalesSettings(ctx context.Context, tripID string) (*TripSalesStatus, error)
+	UpdateSalesSettings(ctx context.Context, req *UpdateSalesSettingsRequest) (*models.TripSalesSettings, error)
+	DeleteSalesSettings(ctx context.Context, tripID, userID string) error
+
+	// Продажа водителем в пути
+	GetDriverManifest(ctx context.Context, tripID string, vehicleNumber int) (*DriverManifest, error)
+	SyncDriverSales(ctx context.Context, req *SyncDriverSalesRequest) (*SyncDriverSalesResult, error)
+	GetDriverSalesReconciliation(ctx context.Context, tripID string) (*DriverSalesReconciliation, error)
+
+	// Автобусы рейса
+	ListTripVehicles(ctx context.Context, tripID string) ([]*VehicleSeatMap, error)
+
+	// Обмен
+	ExchangeTicket(ctx context.Context, req *ExchangeTicketRequest) (*ExchangeResult, error)
+
+	// Багаж
+	SellBaggage(ctx context.Context, req *SellBaggageRequest) (*models.BaggageTicket, error)
+	GetBaggage(ctx context.Context, id string) (*models.BaggageTicket, error)
+	ListBaggageByTicket(ctx context.Context, ticketID string) ([]*models.BaggageTicket, error)
+	RefundBaggage(ctx context.Context, baggageID, userID, role string) (*RefundResult, error)
 
 	// Посадка
 	StartBoarding(ctx context.Context, tripID string, userID string) error
+	EndBoarding(ctx context.Context, tripID string, userID string) (*BoardingSummary, error)
 	MarkBoarding(ctx context.Context, req *MarkBoardingRequest) error
 	GetBoardingStatus(ctx context.Context, tripID string) (*BoardingStatus, error)
+	GetBoardingManifest(ctx context.Context, tripID string) (*BoardingManifest, error)
+	SyncBoarding(ctx context.Context, req *SyncBoardingRequest) (*SyncBoardingResult, error)
+	CancelBoardingMark(ctx context.Context, req *CancelBoardingRequest) (*models.BoardingCorrection, error)
+	CorrectBoardingMark(ctx context.Context, req *CorrectBoardingRequest) (*models.BoardingCorrection, error)
+	ListBoardingCorrections(ctx context.Context, ticketID string) ([]*models.BoardingCorrection, error)
 
-	// Дашборд
+	// Дашборд и отчёты
 	GetDashboardStats(ctx context.Context, date string) (ticketsSold, ticketsReturned int, revenue float64, err error)
+	GetSalesReport(ctx context.Context, req *SalesReportRequest) (*SalesReport, error)
+	ExportSalesReport(ctx context.Context, req *SalesReportRequest) (*ReportFile, error)
+	CreateSalesReportJob(ctx context.Context, req *SalesReportRequest) (*models.ReportJob, error)
+	ListReportJobs(ctx context.Context, userID, role string) ([]*models.ReportJob, error)
+	GetReportJob(ctx context.Context, id, userID, role string) (*models.ReportJob, error)
+	DownloadReportJob(ctx context.Context, id, userID, role string) (*ReportFile, error)
+	RunReportJobs(ctx context.Context) (int, error)
+
+	// События
+	RegisterEventHandlers(consumer *eventbus.Consumer)
 }
 
 type ticketService struct {
-	ticketRepo   repository.TicketRepository
-	boardingRepo repository.BoardingRepository
-	natsConn     *nats.Conn
-	cfg          *config.Config
-	logger       *zap.Logger
+	ticketRepo        repository.TicketRepository
+	boardingRepo      repository.BoardingRepository
+	baggageRepo       repository.BaggageRepository
+	refundPolicyRepo  repository.RefundPolicyRepository
+	qrKeyRepo         repository.QRKeyRepository
+	retentionRepo     repository.RetentionRepository
+	shiftRepo         repository.ShiftRepository
+	reportRepo        repository.ReportRepository
+	waitlistRepo      repository.WaitlistRepository
+	voucherRepo       repository.VoucherRepository
+	settlementRepo    repository.SettlementRepository
+	fareComponentRepo repository.FareComponentRepository
+	salesRepo         repository.SalesRepository
+	driverSaleRepo    repository.DriverSaleRepository
+	piiKeyring        *pii.Keyring
+	tx                *dbtx.Transactor
+	events            *outbox.Outbox
+	cfg               *config.Config
+	logger            *zap.Logger
 }
 
-// SellTicketRequest — запрос на продажу билета.
+// PassengerCategoryAdult — категория пассажира по умолчанию.
+const PassengerCategoryAdult = "adult"
+
+// SellTicketRequest — запрос на продажу билета. Продажа кассиром проводится в его открытой смене.
 type SellTicketRequest struct {
 	SeatID        *string `json:"seat_id"`
 	PassengerName *string `json:"passenger_name"`
@@ -52,82 +183,217 @@ type SellTicketRequest struct {
 	Email         *string `json:"email"`
 	TripID        string  `json:"trip_id" binding:"required"`
 	PaymentMethod string  `json:"payment_method" binding:"required"`
-	Price         float64 `json:"price" binding:"required,gt=0"`
+	// PassengerCategory — категория пассажира для отчётности; по умолчанию "adult".
+	PassengerCategory string `json:"passenger_category" binding:"omitempty,oneof=adult child student senior benefit"`
+	// VoucherCode — промокод или код ваучера: скидка вычитается из Price, билет продаётся по цене со скидкой.
+	VoucherCode string `json:"voucher_code" binding:"omitempty,max=32"`
+	UserID      string `json:"-"`
+	Role        string `json:"-"`
+	// FromStationID — остановка посадки; по умолчанию — начальная станция маршрута.
+	FromStationID *string `json:"from_station_id"`
+	// ToStationID — остановка высадки (nil — конечная); задаётся при выкупе по листу ожидания на участок.
+	ToStationID *string `json:"-"`
+	// WaitlistEntryID — запись листа ожидания, по предложению которой продаётся удерживаемое место.
+	WaitlistEntryID string `json:"-"`
+	// VehicleNumber — автобус рейса (1 — основной); не задан — первый автобус со свободными местами.
+	VehicleNumber int `json:"vehicle_number" binding:"omitempty,min=1"`
+	// Price — тариф перевозчика; сборы вокзала и страховой сбор начисляются к нему по правилам для рейса.
+	Price float64 `json:"price" binding:"required,gt=0"`
+}
+
+// RefundTicketRequest — запрос на возврат билета.
+// Reason — "voluntary" (по умолчанию) или "medical" с обязательным DocumentRef (номер справки).
+type RefundTicketRequest struct {
+	DocumentRef *string `json:"document_ref"`
+	TicketID    string  `json:"-"`
+	UserID      string  `json:"-"`
+	Role        string  `json:"-"`
+	Reason      string  `json:"reason"`
 }
 
 // RefundResult — результат возврата билета.
+// Штраф удерживается с тарифа (Fare), сервисный сбор (ServiceFee) не возвращается.
+// Components — возврат по составляющим цены билета (RefundAmount каждой); у билетов без составляющих пусто.
 type RefundResult struct {
-	OriginalAmount float64 `json:"original_amount"`
-	Penalty        float64 `json:"penalty"`
-	RefundAmount   float64 `json:"refund_amount"`
+	PolicyID       *string                `json:"policy_id,omitempty"`
+	PolicyVersion  *int                   `json:"policy_version,omitempty"`
+	Reason         string                 `json:"reason"`
+	Components     []models.FareComponent `json:"components,omitempty"`
+	OriginalAmount float64                `json:"original_amount"`
+	Fare           float64                `json:"fare"`
+	ServiceFee     float64                `json:"service_fee"`
+	Penalty        float64                `json:"penalty"`
+	PenaltyRate    float64                `json:"penalty_rate"`
+	RefundAmount   float64                `json:"refund_amount"`
 }
 
-// MarkBoardingRequest — запрос на отметку посадки.
+// MarkBoardingRequest — запрос на отметку посадки. VehicleNumber — автобус рейса, в который садится
+// пассажир: если указан, билет должен быть закреплён за этим автобусом.
 type MarkBoardingRequest struct {
-	TicketID   string `json:"ticket_id" binding:"required"`
-	UserID     string `json:"user_id" binding:"required"`
-	ScanMethod string `json:"scan_method"`
+	TicketID      string `json:"ticket_id" binding:"required"`
+	UserID        string `json:"user_id" binding:"required"`
+	ScanMethod    string `json:"scan_method"`
+	VehicleNumber int    `json:"vehicle_number" binding:"omitempty,min=1"`
 }
 
-// BoardingStatus — статус посадки по рейсу.
+// BoardingStatus — статус посадки по рейсу; Vehicles — посадка по каждому автобусу рейса.
 type BoardingStatus struct {
-	StartedAt      *time.Time `json:"started_at,omitempty"`
-	TripID         string     `json:"trip_id"`
-	TotalTickets   int        `json:"total_tickets"`
-	BoardedCount   int        `json:"boarded_count"`
-	BoardingActive bool       `json:"boarding_active"`
+	StartedAt      *time.Time        `json:"started_at,omitempty"`
+	EndedAt        *time.Time        `json:"ended_at,omitempty"`
+	TripID         string            `json:"trip_id"`
+	Vehicles       []VehicleBoarding `json:"vehicles"`
+	TotalTickets   int               `json:"total_tickets"`
+	BoardedCount   int               `json:"boarded_count"`
+	NoShowCount    int               `json:"no_show_count"`
+	BoardingActive bool              `json:"boarding_active"`
+}
+
+// VehicleBoarding — посадка в автобус рейса: билеты, закреплённые за ним, и отмеченные пассажиры.
+type VehicleBoarding struct {
+	VehicleID    *string `json:"vehicle_id,omitempty"`
+	Platform     *string `json:"platform,omitempty"`
+	PlateNumber  *string `json:"plate_number,omitempty"`
+	Number       int     `json:"number"`
+	TotalTickets int     `json:"total_tickets"`
+	BoardedCount int     `json:"boarded_count"`
+}
+
+// BoardingSummary — итог завершённой посадки (событие boarding.closed).
+type BoardingSummary struct {
+	StartedAt    time.Time      `json:"started_at"`
+	EndedAt      time.Time      `json:"ended_at"`
+	ByScanMethod map[string]int `json:"by_scan_method"`
+	TripID       string         `json:"trip_id"`
+	EndedBy      string         `json:"ended_by"`
+	TotalTickets int            `json:"total_tickets"`
+	BoardedCount int            `json:"boarded_count"`
+	OfflineCount int            `json:"offline_count"`
+	NoShowCount  int            `json:"no_show_count"`
 }
 
 // NewTicketService создаёт сервис билетов.
 func NewTicketService(
 	ticketRepo repository.TicketRepository,
 	boardingRepo repository.BoardingRepository,
-	natsConn *nats.Conn,
+	baggageRepo repository.BaggageRepository,
+	refundPolicyRepo repository.RefundPolicyRepository,
+	qrKeyRepo repository.QRKeyRepository,
+	retentionRepo repository.RetentionRepository,
+	shiftRepo repository.ShiftRepository,
+	reportRepo repository.ReportRepository,
+	waitlistRepo repository.WaitlistRepository,
+	voucherRepo repository.VoucherRepository,
+	settlementRepo repository.SettlementRepository,
+	fareComponentRepo repository.FareComponentRepository,
+	salesRepo repository.SalesRepository,
+	driverSaleRepo repository.DriverSaleRepository,
+	piiKeyring *pii.Keyring,
+	tx *dbtx.Transactor,
+	events *outbox.Outbox,
 	cfg *config.Config,
 	logger *zap.Logger,
 ) TicketService {
 	return &ticketService{
-		ticketRepo:   ticketRepo,
-		boardingRepo: boardingRepo,
-		natsConn:     natsConn,
-		cfg:          cfg,
-		logger:       logger,
+		ticketRepo:        ticketRepo,
+		boardingRepo:      boardingRepo,
+		baggageRepo:       baggageRepo,
+		refundPolicyRepo:  refundPolicyRepo,
+		qrKeyRepo:         qrKeyRepo,
+		retentionRepo:     retentionRepo,
+		shiftRepo:         shiftRepo,
+		reportRepo:        reportRepo,
+		waitlistRepo:      waitlistRepo,
+		voucherRepo:       voucherRepo,
+		settlementRepo:    settlementRepo,
+		fareComponentRepo: fareComponentRepo,
+		salesRepo:         salesRepo,
+		driverSaleRepo:    driverSaleRepo,
+		piiKeyring:        piiKeyring,
+		tx:                tx,
+		events:            events,
+		cfg:               cfg,
+		logger:            logger,
 	}
 }
 
-// SellTicket продаёт билет.
+// SellTicket продаёт билет. Продажа возможна только в окне продаж канала (касса или онлайн)
+// для отправления с остановки посадки и в пределах квоты этой остановки.
 func (s *ticketService) SellTicket(ctx context.Context, req *SellTicketRequest) (*models.Ticket, error) {
-	// Проверить доступность места
+	vehicle, err := s.resolveVehicle(ctx, req.TripID, req.VehicleNumber)
+	if err != nil {
+		return nil, err
+	}
+	// Проверить доступность места в автобусе
 	if req.SeatID != nil {
-		available, err := s.ticketRepo.CheckSeatAvailability(ctx, req.TripID, *req.SeatID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to check seat availability: %w", err)
-		}
-		if !available {
-			return nil, repository.ErrSeatAlreadyTaken
+		if err = s.checkSeat(ctx, req.TripID, vehicle.VehicleID, *req.SeatID, req.WaitlistEntryID); err != nil {
+			return nil, err
 		}
 	}
 
+	shift, err := s.cashierShift(ctx, req.UserID, req.Role)
+	if err != nil {
+		return nil, err
+	}
+	sale, err := s.checkSale(ctx, req.TripID, req.FromStationID, channelOf(shift), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
 	// Создать билет
 	ticket := &models.Ticket{
-		TripID:        req.TripID,
-		SeatID:        req.SeatID,
-		PassengerName: req.PassengerName,
-		PassengerDoc:  req.PassengerDoc,
-		Phone:         req.Phone,
-		Email:         req.Email,
-		Price:         req.Price,
-		Status:        "active",
-		PaymentMethod: req.PaymentMethod,
+		TripID:            req.TripID,
+		SeatID:            req.SeatID,
+		PassengerName:     req.PassengerName,
+		PassengerDoc:      req.PassengerDoc,
+		Phone:             req.Phone,
+		Email:             req.Email,
+		Price:             req.Price,
+		Status:            "active",
+		PaymentMethod:     req.PaymentMethod,
+		ShiftID:           shiftIDOf(shift),
+		FromStationID:     sale.stationID,
+		ToStationID:       req.ToStationID,
+		VehicleID:         vehicle.VehicleID,
+		PassengerCategory: PassengerCategoryAdult,
+	}
+	if req.PassengerCategory != "" {
+		ticket.PassengerCategory = req.PassengerCategory
+	}
+	if req.UserID != "" {
+		ticket.SoldBy = &req.UserID
+	}
+	if err = s.issueQRCode(ctx, ticket); err != nil {
+		return nil, err
 	}
 
-	if err := s.ticketRepo.Create(ctx, ticket); err != nil {
-		return nil, fmt.Errorf("failed to create ticket: %w", err)
+	// Билет, применение ваучера и событие для фискализации фиксируются вместе
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if qErr := s.reserveStopQuota(ctx, req.TripID, sale); qErr != nil {
+			return qErr
+		}
+		if req.VoucherCode != "" {
+			if vErr := s.applyVoucher(ctx, req.VoucherCode, ticket); vErr != nil {
+				return vErr
+			}
+		}
+		if fErr := s.applyFareComponents(ctx, ticket); fErr != nil {
+			return fErr
+		}
+		if dbErr := s.ticketRepo.Create(ctx, ticket); dbErr != nil {
+			return fmt.Errorf("failed to create ticket: %w", dbErr)
+		}
+		if dbErr := s.ticketRepo.ReplaceNameTokens(ctx, ticket); dbErr != nil {
+			return fmt.Errorf("failed to index passenger name: %w", dbErr)
+		}
+		if dbErr := s.addCashEntry(ctx, cashEntry(shift, ShiftOpSale, req.PaymentMethod, req.UserID, ticket.Price), "ticket", ticket.ID); dbErr != nil {
+			return dbErr
+		}
+		return s.publishEvent(ctx, "ticket", ticket.ID, events.TicketSold{Ticket: ticketEvent(ticket)})
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Отправить событие в NATS для фискализации
-	s.publishTicketEvent("ticket.sold", ticket)
-
 	s.logger.Info("Ticket sold",
 		zap.String("ticket_id", ticket.ID),
 		zap.String("trip_id", ticket.TripID),
@@ -140,7 +406,12 @@ func (s *ticketService) GetTicket(ctx context.Context, id string) (*models.Ticke
 	return s.ticketRepo.FindByID(ctx, id)
 }
 
+// GetTicketByQR находит билет по QR-коду. Подписанные коды проверяются по ключам,
+// коды старого формата ("TK...") ищутся напрямую.
 func (s *ticketService) GetTicketByQR(ctx context.Context, qrCode string) (*models.Ticket, error) {
+	if ticketqr.IsSigned(qrCode) {
+		return s.findTicketBySignedQR(ctx, qrCode)
+	}
 	return s.ticketRepo.FindByQRCode(ctx, qrCode)
 }
 
@@ -148,10 +419,10 @@ func (s *ticketService) ListTicketsByTrip(ctx context.Context, tripID string) ([
 	return s.ticketRepo.FindByTripID(ctx, tripID)
 }
 
-// RefundTicket возвращает билет.
-func (s *ticketService) RefundTicket(ctx context.Context, ticketID, userID string) (*RefundResult, error) {
+// RefundTicket возвращает билет по политике возврата, действующей для рейса.
+func (s *ticketService) RefundTicket(ctx context.Context, req *RefundTicketRequest) (*RefundResult, error) {
 	// Получить билет
-	ticket, err := s.ticketRepo.FindByID(ctx, ticketID)
+	ticket, err := s.ticketRepo.FindByID(ctx, req.TicketID)
 	if err != nil {
 		return nil, err
 	}
@@ -161,73 +432,136 @@ func (s *ticketService) RefundTicket(ctx context.Context, ticketID, userID strin
 		return nil, fmt.Errorf("ticket is not active, current status: %s", ticket.Status)
 	}
 
-	// Проверить, не началась ли посадка
-	boardingEvent, err := s.boardingRepo.FindEventByTripID(ctx, ticket.TripID)
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, ticket.TripID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check boarding status: %w", err)
+		s.logger.Error("GetTripRefundInfo failed, refusing refund", zap.Error(err), zap.String("trip_id", ticket.TripID))
+		return nil, fmt.Errorf("failed to get trip info for refund calculation: %w", err)
 	}
-	if boardingEvent != nil {
-		return nil, repository.ErrBoardingAlreadyStarted
+
+	now := time.Now()
+	reason, err := refundReason(trip, req, now)
+	if err != nil {
+		return nil, err
+	}
+	// Неявка, зафиксированная при завершении посадки, считается до фактического отправления
+	if ticket.NoShowAt != nil && reason == RefundReasonVoluntary {
+		reason = RefundReasonNoShow
+	}
+	departed := ticket.NoShowAt != nil || (trip.DepartureTime != nil && !now.Before(*trip.DepartureTime))
+	if err = s.checkRefundable(ctx, ticket.ID, ticket.TripID, reason, departed); err != nil {
+		return nil, err
 	}
 
-	departureTime, err := s.ticketRepo.GetTripDepartureTime(ctx, ticket.TripID)
+	rules, err := s.resolveRefundRules(ctx, trip)
 	if err != nil {
-		s.logger.Error("GetTripDepartureTime failed, refusing refund", zap.Error(err), zap.String("trip_id", ticket.TripID))
-		return nil, fmt.Errorf("failed to get trip departure time for penalty calculation: %w", err)
+		return nil, err
+	}
+	result, err := rules.calculateTicket(ticket, reason, hoursBeforeDeparture(trip, now))
+	if err != nil {
+		return nil, err
+	}
+	shift, err := s.cashierShift(ctx, req.UserID, req.Role)
+	if err != nil {
+		return nil, err
 	}
-	penalty := s.calculateRefundPenalty(ticket.Price, departureTime)
 
 	// Обновить билет
-	now := time.Now()
-	refundAmount := ticket.Price - penalty
 	ticket.Status = "returned"
 	ticket.RefundedAt = &now
-	ticket.RefundAmount = &refundAmount
-	ticket.RefundPenalty = &penalty
+	ticket.RefundAmount = &result.RefundAmount
+	ticket.RefundPenalty = &result.Penalty
+	ticket.RefundServiceFee = &result.ServiceFee
+	ticket.RefundReason = &reason
+	ticket.RefundPolicyID = result.PolicyID
+	ticket.RefundPolicyVersion = result.PolicyVersion
+	if len(result.Components) > 0 {
+		ticket.Components = result.Components
+	}
+
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		// Параллельный возврат или обмен того же билета откатывает эту транзакцию вместе с кассой и событием
+		if dbErr := s.ticketRepo.UpdateIfActive(ctx, ticket); dbErr != nil {
+			return fmt.Errorf("failed to update ticket: %w", dbErr)
+		}
+		if dbErr := s.addCashEntry(ctx, cashEntry(shift, ShiftOpRefund, ticket.PaymentMethod, req.UserID, result.RefundAmount), "ticket", ticket.ID); dbErr != nil {
+			return dbErr
+		}
 
-	if err := s.ticketRepo.Update(ctx, ticket); err != nil {
-		return nil, fmt.Errorf("failed to update ticket: %w", err)
-	}
+		// Отправить событие для фискализации возврата
+		if pubErr := s.publishEvent(ctx, "ticket", ticket.ID, events.TicketReturned{Ticket: ticketEvent(ticket)}); pubErr != nil {
+			return pubErr
+		}
 
-	// Отправить событие для фискализации возврата
-	s.publishTicketEvent("ticket.returned", ticket)
+		// Багаж не может ехать без пассажира — вернуть привязанные квитанции
+		if dbErr := s.refundLinkedBaggage(ctx, ticket.ID, shift, req.UserID, reason, result.PenaltyRate); dbErr != nil {
+			return dbErr
+		}
 
-	// Логировать в audit (через NATS)
-	s.publishAuditEvent(ctx, "ticket", ticketID, "refund", userID, ticket.Price, refundAmount)
+		return s.publishAuditEvent(ctx, "ticket", ticket.ID, "refund", req.UserID, ticket.Price, result)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	s.logger.Info("Ticket refunded",
-		zap.String("ticket_id", ticketID),
+		zap.String("ticket_id", ticket.ID),
+		zap.String("reason", reason),
 		zap.Float64("original", ticket.Price),
-		zap.Float64("penalty", penalty),
-		zap.Float64("refund", refundAmount))
-
-	return &RefundResult{
-		OriginalAmount: ticket.Price,
-		Penalty:        penalty,
-		RefundAmount:   refundAmount,
-	}, nil
+		zap.Float64("service_fee", result.ServiceFee),
+		zap.Float64("penalty", result.Penalty),
+		zap.Float64("refund", result.RefundAmount))
+
+	s.releaseSeat(ctx, ticket.TripID, ticket.SeatID)
+
+	return result, nil
+}
+
+// checkSeat проверяет, что место не занято билетом в автобусе рейса (vehicleID nil — основной)
+// и не удерживается предложением листа ожидания (кроме предложения записи waitlistEntryID).
+func (s *ticketService) checkSeat(ctx context.Context, tripID string, vehicleID *string, seatID, waitlistEntryID string) error {
+	available, err := s.ticketRepo.CheckSeatAvailability(ctx, tripID, vehicleID, seatID)
+	if err != nil {
+		return fmt.Errorf("failed to check seat availability: %w", err)
+	}
+	if !available {
+		return repository.ErrSeatAlreadyTaken
+	}
+	held, err := s.waitlistRepo.IsSeatHeld(ctx, tripID, seatID, waitlistEntryID)
+	if err != nil {
+		return fmt.Errorf("failed to check waitlist seat hold: %w", err)
+	}
+	if held {
+		return ErrSeatHeld
+	}
+	return nil
 }
 
-// Расчёт штрафа за возврат (время отправления рейса из БД trips+schedules).
-func (s *ticketService) calculateRefundPenalty(price float64, departureTime *time.Time) float64 {
-	hoursUntilDeparture := 25.0
-	if departureTime != nil && !departureTime.IsZero() {
-		hoursUntilDeparture = time.Until(*departureTime).Hours()
-		if hoursUntilDeparture < 0 {
-			hoursUntilDeparture = 0
+// checkRefundable проверяет, что билет можно вернуть на текущем этапе рейса: до отправления —
+// только до начала посадки, после отправления — только если пассажир не прошёл посадку.
+// При отмене рейса перевозчиком возврат возможен всегда.
+func (s *ticketService) checkRefundable(ctx context.Context, ticketID, tripID, reason string, departed bool) error {
+	if reason == RefundReasonCarrierCancelled {
+		return nil
+	}
+	if departed {
+		marked, err := s.boardingRepo.CheckIfMarked(ctx, ticketID)
+		if err != nil {
+			return fmt.Errorf("failed to check if marked: %w", err)
 		}
+		if marked {
+			return ErrTicketAlreadyUsed
+		}
+		return nil
 	}
 
-	var penaltyRate float64
-	switch {
-	case hoursUntilDeparture > 24:
-		penaltyRate = s.cfg.Business.RefundPenalty.Over24Hours
-	case hoursUntilDeparture >= 12:
-		penaltyRate = s.cfg.Business.RefundPenalty.Between12_24
-	default:
-		penaltyRate = s.cfg.Business.RefundPenalty.Under12Hours
+	boardingEvent, err := s.boardingRepo.FindEventByTripID(ctx, tripID)
+	if err != nil {
+		return fmt.Errorf("failed to check boarding status: %w", err)
+	}
+	if boardingEvent != nil {
+		return repository.ErrBoardingAlreadyStarted
 	}
-	return price * penaltyRate
+	return nil
 }
 
 // StartBoarding начинает посадку (блокировка возвратов).
@@ -248,19 +582,114 @@ func (s *ticketService) StartBoarding(ctx context.Context, tripID, userID string
 		StartedBy: userID,
 	}
 
-	if err := s.boardingRepo.CreateEvent(ctx, event); err != nil {
-		return fmt.Errorf("failed to create boarding event: %w", err)
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.boardingRepo.CreateEvent(ctx, event); dbErr != nil {
+			return fmt.Errorf("failed to create boarding event: %w", dbErr)
+		}
+		return s.publishEvent(ctx, "trip", tripID, events.BoardingStarted{
+			StartedAt: event.StartedAt,
+			TripID:    tripID,
+			StartedBy: userID,
+		})
+	})
+	if err != nil {
+		return err
 	}
 
-	// Отправить событие в NATS
-	s.publishBoardingEvent("boarding.started", map[string]interface{}{
-		"trip_id":    tripID,
-		"started_at": event.StartedAt,
-		"started_by": userID,
+	s.logger.Info("Boarding started", zap.String("trip_id", tripID), zap.String("user_id", userID))
+
+	return nil
+}
+
+// EndBoarding завершает посадку: замораживает манифест, отмечает неявку по билетам без отметки
+// посадки и публикует итог (boarding.closed), по которому schedule-service переводит рейс в departed.
+func (s *ticketService) EndBoarding(ctx context.Context, tripID, userID string) (*BoardingSummary, error) {
+	event, err := s.boardingRepo.FindEventByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check boarding status: %w", err)
+	}
+	if event == nil {
+		return nil, repository.ErrBoardingNotStarted
+	}
+	if event.EndedAt != nil {
+		return nil, repository.ErrBoardingClosed
+	}
+
+	now := time.Now()
+	event.EndedAt = &now
+	event.EndedBy = &userID
+	summary := &BoardingSummary{
+		StartedAt:    event.StartedAt,
+		EndedAt:      now,
+		ByScanMethod: map[string]int{},
+		TripID:       tripID,
+		EndedBy:      userID,
+	}
+	// Закрытие посадки и событие boarding.closed фиксируются вместе: без события рейс не уйдёт в departed
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		noShows, closeErr := s.boardingRepo.CloseEvent(ctx, event)
+		if closeErr != nil {
+			return fmt.Errorf("failed to close boarding: %w", closeErr)
+		}
+		summary.NoShowCount = int(noShows)
+		if sumErr := s.summarizeBoarding(ctx, summary); sumErr != nil {
+			return sumErr
+		}
+
+		pubErr := s.publishEvent(ctx, "trip", tripID, events.BoardingClosed{
+			StartedAt:    summary.StartedAt,
+			EndedAt:      summary.EndedAt,
+			ByScanMethod: summary.ByScanMethod,
+			TripID:       summary.TripID,
+			EndedBy:      summary.EndedBy,
+			TotalTickets: summary.TotalTickets,
+			BoardedCount: summary.BoardedCount,
+			NoShowCount:  summary.NoShowCount,
+			OfflineCount: summary.OfflineCount,
+		})
+		if pubErr != nil {
+			return pubErr
+		}
+		return s.publishAuditEvent(ctx, "boarding", event.ID, "close", userID, nil, summary)
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	s.logger.Info("Boarding started", zap.String("trip_id", tripID), zap.String("user_id", userID))
+	s.logger.Info("Boarding closed",
+		zap.String("trip_id", tripID),
+		zap.Int("boarded", summary.BoardedCount),
+		zap.Int("no_show", summary.NoShowCount))
 
+	return summary, nil
+}
+
+// summarizeBoarding подсчитывает в итоге посадки проданные билеты и отметки посадки по способам сканирования.
+func (s *ticketService) summarizeBoarding(ctx context.Context, summary *BoardingSummary) error {
+	tickets, err := s.ticketRepo.FindByTripID(ctx, summary.TripID)
+	if err != nil {
+		return fmt.Errorf("failed to list tickets: %w", err)
+	}
+	for _, t := range tickets {
+		if t.Status == "active" {
+			summary.TotalTickets++
+		}
+	}
+	marks, err := s.boardingRepo.FindMarksByTripID(ctx, summary.TripID)
+	if err != nil {
+		return fmt.Errorf("failed to list boarding marks: %w", err)
+	}
+	summary.BoardedCount = len(marks)
+	for _, m := range marks {
+		method := m.ScanMethod
+		if method == "" {
+			method = "unknown"
+		}
+		summary.ByScanMethod[method]++
+		if m.SyncedAt != nil {
+			summary.OfflineCount++
+		}
+	}
 	return nil
 }
 
@@ -276,6 +705,9 @@ func (s *ticketService) MarkBoarding(ctx context.Context, req *MarkBoardingReque
 	if ticket.Status != "active" {
 		return fmt.Errorf("ticket is not active, current status: %s", ticket.Status)
 	}
+	if err = s.checkBoardingVehicle(ctx, ticket, req.VehicleNumber); err != nil {
+		return err
+	}
 
 	// Проверить, началась ли посадка
 	boardingEvent, err := s.boardingRepo.FindEventByTripID(ctx, ticket.TripID)
@@ -285,6 +717,9 @@ func (s *ticketService) MarkBoarding(ctx context.Context, req *MarkBoardingReque
 	if boardingEvent == nil {
 		return repository.ErrBoardingNotStarted
 	}
+	if boardingEvent.EndedAt != nil {
+		return repository.ErrBoardingClosed
+	}
 
 	// Проверить, не отмечен ли уже
 	marked, err := s.boardingRepo.CheckIfMarked(ctx, req.TicketID)
@@ -329,11 +764,12 @@ func (s *ticketService) GetBoardingStatus(ctx context.Context, tripID string) (*
 
 	status := &BoardingStatus{
 		TripID:         tripID,
-		BoardingActive: event != nil,
+		BoardingActive: event != nil && event.EndedAt == nil,
 	}
 
 	if event != nil {
 		status.StartedAt = &event.StartedAt
+		status.EndedAt = event.EndedAt
 	}
 
 	// Получить статистику
@@ -341,7 +777,35 @@ func (s *ticketService) GetBoardingStatus(ctx context.Context, tripID string) (*
 	if err != nil {
 		return nil, err
 	}
-	status.TotalTickets = len(tickets)
+	vehicles, err := s.ticketRepo.GetTripVehicles(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	status.Vehicles = make([]VehicleBoarding, len(vehicles))
+	for i, v := range vehicles {
+		status.Vehicles[i] = VehicleBoarding{
+			VehicleID:   v.VehicleID,
+			Platform:    v.Platform,
+			PlateNumber: v.PlateNumber,
+			Number:      v.Number,
+		}
+	}
+	index := vehicleIndex(vehicles)
+	ticketVehicle := make(map[string]int, len(tickets))
+	// Возвращённые, обменянные и отменённые билеты места в автобусе не занимают
+	for _, t := range tickets {
+		if t.Status != "active" {
+			continue
+		}
+		status.TotalTickets++
+		if t.NoShowAt != nil {
+			status.NoShowCount++
+		}
+		if i, ok := index[vehicleKey(t.VehicleID)]; ok {
+			status.Vehicles[i].TotalTickets++
+			ticketVehicle[t.ID] = i
+		}
+	}
 
 	if event != nil {
 		marks, err := s.boardingRepo.FindMarksByTripID(ctx, tripID)
@@ -349,54 +813,107 @@ func (s *ticketService) GetBoardingStatus(ctx context.Context, tripID string) (*
 			return nil, err
 		}
 		status.BoardedCount = len(marks)
+		for _, m := range marks {
+			if i, ok := ticketVehicle[m.TicketID]; ok {
+				status.Vehicles[i].BoardedCount++
+			}
+		}
 	}
 
 	return status, nil
 }
 
-// publishTicketEvent публикует событие по билету в NATS.
-func (s *ticketService) publishTicketEvent(subject string, ticket *models.Ticket) {
-	data, err := json.Marshal(ticket)
-	if err != nil {
-		s.logger.Error("Failed to marshal ticket event", zap.Error(err))
-		return
-	}
-
-	if err := s.natsConn.Publish(subject, data); err != nil {
-		s.logger.Error("Failed to publish ticket event", zap.Error(err), zap.String("subject", subject))
-	}
+// RegisterEventHandlers регистрирует в durable-консьюмере обработчики Z-отчётов ККТ, изменений рейсов
+// (лист ожидания, компенсации за отмену) и отчётов сервисов об обезличивании ПД.
+func (s *ticketService) RegisterEventHandlers(consumer *eventbus.Consumer) {
+	consumer.Handle(events.TypeZReport, events.Handler(s.HandleZReport))
+	consumer.Handle(events.TypeTripUpdated, events.Handler(s.HandleTripUpdated))
+	consumer.Handle(events.TypeTripStatusChanged, events.Handler(s.HandleTripStatusChanged))
+	consumer.Handle(events.TypePIIAnonymized, events.Handler(s.HandleAnonymized))
 }
 
-func (s *ticketService) publishBoardingEvent(subject string, data map[string]interface{}) {
-	payload, err := json.Marshal(data)
-	if err != nil {
-		s.logger.Error("Failed to marshal boarding event", zap.Error(err))
-		return
+// HandleTripStatusChanged обрабатывает отправление или отмену рейса (событие trip.status_changed
+// от schedule-service): закрывает лист ожидания, по отменённому рейсу выпускает пассажирам
+// компенсационные ваучеры.
+func (s *ticketService) HandleTripStatusChanged(ctx context.Context, event *events.TripStatusChanged) error {
+	if !tripStatusClosed(event.Status) {
+		return nil
 	}
-
-	if err := s.natsConn.Publish(subject, payload); err != nil {
-		s.logger.Error("Failed to publish boarding event", zap.Error(err), zap.String("subject", subject))
+	if err := s.closeTripWaitlist(ctx, event); err != nil {
+		return err
+	}
+	if event.Status == tripStatusCancelled {
+		if _, err := s.IssueCompensationVouchers(ctx, event.ID, "system"); err != nil {
+			return fmt.Errorf("failed to issue compensation vouchers: %w", err)
+		}
 	}
+	return nil
 }
 
-func (s *ticketService) publishAuditEvent(_ context.Context, entityType, entityID, action, userID string, oldValue, newValue interface{}) {
-	event := map[string]interface{}{
-		"entity_type": entityType,
-		"entity_id":   entityID,
-		"action":      action,
-		"user_id":     userID,
-		"old_value":   oldValue,
-		"new_value":   newValue,
-		"timestamp":   time.Now(),
-	}
+// publishEvent записывает событие в outbox. События одного агрегата aggregateType/aggregateID
+// доставляются в порядке записи.
+func (s *ticketService) publishEvent(ctx context.Context, aggregateType, aggregateID string, event events.Event) error {
+	return s.events.Publish(ctx, aggregateType, aggregateID, event)
+}
 
-	data, err := json.Marshal(event)
-	if err != nil {
-		s.logger.Error("Failed to marshal audit event", zap.Error(err))
-		return
+// publishAuditEvent записывает в outbox событие audit.log об изменении сущности.
+func (s *ticketService) publishAuditEvent(ctx context.Context, entityType, entityID, action, userID string, oldValue, newValue interface{}) error {
+	return s.events.Publish(ctx, entityType, entityID, events.AuditLog{
+		Timestamp:  time.Now(),
+		OldValue:   oldValue,
+		NewValue:   newValue,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		UserID:     userID,
+	})
+}
+
+// ticketEvent возвращает билет для событий: ПД пассажира, штрихкод и QR-код в другие сервисы не уходят.
+func ticketEvent(t *models.Ticket) events.Ticket {
+	return events.Ticket{
+		CreatedAt:           t.CreatedAt,
+		UpdatedAt:           t.UpdatedAt,
+		Components:          fareComponentEvents(t.Components),
+		ShiftID:             t.ShiftID,
+		SoldBy:              t.SoldBy,
+		SeatID:              t.SeatID,
+		RefundedAt:          t.RefundedAt,
+		RefundAmount:        t.RefundAmount,
+		RefundPenalty:       t.RefundPenalty,
+		RefundServiceFee:    t.RefundServiceFee,
+		RefundReason:        t.RefundReason,
+		RefundPolicyID:      t.RefundPolicyID,
+		RefundPolicyVersion: t.RefundPolicyVersion,
+		ExchangedFromID:     t.ExchangedFromID,
+		ExchangedToID:       t.ExchangedToID,
+		ExchangeFee:         t.ExchangeFee,
+		NoShowAt:            t.NoShowAt,
+		VoucherID:           t.VoucherID,
+		DiscountAmount:      t.DiscountAmount,
+		ID:                  t.ID,
+		TripID:              t.TripID,
+		Status:              t.Status,
+		PaymentMethod:       t.PaymentMethod,
+		PassengerCategory:   t.PassengerCategory,
+		Price:               t.Price,
 	}
+}
 
-	if err := s.natsConn.Publish("audit.log", data); err != nil {
-		s.logger.Error("Failed to publish audit event", zap.Error(err))
+// fareComponentEvents возвращает составляющие цены билета для событий.
+func fareComponentEvents(components []models.FareComponent) []events.FareComponent {
+	if len(components) == 0 {
+		return nil
+	}
+	result := make([]events.FareComponent, len(components))
+	for i, c := range components {
+		result[i] = events.FareComponent{
+			RefundAmount: c.RefundAmount,
+			Kind:         c.Kind,
+			Name:         c.Name,
+			VAT:          c.VAT,
+			Amount:       c.Amount,
+		}
 	}
+	return result
 }
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+var (
+	// ErrVehicleNotOnTrip возвращается, когда на рейсе нет автобуса с указанным номером.
+	ErrVehicleNotOnTrip = errors.New("vehicle is not on the trip")
+	// ErrWrongVehicle возвращается при посадке пассажира не в тот автобус, за которым закреплён билет.
+	ErrWrongVehicle = errors.New("ticket is for another vehicle of the trip")
+)
+
+// VehicleSeatMap — автобус рейса со своей схемой мест: занятые билетами места и число свободных
+// (nil — автобус не назначен, отрицательное — продано больше вместимости).
+type VehicleSeatMap struct {
+	Free          *int     `json:"free,omitempty"`
+	OccupiedSeats []string `json:"occupied_seats"`
+	repository.TripVehicle
+}
+
+// ListTripVehicles возвращает автобусы рейса (основной — номер 1) с занятыми местами каждого.
+func (s *ticketService) ListTripVehicles(ctx context.Context, tripID string) ([]*VehicleSeatMap, error) {
+	vehicles, err := s.ticketRepo.GetTripVehicles(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+
+	maps := make([]*VehicleSeatMap, 0, len(vehicles))
+	for _, v := range vehicles {
+		seatMap := &VehicleSeatMap{TripVehicle: *v, OccupiedSeats: []string{}}
+		if v.Capacity != nil {
+			free := *v.Capacity - v.Sold
+			seatMap.Free = &free
+		}
+		for _, t := range vehicleTickets(tickets, v.VehicleID) {
+			if t.Status == "active" && t.SeatID != nil {
+				seatMap.OccupiedSeats = append(seatMap.OccupiedSeats, *t.SeatID)
+			}
+		}
+		maps = append(maps, seatMap)
+	}
+	return maps, nil
+}
+
+// tripVehicle возвращает автобус рейса по номеру; 0 — основной автобус.
+func (s *ticketService) tripVehicle(ctx context.Context, tripID string, number int) (*repository.TripVehicle, error) {
+	vehicles, err := s.ticketRepo.GetTripVehicles(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if number == 0 {
+		number = 1
+	}
+	for _, v := range vehicles {
+		if v.Number == number {
+			return v, nil
+		}
+	}
+	return nil, ErrVehicleNotOnTrip
+}
+
+// resolveVehicle выбирает автобус для продажи: с указанным номером, а без номера — первый по порядку
+// автобус со свободными местами, так что продажа переходит на добавленный автобус, когда основной заполнен.
+// Если свободных мест нет ни в одном, билет закрепляется за основным автобусом.
+func (s *ticketService) resolveVehicle(ctx context.Context, tripID string, number int) (*repository.TripVehicle, error) {
+	if number > 0 {
+		return s.tripVehicle(ctx, tripID, number)
+	}
+	vehicles, err := s.ticketRepo.GetTripVehicles(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range vehicles {
+		if v.Capacity == nil || v.Sold < *v.Capacity {
+			return v, nil
+		}
+	}
+	return vehicles[0], nil
+}
+
+// checkBoardingVehicle проверяет, что пассажир садится в автобус, за которым закреплён билет;
+// number 0 — автобус не указан, проверка не выполняется.
+func (s *ticketService) checkBoardingVehicle(ctx context.Context, ticket *models.Ticket, number int) error {
+	if number == 0 {
+		return nil
+	}
+	vehicle, err := s.tripVehicle(ctx, ticket.TripID, number)
+	if err != nil {
+		return err
+	}
+	if !sameVehicle(vehicle.VehicleID, ticket.VehicleID) {
+		return ErrWrongVehicle
+	}
+	return nil
+}
+
+// vehicleIndex возвращает позиции автобусов в списке рейса по ID добавленного автобуса
+// (основной — по пустой строке).
+func vehicleIndex(vehicles []*repository.TripVehicle) map[string]int {
+	index := make(map[string]int, len(vehicles))
+	for i, v := range vehicles {
+		index[vehicleKey(v.VehicleID)] = i
+	}
+	return index
+}
+
+// vehicleTickets возвращает билеты, закреплённые за автобусом рейса (vehicleID nil — основной).
+func vehicleTickets(tickets []*models.Ticket, vehicleID *string) []*models.Ticket {
+	result := make([]*models.Ticket, 0, len(tickets))
+	for _, t := range tickets {
+		if sameVehicle(t.VehicleID, vehicleID) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func sameVehicle(a, b *string) bool {
+	return vehicleKey(a) == vehicleKey(b)
+}
+
+func vehicleKey(vehicleID *string) string {
+	if vehicleID == nil {
+		return ""
+	}
+	return *vehicleID
+}
@@ -0,0 +1,440 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Виды ваучеров и типы скидки.
+const (
+	VoucherKindPromo        = "promo"
+	VoucherKindCompensation = "compensation"
+
+	DiscountTypeFixed   = "fixed"
+	DiscountTypePercent = "percent"
+)
+
+// voucherCodeAlphabet — символы генерируемых кодов: без 0/O и 1/I, которые путают при вводе.
+const (
+	voucherCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	voucherCodeLength   = 10
+)
+
+var (
+	// ErrInvalidVoucher возвращается при некорректных параметрах ваучера.
+	ErrInvalidVoucher = errors.New("invalid voucher")
+	// ErrVoucherNotApplicable возвращается, когда ваучер нельзя применить к билету: выключен,
+	// не действует, исчерпан лимит применений или не подходят маршрут и дата рейса.
+	ErrVoucherNotApplicable = errors.New("voucher is not applicable")
+	// ErrTripNotCancelled возвращается при выпуске компенсационных ваучеров по неотменённому рейсу.
+	ErrTripNotCancelled = errors.New("trip is not cancelled")
+	// ErrVoucherClosed возвращается при запросе данных для отправки ваучера, который выключен,
+	// истёк или уже использован.
+	ErrVoucherClosed = errors.New("voucher is disabled, expired or used")
+
+	voucherCodePattern = regexp.MustCompile(`^[A-Z0-9-]{4,32}$`)
+)
+
+// VoucherNotice — данные для отправки компенсационного ваучера пассажиру (внутреннее API для notify-service):
+// код на скидку Amount до ValidUntil и контакт из билета; оба контакта пусты, если ПД билета обезличены.
+type VoucherNotice struct {
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	Phone      *string    `json:"phone,omitempty"`
+	Email      *string    `json:"email,omitempty"`
+	Code       string     `json:"code"`
+	TripID     string     `json:"trip_id"`
+	Amount     float64    `json:"amount"`
+}
+
+// CreateVoucherRequest — запрос на создание промокода кампании. Пустой Code — код генерируется.
+// TravelDateFrom и TravelDateTo — даты отправления рейсов в формате YYYY-MM-DD.
+type CreateVoucherRequest struct {
+	ValidFrom      *time.Time `json:"valid_from"`
+	ValidUntil     *time.Time `json:"valid_until"`
+	MaxDiscount    *float64   `json:"max_discount" binding:"omitempty,gt=0"`
+	UsageLimit     *int       `json:"usage_limit" binding:"omitempty,gt=0"`
+	TravelDateFrom *string    `json:"travel_date_from"`
+	TravelDateTo   *string    `json:"travel_date_to"`
+	Description    *string    `json:"description" binding:"omitempty,max=255"`
+	Code           string     `json:"code" binding:"omitempty,max=32"`
+	DiscountType   string     `json:"discount_type" binding:"required,oneof=fixed percent"`
+	UserID         string     `json:"-"`
+	RouteIDs       []string   `json:"route_ids"`
+	Value          float64    `json:"value" binding:"required,gt=0"`
+}
+
+// CheckVoucherRequest — проверка кода при оформлении заказа: скидка на билет рейса TripID по тарифу Price.
+type CheckVoucherRequest struct {
+	Code   string  `json:"code" binding:"required"`
+	TripID string  `json:"trip_id" binding:"required"`
+	Price  float64 `json:"price" binding:"required,gt=0"`
+}
+
+// VoucherQuote — цена билета с учётом ваучера: Amount = Price − Discount. Проверка код не расходует.
+type VoucherQuote struct {
+	VoucherID string  `json:"voucher_id"`
+	Code      string  `json:"code"`
+	Price     float64 `json:"price"`
+	Discount  float64 `json:"discount"`
+	Amount    float64 `json:"amount"`
+}
+
+// VoucherDetails — ваучер и проданные по нему билеты.
+type VoucherDetails struct {
+	*models.Voucher
+	Usage *repository.VoucherUsage `json:"usage"`
+}
+
+// CreateVoucher создаёт промокод маркетинговой кампании.
+func (s *ticketService) CreateVoucher(ctx context.Context, req *CreateVoucherRequest) (*models.Voucher, error) {
+	if err := validateVoucherRequest(req); err != nil {
+		return nil, err
+	}
+	code := normalizeVoucherCode(req.Code)
+	if code == "" {
+		var err error
+		if code, err = newVoucherCode(); err != nil {
+			return nil, err
+		}
+	}
+
+	voucher := &models.Voucher{
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
+		MaxDiscount:    req.MaxDiscount,
+		UsageLimit:     req.UsageLimit,
+		TravelDateFrom: req.TravelDateFrom,
+		TravelDateTo:   req.TravelDateTo,
+		Description:    req.Description,
+		Code:           code,
+		Kind:           VoucherKindPromo,
+		DiscountType:   req.DiscountType,
+		CreatedBy:      req.UserID,
+		RouteIDs:       req.RouteIDs,
+		Value:          req.Value,
+		IsActive:       true,
+	}
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.voucherRepo.Create(ctx, voucher); dbErr != nil {
+			return fmt.Errorf("failed to create voucher: %w", dbErr)
+		}
+		return s.publishAuditEvent(ctx, "voucher", voucher.ID, "create", req.UserID, nil, voucher)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Voucher created",
+		zap.String("voucher_id", voucher.ID),
+		zap.String("code", voucher.Code),
+		zap.String("discount_type", voucher.DiscountType),
+		zap.Float64("value", voucher.Value))
+
+	return voucher, nil
+}
+
+// GetVoucher возвращает ваучер с числом проданных по нему билетов и суммой скидок.
+func (s *ticketService) GetVoucher(ctx context.Context, id string) (*VoucherDetails, error) {
+	voucher, err := s.voucherRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := s.voucherRepo.Usage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get voucher usage: %w", err)
+	}
+	return &VoucherDetails{Voucher: voucher, Usage: usage}, nil
+}
+
+// GetVoucherNotice возвращает notify-service данные для отправки компенсационного ваучера: код, сумму,
+// срок и контакт пассажира из билета, за который выпущен ваучер. Выключенный, истёкший или
+// использованный ваучер не выдаётся.
+func (s *ticketService) GetVoucherNotice(ctx context.Context, id string) (*VoucherNotice, error) {
+	voucher, err := s.voucherRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if voucher.Kind != VoucherKindCompensation || voucher.SourceTicketID == nil {
+		return nil, repository.ErrVoucherNotFound
+	}
+	if !voucher.IsActive || (voucher.ValidUntil != nil && !time.Now().Before(*voucher.ValidUntil)) ||
+		(voucher.UsageLimit != nil && voucher.UsedCount >= *voucher.UsageLimit) {
+		return nil, ErrVoucherClosed
+	}
+	ticket, err := s.ticketRepo.FindByID(ctx, *voucher.SourceTicketID)
+	if err != nil {
+		return nil, err
+	}
+	return &VoucherNotice{
+		ValidUntil: voucher.ValidUntil,
+		Phone:      ticket.Phone,
+		Email:      ticket.Email,
+		Code:       voucher.Code,
+		TripID:     ticket.TripID,
+		Amount:     voucher.Value,
+	}, nil
+}
+
+func (s *ticketService) ListVouchers(ctx context.Context, filter *repository.VoucherFilter) ([]*models.Voucher, error) {
+	return s.voucherRepo.FindAll(ctx, filter)
+}
+
+// DeactivateVoucher выключает ваучер; проданные по нему билеты не меняются.
+func (s *ticketService) DeactivateVoucher(ctx context.Context, id, userID string) error {
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.voucherRepo.Deactivate(ctx, id); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "voucher", id, "deactivate", userID, true, false)
+	})
+}
+
+// CheckVoucher рассчитывает скидку по коду для оформления заказа, не расходуя его:
+// применение засчитывается при продаже билета.
+func (s *ticketService) CheckVoucher(ctx context.Context, req *CheckVoucherRequest) (*VoucherQuote, error) {
+	voucher, err := s.voucherRepo.FindByCode(ctx, normalizeVoucherCode(req.Code))
+	if err != nil {
+		return nil, err
+	}
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, req.TripID)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkVoucher(voucher, trip, time.Now()); err != nil {
+		return nil, err
+	}
+
+	discount := voucherDiscount(voucher, req.Price)
+	return &VoucherQuote{
+		VoucherID: voucher.ID,
+		Code:      voucher.Code,
+		Price:     req.Price,
+		Discount:  discount,
+		Amount:    roundMoney(req.Price - discount),
+	}, nil
+}
+
+// applyVoucher применяет ваучер к продаваемому билету: вычитает скидку из цены и засчитывает
+// применение. Вызывается в транзакции продажи — при её откате применение не засчитывается.
+func (s *ticketService) applyVoucher(ctx context.Context, code string, ticket *models.Ticket) error {
+	voucher, err := s.voucherRepo.FindByCode(ctx, normalizeVoucherCode(code))
+	if err != nil {
+		return err
+	}
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, ticket.TripID)
+	if err != nil {
+		return fmt.Errorf("failed to get trip info for voucher: %w", err)
+	}
+	if err = checkVoucher(voucher, trip, time.Now()); err != nil {
+		return err
+	}
+	redeemed, err := s.voucherRepo.Redeem(ctx, voucher.ID)
+	if err != nil {
+		return fmt.Errorf("failed to redeem voucher: %w", err)
+	}
+	if !redeemed {
+		return fmt.Errorf("%w: usage limit reached", ErrVoucherNotApplicable)
+	}
+
+	discount := voucherDiscount(voucher, ticket.Price)
+	ticket.Price = roundMoney(ticket.Price - discount)
+	ticket.VoucherID = &voucher.ID
+	ticket.DiscountAmount = &discount
+	return nil
+}
+
+// IssueCompensationVouchers выпускает компенсационные ваучеры пассажирам рейса, отменённого перевозчиком:
+// на каждый действующий билет и на билет, уже возвращённый из-за отмены. Ваучер на билет выпускается
+// один раз, повторный вызов выпускает только недостающие. Возвращает число выпущенных ваучеров.
+func (s *ticketService) IssueCompensationVouchers(ctx context.Context, tripID, userID string) (int, error) {
+	rate := s.cfg.Vouchers.CompensationRate
+	if rate <= 0 {
+		return 0, nil
+	}
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, tripID)
+	if err != nil {
+		return 0, err
+	}
+	if trip.Status != tripStatusCancelled {
+		return 0, ErrTripNotCancelled
+	}
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list trip tickets: %w", err)
+	}
+
+	validUntil := time.Now().Add(s.cfg.Vouchers.CompensationValidity)
+	issued := 0
+	for _, ticket := range tickets {
+		amount := roundMoney(ticket.Price * rate)
+		if !compensationEligible(ticket) || amount <= 0 {
+			continue
+		}
+		created, issueErr := s.issueCompensationVoucher(ctx, ticket, amount, validUntil, userID)
+		if issueErr != nil {
+			return issued, fmt.Errorf("failed to issue voucher for ticket %s: %w", ticket.ID, issueErr)
+		}
+		if created {
+			issued++
+		}
+	}
+
+	if issued > 0 {
+		s.logger.Info("Compensation vouchers issued",
+			zap.String("trip_id", tripID),
+			zap.Int("vouchers", issued))
+	}
+	return issued, nil
+}
+
+// issueCompensationVoucher выпускает ваучер на билет и, если у пассажира есть телефон или email,
+// команду notify-service отправить ему код.
+func (s *ticketService) issueCompensationVoucher(ctx context.Context, ticket *models.Ticket, amount float64, validUntil time.Time, userID string) (bool, error) {
+	code, err := newVoucherCode()
+	if err != nil {
+		return false, err
+	}
+	limit := 1
+	description := "Компенсация за отмену рейса"
+	voucher := &models.Voucher{
+		ValidUntil:     &validUntil,
+		UsageLimit:     &limit,
+		SourceTicketID: &ticket.ID,
+		Description:    &description,
+		Code:           code,
+		Kind:           VoucherKindCompensation,
+		DiscountType:   DiscountTypeFixed,
+		CreatedBy:      userID,
+		Value:          amount,
+		IsActive:       true,
+	}
+
+	var created bool
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		var dbErr error
+		if created, dbErr = s.voucherRepo.CreateForTicket(ctx, voucher); dbErr != nil || !created {
+			return dbErr
+		}
+		if ticket.Phone != nil || ticket.Email != nil {
+			// Контакт и код notify-service запрашивает по ID ваучера (GetVoucherNotice)
+			pubErr := s.publishEvent(ctx, "voucher", voucher.ID, events.VoucherIssued{VoucherID: voucher.ID})
+			if pubErr != nil {
+				return pubErr
+			}
+		}
+		return s.publishAuditEvent(ctx, "voucher", voucher.ID, "issue_compensation", userID, nil, voucher)
+	})
+	return created, err
+}
+
+// compensationEligible сообщает, положена ли пассажиру компенсация за отмену рейса: билет действует
+// или возвращён из-за отмены (обменянный и добровольно возвращённый билет компенсации не дают).
+func compensationEligible(ticket *models.Ticket) bool {
+	switch ticket.Status {
+	case "active":
+		return true
+	case "returned":
+		return ticket.RefundReason != nil && *ticket.RefundReason == RefundReasonCarrierCancelled
+	}
+	return false
+}
+
+// checkVoucher проверяет, что ваучер можно применить к билету на рейс trip в момент now.
+// Лимит применений окончательно проверяется при списании (Redeem).
+func checkVoucher(voucher *models.Voucher, trip *repository.TripRefundInfo, now time.Time) error {
+	switch {
+	case !voucher.IsActive:
+		return fmt.Errorf("%w: voucher is disabled", ErrVoucherNotApplicable)
+	case voucher.ValidFrom != nil && now.Before(*voucher.ValidFrom):
+		return fmt.Errorf("%w: voucher is not valid yet", ErrVoucherNotApplicable)
+	case voucher.ValidUntil != nil && !now.Before(*voucher.ValidUntil):
+		return fmt.Errorf("%w: voucher has expired", ErrVoucherNotApplicable)
+	case voucher.UsageLimit != nil && voucher.UsedCount >= *voucher.UsageLimit:
+		return fmt.Errorf("%w: usage limit reached", ErrVoucherNotApplicable)
+	case len(voucher.RouteIDs) > 0 && !slices.Contains(voucher.RouteIDs, trip.RouteID):
+		return fmt.Errorf("%w: voucher is not valid for this route", ErrVoucherNotApplicable)
+	}
+
+	if voucher.TravelDateFrom == nil && voucher.TravelDateTo == nil {
+		return nil
+	}
+	if trip.DepartureTime == nil {
+		return fmt.Errorf("%w: trip departure date is unknown", ErrVoucherNotApplicable)
+	}
+	date := trip.DepartureTime.Format("2006-01-02")
+	if (voucher.TravelDateFrom != nil && date < *voucher.TravelDateFrom) ||
+		(voucher.TravelDateTo != nil && date > *voucher.TravelDateTo) {
+		return fmt.Errorf("%w: voucher is not valid for trip date %s", ErrVoucherNotApplicable, date)
+	}
+	return nil
+}
+
+// voucherDiscount рассчитывает скидку по ваучеру с тарифа price; скидка не превышает тариф.
+func voucherDiscount(voucher *models.Voucher, price float64) float64 {
+	discount := voucher.Value
+	if voucher.DiscountType == DiscountTypePercent {
+		discount = price * voucher.Value / 100
+		if voucher.MaxDiscount != nil && discount > *voucher.MaxDiscount {
+			discount = *voucher.MaxDiscount
+		}
+	}
+	return roundMoney(math.Min(discount, price))
+}
+
+func validateVoucherRequest(req *CreateVoucherRequest) error {
+	if req.DiscountType == DiscountTypePercent && req.Value > 100 {
+		return fmt.Errorf("%w: percent discount must not exceed 100", ErrInvalidVoucher)
+	}
+	if req.DiscountType == DiscountTypeFixed && req.MaxDiscount != nil {
+		return fmt.Errorf("%w: max_discount applies to percent discounts only", ErrInvalidVoucher)
+	}
+	if code := normalizeVoucherCode(req.Code); code != "" && !voucherCodePattern.MatchString(code) {
+		return fmt.Errorf("%w: code must be 4-32 latin letters, digits or dashes", ErrInvalidVoucher)
+	}
+	if req.ValidFrom != nil && req.ValidUntil != nil && !req.ValidUntil.After(*req.ValidFrom) {
+		return fmt.Errorf("%w: valid_until must be after valid_from", ErrInvalidVoucher)
+	}
+	for _, date := range []*string{req.TravelDateFrom, req.TravelDateTo} {
+		if date == nil {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			return fmt.Errorf("%w: travel dates must be YYYY-MM-DD", ErrInvalidVoucher)
+		}
+	}
+	if req.TravelDateFrom != nil && req.TravelDateTo != nil && *req.TravelDateTo < *req.TravelDateFrom {
+		return fmt.Errorf("%w: travel_date_to is before travel_date_from", ErrInvalidVoucher)
+	}
+	return nil
+}
+
+// normalizeVoucherCode приводит код к виду, в котором он хранится: без пробелов по краям, заглавными.
+func normalizeVoucherCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newVoucherCode генерирует случайный код ваучера.
+func newVoucherCode() (string, error) {
+	buf := make([]byte, voucherCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate voucher code: %w", err)
+	}
+	for i, b := range buf {
+		buf[i] = voucherCodeAlphabet[int(b)%len(voucherCodeAlphabet)]
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,662 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/vokzal-tech/go-common/events"
+
+	"github.com/vokzal-tech/ticket-service/internal/models"
+	"github.com/vokzal-tech/ticket-service/internal/repository"
+)
+
+// Статусы записей листа ожидания.
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistConverted = "converted"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled" //nolint:misspell // статус записи, как у рейса
+)
+
+// Каналы отправки предложения места.
+const (
+	WaitlistChannelSMS   = "sms"
+	WaitlistChannelEmail = "email"
+)
+
+// waitlistBatch — сколько истёкших предложений обрабатывается за один проход.
+const waitlistBatch = 100
+
+var (
+	// ErrWaitlistContact возвращается, если не указан контакт для выбранного канала.
+	ErrWaitlistContact = errors.New("phone is required for sms channel, email for email channel")
+	// ErrInvalidSegment возвращается, если участок задан одной станцией или станции не идут
+	// по маршруту рейса в направлении движения.
+	ErrInvalidSegment = errors.New("from_station_id and to_station_id must be set together and follow the trip route")
+	// ErrTripClosed возвращается, если рейс отправился или отменён и места на него не продаются.
+	ErrTripClosed = errors.New("trip is no longer on sale")
+	// ErrOfferExpired возвращается при выкупе или отказе по истёкшему или уже использованному предложению.
+	ErrOfferExpired = errors.New("waitlist offer has expired or is no longer valid")
+	// ErrWaitlistEntryClosed возвращается при отмене записи, которая уже закрыта.
+	ErrWaitlistEntryClosed = errors.New("waitlist entry is already closed")
+	// ErrSeatHeld возвращается при продаже места, удерживаемого предложением листа ожидания.
+	ErrSeatHeld = errors.New("seat is held for a waitlist offer")
+)
+
+// JoinWaitlistRequest — постановка в лист ожидания рейса (и участка, если заданы обе станции).
+// Предложение места отправляется по Channel: "sms" на Phone или "email" на Email.
+type JoinWaitlistRequest struct {
+	FromStationID *string `json:"from_station_id"`
+	ToStationID   *string `json:"to_station_id"`
+	PassengerName *string `json:"passenger_name"`
+	Phone         *string `json:"phone"`
+	Email         *string `json:"email"`
+	TripID        string  `json:"trip_id" binding:"required"`
+	Channel       string  `json:"channel" binding:"required,oneof=sms email"`
+	// PassengerCategory — категория пассажира будущего билета; по умолчанию "adult".
+	PassengerCategory string  `json:"passenger_category" binding:"omitempty,oneof=adult child student senior benefit"`
+	UserID            string  `json:"-"`
+	Price             float64 `json:"price" binding:"required,gt=0"`
+}
+
+// AcceptWaitlistOfferRequest — выкуп билета по предложению из листа ожидания.
+type AcceptWaitlistOfferRequest struct {
+	PassengerDoc  *string `json:"passenger_doc"`
+	Token         string  `json:"-"`
+	UserID        string  `json:"-"`
+	Role          string  `json:"-"`
+	PaymentMethod string  `json:"payment_method" binding:"required"`
+}
+
+// WaitlistOfferNotice — данные для отправки предложения места пассажиру (внутреннее API для notify-service):
+// контакт для Channel, ссылка на выкуп, действующая до ExpiresAt, и цена.
+type WaitlistOfferNotice struct {
+	ExpiresAt     time.Time  `json:"expires_at"`
+	DepartureTime *time.Time `json:"departure_time,omitempty"`
+	Phone         *string    `json:"phone,omitempty"`
+	Email         *string    `json:"email,omitempty"`
+	TripID        string     `json:"trip_id"`
+	Channel       string     `json:"channel"`
+	OfferURL      string     `json:"offer_url"`
+	Price         float64    `json:"price"`
+}
+
+// WaitlistStatsRequest — статистика листа ожидания по рейсу и/или за период постановки в очередь
+// (даты YYYY-MM-DD включительно).
+type WaitlistStatsRequest struct {
+	TripID   string `form:"trip_id"`
+	DateFrom string `form:"date_from"`
+	DateTo   string `form:"date_to"`
+}
+
+// WaitlistStats — статистика листа ожидания. ConversionRate — доля выкупленных среди получивших предложение.
+type WaitlistStats struct {
+	ByStatus       []*repository.WaitlistStatusCount `json:"by_status"`
+	Entries        int                               `json:"entries"`
+	Offers         int                               `json:"offers"`
+	Converted      int                               `json:"converted"`
+	Expired        int                               `json:"expired"`
+	ConversionRate float64                           `json:"conversion_rate"`
+}
+
+// JoinWaitlist ставит пассажира в очередь рейса. Если на рейсе уже есть свободные места,
+// предложение отправляется сразу.
+func (s *ticketService) JoinWaitlist(ctx context.Context, req *JoinWaitlistRequest) (*models.WaitlistEntry, error) {
+	if (req.FromStationID == nil) != (req.ToStationID == nil) ||
+		(req.FromStationID != nil && *req.FromStationID == *req.ToStationID) {
+		return nil, ErrInvalidSegment
+	}
+	contact := req.Phone
+	if req.Channel == WaitlistChannelEmail {
+		contact = req.Email
+	}
+	if contact == nil || strings.TrimSpace(*contact) == "" {
+		return nil, ErrWaitlistContact
+	}
+
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, req.TripID)
+	if err != nil {
+		return nil, err
+	}
+	if tripClosedForSale(trip, time.Now()) {
+		return nil, ErrTripClosed
+	}
+	// Участок сверяется с маршрутом: по нему считаются свободные места на перегонах
+	if req.FromStationID != nil {
+		info, infoErr := s.salesRepo.GetTripSalesInfo(ctx, req.TripID)
+		if infoErr != nil {
+			return nil, infoErr
+		}
+		from, to := routeStopIndex(info.Stops, *req.FromStationID), routeStopIndex(info.Stops, *req.ToStationID)
+		if from < 0 || to <= from {
+			return nil, ErrInvalidSegment
+		}
+	}
+
+	entry := &models.WaitlistEntry{
+		FromStationID:     req.FromStationID,
+		ToStationID:       req.ToStationID,
+		PassengerName:     req.PassengerName,
+		Phone:             req.Phone,
+		Email:             req.Email,
+		TripID:            req.TripID,
+		Channel:           req.Channel,
+		PassengerCategory: PassengerCategoryAdult,
+		Status:            WaitlistWaiting,
+		Price:             req.Price,
+	}
+	if req.PassengerCategory != "" {
+		entry.PassengerCategory = req.PassengerCategory
+	}
+	if req.UserID != "" && req.UserID != "system" {
+		entry.CreatedBy = &req.UserID
+	}
+	if err = s.waitlistRepo.Create(ctx, entry); err != nil {
+		return nil, fmt.Errorf("failed to create waitlist entry: %w", err)
+	}
+
+	s.logger.Info("Passenger joined waitlist",
+		zap.String("entry_id", entry.ID),
+		zap.String("trip_id", entry.TripID))
+
+	// Места могли освободиться, пока очередь была пуста
+	offered, err := s.offerFreeSeats(ctx, entry.TripID)
+	if err != nil {
+		s.logger.Error("Failed to offer free seats to waitlist", zap.String("trip_id", entry.TripID), zap.Error(err))
+	}
+	if offered > 0 {
+		return s.waitlistRepo.FindByID(ctx, entry.ID)
+	}
+	return entry, nil
+}
+
+func (s *ticketService) GetWaitlistEntry(ctx context.Context, id string) (*models.WaitlistEntry, error) {
+	return s.waitlistRepo.FindByID(ctx, id)
+}
+
+// GetWaitlistOfferNotice возвращает notify-service данные для отправки предложения: контакт пассажира
+// для канала записи, ссылку на выкуп и условия. Доступно только по действующему предложению.
+func (s *ticketService) GetWaitlistOfferNotice(ctx context.Context, id string) (*WaitlistOfferNotice, error) {
+	entry, err := s.waitlistRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !offerActive(entry, time.Now()) || entry.OfferToken == nil {
+		return nil, ErrOfferExpired
+	}
+	trip, err := s.ticketRepo.GetTripRefundInfo(ctx, entry.TripID)
+	if err != nil {
+		return nil, err
+	}
+
+	notice := &WaitlistOfferNotice{
+		ExpiresAt:     *entry.OfferExpiresAt,
+		DepartureTime: trip.DepartureTime,
+		TripID:        entry.TripID,
+		Channel:       entry.Channel,
+		OfferURL:      strings.ReplaceAll(s.cfg.Waitlist.OfferURL, "{token}", *entry.OfferToken),
+		Price:         entry.Price,
+	}
+	// Только контакт выбранного канала: второй notify-service не нужен
+	switch entry.Channel {
+	case "sms":
+		notice.Phone = entry.Phone
+	case "email":
+		notice.Email = entry.Email
+	}
+	return notice, nil
+}
+
+// ListWaitlist возвращает очередь рейса; пустой status — записи во всех статусах.
+func (s *ticketService) ListWaitlist(ctx context.Context, tripID, status string) ([]*models.WaitlistEntry, error) {
+	return s.waitlistRepo.FindByTrip(ctx, tripID, status)
+}
+
+// GetWaitlistOffer возвращает запись по коду предложения из ссылки на выкуп.
+func (s *ticketService) GetWaitlistOffer(ctx context.Context, token string) (*models.WaitlistEntry, error) {
+	return s.waitlistRepo.FindByOfferToken(ctx, token)
+}
+
+// AcceptWaitlistOffer продаёт билет по действующему предложению: на удержанное место, на участок
+// и по цене из листа ожидания. Билет и закрытие записи фиксируются вместе.
+func (s *ticketService) AcceptWaitlistOffer(ctx context.Context, req *AcceptWaitlistOfferRequest) (*models.Ticket, error) {
+	entry, err := s.waitlistRepo.FindByOfferToken(ctx, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if !offerActive(entry, time.Now()) {
+		return nil, ErrOfferExpired
+	}
+
+	sale := &SellTicketRequest{
+		SeatID:            entry.SeatID,
+		PassengerName:     entry.PassengerName,
+		PassengerDoc:      req.PassengerDoc,
+		Phone:             entry.Phone,
+		Email:             entry.Email,
+		TripID:            entry.TripID,
+		PaymentMethod:     req.PaymentMethod,
+		PassengerCategory: entry.PassengerCategory,
+		UserID:            req.UserID,
+		Role:              req.Role,
+		WaitlistEntryID:   entry.ID,
+		FromStationID:     entry.FromStationID,
+		ToStationID:       entry.ToStationID,
+		Price:             entry.Price,
+	}
+	var ticket *models.Ticket
+	err = s.tx.Run(ctx, func(ctx context.Context) error {
+		sold, sellErr := s.SellTicket(ctx, sale)
+		if sellErr != nil {
+			return sellErr
+		}
+		ticket = sold
+		entry.TicketID = &sold.ID
+		closeWaitlistEntry(entry, WaitlistConverted)
+		updated, dbErr := s.waitlistRepo.UpdateIfStatus(ctx, entry, WaitlistOffered)
+		if dbErr != nil {
+			return fmt.Errorf("failed to update waitlist entry: %w", dbErr)
+		}
+		if !updated {
+			return ErrOfferExpired
+		}
+		return s.publishAuditEvent(ctx, "waitlist", entry.ID, "convert", req.UserID, nil,
+			map[string]interface{}{"trip_id": entry.TripID, "ticket_id": sold.ID})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Waitlist offer converted",
+		zap.String("entry_id", entry.ID),
+		zap.String("ticket_id", ticket.ID))
+
+	return ticket, nil
+}
+
+// DeclineWaitlistOffer закрывает предложение по отказу пассажира и передаёт место следующему в очереди.
+func (s *ticketService) DeclineWaitlistOffer(ctx context.Context, token string) error {
+	entry, err := s.waitlistRepo.FindByOfferToken(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !offerActive(entry, time.Now()) {
+		return ErrOfferExpired
+	}
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		return s.closeOpenEntry(ctx, entry, WaitlistCancelled, ErrOfferExpired)
+	})
+}
+
+// CancelWaitlistEntry снимает пассажира с листа ожидания; удерживаемое им место передаётся следующему.
+func (s *ticketService) CancelWaitlistEntry(ctx context.Context, id, userID string) error {
+	entry, err := s.waitlistRepo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if entry.Status != WaitlistWaiting && entry.Status != WaitlistOffered {
+		return ErrWaitlistEntryClosed
+	}
+	return s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.closeOpenEntry(ctx, entry, WaitlistCancelled, ErrWaitlistEntryClosed); dbErr != nil {
+			return dbErr
+		}
+		return s.publishAuditEvent(ctx, "waitlist", entry.ID, "cancel", userID, nil,
+			map[string]interface{}{"trip_id": entry.TripID})
+	})
+}
+
+// ExpireWaitlistOffers закрывает истёкшие предложения и передаёт удержанные места следующим в очереди.
+// Возвращает число закрытых предложений.
+func (s *ticketService) ExpireWaitlistOffers(ctx context.Context) (int, error) {
+	entries, err := s.waitlistRepo.FindExpiredOffers(ctx, time.Now(), waitlistBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find expired waitlist offers: %w", err)
+	}
+	expired := 0
+	for _, entry := range entries {
+		err = s.tx.Run(ctx, func(ctx context.Context) error {
+			return s.closeOpenEntry(ctx, entry, WaitlistExpired, nil)
+		})
+		if err != nil {
+			return expired, fmt.Errorf("failed to expire waitlist offer %s: %w", entry.ID, err)
+		}
+		expired++
+	}
+	if expired > 0 {
+		s.logger.Info("Waitlist offers expired", zap.Int("offers", expired))
+	}
+	return expired, nil
+}
+
+// GetWaitlistStats считает записи листа ожидания по статусам и конверсию предложений в билеты.
+func (s *ticketService) GetWaitlistStats(ctx context.Context, req *WaitlistStatsRequest) (*WaitlistStats, error) {
+	filter := &repository.WaitlistStatsFilter{TripID: req.TripID}
+	if req.DateFrom != "" {
+		from, err := time.ParseInLocation(time.DateOnly, req.DateFrom, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date_from must be YYYY-MM-DD", ErrInvalidReport)
+		}
+		filter.From = &from
+	}
+	if req.DateTo != "" {
+		to, err := time.ParseInLocation(time.DateOnly, req.DateTo, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: date_to must be YYYY-MM-DD", ErrInvalidReport)
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.To = &to
+	}
+
+	rows, err := s.waitlistRepo.Stats(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count waitlist entries: %w", err)
+	}
+	stats := &WaitlistStats{ByStatus: rows}
+	for _, row := range rows {
+		stats.Entries += row.Count
+		stats.Offers += row.Offered
+		switch row.Status {
+		case WaitlistConverted:
+			stats.Converted = row.Count
+		case WaitlistExpired:
+			stats.Expired = row.Count
+		}
+	}
+	if stats.Offers > 0 {
+		stats.ConversionRate = math.Round(float64(stats.Converted)/float64(stats.Offers)*10000) / 10000
+	}
+	return stats, nil
+}
+
+// HandleTripUpdated предлагает очереди места, появившиеся после замены автобуса на более вместительный
+// (событие trip.updated от schedule-service).
+func (s *ticketService) HandleTripUpdated(ctx context.Context, event *events.TripUpdated) error {
+	_, err := s.offerFreeSeats(ctx, event.ID)
+	return err
+}
+
+// closeTripWaitlist закрывает лист ожидания отправившегося или отменённого рейса.
+func (s *ticketService) closeTripWaitlist(ctx context.Context, event *events.TripStatusChanged) error {
+	entries, err := s.waitlistRepo.FindOpenByTrip(ctx, event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to list trip waitlist: %w", err)
+	}
+	for _, entry := range entries {
+		from := entry.Status
+		closeWaitlistEntry(entry, WaitlistExpired)
+		if _, err = s.waitlistRepo.UpdateIfStatus(ctx, entry, from); err != nil {
+			return fmt.Errorf("failed to close waitlist entry %s: %w", entry.ID, err)
+		}
+	}
+	if len(entries) > 0 {
+		s.logger.Info("Trip waitlist closed",
+			zap.String("trip_id", event.ID),
+			zap.String("trip_status", event.Status),
+			zap.Int("entries", len(entries)))
+	}
+	return nil
+}
+
+// cancelWaitlistByContact снимает с листа ожидания записи пассажира по слепому индексу телефона
+// или email (запрос на удаление ПД). Возвращает число снятых записей.
+func (s *ticketService) cancelWaitlistByContact(ctx context.Context, field repository.PIIField, index string) (int, error) {
+	if field != repository.PIIFieldPhone && field != repository.PIIFieldEmail {
+		return 0, nil
+	}
+	entries, err := s.waitlistRepo.FindOpenByContact(ctx, field, index)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find passenger waitlist entries: %w", err)
+	}
+	for _, entry := range entries {
+		err = s.tx.Run(ctx, func(ctx context.Context) error {
+			return s.closeOpenEntry(ctx, entry, WaitlistCancelled, nil)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to cancel waitlist entry %s: %w", entry.ID, err)
+		}
+	}
+	return len(entries), nil
+}
+
+// releaseSeat передаёт очереди рейса место, освобождённое возвратом или обменом билета. Ошибка
+// не отменяет операцию с билетом: свободные места предлагаются и при следующем изменении рейса.
+func (s *ticketService) releaseSeat(ctx context.Context, tripID string, seatID *string) {
+	if _, err := s.offerFreeSeats(ctx, tripID, seatID); err != nil {
+		s.logger.Error("Failed to offer released seat to waitlist",
+			zap.String("trip_id", tripID),
+			zap.Error(err))
+	}
+}
+
+// closeOpenEntry закрывает запись в статусе status и, если у неё было предложение, передаёт место
+// следующему в очереди. Вызывается в транзакции. Если запись уже закрыта другим запросом, возвращает
+// raced (nil — пропустить запись).
+func (s *ticketService) closeOpenEntry(ctx context.Context, entry *models.WaitlistEntry, status string, raced error) error {
+	from := entry.Status
+	closeWaitlistEntry(entry, status)
+	updated, err := s.waitlistRepo.UpdateIfStatus(ctx, entry, from)
+	if err != nil {
+		return fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+	if !updated {
+		return raced
+	}
+	if from != WaitlistOffered {
+		return nil
+	}
+	_, err = s.offerFreeSeats(ctx, entry.TripID, entry.SeatID)
+	return err
+}
+
+// offerFreeSeats предлагает свободные места рейса очереди. freed — места, только что освобождённые
+// возвратом, обменом или закрытым предложением: они предлагаются с номером места, остальные — без.
+// Если вместимость рейса известна, место предлагается записи, на всех перегонах участка которой
+// проданных билетов и действующих предложений меньше вместимости (без остановок маршрута — в пределах
+// мест рейса). Возвращает число отправленных предложений.
+func (s *ticketService) offerFreeSeats(ctx context.Context, tripID string, freed ...*string) (int, error) {
+	offered := 0
+	err := s.tx.Run(ctx, func(ctx context.Context) error {
+		if dbErr := s.waitlistRepo.LockTrip(ctx, tripID); dbErr != nil {
+			return fmt.Errorf("failed to lock trip waitlist: %w", dbErr)
+		}
+		trip, dbErr := s.ticketRepo.GetTripRefundInfo(ctx, tripID)
+		if dbErr != nil {
+			return fmt.Errorf("failed to get trip info: %w", dbErr)
+		}
+		if tripClosedForSale(trip, time.Now()) {
+			return nil
+		}
+		seats, dbErr := s.waitlistRepo.GetTripSeats(ctx, tripID)
+		if dbErr != nil {
+			return fmt.Errorf("failed to count trip seats: %w", dbErr)
+		}
+		limit := len(freed)
+		var stops []repository.TripStop
+		var load []int
+		if seats.Capacity != nil {
+			limit = *seats.Capacity - seats.Sold - seats.Held
+			info, infoErr := s.salesRepo.GetTripSalesInfo(ctx, tripID)
+			if infoErr != nil {
+				return infoErr
+			}
+			if len(info.Stops) > 1 {
+				stops = info.Stops
+				if load, dbErr = s.legOccupancy(ctx, tripID, stops); dbErr != nil {
+					return dbErr
+				}
+			}
+		}
+		// По перегонам предложений может быть больше, чем мест: записи на разные участки едут на одном месте
+		for ; load != nil || offered < limit; offered++ {
+			var segments []repository.WaitlistSegment
+			if load != nil {
+				if segments = freeSegments(stops, load, *seats.Capacity); len(segments) == 0 {
+					break
+				}
+			}
+			var seatID *string
+			if offered < len(freed) {
+				seatID = freed[offered]
+			}
+			entry, offerErr := s.offerSeat(ctx, tripID, trip, seatID, segments)
+			if offerErr != nil {
+				return offerErr
+			}
+			if entry == nil {
+				break
+			}
+			if load != nil {
+				from, to := stopSegment(stops, entry.FromStationID, entry.ToStationID)
+				for i := from; i < to; i++ {
+					load[i]++
+				}
+			}
+		}
+		return nil
+	})
+	return offered, err
+}
+
+// legOccupancy считает на каждом перегоне маршрута действующие билеты и предложения листа ожидания.
+func (s *ticketService) legOccupancy(ctx context.Context, tripID string, stops []repository.TripStop) ([]int, error) {
+	tickets, err := s.ticketRepo.FindByTripID(ctx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tickets: %w", err)
+	}
+	held, err := s.waitlistRepo.FindByTrip(ctx, tripID, WaitlistOffered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list waitlist offers: %w", err)
+	}
+	legs := legLoads(stops, tickets, nil, nil)
+	load := make([]int, len(legs))
+	for i := range legs {
+		load[i] = legs[i].Passengers
+	}
+	for _, entry := range held {
+		from, to := stopSegment(stops, entry.FromStationID, entry.ToStationID)
+		for i := from; i < to; i++ {
+			load[i]++
+		}
+	}
+	return load, nil
+}
+
+// freeSegments возвращает участки маршрута из подряд идущих перегонов, загрузка которых меньше вместимости.
+func freeSegments(stops []repository.TripStop, load []int, capacity int) []repository.WaitlistSegment {
+	var segments []repository.WaitlistSegment
+	for start := 0; start < len(load); {
+		if load[start] >= capacity {
+			start++
+			continue
+		}
+		end := start
+		for end < len(load) && load[end] < capacity {
+			end++
+		}
+		seg := repository.WaitlistSegment{FromStart: start == 0, ToEnd: end == len(load)}
+		for i := start; i < end; i++ {
+			seg.FromStationIDs = append(seg.FromStationIDs, stops[i].StationID)
+			seg.ToStationIDs = append(seg.ToStationIDs, stops[i+1].StationID)
+		}
+		segments = append(segments, seg)
+		start = end
+	}
+	return segments
+}
+
+// offerSeat предлагает место первому в очереди рейса, чей участок лежит в одном из segments, и отправляет
+// ему ссылку на выкуп (waitlist.offered). Предложение действует waitlist.offer_ttl, но не дольше,
+// чем до отправления. Возвращает nil, если подходящих записей в очереди нет.
+func (s *ticketService) offerSeat(
+	ctx context.Context,
+	tripID string,
+	trip *repository.TripRefundInfo,
+	seatID *string,
+	segments []repository.WaitlistSegment,
+) (*models.WaitlistEntry, error) {
+	entry, err := s.waitlistRepo.NextWaiting(ctx, tripID, segments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find next waitlist entry: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+	token, err := newOfferToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expires := now.Add(s.cfg.Waitlist.OfferTTL)
+	if trip.DepartureTime != nil && trip.DepartureTime.Before(expires) {
+		expires = *trip.DepartureTime
+	}
+	entry.Status = WaitlistOffered
+	entry.OfferedAt = &now
+	entry.OfferExpiresAt = &expires
+	entry.OfferToken = &token
+	entry.SeatID = seatID
+	if _, err = s.waitlistRepo.UpdateIfStatus(ctx, entry, WaitlistWaiting); err != nil {
+		return nil, fmt.Errorf("failed to update waitlist entry: %w", err)
+	}
+	// Контакт и ссылку на выкуп notify-service запрашивает по ID записи (GetWaitlistOfferNotice)
+	err = s.publishEvent(ctx, "waitlist", entry.ID, events.WaitlistOffered{EntryID: entry.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("Waitlist seat offered",
+		zap.String("entry_id", entry.ID),
+		zap.String("trip_id", entry.TripID),
+		zap.Time("expires_at", expires))
+	return entry, nil
+}
+
+// closeWaitlistEntry переводит запись в итоговый статус и удаляет ПД: после выкупа они есть в билете,
+// в остальных случаях больше не нужны.
+func closeWaitlistEntry(entry *models.WaitlistEntry, status string) {
+	now := time.Now()
+	entry.Status = status
+	entry.ClosedAt = &now
+	entry.PassengerName = nil
+	entry.Phone = nil
+	entry.Email = nil
+}
+
+// offerActive проверяет, что по записи есть действующее предложение.
+func offerActive(entry *models.WaitlistEntry, now time.Time) bool {
+	return entry.Status == WaitlistOffered && entry.OfferExpiresAt != nil && now.Before(*entry.OfferExpiresAt)
+}
+
+// tripStatusClosed сообщает, что рейс в этом статусе уже не продаётся.
+func tripStatusClosed(status string) bool {
+	switch status {
+	case "departed", "arrived", tripStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// tripClosedForSale сообщает, что рейс отправился (по статусу или по расписанию) или отменён.
+func tripClosedForSale(trip *repository.TripRefundInfo, now time.Time) bool {
+	return tripStatusClosed(trip.Status) || (trip.DepartureTime != nil && !now.Before(*trip.DepartureTime))
+}
+
+// newOfferToken генерирует код предложения для ссылки на выкуп.
+func newOfferToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate offer token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,54 @@
+// Package dbtx передаёт транзакцию GORM через context.
+//
+// Сервис открывает транзакцию (Transactor.Run), а репозитории берут соединение через From:
+// внутри транзакции — её, вне — обычное соединение. Так несколько вызовов репозиториев и запись
+// событий в outbox фиксируются вместе, а сигнатуры репозиториев не меняются.
+package dbtx
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type txKey struct{}
+
+// WithTx возвращает context с транзакцией tx.
+func WithTx(ctx context.Context, tx *gorm.DB) context.Context {
+	return context.WithValue(ctx, txKey{}, tx)
+}
+
+// From возвращает транзакцию из context или db с этим context.
+func From(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(txKey{}).(*gorm.DB); ok {
+		return tx
+	}
+	return db.WithContext(ctx)
+}
+
+// InTx сообщает, выполняется ли код внутри транзакции.
+func InTx(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*gorm.DB)
+	return ok
+}
+
+// Transactor открывает транзакции для сервисного слоя.
+type Transactor struct {
+	db *gorm.DB
+}
+
+// NewTransactor создаёт Transactor над БД сервиса.
+func NewTransactor(db *gorm.DB) *Transactor {
+	return &Transactor{db: db}
+}
+
+// Run выполняет fn в транзакции: ошибка или паника откатывают её. Вложенный вызов
+// выполняется в уже открытой транзакции.
+func (t *Transactor) Run(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTx(ctx) {
+		return fn(ctx)
+	}
+	return t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(WithTx(ctx, tx))
+	})
+}
@@ -0,0 +1,143 @@
+package eventbus
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultListLimit = 50
+	maxListLimit     = 500
+)
+
+// AdminHandler — HTTP API разбора необработанных событий (только роль admin).
+type AdminHandler struct {
+	letters *DeadLetters
+	logger  *zap.Logger
+}
+
+// NewAdminHandler создаёт AdminHandler.
+func NewAdminHandler(letters *DeadLetters, logger *zap.Logger) *AdminHandler {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &AdminHandler{letters: letters, logger: logger}
+}
+
+// Register добавляет маршруты в group:
+//
+//	GET    ""              — список (after — номер, после которого читать; limit — до 500)
+//	GET    "/:seq"         — событие
+//	POST   "/:seq/replay"  — переотправить консьюмеру
+//	DELETE "/:seq"         — удалить без обработки
+func (h *AdminHandler) Register(group *gin.RouterGroup) {
+	group.Use(requireAdmin)
+	group.GET("", h.List)
+	group.GET("/:seq", h.Get)
+	group.POST("/:seq/replay", h.Replay)
+	group.DELETE("/:seq", h.Discard)
+}
+
+// List возвращает необработанные события консьюмера.
+func (h *AdminHandler) List(c *gin.Context) {
+	after, err := strconv.ParseUint(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a sequence number"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultListLimit)))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+		return
+	}
+
+	letters, err := h.letters.List(c.Request.Context(), after, min(limit, maxListLimit))
+	if err != nil {
+		h.logger.Error("Failed to list dead letters", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list dead letters"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": letters})
+}
+
+// Get возвращает необработанное событие по номеру.
+func (h *AdminHandler) Get(c *gin.Context) {
+	seq, ok := sequenceParam(c)
+	if !ok {
+		return
+	}
+	letter, err := h.letters.Get(c.Request.Context(), seq)
+	if err != nil {
+		h.respondError(c, "Failed to get dead letter", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": letter})
+}
+
+// Replay переотправляет событие консьюмеру.
+func (h *AdminHandler) Replay(c *gin.Context) {
+	seq, ok := sequenceParam(c)
+	if !ok {
+		return
+	}
+	letter, err := h.letters.Replay(c.Request.Context(), seq)
+	if err != nil {
+		h.respondError(c, "Failed to replay dead letter", err)
+		return
+	}
+	h.logger.Info("Dead letter replayed",
+		zap.Uint64("sequence", seq),
+		zap.String("subject", letter.Subject),
+		zap.String("user_id", c.GetHeader("X-User-ID")))
+	c.JSON(http.StatusOK, gin.H{"data": letter})
+}
+
+// Discard удаляет событие без обработки.
+func (h *AdminHandler) Discard(c *gin.Context) {
+	seq, ok := sequenceParam(c)
+	if !ok {
+		return
+	}
+	if err := h.letters.Discard(c.Request.Context(), seq); err != nil {
+		h.respondError(c, "Failed to discard dead letter", err)
+		return
+	}
+	h.logger.Info("Dead letter discarded", zap.Uint64("sequence", seq), zap.String("user_id", c.GetHeader("X-User-ID")))
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"sequence": seq}})
+}
+
+func (h *AdminHandler) respondError(c *gin.Context, msg string, err error) {
+	if errors.Is(err, ErrDeadLetterNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+		return
+	}
+	h.logger.Error(msg, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+}
+
+func sequenceParam(c *gin.Context) (uint64, bool) {
+	seq, err := strconv.ParseUint(c.Param("seq"), 10, 64)
+	if err != nil || seq == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seq must be a sequence number"})
+		return 0, false
+	}
+	return seq, true
+}
+
+// requireAdmin пропускает только роль admin из контекста (JWT middleware) или заголовка
+// X-User-Role (API Gateway).
+func requireAdmin(c *gin.Context) {
+	role := c.GetString("role")
+	if role == "" {
+		role = c.GetHeader("X-User-Role")
+	}
+	if role != "admin" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin role required"})
+		return
+	}
+	c.Next()
+}
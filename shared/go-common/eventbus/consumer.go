@@ -0,0 +1,257 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/zap"
+)
+
+// Handler обрабатывает тело события. Ошибка — повтор с задержкой, ошибка с ErrPermanent —
+// сразу перенос в DEAD_LETTERS.
+type Handler func(ctx context.Context, data []byte) error
+
+// ErrPermanent отмечает ошибку, которую повтор не исправит (битое тело события).
+var ErrPermanent = errors.New("permanent event error")
+
+// errNoHandler возвращается для события без обработчика (например, после переименования subject).
+var errNoHandler = errors.New("no handler for subject")
+
+// Permanent оборачивает err как постоянную ошибку обработки.
+func Permanent(err error) error {
+	return fmt.Errorf("%w: %w", ErrPermanent, err)
+}
+
+// ConsumerConfig — настройки durable-консьюмера. Durable — имя консьюмера (обычно имя сервиса).
+// Событие, не подтверждённое за AckWait, доставляется повторно; после ошибки обработчика повтор
+// откладывается на Backoff[n-1] (последнее значение — для всех следующих попыток). После MaxDeliver
+// доставок событие переносится в DEAD_LETTERS. MaxAckPending ограничивает число событий
+// в обработке одновременно.
+type ConsumerConfig struct {
+	Durable       string
+	Backoff       []time.Duration
+	AckWait       time.Duration
+	MaxDeliver    int
+	MaxAckPending int
+}
+
+// Consumer читает события из потока EVENTS durable-консьюмером.
+type Consumer struct {
+	ctx      context.Context
+	nc       *nats.Conn
+	js       jetstream.JetStream
+	handlers map[string]Handler
+	consume  jetstream.ConsumeContext
+	advisory *nats.Subscription
+	logger   *zap.Logger
+	cfg      ConsumerConfig
+}
+
+// NewConsumer создаёт Consumer. Нулевые значения cfg заменяются значениями по умолчанию.
+func NewConsumer(nc *nats.Conn, js jetstream.JetStream, cfg ConsumerConfig, logger *zap.Logger) *Consumer {
+	if cfg.AckWait <= 0 {
+		cfg.AckWait = time.Minute
+	}
+	if len(cfg.Backoff) == 0 {
+		cfg.Backoff = []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute, 10 * time.Minute, 30 * time.Minute}
+	}
+	if cfg.MaxDeliver <= 0 {
+		cfg.MaxDeliver = 10
+	}
+	if cfg.MaxAckPending <= 0 {
+		cfg.MaxAckPending = 100
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Consumer{
+		nc:       nc,
+		js:       js,
+		handlers: make(map[string]Handler),
+		logger:   logger.With(zap.String("consumer", cfg.Durable)),
+		cfg:      cfg,
+	}
+}
+
+// Handle регистрирует обработчик событий subject. Вызывается до Start.
+func (c *Consumer) Handle(subject string, h Handler) {
+	c.handlers[subject] = h
+}
+
+// Start создаёт (или обновляет) durable-консьюмер на subjects зарегистрированных обработчиков
+// и начинает обработку. Переотправленные из DEAD_LETTERS события приходят на replay.<Durable>.>
+// и обрабатываются обработчиком исходного subject.
+func (c *Consumer) Start(ctx context.Context) error {
+	subjects := make([]string, 0, len(c.handlers)+1)
+	for subject := range c.handlers {
+		subjects = append(subjects, subject)
+	}
+	subjects = append(subjects, c.replaySubject(">"))
+
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, EventsStream, jetstream.ConsumerConfig{
+		Durable:        c.cfg.Durable,
+		FilterSubjects: subjects,
+		AckPolicy:      jetstream.AckExplicitPolicy,
+		AckWait:        c.cfg.AckWait,
+		MaxDeliver:     c.cfg.MaxDeliver,
+		MaxAckPending:  c.cfg.MaxAckPending,
+		DeliverPolicy:  jetstream.DeliverAllPolicy,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create consumer %s: %w", c.cfg.Durable, err)
+	}
+
+	// Событие, исчерпавшее доставки по тайм-ауту подтверждения (обработчик завис или сервис упал),
+	// сервер больше не отправит — о нём сообщает advisory, и событие переносится в DEAD_LETTERS
+	c.advisory, err = c.nc.Subscribe(
+		"$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES."+EventsStream+"."+c.cfg.Durable, c.handleMaxDeliveries)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to max deliveries advisory: %w", err)
+	}
+
+	c.ctx = ctx
+	c.consume, err = consumer.Consume(c.handle)
+	if err != nil {
+		return fmt.Errorf("failed to start consumer %s: %w", c.cfg.Durable, err)
+	}
+	c.logger.Info("JetStream consumer started", zap.Strings("subjects", subjects))
+	return nil
+}
+
+// Stop прекращает получение событий; события в обработке будут доставлены повторно.
+func (c *Consumer) Stop() {
+	if c.consume != nil {
+		c.consume.Stop()
+	}
+	if c.advisory != nil {
+		if err := c.advisory.Unsubscribe(); err != nil {
+			c.logger.Warn("Failed to unsubscribe from advisory", zap.Error(err))
+		}
+	}
+}
+
+// handle обрабатывает одно событие и подтверждает, откладывает или переносит его в DEAD_LETTERS.
+func (c *Consumer) handle(msg jetstream.Msg) {
+	meta, err := msg.Metadata()
+	if err != nil {
+		c.logger.Error("Failed to read message metadata", zap.Error(err))
+		c.nak(msg, c.cfg.Backoff[0])
+		return
+	}
+	subject := strings.TrimPrefix(msg.Subject(), c.replaySubject(""))
+
+	handler, ok := c.handlers[subject]
+	if !ok {
+		err = fmt.Errorf("%w: %w %s", ErrPermanent, errNoHandler, subject)
+	} else {
+		err = handler(c.ctx, msg.Data())
+	}
+	if err == nil {
+		if ackErr := msg.Ack(); ackErr != nil {
+			c.logger.Warn("Failed to ack message", zap.String("subject", subject), zap.Error(ackErr))
+		}
+		return
+	}
+
+	deliveries := int(meta.NumDelivered)
+	if !errors.Is(err, ErrPermanent) && deliveries < c.cfg.MaxDeliver {
+		delay := c.cfg.Backoff[min(deliveries, len(c.cfg.Backoff))-1]
+		c.logger.Warn("Failed to process event, will retry",
+			zap.String("subject", subject),
+			zap.Uint64("stream_seq", meta.Sequence.Stream),
+			zap.Int("deliveries", deliveries),
+			zap.Duration("retry_in", delay),
+			zap.Error(err))
+		c.nak(msg, delay)
+		return
+	}
+
+	c.logger.Error("Failed to process event, moving to dead letters",
+		zap.String("subject", subject),
+		zap.Uint64("stream_seq", meta.Sequence.Stream),
+		zap.Int("deliveries", deliveries),
+		zap.Error(err))
+	if dlqErr := c.deadLetter(subject, msg.Headers(), msg.Data(), meta.Sequence.Stream, deliveries, err); dlqErr != nil {
+		// Событие нельзя потерять: без записи в DEAD_LETTERS оно остаётся в потоке до следующей попытки
+		c.logger.Error("Failed to publish dead letter", zap.String("subject", subject), zap.Error(dlqErr))
+		c.nak(msg, c.cfg.Backoff[len(c.cfg.Backoff)-1])
+		return
+	}
+	if termErr := msg.Term(); termErr != nil {
+		c.logger.Warn("Failed to terminate message", zap.String("subject", subject), zap.Error(termErr))
+	}
+}
+
+// maxDeliveriesAdvisory — тело advisory о событии, исчерпавшем доставки.
+type maxDeliveriesAdvisory struct {
+	StreamSeq  uint64 `json:"stream_seq"`
+	Deliveries int    `json:"deliveries"`
+}
+
+// handleMaxDeliveries переносит в DEAD_LETTERS событие, которое сервер перестал доставлять.
+// Advisory получают все экземпляры сервиса; повтор отбрасывается по Nats-Msg-Id.
+func (c *Consumer) handleMaxDeliveries(m *nats.Msg) {
+	var advisory maxDeliveriesAdvisory
+	if err := json.Unmarshal(m.Data, &advisory); err != nil {
+		c.logger.Error("Failed to unmarshal max deliveries advisory", zap.Error(err))
+		return
+	}
+	stream, err := c.js.Stream(c.ctx, EventsStream)
+	if err != nil {
+		c.logger.Error("Failed to get events stream", zap.Error(err))
+		return
+	}
+	raw, err := stream.GetMsg(c.ctx, advisory.StreamSeq)
+	if err != nil {
+		c.logger.Error("Failed to get undelivered event", zap.Uint64("stream_seq", advisory.StreamSeq), zap.Error(err))
+		return
+	}
+	subject := strings.TrimPrefix(raw.Subject, c.replaySubject(""))
+	cause := fmt.Errorf("not acknowledged within %s after %d deliveries", c.cfg.AckWait, advisory.Deliveries)
+	c.logger.Error("Event exceeded max deliveries, moving to dead letters",
+		zap.String("subject", subject),
+		zap.Uint64("stream_seq", advisory.StreamSeq))
+	if err = c.deadLetter(subject, raw.Header, raw.Data, advisory.StreamSeq, advisory.Deliveries, cause); err != nil {
+		c.logger.Error("Failed to publish dead letter", zap.String("subject", subject), zap.Error(err))
+	}
+}
+
+// deadLetter публикует событие в DEAD_LETTERS с причиной отказа. Nats-Msg-Id из номера события
+// в потоке не даёт записать его дважды (повтор после сбоя, advisory на нескольких экземплярах).
+func (c *Consumer) deadLetter(subject string, header nats.Header, data []byte, streamSeq uint64, deliveries int, cause error) error {
+	msg := nats.NewMsg(deadLetterPrefix + c.cfg.Durable + "." + subject)
+	msg.Data = data
+	for k, v := range header {
+		msg.Header[k] = v
+	}
+	seq := strconv.FormatUint(streamSeq, 10)
+	msg.Header.Set(nats.MsgIdHdr, "dlq-"+c.cfg.Durable+"-"+seq)
+	msg.Header.Set(HeaderOriginalSubject, subject)
+	msg.Header.Set(HeaderConsumer, c.cfg.Durable)
+	msg.Header.Set(HeaderError, cause.Error())
+	msg.Header.Set(HeaderDeliveries, strconv.Itoa(deliveries))
+	msg.Header.Set(HeaderStreamSeq, seq)
+
+	ctx, cancel := context.WithTimeout(c.ctx, c.cfg.AckWait)
+	defer cancel()
+	_, err := c.js.PublishMsg(ctx, msg)
+	return err
+}
+
+func (c *Consumer) nak(msg jetstream.Msg, delay time.Duration) {
+	if err := msg.NakWithDelay(delay); err != nil {
+		c.logger.Warn("Failed to nak message", zap.String("subject", msg.Subject()), zap.Error(err))
+	}
+}
+
+// replaySubject возвращает subject переотправки события консьюмеру.
+func (c *Consumer) replaySubject(subject string) string {
+	return replayPrefix + c.cfg.Durable + "." + subject
+}
@@ -0,0 +1,151 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// ErrDeadLetterNotFound возвращается, если в DEAD_LETTERS нет события консьюмера с таким номером.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// DeadLetter — событие, которое консьюмер не смог обработать.
+type DeadLetter struct {
+	FailedAt       time.Time       `json:"failed_at"`
+	Subject        string          `json:"subject"`
+	Consumer       string          `json:"consumer"`
+	Error          string          `json:"error"`
+	Payload        json.RawMessage `json:"payload"`
+	Sequence       uint64          `json:"sequence"`
+	StreamSequence uint64          `json:"stream_sequence"`
+	Deliveries     int             `json:"deliveries"`
+}
+
+// DeadLetters — просмотр и переотправка необработанных событий одного консьюмера.
+type DeadLetters struct {
+	js      jetstream.JetStream
+	durable string
+}
+
+// NewDeadLetters создаёт DeadLetters консьюмера durable.
+func NewDeadLetters(js jetstream.JetStream, durable string) *DeadLetters {
+	return &DeadLetters{js: js, durable: durable}
+}
+
+// List возвращает до limit событий с номером больше after в порядке поступления.
+func (d *DeadLetters) List(ctx context.Context, after uint64, limit int) ([]*DeadLetter, error) {
+	stream, err := d.js.Stream(ctx, DeadLetterStream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter stream: %w", err)
+	}
+	letters := make([]*DeadLetter, 0, limit)
+	for seq := after + 1; len(letters) < limit; {
+		raw, getErr := stream.GetMsg(ctx, seq, jetstream.WithGetMsgSubject(d.subject(">")))
+		if errors.Is(getErr, jetstream.ErrMsgNotFound) {
+			break
+		}
+		if getErr != nil {
+			return nil, fmt.Errorf("failed to read dead letters: %w", getErr)
+		}
+		letters = append(letters, toDeadLetter(raw))
+		seq = raw.Sequence + 1
+	}
+	return letters, nil
+}
+
+// Get возвращает событие по номеру в DEAD_LETTERS.
+func (d *DeadLetters) Get(ctx context.Context, seq uint64) (*DeadLetter, error) {
+	_, raw, err := d.get(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	return toDeadLetter(raw), nil
+}
+
+// Replay переотправляет событие консьюмеру (только ему, а не всем получателям subject)
+// и удаляет его из DEAD_LETTERS. Если обработка снова не удастся, событие вернётся сюда
+// с новым номером.
+func (d *DeadLetters) Replay(ctx context.Context, seq uint64) (*DeadLetter, error) {
+	stream, raw, err := d.get(ctx, seq)
+	if err != nil {
+		return nil, err
+	}
+	letter := toDeadLetter(raw)
+
+	msg := nats.NewMsg(replayPrefix + d.durable + "." + letter.Subject)
+	msg.Data = raw.Data
+	for k, v := range raw.Header {
+		if k == nats.MsgIdHdr || strings.HasPrefix(k, "Vokzal-") {
+			continue
+		}
+		msg.Header[k] = v
+	}
+	if _, err = d.js.PublishMsg(ctx, msg); err != nil {
+		return nil, fmt.Errorf("failed to replay dead letter: %w", err)
+	}
+	if err = stream.DeleteMsg(ctx, seq); err != nil {
+		return nil, fmt.Errorf("failed to delete replayed dead letter: %w", err)
+	}
+	return letter, nil
+}
+
+// Discard удаляет событие из DEAD_LETTERS без обработки.
+func (d *DeadLetters) Discard(ctx context.Context, seq uint64) error {
+	stream, _, err := d.get(ctx, seq)
+	if err != nil {
+		return err
+	}
+	if err = stream.DeleteMsg(ctx, seq); err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	return nil
+}
+
+// get читает событие и проверяет, что оно принадлежит консьюмеру.
+func (d *DeadLetters) get(ctx context.Context, seq uint64) (jetstream.Stream, *jetstream.RawStreamMsg, error) {
+	stream, err := d.js.Stream(ctx, DeadLetterStream)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get dead letter stream: %w", err)
+	}
+	raw, err := stream.GetMsg(ctx, seq)
+	if errors.Is(err, jetstream.ErrMsgNotFound) {
+		return nil, nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+	if !strings.HasPrefix(raw.Subject, d.subject("")) {
+		return nil, nil, ErrDeadLetterNotFound
+	}
+	return stream, raw, nil
+}
+
+func (d *DeadLetters) subject(suffix string) string {
+	return deadLetterPrefix + d.durable + "." + suffix
+}
+
+func toDeadLetter(raw *jetstream.RawStreamMsg) *DeadLetter {
+	letter := &DeadLetter{
+		FailedAt: raw.Time,
+		Subject:  raw.Header.Get(HeaderOriginalSubject),
+		Consumer: raw.Header.Get(HeaderConsumer),
+		Error:    raw.Header.Get(HeaderError),
+		Sequence: raw.Sequence,
+	}
+	letter.StreamSequence, _ = strconv.ParseUint(raw.Header.Get(HeaderStreamSeq), 10, 64)
+	letter.Deliveries, _ = strconv.Atoi(raw.Header.Get(HeaderDeliveries))
+	// Тело события отдаётся как есть, если это JSON, иначе — строкой
+	if json.Valid(raw.Data) {
+		letter.Payload = raw.Data
+	} else if quoted, err := json.Marshal(string(raw.Data)); err == nil {
+		letter.Payload = quoted
+	}
+	return letter
+}
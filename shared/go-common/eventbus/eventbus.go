@@ -0,0 +1,128 @@
+// Package eventbus — доставка событий между сервисами через NATS JetStream.
+//
+// Все события хранятся в потоке EVENTS (subjects — EventSubjects). Получатели читают его
+// durable-консьюмерами с явным подтверждением (Consumer): событие, опубликованное, пока получатель
+// остановлен, будет обработано после запуска. Ошибка обработки — повтор с задержкой (Backoff);
+// после MaxDeliver попыток или при постоянной ошибке (Permanent) событие переносится в поток
+// DEAD_LETTERS (subject dlq.<консьюмер>.<исходный subject>), откуда его можно просмотреть
+// и переотправить тому же консьюмеру (DeadLetters, AdminHandler).
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Имена потоков JetStream.
+const (
+	// EventsStream — поток событий сервисов.
+	EventsStream = "EVENTS"
+	// DeadLetterStream — поток событий, которые не удалось обработать.
+	DeadLetterStream = "DEAD_LETTERS"
+)
+
+// Префиксы служебных subjects.
+const (
+	deadLetterPrefix = "dlq."
+	replayPrefix     = "replay."
+)
+
+// Заголовки сообщений в DEAD_LETTERS.
+const (
+	// HeaderOriginalSubject — subject, на котором событие было опубликовано.
+	HeaderOriginalSubject = "Vokzal-Original-Subject"
+	// HeaderConsumer — консьюмер, не обработавший событие.
+	HeaderConsumer = "Vokzal-Consumer"
+	// HeaderError — последняя ошибка обработки.
+	HeaderError = "Vokzal-Error"
+	// HeaderDeliveries — сколько раз событие доставлялось консьюмеру.
+	HeaderDeliveries = "Vokzal-Deliveries"
+	// HeaderStreamSeq — номер события в потоке EVENTS.
+	HeaderStreamSeq = "Vokzal-Stream-Seq"
+)
+
+const (
+	// eventsMaxAge — сколько хранятся события: за это время остановленный получатель должен
+	// вернуться в строй, иначе необработанные события будут потеряны.
+	eventsMaxAge = 7 * 24 * time.Hour
+	// deadLettersMaxAge — сколько хранятся необработанные события до разбора.
+	deadLettersMaxAge = 30 * 24 * time.Hour
+	// duplicateWindow — окно, в котором повтор с тем же Nats-Msg-Id отбрасывается
+	// (повторная отправка события relay outbox).
+	duplicateWindow = 10 * time.Minute
+)
+
+// EventSubjects — subjects событий, которые сохраняет поток EVENTS. Событие на subject вне списка
+// не будет принято Publisher: новый тип событий нужно добавить сюда.
+var EventSubjects = []string{
+	"ticket.>",
+	"baggage.>",
+	"boarding.>",
+	"shift.>",
+	"trip.>",
+	"payment.>",
+	"fiscal.>",
+	"pii.>",
+	"audit.>",
+	"waitlist.>",
+	"voucher.>",
+	replayPrefix + ">",
+}
+
+// EnsureStreams создаёт или обновляет потоки EVENTS и DEAD_LETTERS. Вызывается при запуске
+// каждым сервисом, который публикует или получает события, поэтому порядок запуска не важен.
+func EnsureStreams(ctx context.Context, js jetstream.JetStream) error {
+	streams := []jetstream.StreamConfig{
+		{
+			Name:       EventsStream,
+			Subjects:   EventSubjects,
+			Storage:    jetstream.FileStorage,
+			Retention:  jetstream.LimitsPolicy,
+			MaxAge:     eventsMaxAge,
+			Duplicates: duplicateWindow,
+		},
+		{
+			Name:       DeadLetterStream,
+			Subjects:   []string{deadLetterPrefix + ">"},
+			Storage:    jetstream.FileStorage,
+			Retention:  jetstream.LimitsPolicy,
+			MaxAge:     deadLettersMaxAge,
+			Duplicates: duplicateWindow,
+		},
+	}
+	for i := range streams {
+		if _, err := js.CreateOrUpdateStream(ctx, streams[i]); err != nil {
+			return fmt.Errorf("failed to ensure stream %s: %w", streams[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// Publisher публикует события в JetStream с подтверждением записи в поток.
+// Реализует outbox.Publisher: событие считается доставленным, когда поток его сохранил.
+type Publisher struct {
+	js      jetstream.JetStream
+	timeout time.Duration
+}
+
+// NewPublisher создаёт Publisher; timeout ограничивает ожидание подтверждения одного события.
+func NewPublisher(js jetstream.JetStream, timeout time.Duration) *Publisher {
+	return &Publisher{js: js, timeout: timeout}
+}
+
+// PublishMsg публикует сообщение и ждёт подтверждения JetStream.
+func (p *Publisher) PublishMsg(msg *nats.Msg) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	_, err := p.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// FlushTimeout ничего не делает: PublishMsg уже дождался подтверждения.
+func (p *Publisher) FlushTimeout(time.Duration) error {
+	return nil
+}
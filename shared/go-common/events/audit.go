@@ -0,0 +1,24 @@
+package events
+
+import "time"
+
+// TypeAuditLog — изменение сущности для журнала аудита (audit-service).
+const TypeAuditLog = "audit.log"
+
+// AuditLog — запись журнала аудита (audit.log). OldValue и NewValue — произвольные снимки
+// состояния сущности до и после действия.
+type AuditLog struct {
+	Timestamp  time.Time   `json:"timestamp"`
+	OldValue   interface{} `json:"old_value"`
+	NewValue   interface{} `json:"new_value"`
+	EntityType string      `json:"entity_type"`
+	EntityID   string      `json:"entity_id"`
+	Action     string      `json:"action"`
+	UserID     string      `json:"user_id"`
+}
+
+// EventType возвращает тип события.
+func (AuditLog) EventType() string { return TypeAuditLog }
+
+// EventVersion возвращает версию контракта.
+func (AuditLog) EventVersion() int { return 1 }
@@ -0,0 +1,59 @@
+package events
+
+import "time"
+
+// Типы событий посадки (ticket-service).
+const (
+	TypeBoardingStarted = "boarding.started"
+	TypeBoardingClosed  = "boarding.closed"
+	TypeBoardingSynced  = "boarding.synced"
+)
+
+// BoardingStarted — начата посадка на рейс (boarding.started).
+type BoardingStarted struct {
+	StartedAt time.Time `json:"started_at"`
+	TripID    string    `json:"trip_id"`
+	StartedBy string    `json:"started_by"`
+}
+
+// EventType возвращает тип события.
+func (BoardingStarted) EventType() string { return TypeBoardingStarted }
+
+// EventVersion возвращает версию контракта.
+func (BoardingStarted) EventVersion() int { return 1 }
+
+// BoardingClosed — посадка завершена, манифест заморожен (boarding.closed). ByScanMethod — число
+// посадок по способу отметки (qr, manual, offline).
+type BoardingClosed struct {
+	StartedAt    time.Time      `json:"started_at"`
+	EndedAt      time.Time      `json:"ended_at"`
+	ByScanMethod map[string]int `json:"by_scan_method"`
+	TripID       string         `json:"trip_id"`
+	EndedBy      string         `json:"ended_by"`
+	TotalTickets int            `json:"total_tickets"`
+	BoardedCount int            `json:"boarded_count"`
+	NoShowCount  int            `json:"no_show_count"`
+	OfflineCount int            `json:"offline_count"`
+}
+
+// EventType возвращает тип события.
+func (BoardingClosed) EventType() string { return TypeBoardingClosed }
+
+// EventVersion возвращает версию контракта.
+func (BoardingClosed) EventVersion() int { return 1 }
+
+// BoardingSynced — с устройства контролёра выгружены офлайн-отметки посадки (boarding.synced).
+type BoardingSynced struct {
+	TripID        string `json:"trip_id"`
+	DeviceID      string `json:"device_id"`
+	UserID        string `json:"user_id"`
+	Accepted      int    `json:"accepted"`
+	AlreadySynced int    `json:"already_synced"`
+	Conflicts     int    `json:"conflicts"`
+}
+
+// EventType возвращает тип события.
+func (BoardingSynced) EventType() string { return TypeBoardingSynced }
+
+// EventVersion возвращает версию контракта.
+func (BoardingSynced) EventVersion() int { return 1 }
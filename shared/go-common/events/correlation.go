@@ -0,0 +1,41 @@
+package events
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// HeaderCorrelationID — HTTP-заголовок с ID корреляции запроса.
+const HeaderCorrelationID = "X-Correlation-ID"
+
+// maxCorrelationIDLength — наибольшая длина ID корреляции из заголовка; более длинный заменяется новым.
+const maxCorrelationIDLength = 64
+
+type correlationKey struct{}
+
+// WithCorrelationID возвращает ctx с ID корреляции.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID возвращает ID корреляции из ctx или пустую строку.
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// CorrelationMiddleware берёт ID корреляции из заголовка X-Correlation-ID (API Gateway, другой
+// сервис) или создаёт новый, кладёт его в context запроса и возвращает в ответе.
+func CorrelationMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(HeaderCorrelationID)
+		if id == "" || len(id) > maxCorrelationIDLength {
+			id = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(WithCorrelationID(c.Request.Context(), id))
+		c.Header(HeaderCorrelationID, id)
+		c.Next()
+	}
+}
@@ -0,0 +1,124 @@
+// Package events — контракты событий между сервисами: версионированные структуры событий
+// и конверт (Envelope) с метаданными.
+//
+// Событие публикуется в конверте: ID, тип (совпадает с subject NATS), версия контракта, время,
+// сервис-источник и ID корреляции (сквозной ID запроса, породившего цепочку событий). Тело события —
+// структура этого пакета, а не модель сервиса: переименование поля в модели не меняет контракт.
+//
+// Совместимые изменения (новое необязательное поле) делаются без смены версии: получатель
+// игнорирует незнакомые поля. Несовместимые (удаление, переименование, смена типа или смысла поля)
+// требуют новой версии структуры; получатель, не знающий версию, отклоняет событие
+// (ErrUnsupportedVersion), и оно уходит в dead letters до обновления получателя.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTypeMismatch возвращается при разборе тела события в структуру другого типа.
+	ErrTypeMismatch = errors.New("event type mismatch")
+	// ErrUnsupportedVersion возвращается для версии события, которую получатель не поддерживает.
+	ErrUnsupportedVersion = errors.New("unsupported event version")
+)
+
+// Event — тело события. EventType совпадает с subject NATS, EventVersion — версия контракта.
+type Event interface {
+	EventType() string
+	EventVersion() int
+}
+
+// Envelope — конверт события.
+type Envelope struct {
+	OccurredAt    time.Time       `json:"occurred_at"`
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Producer      string          `json:"producer"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Data          json.RawMessage `json:"data"`
+	Version       int             `json:"version"`
+}
+
+// New упаковывает событие сервиса producer в конверт. ID корреляции берётся из ctx.
+func New(ctx context.Context, producer string, event Event) (*Envelope, error) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s event: %w", event.EventType(), err)
+	}
+	return &Envelope{
+		OccurredAt:    time.Now().UTC(),
+		ID:            uuid.NewString(),
+		Type:          event.EventType(),
+		Producer:      producer,
+		CorrelationID: CorrelationID(ctx),
+		Data:          data,
+		Version:       event.EventVersion(),
+	}, nil
+}
+
+// Encode упаковывает событие в конверт и возвращает его JSON.
+func Encode(ctx context.Context, producer string, event Event) ([]byte, error) {
+	env, err := New(ctx, producer, event)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
+
+// Decode разбирает конверт события. Тело без конверта (опубликованное до перехода на контракты
+// и ещё не доставленное) считается телом версии 1 без метаданных.
+func Decode(data []byte) (*Envelope, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	if env.Type == "" || env.Version == 0 || len(env.Data) == 0 {
+		return &Envelope{Data: data, Version: 1}, nil
+	}
+	return &env, nil
+}
+
+// DecodeData разбирает тело события в event, проверив тип и версию.
+func (e *Envelope) DecodeData(event Event) error {
+	if e.Type != "" && e.Type != event.EventType() {
+		return fmt.Errorf("%w: got %s, want %s", ErrTypeMismatch, e.Type, event.EventType())
+	}
+	if e.Version != event.EventVersion() {
+		return fmt.Errorf("%w: %s v%d, supported v%d", ErrUnsupportedVersion, event.EventType(), e.Version, event.EventVersion())
+	}
+	if err := json.Unmarshal(e.Data, event); err != nil {
+		return fmt.Errorf("failed to unmarshal %s event data: %w", event.EventType(), err)
+	}
+	return nil
+}
+
+// Unmarshal разбирает конверт и тело события в event.
+func Unmarshal(data []byte, event Event) (*Envelope, error) {
+	env, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = env.DecodeData(event); err != nil {
+		return nil, err
+	}
+	return env, nil
+}
+
+// Context возвращает ctx с ID корреляции события, чтобы события, опубликованные при его обработке,
+// продолжили ту же цепочку. Если у события нет ID корреляции, цепочку начинает его ID.
+func (e *Envelope) Context(ctx context.Context) context.Context {
+	id := e.CorrelationID
+	if id == "" {
+		id = e.ID
+	}
+	if id == "" {
+		return ctx
+	}
+	return WithCorrelationID(ctx, id)
+}
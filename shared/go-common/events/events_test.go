@@ -0,0 +1,245 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+)
+
+// Тела событий в том виде, в каком сервисы публиковали их до перехода на контракты
+// (сериализованные модели и map). Такие события ещё могут лежать в outbox и потоке EVENTS.
+const (
+	legacyTicketReturned = `{
+		"id": "7c9e6679-7425-40de-944b-e07fc1f90ae7",
+		"trip_id": "a3bb189e-8bf9-3888-9912-ace4e6543002",
+		"seat_id": "12",
+		"status": "returned",
+		"payment_method": "card",
+		"passenger_category": "adult",
+		"bar_code": "2000000000015",
+		"qr_code": "eyJhbGciOi",
+		"price": 1500,
+		"refund_amount": 1275.5,
+		"refund_penalty": 224.5,
+		"refund_policy_version": 3,
+		"refunded_at": "2026-03-01T10:15:00Z",
+		"created_at": "2026-02-27T08:00:00Z",
+		"updated_at": "2026-03-01T10:15:00Z"
+	}`
+	legacyBaggageSold = `{
+		"id": "b1", "ticket_id": "t1", "trip_id": "trip1", "weight_class": "large",
+		"payment_method": "cash", "bar_code": "2100000000011", "status": "active",
+		"pieces": 2, "tariff": 250, "price": 500, "created_at": "2026-03-01T10:00:00Z"
+	}`
+	legacyBoardingClosed = `{
+		"trip_id": "trip1", "started_at": "2026-03-01T09:30:00Z", "ended_at": "2026-03-01T10:00:00Z",
+		"ended_by": "u1", "total_tickets": 40, "boarded_count": 37, "no_show_count": 3,
+		"offline_count": 5, "by_scan_method": {"qr": 30, "manual": 2, "offline": 5}
+	}`
+	legacyPIIAnonymized = `{
+		"service": "notify", "entity_type": "notification", "entity_ids": ["n1", "n2"],
+		"fields": "recipient,message", "reason": "erasure_request", "erasure_request_id": "er1"
+	}`
+	legacyAuditLog = `{
+		"entity_type": "ticket", "entity_id": "t1", "action": "refund", "user_id": "u1",
+		"old_value": 1500, "new_value": {"refund_amount": 1275.5}, "timestamp": "2026-03-01T10:15:00Z"
+	}`
+)
+
+func TestDecodeLegacyPayloads(t *testing.T) {
+	var returned TicketReturned
+	if _, err := Unmarshal([]byte(legacyTicketReturned), &returned); err != nil {
+		t.Fatalf("ticket.returned: %v", err)
+	}
+	if returned.ID != "7c9e6679-7425-40de-944b-e07fc1f90ae7" || returned.PaymentMethod != "card" || returned.Price != 1500 {
+		t.Errorf("ticket.returned: unexpected ticket %+v", returned.Ticket)
+	}
+	if returned.RefundAmount == nil || *returned.RefundAmount != 1275.5 {
+		t.Errorf("ticket.returned: refund_amount = %v, want 1275.5", returned.RefundAmount)
+	}
+	if returned.RefundPolicyVersion == nil || *returned.RefundPolicyVersion != 3 {
+		t.Errorf("ticket.returned: refund_policy_version = %v, want 3", returned.RefundPolicyVersion)
+	}
+
+	var baggage BaggageSold
+	if _, err := Unmarshal([]byte(legacyBaggageSold), &baggage); err != nil {
+		t.Fatalf("baggage.sold: %v", err)
+	}
+	if baggage.Pieces != 2 || baggage.Tariff != 250 || baggage.WeightClass != "large" {
+		t.Errorf("baggage.sold: unexpected baggage %+v", baggage.Baggage)
+	}
+
+	var closed BoardingClosed
+	if _, err := Unmarshal([]byte(legacyBoardingClosed), &closed); err != nil {
+		t.Fatalf("boarding.closed: %v", err)
+	}
+	if closed.TripID != "trip1" || closed.NoShowCount != 3 || closed.ByScanMethod["offline"] != 5 {
+		t.Errorf("boarding.closed: unexpected summary %+v", closed)
+	}
+
+	var anonymized PIIAnonymized
+	if _, err := Unmarshal([]byte(legacyPIIAnonymized), &anonymized); err != nil {
+		t.Fatalf("pii.anonymized: %v", err)
+	}
+	if len(anonymized.EntityIDs) != 2 || anonymized.ErasureRequestID != "er1" {
+		t.Errorf("pii.anonymized: unexpected report %+v", anonymized)
+	}
+
+	var audit AuditLog
+	env, err := Unmarshal([]byte(legacyAuditLog), &audit)
+	if err != nil {
+		t.Fatalf("audit.log: %v", err)
+	}
+	if audit.Action != "refund" || audit.OldValue != float64(1500) || audit.Timestamp.IsZero() {
+		t.Errorf("audit.log: unexpected record %+v", audit)
+	}
+	if env.Version != 1 || env.Type != "" || env.ID != "" {
+		t.Errorf("legacy envelope = %+v, want version 1 without metadata", env)
+	}
+}
+
+// TestEnvelopeWireFormat фиксирует формат конверта: получатели на других версиях go-common
+// разбирают его по этим именам полей.
+func TestEnvelopeWireFormat(t *testing.T) {
+	const wire = `{
+		"id": "0b7e2f4c-9d8a-4f7e-a1b2-3c4d5e6f7a8b",
+		"type": "boarding.started",
+		"version": 1,
+		"occurred_at": "2026-03-01T09:30:00.5Z",
+		"producer": "ticket",
+		"correlation_id": "req-42",
+		"data": {"trip_id": "trip1", "started_at": "2026-03-01T09:30:00Z", "started_by": "u1"}
+	}`
+	var started BoardingStarted
+	env, err := Unmarshal([]byte(wire), &started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != "0b7e2f4c-9d8a-4f7e-a1b2-3c4d5e6f7a8b" || env.Producer != "ticket" || env.CorrelationID != "req-42" {
+		t.Errorf("unexpected envelope %+v", env)
+	}
+	if want := time.Date(2026, 3, 1, 9, 30, 0, 500_000_000, time.UTC); !env.OccurredAt.Equal(want) {
+		t.Errorf("occurred_at = %v, want %v", env.OccurredAt, want)
+	}
+	if started.TripID != "trip1" || started.StartedBy != "u1" {
+		t.Errorf("unexpected event %+v", started)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	amount := 300.0
+	sent := TicketReturned{Ticket: Ticket{
+		CreatedAt:     time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC),
+		RefundAmount:  &amount,
+		ID:            "t1",
+		TripID:        "trip1",
+		Status:        "returned",
+		PaymentMethod: "sbp",
+		Price:         400,
+	}}
+	ctx := WithCorrelationID(context.Background(), "req-1")
+	data, err := Encode(ctx, "ticket", sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got TicketReturned
+	env, err := Unmarshal(data, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Type != TypeTicketReturned || env.Version != 1 || env.Producer != "ticket" || env.CorrelationID != "req-1" {
+		t.Errorf("unexpected envelope %+v", env)
+	}
+	if env.ID == "" || env.OccurredAt.IsZero() {
+		t.Errorf("envelope without id or occurred_at: %+v", env)
+	}
+	if got.ID != sent.ID || got.PaymentMethod != sent.PaymentMethod || *got.RefundAmount != amount || !got.CreatedAt.Equal(sent.CreatedAt) {
+		t.Errorf("round trip: got %+v, want %+v", got.Ticket, sent.Ticket)
+	}
+	if got := CorrelationID(env.Context(context.Background())); got != "req-1" {
+		t.Errorf("correlation id in handler context = %q, want req-1", got)
+	}
+}
+
+func TestDecodeIgnoresUnknownFields(t *testing.T) {
+	// Новое поле от более свежего отправителя той же версии не мешает старому получателю
+	const wire = `{"id":"e1","type":"shift.closed","version":1,"producer":"ticket","occurred_at":"2026-03-01T18:00:00Z",
+		"trace":{"span":"x"},"data":{"shift_id":"s1","cashier_id":"c1","expected_cash":1000,"counted_cash":990,
+		"cash_difference":-10,"terminal_settlement":{"batch":7}}}`
+	var closed ShiftClosed
+	if _, err := Unmarshal([]byte(wire), &closed); err != nil {
+		t.Fatal(err)
+	}
+	if closed.ShiftID != "s1" || closed.CashDifference != -10 {
+		t.Errorf("unexpected event %+v", closed)
+	}
+}
+
+func TestDecodeRejectsIncompatibleEvents(t *testing.T) {
+	tests := []struct {
+		want error
+		name string
+		wire string
+	}{
+		{
+			name: "newer version",
+			wire: `{"id":"e1","type":"ticket.sold","version":2,"producer":"ticket","data":{"id":"t1"}}`,
+			want: ErrUnsupportedVersion,
+		},
+		{
+			name: "other type",
+			wire: `{"id":"e1","type":"ticket.returned","version":1,"producer":"ticket","data":{"id":"t1"}}`,
+			want: ErrTypeMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sold TicketSold
+			if _, err := Unmarshal([]byte(tt.wire), &sold); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	var sold TicketSold
+	if _, err := Unmarshal([]byte(`{"id":"t1","price":"1500"}`), &sold); err == nil {
+		t.Error("price as string: expected error")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var got *BoardingClosed
+	var correlationID string
+	handler := Handler(func(ctx context.Context, event *BoardingClosed) error {
+		got = event
+		correlationID = CorrelationID(ctx)
+		return nil
+	})
+
+	data, err := Encode(WithCorrelationID(context.Background(), "req-7"), "ticket", BoardingClosed{TripID: "trip1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handler(context.Background(), data); err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.TripID != "trip1" || correlationID != "req-7" {
+		t.Errorf("handler got %+v with correlation id %q", got, correlationID)
+	}
+
+	wrong, err := json.Marshal(Envelope{ID: "e1", Type: TypeBoardingClosed, Version: 9, Data: json.RawMessage(`{}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = handler(context.Background(), wrong); !errors.Is(err, eventbus.ErrPermanent) {
+		t.Errorf("unsupported version: err = %v, want permanent", err)
+	}
+	if err = handler(context.Background(), []byte("not json")); !errors.Is(err, eventbus.ErrPermanent) {
+		t.Errorf("broken payload: err = %v, want permanent", err)
+	}
+}
@@ -0,0 +1,27 @@
+package events
+
+import "time"
+
+// TypeZReport — смена ККТ закрыта Z-отчётом (fiscal-service).
+const TypeZReport = "fiscal.z_report"
+
+// ZReport — Z-отчёт ККТ (fiscal.z_report). Date — кассовый день (YYYY-MM-DD).
+type ZReport struct {
+	CreatedAt    time.Time `json:"created_at"`
+	ID           string    `json:"id"`
+	Date         string    `json:"date"`
+	KKTSerial    string    `json:"kkt_serial"`
+	Status       string    `json:"status"`
+	FiscalSign   string    `json:"fiscal_sign"`
+	TotalSales   float64   `json:"total_sales"`
+	TotalRefunds float64   `json:"total_refunds"`
+	ShiftNumber  int       `json:"shift_number"`
+	SalesCount   int       `json:"sales_count"`
+	RefundsCount int       `json:"refunds_count"`
+}
+
+// EventType возвращает тип события.
+func (ZReport) EventType() string { return TypeZReport }
+
+// EventVersion возвращает версию контракта.
+func (ZReport) EventVersion() int { return 1 }
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"context"
+
+	"github.com/vokzal-tech/go-common/eventbus"
+)
+
+// Handler превращает обработчик события типа T в eventbus.Handler. Конверт или тело, которые
+// не разбираются, и неизвестная версия повтором не исправятся — такие события сразу уходят
+// в dead letters. Обработчик получает ctx с ID корреляции события.
+func Handler[T any, P interface {
+	*T
+	Event
+}](handle func(ctx context.Context, event P) error) eventbus.Handler {
+	return func(ctx context.Context, data []byte) error {
+		event := P(new(T))
+		env, err := Unmarshal(data, event)
+		if err != nil {
+			return eventbus.Permanent(err)
+		}
+		return handle(env.Context(ctx), event)
+	}
+}
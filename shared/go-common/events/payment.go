@@ -0,0 +1,69 @@
+package events
+
+import "time"
+
+// Типы событий payment-service.
+const (
+	TypePaymentConfirmed    = "payment.confirmed"
+	TypePaymentRefunded     = "payment.refunded"
+	TypePaymentRefundFailed = "payment.refund_failed"
+)
+
+// PaymentConfirmed — платёж подтверждён провайдером (payment.confirmed).
+type PaymentConfirmed struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at,omitempty"`
+	TicketID    *string    `json:"ticket_id,omitempty"`
+	ExternalID  *string    `json:"external_id,omitempty"`
+	ID          string     `json:"id"`
+	Provider    string     `json:"provider"`
+	Method      string     `json:"method"`
+	Status      string     `json:"status"`
+	Currency    string     `json:"currency"`
+	Amount      float64    `json:"amount"`
+}
+
+// EventType возвращает тип события.
+func (PaymentConfirmed) EventType() string { return TypePaymentConfirmed }
+
+// EventVersion возвращает версию контракта.
+func (PaymentConfirmed) EventVersion() int { return 1 }
+
+// Refund — возврат денег по платежу у провайдера в событиях.
+type Refund struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	TicketID    *string    `json:"ticket_id,omitempty"`
+	ExternalID  *string    `json:"external_id,omitempty"`
+	LastError   *string    `json:"last_error,omitempty"`
+	ID          string     `json:"id"`
+	PaymentID   string     `json:"payment_id"`
+	Reference   string     `json:"reference"`
+	Provider    string     `json:"provider"`
+	Status      string     `json:"status"`
+	Amount      float64    `json:"amount"`
+	Attempts    int        `json:"attempts"`
+}
+
+// PaymentRefunded — возврат выполнен провайдером (payment.refunded).
+type PaymentRefunded struct {
+	Refund
+}
+
+// EventType возвращает тип события.
+func (PaymentRefunded) EventType() string { return TypePaymentRefunded }
+
+// EventVersion возвращает версию контракта.
+func (PaymentRefunded) EventVersion() int { return 1 }
+
+// PaymentRefundFailed — провайдер отклонил возврат или исчерпаны попытки (payment.refund_failed);
+// нужен ручной разбор.
+type PaymentRefundFailed struct {
+	Refund
+}
+
+// EventType возвращает тип события.
+func (PaymentRefundFailed) EventType() string { return TypePaymentRefundFailed }
+
+// EventVersion возвращает версию контракта.
+func (PaymentRefundFailed) EventVersion() int { return 1 }
@@ -0,0 +1,40 @@
+package events
+
+// Типы событий обезличивания персональных данных.
+const (
+	// TypePIIAnonymize — команда ticket-service обезличить записи по билетам и контактам.
+	TypePIIAnonymize = "pii.anonymize"
+	// TypePIIAnonymized — отчёт сервиса об обезличенных записях для журнала ticket-service.
+	TypePIIAnonymized = "pii.anonymized"
+)
+
+// PIIAnonymize — команда обезличивания (pii.anonymize). ContactIndexes — слепые индексы контактов
+// пассажира; самих ПД в команде нет. ErasureRequestID пуст при плановой очистке по сроку хранения.
+type PIIAnonymize struct {
+	Reason           string   `json:"reason"`
+	ErasureRequestID string   `json:"erasure_request_id,omitempty"`
+	TicketIDs        []string `json:"ticket_ids"`
+	ContactIndexes   []string `json:"contact_indexes"`
+}
+
+// EventType возвращает тип события.
+func (PIIAnonymize) EventType() string { return TypePIIAnonymize }
+
+// EventVersion возвращает версию контракта.
+func (PIIAnonymize) EventVersion() int { return 1 }
+
+// PIIAnonymized — отчёт об обезличивании (pii.anonymized). Fields — обезличенные поля через запятую.
+type PIIAnonymized struct {
+	Service          string   `json:"service"`
+	EntityType       string   `json:"entity_type"`
+	Fields           string   `json:"fields"`
+	Reason           string   `json:"reason"`
+	ErasureRequestID string   `json:"erasure_request_id,omitempty"`
+	EntityIDs        []string `json:"entity_ids"`
+}
+
+// EventType возвращает тип события.
+func (PIIAnonymized) EventType() string { return TypePIIAnonymized }
+
+// EventVersion возвращает версию контракта.
+func (PIIAnonymized) EventVersion() int { return 1 }
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// Subscribe подписывается на события типа T обычной подпиской NATS, без durable-консьюмера:
+// события, опубликованные, пока сервис остановлен, не доставляются, ошибка обработки только
+// логируется. Для событий, которые нельзя потерять, используется eventbus.Consumer и Handler.
+func Subscribe[T any, P interface {
+	*T
+	Event
+}](nc *nats.Conn, logger *zap.Logger, handle func(ctx context.Context, event P) error) (*nats.Subscription, error) {
+	subject := P(new(T)).EventType()
+	return nc.Subscribe(subject, func(msg *nats.Msg) {
+		event := P(new(T))
+		env, err := Unmarshal(msg.Data, event)
+		if err != nil {
+			logger.Error("Failed to decode "+subject+" event", zap.Error(err))
+			return
+		}
+		if err = handle(env.Context(context.Background()), event); err != nil {
+			logger.Error("Failed to process "+subject+" event",
+				zap.String("event_id", env.ID),
+				zap.String("correlation_id", env.CorrelationID),
+				zap.Error(err))
+		}
+	})
+}
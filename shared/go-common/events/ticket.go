@@ -0,0 +1,170 @@
+package events
+
+import "time"
+
+// Типы событий ticket-service о билетах, багаже и кассовых сменах.
+const (
+	TypeTicketSold      = "ticket.sold"
+	TypeTicketReturned  = "ticket.returned"
+	TypeTicketExchanged = "ticket.exchanged"
+	TypeBaggageSold     = "baggage.sold"
+	TypeBaggageReturned = "baggage.returned"
+	TypeShiftClosed     = "shift.closed"
+)
+
+// Ticket — билет в событиях. ПД пассажира, штрихкод и QR-код в событие не попадают.
+// Price — цена к оплате с учётом скидки DiscountAmount по ваучеру VoucherID. Components — составляющие
+// цены (тариф перевозчика и сборы), в сумме равные Price; у билетов, проданных до их учёта, отсутствуют.
+// DeviceReceipt — чек продажи, уже пробитый на мобильной ККТ водителя (только в ticket.sold).
+type Ticket struct {
+	CreatedAt           time.Time       `json:"created_at"`
+	UpdatedAt           time.Time       `json:"updated_at"`
+	ShiftID             *string         `json:"shift_id,omitempty"`
+	SoldBy              *string         `json:"sold_by,omitempty"`
+	SeatID              *string         `json:"seat_id,omitempty"`
+	RefundedAt          *time.Time      `json:"refunded_at,omitempty"`
+	RefundAmount        *float64        `json:"refund_amount,omitempty"`
+	RefundPenalty       *float64        `json:"refund_penalty,omitempty"`
+	RefundServiceFee    *float64        `json:"refund_service_fee,omitempty"`
+	RefundReason        *string         `json:"refund_reason,omitempty"`
+	RefundPolicyID      *string         `json:"refund_policy_id,omitempty"`
+	RefundPolicyVersion *int            `json:"refund_policy_version,omitempty"`
+	ExchangedFromID     *string         `json:"exchanged_from_id,omitempty"`
+	ExchangedToID       *string         `json:"exchanged_to_id,omitempty"`
+	ExchangeFee         *float64        `json:"exchange_fee,omitempty"`
+	NoShowAt            *time.Time      `json:"no_show_at,omitempty"`
+	VoucherID           *string         `json:"voucher_id,omitempty"`
+	DiscountAmount      *float64        `json:"discount_amount,omitempty"`
+	DeviceReceipt       *DeviceReceipt  `json:"device_receipt,omitempty"`
+	ID                  string          `json:"id"`
+	TripID              string          `json:"trip_id"`
+	Status              string          `json:"status"`
+	PaymentMethod       string          `json:"payment_method"`
+	PassengerCategory   string          `json:"passenger_category"`
+	Components          []FareComponent `json:"components,omitempty"`
+	Price               float64         `json:"price"`
+}
+
+// FareComponent — составляющая цены билета, отдельная позиция чека: Kind — "fare" (тариф перевозчика),
+// "station_fee" (сбор вокзала) или "insurance" (страховой сбор); VAT — ставка НДС в кодах ККТ.
+// RefundAmount — сколько из составляющей возвращено пассажиру (только в ticket.returned).
+type FareComponent struct {
+	RefundAmount *float64 `json:"refund_amount,omitempty"`
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	VAT          string   `json:"vat"`
+	Amount       float64  `json:"amount"`
+}
+
+// DeviceReceipt — фискальные реквизиты чека, пробитого на мобильной ККТ при продаже водителем в пути.
+// Такой чек не печатается повторно: fiscal-service только регистрирует его.
+type DeviceReceipt struct {
+	PrintedAt       time.Time `json:"printed_at"`
+	KKTSerial       string    `json:"kkt_serial"`
+	FiscalSign      string    `json:"fiscal_sign"`
+	FiscalDocNumber int       `json:"fiscal_doc_number"`
+}
+
+// TicketSold — билет продан (ticket.sold).
+type TicketSold struct {
+	Ticket
+}
+
+// EventType возвращает тип события.
+func (TicketSold) EventType() string { return TypeTicketSold }
+
+// EventVersion возвращает версию контракта.
+func (TicketSold) EventVersion() int { return 1 }
+
+// TicketReturned — билет возвращён (ticket.returned); сумма к возврату — RefundAmount.
+type TicketReturned struct {
+	Ticket
+}
+
+// EventType возвращает тип события.
+func (TicketReturned) EventType() string { return TypeTicketReturned }
+
+// EventVersion возвращает версию контракта.
+func (TicketReturned) EventVersion() int { return 1 }
+
+// TicketExchanged — билет обменян на другой рейс или место (ticket.exchanged). ID — новый билет,
+// FareDifference — разница тарифов (отрицательная — к возврату пассажиру). OldComponents и NewComponents —
+// составляющие цены исходного и нового билетов (пусто у билетов без составляющих): разница фискализируется
+// по каждой составляющей со своей ставкой НДС.
+type TicketExchanged struct {
+	ID              string          `json:"id"`
+	ExchangedFromID string          `json:"exchanged_from_id"`
+	TripID          string          `json:"trip_id"`
+	PaymentMethod   string          `json:"payment_method"`
+	OldComponents   []FareComponent `json:"old_components,omitempty"`
+	NewComponents   []FareComponent `json:"new_components,omitempty"`
+	OldPrice        float64         `json:"old_price"`
+	NewPrice        float64         `json:"new_price"`
+	FareDifference  float64         `json:"fare_difference"`
+	ExchangeFee     float64         `json:"exchange_fee"`
+}
+
+// EventType возвращает тип события.
+func (TicketExchanged) EventType() string { return TypeTicketExchanged }
+
+// EventVersion возвращает версию контракта.
+func (TicketExchanged) EventVersion() int { return 1 }
+
+// Baggage — багажная квитанция в событиях.
+type Baggage struct {
+	CreatedAt     time.Time  `json:"created_at"`
+	ShiftID       *string    `json:"shift_id,omitempty"`
+	RefundedAt    *time.Time `json:"refunded_at,omitempty"`
+	RefundAmount  *float64   `json:"refund_amount,omitempty"`
+	RefundPenalty *float64   `json:"refund_penalty,omitempty"`
+	ID            string     `json:"id"`
+	TicketID      string     `json:"ticket_id"`
+	TripID        string     `json:"trip_id"`
+	WeightClass   string     `json:"weight_class"`
+	PaymentMethod string     `json:"payment_method"`
+	Status        string     `json:"status"`
+	Tariff        float64    `json:"tariff"`
+	Price         float64    `json:"price"`
+	Pieces        int        `json:"pieces"`
+}
+
+// BaggageSold — оформлен провоз багажа (baggage.sold).
+type BaggageSold struct {
+	Baggage
+}
+
+// EventType возвращает тип события.
+func (BaggageSold) EventType() string { return TypeBaggageSold }
+
+// EventVersion возвращает версию контракта.
+func (BaggageSold) EventVersion() int { return 1 }
+
+// BaggageReturned — провоз багажа возвращён (baggage.returned).
+type BaggageReturned struct {
+	Baggage
+}
+
+// EventType возвращает тип события.
+func (BaggageReturned) EventType() string { return TypeBaggageReturned }
+
+// EventVersion возвращает версию контракта.
+func (BaggageReturned) EventVersion() int { return 1 }
+
+// ShiftClosed — кассовая смена закрыта (shift.closed); CashDifference — излишек (+) или недостача (−).
+type ShiftClosed struct {
+	ShiftID        string  `json:"shift_id"`
+	CashierID      string  `json:"cashier_id"`
+	WorkstationID  string  `json:"workstation_id"`
+	KKTSerial      string  `json:"kkt_serial"`
+	ExpectedCash   float64 `json:"expected_cash"`
+	CountedCash    float64 `json:"counted_cash"`
+	CashDifference float64 `json:"cash_difference"`
+	SalesAmount    float64 `json:"sales_amount"`
+	RefundsAmount  float64 `json:"refunds_amount"`
+}
+
+// EventType возвращает тип события.
+func (ShiftClosed) EventType() string { return TypeShiftClosed }
+
+// EventVersion возвращает версию контракта.
+func (ShiftClosed) EventVersion() int { return 1 }
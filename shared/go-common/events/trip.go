@@ -0,0 +1,71 @@
+package events
+
+import "time"
+
+// Типы событий schedule-service о рейсах.
+const (
+	TypeTripCreated       = "trip.created"
+	TypeTripUpdated       = "trip.updated"
+	TypeTripStatusChanged = "trip.status_changed"
+)
+
+// Trip — рейс в событиях. Date — дата отправления (YYYY-MM-DD). Platform, BusID и DriverID — основной
+// автобус рейса, Vehicles — автобусы, добавленные к рейсу сверх основного.
+type Trip struct {
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+	DepartureActual *time.Time    `json:"departure_actual,omitempty"`
+	ArrivalActual   *time.Time    `json:"arrival_actual,omitempty"`
+	Platform        *string       `json:"platform,omitempty"`
+	BusID           *string       `json:"bus_id,omitempty"`
+	DriverID        *string       `json:"driver_id,omitempty"`
+	ID              string        `json:"id"`
+	ScheduleID      string        `json:"schedule_id"`
+	Date            string        `json:"date"`
+	Status          string        `json:"status"`
+	Vehicles        []TripVehicle `json:"vehicles,omitempty"`
+	DelayMinutes    int           `json:"delay_minutes"`
+}
+
+// TripVehicle — дополнительный автобус рейса. Number — номер автобуса на рейсе (основной — 1,
+// дополнительные — с 2); Platform nil — посадка с перрона рейса.
+type TripVehicle struct {
+	DriverID *string `json:"driver_id,omitempty"`
+	Platform *string `json:"platform,omitempty"`
+	ID       string  `json:"id"`
+	BusID    string  `json:"bus_id"`
+	Number   int     `json:"number"`
+}
+
+// TripCreated — создан рейс (trip.created).
+type TripCreated struct {
+	Trip
+}
+
+// EventType возвращает тип события.
+func (TripCreated) EventType() string { return TypeTripCreated }
+
+// EventVersion возвращает версию контракта.
+func (TripCreated) EventVersion() int { return 1 }
+
+// TripUpdated — изменены данные рейса: платформа, автобус, водитель или состав автобусов (trip.updated).
+type TripUpdated struct {
+	Trip
+}
+
+// EventType возвращает тип события.
+func (TripUpdated) EventType() string { return TypeTripUpdated }
+
+// EventVersion возвращает версию контракта.
+func (TripUpdated) EventVersion() int { return 1 }
+
+// TripStatusChanged — изменены статус или задержка рейса (trip.status_changed).
+type TripStatusChanged struct {
+	Trip
+}
+
+// EventType возвращает тип события.
+func (TripStatusChanged) EventType() string { return TypeTripStatusChanged }
+
+// EventVersion возвращает версию контракта.
+func (TripStatusChanged) EventVersion() int { return 1 }
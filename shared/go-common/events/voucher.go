@@ -0,0 +1,18 @@
+package events
+
+// TypeVoucherIssued — пассажиру выпущен компенсационный ваучер.
+const TypeVoucherIssued = "voucher.issued"
+
+// VoucherIssued — компенсационный ваучер за рейс, отменённый перевозчиком (voucher.issued), команда
+// notify-service отправить код пассажиру. Как и в waitlist.offered, ПД и кода в событии нет: контакт
+// пассажира, код, сумму и срок notify-service получает по VoucherID во внутреннем API ticket-service.
+// Версия 2: из версии 1 удалены контакт пассажира, код и условия ваучера.
+type VoucherIssued struct {
+	VoucherID string `json:"voucher_id"`
+}
+
+// EventType возвращает тип события.
+func (VoucherIssued) EventType() string { return TypeVoucherIssued }
+
+// EventVersion возвращает версию контракта.
+func (VoucherIssued) EventVersion() int { return 2 }
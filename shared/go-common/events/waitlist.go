@@ -0,0 +1,18 @@
+package events
+
+// TypeWaitlistOffered — пассажиру из листа ожидания предложено освободившееся место.
+const TypeWaitlistOffered = "waitlist.offered"
+
+// WaitlistOffered — предложение места из листа ожидания (waitlist.offered), команда notify-service
+// отправить его пассажиру. ПД и ссылки на выкуп в событии нет: контакт, ссылку и условия предложения
+// notify-service получает по EntryID во внутреннем API ticket-service, пока предложение действует.
+// Версия 2: из версии 1 удалены контакт пассажира и условия предложения.
+type WaitlistOffered struct {
+	EntryID string `json:"entry_id"`
+}
+
+// EventType возвращает тип события.
+func (WaitlistOffered) EventType() string { return TypeWaitlistOffered }
+
+// EventVersion возвращает версию контракта.
+func (WaitlistOffered) EventVersion() int { return 2 }
@@ -0,0 +1,186 @@
+// Package idempotency — поддержка заголовка Idempotency-Key для операций, которые нельзя
+// выполнять дважды (продажа, возврат, инициализация платежа).
+//
+// Клиент (кассовый терминал) передаёт уникальный ключ операции и повторяет запрос с тем же ключом
+// после тайм-аута. Первый запрос выполняется, его ответ сохраняется на Config.TTL; повтор получает
+// сохранённый ответ с заголовком Idempotent-Replayed без повторного выполнения. Ключ действует
+// в пределах маршрута и пользователя; повтор ключа с другим телом запроса — конфликт (422).
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Заголовки HTTP.
+const (
+	// HeaderKey — ключ идемпотентности запроса.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed — признак ответа, возвращённого из сохранённого.
+	HeaderReplayed = "Idempotent-Replayed"
+)
+
+// maxKeyLength — наибольшая длина ключа.
+const maxKeyLength = 255
+
+var (
+	// ErrKeyRequired возвращается, если ключ обязателен, но не передан.
+	ErrKeyRequired = errors.New("missing Idempotency-Key header")
+	// ErrKeyTooLong возвращается для ключа длиннее 255 символов.
+	ErrKeyTooLong = errors.New("idempotency key is too long")
+	// ErrKeyReused возвращается, если ключ уже использован с другим запросом.
+	ErrKeyReused = errors.New("idempotency key was already used with a different request")
+	// ErrInProgress возвращается, пока запрос с тем же ключом ещё выполняется.
+	ErrInProgress = errors.New("request with this Idempotency-Key is in progress")
+	// ErrUnavailable возвращается, если хранилище ключей недоступно: выполнять операцию без
+	// защиты от повтора нельзя.
+	ErrUnavailable = errors.New("idempotency store is unavailable")
+)
+
+// Config — настройки middleware. TTL — срок хранения ответа; LockTimeout — сколько ключ занят
+// выполняемым запросом (после сбоя обработчика ключ освобождается по его истечении);
+// Required — отклонять запросы без ключа.
+type Config struct {
+	Logger      *zap.Logger
+	TTL         time.Duration
+	LockTimeout time.Duration
+	Required    bool
+}
+
+// Middleware возвращает gin middleware идемпотентности. Запросы без ключа выполняются как обычно
+// (если ключ не обязателен). Ответы 5xx не сохраняются: ключ освобождается, и повтор выполнит
+// операцию заново.
+func Middleware(store Store, cfg Config) gin.HandlerFunc {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderKey)
+		if key == "" {
+			if cfg.Required {
+				abort(c, http.StatusBadRequest, ErrKeyRequired)
+				return
+			}
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLength {
+			abort(c, http.StatusBadRequest, ErrKeyTooLong)
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			abort(c, http.StatusBadRequest, err)
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := c.Request.Method + " " + c.FullPath() + " " + requestUser(c)
+		now := time.Now()
+		record := &Record{
+			ID:          hash(scope, key),
+			Key:         key,
+			Scope:       scope,
+			Fingerprint: hash(c.Request.URL.RequestURI(), string(body)),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(cfg.LockTimeout),
+		}
+
+		ctx := c.Request.Context()
+		existing, err := store.Reserve(ctx, record)
+		if err != nil {
+			logger.Error("Failed to reserve idempotency key", zap.String("scope", scope), zap.Error(err))
+			abort(c, http.StatusServiceUnavailable, ErrUnavailable)
+			return
+		}
+		if existing != nil {
+			replay(c, existing, record.Fingerprint)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Клиент мог отключиться по тайм-ауту — результат всё равно нужно сохранить для повтора
+		ctx = context.WithoutCancel(ctx)
+		if recorder.Status() >= http.StatusInternalServerError {
+			if err = store.Release(ctx, record.ID); err != nil {
+				logger.Error("Failed to release idempotency key", zap.String("scope", scope), zap.Error(err))
+			}
+			return
+		}
+		record.StatusCode = recorder.Status()
+		record.ContentType = recorder.Header().Get("Content-Type")
+		record.Body = recorder.body.Bytes()
+		record.ExpiresAt = time.Now().Add(cfg.TTL)
+		if err = store.Complete(ctx, record); err != nil {
+			logger.Error("Failed to store idempotent response", zap.String("scope", scope), zap.Error(err))
+		}
+	}
+}
+
+// replay отвечает на повтор запроса: сохранённым ответом, конфликтом или «выполняется».
+func replay(c *gin.Context, existing *Record, fingerprint string) {
+	switch {
+	case existing.Fingerprint != fingerprint:
+		abort(c, http.StatusUnprocessableEntity, ErrKeyReused)
+	case !existing.Completed:
+		c.Header("Retry-After", "1")
+		abort(c, http.StatusConflict, ErrInProgress)
+	default:
+		c.Header(HeaderReplayed, "true")
+		c.Data(existing.StatusCode, existing.ContentType, existing.Body)
+		c.Abort()
+	}
+}
+
+// requestUser возвращает пользователя из контекста (JWT middleware) или заголовка X-User-ID.
+func requestUser(c *gin.Context) string {
+	if userID := c.GetString("user_id"); userID != "" {
+		return userID
+	}
+	return c.GetHeader("X-User-ID")
+}
+
+func abort(c *gin.Context, status int, err error) {
+	c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+}
+
+// hash возвращает SHA-256 частей в hex; части разделяются нулевым байтом.
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder копирует тело ответа для сохранения.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,206 @@
+package idempotency
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// memStore — хранилище ключей в памяти с той же семантикой, что у GormStore.
+type memStore struct {
+	records map[string]*Record
+	mu      sync.Mutex
+}
+
+func newMemStore() *memStore {
+	return &memStore{records: make(map[string]*Record)}
+}
+
+func (s *memStore) Reserve(_ context.Context, record *Record) (*Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.records[record.ID]; ok && !existing.ExpiresAt.Before(time.Now()) {
+		found := *existing
+		return &found, nil
+	}
+	reserved := *record
+	s.records[record.ID] = &reserved
+	return nil, nil
+}
+
+func (s *memStore) Complete(_ context.Context, record *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	completed := *record
+	completed.Completed = true
+	s.records[record.ID] = &completed
+	return nil
+}
+
+func (s *memStore) Release(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r, ok := s.records[id]; ok && !r.Completed {
+		delete(s.records, id)
+	}
+	return nil
+}
+
+func (s *memStore) DeleteExpired(_ context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, r := range s.records {
+		if r.ExpiresAt.Before(now) {
+			delete(s.records, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// newRouter возвращает маршрут POST /tickets с middleware и обработчиком handler.
+func newRouter(store Store, handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/tickets", Middleware(store, Config{TTL: time.Hour, LockTimeout: time.Minute}), handler)
+	return r
+}
+
+func doRequest(r http.Handler, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tickets", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-User-ID", "cashier-1")
+	if key != "" {
+		req.Header.Set(HeaderKey, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestReplayStoredResponse(t *testing.T) {
+	calls := 0
+	r := newRouter(newMemStore(), func(c *gin.Context) {
+		calls++
+		body, _ := io.ReadAll(c.Request.Body)
+		c.JSON(http.StatusCreated, gin.H{"call": calls, "request": string(body)})
+	})
+
+	first := doRequest(r, "key-1", `{"trip_id":"t1"}`)
+	second := doRequest(r, "key-1", `{"trip_id":"t1"}`)
+
+	if calls != 1 {
+		t.Fatalf("handler called %d times, want 1", calls)
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(HeaderReplayed) != "true" {
+		t.Errorf("replay without %s header", HeaderReplayed)
+	}
+	if first.Header().Get(HeaderReplayed) != "" {
+		t.Errorf("first response has %s header", HeaderReplayed)
+	}
+	if got := second.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("replay Content-Type = %q, want application/json", got)
+	}
+}
+
+func TestKeyReusedWithDifferentBody(t *testing.T) {
+	calls := 0
+	r := newRouter(newMemStore(), func(c *gin.Context) {
+		calls++
+		c.Status(http.StatusCreated)
+	})
+
+	doRequest(r, "key-1", `{"trip_id":"t1"}`)
+	w := doRequest(r, "key-1", `{"trip_id":"t2"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestInProgress(t *testing.T) {
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	r := newRouter(newMemStore(), func(c *gin.Context) {
+		close(started)
+		<-finish
+		c.Status(http.StatusCreated)
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- doRequest(r, "key-1", `{}`) }()
+	<-started
+
+	w := doRequest(r, "key-1", `{}`)
+	close(finish)
+	first := <-done
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("in-progress response without Retry-After")
+	}
+	if first.Code != http.StatusCreated {
+		t.Errorf("first request status = %d, want %d", first.Code, http.StatusCreated)
+	}
+}
+
+func TestReleaseOnServerError(t *testing.T) {
+	store := newMemStore()
+	calls := 0
+	r := newRouter(store, func(c *gin.Context) {
+		calls++
+		if calls == 1 {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.Status(http.StatusCreated)
+	})
+
+	if w := doRequest(r, "key-1", `{}`); w.Code != http.StatusInternalServerError {
+		t.Fatalf("first status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if len(store.records) != 0 {
+		t.Fatalf("key is not released after 5xx: %d records", len(store.records))
+	}
+	if w := doRequest(r, "key-1", `{}`); w.Code != http.StatusCreated || w.Header().Get(HeaderReplayed) != "" {
+		t.Errorf("retry = %d (replayed %q), want executed %d", w.Code, w.Header().Get(HeaderReplayed), http.StatusCreated)
+	}
+	if calls != 2 {
+		t.Errorf("handler called %d times, want 2", calls)
+	}
+}
+
+func TestKeyRules(t *testing.T) {
+	r := newRouter(newMemStore(), func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+	if w := doRequest(r, "", `{}`); w.Code != http.StatusCreated {
+		t.Errorf("without key: status = %d, want %d", w.Code, http.StatusCreated)
+	}
+	if w := doRequest(r, strings.Repeat("k", maxKeyLength+1), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	gin.SetMode(gin.TestMode)
+	required := gin.New()
+	required.POST("/tickets", Middleware(newMemStore(), Config{TTL: time.Hour, LockTimeout: time.Minute, Required: true}),
+		func(c *gin.Context) { c.Status(http.StatusCreated) })
+	if w := doRequest(required, "", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("required key missing: status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,110 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Record — ключ идемпотентности и сохранённый ответ. ID — хеш области (метод, маршрут, пользователь)
+// и ключа; Fingerprint — хеш пути и тела запроса. Пока запрос выполняется, Completed = false,
+// а ExpiresAt ограничивает время блокировки ключа.
+type Record struct {
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `gorm:"index" json:"expires_at"`
+	ID          string    `gorm:"type:varchar(64);primaryKey" json:"id"`
+	Key         string    `gorm:"type:varchar(255);not null" json:"key"`
+	Scope       string    `gorm:"type:varchar(255);not null" json:"scope"`
+	Fingerprint string    `gorm:"type:varchar(64);not null" json:"fingerprint"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"`
+	Body        []byte    `gorm:"type:bytea" json:"-"`
+	StatusCode  int       `json:"status_code"`
+	Completed   bool      `gorm:"not null;default:false" json:"completed"`
+}
+
+// TableName возвращает имя таблицы для GORM (Record).
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// Store — хранилище ключей идемпотентности.
+type Store interface {
+	// Reserve занимает ключ записью record. Если ключ уже занят и не просрочен, возвращает
+	// существующую запись, иначе — nil.
+	Reserve(ctx context.Context, record *Record) (*Record, error)
+	// Complete сохраняет ответ выполненного запроса.
+	Complete(ctx context.Context, record *Record) error
+	// Release освобождает ключ невыполненного запроса.
+	Release(ctx context.Context, id string) error
+	// DeleteExpired удаляет просроченные ключи и возвращает их число.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// GormStore — хранилище ключей в таблице idempotency_keys (модель Record мигрирует сервис).
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore создаёт хранилище ключей в БД сервиса.
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// reserveAttempts — сколько раз Reserve пробует занять ключ, который между вставкой и чтением
+// освобождается другим запросом.
+const reserveAttempts = 3
+
+// Reserve занимает ключ вставкой с ON CONFLICT DO NOTHING: из параллельных запросов
+// с одним ключом выполняется только один. Просроченная запись предварительно удаляется.
+// Если занявший ключ запрос успел его освободить (ответ 5xx), вставка повторяется.
+func (s *GormStore) Reserve(ctx context.Context, record *Record) (*Record, error) {
+	db := s.db.WithContext(ctx)
+	for attempt := 1; ; attempt++ {
+		if err := db.Where("id = ? AND expires_at < ?", record.ID, time.Now()).Delete(&Record{}).Error; err != nil {
+			return nil, err
+		}
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			return nil, nil
+		}
+
+		var existing Record
+		err := db.First(&existing, "id = ?", record.ID).Error
+		if err == nil {
+			return &existing, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) || attempt == reserveAttempts {
+			return nil, err
+		}
+	}
+}
+
+// Complete сохраняет ответ и продлевает хранение ключа до record.ExpiresAt.
+func (s *GormStore) Complete(ctx context.Context, record *Record) error {
+	return s.db.WithContext(ctx).Model(&Record{}).
+		Where("id = ?", record.ID).
+		Updates(map[string]interface{}{
+			"status_code":  record.StatusCode,
+			"content_type": record.ContentType,
+			"body":         record.Body,
+			"completed":    true,
+			"expires_at":   record.ExpiresAt,
+		}).Error
+}
+
+// Release удаляет незавершённую запись ключа.
+func (s *GormStore) Release(ctx context.Context, id string) error {
+	return s.db.WithContext(ctx).Where("id = ? AND completed = ?", id, false).Delete(&Record{}).Error
+}
+
+// DeleteExpired удаляет ключи с истёкшим сроком хранения.
+func (s *GormStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res := s.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&Record{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,74 @@
+// Package outbox — транзакционная отправка событий в NATS (transactional outbox).
+//
+// Событие записывается в таблицу outbox_messages в той же транзакции, что и изменение состояния
+// (транзакция передаётся через context, см. пакет dbtx). Relay сервиса доставляет записанные события
+// в NATS с повторами и экспоненциальной задержкой. События одного агрегата (тип и ID сущности)
+// доставляются строго по порядку записи: следующее ждёт, пока не доставлено предыдущее.
+// Доставка «хотя бы один раз»: получатель должен быть готов к повтору (заголовок Nats-Msg-Id).
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/vokzal-tech/go-common/dbtx"
+	"github.com/vokzal-tech/go-common/events"
+)
+
+// Message — событие в outbox. Таблица общая для сервисов БД, Service отделяет их события.
+// Пока событие не доставлено, PublishedAt пуст, а NextAttemptAt — время следующей попытки.
+type Message struct {
+	CreatedAt     time.Time  `json:"created_at"`
+	NextAttemptAt time.Time  `gorm:"not null" json:"next_attempt_at"`
+	PublishedAt   *time.Time `gorm:"index" json:"published_at,omitempty"`
+	LastError     *string    `gorm:"type:text" json:"last_error,omitempty"`
+	Service       string     `gorm:"type:varchar(30);not null;index:idx_outbox_pending,priority:1,where:published_at IS NULL" json:"service"`
+	AggregateType string     `gorm:"type:varchar(50);not null;index:idx_outbox_pending,priority:2" json:"aggregate_type"`
+	AggregateID   string     `gorm:"type:varchar(64);not null;index:idx_outbox_pending,priority:3" json:"aggregate_id"`
+	Subject       string     `gorm:"type:varchar(100);not null" json:"subject"`
+	Payload       string     `gorm:"type:jsonb;not null" json:"payload"`
+	ID            int64      `gorm:"primaryKey;autoIncrement;index:idx_outbox_pending,priority:4" json:"id"`
+	Attempts      int        `gorm:"not null;default:0" json:"attempts"`
+}
+
+// TableName возвращает имя таблицы для GORM (Message).
+func (Message) TableName() string {
+	return "outbox_messages"
+}
+
+// Outbox записывает события сервиса.
+type Outbox struct {
+	db      *gorm.DB
+	service string
+}
+
+// New создаёт outbox сервиса service (имя сервиса в outbox_messages.service).
+func New(db *gorm.DB, service string) *Outbox {
+	return &Outbox{db: db, service: service}
+}
+
+// Publish записывает событие по агрегату aggregateType/aggregateID в конверте events.Envelope
+// (subject — тип события). Внутри транзакции (dbtx) событие фиксируется вместе с ней, иначе —
+// отдельной записью.
+func (o *Outbox) Publish(ctx context.Context, aggregateType, aggregateID string, event events.Event) error {
+	subject := event.EventType()
+	data, err := events.Encode(ctx, o.service, event)
+	if err != nil {
+		return err
+	}
+	msg := &Message{
+		Service:       o.service,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Subject:       subject,
+		Payload:       string(data),
+		NextAttemptAt: time.Now(),
+	}
+	if err = dbtx.From(ctx, o.db).Create(msg).Error; err != nil {
+		return fmt.Errorf("failed to write %s event to outbox: %w", subject, err)
+	}
+	return nil
+}
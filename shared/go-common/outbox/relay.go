@@ -0,0 +1,208 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Publisher — отправка сообщений в NATS: *nats.Conn (core NATS) или eventbus.Publisher
+// (JetStream с подтверждением записи в поток).
+type Publisher interface {
+	PublishMsg(msg *nats.Msg) error
+	FlushTimeout(timeout time.Duration) error
+}
+
+// RelayConfig — настройки доставки. Outbox проверяется раз в Interval, за проход отправляется
+// до BatchSize событий; задержка повтора растёт от Interval вдвое до MaxBackoff. Доставленные
+// события хранятся Retention, FlushTimeout ограничивает ожидание подтверждения NATS.
+type RelayConfig struct {
+	Service      string
+	Interval     time.Duration
+	MaxBackoff   time.Duration
+	Retention    time.Duration
+	FlushTimeout time.Duration
+	BatchSize    int
+}
+
+// cleanupInterval — как часто удаляются доставленные события.
+const cleanupInterval = time.Hour
+
+// pendingSQL выбирает недоставленные события, первые в очереди своего агрегата. Строки блокируются
+// (SKIP LOCKED), поэтому несколько экземпляров сервиса не отправляют одно событие одновременно,
+// а следующее событие агрегата не выбирается, пока предыдущее не доставлено.
+const pendingSQL = `
+SELECT m.* FROM outbox_messages m
+WHERE m.service = ? AND m.published_at IS NULL AND m.next_attempt_at <= ?
+  AND NOT EXISTS (
+    SELECT 1 FROM outbox_messages p
+    WHERE p.service = m.service AND p.aggregate_type = m.aggregate_type AND p.aggregate_id = m.aggregate_id
+      AND p.published_at IS NULL AND p.id < m.id)
+ORDER BY m.id
+LIMIT ?
+FOR UPDATE OF m SKIP LOCKED`
+
+// Relay доставляет события outbox сервиса в NATS.
+type Relay struct {
+	db        *gorm.DB
+	publisher Publisher
+	logger    *zap.Logger
+	cfg       RelayConfig
+}
+
+// NewRelay создаёт Relay. Нулевые значения cfg заменяются значениями по умолчанию.
+func NewRelay(db *gorm.DB, publisher Publisher, cfg RelayConfig, logger *zap.Logger) *Relay {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.MaxBackoff < cfg.Interval {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.FlushTimeout <= 0 {
+		cfg.FlushTimeout = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Relay{db: db, publisher: publisher, cfg: cfg, logger: logger}
+}
+
+// Run доставляет события до отмены ctx: раз в Interval выбирает очередь, пока в ней есть что
+// отправить, и раз в час удаляет доставленные события старше Retention.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.Interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				sent, err := r.RelayOnce(ctx)
+				if err != nil {
+					r.logger.Error("Outbox relay failed", zap.String("service", r.cfg.Service), zap.Error(err))
+					break
+				}
+				if sent == 0 {
+					break
+				}
+			}
+		case <-cleanup.C:
+			if r.cfg.Retention <= 0 {
+				continue
+			}
+			deleted, err := r.DeletePublished(ctx, time.Now().Add(-r.cfg.Retention))
+			if err != nil {
+				r.logger.Error("Failed to delete published outbox messages", zap.Error(err))
+			} else if deleted > 0 {
+				r.logger.Info("Published outbox messages deleted", zap.Int64("count", deleted))
+			}
+		}
+	}
+}
+
+// RelayOnce отправляет одну пачку событий и возвращает число доставленных. Неотправленные события
+// откладываются с экспоненциальной задержкой и блокируют следующие события своего агрегата.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	sent := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		var messages []Message
+		if err := tx.Raw(pendingSQL, r.cfg.Service, now, r.cfg.BatchSize).Scan(&messages).Error; err != nil {
+			return fmt.Errorf("failed to select outbox messages: %w", err)
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+
+		var published []int64
+		for i := range messages {
+			msg := &messages[i]
+			if err := r.publisher.PublishMsg(natsMessage(msg)); err != nil {
+				if updateErr := r.postpone(tx, msg, err, now); updateErr != nil {
+					return updateErr
+				}
+				continue
+			}
+			published = append(published, msg.ID)
+		}
+		if len(published) == 0 {
+			return nil
+		}
+		// Событие считается доставленным, когда NATS подтвердил приём всей пачки
+		if err := r.publisher.FlushTimeout(r.cfg.FlushTimeout); err != nil {
+			for i := range messages {
+				if updateErr := r.postpone(tx, &messages[i], err, now); updateErr != nil {
+					return updateErr
+				}
+			}
+			return nil
+		}
+		if err := tx.Model(&Message{}).Where("id IN ?", published).
+			Updates(map[string]interface{}{"published_at": time.Now(), "last_error": nil}).Error; err != nil {
+			return fmt.Errorf("failed to mark outbox messages published: %w", err)
+		}
+		sent = len(published)
+		return nil
+	})
+	return sent, err
+}
+
+// DeletePublished удаляет события сервиса, доставленные раньше before, и возвращает их число.
+func (r *Relay) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("service = ? AND published_at < ?", r.cfg.Service, before).
+		Delete(&Message{})
+	return res.RowsAffected, res.Error
+}
+
+// postpone откладывает событие после неудачной отправки.
+func (r *Relay) postpone(tx *gorm.DB, msg *Message, cause error, now time.Time) error {
+	attempts := msg.Attempts + 1
+	r.logger.Warn("Failed to publish outbox message",
+		zap.Int64("id", msg.ID),
+		zap.String("subject", msg.Subject),
+		zap.Int("attempts", attempts),
+		zap.Error(cause))
+
+	lastError := cause.Error()
+	err := tx.Model(&Message{}).Where("id = ?", msg.ID).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": now.Add(r.backoff(attempts)),
+		"last_error":      lastError,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to postpone outbox message %d: %w", msg.ID, errors.Join(err, cause))
+	}
+	return nil
+}
+
+// backoff возвращает задержку перед попыткой attempts+1: Interval·2^(attempts−1), не более MaxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.cfg.Interval
+	for i := 1; i < attempts && delay < r.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.cfg.MaxBackoff)
+}
+
+// natsMessage формирует сообщение NATS. Nats-Msg-Id позволяет получателю (и JetStream)
+// отбросить повторную доставку события.
+func natsMessage(msg *Message) *nats.Msg {
+	m := nats.NewMsg(msg.Subject)
+	m.Data = []byte(msg.Payload)
+	m.Header.Set(nats.MsgIdHdr, msg.Service+"-"+strconv.FormatInt(msg.ID, 10))
+	return m
+}
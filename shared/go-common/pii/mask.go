@@ -0,0 +1,49 @@
+package pii
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MaskName оставляет фамилию и инициалы: "Иванов Иван Иванович" → "Иванов И. И.".
+func MaskName(name string) string {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		return ""
+	}
+	masked := []string{parts[0]}
+	for _, p := range parts[1:] {
+		r, _ := utf8.DecodeRuneInString(p)
+		masked = append(masked, string(r)+".")
+	}
+	return strings.Join(masked, " ")
+}
+
+// MaskDocument оставляет последние 4 символа номера документа: "4500 123456" → "******3456".
+func MaskDocument(doc string) string {
+	return maskTail(strings.ReplaceAll(doc, " ", ""), 4)
+}
+
+// MaskPhone оставляет последние 2 цифры телефона: "+79001234567" → "*********67".
+func MaskPhone(phone string) string {
+	return maskTail(phone, 2)
+}
+
+// MaskEmail оставляет первую букву имени и домен: "ivan@example.com" → "i***@example.com".
+func MaskEmail(email string) string {
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || local == "" {
+		return maskTail(email, 0)
+	}
+	r, _ := utf8.DecodeRuneInString(local)
+	return string(r) + "***@" + domain
+}
+
+// maskTail заменяет звёздочками все символы, кроме последних keep.
+func maskTail(value string, keep int) string {
+	runes := []rune(value)
+	if len(runes) <= keep {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
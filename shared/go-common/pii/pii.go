@@ -0,0 +1,174 @@
+// Package pii — шифрование персональных данных на уровне полей, слепые индексы и маскирование.
+//
+// Зашифрованное значение имеет вид "enc:v1:<kid>:<base64(nonce|ciphertext)>" (AES-256-GCM).
+// Ключ kid хранится в значении, поэтому после ротации старые записи читаются прежним ключом,
+// пока фоновая задача не перешифрует их активным. Слепой индекс — HMAC-SHA256 нормализованного
+// значения отдельным ключом: позволяет искать по точному совпадению без расшифровки.
+package pii
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+const prefix = "enc:v1:"
+
+var (
+	// ErrUnknownKey возвращается, если значение зашифровано ключом, которого нет в наборе.
+	ErrUnknownKey = errors.New("unknown PII encryption key")
+	// ErrMalformed возвращается для повреждённого зашифрованного значения.
+	ErrMalformed = errors.New("malformed encrypted PII value")
+)
+
+// Keyring — набор ключей шифрования ПД: активный ключ для записи и прежние для чтения.
+type Keyring struct {
+	aeads     map[string]cipher.AEAD
+	indexer   *Indexer
+	activeKID string
+}
+
+// Indexer вычисляет слепые индексы. Сервисы, которым нужен только поиск по ПД другого сервиса
+// (например, удаление уведомлений по телефону пассажира), используют его без ключей шифрования.
+type Indexer struct {
+	key []byte
+}
+
+// NewIndexer создаёт вычислитель слепых индексов. indexKey — ключ HMAC в base64 (не короче 32 байт).
+func NewIndexer(indexKey string) (*Indexer, error) {
+	key, err := base64.StdEncoding.DecodeString(indexKey)
+	if err != nil || len(key) < 32 {
+		return nil, errors.New("PII index key must be at least 32 bytes in base64")
+	}
+	return &Indexer{key: key}, nil
+}
+
+// BlindIndex возвращает слепой индекс нормализованного значения (пусто для пустого значения).
+func (x *Indexer) BlindIndex(value string) string {
+	normalized := Normalize(value)
+	if normalized == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, x.key)
+	mac.Write([]byte(normalized))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewKeyring создаёт набор ключей. keys — kid → ключ AES-256 в base64, indexKey — ключ HMAC в base64.
+func NewKeyring(keys map[string]string, activeKID, indexKey string) (*Keyring, error) {
+	if _, ok := keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active PII key %q is not configured", activeKID)
+	}
+	indexer, err := NewIndexer(indexKey)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Keyring{
+		aeads:     make(map[string]cipher.AEAD, len(keys)),
+		indexer:   indexer,
+		activeKID: activeKID,
+	}
+	for kid, encoded := range keys {
+		if kid == "" || strings.Contains(kid, ":") {
+			return nil, fmt.Errorf("invalid PII key id %q", kid)
+		}
+		raw, decodeErr := base64.StdEncoding.DecodeString(encoded)
+		if decodeErr != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("PII key %q must be 32 bytes in base64", kid)
+		}
+		block, blockErr := aes.NewCipher(raw)
+		if blockErr != nil {
+			return nil, fmt.Errorf("PII key %q: %w", kid, blockErr)
+		}
+		aead, gcmErr := cipher.NewGCM(block)
+		if gcmErr != nil {
+			return nil, fmt.Errorf("PII key %q: %w", kid, gcmErr)
+		}
+		k.aeads[kid] = aead
+	}
+	return k, nil
+}
+
+// ActiveKeyID возвращает идентификатор ключа, которым шифруются новые значения.
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeKID
+}
+
+// Encrypt шифрует значение активным ключом.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	aead := k.aeads[k.activeKID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.activeKID))
+	return prefix + k.activeKID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt расшифровывает значение. Незашифрованные значения (записанные до включения шифрования)
+// возвращаются как есть.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	kid, payload, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok {
+		return "", ErrMalformed
+	}
+	aead, ok := k.aeads[kid]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, kid)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return "", ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(kid))
+	if err != nil {
+		return "", ErrMalformed
+	}
+	return string(plaintext), nil
+}
+
+// KeyID возвращает идентификатор ключа зашифрованного значения (пусто для открытого текста).
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	kid, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return kid
+}
+
+// IsEncrypted сообщает, зашифровано ли значение.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// BlindIndex возвращает слепой индекс значения ключом индексов набора.
+func (k *Keyring) BlindIndex(value string) string {
+	return k.indexer.BlindIndex(value)
+}
+
+// Normalize приводит значение к виду для слепого индекса: нижний регистр, ё → е, только буквы, цифры и '@', '.'.
+// Так "4500 123456" и "4500123456", "+7 (900) 123-45-67" и "79001234567" дают один индекс.
+func Normalize(value string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(value) {
+		switch {
+		case r == 'ё':
+			b.WriteRune('е')
+		case unicode.IsLetter(r), unicode.IsDigit(r), r == '@', r == '.':
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
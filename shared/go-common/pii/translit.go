@@ -0,0 +1,112 @@
+package pii
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode"
+)
+
+// cyrillicToLatin — транслитерация кириллицы; ь и ъ опускаются.
+var cyrillicToLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+	'з': "z", 'и': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+	'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+	'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+	'я': "ya", 'і': "i", 'ї': "i", 'є': "e", 'ґ': "g",
+}
+
+// latinFolding сводит варианты латинского написания к одному: Alexey и Aleksei, Tsoy и Coi,
+// Shchukin и Schukin. Замены применяются за один проход слева направо.
+var latinFolding = strings.NewReplacer(
+	"shch", "sh", "sch", "sh", "tch", "ch", "kh", "h", "ts", "c", "tz", "c",
+	"ph", "f", "ck", "k", "x", "ks", "w", "v", "q", "k", "y", "i", "j", "i",
+)
+
+// nameTokenLen — длина токена имени в hex (64 бита HMAC): коллизии редки, а по токену
+// нельзя восстановить слово без ключа индексов.
+const nameTokenLen = 16
+
+// FoldName приводит ФИО к виду для нечёткого поиска: транслитерация в латиницу, свёртка
+// вариантов написания и удвоенных букв. Возвращает слова в свёрнутом виде:
+// "Юлия Наталья" и "Iuliia Natalya" дают ["iulia", "natalia"].
+func FoldName(name string) []string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z':
+			b.WriteRune(r)
+		case cyrillicToLatin[r] != "" || r == 'ь' || r == 'ъ':
+			b.WriteString(cyrillicToLatin[r])
+		case unicode.IsLetter(r):
+			// Прочие буквы (диакритика и т. п.) в токены не попадают
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	folded := make([]string, 0, len(words))
+	for _, w := range words {
+		w = squeeze(latinFolding.Replace(w))
+		// "Yevgeniy" → "ievgeni", а "Евгений" → "evgeni"
+		if strings.HasPrefix(w, "ie") {
+			w = w[1:]
+		}
+		if w != "" {
+			folded = append(folded, w)
+		}
+	}
+	return folded
+}
+
+// NameTrigrams возвращает уникальные триграммы свёрнутых слов ФИО. Слово дополняется
+// пробелами, как в pg_trgm, чтобы начало и конец слова весили больше середины.
+func NameTrigrams(name string) []string {
+	seen := map[string]bool{}
+	var trigrams []string
+	for _, w := range FoldName(name) {
+		padded := "  " + w + " "
+		for i := 0; i+3 <= len(padded); i++ {
+			t := padded[i : i+3]
+			if !seen[t] {
+				seen[t] = true
+				trigrams = append(trigrams, t)
+			}
+		}
+	}
+	return trigrams
+}
+
+// NameTokens возвращает слепые токены ФИО — укороченные HMAC триграмм. По совпадению токенов
+// ищутся похожие имена без расшифровки: "Иванов" находит "Ivanov" и "Иваннов".
+func (x *Indexer) NameTokens(name string) []string {
+	trigrams := NameTrigrams(name)
+	tokens := make([]string, 0, len(trigrams))
+	for _, t := range trigrams {
+		mac := hmac.New(sha256.New, x.key)
+		// Префикс отделяет токены имён от слепых индексов полей
+		mac.Write([]byte("name:" + t))
+		tokens = append(tokens, hex.EncodeToString(mac.Sum(nil))[:nameTokenLen])
+	}
+	return tokens
+}
+
+// NameTokens возвращает слепые токены ФИО ключом индексов набора.
+func (k *Keyring) NameTokens(name string) []string {
+	return k.indexer.NameTokens(name)
+}
+
+// squeeze схлопывает повторяющиеся буквы: "mariia" → "maria", "ivannov" → "ivanov".
+func squeeze(s string) string {
+	var b strings.Builder
+	var prev rune
+	for _, r := range s {
+		if r != prev {
+			b.WriteRune(r)
+		}
+		prev = r
+	}
+	return b.String()
+}
@@ -0,0 +1,148 @@
+// Package ticketqr описывает формат подписанного QR-кода билета и его офлайн-проверку.
+//
+// Код имеет вид "VT1.<kid>.<payload>.<signature>": payload — JSON Claims в base64url без выравнивания,
+// signature — подпись Ed25519 строки "VT1.<kid>.<payload>" ключом kid в base64url.
+// Открытые ключи публикуются ticket-service в виде набора JWK (KeySet).
+package ticketqr
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Prefix — версия формата кода.
+const Prefix = "VT1"
+
+var (
+	// ErrMalformed возвращается, если строка не является подписанным кодом билета.
+	ErrMalformed = errors.New("malformed ticket QR code")
+	// ErrUnknownKey возвращается, если ключ подписи отсутствует в наборе или уже выведен из проверки.
+	ErrUnknownKey = errors.New("unknown QR signing key")
+	// ErrBadSignature возвращается при неверной подписи.
+	ErrBadSignature = errors.New("invalid QR code signature")
+	// ErrExpired возвращается, если срок действия кода истёк.
+	ErrExpired = errors.New("ticket QR code expired")
+	// ErrNotYetValid возвращается, если срок действия кода ещё не наступил.
+	ErrNotYetValid = errors.New("ticket QR code not yet valid")
+)
+
+// Claims — данные билета в QR-коде. Время — Unix-секунды.
+type Claims struct {
+	SeatID    *string `json:"s,omitempty"`
+	TicketID  string  `json:"t"`
+	TripID    string  `json:"r"`
+	NotBefore int64   `json:"nbf"`
+	ExpiresAt int64   `json:"exp"`
+}
+
+// Key — открытый ключ подписи в формате JWK (OKP/Ed25519).
+// NotAfter — момент, после которого ключ не принимается для проверки (для выведенных ключей).
+type Key struct {
+	NotAfter *time.Time `json:"not_after,omitempty"`
+	KeyType  string     `json:"kty"`
+	Curve    string     `json:"crv"`
+	ID       string     `json:"kid"`
+	X        string     `json:"x"`
+	Use      string     `json:"use"`
+}
+
+// KeySet — опубликованный набор открытых ключей.
+type KeySet struct {
+	Keys []Key `json:"keys"`
+}
+
+// NewKey формирует JWK для открытого ключа.
+func NewKey(kid string, pub ed25519.PublicKey, notAfter *time.Time) Key {
+	return Key{
+		NotAfter: notAfter,
+		KeyType:  "OKP",
+		Curve:    "Ed25519",
+		ID:       kid,
+		X:        base64.RawURLEncoding.EncodeToString(pub),
+		Use:      "sig",
+	}
+}
+
+// IsSigned сообщает, похожа ли строка на подписанный код (в отличие от старых кодов "TK...").
+func IsSigned(code string) bool {
+	return strings.HasPrefix(code, Prefix+".")
+}
+
+// Sign подписывает данные билета ключом kid.
+func Sign(claims *Claims, kid string, key ed25519.PrivateKey) (string, error) {
+	if kid == "" || strings.Contains(kid, ".") {
+		return "", fmt.Errorf("invalid key id %q", kid)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal claims: %w", err)
+	}
+	signingInput := Prefix + "." + kid + "." + base64.RawURLEncoding.EncodeToString(payload)
+	signature := ed25519.Sign(key, []byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Verify проверяет подпись и срок действия кода по набору ключей на момент now.
+func Verify(code string, keys *KeySet, now time.Time) (*Claims, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 4 || parts[0] != Prefix {
+		return nil, ErrMalformed
+	}
+
+	pub, err := keys.publicKey(parts[1], now)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[3])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	signingInput := code[:len(code)-len(parts[3])-1]
+	if !ed25519.Verify(pub, []byte(signingInput), signature) {
+		return nil, ErrBadSignature
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrMalformed
+	}
+
+	switch unix := now.Unix(); {
+	case unix < claims.NotBefore:
+		return nil, ErrNotYetValid
+	case unix > claims.ExpiresAt:
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+// publicKey возвращает открытый ключ kid, если он есть в наборе и ещё принимается для проверки.
+func (ks *KeySet) publicKey(kid string, now time.Time) (ed25519.PublicKey, error) {
+	for _, k := range ks.Keys {
+		if k.ID != kid {
+			continue
+		}
+		if k.KeyType != "OKP" || k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("%w: unsupported key type %s/%s", ErrUnknownKey, k.KeyType, k.Curve)
+		}
+		if k.NotAfter != nil && now.After(*k.NotAfter) {
+			return nil, ErrUnknownKey
+		}
+		raw, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: invalid public key %s", ErrUnknownKey, kid)
+		}
+		return ed25519.PublicKey(raw), nil
+	}
+	return nil, ErrUnknownKey
+}
@@ -0,0 +1,150 @@
+package ticketqr
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// signedCodeFixture — код, подписанный этим пакетом; тот же файл проверяет офлайн-верификатор
+// контроллёра (ui/controller-app/src/utils/ticketQr.test.ts).
+type signedCodeFixture struct {
+	Now    time.Time `json:"now"`
+	Seed   string    `json:"seed"`
+	KID    string    `json:"kid"`
+	Code   string    `json:"code"`
+	Keys   KeySet    `json:"keys"`
+	Claims Claims    `json:"claims"`
+}
+
+func loadFixture(t *testing.T) (*signedCodeFixture, ed25519.PrivateKey) {
+	t.Helper()
+	raw, err := os.ReadFile("testdata/signed_code.json")
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+	var f signedCodeFixture
+	if err = json.Unmarshal(raw, &f); err != nil {
+		t.Fatalf("decode fixture: %v", err)
+	}
+	seed, err := hex.DecodeString(f.Seed)
+	if err != nil || len(seed) != ed25519.SeedSize {
+		t.Fatalf("invalid fixture seed: %v", err)
+	}
+	return &f, ed25519.NewKeyFromSeed(seed)
+}
+
+func testKey(t *testing.T, kid string, notAfter *time.Time) (ed25519.PrivateKey, *KeySet) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return priv, &KeySet{Keys: []Key{NewKey(kid, pub, notAfter)}}
+}
+
+func TestSignVerifyRoundTrip(t *testing.T) {
+	priv, keys := testKey(t, "k1", nil)
+	now := time.Date(2026, 4, 15, 10, 0, 0, 0, time.UTC)
+	seat := "7"
+	claims := &Claims{
+		SeatID:    &seat,
+		TicketID:  "ticket-1",
+		TripID:    "trip-1",
+		NotBefore: now.Add(-time.Hour).Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+
+	code, err := Sign(claims, "k1", priv)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !IsSigned(code) {
+		t.Fatalf("IsSigned(%q) = false", code)
+	}
+
+	got, err := Verify(code, keys, now)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.TicketID != claims.TicketID || got.TripID != claims.TripID || got.SeatID == nil || *got.SeatID != seat ||
+		got.NotBefore != claims.NotBefore || got.ExpiresAt != claims.ExpiresAt {
+		t.Errorf("Verify claims = %+v, want %+v", got, claims)
+	}
+}
+
+func TestSignRejectsInvalidKeyID(t *testing.T) {
+	priv, _ := testKey(t, "k1", nil)
+	for _, kid := range []string{"", "k.1"} {
+		if _, err := Sign(&Claims{TicketID: "t"}, kid, priv); err == nil {
+			t.Errorf("Sign with kid %q: expected error", kid)
+		}
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	now := time.Date(2026, 4, 15, 10, 0, 0, 0, time.UTC)
+	retired := now.Add(-time.Minute)
+	priv, keys := testKey(t, "k1", nil)
+	otherPriv, _ := testKey(t, "k1", nil)
+	retiredPriv, retiredKeys := testKey(t, "old", &retired)
+
+	sign := func(priv ed25519.PrivateKey, kid string, nbf, exp time.Time) string {
+		code, err := Sign(&Claims{TicketID: "t", TripID: "r", NotBefore: nbf.Unix(), ExpiresAt: exp.Unix()}, kid, priv)
+		if err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+		return code
+	}
+	valid := sign(priv, "k1", now.Add(-time.Hour), now.Add(time.Hour))
+	// Подпись от одного кода, payload — от другого (срок действия продлён).
+	parts := strings.Split(valid, ".")
+	parts[2] = strings.Split(sign(priv, "k1", now, now.Add(48*time.Hour)), ".")[2]
+	tampered := strings.Join(parts, ".")
+
+	tests := []struct {
+		want error
+		keys *KeySet
+		name string
+		code string
+	}{
+		{name: "legacy code", code: "TK1234abcd", keys: keys, want: ErrMalformed},
+		{name: "wrong prefix", code: "VT2" + valid[3:], keys: keys, want: ErrMalformed},
+		{name: "unknown key", code: sign(priv, "k2", now.Add(-time.Hour), now.Add(time.Hour)), keys: keys, want: ErrUnknownKey},
+		{name: "retired key", code: sign(retiredPriv, "old", now.Add(-time.Hour), now.Add(time.Hour)), keys: retiredKeys, want: ErrUnknownKey},
+		{name: "foreign signature", code: sign(otherPriv, "k1", now.Add(-time.Hour), now.Add(time.Hour)), keys: keys, want: ErrBadSignature},
+		{name: "tampered payload", code: tampered, keys: keys, want: ErrBadSignature},
+		{name: "expired", code: sign(priv, "k1", now.Add(-2*time.Hour), now.Add(-time.Hour)), keys: keys, want: ErrExpired},
+		{name: "not yet valid", code: sign(priv, "k1", now.Add(time.Hour), now.Add(2*time.Hour)), keys: keys, want: ErrNotYetValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Verify(tt.code, tt.keys, now); !errors.Is(err, tt.want) {
+				t.Errorf("Verify = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedCodeFixture(t *testing.T) {
+	f, priv := loadFixture(t)
+
+	code, err := Sign(&f.Claims, f.KID, priv)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if code != f.Code {
+		t.Fatalf("fixture code is stale:\n got %s\nwant %s", code, f.Code)
+	}
+	if len(code) <= 255 {
+		t.Errorf("len(code) = %d, signed codes are expected not to fit varchar(255)", len(code))
+	}
+	if _, err = Verify(f.Code, &f.Keys, f.Now); err != nil {
+		t.Errorf("Verify fixture: %v", err)
+	}
+}
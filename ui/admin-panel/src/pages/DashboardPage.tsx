@@ -43,6 +43,25 @@ const useStyles = makeStyles({
     marginBottom: '24px',
     flexWrap: 'wrap',
   },
+  blocks: {
+    display: 'grid',
+    gap: '12px',
+    marginTop: '16px',
+  },
+  blockTrips: {
+    display: 'flex',
+    gap: '8px',
+    flexWrap: 'wrap',
+    marginTop: '8px',
+  },
+  blockTrip: {
+    padding: '8px 12px',
+    border: '1px solid #e0e0e0',
+    borderRadius: '4px',
+  },
+  knockOn: {
+    color: '#c50f1f',
+  },
   langSwitcher: {
     marginLeft: 'auto',
     minWidth: '100px',
@@ -50,6 +69,7 @@ const useStyles = makeStyles({
 });
 
 const today = () => new Date().toISOString().slice(0, 10);
+const clock = (iso: string) => iso.slice(11, 16);
 
 export const DashboardPage: React.FC = () => {
   const styles = useStyles();
@@ -78,6 +98,8 @@ export const DashboardPage: React.FC = () => {
     (ticketStats as { occupancy?: number } | undefined)?.occupancy ??
     (scheduleStats as { occupancy?: number } | undefined)?.occupancy ??
     (totalSeats > 0 ? Math.round((ticketsSold / totalSeats) * 100) : 0);
+  const vehicleBlocks = scheduleStats?.vehicle_blocks ?? [];
+  const pendingProposals = scheduleStats?.pending_delay_proposals ?? 0;
 
   return (
     <FluentProvider theme={webLightTheme}>
@@ -165,8 +187,50 @@ export const DashboardPage: React.FC = () => {
               <Text size={600} weight="bold">{occupancyPercent}%</Text>
               <Text block>{t('dashboard.statsOccupancy')}</Text>
             </Card>
+
+            <Card className={styles.statCard}>
+              <Text size={600} weight="bold">{pendingProposals}</Text>
+              <Text block>{t('dashboard.statsDelayProposals')}</Text>
+            </Card>
           </div>
         )}
+
+        {!isLoading && vehicleBlocks.length > 0 && (
+          <>
+            <Title2 style={{ margin: '24px 0 0' }}>{t('dashboard.blocksTitle')}</Title2>
+            <div className={styles.blocks}>
+              {vehicleBlocks.map((block) => (
+                <Card key={block.bus_id} className={styles.card}>
+                  <Text weight="semibold">
+                    {block.plate_number || block.bus_id} · {t('dashboard.blockTrips', { count: block.trips.length })}
+                    {block.knock_on_trips > 0 && (
+                      <span className={styles.knockOn}>
+                        {' '}· {t('dashboard.blockKnockOn', { count: block.knock_on_trips })}
+                      </span>
+                    )}
+                  </Text>
+                  <div className={styles.blockTrips}>
+                    {block.trips.map((trip) => (
+                      <div key={`${trip.trip_id}-${trip.vehicle_number}`} className={styles.blockTrip}>
+                        <Text block weight="semibold">{trip.route_name}</Text>
+                        <Text block>
+                          {clock(trip.planned_departure)}
+                          {trip.predicted_delay_minutes > 0 && ` → ${clock(trip.expected_departure)}`}
+                          {' '}({t(`trips.status_${trip.status}`, { defaultValue: trip.status })})
+                        </Text>
+                        {trip.knock_on_minutes > 0 && (
+                          <Text block className={styles.knockOn}>
+                            {t('dashboard.knockOnMinutes', { minutes: trip.knock_on_minutes })}
+                          </Text>
+                        )}
+                      </div>
+                    ))}
+                  </div>
+                </Card>
+              ))}
+            </div>
+          </>
+        )}
       </div>
     </FluentProvider>
   );
@@ -67,7 +67,7 @@ const useStyles = makeStyles({
   },
 });
 
-const ROLE_VALUES: UserRole[] = ['admin', 'dispatcher', 'cashier', 'controller', 'accountant'];
+const ROLE_VALUES: UserRole[] = ['admin', 'dispatcher', 'cashier', 'controller', 'supervisor', 'accountant'];
 
 export const UsersPage: React.FC = () => {
   const { t } = useTranslation();
@@ -9,6 +9,7 @@ const UserRoleSchema = z.enum([
   'dispatcher',
   'cashier',
   'controller',
+  'supervisor',
   'accountant',
 ]);
 
@@ -1,5 +1,5 @@
 import { apiClient } from './api';
-import type { Schedule, Trip, Route, Station, Bus, Driver } from '@/types';
+import type { Schedule, Trip, Route, Station, Bus, Driver, VehicleBlock } from '@/types';
 
 /**
  * Schedule API returns all successful responses in a wrapper: `{ data: T }`.
@@ -167,6 +167,8 @@ export const scheduleService = {
       trips_delayed: number;
       trips_arrived: number;
       total_capacity: number;
+      vehicle_blocks: VehicleBlock[];
+      pending_delay_proposals: number;
     };
     const response = await apiClient.get<{ data: Stats }>('/schedule/stats/dashboard', {
       params: date ? { date } : {},
@@ -180,6 +182,8 @@ export const scheduleService = {
       trips_delayed: 0,
       trips_arrived: 0,
       total_capacity: 0,
+      vehicle_blocks: [],
+      pending_delay_proposals: 0,
     };
   },
 };
@@ -1,5 +1,5 @@
 // User roles
-export type UserRole = 'admin' | 'dispatcher' | 'cashier' | 'controller' | 'accountant';
+export type UserRole = 'admin' | 'dispatcher' | 'cashier' | 'controller' | 'supervisor' | 'accountant';
 
 // Auth (login response user)
 export interface User {
@@ -146,6 +146,29 @@ export interface Trip {
   updated_at: string;
 }
 
+// Vehicle block — цепочка рейсов одного автобуса за день
+export interface BlockTrip {
+  trip_id: string;
+  route_name: string;
+  status: Trip['status'];
+  vehicle_number: number;
+  planned_departure: string;
+  expected_departure: string;
+  expected_arrival: string;
+  delay_minutes: number;
+  predicted_delay_minutes: number;
+  knock_on_minutes: number;
+}
+
+export interface VehicleBlock {
+  bus_id: string;
+  plate_number?: string;
+  date: string;
+  capacity: number;
+  knock_on_trips: number;
+  trips: BlockTrip[];
+}
+
 // Ticket
 export interface Ticket {
   id: string;
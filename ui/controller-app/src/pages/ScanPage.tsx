@@ -21,6 +21,13 @@ import { ticketService } from '@/services/ticket';
 import { tripService } from '@/services/trip';
 import { QRScanner } from '@/components/QRScanner';
 import { formatTime, formatDateTime, playSuccessSound, playErrorSound, vibratePhone } from '@/utils/format';
+import {
+  isSignedTicketQR,
+  loadQRKeys,
+  saveQRKeys,
+  ticketQRErrorMessages,
+  verifyTicketQR,
+} from '@/utils/ticketQr';
 import type { Ticket } from '@/types';
 
 const useStyles = makeStyles({
@@ -139,6 +146,13 @@ export const ScanPage = () => {
     }
   }, [currentTrip, navigate]);
 
+  useEffect(() => {
+    // Обновить ключи проверки QR-кодов; без сети используется сохранённый набор
+    ticketService.getQRKeys()
+      .then(saveQRKeys)
+      .catch((err) => console.warn('Failed to refresh QR keys, using cached set:', err));
+  }, []);
+
   useEffect(() => {
     // Refresh stats every 10 seconds
     const interval = setInterval(async () => {
@@ -155,57 +169,76 @@ export const ScanPage = () => {
     return () => clearInterval(interval);
   }, [currentTrip, setStats]);
 
+  const showResult = (result: { success: boolean; ticket?: Ticket; message: string }) => {
+    setScanResult(result);
+    if (result.success) {
+      playSuccessSound();
+      vibratePhone([100, 50, 100]);
+    } else {
+      playErrorSound();
+      vibratePhone(500);
+    }
+
+    // Auto-clear result and resume scanning after 3 seconds
+    setTimeout(() => {
+      setScanResult(null);
+      setIsScanning(true);
+    }, 3000);
+  };
+
   const boardingMutation = useMutation({
     mutationFn: (qrCode: string) => ticketService.markBoarding({
       ticketId: '',
       qrCode,
     }),
     onSuccess: (data) => {
-      setScanResult({
+      showResult({
         success: data.success,
         ticket: data.ticket,
         message: data.message,
       });
-      
+
       if (data.success) {
-        playSuccessSound();
-        vibratePhone([100, 50, 100]);
         addRecentScan(data.ticket);
-        
+
         // Refresh stats
         if (currentTrip) {
           tripService.getStats(currentTrip.id).then(setStats);
         }
-      } else {
-        playErrorSound();
-        vibratePhone(500);
       }
-
-      // Auto-clear result and resume scanning after 3 seconds
-      setTimeout(() => {
-        setScanResult(null);
-        setIsScanning(true);
-      }, 3000);
     },
-    onError: (error) => {
-      setScanResult({
+    onError: (error, qrCode) => {
+      // Без связи с сервером подписанный код, прошедший офлайн-проверку, считается действительным
+      if (isSignedTicketQR(qrCode) && !navigator.onLine) {
+        showResult({ success: true, message: 'Билет действителен (офлайн-проверка)' });
+        return;
+      }
+      showResult({
         success: false,
         message: error instanceof Error ? error.message : 'Ошибка проверки билета',
       });
-      playErrorSound();
-      vibratePhone(500);
-
-      // Auto-clear error and resume scanning after 3 seconds
-      setTimeout(() => {
-        setScanResult(null);
-        setIsScanning(true);
-      }, 3000);
     },
   });
 
-  const handleQRScan = (qrCode: string) => {
+  const handleQRScan = async (qrCode: string) => {
     setIsScanning(false);
     setScanResult(null);
+
+    if (isSignedTicketQR(qrCode)) {
+      const keys = loadQRKeys();
+      if (keys) {
+        const verification = await verifyTicketQR(qrCode, keys);
+        if (!verification.valid) {
+          showResult({ success: false, message: ticketQRErrorMessages[verification.error] });
+          return;
+        }
+        if (currentTrip && verification.claims.r !== currentTrip.id) {
+          showResult({ success: false, message: 'Билет на другой рейс' });
+          return;
+        }
+      }
+    }
+
     boardingMutation.mutate(qrCode);
   };
 
@@ -1,5 +1,5 @@
 import api from './api';
-import { ApiResponse, Ticket, BoardingRequest, BoardingResponse } from '@/types';
+import { ApiResponse, Ticket, BoardingRequest, BoardingResponse, QRKeySet } from '@/types';
 
 export const ticketService = {
   async getById(id: string): Promise<Ticket> {
@@ -22,6 +22,11 @@ export const ticketService = {
     return response.data.data;
   },
 
+  async getQRKeys(): Promise<QRKeySet> {
+    const response = await api.get<QRKeySet>('/tickets/qr/keys');
+    return response.data;
+  },
+
   async getByTrip(tripId: string): Promise<Ticket[]> {
     const response = await api.get<ApiResponse<Ticket[]>>('/tickets', {
       params: { tripId },
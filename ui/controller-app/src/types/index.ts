@@ -11,7 +11,7 @@ export interface User {
   username: string;
   email: string;
   fullName: string;
-  role: 'cashier' | 'dispatcher' | 'controller' | 'accountant' | 'admin';
+  role: 'cashier' | 'dispatcher' | 'controller' | 'supervisor' | 'accountant' | 'admin';
   phone?: string;
   createdAt: string;
   updatedAt: string;
@@ -98,6 +98,29 @@ export interface BoardingResponse {
   message: string;
 }
 
+// Открытый ключ подписи QR-кодов (JWK, Ed25519)
+export interface QRKey {
+  kty: string;
+  crv: string;
+  kid: string;
+  x: string;
+  use: string;
+  not_after?: string;
+}
+
+export interface QRKeySet {
+  keys: QRKey[];
+}
+
+// Данные билета в подписанном QR-коде (время — Unix-секунды)
+export interface TicketQRClaims {
+  t: string;
+  r: string;
+  s?: string;
+  nbf: number;
+  exp: number;
+}
+
 export interface TripStats {
   tripId: string;
   totalSeats: number;
@@ -0,0 +1,42 @@
+import { describe, expect, it } from 'vitest';
+import type { QRKeySet, TicketQRClaims } from '@/types';
+import fixture from '../../../../shared/go-common/ticketqr/testdata/signed_code.json';
+import { verifyTicketQR } from './ticketQr';
+
+// Код подписан go-common/ticketqr (TestSignedCodeFixture проверяет, что фикстура актуальна).
+const keys = fixture.keys as QRKeySet;
+const claims = fixture.claims as TicketQRClaims;
+const now = new Date(fixture.now);
+
+describe('verifyTicketQR', () => {
+  it('accepts a code signed by the Go service', async () => {
+    await expect(verifyTicketQR(fixture.code, keys, now)).resolves.toEqual({ valid: true, claims });
+  });
+
+  it('rejects a code with a modified payload', async () => {
+    const [prefix, kid, , signature] = fixture.code.split('.');
+    const payload = btoa(JSON.stringify({ ...claims, exp: claims.exp + 86400 }))
+      .replace(/\+/g, '-')
+      .replace(/\//g, '_')
+      .replace(/=+$/, '');
+    await expect(verifyTicketQR([prefix, kid, payload, signature].join('.'), keys, now)).resolves.toEqual({
+      valid: false,
+      error: 'bad_signature',
+    });
+  });
+
+  it('rejects an expired code', async () => {
+    await expect(verifyTicketQR(fixture.code, keys, new Date((claims.exp + 1) * 1000))).resolves.toEqual({
+      valid: false,
+      error: 'expired',
+    });
+  });
+
+  it('rejects a code signed by a retired key', async () => {
+    const retired: QRKeySet = { keys: keys.keys.map((k) => ({ ...k, not_after: '2026-04-01T00:00:00Z' })) };
+    await expect(verifyTicketQR(fixture.code, retired, now)).resolves.toEqual({
+      valid: false,
+      error: 'unknown_key',
+    });
+  });
+});
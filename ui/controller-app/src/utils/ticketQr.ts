@@ -0,0 +1,102 @@
+import type { QRKeySet, TicketQRClaims } from '@/types';
+
+// Формат подписанного кода билета: "VT1.<kid>.<payload>.<signature>" (см. go-common/ticketqr).
+const PREFIX = 'VT1';
+const KEYS_STORAGE_KEY = 'ticket-qr-keys';
+
+export type TicketQRError = 'malformed' | 'unknown_key' | 'bad_signature' | 'expired' | 'not_yet_valid';
+
+export type TicketQRVerification =
+  | { valid: true; claims: TicketQRClaims }
+  | { valid: false; error: TicketQRError };
+
+export const ticketQRErrorMessages: Record<TicketQRError, string> = {
+  malformed: 'Неверный формат QR-кода',
+  unknown_key: 'Неизвестный ключ подписи',
+  bad_signature: 'Поддельный QR-код',
+  expired: 'Срок действия билета истёк',
+  not_yet_valid: 'Билет ещё не действителен',
+};
+
+export const isSignedTicketQR = (code: string): boolean => code.startsWith(`${PREFIX}.`);
+
+const base64UrlDecode = (value: string): Uint8Array => {
+  const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = base64 + '='.repeat((4 - (base64.length % 4)) % 4);
+  const binary = atob(padded);
+  const bytes = new Uint8Array(binary.length);
+  for (let i = 0; i < binary.length; i++) {
+    bytes[i] = binary.charCodeAt(i);
+  }
+  return bytes;
+};
+
+export const saveQRKeys = (keys: QRKeySet): void => {
+  localStorage.setItem(KEYS_STORAGE_KEY, JSON.stringify(keys));
+};
+
+export const loadQRKeys = (): QRKeySet | null => {
+  const raw = localStorage.getItem(KEYS_STORAGE_KEY);
+  if (!raw) {
+    return null;
+  }
+  try {
+    return JSON.parse(raw) as QRKeySet;
+  } catch {
+    return null;
+  }
+};
+
+// Проверяет подпись Ed25519 и срок действия кода по набору ключей без обращения к серверу.
+export const verifyTicketQR = async (
+  code: string,
+  keys: QRKeySet,
+  now: Date = new Date()
+): Promise<TicketQRVerification> => {
+  const parts = code.split('.');
+  if (parts.length !== 4 || parts[0] !== PREFIX) {
+    return { valid: false, error: 'malformed' };
+  }
+  const [, kid, payload, signature] = parts;
+
+  const key = keys.keys.find((k) => k.kid === kid);
+  if (!key || key.kty !== 'OKP' || key.crv !== 'Ed25519') {
+    return { valid: false, error: 'unknown_key' };
+  }
+  if (key.not_after && now > new Date(key.not_after)) {
+    return { valid: false, error: 'unknown_key' };
+  }
+
+  try {
+    const publicKey = await crypto.subtle.importKey(
+      'raw',
+      base64UrlDecode(key.x),
+      { name: 'Ed25519' },
+      false,
+      ['verify']
+    );
+    const signingInput = new TextEncoder().encode(`${PREFIX}.${kid}.${payload}`);
+    const ok = await crypto.subtle.verify({ name: 'Ed25519' }, publicKey, base64UrlDecode(signature), signingInput);
+    if (!ok) {
+      return { valid: false, error: 'bad_signature' };
+    }
+  } catch {
+    return { valid: false, error: 'malformed' };
+  }
+
+  let claims: TicketQRClaims;
+  try {
+    claims = JSON.parse(new TextDecoder().decode(base64UrlDecode(payload))) as TicketQRClaims;
+  } catch {
+    return { valid: false, error: 'malformed' };
+  }
+
+  const unix = Math.floor(now.getTime() / 1000);
+  if (unix < claims.nbf) {
+    return { valid: false, error: 'not_yet_valid' };
+  }
+  if (unix > claims.exp) {
+    return { valid: false, error: 'expired' };
+  }
+  return { valid: true, claims };
+};